@@ -20,9 +20,11 @@ const _ = grpc.SupportPackageIsVersion9
 
 const (
 	UserService_GetUser_FullMethodName            = "/user.UserService/GetUser"
+	UserService_GetUsers_FullMethodName           = "/user.UserService/GetUsers"
 	UserService_GetUserPermissions_FullMethodName = "/user.UserService/GetUserPermissions"
 	UserService_ValidateToken_FullMethodName      = "/user.UserService/ValidateToken"
 	UserService_HasPermission_FullMethodName      = "/user.UserService/HasPermission"
+	UserService_WhoAmI_FullMethodName             = "/user.UserService/WhoAmI"
 )
 
 // UserServiceClient is the client API for UserService service.
@@ -31,12 +33,17 @@ const (
 type UserServiceClient interface {
 	// GetUser retrieves a user profile by ID
 	GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*UserProfile, error)
+	// GetUsers retrieves a page of users, optionally filtered by a search query and active state
+	GetUsers(ctx context.Context, in *GetUsersRequest, opts ...grpc.CallOption) (*GetUsersResponse, error)
 	// GetUserPermissions retrieves all permissions for a user
 	GetUserPermissions(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*UserPermissionsResponse, error)
 	// ValidateToken validates a JWT token and returns user info
 	ValidateToken(ctx context.Context, in *ValidateTokenRequest, opts ...grpc.CallOption) (*TokenValidationResponse, error)
 	// HasPermission checks if a user has a specific permission
 	HasPermission(ctx context.Context, in *HasPermissionRequest, opts ...grpc.CallOption) (*HasPermissionResponse, error)
+	// WhoAmI resolves the caller's bearer token, sent as "authorization" metadata, to their
+	// identity and effective permissions
+	WhoAmI(ctx context.Context, in *WhoAmIRequest, opts ...grpc.CallOption) (*WhoAmIResponse, error)
 }
 
 type userServiceClient struct {
@@ -57,6 +64,16 @@ func (c *userServiceClient) GetUser(ctx context.Context, in *GetUserRequest, opt
 	return out, nil
 }
 
+func (c *userServiceClient) GetUsers(ctx context.Context, in *GetUsersRequest, opts ...grpc.CallOption) (*GetUsersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetUsersResponse)
+	err := c.cc.Invoke(ctx, UserService_GetUsers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *userServiceClient) GetUserPermissions(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*UserPermissionsResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(UserPermissionsResponse)
@@ -87,18 +104,33 @@ func (c *userServiceClient) HasPermission(ctx context.Context, in *HasPermission
 	return out, nil
 }
 
+func (c *userServiceClient) WhoAmI(ctx context.Context, in *WhoAmIRequest, opts ...grpc.CallOption) (*WhoAmIResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WhoAmIResponse)
+	err := c.cc.Invoke(ctx, UserService_WhoAmI_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // UserServiceServer is the server API for UserService service.
 // All implementations must embed UnimplementedUserServiceServer
 // for forward compatibility.
 type UserServiceServer interface {
 	// GetUser retrieves a user profile by ID
 	GetUser(context.Context, *GetUserRequest) (*UserProfile, error)
+	// GetUsers retrieves a page of users, optionally filtered by a search query and active state
+	GetUsers(context.Context, *GetUsersRequest) (*GetUsersResponse, error)
 	// GetUserPermissions retrieves all permissions for a user
 	GetUserPermissions(context.Context, *GetUserRequest) (*UserPermissionsResponse, error)
 	// ValidateToken validates a JWT token and returns user info
 	ValidateToken(context.Context, *ValidateTokenRequest) (*TokenValidationResponse, error)
 	// HasPermission checks if a user has a specific permission
 	HasPermission(context.Context, *HasPermissionRequest) (*HasPermissionResponse, error)
+	// WhoAmI resolves the caller's bearer token, sent as "authorization" metadata, to their
+	// identity and effective permissions
+	WhoAmI(context.Context, *WhoAmIRequest) (*WhoAmIResponse, error)
 	mustEmbedUnimplementedUserServiceServer()
 }
 
@@ -112,6 +144,9 @@ type UnimplementedUserServiceServer struct{}
 func (UnimplementedUserServiceServer) GetUser(context.Context, *GetUserRequest) (*UserProfile, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetUser not implemented")
 }
+func (UnimplementedUserServiceServer) GetUsers(context.Context, *GetUsersRequest) (*GetUsersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUsers not implemented")
+}
 func (UnimplementedUserServiceServer) GetUserPermissions(context.Context, *GetUserRequest) (*UserPermissionsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetUserPermissions not implemented")
 }
@@ -121,6 +156,9 @@ func (UnimplementedUserServiceServer) ValidateToken(context.Context, *ValidateTo
 func (UnimplementedUserServiceServer) HasPermission(context.Context, *HasPermissionRequest) (*HasPermissionResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method HasPermission not implemented")
 }
+func (UnimplementedUserServiceServer) WhoAmI(context.Context, *WhoAmIRequest) (*WhoAmIResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WhoAmI not implemented")
+}
 func (UnimplementedUserServiceServer) mustEmbedUnimplementedUserServiceServer() {}
 func (UnimplementedUserServiceServer) testEmbeddedByValue()                     {}
 
@@ -160,6 +198,24 @@ func _UserService_GetUser_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _UserService_GetUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUsersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).GetUsers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_GetUsers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).GetUsers(ctx, req.(*GetUsersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _UserService_GetUserPermissions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetUserRequest)
 	if err := dec(in); err != nil {
@@ -214,6 +270,24 @@ func _UserService_HasPermission_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _UserService_WhoAmI_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WhoAmIRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).WhoAmI(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_WhoAmI_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).WhoAmI(ctx, req.(*WhoAmIRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // UserService_ServiceDesc is the grpc.ServiceDesc for UserService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -225,6 +299,10 @@ var UserService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetUser",
 			Handler:    _UserService_GetUser_Handler,
 		},
+		{
+			MethodName: "GetUsers",
+			Handler:    _UserService_GetUsers_Handler,
+		},
 		{
 			MethodName: "GetUserPermissions",
 			Handler:    _UserService_GetUserPermissions_Handler,
@@ -237,6 +315,10 @@ var UserService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "HasPermission",
 			Handler:    _UserService_HasPermission_Handler,
 		},
+		{
+			MethodName: "WhoAmI",
+			Handler:    _UserService_WhoAmI_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "api/grpc/proto/user.proto",
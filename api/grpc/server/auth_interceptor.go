@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+
+	"github.com/chats/go-user-api/internal/services"
+	"github.com/chats/go-user-api/internal/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// claimsKey is the context key the caller's verified token claims are stored under
+type claimsKey struct{}
+
+// AuthInterceptor verifies the bearer token in the "authorization" metadata and stores its claims in the
+// context for ClaimsFromContext. Calls without a token pass through, since most methods name the user in
+// the request; a token that is present but invalid is rejected as Unauthenticated.
+func AuthInterceptor(authService *services.AuthService) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		values := metadata.ValueFromIncomingContext(ctx, "authorization")
+		if len(values) == 0 {
+			return handler(ctx, req)
+		}
+
+		tokenString, err := utils.ExtractBearerToken(values[0])
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+
+		claims, err := authService.VerifyToken(ctx, tokenString)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+
+		// Tokens issued for an expired password only allow changing it, which is HTTP only
+		if claims.Scope == utils.ScopePasswordChange {
+			return nil, status.Error(codes.Unauthenticated, "password has expired and must be changed")
+		}
+
+		return handler(context.WithValue(ctx, claimsKey{}, claims), req)
+	}
+}
+
+// ClaimsFromContext returns the claims of the caller's token verified by AuthInterceptor
+func ClaimsFromContext(ctx context.Context) (*utils.JWTClaims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*utils.JWTClaims)
+	return claims, ok
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"time"
+
+	"github.com/chats/go-user-api/api/grpc/pb"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/golang/protobuf/ptypes/timestamp"
+)
+
+// Protobuf messages are built from the HTTP response models rather than the stored models, so both
+// APIs expose the same fields; a field added to a response belongs in its message too
+
+// toTimestamp converts a time to its protobuf message
+func toTimestamp(t time.Time) *timestamp.Timestamp {
+	return &timestamp.Timestamp{
+		Seconds: t.Unix(),
+		Nanos:   int32(t.Nanosecond()),
+	}
+}
+
+// toPermission converts a permission to its protobuf message
+func toPermission(permission models.PermissionResponse) *pb.Permission {
+	return &pb.Permission{
+		Id:          permission.ID.String(),
+		Name:        permission.Name,
+		Resource:    permission.Resource,
+		Action:      permission.Action,
+		Description: permission.Description,
+		Category:    permission.Category,
+		CreatedAt:   toTimestamp(permission.CreatedAt),
+		UpdatedAt:   toTimestamp(permission.UpdatedAt),
+	}
+}
+
+// toPermissions converts permissions to their protobuf messages
+func toPermissions(permissions []models.PermissionResponse) []*pb.Permission {
+	protoPermissions := make([]*pb.Permission, len(permissions))
+	for i, permission := range permissions {
+		protoPermissions[i] = toPermission(permission)
+	}
+	return protoPermissions
+}
+
+// toRole converts a role to its protobuf message
+func toRole(role models.RoleResponse) *pb.Role {
+	return &pb.Role{
+		Id:          role.ID.String(),
+		Name:        role.Name,
+		Description: role.Description,
+		CreatedAt:   toTimestamp(role.CreatedAt),
+		UpdatedAt:   toTimestamp(role.UpdatedAt),
+	}
+}
+
+// toUserProfile converts a user to its protobuf message
+func toUserProfile(user *models.UserResponse) *pb.UserProfile {
+	roles := make([]*pb.Role, len(user.Roles))
+	for i, role := range user.Roles {
+		roles[i] = toRole(role)
+	}
+
+	return &pb.UserProfile{
+		Id:        user.ID.String(),
+		Username:  user.Username,
+		Email:     user.Email,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		IsActive:  user.IsActive,
+		CreatedAt: toTimestamp(user.CreatedAt),
+		UpdatedAt: toTimestamp(user.UpdatedAt),
+		Roles:     roles,
+	}
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"errors"
+
+	"github.com/chats/go-user-api/internal/services"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// serviceStatus converts a failed service call to a gRPC status error: InvalidArgument when an ID is not a
+// valid UUID, Canceled when the client canceled the request, DeadlineExceeded when its deadline passed,
+// and code for any other error
+func serviceStatus(err error, code codes.Code, message string) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidID):
+		code = codes.InvalidArgument
+	case errors.Is(err, services.ErrRequestCanceled):
+		code = codes.Canceled
+	case errors.Is(err, services.ErrRequestTimeout):
+		code = codes.DeadlineExceeded
+	}
+
+	return status.Errorf(code, "%s: %v", message, err)
+}
+
+// endedEarly reports whether a service call failed because its request was canceled or timed out
+func endedEarly(err error) bool {
+	return errors.Is(err, services.ErrRequestCanceled) || errors.Is(err, services.ErrRequestTimeout)
+}
+
+// errorLog returns the event a failed service call is logged on. A client canceling its request is
+// not a server error, so it is only logged at debug level.
+func errorLog(err error) *zerolog.Event {
+	if errors.Is(err, services.ErrRequestCanceled) {
+		return log.Debug()
+	}
+	return log.Error()
+}
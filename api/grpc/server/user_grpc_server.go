@@ -8,10 +8,10 @@ import (
 
 	"github.com/chats/go-user-api/api/grpc/pb"
 	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/models"
 	"github.com/chats/go-user-api/internal/services"
 	"github.com/chats/go-user-api/internal/tracing"
 	"github.com/chats/go-user-api/internal/utils"
-	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/rs/zerolog/log"
 	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc/codes"
@@ -56,45 +56,75 @@ func (s *UserGRPCServer) GetUser(ctx context.Context, req *pb.GetUserRequest) (*
 	if err != nil {
 		s.tracer.RecordError(ctx, err)
 
-		log.Error().Err(err).
+		errorLog(err).Err(err).
 			Str("user_id", req.UserId).
 			Msg("gRPC: Failed to get user")
 
-		return nil, status.Errorf(codes.NotFound, "User not found: %v", err)
+		return nil, serviceStatus(err, codes.NotFound, "User not found")
 	}
 
-	// Convert to protobuf message
-	createdAt := &timestamp.Timestamp{
-		Seconds: user.CreatedAt.Unix(),
-		Nanos:   int32(user.CreatedAt.Nanosecond()),
+	return toUserProfile(user), nil
+}
+
+// GetUsers retrieves a page of users, filtered by a search query and active state when set
+func (s *UserGRPCServer) GetUsers(ctx context.Context, req *pb.GetUsersRequest) (*pb.GetUsersResponse, error) {
+	ctx, span := s.tracer.StartSpan(ctx, "UserGRPCServer.GetUsers")
+	defer span.End()
+
+	// Zero means unset and takes the same defaults as the HTTP API
+	if req.Page < 0 || req.PageSize < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "page and page_size must not be negative")
 	}
 
-	updatedAt := &timestamp.Timestamp{
-		Seconds: user.UpdatedAt.Unix(),
-		Nanos:   int32(user.UpdatedAt.Nanosecond()),
+	page := int(req.Page)
+	if page < 1 {
+		page = 1
+	}
+	pageSize := int(req.PageSize)
+	if pageSize < 1 {
+		pageSize = 10
 	}
 
-	// Convert roles
-	roles := make([]*pb.Role, len(user.Roles))
-	for i, role := range user.Roles {
-		roles[i] = &pb.Role{
-			Id:          role.ID.String(),
-			Name:        role.Name,
-			Description: role.Description,
-		}
+	filter := models.UserFilter{
+		Query:    strings.TrimSpace(req.Q),
+		IsActive: req.IsActive,
 	}
 
-	// Log activity
-	return &pb.UserProfile{
-		Id:        user.ID.String(),
-		Username:  user.Username,
-		Email:     user.Email,
-		FirstName: user.FirstName,
-		LastName:  user.LastName,
-		IsActive:  user.IsActive,
-		CreatedAt: createdAt,
-		UpdatedAt: updatedAt,
-		Roles:     roles,
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("page", page),
+		attribute.Int("page_size", pageSize),
+		attribute.Bool("filtered", !filter.IsEmpty()),
+	)
+
+	var users []models.UserResponse
+	var totalCount int
+	var err error
+	if filter.IsEmpty() {
+		users, totalCount, err = s.userService.GetAllUsers(ctx, page, pageSize, models.SortOptions{})
+	} else {
+		users, totalCount, err = s.userService.SearchUsers(ctx, filter, page, pageSize)
+	}
+	if err != nil {
+		s.tracer.RecordError(ctx, err)
+
+		errorLog(err).Err(err).
+			Int("page", page).
+			Int("page_size", pageSize).
+			Msg("gRPC: Failed to get users")
+
+		return nil, serviceStatus(err, codes.Internal, "Failed to get users")
+	}
+
+	profiles := make([]*pb.UserProfile, len(users))
+	for i := range users {
+		profiles[i] = toUserProfile(&users[i])
+	}
+
+	return &pb.GetUsersResponse{
+		Users:      profiles,
+		TotalCount: int32(totalCount),
+		Page:       int32(page),
+		TotalPages: int32((totalCount + pageSize - 1) / pageSize),
 	}, nil
 }
 
@@ -112,11 +142,11 @@ func (s *UserGRPCServer) GetUserPermissions(ctx context.Context, req *pb.GetUser
 	if err != nil {
 		s.tracer.RecordError(ctx, err)
 
-		log.Error().Err(err).
+		errorLog(err).Err(err).
 			Str("user_id", req.UserId).
 			Msg("gRPC: User not found for permissions lookup")
 
-		return nil, status.Errorf(codes.NotFound, "User not found: %v", err)
+		return nil, serviceStatus(err, codes.NotFound, "User not found")
 	}
 
 	// Get user permissions
@@ -124,27 +154,15 @@ func (s *UserGRPCServer) GetUserPermissions(ctx context.Context, req *pb.GetUser
 	if err != nil {
 		s.tracer.RecordError(ctx, err)
 
-		log.Error().Err(err).
+		errorLog(err).Err(err).
 			Str("user_id", req.UserId).
 			Msg("gRPC: Failed to get user permissions")
 
-		return nil, status.Errorf(codes.Internal, "Failed to get user permissions: %v", err)
-	}
-
-	// Convert to protobuf message
-	protoPermissions := make([]*pb.Permission, len(permissions))
-	for i, perm := range permissions {
-		protoPermissions[i] = &pb.Permission{
-			Id:          perm.ID.String(),
-			Name:        perm.Name,
-			Resource:    perm.Resource,
-			Action:      perm.Action,
-			Description: perm.Description,
-		}
+		return nil, serviceStatus(err, codes.Internal, "Failed to get user permissions")
 	}
 
 	return &pb.UserPermissionsResponse{
-		Permissions: protoPermissions,
+		Permissions: toPermissions(permissions),
 	}, nil
 }
 
@@ -153,8 +171,9 @@ func (s *UserGRPCServer) ValidateToken(ctx context.Context, req *pb.ValidateToke
 	ctx, span := s.tracer.StartSpan(ctx, "UserGRPCServer.ValidateToken")
 	defer span.End()
 
-	// Parse and verify the token
-	claims, err := utils.ParseJWT(req.Token, s.config)
+	// Verify the token, rejecting it when the user's tokens were revoked or, if enabled, when it
+	// predates the user's last role change
+	claims, err := s.authService.VerifyToken(ctx, req.Token)
 	if err != nil {
 		s.tracer.RecordError(ctx, err)
 
@@ -170,12 +189,31 @@ func (s *UserGRPCServer) ValidateToken(ctx context.Context, req *pb.ValidateToke
 		}, nil
 	}
 
+	// Tokens issued for an expired password only allow changing it
+	if claims.Scope == utils.ScopePasswordChange {
+		return &pb.TokenValidationResponse{
+			IsValid: false,
+			Error: &pb.Error{
+				Code:    "password_change_required",
+				Message: "password has expired and must be changed",
+			},
+		}, nil
+	}
+
+	roles, err := s.tokenRoles(ctx, claims)
+	if err != nil {
+		s.tracer.RecordError(ctx, err)
+
+		errorLog(err).Err(err).
+			Str("user_id", claims.UserID).
+			Msg("gRPC: Failed to get token roles")
+
+		return nil, serviceStatus(err, codes.Internal, "Failed to get user roles")
+	}
+
 	// Get expiration time
 	expTime := time.Unix(claims.ExpiresAt.Unix(), 0)
-	expProto := &timestamp.Timestamp{
-		Seconds: expTime.Unix(),
-		Nanos:   int32(expTime.Nanosecond()),
-	}
+	expProto := toTimestamp(expTime)
 
 	s.tracer.SetAttributes(ctx,
 		attribute.String("user_id", claims.UserID),
@@ -187,12 +225,21 @@ func (s *UserGRPCServer) ValidateToken(ctx context.Context, req *pb.ValidateToke
 		IsValid:   true,
 		UserId:    claims.UserID,
 		Username:  claims.Username,
-		Roles:     claims.Roles,
+		Roles:     roles,
 		ExpiresAt: expProto,
 		Error:     nil,
 	}, nil
 }
 
+// tokenRoles returns the roles a token carries, looking up the user's current roles when the user had
+// more roles than JWT_MAX_ROLES allows and the token carries none, as the HTTP API does
+func (s *UserGRPCServer) tokenRoles(ctx context.Context, claims *utils.JWTClaims) ([]string, error) {
+	if !claims.RolesOmitted {
+		return claims.Roles, nil
+	}
+	return s.authService.GetUserRoleNames(ctx, claims.UserID)
+}
+
 // HasPermission checks if a user has a specific permission
 func (s *UserGRPCServer) HasPermission(ctx context.Context, req *pb.HasPermissionRequest) (*pb.HasPermissionResponse, error) {
 	ctx, span := s.tracer.StartSpan(ctx, "UserGRPCServer.HasPermission")
@@ -209,12 +256,17 @@ func (s *UserGRPCServer) HasPermission(ctx context.Context, req *pb.HasPermissio
 	if err != nil {
 		s.tracer.RecordError(ctx, err)
 
-		log.Error().Err(err).
+		errorLog(err).Err(err).
 			Str("user_id", req.UserId).
 			Str("resource", req.Resource).
 			Str("action", req.Action).
 			Msg("gRPC: Failed to check permission")
 
+		// A request that ended early says nothing about the permission
+		if endedEarly(err) {
+			return nil, serviceStatus(err, codes.Internal, "Failed to check permission")
+		}
+
 		// If it's a "not found" error
 		if strings.Contains(strings.ToLower(err.Error()), "not found") {
 			return &pb.HasPermissionResponse{
@@ -240,3 +292,49 @@ func (s *UserGRPCServer) HasPermission(ctx context.Context, req *pb.HasPermissio
 		Error:         nil,
 	}, nil
 }
+
+// WhoAmI returns the identity and effective permissions of the caller whose token AuthInterceptor verified
+func (s *UserGRPCServer) WhoAmI(ctx context.Context, req *pb.WhoAmIRequest) (*pb.WhoAmIResponse, error) {
+	ctx, span := s.tracer.StartSpan(ctx, "UserGRPCServer.WhoAmI")
+	defer span.End()
+
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token in authorization metadata")
+	}
+
+	s.tracer.SetAttributes(ctx,
+		attribute.String("user_id", claims.UserID),
+		attribute.String("username", claims.Username),
+	)
+
+	roles, err := s.tokenRoles(ctx, claims)
+	if err != nil {
+		s.tracer.RecordError(ctx, err)
+
+		errorLog(err).Err(err).
+			Str("user_id", claims.UserID).
+			Msg("gRPC: Failed to get caller roles")
+
+		return nil, serviceStatus(err, codes.Internal, "Failed to get user roles")
+	}
+
+	// Permissions come from the user's current roles, loaded in one batched and cached lookup
+	permissions, err := s.userService.GetUserPermissions(ctx, claims.UserID)
+	if err != nil {
+		s.tracer.RecordError(ctx, err)
+
+		errorLog(err).Err(err).
+			Str("user_id", claims.UserID).
+			Msg("gRPC: Failed to get caller permissions")
+
+		return nil, serviceStatus(err, codes.Internal, "Failed to get user permissions")
+	}
+
+	return &pb.WhoAmIResponse{
+		UserId:      claims.UserID,
+		Username:    claims.Username,
+		Roles:       roles,
+		Permissions: toPermissions(permissions),
+	}, nil
+}
@@ -0,0 +1,400 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/chats/go-user-api/api/grpc/pb"
+	"github.com/chats/go-user-api/api/grpc/server"
+	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/mocks"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/repositories/transaction"
+	"github.com/chats/go-user-api/internal/services"
+	"github.com/chats/go-user-api/internal/tracing"
+	"github.com/chats/go-user-api/internal/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func testConfig() *config.Config {
+	return &config.Config{
+		JWTSecret:       "test-secret-key",
+		JWTExpireMinute: 60,
+		JaegerEndpoint:  "http://localhost:14268/api/traces",
+	}
+}
+
+// newTestClient serves a UserGRPCServer behind the auth interceptor over an in-memory connection
+// and returns a client for it
+func newTestClient(t *testing.T, userRepo *mocks.MockUserRepository) pb.UserServiceClient {
+	t.Helper()
+
+	cfg := testConfig()
+	tracer, err := tracing.NewTracer(cfg)
+	require.NoError(t, err)
+
+	userService := services.NewUserService(userRepo, new(mocks.MockRoleRepository), new(mocks.Manager[transaction.Repository]), services.DefaultUserServiceOptions())
+	authService := services.NewAuthService(userRepo, cfg)
+
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(server.AuthInterceptor(authService)))
+	pb.RegisterUserServiceServer(grpcServer, server.NewUserGRPCServer(userService, authService, tracer, cfg))
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewUserServiceClient(conn)
+}
+
+func testUsers(n int) []*models.User {
+	users := make([]*models.User, n)
+	for i := range users {
+		users[i] = &models.User{
+			ID:        uuid.New(),
+			Username:  "user" + string(rune('a'+i)),
+			Email:     "user" + string(rune('a'+i)) + "@example.com",
+			IsActive:  true,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+	}
+	return users
+}
+
+func userIDs(users []*models.User) []uuid.UUID {
+	ids := make([]uuid.UUID, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+	return ids
+}
+
+func TestUserGRPCServer_GetUsers(t *testing.T) {
+	users := testUsers(5)
+
+	t.Run("Pages through all users", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetAll", mock.Anything, 2, 2, models.SortOptions{}).Return(users[2:4], nil)
+		mockUserRepo.On("CountUsers", mock.Anything).Return(5, nil)
+		client := newTestClient(t, mockUserRepo)
+
+		response, err := client.GetUsers(context.Background(), &pb.GetUsersRequest{Page: 2, PageSize: 2})
+
+		require.NoError(t, err)
+		assert.Equal(t, int32(5), response.TotalCount)
+		assert.Equal(t, int32(2), response.Page)
+		assert.Equal(t, int32(3), response.TotalPages)
+		require.Len(t, response.Users, 2)
+		assert.Equal(t, users[2].ID.String(), response.Users[0].Id)
+		assert.Equal(t, users[3].Username, response.Users[1].Username)
+	})
+
+	t.Run("Unset paging takes the HTTP defaults", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetAll", mock.Anything, 10, 0, models.SortOptions{}).Return(users, nil)
+		mockUserRepo.On("CountUsers", mock.Anything).Return(5, nil)
+		client := newTestClient(t, mockUserRepo)
+
+		response, err := client.GetUsers(context.Background(), &pb.GetUsersRequest{})
+
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), response.Page)
+		assert.Equal(t, int32(1), response.TotalPages)
+		assert.Len(t, response.Users, 5)
+	})
+
+	t.Run("Filters by query and active state", func(t *testing.T) {
+		inactive := false
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetUserIDsByFilter", mock.Anything, models.UserFilter{Query: "example", IsActive: &inactive}).Return(userIDs(users), nil)
+		mockUserRepo.On("GetByIDs", mock.Anything, userIDs(users[3:])).Return(users[3:], nil)
+		client := newTestClient(t, mockUserRepo)
+
+		response, err := client.GetUsers(context.Background(), &pb.GetUsersRequest{Page: 2, PageSize: 3, Q: " example ", IsActive: &inactive})
+
+		require.NoError(t, err)
+		assert.Equal(t, int32(5), response.TotalCount)
+		assert.Equal(t, int32(2), response.TotalPages)
+		require.Len(t, response.Users, 2)
+		assert.Equal(t, users[4].ID.String(), response.Users[1].Id)
+		mockUserRepo.AssertNotCalled(t, "GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Page past the end is empty", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetUserIDsByFilter", mock.Anything, models.UserFilter{Query: "user"}).Return(userIDs(users), nil)
+		client := newTestClient(t, mockUserRepo)
+
+		response, err := client.GetUsers(context.Background(), &pb.GetUsersRequest{Page: 3, PageSize: 5, Q: "user"})
+
+		require.NoError(t, err)
+		assert.Equal(t, int32(5), response.TotalCount)
+		assert.Empty(t, response.Users)
+		mockUserRepo.AssertNotCalled(t, "GetByIDs", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Negative paging is an invalid argument", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		client := newTestClient(t, mockUserRepo)
+
+		_, err := client.GetUsers(context.Background(), &pb.GetUsersRequest{PageSize: -1})
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		mockUserRepo.AssertNotCalled(t, "GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestUserGRPCServer_WhoAmI(t *testing.T) {
+	withToken := func(t *testing.T, token string) context.Context {
+		t.Helper()
+		return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+	}
+
+	t.Run("Authenticated caller", func(t *testing.T) {
+		userID := uuid.New()
+		permissions := []models.Permission{
+			{ID: uuid.New(), Name: "user:read", Resource: "user", Action: "read"},
+			{ID: uuid.New(), Name: "role:read", Resource: "role", Action: "read"},
+		}
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetUserPermissions", mock.Anything, userID).Return(permissions, nil)
+		mockUserRepo.On("GetTokensRevokedAt", mock.Anything, userID).Return(time.Time{}, nil)
+		client := newTestClient(t, mockUserRepo)
+
+		token, _, err := utils.GenerateJWT(userID, "johndoe", []string{"viewer", "editor"}, testConfig())
+		require.NoError(t, err)
+
+		response, err := client.WhoAmI(withToken(t, token), &pb.WhoAmIRequest{})
+
+		require.NoError(t, err)
+		assert.Equal(t, userID.String(), response.UserId)
+		assert.Equal(t, "johndoe", response.Username)
+		assert.Equal(t, []string{"viewer", "editor"}, response.Roles)
+		require.Len(t, response.Permissions, 2)
+		assert.Equal(t, "user:read", response.Permissions[0].Name)
+		assert.Equal(t, permissions[1].ID.String(), response.Permissions[1].Id)
+		mockUserRepo.AssertNumberOfCalls(t, "GetUserPermissions", 1)
+	})
+
+	t.Run("Token issued before the tokens were revoked", func(t *testing.T) {
+		userID := uuid.New()
+		mockUserRepo := new(mocks.MockUserRepository)
+		client := newTestClient(t, mockUserRepo)
+
+		token, _, err := utils.GenerateJWT(userID, "johndoe", []string{"viewer"}, testConfig())
+		require.NoError(t, err)
+		mockUserRepo.On("GetTokensRevokedAt", mock.Anything, userID).Return(time.Now().Add(time.Second), nil)
+
+		_, err = client.WhoAmI(withToken(t, token), &pb.WhoAmIRequest{})
+
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		mockUserRepo.AssertNotCalled(t, "GetUserPermissions", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Missing token", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		client := newTestClient(t, mockUserRepo)
+
+		_, err := client.WhoAmI(context.Background(), &pb.WhoAmIRequest{})
+
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		mockUserRepo.AssertNotCalled(t, "GetUserPermissions", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		client := newTestClient(t, mockUserRepo)
+
+		_, err := client.WhoAmI(withToken(t, "not-a-token"), &pb.WhoAmIRequest{})
+
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		mockUserRepo.AssertNotCalled(t, "GetUserPermissions", mock.Anything, mock.Anything)
+	})
+}
+
+func TestUserGRPCServer_FieldParity(t *testing.T) {
+	// Decodes the JSON of both representations into generic maps, which compare field by field
+	decode := func(t *testing.T, data []byte, err error) map[string]interface{} {
+		t.Helper()
+		require.NoError(t, err)
+
+		var fields map[string]interface{}
+		require.NoError(t, json.Unmarshal(data, &fields))
+		return fields
+	}
+	httpJSON := func(t *testing.T, v interface{}) map[string]interface{} {
+		t.Helper()
+		data, err := json.Marshal(v)
+		return decode(t, data, err)
+	}
+	protoJSON := func(t *testing.T, m proto.Message) map[string]interface{} {
+		t.Helper()
+		data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(m)
+		return decode(t, data, err)
+	}
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 123000000, time.UTC)
+	updatedAt := createdAt.Add(time.Hour)
+	user := &models.User{
+		ID:        uuid.New(),
+		Username:  "johndoe",
+		Email:     "john@example.com",
+		FirstName: "John",
+		LastName:  "Doe",
+		IsActive:  true,
+		CreatedAt: createdAt,
+		UpdatedAt: updatedAt,
+	}
+	permission := models.Permission{
+		ID:          uuid.New(),
+		Name:        "user:read",
+		Description: "Read users",
+		Resource:    "user",
+		Action:      "read",
+		Category:    "users",
+		CreatedAt:   createdAt,
+		UpdatedAt:   updatedAt,
+	}
+
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	mockUserRepo.On("GetUserPermissions", mock.Anything, user.ID).Return([]models.Permission{permission}, nil)
+	client := newTestClient(t, mockUserRepo)
+
+	t.Run("Permission", func(t *testing.T) {
+		response, err := client.GetUserPermissions(context.Background(), &pb.GetUserRequest{UserId: user.ID.String()})
+		require.NoError(t, err)
+		require.Len(t, response.Permissions, 1)
+
+		assert.Equal(t, httpJSON(t, permission.ToResponse()), protoJSON(t, response.Permissions[0]))
+	})
+
+	t.Run("User", func(t *testing.T) {
+		response, err := client.GetUser(context.Background(), &pb.GetUserRequest{UserId: user.ID.String()})
+		require.NoError(t, err)
+
+		assert.Equal(t, httpJSON(t, user.ToResponse()), protoJSON(t, response))
+	})
+}
+
+func TestUserGRPCServer_ContextEnded(t *testing.T) {
+	cfg := &config.Config{JaegerEndpoint: "http://localhost:14268/api/traces"}
+	tracer, err := tracing.NewTracer(cfg)
+	require.NoError(t, err)
+
+	newServer := func(userRepo *mocks.MockUserRepository) *server.UserGRPCServer {
+		userService := services.NewUserService(userRepo, new(mocks.MockRoleRepository), new(mocks.Manager[transaction.Repository]), services.DefaultUserServiceOptions())
+		return server.NewUserGRPCServer(userService, services.NewAuthService(userRepo, cfg), tracer, cfg)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want codes.Code
+	}{
+		{"Client canceled", canceled, codes.Canceled},
+		{"Deadline passed", expired, codes.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := uuid.New()
+			mockUserRepo := new(mocks.MockUserRepository)
+			mockUserRepo.On("GetAll", mock.Anything, 10, 0, models.SortOptions{}).Return([]*models.User(nil), tt.ctx.Err())
+			mockUserRepo.On("GetByID", mock.Anything, userID).Return(nil, tt.ctx.Err())
+			mockUserRepo.On("HasPermission", mock.Anything, userID, "user", "read").Return(false, tt.ctx.Err())
+			grpcServer := newServer(mockUserRepo)
+
+			_, err := grpcServer.GetUsers(tt.ctx, &pb.GetUsersRequest{})
+			assert.Equal(t, tt.want, status.Code(err))
+
+			_, err = grpcServer.GetUser(tt.ctx, &pb.GetUserRequest{UserId: userID.String()})
+			assert.Equal(t, tt.want, status.Code(err))
+
+			_, err = grpcServer.HasPermission(tt.ctx, &pb.HasPermissionRequest{UserId: userID.String(), Resource: "user", Action: "read"})
+			assert.Equal(t, tt.want, status.Code(err))
+		})
+	}
+}
+
+func TestUserGRPCServer_ValidateToken(t *testing.T) {
+	t.Run("Valid token", func(t *testing.T) {
+		userID := uuid.New()
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetTokensRevokedAt", mock.Anything, userID).Return(time.Time{}, nil)
+		client := newTestClient(t, mockUserRepo)
+
+		token, _, err := utils.GenerateJWT(userID, "johndoe", []string{"viewer"}, testConfig())
+		require.NoError(t, err)
+
+		response, err := client.ValidateToken(context.Background(), &pb.ValidateTokenRequest{Token: token})
+
+		require.NoError(t, err)
+		assert.True(t, response.IsValid)
+		assert.Equal(t, userID.String(), response.UserId)
+		assert.Equal(t, []string{"viewer"}, response.Roles)
+	})
+
+	t.Run("Token without roles reports the user's roles", func(t *testing.T) {
+		user := &models.User{ID: uuid.New(), Username: "johndoe", Roles: []models.Role{{Name: "viewer"}, {Name: "editor"}}}
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetTokensRevokedAt", mock.Anything, user.ID).Return(time.Time{}, nil)
+		mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		client := newTestClient(t, mockUserRepo)
+
+		cfg := testConfig()
+		cfg.JWTMaxRoles = 1
+		token, _, err := utils.GenerateJWT(user.ID, user.Username, []string{"viewer", "editor"}, cfg)
+		require.NoError(t, err)
+
+		response, err := client.ValidateToken(context.Background(), &pb.ValidateTokenRequest{Token: token})
+
+		require.NoError(t, err)
+		assert.True(t, response.IsValid)
+		assert.Equal(t, []string{"viewer", "editor"}, response.Roles)
+	})
+
+	t.Run("Token issued before the tokens were revoked", func(t *testing.T) {
+		userID := uuid.New()
+		mockUserRepo := new(mocks.MockUserRepository)
+		client := newTestClient(t, mockUserRepo)
+
+		token, _, err := utils.GenerateJWT(userID, "johndoe", []string{"viewer"}, testConfig())
+		require.NoError(t, err)
+		mockUserRepo.On("GetTokensRevokedAt", mock.Anything, userID).Return(time.Now().Add(time.Second), nil)
+
+		response, err := client.ValidateToken(context.Background(), &pb.ValidateTokenRequest{Token: token})
+
+		require.NoError(t, err)
+		assert.False(t, response.IsValid)
+		assert.Equal(t, "invalid_token", response.Error.Code)
+		assert.Empty(t, response.UserId)
+	})
+}
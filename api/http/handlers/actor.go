@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// withActor records on ctx who makes the request, so services can check what the caller may grant
+func withActor(ctx context.Context, c *fiber.Ctx) context.Context {
+	if key, ok := c.Locals("apiKey").(*models.APIKey); ok {
+		return services.WithActor(ctx, services.Actor{APIKey: key})
+	}
+	userID, _ := c.Locals("userID").(string)
+	return services.WithActor(ctx, services.Actor{UserID: userID})
+}
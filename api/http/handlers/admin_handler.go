@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/events"
+	"github.com/chats/go-user-api/internal/features"
+	"github.com/chats/go-user-api/internal/metrics"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/services"
+	"github.com/chats/go-user-api/internal/tracing"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// AdminHandler handles operational HTTP requests for administrators
+type AdminHandler struct {
+	cfg        *config.Config
+	dispatcher *events.Dispatcher
+	tracer     *tracing.Tracer
+	routes     []models.RouteInfo
+	metrics    *metrics.Collector
+	features   *features.Flags
+	thresholds metrics.Thresholds
+	cache      *services.CacheService
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(
+	cfg *config.Config,
+	dispatcher *events.Dispatcher,
+	tracer *tracing.Tracer,
+) *AdminHandler {
+	return &AdminHandler{
+		cfg:        cfg,
+		dispatcher: dispatcher,
+		tracer:     tracer,
+		thresholds: statusThresholds(cfg),
+	}
+}
+
+// statusThresholds returns the levels GetStatus classifies the collected metrics against
+func statusThresholds(cfg *config.Config) metrics.Thresholds {
+	return metrics.Thresholds{
+		ErrorRateWarn:          cfg.StatusErrorRateWarn,
+		ErrorRateCritical:      cfg.StatusErrorRateCritical,
+		LatencyP99Warn:         time.Duration(cfg.StatusLatencyP99WarnMs) * time.Millisecond,
+		LatencyP99Critical:     time.Duration(cfg.StatusLatencyP99CriticalMs) * time.Millisecond,
+		CacheHitRatioWarn:      cfg.StatusCacheHitRatioWarn,
+		CacheHitRatioCritical:  cfg.StatusCacheHitRatioCritical,
+		PoolSaturationWarn:     cfg.StatusDBPoolSaturationWarn,
+		PoolSaturationCritical: cfg.StatusDBPoolSaturationCritical,
+	}
+}
+
+// UseRouteManifest sets the routes listed by GetRoutes
+func (h *AdminHandler) UseRouteManifest(routes []models.RouteInfo) {
+	h.routes = routes
+}
+
+// UseFeatureFlags sets the flags listed by GetFeatures and changed by SetFeature
+func (h *AdminHandler) UseFeatureFlags(flags *features.Flags) {
+	h.features = flags
+}
+
+// UseMetrics sets the collector GetStatus evaluates
+func (h *AdminHandler) UseMetrics(collector *metrics.Collector) {
+	h.metrics = collector
+}
+
+// UseCacheService sets the service InvalidateCache and InvalidateCacheEntity clear the cache through
+func (h *AdminHandler) UseCacheService(cache *services.CacheService) {
+	h.cache = cache
+}
+
+// GetConfig returns the effective configuration with secrets redacted
+func (h *AdminHandler) GetConfig(c *fiber.Ctx) error {
+	_, span := h.tracer.StartSpan(c.Context(), "AdminHandler.GetConfig")
+	defer span.End()
+
+	// Log activity
+	adminID, _ := c.Locals("userID").(string)
+	log.Info().
+		Str("admin_id", adminID).
+		Msg("Effective configuration viewed")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    h.cfg.Sanitized(),
+		"sources": h.cfg.Sources(),
+	})
+}
+
+// GetEventStats returns the activity event counters, including events dropped by the buffer or breaker
+func (h *AdminHandler) GetEventStats(c *fiber.Ctx) error {
+	_, span := h.tracer.StartSpan(c.Context(), "AdminHandler.GetEventStats")
+	defer span.End()
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    h.dispatcher.Stats(),
+	})
+}
+
+// GetRoutes lists every HTTP route with the authentication, roles and permissions it requires
+func (h *AdminHandler) GetRoutes(c *fiber.Ctx) error {
+	_, span := h.tracer.StartSpan(c.Context(), "AdminHandler.GetRoutes")
+	defer span.End()
+
+	// Log activity
+	adminID, _ := c.Locals("userID").(string)
+	log.Info().
+		Str("admin_id", adminID).
+		Msg("Route manifest viewed")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    h.routes,
+	})
+}
+
+// GetStatus classifies the error rate, p99 latency, cache hit ratio and database pool saturation
+// as OK, WARN or CRITICAL against the configured thresholds
+func (h *AdminHandler) GetStatus(c *fiber.Ctx) error {
+	_, span := h.tracer.StartSpan(c.Context(), "AdminHandler.GetStatus")
+	defer span.End()
+
+	if h.metrics == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"success": false,
+			"message": "Metrics are not being collected",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    metrics.Evaluate(h.metrics.Snapshot(), h.thresholds),
+	})
+}
+
+// GetFeatures lists every feature flag and whether it is enabled
+func (h *AdminHandler) GetFeatures(c *fiber.Ctx) error {
+	_, span := h.tracer.StartSpan(c.Context(), "AdminHandler.GetFeatures")
+	defer span.End()
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    h.features.List(),
+	})
+}
+
+// SetFeature switches a feature on or off on this instance until it restarts
+func (h *AdminHandler) SetFeature(c *fiber.Ctx) error {
+	_, span := h.tracer.StartSpan(c.Context(), "AdminHandler.SetFeature")
+	defer span.End()
+
+	// Fiber reuses the request buffer, and the name outlives the request as a map key
+	name := strings.Clone(c.Params("name"))
+
+	var request models.FeatureFlagRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request",
+			"error":   err.Error(),
+		})
+	}
+	if request.Enabled == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request",
+			"error":   "enabled is required",
+		})
+	}
+
+	if err := h.features.Set(name, *request.Enabled); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Feature not found",
+			"error":   err.Error(),
+		})
+	}
+
+	// Log activity
+	adminID, _ := c.Locals("userID").(string)
+	log.Info().
+		Str("admin_id", adminID).
+		Str("feature", name).
+		Bool("enabled", *request.Enabled).
+		Msg("Feature flag changed")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Feature flag updated successfully",
+		"data":    h.features.List(),
+	})
+}
+
+// InvalidateCacheEntity clears the cached copies of a single user, role or permission
+func (h *AdminHandler) InvalidateCacheEntity(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "AdminHandler.InvalidateCacheEntity")
+	defer span.End()
+
+	if h.cache == nil {
+		return h.cacheDisabled(c)
+	}
+
+	entity := c.Params("entity")
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid ID",
+			"error":   err.Error(),
+		})
+	}
+
+	result, err := h.cache.Invalidate(ctx, entity, id)
+	if err != nil {
+		return h.cacheInvalidationFailed(ctx, c, entity, err)
+	}
+
+	// Log activity
+	adminID, _ := c.Locals("userID").(string)
+	log.Info().
+		Str("admin_id", adminID).
+		Str("entity", result.Entity).
+		Str("id", result.ID).
+		Int("cleared", result.Cleared).
+		Msg("Cache invalidated")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Cache invalidated successfully",
+		"data":    result,
+	})
+}
+
+// InvalidateCache clears every cached user, role or permission
+func (h *AdminHandler) InvalidateCache(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "AdminHandler.InvalidateCache")
+	defer span.End()
+
+	if h.cache == nil {
+		return h.cacheDisabled(c)
+	}
+
+	entity := c.Params("entity")
+	result, err := h.cache.InvalidateAll(ctx, entity)
+	if err != nil {
+		return h.cacheInvalidationFailed(ctx, c, entity, err)
+	}
+
+	// Log activity
+	adminID, _ := c.Locals("userID").(string)
+	log.Info().
+		Str("admin_id", adminID).
+		Str("entity", result.Entity).
+		Int("cleared", result.Cleared).
+		Msg("Cache invalidated")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Cache invalidated successfully",
+		"data":    result,
+	})
+}
+
+func (h *AdminHandler) cacheDisabled(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+		"success": false,
+		"message": "Caching is disabled",
+	})
+}
+
+func (h *AdminHandler) cacheInvalidationFailed(ctx context.Context, c *fiber.Ctx, entity string, err error) error {
+	if errors.Is(err, services.ErrUnknownCacheEntity) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Cache entity not found",
+			"error":   err.Error(),
+		})
+	}
+
+	h.tracer.RecordError(ctx, err)
+	log.Error().Err(err).
+		Str("entity", entity).
+		Msg("Failed to invalidate cache")
+
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"success": false,
+		"message": "Failed to invalidate cache",
+		"error":   err.Error(),
+	})
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/services"
+	"github.com/chats/go-user-api/internal/tracing"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// APIKeyHandler handles API key related HTTP requests
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+	tracer        *tracing.Tracer
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(
+	apiKeyService *services.APIKeyService,
+	tracer *tracing.Tracer,
+) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+		tracer:        tracer,
+	}
+}
+
+// GetAPIKeys retrieves all API keys
+func (h *APIKeyHandler) GetAPIKeys(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "APIKeyHandler.GetAPIKeys")
+	defer span.End()
+
+	keys, err := h.apiKeyService.GetAllAPIKeys(ctx)
+	if err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		errorLog(err).Err(err).Msg("Failed to get API keys")
+
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get API keys",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    keys,
+	})
+}
+
+// CreateAPIKey creates a new API key
+func (h *APIKeyHandler) CreateAPIKey(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "APIKeyHandler.CreateAPIKey")
+	defer span.End()
+
+	// Get admin ID from context
+	adminID, ok := c.Locals("userID").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "User ID not found in token",
+		})
+	}
+
+	// Parse request body
+	var request models.APIKeyCreateRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request",
+			"error":   err.Error(),
+		})
+	}
+
+	h.tracer.SetAttributes(ctx,
+		attribute.String("api_key_name", request.Name),
+	)
+
+	// Create API key
+	key, err := h.apiKeyService.CreateAPIKey(ctx, adminID, request)
+	if err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		log.Error().Err(err).
+			Str("admin_id", adminID).
+			Str("api_key_name", request.Name).
+			Msg("Failed to create API key")
+
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to create API key",
+			"error":   err.Error(),
+		})
+	}
+
+	// Log activity
+	log.Info().
+		Str("admin_id", adminID).
+		Str("api_key_id", key.ID.String()).
+		Str("api_key_name", key.Name).
+		Msg("API key created successfully")
+
+	return respondCreated(c, "/api/v1/admin/api-keys/"+key.ID.String(), fiber.Map{
+		"success": true,
+		"message": "Store this key securely, it will not be shown again",
+		"data":    key,
+	})
+}
+
+// GetAPIKey retrieves an API key (admin only)
+func (h *APIKeyHandler) GetAPIKey(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "APIKeyHandler.GetAPIKey")
+	defer span.End()
+
+	id := c.Params("id")
+
+	h.tracer.SetAttributes(ctx,
+		attribute.String("api_key_id", id),
+	)
+
+	key, err := h.apiKeyService.GetAPIKey(ctx, id)
+	if err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		errorLog(err).Err(err).
+			Str("api_key_id", id).
+			Msg("Failed to get API key")
+
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, services.ErrAPIKeyNotFound) {
+			status = fiber.StatusNotFound
+		}
+
+		return c.Status(errorStatus(err, status)).JSON(fiber.Map{
+			"success": false,
+			"message": errorMessage(err, "Failed to get API key"),
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    key,
+	})
+}
+
+// RevokeAPIKey revokes an API key
+func (h *APIKeyHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "APIKeyHandler.RevokeAPIKey")
+	defer span.End()
+
+	// Get API key ID from path
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "API key ID is required",
+		})
+	}
+
+	h.tracer.SetAttributes(ctx,
+		attribute.String("api_key_id", id),
+	)
+
+	// Revoke API key
+	if err := h.apiKeyService.RevokeAPIKey(ctx, id); err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		log.Error().Err(err).
+			Str("api_key_id", id).
+			Msg("Failed to revoke API key")
+
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to revoke API key",
+			"error":   err.Error(),
+		})
+	}
+
+	// Log activity
+	adminID, _ := c.Locals("userID").(string)
+	log.Info().
+		Str("admin_id", adminID).
+		Str("api_key_id", id).
+		Msg("API key revoked successfully")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "API key revoked successfully",
+	})
+}
+
+// GetMyAPIKeys retrieves the API keys of the current user
+func (h *APIKeyHandler) GetMyAPIKeys(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "User ID not found in token",
+		})
+	}
+
+	return h.getUserAPIKeys(c, userID)
+}
+
+// GetUserAPIKeys retrieves the API keys of any user (admin only)
+func (h *APIKeyHandler) GetUserAPIKeys(c *fiber.Ctx) error {
+	return h.getUserAPIKeys(c, c.Params("id"))
+}
+
+// getUserAPIKeys responds with the API keys the user created; secrets are never included
+func (h *APIKeyHandler) getUserAPIKeys(c *fiber.Ctx, userID string) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "APIKeyHandler.GetUserAPIKeys")
+	defer span.End()
+
+	h.tracer.SetAttributes(ctx,
+		attribute.String("user_id", userID),
+	)
+
+	keys, err := h.apiKeyService.GetUserAPIKeys(ctx, userID)
+	if err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		errorLog(err).Err(err).
+			Str("user_id", userID).
+			Msg("Failed to get user API keys")
+
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
+			"success": false,
+			"message": errorMessage(err, "Failed to get API keys"),
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    keys,
+	})
+}
+
+// RevokeMyAPIKey revokes an API key of the current user
+func (h *APIKeyHandler) RevokeMyAPIKey(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "User ID not found in token",
+		})
+	}
+
+	return h.revokeUserAPIKey(c, userID, c.Params("id"))
+}
+
+// RevokeUserAPIKey revokes an API key of any user (admin only)
+func (h *APIKeyHandler) RevokeUserAPIKey(c *fiber.Ctx) error {
+	return h.revokeUserAPIKey(c, c.Params("id"), c.Params("keyId"))
+}
+
+// revokeUserAPIKey revokes an API key the user created; it stops authenticating immediately
+func (h *APIKeyHandler) revokeUserAPIKey(c *fiber.Ctx, userID, id string) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "APIKeyHandler.RevokeUserAPIKey")
+	defer span.End()
+
+	h.tracer.SetAttributes(ctx,
+		attribute.String("user_id", userID),
+		attribute.String("api_key_id", id),
+	)
+
+	if err := h.apiKeyService.RevokeUserAPIKey(ctx, userID, id); err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		errorLog(err).Err(err).
+			Str("user_id", userID).
+			Str("api_key_id", id).
+			Msg("Failed to revoke user API key")
+
+		return c.Status(errorStatus(err, fiber.StatusNotFound)).JSON(fiber.Map{
+			"success": false,
+			"message": errorMessage(err, "Failed to revoke API key"),
+			"error":   err.Error(),
+		})
+	}
+
+	actorID, _ := c.Locals("userID").(string)
+	log.Info().
+		Str("actor_id", actorID).
+		Str("user_id", userID).
+		Str("api_key_id", id).
+		Msg("API key revoked successfully")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "API key revoked successfully",
+	})
+}
@@ -1,6 +1,11 @@
 package handlers
 
 import (
+	"errors"
+	"strings"
+
+	"github.com/chats/go-user-api/internal/jobs"
+	"github.com/chats/go-user-api/internal/logger"
 	"github.com/chats/go-user-api/internal/models"
 	"github.com/chats/go-user-api/internal/services"
 	"github.com/chats/go-user-api/internal/tracing"
@@ -57,14 +62,25 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	}
 
 	// Authenticate user
+	request.ClientIP = c.IP()
 	response, err := h.authService.Login(ctx, request)
 	if err != nil {
 		h.tracer.RecordError(ctx, err)
 
 		log.Error().Err(err).
-			Str("username", request.Username).
+			Str("username", logger.MaskUsername(request.Username)).
 			Msg("Login failed")
 
+		// Tell the client to show a challenge after repeated failures
+		if errors.Is(err, services.ErrChallengeRequired) || errors.Is(err, services.ErrChallengeFailed) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"success":            false,
+				"message":            "Challenge required",
+				"error":              err.Error(),
+				"challenge_required": true,
+			})
+		}
+
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"success": false,
 			"message": "Invalid username or password",
@@ -73,7 +89,7 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 
 	// Log successful login
 	log.Info().
-		Str("username", request.Username).
+		Str("username", logger.MaskUsername(request.Username)).
 		Str("user_id", response.User.ID.String()).
 		Msg("User logged in successfully")
 
@@ -83,6 +99,166 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	})
 }
 
+// RefreshToken exchanges a refresh token for new tokens
+func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "AuthHandler.RefreshToken")
+	defer span.End()
+
+	// Parse request body
+	var request models.RefreshTokenRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request",
+			"error":   err.Error(),
+		})
+	}
+
+	// Validate request
+	if request.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Refresh token is required",
+		})
+	}
+
+	response, err := h.authService.RefreshToken(ctx, request.RefreshToken)
+	if err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		log.Error().Err(err).Msg("Token refresh failed")
+
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to refresh token",
+			"error":   err.Error(),
+		})
+	}
+
+	log.Info().
+		Str("user_id", response.User.ID.String()).
+		Msg("Token refreshed")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    response,
+	})
+}
+
+// ReissueToken issues a new token for the authenticated user with their current roles
+func (h *AuthHandler) ReissueToken(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "AuthHandler.ReissueToken")
+	defer span.End()
+
+	// Get user ID from context
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "User ID not found in token",
+		})
+	}
+
+	h.tracer.SetAttributes(ctx,
+		attribute.String("user_id", userID),
+	)
+
+	response, err := h.authService.ReissueToken(ctx, userID)
+	if err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		log.Error().Err(err).
+			Str("user_id", userID).
+			Msg("Token reissue failed")
+
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to reissue token",
+			"error":   err.Error(),
+		})
+	}
+
+	log.Info().
+		Str("user_id", userID).
+		Msg("Token reissued")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    response,
+	})
+}
+
+// StepUp re-checks the caller's password and issues a token with a fresh auth time
+func (h *AuthHandler) StepUp(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "AuthHandler.StepUp")
+	defer span.End()
+
+	// Get user ID from context
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "User ID not found in token",
+		})
+	}
+
+	// Parse request body
+	var request struct {
+		Password string `json:"password" validate:"required"`
+	}
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request",
+			"error":   err.Error(),
+		})
+	}
+
+	if request.Password == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Password is required",
+		})
+	}
+
+	h.tracer.SetAttributes(ctx,
+		attribute.String("user_id", userID),
+	)
+
+	response, err := h.authService.StepUp(ctx, userID, request.Password)
+	if err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		log.Warn().Err(err).
+			Str("user_id", userID).
+			Msg("Step-up authentication failed")
+
+		message := "Failed to step up"
+		switch {
+		case errors.Is(err, services.ErrReauthFailed):
+			message = "Current password is incorrect"
+		case errors.Is(err, services.ErrChallengeRequired):
+			// Step-up carries no challenge token, so the user has to log in again
+			message = "Too many failed attempts, log in again"
+		}
+
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": message,
+			"error":   err.Error(),
+		})
+	}
+
+	log.Info().
+		Str("user_id", userID).
+		Msg("Step-up authentication succeeded")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    response,
+	})
+}
+
 // ChangePassword handles password change
 func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
 	ctx, span := h.tracer.StartSpan(c.Context(), "AuthHandler.ChangePassword")
@@ -184,8 +360,8 @@ func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
 		})
 	}
 
-	// Reset password
-	newPassword, err := h.authService.ResetPassword(ctx, request.UserID)
+	// Queue delivery of a reset token; the response never carries a password or the token
+	err := h.authService.ResetPassword(ctx, request.UserID)
 	if err != nil {
 		h.tracer.RecordError(ctx, err)
 
@@ -194,40 +370,149 @@ func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
 			Str("user_id", request.UserID).
 			Msg("Password reset failed")
 
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		status := fiber.StatusBadRequest
+		if errors.Is(err, jobs.ErrQueueFull) || errors.Is(err, jobs.ErrQueueClosed) {
+			status = fiber.StatusServiceUnavailable
+		}
+
+		return c.Status(errorStatus(err, status)).JSON(fiber.Map{
 			"success": false,
 			"message": err.Error(),
 		})
 	}
 
-	// Get user to send email
-	/*
-		user, err := h.userService.GetUserByID(ctx, request.UserID)
-		if err == nil && user != nil {
-			// Send password reset email
-			go func() {
-				mailErr := h.mailService.SendPasswordResetEmail(
-					context.Background(),
-					user.Email,
-					user.Username,
-					newPassword,
-				)
-				if mailErr != nil {
-					log.Error().Err(mailErr).
-						Str("user_id", user.ID.String()).
-						Msg("Failed to send password reset email")
-				}
-			}()
-		}*/
-
 	log.Info().
 		Str("admin_id", adminID).
 		Str("user_id", request.UserID).
-		Msg("Password reset successfully")
+		Msg("Password reset queued")
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"success": true,
+		"message": "Password reset token queued for delivery to the user",
+	})
+}
+
+// ConfirmPasswordReset sets a new password with a password reset token
+func (h *AuthHandler) ConfirmPasswordReset(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "AuthHandler.ConfirmPasswordReset")
+	defer span.End()
+
+	// Parse request body
+	var request struct {
+		Token       string `json:"token" validate:"required"`
+		NewPassword string `json:"new_password" validate:"required,min=8"`
+	}
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request",
+			"error":   err.Error(),
+		})
+	}
+
+	// Validate request
+	if request.Token == "" || request.NewPassword == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Token and new password are required",
+		})
+	}
+
+	if len(request.NewPassword) < 8 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "New password must be at least 8 characters long",
+		})
+	}
+
+	if err := h.authService.ConfirmPasswordReset(ctx, request.Token, request.NewPassword); err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		log.Error().Err(err).Msg("Password reset confirmation failed")
+
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to reset password",
+			"error":   err.Error(),
+		})
+	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"success":      true,
-		"message":      "Password reset successfully",
-		"new_password": newPassword,
+		"success": true,
+		"message": "Password reset successfully",
+	})
+}
+
+// CheckPermission reports whether a user holds a permission and which of their roles grant it, for
+// diagnosing access (admin only)
+func (h *AuthHandler) CheckPermission(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "AuthHandler.CheckPermission")
+	defer span.End()
+
+	userID := c.Query("user_id")
+	resource := c.Query("resource")
+	action := c.Query("action")
+	if userID == "" || resource == "" || action == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "user_id, resource and action are required",
+		})
+	}
+
+	h.tracer.SetAttributes(ctx,
+		attribute.String("user_id", userID),
+		attribute.String("resource", resource),
+		attribute.String("action", action),
+	)
+
+	decision, err := h.authService.CheckPermissionDecision(ctx, userID, resource, action)
+	if err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to check permission",
+			"error":   err.Error(),
+		})
+	}
+
+	check := models.PermissionCheck{
+		UserID:     userID,
+		Permission: resource + ":" + action,
+		Decision:   decision,
+		Allowed:    decision == models.PermissionGranted,
+		GrantedBy:  []string{},
+	}
+
+	switch decision {
+	case models.PermissionGranted:
+		grantedBy, err := h.userService.PermissionGrantedBy(ctx, userID, check.Permission)
+		if err != nil {
+			h.tracer.RecordError(ctx, err)
+
+			return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to find the roles granting the permission",
+				"error":   err.Error(),
+			})
+		}
+		check.GrantedBy = grantedBy
+		switch len(grantedBy) {
+		case 0:
+			check.Explanation = "granted, but no role of the user lists the permission"
+		case 1:
+			check.Explanation = "granted by role " + grantedBy[0]
+		default:
+			check.Explanation = "granted by roles " + strings.Join(grantedBy, ", ")
+		}
+	case models.PermissionUndefined:
+		check.Explanation = "no permission " + check.Permission + " exists"
+	default:
+		check.Explanation = "no role of the user grants " + check.Permission
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    check,
 	})
 }
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/jobs"
+	"github.com/chats/go-user-api/internal/mocks"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/repositories/transaction"
+	"github.com/chats/go-user-api/internal/services"
+	"github.com/chats/go-user-api/internal/tracing"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingQueue records the jobs enqueued
+type recordingQueue struct {
+	jobs []jobs.Job
+}
+
+func (q *recordingQueue) Enqueue(_ context.Context, job jobs.Job) error {
+	q.jobs = append(q.jobs, job)
+	return nil
+}
+
+func TestAuthHandler_ResetPassword(t *testing.T) {
+	tracer, err := tracing.NewTracer(&config.Config{JaegerEndpoint: "http://localhost:14268/api/traces"})
+	require.NoError(t, err)
+
+	cfg := &config.Config{JWTSecret: "test-secret-key", JWTExpireMinute: 60, PasswordResetTokenMinute: 60}
+	user := &models.User{ID: uuid.New(), Username: "johndoe", Email: "john@example.com", Password: "$2a$10$current-password-hash"}
+
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	queue := &recordingQueue{}
+	authService := services.NewAuthService(mockUserRepo, cfg)
+	authService.UsePasswordResetQueue(queue)
+
+	app := fiber.New()
+	app.Post("/auth/reset-password", func(c *fiber.Ctx) error {
+		c.Locals("userID", uuid.NewString())
+		return c.Next()
+	}, NewAuthHandler(authService, nil, tracer).ResetPassword)
+
+	req := httptest.NewRequest(fiber.MethodPost, "/auth/reset-password", strings.NewReader(`{"user_id":"`+user.ID.String()+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusAccepted, resp.StatusCode)
+
+	// The job carries the token; the response carries neither it nor a password
+	require.Len(t, queue.jobs, 1)
+	var payload jobs.PasswordReset
+	require.NoError(t, queue.jobs[0].Decode(&payload))
+	assert.Equal(t, user.ID, payload.UserID)
+	assert.NotContains(t, string(body), "password\"")
+	assert.NotContains(t, string(body), payload.Token)
+}
+
+func TestAuthHandler_CheckPermission(t *testing.T) {
+	tracer, err := tracing.NewTracer(&config.Config{JaegerEndpoint: "http://localhost:14268/api/traces"})
+	require.NoError(t, err)
+
+	editor := models.Role{ID: uuid.New(), Name: "editor"}
+	user := &models.User{ID: uuid.New(), Username: "johndoe", Roles: []models.Role{editor}}
+
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRoleRepo := new(mocks.MockRoleRepository)
+	mockPermissionRepo := new(mocks.MockPermissionRepository)
+	mockUserRepo.On("HasPermission", mock.Anything, user.ID, "user", "write").Return(true, nil)
+	mockUserRepo.On("HasPermission", mock.Anything, user.ID, mock.Anything, mock.Anything).Return(false, nil)
+	mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	mockRoleRepo.On("GetPermissionsByRoleIDs", mock.Anything, []uuid.UUID{editor.ID}).Return(map[uuid.UUID][]models.Permission{
+		editor.ID: {{Resource: "user", Action: "write"}},
+	}, nil)
+	mockPermissionRepo.On("ExistsByResourceAction", mock.Anything, "user", "delete").Return(true, nil)
+	mockPermissionRepo.On("ExistsByResourceAction", mock.Anything, "user", "frobnicate").Return(false, nil)
+
+	authService := services.NewAuthService(mockUserRepo, &config.Config{JWTSecret: "test-secret-key"})
+	authService.UsePermissionRepository(mockPermissionRepo)
+	userService := services.NewUserService(mockUserRepo, mockRoleRepo, new(mocks.Manager[transaction.Repository]), services.DefaultUserServiceOptions())
+
+	app := fiber.New()
+	app.Get("/admin/permission-check", NewAuthHandler(authService, userService, tracer).CheckPermission)
+
+	check := func(t *testing.T, query string) (int, models.PermissionCheck) {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/admin/permission-check?"+query, nil))
+		require.NoError(t, err)
+
+		var body struct {
+			Data models.PermissionCheck `json:"data"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp.StatusCode, body.Data
+	}
+
+	t.Run("Granted, with the granting role", func(t *testing.T) {
+		status, result := check(t, "user_id="+user.ID.String()+"&resource=user&action=write")
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, models.PermissionGranted, result.Decision)
+		assert.Equal(t, []string{"editor"}, result.GrantedBy)
+		assert.Equal(t, "granted by role editor", result.Explanation)
+	})
+
+	t.Run("Denied", func(t *testing.T) {
+		status, result := check(t, "user_id="+user.ID.String()+"&resource=user&action=delete")
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, models.PermissionDenied, result.Decision)
+		assert.Empty(t, result.GrantedBy)
+	})
+
+	t.Run("Undefined permission", func(t *testing.T) {
+		status, result := check(t, "user_id="+user.ID.String()+"&resource=user&action=frobnicate")
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, models.PermissionUndefined, result.Decision)
+		assert.Equal(t, "no permission user:frobnicate exists", result.Explanation)
+	})
+
+	t.Run("Missing parameters", func(t *testing.T) {
+		status, _ := check(t, "user_id="+user.ID.String())
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+	})
+}
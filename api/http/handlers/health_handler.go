@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"github.com/chats/go-user-api/internal/health"
+	"github.com/gofiber/fiber/v2"
+)
+
+// HealthHandler reports the service and dependency health
+type HealthHandler struct {
+	registry *health.Registry
+}
+
+// NewHealthHandler creates a new health handler
+func NewHealthHandler(registry *health.Registry) *HealthHandler {
+	return &HealthHandler{
+		registry: registry,
+	}
+}
+
+// Healthz reports "ok", or "degraded" with the unhealthy dependencies while the service keeps serving without them
+func (h *HealthHandler) Healthz(c *fiber.Ctx) error {
+	status := "ok"
+	degraded := h.registry.Degraded()
+	if len(degraded) > 0 {
+		status = "degraded"
+	}
+
+	return c.JSON(fiber.Map{
+		"status":       status,
+		"degraded":     degraded,
+		"dependencies": h.registry.Statuses(),
+	})
+}
+
+// Readyz returns 503 until startup has finished (migrations applied, dependencies connected) and again once shutdown begins.
+// Degraded dependencies are listed but do not make the service unready, since it keeps serving without them.
+func (h *HealthHandler) Readyz(c *fiber.Ctx) error {
+	if !h.registry.Ready() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"status": "not ready",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":   "ready",
+		"degraded": h.registry.Degraded(),
+	})
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chats/go-user-api/api/http/middleware"
+	"github.com/chats/go-user-api/internal/events"
+	"github.com/chats/go-user-api/internal/health"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthHandler_Healthz(t *testing.T) {
+	registry := health.NewRegistry()
+	registry.Set(health.DependencyDatabase, nil)
+	registry.Set(health.DependencyCache, nil)
+
+	app := fiber.New()
+	app.Use(middleware.DegradedMiddleware(registry))
+	app.Get("/healthz", NewHealthHandler(registry).Healthz)
+
+	get := func(t *testing.T) (string, map[string]interface{}) {
+		t.Helper()
+
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/healthz", nil))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp.Header.Get(middleware.DegradedHeader), body
+	}
+
+	t.Run("All dependencies healthy", func(t *testing.T) {
+		header, body := get(t)
+
+		assert.Empty(t, header)
+		assert.Equal(t, "ok", body["status"])
+		assert.Empty(t, body["degraded"])
+	})
+
+	t.Run("Cache degraded", func(t *testing.T) {
+		registry.Set(health.DependencyCache, errors.New("caching is disabled"))
+
+		header, body := get(t)
+
+		assert.Equal(t, "cache", header)
+		assert.Equal(t, "degraded", body["status"])
+		assert.Equal(t, []interface{}{"cache"}, body["degraded"])
+
+		dependencies := body["dependencies"].(map[string]interface{})
+		cache := dependencies["cache"].(map[string]interface{})
+		assert.Equal(t, false, cache["healthy"])
+		assert.Equal(t, "caching is disabled", cache["error"])
+	})
+
+	t.Run("Database and cache degraded", func(t *testing.T) {
+		registry.Set(health.DependencyDatabase, errors.New("connection refused"))
+
+		header, _ := get(t)
+
+		assert.Equal(t, "cache,database", header)
+	})
+
+	t.Run("Recovered", func(t *testing.T) {
+		registry.Set(health.DependencyDatabase, nil)
+		registry.Set(health.DependencyCache, nil)
+
+		header, body := get(t)
+
+		assert.Empty(t, header)
+		assert.Equal(t, "ok", body["status"])
+	})
+}
+
+// brokerPublisher is an event publisher whose broker can be taken down
+type brokerPublisher struct {
+	down atomic.Bool
+}
+
+func (p *brokerPublisher) Publish(context.Context, events.Envelope) error {
+	return nil
+}
+
+func (p *brokerPublisher) Ping(context.Context) error {
+	if p.down.Load() {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func TestHealthHandler_Messaging(t *testing.T) {
+	publisher := &brokerPublisher{}
+	dispatcher := events.NewDispatcher(publisher, events.DispatcherConfig{BufferSize: 10})
+	defer dispatcher.Close(context.Background())
+
+	registry := health.NewRegistry()
+	registry.SetReady(true)
+	registry.Describe(health.DependencyMessaging, func() map[string]interface{} {
+		return map[string]interface{}{"last_published_at": dispatcher.LastPublishedAt()}
+	})
+	checks := map[string]health.CheckFunc{
+		health.DependencyDatabase:  func(context.Context) error { return nil },
+		health.DependencyMessaging: dispatcher.Check,
+	}
+
+	handler := NewHealthHandler(registry)
+	app := fiber.New()
+	app.Get("/healthz", handler.Healthz)
+	app.Get("/readyz", handler.Readyz)
+
+	get := func(t *testing.T, path string) map[string]interface{} {
+		t.Helper()
+
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode, "a broker outage is not fatal")
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return body
+	}
+
+	messaging := func(body map[string]interface{}) map[string]interface{} {
+		return body["dependencies"].(map[string]interface{})["messaging"].(map[string]interface{})
+	}
+
+	t.Run("Broker reachable", func(t *testing.T) {
+		require.True(t, dispatcher.Emit(events.NewEvent(events.TypeUserCreated).Build()))
+		require.Eventually(t, func() bool { return dispatcher.LastPublishedAt() != nil }, time.Second, time.Millisecond)
+		registry.Check(context.Background(), checks)
+
+		body := get(t, "/healthz")
+		assert.Equal(t, "ok", body["status"])
+		assert.Equal(t, true, messaging(body)["healthy"])
+		assert.NotEmpty(t, messaging(body)["details"].(map[string]interface{})["last_published_at"])
+
+		assert.Empty(t, get(t, "/readyz")["degraded"])
+	})
+
+	t.Run("Broker unreachable", func(t *testing.T) {
+		publisher.down.Store(true)
+		registry.Check(context.Background(), checks)
+
+		body := get(t, "/healthz")
+		assert.Equal(t, "degraded", body["status"])
+		assert.Equal(t, []interface{}{"messaging"}, body["degraded"])
+		assert.Equal(t, false, messaging(body)["healthy"])
+		assert.Contains(t, messaging(body)["error"], "connection refused")
+
+		ready := get(t, "/readyz")
+		assert.Equal(t, "ready", ready["status"])
+		assert.Equal(t, []interface{}{"messaging"}, ready["degraded"])
+	})
+
+	t.Run("Broker back", func(t *testing.T) {
+		publisher.down.Store(false)
+		registry.Check(context.Background(), checks)
+
+		body := get(t, "/healthz")
+		assert.Equal(t, "ok", body["status"])
+		assert.Equal(t, true, messaging(body)["healthy"])
+	})
+}
+
+func TestHealthHandler_Readyz(t *testing.T) {
+	registry := health.NewRegistry()
+
+	app := fiber.New()
+	app.Get("/readyz", NewHealthHandler(registry).Readyz)
+
+	get := func(t *testing.T) (int, map[string]interface{}) {
+		t.Helper()
+
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/readyz", nil))
+		require.NoError(t, err)
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp.StatusCode, body
+	}
+
+	t.Run("Before readiness", func(t *testing.T) {
+		status, body := get(t)
+
+		assert.Equal(t, fiber.StatusServiceUnavailable, status)
+		assert.Equal(t, "not ready", body["status"])
+	})
+
+	t.Run("After readiness", func(t *testing.T) {
+		registry.SetReady(true)
+
+		status, body := get(t)
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.Equal(t, "ready", body["status"])
+	})
+
+	t.Run("Degraded dependencies do not affect readiness", func(t *testing.T) {
+		registry.Set(health.DependencyCache, errors.New("caching is disabled"))
+
+		status, _ := get(t)
+
+		assert.Equal(t, fiber.StatusOK, status)
+	})
+
+	t.Run("Shutting down", func(t *testing.T) {
+		registry.SetReady(false)
+
+		status, _ := get(t)
+
+		assert.Equal(t, fiber.StatusServiceUnavailable, status)
+	})
+}
@@ -33,41 +33,118 @@ func (h *PermissionHandler) GetPermissions(c *fiber.Ctx) error {
 
 	// Get query parameters
 	resource := c.Query("resource", "")
+	action := c.Query("action", "")
+	category := c.Query("category", "")
+	if (resource != "" || action != "") && category != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Filter by resource and action, or by category, not both",
+		})
+	}
+
+	sort, err := models.ParseSortOptions(c.Query("sort_by"), c.Query("order"), models.PermissionSortFields)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid sort parameters",
+			"error":   err.Error(),
+		})
+	}
+
+	// Optionally count the roles granting each permission
+	withUsage := c.QueryBool("with_usage", false)
 
 	var permissions []models.PermissionResponse
-	var err error
 
-	// Get permissions by resource if provided, otherwise get all
-	if resource != "" {
+	// Get permissions by resource, action, both, or category if provided, otherwise get all. An empty
+	// ?resource= or ?action= is no filter and lists every permission; a blank one is rejected by the lookup.
+	switch {
+	case resource != "" && action != "":
+		h.tracer.SetAttributes(ctx,
+			attribute.String("resource", resource),
+			attribute.String("action", action),
+		)
+
+		permissions, err = h.permissionService.GetPermissionsByResourceAction(ctx, resource, action)
+	case resource != "":
 		h.tracer.SetAttributes(ctx,
 			attribute.String("resource", resource),
 		)
 
 		permissions, err = h.permissionService.GetPermissionsByResource(ctx, resource)
-	} else {
-		permissions, err = h.permissionService.GetAllPermissions(ctx)
+	case action != "":
+		h.tracer.SetAttributes(ctx,
+			attribute.String("action", action),
+		)
+
+		permissions, err = h.permissionService.GetPermissionsByAction(ctx, action)
+	case category != "":
+		h.tracer.SetAttributes(ctx,
+			attribute.String("category", category),
+			attribute.String("sort_by", sort.Field),
+			attribute.String("order", string(sort.Order)),
+		)
+
+		permissions, err = h.permissionService.GetPermissionsByCategory(ctx, category, sort)
+	default:
+		h.tracer.SetAttributes(ctx,
+			attribute.String("sort_by", sort.Field),
+			attribute.String("order", string(sort.Order)),
+		)
+
+		permissions, err = h.permissionService.GetAllPermissions(ctx, sort)
+	}
+
+	if err == nil && withUsage {
+		err = h.permissionService.AddRoleCounts(ctx, permissions)
 	}
 
 	if err != nil {
 		h.tracer.RecordError(ctx, err)
 
-		log.Error().Err(err).
+		errorLog(err).Err(err).
 			Str("resource", resource).
+			Str("action", action).
+			Str("category", category).
 			Msg("Failed to get permissions")
 
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to get permissions",
 			"error":   err.Error(),
 		})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+	return respond(c, fiber.StatusOK, fiber.Map{
 		"success": true,
 		"data":    permissions,
 	})
 }
 
+// GetPermissionCatalog retrieves all permissions grouped by category, then by resource
+func (h *PermissionHandler) GetPermissionCatalog(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "PermissionHandler.GetPermissionCatalog")
+	defer span.End()
+
+	catalog, err := h.permissionService.GetPermissionCatalog(ctx)
+	if err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		errorLog(err).Err(err).Msg("Failed to get permission catalog")
+
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get permission catalog",
+			"error":   err.Error(),
+		})
+	}
+
+	return respond(c, fiber.StatusOK, fiber.Map{
+		"success": true,
+		"data":    catalog,
+	})
+}
+
 // GetPermission retrieves a permission by ID
 func (h *PermissionHandler) GetPermission(c *fiber.Ctx) error {
 	ctx, span := h.tracer.StartSpan(c.Context(), "PermissionHandler.GetPermission")
@@ -91,18 +168,18 @@ func (h *PermissionHandler) GetPermission(c *fiber.Ctx) error {
 	if err != nil {
 		h.tracer.RecordError(ctx, err)
 
-		log.Error().Err(err).
+		errorLog(err).Err(err).
 			Str("permission_id", id).
 			Msg("Failed to get permission")
 
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		return c.Status(errorStatus(err, fiber.StatusNotFound)).JSON(fiber.Map{
 			"success": false,
-			"message": "Permission not found",
+			"message": errorMessage(err, "Permission not found"),
 			"error":   err.Error(),
 		})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+	return respond(c, fiber.StatusOK, fiber.Map{
 		"success": true,
 		"data":    permission,
 	})
@@ -129,11 +206,11 @@ func (h *PermissionHandler) CreatePermission(c *fiber.Ctx) error {
 		attribute.String("action", request.Action),
 	)
 
-	// Validate request
-	if request.Name == "" || request.Resource == "" || request.Action == "" {
+	// Validate request; the name defaults to resource:action
+	if request.Resource == "" || request.Action == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
-			"message": "Permission name, resource, and action are required",
+			"message": "Permission resource and action are required",
 		})
 	}
 
@@ -148,7 +225,7 @@ func (h *PermissionHandler) CreatePermission(c *fiber.Ctx) error {
 			Str("action", request.Action).
 			Msg("Failed to create permission")
 
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to create permission",
 			"error":   err.Error(),
@@ -163,7 +240,7 @@ func (h *PermissionHandler) CreatePermission(c *fiber.Ctx) error {
 		Str("permission_id", permission.ID.String()).
 		Msg("Permission created successfully")
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+	return respondCreated(c, "/api/v1/permissions/"+permission.ID.String(), fiber.Map{
 		"success": true,
 		"data":    permission,
 	})
@@ -206,9 +283,9 @@ func (h *PermissionHandler) UpdatePermission(c *fiber.Ctx) error {
 			Str("permission_id", id).
 			Msg("Failed to update permission")
 
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{
 			"success": false,
-			"message": "Failed to update permission",
+			"message": errorMessage(err, "Failed to update permission"),
 			"error":   err.Error(),
 		})
 	}
@@ -226,6 +303,39 @@ func (h *PermissionHandler) UpdatePermission(c *fiber.Ctx) error {
 	})
 }
 
+// ReconcilePermissions creates the built-in permissions missing from the catalog
+func (h *PermissionHandler) ReconcilePermissions(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "PermissionHandler.ReconcilePermissions")
+	defer span.End()
+
+	updateDescriptions := c.QueryBool("update_descriptions", false)
+
+	result, err := h.permissionService.ReconcilePermissions(ctx, updateDescriptions)
+	if err != nil {
+		h.tracer.RecordError(ctx, err)
+		log.Error().Err(err).Msg("Failed to reconcile permissions")
+
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to reconcile permissions",
+			"error":   err.Error(),
+		})
+	}
+
+	// Log activity
+	adminID, _ := c.Locals("userID").(string)
+	log.Info().
+		Str("admin_id", adminID).
+		Int("created", len(result.Created)).
+		Int("updated", len(result.Updated)).
+		Msg("Permissions reconciled")
+
+	return respond(c, fiber.StatusOK, fiber.Map{
+		"success": true,
+		"data":    result,
+	})
+}
+
 // DeletePermission deletes a permission
 func (h *PermissionHandler) DeletePermission(c *fiber.Ctx) error {
 	ctx, span := h.tracer.StartSpan(c.Context(), "PermissionHandler.DeletePermission")
@@ -253,9 +363,9 @@ func (h *PermissionHandler) DeletePermission(c *fiber.Ctx) error {
 			Str("permission_id", id).
 			Msg("Permission not found for deletion")
 
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		return c.Status(errorStatus(err, fiber.StatusNotFound)).JSON(fiber.Map{
 			"success": false,
-			"message": "Permission not found",
+			"message": errorMessage(err, "Permission not found"),
 			"error":   err.Error(),
 		})
 	}
@@ -269,9 +379,9 @@ func (h *PermissionHandler) DeletePermission(c *fiber.Ctx) error {
 			Str("permission_id", id).
 			Msg("Failed to delete permission")
 
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
 			"success": false,
-			"message": "Failed to delete permission",
+			"message": errorMessage(err, "Failed to delete permission"),
 			"error":   err.Error(),
 		})
 	}
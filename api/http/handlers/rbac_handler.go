@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/services"
+	"github.com/chats/go-user-api/internal/tracing"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// RBACHandler handles exporting and importing the role and permission configuration
+type RBACHandler struct {
+	rbacService *services.RBACService
+	tracer      *tracing.Tracer
+}
+
+// NewRBACHandler creates a new RBAC handler
+func NewRBACHandler(
+	rbacService *services.RBACService,
+	tracer *tracing.Tracer,
+) *RBACHandler {
+	return &RBACHandler{
+		rbacService: rbacService,
+		tracer:      tracer,
+	}
+}
+
+// ExportRBAC returns all roles, permissions and their mappings as one document
+func (h *RBACHandler) ExportRBAC(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "RBACHandler.ExportRBAC")
+	defer span.End()
+
+	document, err := h.rbacService.ExportRBAC(ctx)
+	if err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		errorLog(err).Err(err).Msg("Failed to export RBAC configuration")
+
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to export RBAC configuration",
+			"error":   err.Error(),
+		})
+	}
+
+	return respond(c, fiber.StatusOK, fiber.Map{
+		"success": true,
+		"data":    document,
+	})
+}
+
+// ImportRBAC applies an exported document; ?prune=true deletes roles and permissions it does not list
+func (h *RBACHandler) ImportRBAC(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "RBACHandler.ImportRBAC")
+	defer span.End()
+
+	var document models.RBACDocument
+	if err := c.BodyParser(&document); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request",
+			"error":   err.Error(),
+		})
+	}
+
+	prune := c.QueryBool("prune")
+
+	h.tracer.SetAttributes(ctx,
+		attribute.Bool("prune", prune),
+		attribute.Int("permissions", len(document.Permissions)),
+		attribute.Int("roles", len(document.Roles)),
+	)
+
+	adminID, _ := c.Locals("userID").(string)
+	result, err := h.rbacService.ImportRBAC(ctx, document, prune)
+	if err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		log.Error().Err(err).
+			Str("admin_id", adminID).
+			Bool("prune", prune).
+			Msg("Failed to import RBAC configuration")
+
+		status := fiber.StatusBadRequest
+		if errors.Is(err, services.ErrRBACConflict) {
+			status = fiber.StatusConflict
+		}
+
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to import RBAC configuration",
+			"error":   err.Error(),
+		})
+	}
+
+	// Log activity
+	log.Info().
+		Str("admin_id", adminID).
+		Bool("prune", prune).
+		Strs("permissions_created", result.PermissionsCreated).
+		Strs("permissions_updated", result.PermissionsUpdated).
+		Strs("permissions_deleted", result.PermissionsDeleted).
+		Strs("roles_created", result.RolesCreated).
+		Strs("roles_updated", result.RolesUpdated).
+		Strs("roles_deleted", result.RolesDeleted).
+		Msg("RBAC configuration imported")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    result,
+	})
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"github.com/chats/go-user-api/api/http/middleware"
+	"github.com/chats/go-user-api/internal/msgpack"
+	"github.com/gofiber/fiber/v2"
+)
+
+// respond writes body as MessagePack when the client negotiated it and as JSON otherwise
+func respond(c *fiber.Ctx, status int, body interface{}) error {
+	if format, _ := c.Locals(middleware.ResponseFormatKey).(string); format == msgpack.ContentType {
+		data, err := msgpack.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		c.Set(fiber.HeaderContentType, msgpack.ContentType)
+		return c.Status(status).Send(data)
+	}
+
+	return c.Status(status).JSON(body)
+}
+
+// respondCreated writes a 201 response with a Location header pointing at the new resource
+func respondCreated(c *fiber.Ctx, location string, body interface{}) error {
+	c.Set(fiber.HeaderLocation, location)
+	return respond(c, fiber.StatusCreated, body)
+}
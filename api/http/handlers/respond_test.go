@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chats/go-user-api/api/http/middleware"
+	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/mocks"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/msgpack"
+	"github.com/chats/go-user-api/internal/repositories/transaction"
+	"github.com/chats/go-user-api/internal/services"
+	"github.com/chats/go-user-api/internal/tracing"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRespond(t *testing.T) {
+	lastLogin := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+	user := models.UserResponse{
+		ID:          uuid.New(),
+		Username:    "johndoe",
+		Email:       "john@example.com",
+		FirstName:   "John",
+		LastName:    "Doe",
+		IsActive:    true,
+		LastLoginAt: &lastLogin,
+		CreatedAt:   lastLogin.Add(-time.Hour),
+		UpdatedAt:   lastLogin,
+		Roles:       []models.RoleResponse{{ID: uuid.New(), Name: "admin", Description: "Administrator"}},
+	}
+
+	type envelope struct {
+		Success bool                  `json:"success"`
+		Data    []models.UserResponse `json:"data"`
+		Total   int                   `json:"total"`
+	}
+	want := envelope{Success: true, Data: []models.UserResponse{user}, Total: 1}
+
+	newApp := func(cfg *config.Config) *fiber.App {
+		app := fiber.New()
+		app.Use(middleware.ContentNegotiationMiddleware(cfg))
+		app.Get("/users", func(c *fiber.Ctx) error {
+			return respond(c, fiber.StatusOK, fiber.Map{
+				"success": true,
+				"data":    []models.UserResponse{user},
+				"total":   1,
+			})
+		})
+		return app
+	}
+
+	get := func(t *testing.T, app *fiber.App, accept string) (string, []byte) {
+		t.Helper()
+
+		req := httptest.NewRequest(fiber.MethodGet, "/users", nil)
+		if accept != "" {
+			req.Header.Set(fiber.HeaderAccept, accept)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.Header.Get(fiber.HeaderContentType), body
+	}
+
+	decodeJSON := func(t *testing.T, body []byte) envelope {
+		t.Helper()
+
+		var got envelope
+		require.NoError(t, json.Unmarshal(body, &got))
+		return got
+	}
+
+	app := newApp(&config.Config{ResponseMsgpackEnabled: true})
+
+	t.Run("JSON by default", func(t *testing.T) {
+		contentType, body := get(t, app, "")
+
+		assert.Contains(t, contentType, fiber.MIMEApplicationJSON)
+		assert.Equal(t, want, decodeJSON(t, body))
+	})
+
+	t.Run("JSON requested", func(t *testing.T) {
+		contentType, body := get(t, app, "application/json")
+
+		assert.Contains(t, contentType, fiber.MIMEApplicationJSON)
+		assert.Equal(t, want, decodeJSON(t, body))
+	})
+
+	t.Run("MessagePack requested", func(t *testing.T) {
+		contentType, body := get(t, app, "application/msgpack")
+
+		assert.Equal(t, msgpack.ContentType, contentType)
+
+		// The body carries the same structure as the JSON response
+		encoded, err := msgpack.Marshal(want)
+		require.NoError(t, err)
+		assert.Equal(t, encoded, body)
+	})
+
+	t.Run("MessagePack preferred by quality", func(t *testing.T) {
+		contentType, _ := get(t, app, "application/json;q=0.5, application/msgpack")
+
+		assert.Equal(t, msgpack.ContentType, contentType)
+	})
+
+	t.Run("Wildcard gets JSON", func(t *testing.T) {
+		contentType, _ := get(t, app, "*/*")
+
+		assert.Contains(t, contentType, fiber.MIMEApplicationJSON)
+	})
+
+	t.Run("MessagePack disabled", func(t *testing.T) {
+		contentType, body := get(t, newApp(&config.Config{}), "application/msgpack")
+
+		assert.Contains(t, contentType, fiber.MIMEApplicationJSON)
+		assert.Equal(t, want, decodeJSON(t, body))
+	})
+}
+
+func TestRespondCreated_Location(t *testing.T) {
+	tracer, err := tracing.NewTracer(&config.Config{JaegerEndpoint: "http://localhost:14268/api/traces"})
+	require.NoError(t, err)
+
+	id := uuid.New()
+
+	// txManager runs the transaction against a repository that gives every created entity id
+	txManager := func() *mocks.Manager[transaction.Repository] {
+		mockTxRepo := new(mocks.MockTxRepository)
+		mockTxRepo.On("CreateUser", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			args.Get(1).(*models.User).ID = id
+		})
+		mockTxRepo.On("CreateRole", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			args.Get(1).(*models.Role).ID = id
+		})
+		mockTxRepo.On("CreatePermission", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			args.Get(1).(*models.Permission).ID = id
+		})
+
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			args.Get(1).(func(transaction.Repository) error)(mockTxRepo)
+		})
+		return mockTxManager
+	}
+
+	notFound := errors.New("not found")
+
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		handler  func() fiber.Handler
+		location string
+	}{
+		{
+			name: "User",
+			path: "/api/v1/users",
+			body: `{"username": "johndoe", "email": "john@example.com", "password": "password123"}`,
+			handler: func() fiber.Handler {
+				mockUserRepo := new(mocks.MockUserRepository)
+				mockUserRepo.On("GetByUsername", mock.Anything, "johndoe").Return(nil, notFound)
+				mockUserRepo.On("InvalidateUser", id).Return()
+				mockUserRepo.On("GetByID", mock.Anything, id).Return(nil, notFound)
+				userService := services.NewUserService(mockUserRepo, new(mocks.MockRoleRepository), txManager(), services.DefaultUserServiceOptions())
+				return NewUserHandler(userService, tracer).CreateUser
+			},
+			location: "/api/v1/users/" + id.String(),
+		},
+		{
+			name: "Role",
+			path: "/api/v1/roles",
+			body: `{"name": "editor"}`,
+			handler: func() fiber.Handler {
+				mockRoleRepo := new(mocks.MockRoleRepository)
+				mockRoleRepo.On("GetByName", mock.Anything, "editor").Return(nil, notFound)
+				mockRoleRepo.On("GetByID", mock.Anything, id).Return(nil, notFound)
+				roleService := services.NewRoleService(mockRoleRepo, new(mocks.MockPermissionRepository), txManager(), services.DefaultRoleServiceOptions())
+				return NewRoleHandler(roleService, tracer).CreateRole
+			},
+			location: "/api/v1/roles/" + id.String(),
+		},
+		{
+			name: "Permission",
+			path: "/api/v1/permissions",
+			body: `{"resource": "report", "action": "read"}`,
+			handler: func() fiber.Handler {
+				mockPermissionRepo := new(mocks.MockPermissionRepository)
+				mockPermissionRepo.On("GetByResourceAction", mock.Anything, "report", "read").Return(nil, notFound)
+				permissionService := services.NewPermissionService(mockPermissionRepo, txManager(), &config.Config{})
+				return NewPermissionHandler(permissionService, tracer).CreatePermission
+			},
+			location: "/api/v1/permissions/" + id.String(),
+		},
+		{
+			name: "API key",
+			path: "/api/v1/admin/api-keys",
+			body: `{"name": "reporting", "permissions": ["report:read"]}`,
+			handler: func() fiber.Handler {
+				mockAPIKeyRepo := new(mocks.MockAPIKeyRepository)
+				mockAPIKeyRepo.On("Create", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+					args.Get(1).(*models.APIKey).ID = id
+				})
+				return NewAPIKeyHandler(services.NewAPIKeyService(mockAPIKeyRepo), tracer).CreateAPIKey
+			},
+			location: "/api/v1/admin/api-keys/" + id.String(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Post(tt.path, func(c *fiber.Ctx) error {
+				c.Locals("userID", uuid.New().String())
+				return c.Next()
+			}, tt.handler())
+
+			req := httptest.NewRequest(fiber.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+			assert.Equal(t, tt.location, resp.Header.Get(fiber.HeaderLocation))
+		})
+	}
+}
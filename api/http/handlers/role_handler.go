@@ -1,6 +1,10 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
 	"github.com/chats/go-user-api/internal/models"
 	"github.com/chats/go-user-api/internal/services"
 	"github.com/chats/go-user-api/internal/tracing"
@@ -31,21 +35,39 @@ func (h *RoleHandler) GetRoles(c *fiber.Ctx) error {
 	ctx, span := h.tracer.StartSpan(c.Context(), "RoleHandler.GetRoles")
 	defer span.End()
 
+	// Permissions are included unless the caller only needs the role names
+	includePermissions := c.QueryBool("include_permissions", true)
+
+	sort, err := models.ParseSortOptions(c.Query("sort_by"), c.Query("order"), models.RoleSortFields)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid sort parameters",
+			"error":   err.Error(),
+		})
+	}
+
+	h.tracer.SetAttributes(ctx,
+		attribute.Bool("include_permissions", includePermissions),
+		attribute.String("sort_by", sort.Field),
+		attribute.String("order", string(sort.Order)),
+	)
+
 	// Get roles
-	roles, err := h.roleService.GetAllRoles(ctx)
+	roles, err := h.roleService.GetAllRoles(ctx, includePermissions, sort)
 	if err != nil {
 		h.tracer.RecordError(ctx, err)
 
-		log.Error().Err(err).Msg("Failed to get roles")
+		errorLog(err).Err(err).Msg("Failed to get roles")
 
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to get roles",
 			"error":   err.Error(),
 		})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+	return respond(c, fiber.StatusOK, fiber.Map{
 		"success": true,
 		"data":    roles,
 	})
@@ -65,8 +87,12 @@ func (h *RoleHandler) GetRole(c *fiber.Ctx) error {
 		})
 	}
 
+	// Optionally count the users holding the role and the permissions it grants
+	withCounts := c.QueryBool("with_counts", false)
+
 	h.tracer.SetAttributes(ctx,
 		attribute.String("role_id", id),
+		attribute.Bool("with_counts", withCounts),
 	)
 
 	// Get role
@@ -74,18 +100,34 @@ func (h *RoleHandler) GetRole(c *fiber.Ctx) error {
 	if err != nil {
 		h.tracer.RecordError(ctx, err)
 
-		log.Error().Err(err).
+		errorLog(err).Err(err).
 			Str("role_id", id).
 			Msg("Failed to get role")
 
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		return c.Status(errorStatus(err, fiber.StatusNotFound)).JSON(fiber.Map{
 			"success": false,
-			"message": "Role not found",
+			"message": errorMessage(err, "Role not found"),
 			"error":   err.Error(),
 		})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+	if withCounts {
+		if err := h.roleService.AddCounts(ctx, role); err != nil {
+			h.tracer.RecordError(ctx, err)
+
+			errorLog(err).Err(err).
+				Str("role_id", id).
+				Msg("Failed to count role users and permissions")
+
+			return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to count role users and permissions",
+				"error":   err.Error(),
+			})
+		}
+	}
+
+	return respond(c, fiber.StatusOK, fiber.Map{
 		"success": true,
 		"data":    role,
 	})
@@ -119,7 +161,7 @@ func (h *RoleHandler) CreateRole(c *fiber.Ctx) error {
 	}
 
 	// Create role
-	role, err := h.roleService.CreateRole(ctx, request)
+	role, err := h.roleService.CreateRole(withActor(ctx, c), request)
 	if err != nil {
 		h.tracer.RecordError(ctx, err)
 
@@ -127,7 +169,7 @@ func (h *RoleHandler) CreateRole(c *fiber.Ctx) error {
 			Str("role_name", request.Name).
 			Msg("Failed to create role")
 
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to create role",
 			"error":   err.Error(),
@@ -142,7 +184,7 @@ func (h *RoleHandler) CreateRole(c *fiber.Ctx) error {
 		Str("role_id", role.ID.String()).
 		Msg("Role created successfully")
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+	return respondCreated(c, "/api/v1/roles/"+role.ID.String(), fiber.Map{
 		"success": true,
 		"data":    role,
 	})
@@ -177,7 +219,7 @@ func (h *RoleHandler) UpdateRole(c *fiber.Ctx) error {
 	)
 
 	// Update role
-	role, err := h.roleService.UpdateRole(ctx, id, request)
+	role, err := h.roleService.UpdateRole(withActor(ctx, c), id, request)
 	if err != nil {
 		h.tracer.RecordError(ctx, err)
 
@@ -185,9 +227,9 @@ func (h *RoleHandler) UpdateRole(c *fiber.Ctx) error {
 			Str("role_id", id).
 			Msg("Failed to update role")
 
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{
 			"success": false,
-			"message": "Failed to update role",
+			"message": errorMessage(err, "Failed to update role"),
 			"error":   err.Error(),
 		})
 	}
@@ -232,9 +274,9 @@ func (h *RoleHandler) DeleteRole(c *fiber.Ctx) error {
 			Str("role_id", id).
 			Msg("Role not found for deletion")
 
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		return c.Status(errorStatus(err, fiber.StatusNotFound)).JSON(fiber.Map{
 			"success": false,
-			"message": "Role not found",
+			"message": errorMessage(err, "Role not found"),
 			"error":   err.Error(),
 		})
 	}
@@ -248,9 +290,9 @@ func (h *RoleHandler) DeleteRole(c *fiber.Ctx) error {
 			Str("role_id", id).
 			Msg("Failed to delete role")
 
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
 			"success": false,
-			"message": "Failed to delete role",
+			"message": errorMessage(err, "Failed to delete role"),
 			"error":   err.Error(),
 		})
 	}
@@ -269,6 +311,179 @@ func (h *RoleHandler) DeleteRole(c *fiber.Ctx) error {
 	})
 }
 
+// DiffRolePermissions compares the permissions of two roles
+func (h *RoleHandler) DiffRolePermissions(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "RoleHandler.DiffRolePermissions")
+	defer span.End()
+
+	id := c.Params("id")
+	otherID := c.Params("otherId")
+
+	h.tracer.SetAttributes(ctx,
+		attribute.String("role_id", id),
+		attribute.String("other_role_id", otherID),
+	)
+
+	diff, err := h.roleService.DiffRolePermissions(ctx, id, otherID)
+	if err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		log.Error().Err(err).
+			Str("role_id", id).
+			Str("other_role_id", otherID).
+			Msg("Failed to diff role permissions")
+
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Role not found",
+			"error":   err.Error(),
+		})
+	}
+
+	return respond(c, fiber.StatusOK, fiber.Map{
+		"success": true,
+		"data":    diff,
+	})
+}
+
+// PreviewRolePermissionChange reports what replacing a role's permissions would change without applying it
+func (h *RoleHandler) PreviewRolePermissionChange(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "RoleHandler.PreviewRolePermissionChange")
+	defer span.End()
+
+	id := c.Params("id")
+
+	// Parse request body
+	var request models.RolePermissionImpactRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request",
+			"error":   err.Error(),
+		})
+	}
+
+	h.tracer.SetAttributes(ctx,
+		attribute.String("role_id", id),
+		attribute.Int("permission_count", len(request.PermissionIDs)),
+	)
+
+	// Check if role exists
+	if _, err := h.roleService.GetRoleByID(ctx, id); err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		log.Error().Err(err).
+			Str("role_id", id).
+			Msg("Role not found for permission impact")
+
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Role not found",
+			"error":   err.Error(),
+		})
+	}
+
+	impact, err := h.roleService.PreviewRolePermissionChange(ctx, id, request)
+	if err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		log.Error().Err(err).
+			Str("role_id", id).
+			Msg("Failed to preview role permission change")
+
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to preview role permission change",
+			"error":   err.Error(),
+		})
+	}
+
+	return respond(c, fiber.StatusOK, fiber.Map{
+		"success": true,
+		"data":    impact,
+	})
+}
+
+// AddRolePermissions grants a role permissions without touching the ones it already holds
+func (h *RoleHandler) AddRolePermissions(c *fiber.Ctx) error {
+	return h.changeRolePermissions(c, "RoleHandler.AddRolePermissions", "add", h.roleService.AddRolePermissions)
+}
+
+// RemoveRolePermissions revokes permissions from a role without touching the others
+func (h *RoleHandler) RemoveRolePermissions(c *fiber.Ctx) error {
+	return h.changeRolePermissions(c, "RoleHandler.RemoveRolePermissions", "remove", h.roleService.RemoveRolePermissions)
+}
+
+// changeRolePermissions handles an incremental change to a role's permissions
+func (h *RoleHandler) changeRolePermissions(
+	c *fiber.Ctx,
+	spanName, verb string,
+	change func(ctx context.Context, id string, request models.RolePermissionsChangeRequest) (*models.RolePermissionsChangeResponse, error),
+) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), spanName)
+	defer span.End()
+
+	id := c.Params("id")
+
+	// Parse request body
+	var request models.RolePermissionsChangeRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request",
+			"error":   err.Error(),
+		})
+	}
+
+	h.tracer.SetAttributes(ctx,
+		attribute.String("role_id", id),
+		attribute.Int("permission_count", len(request.PermissionIDs)),
+	)
+
+	// Check if role exists
+	if _, err := h.roleService.GetRoleByID(ctx, id); err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		log.Error().Err(err).
+			Str("role_id", id).
+			Msgf("Role not found to %s permissions", verb)
+
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Role not found",
+			"error":   err.Error(),
+		})
+	}
+
+	result, err := change(withActor(ctx, c), id, request)
+	if err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		log.Error().Err(err).
+			Str("role_id", id).
+			Msgf("Failed to %s role permissions", verb)
+
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{
+			"success": false,
+			"message": fmt.Sprintf("Failed to %s role permissions", verb),
+			"error":   err.Error(),
+		})
+	}
+
+	// Log activity
+	adminID, _ := c.Locals("userID").(string)
+	log.Info().
+		Str("admin_id", adminID).
+		Str("role_id", id).
+		Strs("changed", result.Changed).
+		Msg("Role permissions changed successfully")
+
+	return respond(c, fiber.StatusOK, fiber.Map{
+		"success": true,
+		"data":    result,
+	})
+}
+
 // GetRolePermissions retrieves permissions for a role
 func (h *RoleHandler) GetRolePermissions(c *fiber.Ctx) error {
 	ctx, span := h.tracer.StartSpan(c.Context(), "RoleHandler.GetRolePermissions")
@@ -292,11 +507,11 @@ func (h *RoleHandler) GetRolePermissions(c *fiber.Ctx) error {
 	if err != nil {
 		h.tracer.RecordError(ctx, err)
 
-		log.Error().Err(err).
+		errorLog(err).Err(err).
 			Str("role_id", id).
 			Msg("Role not found for permissions lookup")
 
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		return c.Status(errorStatus(err, fiber.StatusNotFound)).JSON(fiber.Map{
 			"success": false,
 			"message": "Role not found",
 			"error":   err.Error(),
@@ -308,19 +523,75 @@ func (h *RoleHandler) GetRolePermissions(c *fiber.Ctx) error {
 	if err != nil {
 		h.tracer.RecordError(ctx, err)
 
-		log.Error().Err(err).
+		errorLog(err).Err(err).
 			Str("role_id", id).
 			Msg("Failed to get role permissions")
 
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to get role permissions",
 			"error":   err.Error(),
 		})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+	return respond(c, fiber.StatusOK, fiber.Map{
 		"success": true,
 		"data":    permissions,
 	})
 }
+
+// ValidateRolePermissions checks permission IDs for a role without assigning them
+func (h *RoleHandler) ValidateRolePermissions(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "RoleHandler.ValidateRolePermissions")
+	defer span.End()
+
+	// Get role ID from path
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Role ID is required",
+		})
+	}
+
+	// Parse request body
+	var request models.RolePermissionsValidateRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request",
+			"error":   err.Error(),
+		})
+	}
+
+	h.tracer.SetAttributes(ctx,
+		attribute.String("role_id", id),
+		attribute.Int("permission_count", len(request.PermissionIDs)),
+	)
+
+	// Validate permissions; the service reports a role that does not exist
+	result, err := h.roleService.ValidateRolePermissions(ctx, id, request)
+	if err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		errorLog(err).Err(err).
+			Str("role_id", id).
+			Msg("Failed to validate role permissions")
+
+		status, message := fiber.StatusInternalServerError, "Failed to validate role permissions"
+		if errors.Is(err, models.ErrRoleNotFound) {
+			status, message = fiber.StatusNotFound, "Role not found"
+		}
+
+		return c.Status(errorStatus(err, status)).JSON(fiber.Map{
+			"success": false,
+			"message": errorMessage(err, message),
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    result,
+	})
+}
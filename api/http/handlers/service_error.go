@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/repositories/transaction"
+	"github.com/chats/go-user-api/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// StatusClientClosedRequest is the non-standard status for a request the client abandoned before it completed
+const StatusClientClosedRequest = 499
+
+// errorStatus returns the status for a failed service call: 400 when an ID is not a valid UUID or a
+// permission is looked up by a blank resource or action, 403 when the caller grants permissions it
+// does not hold, 409 when a username, email, role name or permission is already taken, 499 when the
+// client canceled the request, 501 when the change needs transactions the database does not support,
+// 504 when its deadline passed, and fallback for any other error
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidID), errors.Is(err, models.ErrBlankPermissionLookup):
+		return fiber.StatusBadRequest
+	case errors.Is(err, services.ErrPrivilegeEscalation):
+		return fiber.StatusForbidden
+	case errors.Is(err, models.ErrUsernameExists), errors.Is(err, models.ErrEmailExists),
+		errors.Is(err, models.ErrRoleNameExists),
+		errors.Is(err, models.ErrPermissionExists), errors.Is(err, models.ErrPermissionNameExists):
+		return fiber.StatusConflict
+	case errors.Is(err, services.ErrRequestCanceled):
+		return StatusClientClosedRequest
+	case errors.Is(err, transaction.ErrNotAtomic):
+		return fiber.StatusNotImplemented
+	case errors.Is(err, services.ErrRequestTimeout):
+		return fiber.StatusGatewayTimeout
+	default:
+		return fallback
+	}
+}
+
+// errorMessage returns the message for a failed service call, or one saying the ID is malformed when it
+// did not parse, since the message otherwise suggests the entity was looked up
+func errorMessage(err error, fallback string) string {
+	if errors.Is(err, services.ErrInvalidID) {
+		return "Invalid ID"
+	}
+	return fallback
+}
+
+// errorLog returns the event a failed service call is logged on. A client canceling its request is
+// not a server error, so it is only logged at debug level.
+func errorLog(err error) *zerolog.Event {
+	if errors.Is(err, services.ErrRequestCanceled) {
+		return log.Debug()
+	}
+	return log.Error()
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/mocks"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/repositories/transaction"
+	"github.com/chats/go-user-api/internal/services"
+	"github.com/chats/go-user-api/internal/tracing"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlers_InvalidID(t *testing.T) {
+	tracer, err := tracing.NewTracer(&config.Config{JaegerEndpoint: "http://localhost:14268/api/traces"})
+	require.NoError(t, err)
+
+	// No repository call is expected: the ID is rejected before any lookup
+	txManager := new(mocks.Manager[transaction.Repository])
+	userHandler := NewUserHandler(services.NewUserService(new(mocks.MockUserRepository), new(mocks.MockRoleRepository), txManager, services.DefaultUserServiceOptions()), tracer)
+	roleHandler := NewRoleHandler(services.NewRoleService(new(mocks.MockRoleRepository), new(mocks.MockPermissionRepository), txManager, services.DefaultRoleServiceOptions()), tracer)
+	permissionHandler := NewPermissionHandler(services.NewPermissionService(new(mocks.MockPermissionRepository), txManager, &config.Config{}), tracer)
+
+	app := fiber.New()
+	app.Get("/users/:id", userHandler.GetUser)
+	app.Put("/users/:id", userHandler.UpdateUser)
+	app.Delete("/users/:id", userHandler.DeleteUser)
+	app.Get("/roles/:id", roleHandler.GetRole)
+	app.Put("/roles/:id", roleHandler.UpdateRole)
+	app.Delete("/roles/:id", roleHandler.DeleteRole)
+	app.Get("/permissions/:id", permissionHandler.GetPermission)
+	app.Put("/permissions/:id", permissionHandler.UpdatePermission)
+	app.Delete("/permissions/:id", permissionHandler.DeletePermission)
+
+	tests := []struct {
+		method string
+		path   string
+		kind   string
+	}{
+		{fiber.MethodGet, "/users/not-a-uuid", "user"},
+		{fiber.MethodPut, "/users/not-a-uuid", "user"},
+		{fiber.MethodDelete, "/users/not-a-uuid", "user"},
+		{fiber.MethodGet, "/roles/not-a-uuid", "role"},
+		{fiber.MethodPut, "/roles/not-a-uuid", "role"},
+		{fiber.MethodDelete, "/roles/not-a-uuid", "role"},
+		{fiber.MethodGet, "/permissions/not-a-uuid", "permission"},
+		{fiber.MethodPut, "/permissions/not-a-uuid", "permission"},
+		{fiber.MethodDelete, "/permissions/not-a-uuid", "permission"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{}`))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+			assert.Equal(t, "Invalid ID", body["message"])
+			assert.Equal(t, `invalid `+tt.kind+` ID "not-a-uuid": not a valid UUID`, body["error"])
+		})
+	}
+}
+
+func TestPermissionHandler_ResourceFilter(t *testing.T) {
+	tracer, err := tracing.NewTracer(&config.Config{JaegerEndpoint: "http://localhost:14268/api/traces"})
+	require.NoError(t, err)
+
+	call := func(t *testing.T, mockPermissionRepo *mocks.MockPermissionRepository, path string) (int, map[string]interface{}) {
+		t.Helper()
+
+		permissionService := services.NewPermissionService(mockPermissionRepo, new(mocks.Manager[transaction.Repository]), &config.Config{})
+		app := fiber.New()
+		app.Get("/permissions", NewPermissionHandler(permissionService, tracer).GetPermissions)
+
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil))
+		require.NoError(t, err)
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp.StatusCode, body
+	}
+
+	t.Run("Empty resource lists every permission", func(t *testing.T) {
+		mockPermissionRepo := new(mocks.MockPermissionRepository)
+		mockPermissionRepo.On("GetAll", mock.Anything, mock.Anything).Return([]*models.Permission{
+			{ID: uuid.New(), Name: "user:read", Resource: "user", Action: "read"},
+			{ID: uuid.New(), Name: "role:read", Resource: "role", Action: "read"},
+		}, nil)
+
+		status, body := call(t, mockPermissionRepo, "/permissions?resource=")
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.Len(t, body["data"], 2)
+		mockPermissionRepo.AssertNotCalled(t, "GetByResource", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Blank resource rejected", func(t *testing.T) {
+		mockPermissionRepo := new(mocks.MockPermissionRepository)
+		mockPermissionRepo.On("GetByResource", mock.Anything, "  ").Return(([]*models.Permission)(nil), models.CheckPermissionLookup("resource", "  "))
+
+		status, body := call(t, mockPermissionRepo, "/permissions?resource=%20%20")
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+		assert.Contains(t, body["error"], "resource is blank")
+	})
+}
+
+func TestRoleHandler_ValidateRolePermissions(t *testing.T) {
+	tracer, err := tracing.NewTracer(&config.Config{JaegerEndpoint: "http://localhost:14268/api/traces"})
+	require.NoError(t, err)
+
+	call := func(t *testing.T, mockRoleRepo *mocks.MockRoleRepository, path string) (int, map[string]interface{}) {
+		t.Helper()
+
+		mockPermissionRepo := new(mocks.MockPermissionRepository)
+		mockPermissionRepo.On("GetAll", mock.Anything, models.SortOptions{}).Return([]*models.Permission{}, nil)
+		roleService := services.NewRoleService(mockRoleRepo, mockPermissionRepo, new(mocks.Manager[transaction.Repository]), services.DefaultRoleServiceOptions())
+		app := fiber.New()
+		app.Post("/roles/:id/permissions/validate", NewRoleHandler(roleService, tracer).ValidateRolePermissions)
+
+		req := httptest.NewRequest(fiber.MethodPost, path, strings.NewReader(`{"permission_ids":[]}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp.StatusCode, body
+	}
+
+	t.Run("Role looked up once", func(t *testing.T) {
+		role := &models.Role{ID: uuid.New(), Name: "editor"}
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockRoleRepo.On("GetByID", mock.Anything, role.ID).Return(role, nil)
+
+		status, _ := call(t, mockRoleRepo, "/roles/"+role.ID.String()+"/permissions/validate")
+
+		assert.Equal(t, fiber.StatusOK, status)
+		mockRoleRepo.AssertNumberOfCalls(t, "GetByID", 1)
+	})
+
+	t.Run("Unknown role", func(t *testing.T) {
+		roleID := uuid.New()
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockRoleRepo.On("GetByID", mock.Anything, roleID).Return(nil, models.ErrRoleNotFound)
+
+		status, body := call(t, mockRoleRepo, "/roles/"+roleID.String()+"/permissions/validate")
+
+		assert.Equal(t, fiber.StatusNotFound, status)
+		assert.Equal(t, "Role not found", body["message"])
+	})
+
+	t.Run("Lookup failure", func(t *testing.T) {
+		roleID := uuid.New()
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockRoleRepo.On("GetByID", mock.Anything, roleID).Return(nil, errors.New("connection refused"))
+
+		status, _ := call(t, mockRoleRepo, "/roles/"+roleID.String()+"/permissions/validate")
+
+		assert.Equal(t, fiber.StatusInternalServerError, status)
+	})
+
+	t.Run("Invalid role ID", func(t *testing.T) {
+		status, body := call(t, new(mocks.MockRoleRepository), "/roles/not-a-uuid/permissions/validate")
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+		assert.Equal(t, "Invalid ID", body["message"])
+	})
+}
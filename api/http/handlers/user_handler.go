@@ -1,6 +1,12 @@
 package handlers
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/chats/go-user-api/internal/logger"
 	"github.com/chats/go-user-api/internal/models"
 	"github.com/chats/go-user-api/internal/services"
 	"github.com/chats/go-user-api/internal/tracing"
@@ -35,17 +41,60 @@ func (h *UserHandler) GetUsers(c *fiber.Ctx) error {
 	page := c.QueryInt("page", 1)
 	pageSize := c.QueryInt("page_size", 10)
 
+	sort, err := models.ParseSortOptions(c.Query("sort_by"), c.Query("order"), models.UserSortFields)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid sort parameters",
+			"error":   err.Error(),
+		})
+	}
+
+	// Restrict to users created within an inclusive range
+	createdFrom, createdTo, err := models.ParseCreatedRange(c.Query("created_from"), c.Query("created_to"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid created date range",
+			"error":   err.Error(),
+		})
+	}
+	filter := models.UserFilter{CreatedFrom: createdFrom, CreatedTo: createdTo}
+
+	// Filtered users are listed oldest first
+	if !filter.IsEmpty() && !sort.IsDefault() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid sort parameters",
+			"error":   "sort_by cannot be combined with created_from or created_to",
+		})
+	}
+
+	h.tracer.SetAttributes(ctx,
+		attribute.String("sort_by", sort.Field),
+		attribute.String("order", string(sort.Order)),
+		attribute.String("created_from", c.Query("created_from")),
+		attribute.String("created_to", c.Query("created_to")),
+	)
+
 	// Get users
-	users, totalCount, err := h.userService.GetAllUsers(ctx, page, pageSize)
+	var users []models.UserResponse
+	var totalCount int
+	var warnings []models.Warning
+	if filter.IsEmpty() {
+		users, totalCount, warnings, err = h.userService.GetAllUsersWithWarnings(ctx, page, pageSize, sort)
+	} else {
+		users, totalCount, err = h.userService.SearchUsers(ctx, filter, page, pageSize)
+	}
 	if err != nil {
 		h.tracer.RecordError(ctx, err)
 
-		log.Error().Err(err).
+		errorLog(err).Err(err).
 			Int("page", page).
 			Int("page_size", pageSize).
 			Msg("Failed to get users")
 
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to get users",
 			"error":   err.Error(),
@@ -64,7 +113,7 @@ func (h *UserHandler) GetUsers(c *fiber.Ctx) error {
 		attribute.Int("total_pages", totalPages),
 	)
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+	response := fiber.Map{
 		"success": true,
 		"data": fiber.Map{
 			"users":        users,
@@ -75,7 +124,14 @@ func (h *UserHandler) GetUsers(c *fiber.Ctx) error {
 			"has_next":     hasNextPage,
 			"has_previous": hasPrevPage,
 		},
-	})
+	}
+
+	// Users listed without all their roles
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+
+	return respond(c, fiber.StatusOK, response)
 }
 
 // GetUser retrieves a user by ID
@@ -101,18 +157,18 @@ func (h *UserHandler) GetUser(c *fiber.Ctx) error {
 	if err != nil {
 		h.tracer.RecordError(ctx, err)
 
-		log.Error().Err(err).
+		errorLog(err).Err(err).
 			Str("user_id", id).
 			Msg("Failed to get user")
 
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		return c.Status(errorStatus(err, fiber.StatusNotFound)).JSON(fiber.Map{
 			"success": false,
-			"message": "User not found",
+			"message": errorMessage(err, "User not found"),
 			"error":   err.Error(),
 		})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+	return respond(c, fiber.StatusOK, fiber.Map{
 		"success": true,
 		"data":    user,
 	})
@@ -137,26 +193,31 @@ func (h *UserHandler) GetMe(c *fiber.Ctx) error {
 	if err != nil {
 		h.tracer.RecordError(ctx, err)
 
-		log.Error().Err(err).
+		errorLog(err).Err(err).
 			Str("user_id", userID).
 			Msg("Failed to get current user")
 
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to get user information",
 			"error":   err.Error(),
 		})
 	}
 
-	// Get user permissions
-	permissions, err := h.userService.GetUserPermissions(ctx, userID)
+	// Get user permissions, grouped by resource if requested
+	var permissions interface{}
+	if c.QueryBool("grouped") {
+		permissions, err = h.userService.GetUserPermissionsGrouped(ctx, userID)
+	} else {
+		permissions, err = h.userService.GetUserPermissions(ctx, userID)
+	}
 	if err != nil {
 		log.Warn().Err(err).
 			Str("user_id", userID).
 			Msg("Failed to get user permissions")
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+	return respond(c, fiber.StatusOK, fiber.Map{
 		"success": true,
 		"data": fiber.Map{
 			"user":        user,
@@ -194,16 +255,16 @@ func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 	}
 
 	// Create user
-	user, err := h.userService.CreateUser(ctx, request)
+	user, err := h.userService.CreateUser(withActor(ctx, c), request)
 	if err != nil {
 		h.tracer.RecordError(ctx, err)
 
 		log.Error().Err(err).
-			Str("username", request.Username).
-			Str("email", request.Email).
+			Str("username", logger.MaskUsername(request.Username)).
+			Str("email", logger.MaskEmail(request.Email)).
 			Msg("Failed to create user")
 
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to create user",
 			"error":   err.Error(),
@@ -214,11 +275,11 @@ func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 	adminID, _ := c.Locals("userID").(string)
 	log.Info().
 		Str("admin_id", adminID).
-		Str("username", request.Username).
+		Str("username", logger.MaskUsername(request.Username)).
 		Str("user_id", user.ID.String()).
 		Msg("User created successfully")
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+	return respondCreated(c, "/api/v1/users/"+user.ID.String(), fiber.Map{
 		"success": true,
 		"data":    user,
 	})
@@ -253,7 +314,7 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 	)
 
 	// Update user
-	user, err := h.userService.UpdateUser(ctx, id, request)
+	user, err := h.userService.UpdateUser(withActor(ctx, c), id, request)
 	if err != nil {
 		h.tracer.RecordError(ctx, err)
 
@@ -261,9 +322,14 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 			Str("user_id", id).
 			Msg("Failed to update user")
 
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		status := fiber.StatusBadRequest
+		if errors.Is(err, services.ErrLastAdmin) {
+			status = fiber.StatusConflict
+		}
+
+		return c.Status(errorStatus(err, status)).JSON(fiber.Map{
 			"success": false,
-			"message": "Failed to update user",
+			"message": errorMessage(err, "Failed to update user"),
 			"error":   err.Error(),
 		})
 	}
@@ -281,6 +347,278 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 	})
 }
 
+// TransferRoles assigns a user's roles to another user, optionally removing them from the source
+func (h *UserHandler) TransferRoles(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "UserHandler.TransferRoles")
+	defer span.End()
+
+	sourceID := c.Params("id")
+	targetID := c.Params("targetId")
+
+	// The body is optional; an empty one copies the roles
+	var request models.RoleTransferRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid request",
+				"error":   err.Error(),
+			})
+		}
+	}
+
+	h.tracer.SetAttributes(ctx,
+		attribute.String("source_user_id", sourceID),
+		attribute.String("target_user_id", targetID),
+		attribute.String("mode", request.Mode),
+	)
+
+	result, err := h.userService.TransferRoles(withActor(ctx, c), sourceID, targetID, request)
+	if err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		log.Error().Err(err).
+			Str("source_user_id", sourceID).
+			Str("target_user_id", targetID).
+			Msg("Failed to transfer roles")
+
+		status := fiber.StatusBadRequest
+		switch {
+		case errors.Is(err, services.ErrUserNotFound):
+			status = fiber.StatusNotFound
+		case errors.Is(err, services.ErrLastAdmin):
+			status = fiber.StatusConflict
+		}
+
+		return c.Status(errorStatus(err, status)).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to transfer roles",
+			"error":   err.Error(),
+		})
+	}
+
+	// Log activity
+	adminID, _ := c.Locals("userID").(string)
+	log.Info().
+		Str("admin_id", adminID).
+		Str("source_user_id", sourceID).
+		Str("target_user_id", targetID).
+		Str("mode", result.Mode).
+		Strs("transferred_roles", result.TransferredRoles).
+		Bool("source_deactivated", result.SourceDeactivated).
+		Msg("Roles transferred successfully")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// GetUserPolicy retrieves a user's complete access as one policy document
+func (h *UserHandler) GetUserPolicy(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "UserHandler.GetUserPolicy")
+	defer span.End()
+
+	id := c.Params("id")
+
+	h.tracer.SetAttributes(ctx,
+		attribute.String("user_id", id),
+	)
+
+	policy, err := h.userService.GetUserPolicy(ctx, id)
+	if err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		errorLog(err).Err(err).
+			Str("user_id", id).
+			Msg("Failed to get user policy")
+
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, services.ErrUserNotFound) {
+			status = fiber.StatusNotFound
+		}
+
+		return c.Status(errorStatus(err, status)).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get user policy",
+			"error":   err.Error(),
+		})
+	}
+
+	return respond(c, fiber.StatusOK, fiber.Map{
+		"success": true,
+		"data":    policy,
+	})
+}
+
+// MergeUsers merges the duplicate source user into the target user
+func (h *UserHandler) MergeUsers(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "UserHandler.MergeUsers")
+	defer span.End()
+
+	targetID := c.Params("id")
+	sourceID := c.Params("sourceId")
+	adminID, _ := c.Locals("userID").(string)
+
+	h.tracer.SetAttributes(ctx,
+		attribute.String("target_user_id", targetID),
+		attribute.String("source_user_id", sourceID),
+	)
+
+	result, err := h.userService.MergeUsers(withActor(ctx, c), adminID, targetID, sourceID)
+	if err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		log.Error().Err(err).
+			Str("target_user_id", targetID).
+			Str("source_user_id", sourceID).
+			Msg("Failed to merge users")
+
+		status := fiber.StatusBadRequest
+		switch {
+		case errors.Is(err, services.ErrUserNotFound):
+			status = fiber.StatusNotFound
+		case errors.Is(err, services.ErrLastAdmin):
+			status = fiber.StatusConflict
+		}
+
+		return c.Status(errorStatus(err, status)).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to merge users",
+			"error":   err.Error(),
+		})
+	}
+
+	// Log activity
+	log.Info().
+		Str("admin_id", adminID).
+		Str("target_user_id", targetID).
+		Str("source_user_id", sourceID).
+		Strs("merged_roles", result.MergedRoles).
+		Int("api_keys_reassigned", result.APIKeysReassigned).
+		Msg("Users merged successfully")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// BulkDeactivateUsers deactivates every user matching a filter, or counts them on a dry run
+func (h *UserHandler) BulkDeactivateUsers(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "UserHandler.BulkDeactivateUsers")
+	defer span.End()
+
+	var request models.BulkDeactivateRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request",
+			"error":   err.Error(),
+		})
+	}
+
+	h.tracer.SetAttributes(ctx, attribute.Bool("dry_run", request.DryRun))
+
+	adminID, _ := c.Locals("userID").(string)
+	result, err := h.userService.BulkDeactivateUsers(ctx, adminID, request)
+	if err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		log.Error().Err(err).
+			Str("admin_id", adminID).
+			Msg("Failed to bulk deactivate users")
+
+		status := fiber.StatusBadRequest
+		if errors.Is(err, services.ErrLastAdmin) {
+			status = fiber.StatusConflict
+		}
+
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to deactivate users",
+			"error":   err.Error(),
+		})
+	}
+
+	// Log activity
+	log.Info().
+		Str("admin_id", adminID).
+		Bool("dry_run", result.DryRun).
+		Int("matched", result.Matched).
+		Int("deactivated", result.Deactivated).
+		Msg("Bulk deactivation completed")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// ImportUsers creates users from an uploaded CSV, given as the file field of a multipart form or as
+// the request body, and reports every row. dry_run only validates the CSV.
+func (h *UserHandler) ImportUsers(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "UserHandler.ImportUsers")
+	defer span.End()
+
+	var data io.Reader = bytes.NewReader(c.Body())
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "CSV file is required in the file field",
+				"error":   err.Error(),
+			})
+		}
+		file, err := header.Open()
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid request",
+				"error":   err.Error(),
+			})
+		}
+		defer file.Close()
+		data = file
+	}
+
+	dryRun := c.QueryBool("dry_run")
+	h.tracer.SetAttributes(ctx, attribute.Bool("dry_run", dryRun))
+
+	adminID, _ := c.Locals("userID").(string)
+	result, err := h.userService.ImportUsers(withActor(ctx, c), data, dryRun)
+	if err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		log.Error().Err(err).
+			Str("admin_id", adminID).
+			Msg("Failed to import users")
+
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to import users",
+			"error":   err.Error(),
+		})
+	}
+
+	// Log activity
+	log.Info().
+		Str("admin_id", adminID).
+		Bool("dry_run", result.DryRun).
+		Int("total", result.Total).
+		Int("created", result.Created).
+		Int("duplicates", result.Duplicates).
+		Int("invalid", result.Invalid).
+		Int("failed", result.Failed).
+		Msg("User import completed")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    result,
+	})
+}
+
 // DeleteUser deletes a user
 func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 	ctx, span := h.tracer.StartSpan(c.Context(), "UserHandler.DeleteUser")
@@ -308,9 +646,9 @@ func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 			Str("user_id", id).
 			Msg("User not found for deletion")
 
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		return c.Status(errorStatus(err, fiber.StatusNotFound)).JSON(fiber.Map{
 			"success": false,
-			"message": "User not found",
+			"message": errorMessage(err, "User not found"),
 			"error":   err.Error(),
 		})
 	}
@@ -324,9 +662,14 @@ func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 			Str("user_id", id).
 			Msg("Failed to delete user")
 
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, services.ErrLastAdmin) {
+			status = fiber.StatusConflict
+		}
+
+		return c.Status(errorStatus(err, status)).JSON(fiber.Map{
 			"success": false,
-			"message": "Failed to delete user",
+			"message": errorMessage(err, "Failed to delete user"),
 			"error":   err.Error(),
 		})
 	}
@@ -336,7 +679,7 @@ func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 	log.Info().
 		Str("admin_id", adminID).
 		Str("user_id", id).
-		Str("username", user.Username).
+		Str("username", logger.MaskUsername(user.Username)).
 		Msg("User deleted successfully")
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
@@ -345,6 +688,167 @@ func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 	})
 }
 
+// PurgeUser permanently deletes a user, soft-deleted or not, with its role assignments and API keys
+func (h *UserHandler) PurgeUser(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "UserHandler.PurgeUser")
+	defer span.End()
+
+	// Get user ID from path
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "User ID is required",
+		})
+	}
+
+	h.tracer.SetAttributes(ctx,
+		attribute.String("user_id", id),
+	)
+
+	if err := h.userService.PurgeUser(ctx, id); err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		log.Error().Err(err).
+			Str("user_id", id).
+			Msg("Failed to purge user")
+
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, services.ErrLastAdmin) {
+			status = fiber.StatusConflict
+		}
+
+		return c.Status(errorStatus(err, status)).JSON(fiber.Map{
+			"success": false,
+			"message": errorMessage(err, "Failed to purge user"),
+			"error":   err.Error(),
+		})
+	}
+
+	// Log activity
+	adminID, _ := c.Locals("userID").(string)
+	log.Info().
+		Str("admin_id", adminID).
+		Str("user_id", id).
+		Msg("User purged successfully")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "User purged successfully",
+	})
+}
+
+// RevokeUserTokens revokes every token issued to a user so far
+func (h *UserHandler) RevokeUserTokens(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "UserHandler.RevokeUserTokens")
+	defer span.End()
+
+	// Get user ID from path
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "User ID is required",
+		})
+	}
+
+	h.tracer.SetAttributes(ctx,
+		attribute.String("user_id", id),
+	)
+
+	revokedAt, err := h.userService.RevokeUserTokens(ctx, id)
+	if err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		errorLog(err).Err(err).
+			Str("user_id", id).
+			Msg("Failed to revoke user tokens")
+
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, services.ErrUserNotFound) {
+			status = fiber.StatusNotFound
+		}
+
+		return c.Status(errorStatus(err, status)).JSON(fiber.Map{
+			"success": false,
+			"message": errorMessage(err, "Failed to revoke user tokens"),
+			"error":   err.Error(),
+		})
+	}
+
+	// Log activity
+	callerID, _ := c.Locals("userID").(string)
+	log.Info().
+		Str("caller_id", callerID).
+		Str("user_id", id).
+		Msg("User tokens revoked")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "User tokens revoked successfully",
+		"data": fiber.Map{
+			"user_id":    id,
+			"revoked_at": revokedAt,
+		},
+	})
+}
+
+// RestoreUser reactivates a soft-deleted user with its prior roles, or the configured restore roles
+func (h *UserHandler) RestoreUser(c *fiber.Ctx) error {
+	ctx, span := h.tracer.StartSpan(c.Context(), "UserHandler.RestoreUser")
+	defer span.End()
+
+	// Get user ID from path
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "User ID is required",
+		})
+	}
+
+	h.tracer.SetAttributes(ctx,
+		attribute.String("user_id", id),
+	)
+
+	user, err := h.userService.RestoreUser(withActor(ctx, c), id)
+	if err != nil {
+		h.tracer.RecordError(ctx, err)
+
+		errorLog(err).Err(err).
+			Str("user_id", id).
+			Msg("Failed to restore user")
+
+		status := fiber.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrUserNotFound):
+			status = fiber.StatusNotFound
+		case errors.Is(err, services.ErrUserNotDeleted):
+			status = fiber.StatusConflict
+		}
+
+		return c.Status(errorStatus(err, status)).JSON(fiber.Map{
+			"success": false,
+			"message": errorMessage(err, "Failed to restore user"),
+			"error":   err.Error(),
+		})
+	}
+
+	// Log activity
+	adminID, _ := c.Locals("userID").(string)
+	log.Info().
+		Str("admin_id", adminID).
+		Str("user_id", id).
+		Str("username", logger.MaskUsername(user.Username)).
+		Msg("User restored successfully")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "User restored successfully",
+		"data":    user,
+	})
+}
+
 // GetUserPermissions retrieves permissions for a user
 func (h *UserHandler) GetUserPermissions(c *fiber.Ctx) error {
 	ctx, span := h.tracer.StartSpan(c.Context(), "UserHandler.GetUserPermissions")
@@ -368,34 +872,39 @@ func (h *UserHandler) GetUserPermissions(c *fiber.Ctx) error {
 	if err != nil {
 		h.tracer.RecordError(ctx, err)
 
-		log.Error().Err(err).
+		errorLog(err).Err(err).
 			Str("user_id", id).
 			Msg("User not found for permissions lookup")
 
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		return c.Status(errorStatus(err, fiber.StatusNotFound)).JSON(fiber.Map{
 			"success": false,
 			"message": "User not found",
 			"error":   err.Error(),
 		})
 	}
 
-	// Get user permissions
-	permissions, err := h.userService.GetUserPermissions(ctx, id)
+	// Get user permissions, grouped by resource if requested
+	var permissions interface{}
+	if c.QueryBool("grouped") {
+		permissions, err = h.userService.GetUserPermissionsGrouped(ctx, id)
+	} else {
+		permissions, err = h.userService.GetUserPermissions(ctx, id)
+	}
 	if err != nil {
 		h.tracer.RecordError(ctx, err)
 
-		log.Error().Err(err).
+		errorLog(err).Err(err).
 			Str("user_id", id).
 			Msg("Failed to get user permissions")
 
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to get user permissions",
 			"error":   err.Error(),
 		})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+	return respond(c, fiber.StatusOK, fiber.Map{
 		"success": true,
 		"data":    permissions,
 	})
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/mocks"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/repositories/transaction"
+	"github.com/chats/go-user-api/internal/services"
+	"github.com/chats/go-user-api/internal/tracing"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserHandler_GetUsersCreatedRange(t *testing.T) {
+	tracer, err := tracing.NewTracer(&config.Config{JaegerEndpoint: "http://localhost:14268/api/traces"})
+	require.NoError(t, err)
+
+	newApp := func(userRepo *mocks.MockUserRepository) *fiber.App {
+		userService := services.NewUserService(userRepo, new(mocks.MockRoleRepository), new(mocks.Manager[transaction.Repository]), services.DefaultUserServiceOptions())
+
+		app := fiber.New()
+		app.Get("/users", NewUserHandler(userService, tracer).GetUsers)
+		return app
+	}
+
+	get := func(t *testing.T, app *fiber.App, target string) (int, map[string]interface{}) {
+		t.Helper()
+
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, target, nil))
+		require.NoError(t, err)
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp.StatusCode, body
+	}
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 31, 23, 59, 59, 999999999, time.UTC)
+	rangeFilter := models.UserFilter{CreatedFrom: &from, CreatedTo: &to}
+
+	t.Run("Range captures a subset", func(t *testing.T) {
+		inRange := []*models.User{
+			{ID: uuid.New(), Username: "alice", CreatedAt: from.AddDate(0, 0, 3)},
+			{ID: uuid.New(), Username: "bob", CreatedAt: from.AddDate(0, 0, 20)},
+		}
+		ids := []uuid.UUID{inRange[0].ID, inRange[1].ID}
+
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetUserIDsByFilter", mock.Anything, rangeFilter).Return(ids, nil)
+		mockUserRepo.On("GetByIDs", mock.Anything, ids).Return(inRange, nil)
+
+		status, body := get(t, newApp(mockUserRepo), "/users?created_from=2024-01-01&created_to=2024-01-31")
+
+		assert.Equal(t, fiber.StatusOK, status)
+		data := body["data"].(map[string]interface{})
+		assert.Equal(t, float64(2), data["total_count"])
+		users := data["users"].([]interface{})
+		require.Len(t, users, 2)
+		assert.Equal(t, "alice", users[0].(map[string]interface{})["username"])
+		mockUserRepo.AssertNotCalled(t, "GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Empty range", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetUserIDsByFilter", mock.Anything, rangeFilter).Return([]uuid.UUID{}, nil)
+
+		status, body := get(t, newApp(mockUserRepo), "/users?created_from=2024-01-01&created_to=2024-01-31")
+
+		assert.Equal(t, fiber.StatusOK, status)
+		data := body["data"].(map[string]interface{})
+		assert.Equal(t, float64(0), data["total_count"])
+		assert.Empty(t, data["users"])
+		mockUserRepo.AssertNotCalled(t, "GetByIDs", mock.Anything, mock.Anything)
+	})
+
+	t.Run("From after to is rejected", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+
+		status, body := get(t, newApp(mockUserRepo), "/users?created_from=2024-02-01&created_to=2024-01-01")
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+		assert.Contains(t, body["error"], "created_from must not be after created_to")
+		mockUserRepo.AssertNotCalled(t, "GetUserIDsByFilter", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Invalid date format is rejected", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+
+		status, _ := get(t, newApp(mockUserRepo), "/users?created_to=yesterday")
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+	})
+}
+
+func TestUserHandler_GetUsersContextEnded(t *testing.T) {
+	tracer, err := tracing.NewTracer(&config.Config{JaegerEndpoint: "http://localhost:14268/api/traces"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		repoErr error
+		want    int
+	}{
+		{"Client canceled", context.Canceled, StatusClientClosedRequest},
+		{"Deadline passed", context.DeadlineExceeded, fiber.StatusGatewayTimeout},
+		{"Other error", errors.New("db down"), fiber.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserRepo := new(mocks.MockUserRepository)
+			mockUserRepo.On("GetAll", mock.Anything, 10, 0, models.SortOptions{}).Return([]*models.User(nil), fmt.Errorf("failed to get users: %w", tt.repoErr))
+			userService := services.NewUserService(mockUserRepo, new(mocks.MockRoleRepository), new(mocks.Manager[transaction.Repository]), services.DefaultUserServiceOptions())
+
+			app := fiber.New()
+			app.Get("/users", NewUserHandler(userService, tracer).GetUsers)
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/users", nil))
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/logger"
+	"github.com/gofiber/contrib/fiberzerolog"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+)
+
+// AccessLogMiddleware logs every request to accessLog. Request headers and JSON bodies are only
+// logged when configured, and the values of the ACCESS_LOG_REDACT_HEADERS headers and of the
+// ACCESS_LOG_REDACT_FIELDS body fields and query parameters are always replaced, so bearer tokens
+// and passwords never reach the logs.
+func AccessLogMiddleware(cfg *config.Config, accessLog *zerolog.Logger) fiber.Handler {
+	redactor := logger.NewRedactor(cfg.GetAccessLogRedactHeaders(), cfg.GetAccessLogRedactFields())
+
+	return fiberzerolog.New(fiberzerolog.Config{
+		Logger: accessLog,
+		// The URL, headers and bodies are added redacted by GetLogger instead
+		Fields: []string{
+			fiberzerolog.FieldIP,
+			fiberzerolog.FieldLatency,
+			fiberzerolog.FieldStatus,
+			fiberzerolog.FieldMethod,
+			fiberzerolog.FieldError,
+		},
+		// Called once the request has been handled, so the response is available
+		GetLogger: func(c *fiber.Ctx) zerolog.Logger {
+			zc := accessLog.With().Str(fiberzerolog.FieldURL, redactedURL(c, redactor))
+
+			if cfg.AccessLogHeaders {
+				headers := zerolog.Dict()
+				c.Request().Header.VisitAll(func(key, value []byte) {
+					headers.Str(string(key), redactor.Header(string(key), string(value)))
+				})
+				zc = zc.Dict(fiberzerolog.FieldReqHeaders, headers)
+			}
+
+			if cfg.AccessLogBodies {
+				if body, ok := redactedBody(c.Get(fiber.HeaderContentType), "", c.Body(), redactor); ok {
+					zc = zc.RawJSON(fiberzerolog.FieldBody, body)
+				}
+				resp := c.Response()
+				if body, ok := redactedBody(string(resp.Header.ContentType()), string(resp.Header.Peek(fiber.HeaderContentEncoding)), resp.Body(), redactor); ok {
+					zc = zc.RawJSON(fiberzerolog.FieldResBody, body)
+				}
+			}
+
+			return zc.Logger()
+		},
+	})
+}
+
+// redactedURL returns the original path and query with sensitive query parameters redacted
+func redactedURL(c *fiber.Ctx, redactor *logger.Redactor) string {
+	path, query, found := strings.Cut(c.OriginalURL(), "?")
+	if !found {
+		return path
+	}
+	return path + "?" + redactor.Query(query)
+}
+
+// redactedBody returns a JSON body with sensitive fields redacted. Empty, encoded and non-JSON
+// bodies are not logged, since they cannot be inspected.
+func redactedBody(contentType, contentEncoding string, body []byte, redactor *logger.Redactor) ([]byte, bool) {
+	if len(body) == 0 || contentEncoding != "" || !strings.HasPrefix(contentType, fiber.MIMEApplicationJSON) {
+		return nil, false
+	}
+	return redactor.JSON(body)
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chats/go-user-api/config"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// accessLogLine sends a change-password request carrying a bearer token through the middleware
+// and returns the raw access log line and its decoded fields
+func accessLogLine(t *testing.T, cfg *config.Config) (string, map[string]interface{}) {
+	t.Helper()
+
+	var out bytes.Buffer
+	accessLog := zerolog.New(&out)
+
+	app := fiber.New()
+	app.Use(AccessLogMiddleware(cfg, &accessLog))
+	app.Post("/api/v1/auth/change-password", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"access_token": "issued-jwt", "token_type": "Bearer"}})
+	})
+
+	body := `{"current_password":"old-secret-pw","new_password":"new-secret-pw","profile":{"password":"nested-pw"}}`
+	req := httptest.NewRequest(fiber.MethodPost, "/api/v1/auth/change-password?token=query-secret&lang=en", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer header-jwt")
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	line := out.String()
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(line), &fields))
+	return line, fields
+}
+
+func TestAccessLogMiddleware(t *testing.T) {
+	secrets := []string{"header-jwt", "old-secret-pw", "new-secret-pw", "nested-pw", "query-secret", "issued-jwt"}
+
+	cfg := &config.Config{
+		AccessLogHeaders:       true,
+		AccessLogBodies:        true,
+		AccessLogRedactHeaders: "Authorization,X-API-Key",
+		AccessLogRedactFields:  "password,current_password,new_password,access_token,token",
+	}
+
+	t.Run("Sensitive values are redacted", func(t *testing.T) {
+		line, fields := accessLogLine(t, cfg)
+
+		for _, secret := range secrets {
+			assert.NotContains(t, line, secret)
+		}
+
+		headers := fields["reqHeaders"].(map[string]interface{})
+		assert.Equal(t, config.RedactedValue, headers["Authorization"])
+		assert.Equal(t, fiber.MIMEApplicationJSON, headers["Content-Type"])
+
+		body := fields["body"].(map[string]interface{})
+		assert.Equal(t, config.RedactedValue, body["current_password"])
+		assert.Equal(t, config.RedactedValue, body["new_password"])
+		assert.Equal(t, config.RedactedValue, body["profile"].(map[string]interface{})["password"])
+
+		resBody := fields["resBody"].(map[string]interface{})
+		assert.Equal(t, config.RedactedValue, resBody["data"].(map[string]interface{})["access_token"])
+		assert.Equal(t, "Bearer", resBody["data"].(map[string]interface{})["token_type"])
+
+		assert.Equal(t, "/api/v1/auth/change-password?token=[REDACTED]&lang=en", fields["url"])
+		assert.Equal(t, float64(fiber.StatusOK), fields["status"])
+	})
+
+	t.Run("Headers and bodies are not logged by default", func(t *testing.T) {
+		line, fields := accessLogLine(t, &config.Config{AccessLogRedactFields: cfg.AccessLogRedactFields})
+
+		for _, secret := range secrets {
+			assert.NotContains(t, line, secret)
+		}
+		assert.NotContains(t, fields, "reqHeaders")
+		assert.NotContains(t, fields, "body")
+		assert.NotContains(t, fields, "resBody")
+	})
+}
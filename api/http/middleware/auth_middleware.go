@@ -1,10 +1,15 @@
 package middleware
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/logger"
+	"github.com/chats/go-user-api/internal/services"
 	"github.com/chats/go-user-api/internal/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -33,7 +38,7 @@ func JWTAuthMiddleware(cfg *config.Config) fiber.Handler {
 		}
 
 		// Parse and verify token
-		claims, err := utils.ParseJWT(tokenString, cfg)
+		claims, err := utils.ParseJWT(tokenString, utils.TokenTypeAccess, cfg)
 		if err != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"success": false,
@@ -45,6 +50,14 @@ func JWTAuthMiddleware(cfg *config.Config) fiber.Handler {
 		c.Locals("userID", claims.UserID)
 		c.Locals("username", claims.Username)
 		c.Locals("roles", claims.Roles)
+		c.Locals("rolesOmitted", claims.RolesOmitted)
+		if claims.IssuedAt != nil {
+			c.Locals("tokenIssuedAt", claims.IssuedAt.Time)
+		}
+		c.Locals("tokenScope", claims.Scope)
+		if claims.AuthTime != nil {
+			c.Locals("authTime", claims.AuthTime.Time)
+		}
 
 		// Generate request ID if not exists
 		requestID := c.Get("X-Request-ID")
@@ -56,7 +69,7 @@ func JWTAuthMiddleware(cfg *config.Config) fiber.Handler {
 
 		log.Debug().
 			Str("user_id", claims.UserID).
-			Str("username", claims.Username).
+			Str("username", logger.MaskUsername(claims.Username)).
 			Strs("roles", claims.Roles).
 			Str("request_id", requestID).
 			Str("path", c.Path()).
@@ -67,6 +80,244 @@ func JWTAuthMiddleware(cfg *config.Config) fiber.Handler {
 	}
 }
 
+// APIKeyHeader is the request header carrying an API key
+const APIKeyHeader = "X-API-Key"
+
+// JWTOrAPIKeyAuthMiddleware creates a middleware that accepts either an API key or a Bearer JWT
+func JWTOrAPIKeyAuthMiddleware(cfg *config.Config, apiKeyService *services.APIKeyService) fiber.Handler {
+	jwtAuth := JWTAuthMiddleware(cfg)
+
+	return func(c *fiber.Ctx) error {
+		rawKey := c.Get(APIKeyHeader)
+		if rawKey == "" {
+			return jwtAuth(c)
+		}
+
+		// Resolve the API key to its scoped permissions
+		key, err := apiKeyService.Authenticate(c.Context(), rawKey)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"success": false,
+				"message": err.Error(),
+			})
+		}
+
+		c.Locals("apiKey", key)
+
+		log.Debug().
+			Str("api_key_id", key.ID.String()).
+			Str("api_key_name", key.Name).
+			Str("path", c.Path()).
+			Str("method", c.Method()).
+			Msg("API key authenticated")
+
+		return c.Next()
+	}
+}
+
+// RejectRevokedTokenMiddleware rejects tokens issued before the user's tokens were last revoked
+func RejectRevokedTokenMiddleware(authService *services.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// API keys carry no token
+		userID, ok := c.Locals("userID").(string)
+		issuedAt, hasIssuedAt := c.Locals("tokenIssuedAt").(time.Time)
+		if !ok || !hasIssuedAt {
+			return c.Next()
+		}
+
+		revoked, err := authService.IsTokenRevoked(c.Context(), userID, issuedAt)
+		if err != nil {
+			log.Error().Err(err).
+				Str("user_id", userID).
+				Msg("Failed to check token revocation")
+
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to verify token",
+			})
+		}
+
+		if revoked {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"success": false,
+				"message": "Token has been revoked",
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// RejectStaleTokenMiddleware rejects tokens issued before the user's roles last changed when enabled,
+// so clients must call /auth/reissue to pick up their current roles
+func RejectStaleTokenMiddleware(authService *services.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !authService.RejectsStaleTokens() {
+			return c.Next()
+		}
+
+		// API keys carry no token
+		userID, ok := c.Locals("userID").(string)
+		issuedAt, hasIssuedAt := c.Locals("tokenIssuedAt").(time.Time)
+		if !ok || !hasIssuedAt {
+			return c.Next()
+		}
+
+		stale, err := authService.IsTokenStale(c.Context(), userID, issuedAt)
+		if err != nil {
+			log.Error().Err(err).
+				Str("user_id", userID).
+				Msg("Failed to check token freshness")
+
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to verify token",
+			})
+		}
+
+		if stale {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"success":     false,
+				"message":     "Token was issued before your roles changed, reissue it",
+				"token_stale": true,
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// ResolveOmittedRolesMiddleware loads the user's roles for a token issued without them because the
+// user had more than JWT_MAX_ROLES, so role checks further down see the same roles a full token carries
+func ResolveOmittedRolesMiddleware(authService *services.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if omitted, _ := c.Locals("rolesOmitted").(bool); !omitted {
+			return c.Next()
+		}
+
+		userID, _ := c.Locals("userID").(string)
+		roles, err := authService.GetUserRoleNames(c.Context(), userID)
+		if err != nil {
+			log.Error().Err(err).
+				Str("user_id", userID).
+				Msg("Failed to look up roles omitted from token")
+
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to verify token",
+			})
+		}
+
+		c.Locals("roles", roles)
+
+		return c.Next()
+	}
+}
+
+// CurrentPasswordHeader carries the user's password to re-authenticate for a single sensitive action
+const CurrentPasswordHeader = "X-Current-Password"
+
+// ReauthMiddleware requires the user to have entered their password within the re-authentication
+// window for action when REAUTH_ACTIONS lists it: the token must come from a recent login or step-up,
+// or the request must carry the current password. applies limits the check to some requests of the
+// route, nil checks all of them. API keys are not asked to re-authenticate.
+func ReauthMiddleware(authService *services.AuthService, action string, applies func(c *fiber.Ctx) bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !authService.RequiresReauth(action) || (applies != nil && !applies(c)) {
+			return c.Next()
+		}
+
+		// API keys carry no token
+		userID, ok := c.Locals("userID").(string)
+		if !ok {
+			return c.Next()
+		}
+
+		if authTime, ok := c.Locals("authTime").(time.Time); ok && time.Since(authTime) <= authService.ReauthMaxAge() {
+			return c.Next()
+		}
+
+		password := c.Get(CurrentPasswordHeader)
+		if password == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"success":         false,
+				"message":         "Re-authenticate to continue: step up or send the current password",
+				"reauth_required": true,
+			})
+		}
+
+		if err := authService.VerifyCurrentPassword(c.Context(), userID, password); err != nil {
+			if errors.Is(err, services.ErrReauthFailed) {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"success":         false,
+					"message":         "Current password is incorrect",
+					"reauth_required": true,
+				})
+			}
+			if errors.Is(err, services.ErrChallengeRequired) {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"success":            false,
+					"message":            "Too many failed attempts, log in again",
+					"challenge_required": true,
+				})
+			}
+
+			log.Error().Err(err).
+				Str("user_id", userID).
+				Msg("Failed to verify re-authentication")
+
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to verify re-authentication",
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// SetsOwnEmail reports whether a user update sets an email on the caller's own account, named by
+// the id path parameter
+func SetsOwnEmail(c *fiber.Ctx) bool {
+	userID, _ := c.Locals("userID").(string)
+	if userID == "" || !strings.EqualFold(userID, c.Params("id")) {
+		return false
+	}
+
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(c.Body(), &body); err != nil {
+		return false
+	}
+	return body.Email != ""
+}
+
+// PasswordChangeScopeMiddleware limits tokens issued for an expired password to the given paths,
+// so the user has to change the password before doing anything else. Paths compare the way lenient
+// routing matches them, ignoring case and a trailing slash.
+func PasswordChangeScopeMiddleware(allowedPaths ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		scope, _ := c.Locals("tokenScope").(string)
+		if scope != utils.ScopePasswordChange {
+			return c.Next()
+		}
+
+		requested := strings.TrimSuffix(c.Path(), "/")
+		for _, path := range allowedPaths {
+			if strings.EqualFold(requested, strings.TrimSuffix(path, "/")) {
+				return c.Next()
+			}
+		}
+
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success":              false,
+			"message":              "Password has expired, change it to continue",
+			"must_change_password": true,
+		})
+	}
+}
+
 // HasRoleMiddleware creates a middleware that checks if user has at least one of the required roles
 func HasRoleMiddleware(allowedRoles ...string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -104,6 +355,19 @@ func HasRoleMiddleware(allowedRoles ...string) fiber.Handler {
 	}
 }
 
+// SelfOrRoleMiddleware lets the user named by the path parameter act on themselves, and otherwise
+// requires one of the roles
+func SelfOrRoleMiddleware(param string, allowedRoles ...string) fiber.Handler {
+	hasRole := HasRoleMiddleware(allowedRoles...)
+
+	return func(c *fiber.Ctx) error {
+		if userID, ok := c.Locals("userID").(string); ok && strings.EqualFold(userID, c.Params(param)) {
+			return c.Next()
+		}
+		return hasRole(c)
+	}
+}
+
 // AdminOnlyMiddleware creates a middleware that restricts access to admin users only
 func AdminOnlyMiddleware() fiber.Handler {
 	return HasRoleMiddleware("admin")
@@ -0,0 +1,367 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/mocks"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/services"
+	"github.com/chats/go-user-api/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRejectStaleTokenMiddleware(t *testing.T) {
+	userID := uuid.New()
+
+	call := func(t *testing.T, cfg *config.Config, changedAt time.Time) (int, *mocks.MockUserRepository) {
+		t.Helper()
+
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetRolesChangedAt", mock.Anything, userID).Return(changedAt, nil)
+		authService := services.NewAuthService(mockUserRepo, cfg)
+
+		app := fiber.New()
+		app.Get("/", JWTAuthMiddleware(cfg), RejectStaleTokenMiddleware(authService), func(c *fiber.Ctx) error {
+			return c.SendString("ok")
+		})
+
+		token, _, err := utils.GenerateJWT(userID, "johndoe", []string{"viewer"}, cfg)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+
+		return resp.StatusCode, mockUserRepo
+	}
+
+	t.Run("Stale token rejected when enabled", func(t *testing.T) {
+		cfg := &config.Config{JWTSecret: "test-secret-key", JWTExpireMinute: 60, TokenRejectStaleRoles: true}
+
+		status, _ := call(t, cfg, time.Now().Add(time.Minute))
+
+		assert.Equal(t, fiber.StatusUnauthorized, status)
+	})
+
+	t.Run("Fresh token accepted when enabled", func(t *testing.T) {
+		cfg := &config.Config{JWTSecret: "test-secret-key", JWTExpireMinute: 60, TokenRejectStaleRoles: true}
+
+		status, _ := call(t, cfg, time.Now().Add(-time.Hour))
+
+		assert.Equal(t, fiber.StatusOK, status)
+	})
+
+	t.Run("Stale token accepted when disabled", func(t *testing.T) {
+		cfg := &config.Config{JWTSecret: "test-secret-key", JWTExpireMinute: 60}
+
+		status, mockUserRepo := call(t, cfg, time.Now().Add(time.Minute))
+
+		assert.Equal(t, fiber.StatusOK, status)
+		mockUserRepo.AssertNotCalled(t, "GetRolesChangedAt", mock.Anything, mock.Anything)
+	})
+}
+
+func TestRejectRevokedTokenMiddleware(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "test-secret-key", JWTExpireMinute: 60}
+	userID := uuid.New()
+
+	call := func(t *testing.T, revokedAt time.Time) int {
+		t.Helper()
+
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetTokensRevokedAt", mock.Anything, userID).Return(revokedAt, nil)
+		authService := services.NewAuthService(mockUserRepo, cfg)
+
+		app := fiber.New()
+		app.Get("/", JWTAuthMiddleware(cfg), RejectRevokedTokenMiddleware(authService), func(c *fiber.Ctx) error {
+			return c.SendString("ok")
+		})
+
+		token, _, err := utils.GenerateJWT(userID, "johndoe", []string{"viewer"}, cfg)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+
+		return resp.StatusCode
+	}
+
+	t.Run("Token issued before the revocation rejected", func(t *testing.T) {
+		assert.Equal(t, fiber.StatusUnauthorized, call(t, time.Now().Add(time.Second)))
+	})
+
+	t.Run("Token issued after the revocation accepted", func(t *testing.T) {
+		assert.Equal(t, fiber.StatusOK, call(t, time.Now().Add(-time.Minute)))
+	})
+
+	t.Run("Token of a user never revoked accepted", func(t *testing.T) {
+		assert.Equal(t, fiber.StatusOK, call(t, time.Time{}))
+	})
+}
+
+func TestSelfOrRoleMiddleware(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "test-secret-key", JWTExpireMinute: 60}
+	userID := uuid.New()
+
+	app := fiber.New()
+	app.Post("/users/:id/revoke-tokens", JWTAuthMiddleware(cfg), SelfOrRoleMiddleware("id", "admin"), func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	call := func(t *testing.T, target string, roles []string) int {
+		t.Helper()
+
+		token, _, err := utils.GenerateJWT(userID, "johndoe", roles, cfg)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(fiber.MethodPost, "/users/"+target+"/revoke-tokens", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	t.Run("Self allowed", func(t *testing.T) {
+		assert.Equal(t, fiber.StatusOK, call(t, userID.String(), []string{"viewer"}))
+	})
+
+	t.Run("Admin allowed for another user", func(t *testing.T) {
+		assert.Equal(t, fiber.StatusOK, call(t, uuid.New().String(), []string{"admin"}))
+	})
+
+	t.Run("Other user forbidden", func(t *testing.T) {
+		assert.Equal(t, fiber.StatusForbidden, call(t, uuid.New().String(), []string{"viewer"}))
+	})
+}
+
+func TestResolveOmittedRolesMiddleware(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "test-secret-key", JWTExpireMinute: 60, JWTMaxRoles: 2}
+	userID := uuid.New()
+
+	call := func(t *testing.T, roles []string) (int, *mocks.MockUserRepository) {
+		t.Helper()
+
+		user := &models.User{ID: userID, Username: "johndoe"}
+		for _, role := range roles {
+			user.Roles = append(user.Roles, models.Role{ID: uuid.New(), Name: role})
+		}
+
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetByID", mock.Anything, userID).Return(user, nil)
+		authService := services.NewAuthService(mockUserRepo, cfg)
+
+		app := fiber.New()
+		app.Get("/", JWTAuthMiddleware(cfg), ResolveOmittedRolesMiddleware(authService), AdminOnlyMiddleware(), func(c *fiber.Ctx) error {
+			return c.SendString("ok")
+		})
+
+		token, _, err := utils.GenerateJWT(userID, "johndoe", roles, cfg)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+
+		return resp.StatusCode, mockUserRepo
+	}
+
+	t.Run("Roles under the cap embedded in the token", func(t *testing.T) {
+		token, _, err := utils.GenerateJWT(userID, "johndoe", []string{"admin", "viewer"}, cfg)
+		require.NoError(t, err)
+		claims, err := utils.ParseJWT(token, utils.TokenTypeAccess, cfg)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"admin", "viewer"}, claims.Roles)
+		assert.False(t, claims.RolesOmitted)
+
+		status, mockUserRepo := call(t, []string{"admin", "viewer"})
+
+		assert.Equal(t, fiber.StatusOK, status)
+		mockUserRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Roles over the cap omitted and looked up", func(t *testing.T) {
+		token, _, err := utils.GenerateJWT(userID, "johndoe", []string{"admin", "editor", "viewer"}, cfg)
+		require.NoError(t, err)
+		claims, err := utils.ParseJWT(token, utils.TokenTypeAccess, cfg)
+		require.NoError(t, err)
+		assert.Empty(t, claims.Roles)
+		assert.True(t, claims.RolesOmitted)
+
+		status, mockUserRepo := call(t, []string{"admin", "editor", "viewer"})
+
+		assert.Equal(t, fiber.StatusOK, status)
+		mockUserRepo.AssertCalled(t, "GetByID", mock.Anything, userID)
+	})
+}
+
+func TestReauthMiddleware(t *testing.T) {
+	userID := uuid.New()
+	user := &models.User{ID: userID, Username: "johndoe", IsActive: true}
+	require.NoError(t, user.HashPassword("password123"))
+
+	call := func(t *testing.T, actions string, authTime time.Time, password string) (*http.Response, *mocks.MockUserRepository) {
+		t.Helper()
+
+		cfg := &config.Config{JWTSecret: "test-secret-key", JWTExpireMinute: 60, ReauthActions: actions, ReauthMaxAgeMinutes: 15}
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetByID", mock.Anything, userID).Return(user, nil)
+		authService := services.NewAuthService(mockUserRepo, cfg)
+
+		app := fiber.New()
+		app.Delete("/users/:id", JWTAuthMiddleware(cfg), ReauthMiddleware(authService, config.ReauthDeleteUser, nil), func(c *fiber.Ctx) error {
+			return c.SendString("ok")
+		})
+
+		token, _, err := utils.GenerateScopedJWT(userID, "johndoe", []string{"admin"}, "", authTime, cfg)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(fiber.MethodDelete, "/users/"+uuid.NewString(), nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if password != "" {
+			req.Header.Set(CurrentPasswordHeader, password)
+		}
+
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+
+		return resp, mockUserRepo
+	}
+
+	t.Run("Blocked without step-up", func(t *testing.T) {
+		resp, _ := call(t, config.ReauthDeleteUser, time.Time{}, "")
+
+		assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, true, body["reauth_required"])
+	})
+
+	t.Run("Blocked when the authentication is too old", func(t *testing.T) {
+		resp, _ := call(t, config.ReauthDeleteUser, time.Now().Add(-time.Hour), "")
+
+		assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Allowed with a fresh step-up token", func(t *testing.T) {
+		resp, mockUserRepo := call(t, config.ReauthDeleteUser, time.Now(), "")
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		mockUserRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Allowed with the current password", func(t *testing.T) {
+		resp, _ := call(t, config.ReauthDeleteUser, time.Time{}, "password123")
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Wrong current password rejected", func(t *testing.T) {
+		resp, _ := call(t, config.ReauthDeleteUser, time.Time{}, "wrong-password")
+
+		assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Not required when the action is not listed", func(t *testing.T) {
+		resp, _ := call(t, config.ReauthCreateAPIKey, time.Time{}, "")
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	})
+}
+
+func TestPasswordChangeScopeMiddleware(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "test-secret-key", JWTExpireMinute: 60}
+	userID := uuid.New()
+
+	app := fiber.New()
+	protected := app.Group("", JWTAuthMiddleware(cfg), PasswordChangeScopeMiddleware("/auth/change-password"))
+	protected.Post("/auth/change-password", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	protected.Get("/users/me", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	call := func(t *testing.T, method, path, scope string) int {
+		t.Helper()
+
+		token, _, err := utils.GenerateScopedJWT(userID, "johndoe", []string{"viewer"}, scope, time.Time{}, cfg)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+
+		return resp.StatusCode
+	}
+
+	t.Run("Scoped token can change password", func(t *testing.T) {
+		assert.Equal(t, fiber.StatusOK, call(t, fiber.MethodPost, "/auth/change-password", utils.ScopePasswordChange))
+	})
+
+	t.Run("Scoped token matches the path as leniently as routing", func(t *testing.T) {
+		assert.Equal(t, fiber.StatusOK, call(t, fiber.MethodPost, "/Auth/Change-Password/", utils.ScopePasswordChange))
+	})
+
+	t.Run("Scoped token rejected elsewhere", func(t *testing.T) {
+		assert.Equal(t, fiber.StatusForbidden, call(t, fiber.MethodGet, "/users/me", utils.ScopePasswordChange))
+	})
+
+	t.Run("Unscoped token unrestricted", func(t *testing.T) {
+		assert.Equal(t, fiber.StatusOK, call(t, fiber.MethodGet, "/users/me", ""))
+	})
+}
+
+func TestJWTAuthMiddleware_TokenType(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "test-secret-key", JWTExpireMinute: 60, JWTRefreshExpireMinute: 120}
+	userID := uuid.New()
+
+	app := fiber.New()
+	app.Get("/users/me", JWTAuthMiddleware(cfg), func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	call := func(t *testing.T, token string) int {
+		t.Helper()
+
+		req := httptest.NewRequest(fiber.MethodGet, "/users/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	t.Run("Access token accepted", func(t *testing.T) {
+		token, _, err := utils.GenerateJWT(userID, "johndoe", []string{"viewer"}, cfg)
+		require.NoError(t, err)
+
+		assert.Equal(t, fiber.StatusOK, call(t, token))
+	})
+
+	t.Run("Refresh token rejected", func(t *testing.T) {
+		token, _, err := utils.GenerateRefreshJWT(userID, "johndoe", cfg)
+		require.NoError(t, err)
+
+		assert.Equal(t, fiber.StatusUnauthorized, call(t, token))
+	})
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/chats/go-user-api/config"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/compress"
+	"github.com/valyala/fasthttp"
+)
+
+// CompressionMiddleware compresses responses for clients that accept it, at the configured level,
+// but only bodies of at least COMPRESS_MIN_SIZE bytes with a content type in COMPRESS_CONTENT_TYPES.
+// Small bodies are not worth the overhead and binary ones are usually compressed already.
+func CompressionMiddleware(cfg *config.Config) fiber.Handler {
+	compressor := newCompressor(compress.Level(cfg.CompressLevel))
+	if compressor == nil {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+
+	minSize := cfg.CompressMinSize
+	contentTypes := cfg.GetCompressContentTypes()
+
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		resp := c.Response()
+		if resp.IsBodyStream() || len(resp.Body()) < minSize {
+			return nil
+		}
+		if !compressible(string(resp.Header.ContentType()), contentTypes) {
+			return nil
+		}
+
+		// Negotiates the encoding from Accept-Encoding and sets Content-Encoding and Vary
+		compressor(c.Context())
+		return nil
+	}
+}
+
+// newCompressor returns the fasthttp compressor for a level the way fiber's compress middleware
+// builds it, or nil when compression is disabled
+func newCompressor(level compress.Level) fasthttp.RequestHandler {
+	noop := func(*fasthttp.RequestCtx) {}
+
+	switch level {
+	case compress.LevelDefault:
+		return fasthttp.CompressHandlerBrotliLevel(noop, fasthttp.CompressBrotliDefaultCompression, fasthttp.CompressDefaultCompression)
+	case compress.LevelBestSpeed:
+		return fasthttp.CompressHandlerBrotliLevel(noop, fasthttp.CompressBrotliBestSpeed, fasthttp.CompressBestSpeed)
+	case compress.LevelBestCompression:
+		return fasthttp.CompressHandlerBrotliLevel(noop, fasthttp.CompressBrotliBestCompression, fasthttp.CompressBestCompression)
+	default:
+		return nil
+	}
+}
+
+// compressible reports whether contentType, ignoring parameters such as charset, is one of
+// contentTypes; an entry ending in "/" matches every subtype
+func compressible(contentType string, contentTypes []string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+	for _, allowed := range contentTypes {
+		if mediaType == allowed || (strings.HasSuffix(allowed, "/") && strings.HasPrefix(mediaType, allowed)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chats/go-user-api/config"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// compressedEncoding serves body with contentType through the middleware and returns the
+// Content-Encoding of the response to a client accepting gzip
+func compressedEncoding(t *testing.T, cfg *config.Config, contentType string, body string) string {
+	t.Helper()
+
+	app := fiber.New()
+	app.Use(CompressionMiddleware(cfg))
+	app.Get("/", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, contentType)
+		return c.SendString(body)
+	})
+
+	req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+	req.Header.Set(fiber.HeaderAcceptEncoding, "gzip")
+
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	return resp.Header.Get(fiber.HeaderContentEncoding)
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	cfg := &config.Config{
+		CompressLevel:        1,
+		CompressMinSize:      1024,
+		CompressContentTypes: "application/json,text/",
+	}
+	small := `{"data":"` + strings.Repeat("a", 500) + `"}`
+	large := `{"data":"` + strings.Repeat("a", 4096) + `"}`
+
+	t.Run("Sub-threshold response is not compressed", func(t *testing.T) {
+		assert.Empty(t, compressedEncoding(t, cfg, fiber.MIMEApplicationJSON, small))
+	})
+
+	t.Run("Large JSON response is compressed", func(t *testing.T) {
+		assert.Equal(t, "gzip", compressedEncoding(t, cfg, fiber.MIMEApplicationJSONCharsetUTF8, large))
+	})
+
+	t.Run("Type prefix matches every subtype", func(t *testing.T) {
+		assert.Equal(t, "gzip", compressedEncoding(t, cfg, fiber.MIMETextHTML, large))
+	})
+
+	t.Run("Content type outside the allow-list is not compressed", func(t *testing.T) {
+		assert.Empty(t, compressedEncoding(t, cfg, "application/msgpack", large))
+	})
+
+	t.Run("Disabled level never compresses", func(t *testing.T) {
+		disabled := *cfg
+		disabled.CompressLevel = -1
+
+		assert.Empty(t, compressedEncoding(t, &disabled, fiber.MIMEApplicationJSON, large))
+	})
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/chats/go-user-api/config"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/semaphore"
+)
+
+// Heavy operation classes that can be limited through HEAVY_OP_LIMITS
+const (
+	HeavyOpBulk = "bulk"
+)
+
+// ConcurrencyLimiter caps how many requests of each heavy operation class run at once,
+// so a burst of them cannot exhaust the database pool while lighter endpoints keep working
+type ConcurrencyLimiter struct {
+	classes      map[string]*semaphore.Weighted
+	limits       map[string]int64
+	queueTimeout time.Duration
+}
+
+// NewConcurrencyLimiter creates a limiter from HEAVY_OP_LIMITS. Classes that are missing
+// or set to 0 are not limited.
+func NewConcurrencyLimiter(cfg *config.Config) *ConcurrencyLimiter {
+	limits, err := cfg.GetHeavyOpLimits()
+	if err != nil {
+		// Validate rejects this at startup
+		log.Warn().Err(err).Msg("Ignoring invalid heavy operation limits")
+	}
+
+	limiter := &ConcurrencyLimiter{
+		classes:      make(map[string]*semaphore.Weighted),
+		limits:       make(map[string]int64),
+		queueTimeout: cfg.GetHeavyOpQueueTimeout(),
+	}
+	for class, limit := range limits {
+		if limit > 0 {
+			limiter.classes[class] = semaphore.NewWeighted(int64(limit))
+			limiter.limits[class] = int64(limit)
+		}
+	}
+	return limiter
+}
+
+// Limit runs the request once a slot of the class is free. A request costing weight slots
+// waits up to the queue timeout and is rejected with 429 if none frees up in time.
+func (l *ConcurrencyLimiter) Limit(class string, weight int64) fiber.Handler {
+	sem, limited := l.classes[class]
+
+	// A weight above the limit could never be acquired
+	weight = max(1, min(weight, l.limits[class]))
+
+	return func(c *fiber.Ctx) error {
+		if !limited {
+			return c.Next()
+		}
+
+		if !sem.TryAcquire(weight) {
+			ctx, cancel := context.WithTimeout(c.UserContext(), l.queueTimeout)
+			err := sem.Acquire(ctx, weight)
+			cancel()
+
+			if err != nil {
+				log.Warn().
+					Str("class", class).
+					Str("path", c.Path()).
+					Msg("Heavy operation rejected, concurrency limit reached")
+
+				c.Set(fiber.HeaderRetryAfter, "1")
+				return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+					"success": false,
+					"message": "Too many concurrent requests of this kind, retry later",
+				})
+			}
+		}
+		defer sem.Release(weight)
+
+		return c.Next()
+	}
+}
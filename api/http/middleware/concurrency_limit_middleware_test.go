@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chats/go-user-api/config"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	// newApp serves /heavy, which blocks until release is closed, and /light
+	newApp := func(cfg *config.Config) (*fiber.App, chan struct{}, chan struct{}) {
+		started := make(chan struct{}, 10)
+		release := make(chan struct{})
+
+		limiter := NewConcurrencyLimiter(cfg)
+		app := fiber.New()
+		app.Get("/heavy", limiter.Limit(HeavyOpBulk, 1), func(c *fiber.Ctx) error {
+			started <- struct{}{}
+			<-release
+			return c.SendString("done")
+		})
+		app.Get("/light", func(c *fiber.Ctx) error {
+			return c.SendString("ok")
+		})
+
+		return app, started, release
+	}
+
+	// hold starts n heavy requests and waits until all of them are running
+	hold := func(t *testing.T, app *fiber.App, started chan struct{}, n int) chan int {
+		t.Helper()
+
+		statuses := make(chan int, n)
+		for i := 0; i < n; i++ {
+			go func() {
+				resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/heavy", nil), -1)
+				if err != nil {
+					statuses <- 0
+					return
+				}
+				statuses <- resp.StatusCode
+			}()
+		}
+		for i := 0; i < n; i++ {
+			select {
+			case <-started:
+			case <-time.After(2 * time.Second):
+				t.Fatal("heavy request did not start")
+			}
+		}
+		return statuses
+	}
+
+	t.Run("Request over the limit is throttled while light endpoints respond", func(t *testing.T) {
+		app, started, release := newApp(&config.Config{HeavyOpLimits: "bulk=2", HeavyOpQueueTimeoutMs: 20})
+		statuses := hold(t, app, started, 2)
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/heavy", nil), -1)
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, "1", resp.Header.Get(fiber.HeaderRetryAfter))
+
+		resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/light", nil), -1)
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		close(release)
+		assert.Equal(t, fiber.StatusOK, <-statuses)
+		assert.Equal(t, fiber.StatusOK, <-statuses)
+
+		// Slots are released once the heavy requests finish
+		resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/heavy", nil), -1)
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Queued request runs when a slot frees up in time", func(t *testing.T) {
+		app, started, release := newApp(&config.Config{HeavyOpLimits: "bulk=1", HeavyOpQueueTimeoutMs: 2000})
+		statuses := hold(t, app, started, 1)
+
+		time.AfterFunc(50*time.Millisecond, func() { close(release) })
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/heavy", nil), -1)
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, fiber.StatusOK, <-statuses)
+	})
+
+	t.Run("Unconfigured class is not limited", func(t *testing.T) {
+		app, started, release := newApp(&config.Config{HeavyOpLimits: "bulk=0"})
+		statuses := hold(t, app, started, 3)
+
+		close(release)
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, fiber.StatusOK, <-statuses)
+		}
+	})
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/msgpack"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ResponseFormatKey is the Locals key holding the negotiated response media type
+const ResponseFormatKey = "responseFormat"
+
+// ContentNegotiationMiddleware picks the response encoding from the Accept header.
+// MessagePack is chosen only when the client prefers it; JSON stays the default.
+func ContentNegotiationMiddleware(cfg *config.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !cfg.ResponseMsgpackEnabled {
+			return c.Next()
+		}
+
+		c.Vary(fiber.HeaderAccept)
+		format := c.Accepts(fiber.MIMEApplicationJSON, msgpack.ContentType)
+		if format == msgpack.ContentType {
+			c.Locals(ResponseFormatKey, format)
+		}
+
+		return c.Next()
+	}
+}
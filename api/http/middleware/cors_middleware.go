@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"github.com/chats/go-user-api/config"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+)
+
+// CORSMiddleware creates a CORS middleware from the configured origins, methods, headers and credentials policy
+func CORSMiddleware(cfg *config.Config) fiber.Handler {
+	return cors.New(cors.Config{
+		AllowOrigins:     cfg.CorsAllowOrigins,
+		AllowMethods:     cfg.CorsAllowMethods,
+		AllowHeaders:     cfg.CorsAllowHeaders,
+		ExposeHeaders:    cfg.CorsExposeHeaders,
+		AllowCredentials: cfg.CorsAllowCredentials,
+		MaxAge:           cfg.CorsMaxAge,
+	})
+}
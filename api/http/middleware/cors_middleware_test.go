@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chats/go-user-api/config"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func preflight(t *testing.T, cfg *config.Config, origin string) *http.Response {
+	t.Helper()
+
+	app := fiber.New()
+	app.Use(CORSMiddleware(cfg))
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+	req := httptest.NewRequest(fiber.MethodOptions, "/", nil)
+	req.Header.Set(fiber.HeaderOrigin, origin)
+	req.Header.Set(fiber.HeaderAccessControlRequestMethod, fiber.MethodGet)
+
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+
+	return resp
+}
+
+func TestCORSMiddleware_PreflightUsesConfiguredValues(t *testing.T) {
+	cfg := &config.Config{
+		CorsAllowOrigins:     "https://app.example.com",
+		CorsAllowMethods:     "GET,POST",
+		CorsAllowHeaders:     "Authorization,X-API-Key",
+		CorsExposeHeaders:    "X-Request-ID",
+		CorsAllowCredentials: true,
+		CorsMaxAge:           600,
+	}
+
+	resp := preflight(t, cfg, "https://app.example.com")
+
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "https://app.example.com", resp.Header.Get(fiber.HeaderAccessControlAllowOrigin))
+	assert.Equal(t, "GET,POST", resp.Header.Get(fiber.HeaderAccessControlAllowMethods))
+	assert.Equal(t, "Authorization,X-API-Key", resp.Header.Get(fiber.HeaderAccessControlAllowHeaders))
+	assert.Equal(t, "true", resp.Header.Get(fiber.HeaderAccessControlAllowCredentials))
+	assert.Equal(t, "600", resp.Header.Get(fiber.HeaderAccessControlMaxAge))
+}
+
+func TestCORSMiddleware_CredentialsDisabled(t *testing.T) {
+	cfg := &config.Config{
+		CorsAllowOrigins:     "*",
+		CorsAllowMethods:     "GET",
+		CorsAllowHeaders:     "Content-Type",
+		CorsAllowCredentials: false,
+	}
+
+	resp := preflight(t, cfg, "https://anywhere.example.com")
+
+	assert.Equal(t, "*", resp.Header.Get(fiber.HeaderAccessControlAllowOrigin))
+	assert.Empty(t, resp.Header.Get(fiber.HeaderAccessControlAllowCredentials))
+	assert.Empty(t, resp.Header.Get(fiber.HeaderAccessControlMaxAge))
+}
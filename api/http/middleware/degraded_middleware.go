@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/chats/go-user-api/internal/health"
+	"github.com/gofiber/fiber/v2"
+)
+
+// DegradedHeader lists the degraded dependencies on every response while any are unhealthy
+const DegradedHeader = "X-Degraded"
+
+// DegradedMiddleware adds the X-Degraded header naming the unhealthy dependencies, e.g. "cache"
+func DegradedMiddleware(registry *health.Registry) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if degraded := registry.Degraded(); len(degraded) > 0 {
+			c.Set(DegradedHeader, strings.Join(degraded, ","))
+		}
+
+		return c.Next()
+	}
+}
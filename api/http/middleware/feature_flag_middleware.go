@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"html"
+
+	"github.com/chats/go-user-api/internal/features"
+	"github.com/gofiber/fiber/v2"
+)
+
+// FeatureFlagMiddleware answers 404 while the named feature is off, the same way as for a path
+// no route matches, so an endpoint shipped dark does not reveal that it exists
+func FeatureFlagMiddleware(flags *features.Flags, name string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !flags.Enabled(name) {
+			path := string(c.Request().URI().PathOriginal())
+			return fiber.NewError(fiber.StatusNotFound, "Cannot "+c.Method()+" "+html.EscapeString(path))
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"github.com/chats/go-user-api/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// httpsExemptPaths are served over plaintext regardless of enforcement, since probes usually
+// call the instance directly rather than through the TLS-terminating proxy
+var httpsExemptPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// HTTPSMiddleware enforces HTTPS as HTTPS_ENFORCE sets: plaintext requests are redirected to the
+// https URL with 301 or rejected with 403. A request is HTTPS when served over TLS or when a trusted
+// proxy forwards it as such in X-Forwarded-Proto.
+func HTTPSMiddleware(cfg *config.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if cfg.HTTPSEnforce == "off" || cfg.HTTPSEnforce == "" || c.Protocol() == "https" || httpsExemptPaths[c.Path()] {
+			return c.Next()
+		}
+
+		if cfg.HTTPSEnforce == "redirect" {
+			return c.Redirect("https://"+c.Hostname()+c.OriginalURL(), fiber.StatusMovedPermanently)
+		}
+
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "HTTPS is required",
+		})
+	}
+}
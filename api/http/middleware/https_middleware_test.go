@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chats/go-user-api/config"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// httpsRequest sends a GET for target through HTTPSMiddleware, forwarded as proto when it is set
+func httpsRequest(t *testing.T, app *fiber.App, enforce, target, proto string) *http.Response {
+	t.Helper()
+
+	app.Use(HTTPSMiddleware(&config.Config{HTTPSEnforce: enforce}))
+	app.Get("/*", func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+	req := httptest.NewRequest(fiber.MethodGet, target, nil)
+	req.Host = "api.example.com"
+	if proto != "" {
+		req.Header.Set(fiber.HeaderXForwardedProto, proto)
+	}
+
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestHTTPSMiddleware(t *testing.T) {
+	t.Run("Plaintext request is redirected", func(t *testing.T) {
+		resp := httpsRequest(t, fiber.New(), "redirect", "/api/v1/users?page=2", "http")
+
+		assert.Equal(t, fiber.StatusMovedPermanently, resp.StatusCode)
+		assert.Equal(t, "https://api.example.com/api/v1/users?page=2", resp.Header.Get(fiber.HeaderLocation))
+	})
+
+	t.Run("Plaintext request is rejected", func(t *testing.T) {
+		resp := httpsRequest(t, fiber.New(), "reject", "/api/v1/users", "")
+
+		assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("Forwarded HTTPS request passes", func(t *testing.T) {
+		resp := httpsRequest(t, fiber.New(), "reject", "/api/v1/users", "https")
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Forwarded proto from an untrusted peer is ignored", func(t *testing.T) {
+		app := fiber.New(fiber.Config{EnableTrustedProxyCheck: true, TrustedProxies: []string{"10.0.0.1"}})
+
+		resp := httpsRequest(t, app, "reject", "/api/v1/users", "https")
+
+		assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("Health checks are exempt", func(t *testing.T) {
+		resp := httpsRequest(t, fiber.New(), "reject", "/healthz", "")
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Enforcement off", func(t *testing.T) {
+		resp := httpsRequest(t, fiber.New(), "off", "/api/v1/users", "")
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	})
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"errors"
+	"time"
+
+	"github.com/chats/go-user-api/internal/metrics"
+	"github.com/gofiber/fiber/v2"
+)
+
+// MetricsMiddleware records the status and duration of every request for the status indicators
+func MetricsMiddleware(collector *metrics.Collector) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		// An error returned here is turned into a response by the error handler afterwards
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				status = fiberErr.Code
+			}
+		}
+
+		collector.ObserveRequest(status, time.Since(start))
+		return err
+	}
+}
@@ -1,6 +1,10 @@
 package middleware
 
 import (
+	"context"
+	"strings"
+
+	"github.com/chats/go-user-api/internal/models"
 	"github.com/chats/go-user-api/internal/services"
 	"github.com/gofiber/fiber/v2"
 	"github.com/rs/zerolog/log"
@@ -9,6 +13,17 @@ import (
 // HasPermissionMiddleware creates a middleware that checks if user has the required permission
 func HasPermissionMiddleware(authService *services.AuthService, resource, action string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		// API keys are limited to the permissions they were issued with
+		if key, ok := c.Locals("apiKey").(*models.APIKey); ok {
+			if !key.HasPermission(resource, action) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"success": false,
+					"message": "Access denied: API key is not scoped for this permission",
+				})
+			}
+			return c.Next()
+		}
+
 		// Get user ID from context
 		userID, ok := c.Locals("userID").(string)
 		if !ok {
@@ -19,7 +34,8 @@ func HasPermissionMiddleware(authService *services.AuthService, resource, action
 		}
 
 		// Check if user has the required permission
-		hasPermission, err := authService.CheckPermission(c.Context(), userID, resource, action)
+		roles, _ := c.Locals("roles").([]string)
+		decision, err := permissionDecision(c.Context(), authService, userID, roles, resource, action)
 		if err != nil {
 			log.Error().Err(err).
 				Str("user_id", userID).
@@ -33,7 +49,10 @@ func HasPermissionMiddleware(authService *services.AuthService, resource, action
 			})
 		}
 
-		if !hasPermission {
+		switch decision {
+		case models.PermissionUndefined:
+			return undefinedPermission(c, userID, resource, action)
+		case models.PermissionDenied:
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"success": false,
 				"message": "Access denied: insufficient permissions",
@@ -44,6 +63,104 @@ func HasPermissionMiddleware(authService *services.AuthService, resource, action
 	}
 }
 
+// RequireAllPermissions creates a middleware that checks the user holds every listed "resource:action" permission.
+// The permissions are resolved in one batched check and the response lists any that are missing.
+func RequireAllPermissions(authService *services.AuthService, permissions []string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var missing []string
+
+		// API keys are limited to the permissions they were issued with
+		if key, ok := c.Locals("apiKey").(*models.APIKey); ok {
+			missing = make([]string, 0)
+			for _, permission := range permissions {
+				resource, action, _ := strings.Cut(permission, ":")
+				if !key.HasPermission(resource, action) {
+					missing = append(missing, permission)
+				}
+			}
+		} else {
+			// Get user ID from context
+			userID, ok := c.Locals("userID").(string)
+			if !ok {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"success": false,
+					"message": "User ID not found in token",
+				})
+			}
+
+			roles, _ := c.Locals("roles").([]string)
+
+			var err error
+			missing, err = authService.MissingPermissions(c.Context(), userID, roles, permissions)
+			if err != nil {
+				log.Error().Err(err).
+					Str("user_id", userID).
+					Strs("permissions", permissions).
+					Msg("Failed to check permissions")
+
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"success": false,
+					"message": "Failed to check permission",
+				})
+			}
+
+			// Surface checks against permissions that do not exist, as HasPermissionMiddleware does
+			if len(missing) > 0 && authService.StrictPermissionChecks() {
+				for _, permission := range missing {
+					resource, action, _ := strings.Cut(permission, ":")
+					decision, err := authService.CheckPermissionDecision(c.Context(), userID, resource, action)
+					if err == nil && decision == models.PermissionUndefined {
+						return undefinedPermission(c, userID, resource, action)
+					}
+				}
+			}
+		}
+
+		if len(missing) > 0 {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"success":             false,
+				"message":             "Access denied: missing permissions " + strings.Join(missing, ", "),
+				"missing_permissions": missing,
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// permissionDecision decides whether the user holds a permission. Strict checks make one decision
+// that also reports a permission that does not exist, which usually means a typo; other checks use
+// the token's roles when possible.
+func permissionDecision(ctx context.Context, authService *services.AuthService, userID string, roles []string, resource, action string) (models.PermissionDecision, error) {
+	if authService.StrictPermissionChecks() {
+		return authService.CheckPermissionDecision(ctx, userID, resource, action)
+	}
+
+	hasPermission, err := authService.CheckPermissionWithRoles(ctx, userID, roles, resource, action)
+	if err != nil {
+		return "", err
+	}
+	if !hasPermission {
+		return models.PermissionDenied, nil
+	}
+	return models.PermissionGranted, nil
+}
+
+// undefinedPermission answers a check against a permission that does not exist, which usually
+// means a typo, with 500
+func undefinedPermission(c *fiber.Ctx, userID, resource, action string) error {
+	log.Error().
+		Str("user_id", userID).
+		Str("resource", resource).
+		Str("action", action).
+		Msg("Permission check against undefined permission")
+
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"success": false,
+		"message": "Permission is not defined: " + resource + ":" + action,
+	})
+}
+
 // ResourceWriteAccessMiddleware creates a middleware that checks if user has write access to a resource
 func ResourceWriteAccessMiddleware(authService *services.AuthService, resource string) fiber.Handler {
 	return HasPermissionMiddleware(authService, resource, "write")
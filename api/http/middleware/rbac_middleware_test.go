@@ -0,0 +1,236 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/mocks"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireAllPermissions(t *testing.T) {
+	required := []string{"user:write", "role:write"}
+	userID := uuid.New()
+
+	// newApp mounts the middleware behind a stub that authenticates the request like the auth middleware would
+	newApp := func(authService *services.AuthService, locals map[string]interface{}) *fiber.App {
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			for key, value := range locals {
+				c.Locals(key, value)
+			}
+			return c.Next()
+		})
+		app.Post("/users", RequireAllPermissions(authService, required), func(c *fiber.Ctx) error {
+			return c.SendString("created")
+		})
+		return app
+	}
+
+	call := func(t *testing.T, app *fiber.App) (int, map[string]interface{}) {
+		t.Helper()
+
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodPost, "/users", nil))
+		require.NoError(t, err)
+
+		var body map[string]interface{}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body
+	}
+
+	tests := []struct {
+		name        string
+		permissions []models.Permission
+		wantStatus  int
+		wantMissing []interface{}
+	}{
+		{
+			name:        "User with all permissions",
+			permissions: []models.Permission{{Resource: "user", Action: "write"}, {Resource: "role", Action: "write"}, {Resource: "user", Action: "read"}},
+			wantStatus:  fiber.StatusOK,
+		},
+		{
+			name:        "User with some permissions",
+			permissions: []models.Permission{{Resource: "user", Action: "write"}, {Resource: "role", Action: "read"}},
+			wantStatus:  fiber.StatusForbidden,
+			wantMissing: []interface{}{"role:write"},
+		},
+		{
+			name:        "User with none of the permissions",
+			permissions: []models.Permission{{Resource: "user", Action: "read"}},
+			wantStatus:  fiber.StatusForbidden,
+			wantMissing: []interface{}{"user:write", "role:write"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserRepo := new(mocks.MockUserRepository)
+			mockUserRepo.On("GetUserPermissions", mock.Anything, userID).Return(tt.permissions, nil).Once()
+			authService := services.NewAuthService(mockUserRepo, &config.Config{})
+
+			status, body := call(t, newApp(authService, map[string]interface{}{"userID": userID.String()}))
+
+			assert.Equal(t, tt.wantStatus, status)
+			if tt.wantMissing != nil {
+				assert.Equal(t, tt.wantMissing, body["missing_permissions"])
+			}
+
+			// Every permission is resolved from a single lookup
+			mockUserRepo.AssertNumberOfCalls(t, "GetUserPermissions", 1)
+			mockUserRepo.AssertNotCalled(t, "HasPermission", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+
+	t.Run("API key scopes", func(t *testing.T) {
+		authService := services.NewAuthService(new(mocks.MockUserRepository), &config.Config{})
+
+		status, _ := call(t, newApp(authService, map[string]interface{}{
+			"apiKey": &models.APIKey{Permissions: []string{"user:write", "role:write"}},
+		}))
+		assert.Equal(t, fiber.StatusOK, status)
+
+		status, body := call(t, newApp(authService, map[string]interface{}{
+			"apiKey": &models.APIKey{Permissions: []string{"user:write"}},
+		}))
+		assert.Equal(t, fiber.StatusForbidden, status)
+		assert.Equal(t, []interface{}{"role:write"}, body["missing_permissions"])
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		authService := services.NewAuthService(new(mocks.MockUserRepository), &config.Config{})
+
+		status, _ := call(t, newApp(authService, nil))
+
+		assert.Equal(t, fiber.StatusUnauthorized, status)
+	})
+
+	t.Run("Strict mode reports an undefined permission", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetUserPermissions", mock.Anything, userID).Return([]models.Permission{{Resource: "user", Action: "write"}}, nil)
+		mockUserRepo.On("HasPermission", mock.Anything, userID, "role", "write").Return(false, nil)
+		mockPermissionRepo := new(mocks.MockPermissionRepository)
+		mockPermissionRepo.On("ExistsByResourceAction", mock.Anything, "role", "write").Return(false, nil)
+		authService := services.NewAuthService(mockUserRepo, &config.Config{PermissionCheckStrict: true})
+		authService.UsePermissionRepository(mockPermissionRepo)
+
+		status, body := call(t, newApp(authService, map[string]interface{}{"userID": userID.String()}))
+
+		assert.Equal(t, fiber.StatusInternalServerError, status)
+		assert.Equal(t, "Permission is not defined: role:write", body["message"])
+	})
+
+	t.Run("Lookup failure", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetUserPermissions", mock.Anything, userID).Return([]models.Permission(nil), errors.New("database error"))
+		authService := services.NewAuthService(mockUserRepo, &config.Config{})
+
+		status, _ := call(t, newApp(authService, map[string]interface{}{"userID": userID.String()}))
+
+		assert.Equal(t, fiber.StatusInternalServerError, status)
+	})
+}
+
+func TestHasPermissionMiddleware(t *testing.T) {
+	userID := uuid.New()
+
+	// call runs a request through the middleware behind a stub that authenticates it like the auth middleware would
+	call := func(t *testing.T, authService *services.AuthService, locals map[string]interface{}) (int, map[string]interface{}) {
+		t.Helper()
+
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			for key, value := range locals {
+				c.Locals(key, value)
+			}
+			return c.Next()
+		})
+		app.Post("/roles", HasPermissionMiddleware(authService, "role", "write"), func(c *fiber.Ctx) error {
+			return c.SendString("created")
+		})
+
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodPost, "/roles", nil))
+		require.NoError(t, err)
+
+		var body map[string]interface{}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body
+	}
+
+	// strictService returns a service in strict mode whose user holds the permission when granted,
+	// and whose permission is defined when exists
+	strictService := func(granted, exists bool) (*services.AuthService, *mocks.MockUserRepository) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("HasPermission", mock.Anything, userID, "role", "write").Return(granted, nil)
+		mockPermissionRepo := new(mocks.MockPermissionRepository)
+		mockPermissionRepo.On("ExistsByResourceAction", mock.Anything, "role", "write").Return(exists, nil)
+		authService := services.NewAuthService(mockUserRepo, &config.Config{PermissionCheckStrict: true})
+		authService.UsePermissionRepository(mockPermissionRepo)
+		return authService, mockUserRepo
+	}
+
+	t.Run("API key scoped for the permission", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		authService := services.NewAuthService(mockUserRepo, &config.Config{})
+
+		status, _ := call(t, authService, map[string]interface{}{
+			"apiKey": &models.APIKey{Permissions: []string{"user:read", "role:write"}},
+		})
+
+		assert.Equal(t, fiber.StatusOK, status)
+		mockUserRepo.AssertNotCalled(t, "HasPermission", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("API key denied outside its permissions", func(t *testing.T) {
+		// The key's creator could hold role:write; the key is still limited to its own scope
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("HasPermission", mock.Anything, mock.Anything, "role", "write").Return(true, nil)
+		authService := services.NewAuthService(mockUserRepo, &config.Config{})
+
+		status, body := call(t, authService, map[string]interface{}{
+			"apiKey": &models.APIKey{CreatedBy: userID, Permissions: []string{"user:read", "role:read"}},
+			"userID": userID.String(),
+		})
+
+		assert.Equal(t, fiber.StatusForbidden, status)
+		assert.Equal(t, "Access denied: API key is not scoped for this permission", body["message"])
+		mockUserRepo.AssertNotCalled(t, "HasPermission", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Strict mode grants a held permission", func(t *testing.T) {
+		authService, mockUserRepo := strictService(true, true)
+
+		status, _ := call(t, authService, map[string]interface{}{"userID": userID.String()})
+
+		assert.Equal(t, fiber.StatusOK, status)
+		mockUserRepo.AssertNumberOfCalls(t, "HasPermission", 1)
+	})
+
+	t.Run("Strict mode denies a defined permission", func(t *testing.T) {
+		authService, mockUserRepo := strictService(false, true)
+
+		status, _ := call(t, authService, map[string]interface{}{"userID": userID.String()})
+
+		assert.Equal(t, fiber.StatusForbidden, status)
+		mockUserRepo.AssertNumberOfCalls(t, "HasPermission", 1)
+	})
+
+	t.Run("Strict mode reports an undefined permission", func(t *testing.T) {
+		authService, mockUserRepo := strictService(false, false)
+
+		status, body := call(t, authService, map[string]interface{}{"userID": userID.String()})
+
+		assert.Equal(t, fiber.StatusInternalServerError, status)
+		assert.Equal(t, "Permission is not defined: role:write", body["message"])
+		mockUserRepo.AssertNumberOfCalls(t, "HasPermission", 1)
+	})
+}
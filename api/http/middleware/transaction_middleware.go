@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/chats/go-user-api/internal/repositories/transaction"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// errResponseFailed rolls back a request transaction when the handler responded with an error status
+var errResponseFailed = errors.New("response has an error status")
+
+// TransactionMiddleware runs the rest of the request in one transaction that service calls writing
+// through the transaction manager join through the request context, so several mutations commit or
+// roll back together. Deleting a role or a permission and creating or revoking an API key write
+// through the plain repositories instead and commit on their own. The transaction rolls back when
+// the handler returns an error or responds with a 4xx or 5xx status. Reads through the plain
+// repositories do not see its changes until it commits, and the cache invalidation and events
+// services defer with transaction.AfterCommit run once it has. A database that cannot roll back is
+// refused with 501 before the handler runs, since the changes would not be atomic.
+func TransactionMiddleware(manager transaction.Manager[transaction.Repository]) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var handlerErr error
+		hooks := &transaction.CommitHooks{}
+		err := manager.ExecuteTx(c.Context(), func(tx transaction.Repository) error {
+			if err := transaction.RequireAtomic(tx); err != nil {
+				return err
+			}
+
+			c.Context().SetUserValue(transaction.AmbientKey, tx)
+			c.Context().SetUserValue(transaction.CommitHooksKey, hooks)
+			defer c.Context().RemoveUserValue(transaction.AmbientKey)
+			defer c.Context().RemoveUserValue(transaction.CommitHooksKey)
+
+			if handlerErr = c.Next(); handlerErr != nil {
+				return handlerErr
+			}
+			if c.Response().StatusCode() >= fiber.StatusBadRequest {
+				return errResponseFailed
+			}
+			return nil
+		})
+
+		switch {
+		case handlerErr != nil:
+			return handlerErr
+		case errors.Is(err, errResponseFailed):
+			// The handler already wrote its error response
+			return nil
+		case errors.Is(err, transaction.ErrNotAtomic):
+			return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{
+				"success": false,
+				"message": "Request transactions need database transaction support",
+				"error":   err.Error(),
+			})
+		case err != nil:
+			log.Error().Err(err).
+				Str("path", c.Path()).
+				Str("method", c.Method()).
+				Msg("Failed to commit request transaction")
+
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to commit changes",
+			})
+		}
+
+		hooks.Run()
+		return nil
+	}
+}
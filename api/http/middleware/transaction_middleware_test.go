@@ -0,0 +1,208 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chats/go-user-api/internal/mocks"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/repositories/transaction"
+	"github.com/chats/go-user-api/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTx records how a transaction ended
+type fakeTx struct {
+	outcomes *[]string
+}
+
+func (tx *fakeTx) Commit() error {
+	*tx.outcomes = append(*tx.outcomes, "commit")
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	*tx.outcomes = append(*tx.outcomes, "rollback")
+	return nil
+}
+
+func TestTransactionMiddleware(t *testing.T) {
+	// setup returns an app whose handler creates two roles through the role service and then
+	// finishes as fail says, and the outcome of every transaction begun
+	setup := func(t *testing.T, scoped bool, fail func(c *fiber.Ctx) error) (*fiber.App, *[]string, *mocks.MockTxRepository) {
+		t.Helper()
+
+		outcomes := &[]string{}
+		mockTxRepo := new(mocks.MockTxRepository)
+		mockTxRepo.On("CreateRole", mock.Anything, mock.Anything).Return(nil)
+		manager := transaction.NewGenericManager(
+			func(ctx context.Context) (*fakeTx, error) {
+				return &fakeTx{outcomes: outcomes}, nil
+			},
+			func(tx *fakeTx) transaction.Repository {
+				return mockTxRepo
+			},
+		)
+
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockRoleRepo.On("GetByName", mock.Anything, mock.Anything).Return(nil, errors.New("role not found"))
+		mockRoleRepo.On("GetByID", mock.Anything, mock.Anything).Return(nil, errors.New("role not found"))
+		roleService := services.NewRoleService(mockRoleRepo, new(mocks.MockPermissionRepository), manager, services.DefaultRoleServiceOptions())
+
+		handlers := []fiber.Handler{func(c *fiber.Ctx) error {
+			for _, name := range []string{"auditor", "reviewer"} {
+				if _, err := roleService.CreateRole(c.Context(), models.RoleCreateRequest{Name: name}); err != nil {
+					return err
+				}
+			}
+			if fail != nil {
+				return fail(c)
+			}
+			return c.SendStatus(fiber.StatusCreated)
+		}}
+		if scoped {
+			handlers = append([]fiber.Handler{TransactionMiddleware(manager)}, handlers...)
+		}
+
+		app := fiber.New()
+		app.Post("/roles", handlers...)
+
+		return app, outcomes, mockTxRepo
+	}
+
+	call := func(t *testing.T, app *fiber.App) int {
+		t.Helper()
+
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodPost, "/roles", nil))
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	t.Run("Both mutations commit together", func(t *testing.T) {
+		app, outcomes, mockTxRepo := setup(t, true, nil)
+
+		assert.Equal(t, fiber.StatusCreated, call(t, app))
+		assert.Equal(t, []string{"commit"}, *outcomes)
+		mockTxRepo.AssertNumberOfCalls(t, "CreateRole", 2)
+	})
+
+	t.Run("Both mutations roll back on a later error response", func(t *testing.T) {
+		app, outcomes, mockTxRepo := setup(t, true, func(c *fiber.Ctx) error {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"success": false})
+		})
+
+		assert.Equal(t, fiber.StatusInternalServerError, call(t, app))
+		assert.Equal(t, []string{"rollback"}, *outcomes)
+		mockTxRepo.AssertNumberOfCalls(t, "CreateRole", 2)
+	})
+
+	t.Run("Both mutations roll back on a later handler error", func(t *testing.T) {
+		app, outcomes, _ := setup(t, true, func(c *fiber.Ctx) error {
+			return fiber.NewError(fiber.StatusConflict, "conflict")
+		})
+
+		assert.Equal(t, fiber.StatusConflict, call(t, app))
+		assert.Equal(t, []string{"rollback"}, *outcomes)
+	})
+
+	t.Run("Without the middleware each call commits on its own", func(t *testing.T) {
+		app, outcomes, _ := setup(t, false, func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		})
+
+		assert.Equal(t, fiber.StatusInternalServerError, call(t, app))
+		assert.Equal(t, []string{"commit", "commit"}, *outcomes)
+	})
+}
+
+// sequentialTx is a transaction repository that cannot roll back its writes
+type sequentialTx struct {
+	*mocks.MockTxRepository
+}
+
+func (sequentialTx) Sequential() bool { return true }
+
+func TestTransactionMiddleware_WithoutTransactionSupport(t *testing.T) {
+	outcomes := &[]string{}
+	manager := transaction.NewGenericManager(
+		func(ctx context.Context) (*fakeTx, error) {
+			return &fakeTx{outcomes: outcomes}, nil
+		},
+		func(tx *fakeTx) transaction.Repository {
+			return sequentialTx{new(mocks.MockTxRepository)}
+		},
+	)
+
+	handled := false
+	app := fiber.New()
+	app.Post("/roles", TransactionMiddleware(manager), func(c *fiber.Ctx) error {
+		handled = true
+		return c.SendStatus(fiber.StatusCreated)
+	})
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodPost, "/roles", nil))
+	require.NoError(t, err)
+
+	assert.Equal(t, fiber.StatusNotImplemented, resp.StatusCode)
+	assert.False(t, handled, "the handler must not write without a transaction to roll back")
+	assert.Equal(t, []string{"rollback"}, *outcomes)
+}
+
+func TestTransactionMiddleware_AfterCommit(t *testing.T) {
+	// setup returns an app whose handler defers a hook until after commit and then responds with
+	// status, and the outcome of the transaction followed by the hook when it ran
+	setup := func(status int) (*fiber.App, *[]string) {
+		outcomes := &[]string{}
+		manager := transaction.NewGenericManager(
+			func(ctx context.Context) (*fakeTx, error) {
+				return &fakeTx{outcomes: outcomes}, nil
+			},
+			func(tx *fakeTx) transaction.Repository {
+				return new(mocks.MockTxRepository)
+			},
+		)
+
+		app := fiber.New()
+		app.Post("/users", TransactionMiddleware(manager), func(c *fiber.Ctx) error {
+			transaction.AfterCommit(c.Context(), func() {
+				*outcomes = append(*outcomes, "hook")
+			})
+			return c.SendStatus(status)
+		})
+
+		return app, outcomes
+	}
+
+	t.Run("Hooks run once the transaction commits", func(t *testing.T) {
+		app, outcomes := setup(fiber.StatusOK)
+
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodPost, "/users", nil))
+
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, []string{"commit", "hook"}, *outcomes)
+	})
+
+	t.Run("Hooks are dropped when the transaction rolls back", func(t *testing.T) {
+		app, outcomes := setup(fiber.StatusBadRequest)
+
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodPost, "/users", nil))
+
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, []string{"rollback"}, *outcomes)
+	})
+
+	t.Run("Hooks run right away without a request transaction", func(t *testing.T) {
+		ran := false
+
+		transaction.AfterCommit(context.Background(), func() { ran = true })
+
+		assert.True(t, ran)
+	})
+}
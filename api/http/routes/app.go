@@ -0,0 +1,41 @@
+package routes
+
+import (
+	"errors"
+	"time"
+
+	"github.com/chats/go-user-api/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// NewApp creates the Fiber app. Routing is lenient by default, so /api/v1/Users and
+// /api/v1/users/ reach the same handler as /api/v1/users.
+func NewApp(cfg *config.Config) *fiber.App {
+	return fiber.New(fiber.Config{
+		AppName:               cfg.AppName,
+		DisableStartupMessage: true,
+		ReadTimeout:           30 * time.Second,
+		WriteTimeout:          30 * time.Second,
+		IdleTimeout:           60 * time.Second,
+		StrictRouting:         cfg.RoutingStrict,
+		CaseSensitive:         cfg.RoutingCaseSensitive,
+		// Forwarded headers such as X-Forwarded-Proto are only believed from the trusted proxies, so
+		// none are believed until proxies are configured
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          cfg.GetTrustedProxies(),
+		ErrorHandler: func(c *fiber.Ctx, err error) error {
+			code := fiber.StatusInternalServerError
+
+			// Fiber errors carry their status, e.g. 404 for a path no route matches
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				code = fiberErr.Code
+			}
+
+			return c.Status(code).JSON(fiber.Map{
+				"success": false,
+				"message": err.Error(),
+			})
+		},
+	})
+}
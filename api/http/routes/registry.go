@@ -0,0 +1,225 @@
+package routes
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/chats/go-user-api/api/http/middleware"
+	"github.com/chats/go-user-api/internal/features"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Registry records every declared route with the access it requires, so the
+// enforced access map can be listed for review
+type Registry struct {
+	routes []models.RouteInfo
+
+	// overrides replace the permissions a route declares, keyed by "METHOD /path";
+	// applied records those that matched a permission-guarded route
+	overrides map[string][]string
+	applied   map[string]bool
+}
+
+// newRegistry creates a registry applying the permission overrides
+func newRegistry(overrides map[string][]string) *Registry {
+	return &Registry{
+		overrides: overrides,
+		applied:   make(map[string]bool),
+	}
+}
+
+// Routes returns the recorded routes ordered by path, then method
+func (r *Registry) Routes() []models.RouteInfo {
+	routes := make([]models.RouteInfo, len(r.routes))
+	copy(routes, r.routes)
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// CheckOverrides reports every permission override that names no permission-guarded route
+func (r *Registry) CheckOverrides() error {
+	routes := make([]string, 0, len(r.overrides))
+	for route := range r.overrides {
+		if !r.applied[route] {
+			routes = append(routes, route)
+		}
+	}
+	sort.Strings(routes)
+
+	errs := make([]error, len(routes))
+	for i, route := range routes {
+		errs[i] = fmt.Errorf("PERMISSION_OVERRIDES names %q, which is not a route guarded by permissions", route)
+	}
+	return errors.Join(errs...)
+}
+
+// guard is an access requirement: the middleware enforcing it and how it reads in the manifest
+type guard struct {
+	handlers []fiber.Handler
+	access   models.RouteAccess
+
+	// withPermissions rebuilds the guard requiring other permissions; nil when it requires none
+	withPermissions func(permissions []string) guard
+}
+
+// public adds no requirement beyond those of the enclosing group
+var public = guard{}
+
+// authenticated requires a caller identified by the given authentication middleware
+func authenticated(handlers ...fiber.Handler) guard {
+	return guard{handlers: handlers, access: models.RouteAccess{Authenticated: true}}
+}
+
+// adminOnly requires the admin role
+func adminOnly() guard {
+	return guard{
+		handlers: []fiber.Handler{middleware.AdminOnlyMiddleware()},
+		access:   models.RouteAccess{Roles: []string{"admin"}},
+	}
+}
+
+// selfOrAdmin requires the admin role unless the caller is the user named by :id
+func selfOrAdmin() guard {
+	return guard{
+		handlers: []fiber.Handler{middleware.SelfOrRoleMiddleware("id", "admin")},
+		access:   models.RouteAccess{Roles: []string{"admin"}, Self: true},
+	}
+}
+
+// requirePermission requires the resource:action permission
+func requirePermission(authService *services.AuthService, resource, action string) guard {
+	return guard{
+		handlers: []fiber.Handler{middleware.HasPermissionMiddleware(authService, resource, action)},
+		access:   models.RouteAccess{Permissions: []string{resource + ":" + action}},
+		withPermissions: func(permissions []string) guard {
+			return requireAllPermissions(authService, permissions)
+		},
+	}
+}
+
+// requireAllPermissions requires every listed "resource:action" permission
+func requireAllPermissions(authService *services.AuthService, permissions []string) guard {
+	return guard{
+		handlers: []fiber.Handler{middleware.RequireAllPermissions(authService, permissions)},
+		access:   models.RouteAccess{Permissions: permissions},
+		withPermissions: func(permissions []string) guard {
+			return requireAllPermissions(authService, permissions)
+		},
+	}
+}
+
+// behindFeature requires g and the named feature to be enabled. The flag is checked first,
+// so callers see a 404 rather than learn from a 401 or 403 that the route exists.
+func behindFeature(flags *features.Flags, name string, g guard) guard {
+	access := g.access
+	access.Feature = name
+
+	var withPermissions func(permissions []string) guard
+	if g.withPermissions != nil {
+		withPermissions = func(permissions []string) guard {
+			return behindFeature(flags, name, g.withPermissions(permissions))
+		}
+	}
+
+	return guard{
+		handlers:        append([]fiber.Handler{middleware.FeatureFlagMiddleware(flags, name)}, g.handlers...),
+		access:          access,
+		withPermissions: withPermissions,
+	}
+}
+
+// routeGroup declares routes on a fiber router and records each one with the access its
+// enclosing groups and its own guard require
+type routeGroup struct {
+	router   fiber.Router
+	prefix   string
+	access   models.RouteAccess
+	registry *Registry
+}
+
+// newRouteGroup wraps the app root
+func newRouteGroup(app *fiber.App, registry *Registry) *routeGroup {
+	return &routeGroup{router: app, registry: registry}
+}
+
+// Group creates a sub-group whose routes all require g
+func (rg *routeGroup) Group(prefix string, g guard) *routeGroup {
+	return &routeGroup{
+		router:   rg.router.Group(prefix, g.handlers...),
+		prefix:   groupPath(rg.prefix, prefix),
+		access:   mergeAccess(rg.access, g.access),
+		registry: rg.registry,
+	}
+}
+
+// Get declares a GET route guarded by g
+func (rg *routeGroup) Get(path string, g guard, handlers ...fiber.Handler) {
+	rg.add(fiber.MethodGet, path, g, handlers)
+}
+
+// Post declares a POST route guarded by g
+func (rg *routeGroup) Post(path string, g guard, handlers ...fiber.Handler) {
+	rg.add(fiber.MethodPost, path, g, handlers)
+}
+
+// Put declares a PUT route guarded by g
+func (rg *routeGroup) Put(path string, g guard, handlers ...fiber.Handler) {
+	rg.add(fiber.MethodPut, path, g, handlers)
+}
+
+// Delete declares a DELETE route guarded by g
+func (rg *routeGroup) Delete(path string, g guard, handlers ...fiber.Handler) {
+	rg.add(fiber.MethodDelete, path, g, handlers)
+}
+
+func (rg *routeGroup) add(method, path string, g guard, handlers []fiber.Handler) {
+	fullPath := groupPath(rg.prefix, path)
+
+	// A configured override replaces the permissions the route declares
+	route := method + " " + fullPath
+	if permissions, ok := rg.registry.overrides[route]; ok && g.withPermissions != nil {
+		g = g.withPermissions(permissions)
+		rg.registry.applied[route] = true
+	}
+
+	rg.router.Add(method, path, append(append([]fiber.Handler{}, g.handlers...), handlers...)...)
+
+	rg.registry.routes = append(rg.registry.routes, models.RouteInfo{
+		Method:      method,
+		Path:        fullPath,
+		RouteAccess: mergeAccess(rg.access, g.access),
+	})
+}
+
+// groupPath joins a group prefix and a path the way fiber does
+func groupPath(prefix, path string) string {
+	if path == "" {
+		return prefix
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return strings.TrimRight(prefix, "/") + path
+}
+
+// mergeAccess combines the requirements of an enclosing group with those of a nested guard
+func mergeAccess(outer, inner models.RouteAccess) models.RouteAccess {
+	return models.RouteAccess{
+		Authenticated: outer.Authenticated || inner.Authenticated,
+		Roles:         append(append([]string(nil), outer.Roles...), inner.Roles...),
+		Self:          outer.Self || inner.Self,
+		Permissions:   append(append([]string(nil), outer.Permissions...), inner.Permissions...),
+		Feature:       cmp.Or(inner.Feature, outer.Feature),
+	}
+}
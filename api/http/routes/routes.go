@@ -4,11 +4,13 @@ import (
 	"github.com/chats/go-user-api/api/http/handlers"
 	"github.com/chats/go-user-api/api/http/middleware"
 	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/features"
 	"github.com/chats/go-user-api/internal/services"
 	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
 )
 
-// SetupRoutes sets up all HTTP routes for the application
+// SetupRoutes sets up all HTTP routes for the application and returns the registry listing them
 func SetupRoutes(
 	app *fiber.App,
 	cfg *config.Config,
@@ -16,54 +18,142 @@ func SetupRoutes(
 	userHandler *handlers.UserHandler,
 	roleHandler *handlers.RoleHandler,
 	permissionHandler *handlers.PermissionHandler,
+	apiKeyHandler *handlers.APIKeyHandler,
+	adminHandler *handlers.AdminHandler,
+	rbacHandler *handlers.RBACHandler,
+	healthHandler *handlers.HealthHandler,
 	authService *services.AuthService,
-) {
+	apiKeyService *services.APIKeyService,
+) *Registry {
+	// Deployments may remap the permissions guarding a route
+	overrides, err := cfg.GetPermissionOverrides()
+	if err != nil {
+		// Validate rejects this at startup
+		log.Warn().Err(err).Msg("Ignoring invalid permission overrides")
+	}
+	registry := newRegistry(overrides)
+	root := newRouteGroup(app, registry)
+
 	// Health check
-	app.Get("/healthz", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{
-			"status": "ok",
-		})
-	})
+	root.Get("/healthz", public, healthHandler.Healthz)
+	root.Get("/readyz", public, healthHandler.Readyz)
 
 	// API routes
-	api := app.Group("/api/v1")
+	api := root.Group("/api/v1", public)
 
 	// Public routes
-	auth := api.Group("/auth")
-	auth.Post("/login", authHandler.Login)
+	auth := api.Group("/auth", public)
+	auth.Post("/login", public, authHandler.Login)
+	// Refresh authenticates with the refresh token in the body, never an access token
+	auth.Post("/refresh", public, authHandler.RefreshToken)
+	// Confirming a reset authenticates with the reset token in the body
+	auth.Post("/reset-password/confirm", public, authHandler.ConfirmPasswordReset)
+
+	// Reissue accepts stale tokens, since that is how clients pick up changed roles
+	auth.Post("/reissue", authenticated(
+		middleware.JWTAuthMiddleware(cfg),
+		middleware.RejectRevokedTokenMiddleware(authService),
+	), authHandler.ReissueToken)
 
-	// Protected routes
-	protected := api.Group("", middleware.JWTAuthMiddleware(cfg))
+	// Protected routes (Bearer JWT or X-API-Key); a token issued for an expired password can only change it
+	protected := api.Group("", authenticated(
+		middleware.JWTOrAPIKeyAuthMiddleware(cfg, apiKeyService),
+		middleware.RejectRevokedTokenMiddleware(authService),
+		middleware.RejectStaleTokenMiddleware(authService),
+		middleware.ResolveOmittedRolesMiddleware(authService),
+		middleware.PasswordChangeScopeMiddleware("/api/v1/auth/change-password"),
+	))
 
 	// Auth routes
-	protectedAuth := protected.Group("/auth")
-	protectedAuth.Post("/change-password", authHandler.ChangePassword)
-	protectedAuth.Post("/reset-password", middleware.AdminOnlyMiddleware(), authHandler.ResetPassword)
-
-	// User routes
-	users := protected.Group("/users")
-	users.Get("/", middleware.ResourceReadAccessMiddleware(authService, "user"), userHandler.GetUsers)
-	users.Post("/", middleware.ResourceWriteAccessMiddleware(authService, "user"), userHandler.CreateUser)
-	users.Get("/me", userHandler.GetMe)
-	users.Get("/:id", middleware.ResourceReadAccessMiddleware(authService, "user"), userHandler.GetUser)
-	users.Put("/:id", middleware.ResourceWriteAccessMiddleware(authService, "user"), userHandler.UpdateUser)
-	users.Delete("/:id", middleware.ResourceDeleteAccessMiddleware(authService, "user"), userHandler.DeleteUser)
-	users.Get("/:id/permissions", middleware.ResourceReadAccessMiddleware(authService, "user"), userHandler.GetUserPermissions)
+	protectedAuth := protected.Group("/auth", public)
+	protectedAuth.Post("/change-password", public, authHandler.ChangePassword)
+	protectedAuth.Post("/step-up", public, authHandler.StepUp)
+	protectedAuth.Post("/reset-password", adminOnly(), middleware.ReauthMiddleware(authService, config.ReauthResetPassword, nil), authHandler.ResetPassword)
+
+	// Heavy operations share a concurrency limit per class
+	heavyOps := middleware.NewConcurrencyLimiter(cfg)
+
+	// Newer endpoints can be switched off per environment, or at runtime by an admin
+	flags, err := cfg.GetFeatureFlags()
+	if err != nil {
+		// Validate rejects this at startup
+		log.Warn().Err(err).Msg("Ignoring invalid feature flags")
+	}
+	featureFlags := features.NewFlags(flags)
+
+	users := protected.Group("/users", public)
+	users.Get("/", requirePermission(authService, "user", "read"), userHandler.GetUsers)
+	// Creating or updating a user that assigns roles needs role:write too, which the user service checks
+	users.Post("/", requirePermission(authService, "user", "write"), userHandler.CreateUser)
+	users.Get("/me", public, userHandler.GetMe)
+	users.Get("/me/api-keys", public, apiKeyHandler.GetMyAPIKeys)
+	users.Delete("/me/api-keys/:id", public, apiKeyHandler.RevokeMyAPIKey)
+	users.Post("/bulk-deactivate", behindFeature(featureFlags, features.BulkOps, adminOnly()), heavyOps.Limit(middleware.HeavyOpBulk, 1), userHandler.BulkDeactivateUsers)
+	users.Post("/import", behindFeature(featureFlags, features.BulkOps, adminOnly()), heavyOps.Limit(middleware.HeavyOpBulk, 1), userHandler.ImportUsers)
+	users.Get("/:id", requirePermission(authService, "user", "read"), userHandler.GetUser)
+	users.Put("/:id", requirePermission(authService, "user", "write"), middleware.ReauthMiddleware(authService, config.ReauthChangeEmail, middleware.SetsOwnEmail), userHandler.UpdateUser)
+	users.Delete("/:id", requirePermission(authService, "user", "delete"), middleware.ReauthMiddleware(authService, config.ReauthDeleteUser, nil), userHandler.DeleteUser)
+	users.Delete("/:id/purge", requirePermission(authService, "user", "delete"), middleware.ReauthMiddleware(authService, config.ReauthDeleteUser, nil), userHandler.PurgeUser)
+	// Users can revoke their own tokens
+	users.Post("/:id/revoke-tokens", selfOrAdmin(), userHandler.RevokeUserTokens)
+	// Restoring a user gives roles back, so role write access is required too
+	users.Post("/:id/restore", requireAllPermissions(authService, []string{"user:delete", "role:write"}), userHandler.RestoreUser)
+	users.Post("/:id/transfer-roles/:targetId", requireAllPermissions(authService, []string{"user:write", "role:write"}), userHandler.TransferRoles)
+	// Merging moves roles and deletes the source user
+	users.Post("/:id/merge/:sourceId", requireAllPermissions(authService, []string{"user:write", "role:write", "user:delete"}), userHandler.MergeUsers)
+	users.Get("/:id/permissions", requirePermission(authService, "user", "read"), userHandler.GetUserPermissions)
+	users.Get("/:id/policy", requireAllPermissions(authService, []string{"user:read", "role:read"}), userHandler.GetUserPolicy)
 
 	// Role routes
-	roles := protected.Group("/roles")
-	roles.Get("/", middleware.ResourceReadAccessMiddleware(authService, "role"), roleHandler.GetRoles)
-	roles.Post("/", middleware.ResourceWriteAccessMiddleware(authService, "role"), roleHandler.CreateRole)
-	roles.Get("/:id", middleware.ResourceReadAccessMiddleware(authService, "role"), roleHandler.GetRole)
-	roles.Put("/:id", middleware.ResourceWriteAccessMiddleware(authService, "role"), roleHandler.UpdateRole)
-	roles.Delete("/:id", middleware.ResourceDeleteAccessMiddleware(authService, "role"), roleHandler.DeleteRole)
-	roles.Get("/:id/permissions", middleware.ResourceReadAccessMiddleware(authService, "role"), roleHandler.GetRolePermissions)
+	roles := protected.Group("/roles", public)
+	roles.Get("/", requirePermission(authService, "role", "read"), roleHandler.GetRoles)
+	roles.Post("/", requirePermission(authService, "role", "write"), roleHandler.CreateRole)
+	roles.Get("/:id", requirePermission(authService, "role", "read"), roleHandler.GetRole)
+	roles.Put("/:id", requirePermission(authService, "role", "write"), roleHandler.UpdateRole)
+	roles.Delete("/:id", requirePermission(authService, "role", "delete"), roleHandler.DeleteRole)
+	roles.Get("/:id/permissions", requirePermission(authService, "role", "read"), roleHandler.GetRolePermissions)
+	roles.Post("/:id/permissions", requirePermission(authService, "role", "write"), roleHandler.AddRolePermissions)
+	roles.Delete("/:id/permissions", requirePermission(authService, "role", "write"), roleHandler.RemoveRolePermissions)
+	roles.Get("/:id/diff/:otherId", requirePermission(authService, "role", "read"), roleHandler.DiffRolePermissions)
+	roles.Post("/:id/permissions/validate", requirePermission(authService, "role", "write"), roleHandler.ValidateRolePermissions)
+	roles.Post("/:id/permissions/impact", requirePermission(authService, "role", "write"), roleHandler.PreviewRolePermissionChange)
 
 	// Permission routes
-	permissions := protected.Group("/permissions")
-	permissions.Get("/", middleware.ResourceReadAccessMiddleware(authService, "permission"), permissionHandler.GetPermissions)
-	permissions.Post("/", middleware.ResourceWriteAccessMiddleware(authService, "permission"), permissionHandler.CreatePermission)
-	permissions.Get("/:id", middleware.ResourceReadAccessMiddleware(authService, "permission"), permissionHandler.GetPermission)
-	permissions.Put("/:id", middleware.ResourceWriteAccessMiddleware(authService, "permission"), permissionHandler.UpdatePermission)
-	permissions.Delete("/:id", middleware.ResourceDeleteAccessMiddleware(authService, "permission"), permissionHandler.DeletePermission)
+	permissions := protected.Group("/permissions", public)
+	permissions.Get("/", requirePermission(authService, "permission", "read"), permissionHandler.GetPermissions)
+	permissions.Post("/", requirePermission(authService, "permission", "write"), permissionHandler.CreatePermission)
+	permissions.Get("/catalog", requirePermission(authService, "permission", "read"), permissionHandler.GetPermissionCatalog)
+	permissions.Get("/:id", requirePermission(authService, "permission", "read"), permissionHandler.GetPermission)
+	permissions.Put("/:id", requirePermission(authService, "permission", "write"), permissionHandler.UpdatePermission)
+	permissions.Delete("/:id", requirePermission(authService, "permission", "delete"), permissionHandler.DeletePermission)
+
+	// RBAC configuration routes; an import can rewrite any role, so it is admin only
+	rbac := protected.Group("/rbac", public)
+	rbac.Get("/export", behindFeature(featureFlags, features.Export, requireAllPermissions(authService, []string{"role:read", "permission:read"})), rbacHandler.ExportRBAC)
+	rbac.Post("/import", behindFeature(featureFlags, features.BulkOps, adminOnly()), heavyOps.Limit(middleware.HeavyOpBulk, 1), rbacHandler.ImportRBAC)
+
+	// Admin routes
+	admin := protected.Group("/admin", adminOnly())
+	admin.Get("/api-keys", public, apiKeyHandler.GetAPIKeys)
+	admin.Post("/api-keys", public, middleware.ReauthMiddleware(authService, config.ReauthCreateAPIKey, nil), apiKeyHandler.CreateAPIKey)
+	admin.Get("/api-keys/:id", public, apiKeyHandler.GetAPIKey)
+	admin.Delete("/api-keys/:id", public, apiKeyHandler.RevokeAPIKey)
+	admin.Get("/users/:id/api-keys", public, apiKeyHandler.GetUserAPIKeys)
+	admin.Delete("/users/:id/api-keys/:keyId", public, apiKeyHandler.RevokeUserAPIKey)
+	admin.Get("/config", public, adminHandler.GetConfig)
+	admin.Get("/events/stats", public, adminHandler.GetEventStats)
+	admin.Get("/status", public, adminHandler.GetStatus)
+	admin.Get("/routes", public, adminHandler.GetRoutes)
+	admin.Get("/permission-check", public, authHandler.CheckPermission)
+	admin.Get("/features", public, adminHandler.GetFeatures)
+	admin.Put("/features/:name", public, adminHandler.SetFeature)
+	admin.Delete("/cache/:entity", public, adminHandler.InvalidateCache)
+	admin.Delete("/cache/:entity/:id", public, adminHandler.InvalidateCacheEntity)
+	admin.Post("/permissions/reconcile", public, permissionHandler.ReconcilePermissions)
+
+	// Every route is declared, so the manifest is complete
+	adminHandler.UseRouteManifest(registry.Routes())
+	adminHandler.UseFeatureFlags(featureFlags)
+
+	return registry
 }
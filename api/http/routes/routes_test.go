@@ -0,0 +1,331 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chats/go-user-api/api/http/handlers"
+	"github.com/chats/go-user-api/api/http/middleware"
+	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/mocks"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/services"
+	"github.com/chats/go-user-api/internal/tracing"
+	"github.com/chats/go-user-api/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig() *config.Config {
+	return &config.Config{
+		JWTSecret:       "test-secret-key",
+		JWTExpireMinute: 60,
+		JaegerEndpoint:  "http://localhost:14268/api/traces",
+	}
+}
+
+func setupTestRoutes(t *testing.T, cfg *config.Config) (*fiber.App, *Registry) {
+	t.Helper()
+
+	tracer, err := tracing.NewTracer(cfg)
+	require.NoError(t, err)
+
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockUserRepo.On("GetTokensRevokedAt", mock.Anything, mock.Anything).Return(time.Time{}, nil)
+	authService := services.NewAuthService(mockUserRepo, cfg)
+
+	app := NewApp(cfg)
+	registry := SetupRoutes(app, cfg,
+		&handlers.AuthHandler{}, &handlers.UserHandler{}, &handlers.RoleHandler{}, &handlers.PermissionHandler{},
+		&handlers.APIKeyHandler{}, handlers.NewAdminHandler(cfg, nil, tracer), &handlers.RBACHandler{}, &handlers.HealthHandler{},
+		authService, nil,
+	)
+
+	return app, registry
+}
+
+// getAdminRoutes requests path with an admin token and returns the status code
+func getAdminRoutes(t *testing.T, app *fiber.App, cfg *config.Config, path string, roles []string) (int, map[string]interface{}) {
+	t.Helper()
+
+	token, _, err := utils.GenerateJWT(uuid.New(), "johndoe", roles, cfg)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(fiber.MethodGet, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+
+	var body map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return resp.StatusCode, body
+}
+
+// sendAs sends a request with a JSON body and a token carrying roles and returns the status code
+func sendAs(t *testing.T, app *fiber.App, cfg *config.Config, method, path, body string, roles []string) int {
+	t.Helper()
+
+	token, _, err := utils.GenerateJWT(uuid.New(), "johndoe", roles, cfg)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	return resp.StatusCode
+}
+
+func findRoute(routes []models.RouteInfo, method, path string) (models.RouteInfo, bool) {
+	for _, route := range routes {
+		if route.Method == method && route.Path == path {
+			return route, true
+		}
+	}
+	return models.RouteInfo{}, false
+}
+
+func TestSetupRoutes_Manifest(t *testing.T) {
+	app, registry := setupTestRoutes(t, testConfig())
+	routes := registry.Routes()
+
+	tests := []struct {
+		method string
+		path   string
+		want   models.RouteAccess
+	}{
+		{fiber.MethodGet, "/healthz", models.RouteAccess{}},
+		{fiber.MethodPost, "/api/v1/auth/login", models.RouteAccess{}},
+		{fiber.MethodPost, "/api/v1/auth/change-password", models.RouteAccess{Authenticated: true}},
+		{fiber.MethodPost, "/api/v1/auth/step-up", models.RouteAccess{Authenticated: true}},
+		{fiber.MethodPost, "/api/v1/auth/reset-password/confirm", models.RouteAccess{}},
+		{fiber.MethodGet, "/api/v1/users/", models.RouteAccess{Authenticated: true, Permissions: []string{"user:read"}}},
+		{fiber.MethodPost, "/api/v1/users/", models.RouteAccess{Authenticated: true, Permissions: []string{"user:write"}}},
+		{fiber.MethodPost, "/api/v1/users/:id/transfer-roles/:targetId", models.RouteAccess{Authenticated: true, Permissions: []string{"user:write", "role:write"}}},
+		{fiber.MethodDelete, "/api/v1/roles/:id", models.RouteAccess{Authenticated: true, Permissions: []string{"role:delete"}}},
+		{fiber.MethodDelete, "/api/v1/roles/:id/permissions", models.RouteAccess{Authenticated: true, Permissions: []string{"role:write"}}},
+		{fiber.MethodGet, "/api/v1/users/me/api-keys", models.RouteAccess{Authenticated: true}},
+		{fiber.MethodDelete, "/api/v1/users/me/api-keys/:id", models.RouteAccess{Authenticated: true}},
+		{fiber.MethodPost, "/api/v1/users/bulk-deactivate", models.RouteAccess{Authenticated: true, Roles: []string{"admin"}, Feature: "bulk_ops"}},
+		{fiber.MethodPost, "/api/v1/users/import", models.RouteAccess{Authenticated: true, Roles: []string{"admin"}, Feature: "bulk_ops"}},
+		{fiber.MethodDelete, "/api/v1/users/:id/purge", models.RouteAccess{Authenticated: true, Permissions: []string{"user:delete"}}},
+		{fiber.MethodPost, "/api/v1/users/:id/revoke-tokens", models.RouteAccess{Authenticated: true, Roles: []string{"admin"}, Self: true}},
+		{fiber.MethodPost, "/api/v1/users/:id/restore", models.RouteAccess{Authenticated: true, Permissions: []string{"user:delete", "role:write"}}},
+		{fiber.MethodPost, "/api/v1/users/:id/merge/:sourceId", models.RouteAccess{Authenticated: true, Permissions: []string{"user:write", "role:write", "user:delete"}}},
+		{fiber.MethodGet, "/api/v1/permissions/catalog", models.RouteAccess{Authenticated: true, Permissions: []string{"permission:read"}}},
+		{fiber.MethodGet, "/api/v1/users/:id/policy", models.RouteAccess{Authenticated: true, Permissions: []string{"user:read", "role:read"}}},
+		{fiber.MethodGet, "/api/v1/rbac/export", models.RouteAccess{Authenticated: true, Permissions: []string{"role:read", "permission:read"}, Feature: "export"}},
+		{fiber.MethodPost, "/api/v1/rbac/import", models.RouteAccess{Authenticated: true, Roles: []string{"admin"}, Feature: "bulk_ops"}},
+		{fiber.MethodGet, "/api/v1/admin/routes", models.RouteAccess{Authenticated: true, Roles: []string{"admin"}}},
+		{fiber.MethodGet, "/api/v1/admin/api-keys/:id", models.RouteAccess{Authenticated: true, Roles: []string{"admin"}}},
+		{fiber.MethodDelete, "/api/v1/admin/users/:id/api-keys/:keyId", models.RouteAccess{Authenticated: true, Roles: []string{"admin"}}},
+		{fiber.MethodGet, "/api/v1/admin/permission-check", models.RouteAccess{Authenticated: true, Roles: []string{"admin"}}},
+		{fiber.MethodGet, "/api/v1/admin/status", models.RouteAccess{Authenticated: true, Roles: []string{"admin"}}},
+		{fiber.MethodPut, "/api/v1/admin/features/:name", models.RouteAccess{Authenticated: true, Roles: []string{"admin"}}},
+		{fiber.MethodDelete, "/api/v1/admin/cache/:entity", models.RouteAccess{Authenticated: true, Roles: []string{"admin"}}},
+		{fiber.MethodDelete, "/api/v1/admin/cache/:entity/:id", models.RouteAccess{Authenticated: true, Roles: []string{"admin"}}},
+		{fiber.MethodPost, "/api/v1/admin/permissions/reconcile", models.RouteAccess{Authenticated: true, Roles: []string{"admin"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			route, ok := findRoute(routes, tt.method, tt.path)
+			require.True(t, ok, "route missing from manifest")
+			assert.Equal(t, tt.want.Authenticated, route.Authenticated)
+			assert.ElementsMatch(t, tt.want.Roles, route.Roles)
+			assert.ElementsMatch(t, tt.want.Permissions, route.Permissions)
+			assert.Equal(t, tt.want.Feature, route.Feature)
+		})
+	}
+
+	t.Run("Every registered route is listed", func(t *testing.T) {
+		for _, route := range app.GetRoutes(true) {
+			if route.Method == fiber.MethodHead {
+				continue
+			}
+			_, ok := findRoute(routes, route.Method, route.Path)
+			assert.True(t, ok, "%s %s missing from manifest", route.Method, route.Path)
+		}
+	})
+}
+
+func TestSetupRoutes_RoutesEndpoint(t *testing.T) {
+	cfg := testConfig()
+	app, registry := setupTestRoutes(t, cfg)
+
+	t.Run("Admin lists the routes", func(t *testing.T) {
+		status, body := getAdminRoutes(t, app, cfg, "/api/v1/admin/routes", []string{"admin"})
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.Len(t, body["data"], len(registry.Routes()))
+	})
+
+	t.Run("Non-admin is denied", func(t *testing.T) {
+		status, _ := getAdminRoutes(t, app, cfg, "/api/v1/admin/routes", []string{"viewer"})
+
+		assert.Equal(t, fiber.StatusForbidden, status)
+	})
+}
+
+func TestSetupRoutes_FeatureFlags(t *testing.T) {
+	cfg := testConfig()
+	cfg.FeatureFlags = "bulk_ops=false"
+	app, _ := setupTestRoutes(t, cfg)
+	viewer := []string{"viewer"}
+	admin := []string{"admin"}
+
+	t.Run("Flagged-off route is not found", func(t *testing.T) {
+		status := sendAs(t, app, cfg, fiber.MethodPost, "/api/v1/users/bulk-deactivate", `{}`, viewer)
+
+		// Not found rather than forbidden, so the route's existence is not revealed
+		assert.Equal(t, fiber.StatusNotFound, status)
+	})
+
+	t.Run("Unknown feature cannot be set", func(t *testing.T) {
+		status := sendAs(t, app, cfg, fiber.MethodPut, "/api/v1/admin/features/webhooks", `{"enabled": true}`, admin)
+
+		assert.Equal(t, fiber.StatusNotFound, status)
+	})
+
+	t.Run("Enabling the flag turns the route on", func(t *testing.T) {
+		status := sendAs(t, app, cfg, fiber.MethodPut, "/api/v1/admin/features/bulk_ops", `{"enabled": true}`, admin)
+		require.Equal(t, fiber.StatusOK, status)
+
+		// The route now answers, here by refusing a non-admin
+		status = sendAs(t, app, cfg, fiber.MethodPost, "/api/v1/users/bulk-deactivate", `{}`, viewer)
+		assert.Equal(t, fiber.StatusForbidden, status)
+	})
+
+	t.Run("Disabling the flag turns the route off", func(t *testing.T) {
+		status := sendAs(t, app, cfg, fiber.MethodPut, "/api/v1/admin/features/bulk_ops", `{"enabled": false}`, admin)
+		require.Equal(t, fiber.StatusOK, status)
+
+		status = sendAs(t, app, cfg, fiber.MethodPost, "/api/v1/users/bulk-deactivate", `{}`, viewer)
+		assert.Equal(t, fiber.StatusNotFound, status)
+	})
+
+	t.Run("Enabled must be given", func(t *testing.T) {
+		status := sendAs(t, app, cfg, fiber.MethodPut, "/api/v1/admin/features/bulk_ops", `{}`, admin)
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+	})
+}
+
+func TestNewApp_Routing(t *testing.T) {
+	paths := []string{"/api/v1/admin/routes/", "/api/v1/Admin/Routes"}
+
+	t.Run("Lenient by default", func(t *testing.T) {
+		cfg := testConfig()
+		app, _ := setupTestRoutes(t, cfg)
+
+		for _, path := range paths {
+			status, _ := getAdminRoutes(t, app, cfg, path, []string{"admin"})
+			assert.Equal(t, fiber.StatusOK, status, path)
+		}
+	})
+
+	t.Run("Strict and case-sensitive when configured", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.RoutingStrict = true
+		cfg.RoutingCaseSensitive = true
+		app, _ := setupTestRoutes(t, cfg)
+
+		for _, path := range paths {
+			status, _ := getAdminRoutes(t, app, cfg, path, []string{"admin"})
+			assert.Equal(t, fiber.StatusNotFound, status, path)
+		}
+	})
+}
+
+func TestNewApp_ForwardedProto(t *testing.T) {
+	// forwarded sends a request claiming to have been forwarded over HTTPS to an app rejecting plaintext
+	forwarded := func(t *testing.T, trustedProxies string) int {
+		cfg := testConfig()
+		cfg.HTTPSEnforce = "reject"
+		cfg.TrustedProxies = trustedProxies
+
+		app := NewApp(cfg)
+		app.Use(middleware.HTTPSMiddleware(cfg))
+		app.Get("/api/v1/users", func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+		req := httptest.NewRequest(fiber.MethodGet, "/api/v1/users", nil)
+		req.Header.Set(fiber.HeaderXForwardedProto, "https")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	t.Run("Spoofed header is ignored without trusted proxies", func(t *testing.T) {
+		assert.Equal(t, fiber.StatusForbidden, forwarded(t, ""))
+	})
+
+	t.Run("Header from a trusted proxy is believed", func(t *testing.T) {
+		assert.Equal(t, fiber.StatusOK, forwarded(t, "0.0.0.0"))
+	})
+}
+
+func TestSetupRoutes_PermissionOverrides(t *testing.T) {
+	t.Run("Override changes the required permissions", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.PermissionOverrides = "POST /api/v1/users/=user:create+role:write, DELETE /api/v1/roles/:id=role:remove"
+
+		tracer, err := tracing.NewTracer(cfg)
+		require.NoError(t, err)
+
+		// The caller holds the declared permissions, but not the overriding ones
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetUserPermissions", mock.Anything, mock.Anything).Return([]models.Permission{
+			{Resource: "user", Action: "write"},
+			{Resource: "role", Action: "write"},
+			{Resource: "role", Action: "delete"},
+		}, nil)
+		mockUserRepo.On("GetTokensRevokedAt", mock.Anything, mock.Anything).Return(time.Time{}, nil)
+		authService := services.NewAuthService(mockUserRepo, cfg)
+
+		app := NewApp(cfg)
+		registry := SetupRoutes(app, cfg,
+			&handlers.AuthHandler{}, &handlers.UserHandler{}, &handlers.RoleHandler{}, &handlers.PermissionHandler{},
+			&handlers.APIKeyHandler{}, handlers.NewAdminHandler(cfg, nil, tracer), &handlers.RBACHandler{}, &handlers.HealthHandler{},
+			authService, nil,
+		)
+		require.NoError(t, registry.CheckOverrides())
+
+		route, ok := findRoute(registry.Routes(), fiber.MethodPost, "/api/v1/users/")
+		require.True(t, ok)
+		assert.Equal(t, []string{"user:create", "role:write"}, route.Permissions)
+
+		route, ok = findRoute(registry.Routes(), fiber.MethodDelete, "/api/v1/roles/:id")
+		require.True(t, ok)
+		assert.Equal(t, []string{"role:remove"}, route.Permissions)
+
+		// Routes without an override keep their declared permissions
+		route, ok = findRoute(registry.Routes(), fiber.MethodPut, "/api/v1/users/:id")
+		require.True(t, ok)
+		assert.Equal(t, []string{"user:write"}, route.Permissions)
+
+		assert.Equal(t, fiber.StatusForbidden, sendAs(t, app, cfg, fiber.MethodPost, "/api/v1/users/", `{}`, []string{"editor"}))
+		assert.Equal(t, fiber.StatusForbidden, sendAs(t, app, cfg, fiber.MethodDelete, "/api/v1/roles/"+uuid.New().String(), ``, []string{"editor"}))
+	})
+
+	t.Run("Invalid overrides are reported", func(t *testing.T) {
+		cfg := testConfig()
+		// An unknown route, and a route guarded by a role rather than permissions
+		cfg.PermissionOverrides = "POST /api/v1/users=user:create,GET /api/v1/admin/routes=admin:read,GET /api/v1/users/=user:list"
+		_, registry := setupTestRoutes(t, cfg)
+
+		err := registry.CheckOverrides()
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `"GET /api/v1/admin/routes"`)
+		assert.Contains(t, err.Error(), `"POST /api/v1/users"`)
+		assert.NotContains(t, err.Error(), `"GET /api/v1/users/"`)
+	})
+}
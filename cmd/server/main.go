@@ -2,103 +2,73 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
-	"math/rand"
 	"net"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
-	"time"
 
 	"github.com/chats/go-user-api/api/grpc/pb"
 	grpcserver "github.com/chats/go-user-api/api/grpc/server"
 	"github.com/chats/go-user-api/api/http/handlers"
+	"github.com/chats/go-user-api/api/http/middleware"
 	"github.com/chats/go-user-api/api/http/routes"
 	"github.com/chats/go-user-api/config"
 	"github.com/chats/go-user-api/internal/cache"
 	"github.com/chats/go-user-api/internal/database"
+	"github.com/chats/go-user-api/internal/events"
+	"github.com/chats/go-user-api/internal/health"
+	"github.com/chats/go-user-api/internal/jobs"
 	"github.com/chats/go-user-api/internal/logger"
+	"github.com/chats/go-user-api/internal/metrics"
 	"github.com/chats/go-user-api/internal/repositories"
 	"github.com/chats/go-user-api/internal/repositories/mongodb"
 	"github.com/chats/go-user-api/internal/repositories/postgres"
 	"github.com/chats/go-user-api/internal/repositories/transaction"
+	"github.com/chats/go-user-api/internal/retry"
 	"github.com/chats/go-user-api/internal/services"
+	"github.com/chats/go-user-api/internal/shutdown"
 	"github.com/chats/go-user-api/internal/tracing"
-	"github.com/gofiber/contrib/fiberzerolog"
-	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/compress"
-	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc"
 )
 
-const (
-	serviceConnectRetries = 3
-	serviceRetryInterval  = 2 * time.Second
-	gracefulTimeout       = 15 * time.Second
-)
+// connectBackoff returns the retry policy for connecting to the database and Redis
+func connectBackoff(cfg *config.Config) retry.Backoff {
+	return retry.Backoff{
+		Attempts:   cfg.ConnectRetries,
+		Initial:    cfg.GetConnectBackoffInitial(),
+		Max:        cfg.GetConnectBackoffMax(),
+		Multiplier: cfg.ConnectBackoffMultiplier,
+	}
+}
 
 func dbConnect(cfg *config.Config) (database.Database, error) {
 	var db database.Database
-	var err error
-	backoff := time.Second
-	maxBackoff := 30 * time.Second
-	for i := 0; i < serviceConnectRetries; i++ {
+	err := connectBackoff(cfg).Do(func() (err error) {
 		db, err = database.NewDatabase(cfg)
-		if err == nil {
-			break
-		}
-
-		if i == serviceConnectRetries-1 {
-			return nil, fmt.Errorf("failed to connect after %d attempts: %w", serviceConnectRetries, err)
-		}
-
-		// Add jitter to prevent thundering herd
-		jitter := time.Duration(rand.Int63n(int64(backoff) / 2))
-		sleepTime := backoff + jitter
-		log.Warn().Err(err).Dur("retry_in", sleepTime).Int("attempt", i+1).Msg("Retrying connection")
-		time.Sleep(sleepTime)
-
-		// Exponential backoff
-		backoff = time.Duration(float64(backoff) * 1.5)
-		if backoff > maxBackoff {
-			backoff = maxBackoff
-		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect after %d attempts: %w", cfg.ConnectRetries, err)
 	}
-	return db, err
+	return db, nil
 }
 
 func redisConnect(cfg *config.Config) (*cache.RedisClient, error) {
 	var redisClient *cache.RedisClient
-	var err error
-	backoff := time.Second
-	maxBackoff := 30 * time.Second
-	for i := 0; i < serviceConnectRetries; i++ {
+	err := connectBackoff(cfg).Do(func() (err error) {
 		redisClient, err = cache.NewRedisClient(cfg)
-		if err == nil {
-			break
-		}
-
-		if i == serviceConnectRetries-1 {
-			return nil, fmt.Errorf("failed to connect after %d attempts: %w", serviceConnectRetries, err)
-		}
-
-		// Add jitter to prevent thundering herd
-		jitter := time.Duration(rand.Int63n(int64(backoff) / 2))
-		sleepTime := backoff + jitter
-		log.Warn().Err(err).Dur("retry_in", sleepTime).Int("attempt", i+1).Msg("Retrying connection")
-		time.Sleep(sleepTime)
-
-		// Exponential backoff
-		backoff = time.Duration(float64(backoff) * 1.5)
-		if backoff > maxBackoff {
-			backoff = maxBackoff
-		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect after %d attempts: %w", cfg.ConnectRetries, err)
 	}
-	return redisClient, err
+	return redisClient, nil
 }
 
 func createTxManager(cfg *config.Config, db database.Database) (transaction.Manager[transaction.Repository], error) {
@@ -121,6 +91,10 @@ func createTxManager(cfg *config.Config, db database.Database) (transaction.Mana
 }
 
 func main() {
+	check := flag.Bool("check", false, "check connectivity to the database and Redis, print a JSON report and exit")
+	configFile := flag.String("config", "", "config file of KEY=VALUE lines, overridden by environment variables (default $CONFIG_FILE, or .env)")
+	flag.Parse()
+
 	// Set up context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -131,11 +105,24 @@ func main() {
 	log.Info().Msg("Starting service...")
 
 	// Load configuration
-	cfg, err := config.LoadConfig()
+	cfg, err := config.LoadConfigFile(*configFile)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to load configuration")
 	}
 
+	// Fail fast on settings that would otherwise break deep in the stack
+	if err := cfg.Validate(); err != nil {
+		log.Fatal().Err(err).Msg("Invalid configuration")
+	}
+
+	logger.SetMaskPII(cfg.MaskPII)
+
+	// Self-check mode exits before anything is migrated or served
+	if *check {
+		cancel()
+		os.Exit(runSelfCheck(cfg))
+	}
+
 	log.Info().Str("database_type", cfg.DBType).Msg("Using database type")
 
 	// Connect to database with retries
@@ -172,6 +159,18 @@ func main() {
 		}
 	}()
 
+	// Collect request, cache and pool figures for the /admin/status indicators
+	metricsCollector := metrics.NewCollector(cfg.GetStatusWindow())
+	if redisClient != nil {
+		redisClient.UseLookupObserver(metricsCollector.ObserveCacheLookup)
+	}
+	if postgresDB, ok := db.GetImplementation().(*database.PostgresDB); ok {
+		metricsCollector.UsePool(func() metrics.PoolStats {
+			stats := postgresDB.Stats()
+			return metrics.PoolStats{InUse: stats.InUse, MaxOpen: stats.MaxOpenConnections}
+		})
+	}
+
 	// Create repository factory
 	repoFactory := repositories.NewRepositoryFactory(cfg, db, redisClient)
 
@@ -191,75 +190,202 @@ func main() {
 		log.Fatal().Err(err).Msg("Failed to create permission repository")
 	}
 
+	apiKeyRepo, err := repoFactory.CreateAPIKeyRepository()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create API key repository")
+	}
+
 	txManager, _ := createTxManager(cfg, db)
 
+	// Publish activity events in the background so requests never wait on the broker
+	eventDispatcher := events.NewDispatcher(events.LogPublisher{}, events.DispatcherConfig{
+		BufferSize:       cfg.EventBufferSize,
+		PublishTimeout:   cfg.GetEventPublishTimeout(),
+		FailureThreshold: cfg.EventBreakerFailureThreshold,
+		Cooldown:         cfg.GetEventBreakerCooldown(),
+	})
+
 	// Initialize services
 	authService := services.NewAuthService(userRepo, cfg)
-	userService := services.NewUserService(userRepo, roleRepo, txManager)
-	roleService := services.NewRoleService(roleRepo, permissionRepo, txManager)
-	permissionService := services.NewPermissionService(permissionRepo, txManager)
+	authService.UseTransactionManager(txManager)
+	userOptions, err := services.UserServiceOptionsFromConfig(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid user service configuration")
+	}
+	userOptions.Events = eventDispatcher
+	userService := services.NewUserService(userRepo, roleRepo, txManager, userOptions)
+	roleService := services.NewRoleService(roleRepo, permissionRepo, txManager, services.RoleServiceOptionsFromConfig(cfg))
+	if cfg.HasInitialAdmin() {
+		created, err := userService.SeedInitialAdmin(ctx, cfg.InitialAdminUsername, cfg.InitialAdminEmail, cfg.InitialAdminPassword)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to seed initial admin")
+		}
+		if !created {
+			log.Info().Msg("Skipped seeding initial admin, an active admin already exists")
+		}
+	}
+	roleService.UseUserRepository(userRepo)
+	permissionService := services.NewPermissionService(permissionRepo, txManager, cfg)
+	if cfg.PermissionReconcileOnStartup {
+		result, err := permissionService.ReconcilePermissions(ctx, false)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to reconcile built-in permissions")
+		}
+		log.Info().Int("created", len(result.Created)).Msg("Reconciled built-in permissions")
+	}
+	rbacService := services.NewRBACService(roleRepo, permissionRepo, txManager, cfg)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo)
+	inactivityLockService := services.NewInactivityLockService(userRepo, txManager, eventDispatcher, cfg)
+
+	// Resolve permissions from token roles against a cached snapshot if enabled
+	var permissionSnapshot *services.RolePermissionSnapshot
+	if cfg.PermissionSnapshotEnabled {
+		permissionSnapshot = services.NewRolePermissionSnapshot(roleRepo, cfg.GetPermissionSnapshotMaxAge())
+		authService.UsePermissionSnapshot(permissionSnapshot)
+		roleService.UsePermissionSnapshot(permissionSnapshot)
+		permissionService.UsePermissionSnapshot(permissionSnapshot)
+		rbacService.UsePermissionSnapshot(permissionSnapshot)
+	}
+	authService.UsePermissionRepository(permissionRepo)
+
+	// Verify challenge tokens required after repeated failed logins
+	switch cfg.LoginChallengeProvider {
+	case "hcaptcha":
+		authService.UseChallengeVerifier(services.NewHCaptchaVerifier(cfg.LoginChallengeSecret, ""))
+	case "none", "":
+	default:
+		log.Fatal().Str("provider", cfg.LoginChallengeProvider).Msg("Unknown login challenge provider")
+	}
+
+	// Deliver password reset tokens from a background job queue
+	jobQueue := jobs.NewMemoryQueue(cfg.JobBufferSize, map[string]jobs.Handler{
+		jobs.TypePasswordReset: jobs.PasswordResetHandler(userRepo, jobs.LogNotifier{}),
+	})
+	authService.UsePasswordResetQueue(jobQueue)
 
 	// Initialize HTTP handlers
 	authHandler := handlers.NewAuthHandler(authService, userService, tracer)
 	userHandler := handlers.NewUserHandler(userService, tracer)
 	roleHandler := handlers.NewRoleHandler(roleService, tracer)
 	permissionHandler := handlers.NewPermissionHandler(permissionService, tracer)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService, tracer)
+	adminHandler := handlers.NewAdminHandler(cfg, eventDispatcher, tracer)
+	rbacHandler := handlers.NewRBACHandler(rbacService, tracer)
+	adminHandler.UseMetrics(metricsCollector)
+
+	if redisClient != nil {
+		cacheInvalidator := repoFactory.CreateCacheInvalidator()
+		adminHandler.UseCacheService(services.NewCacheService(cacheInvalidator))
+		rbacService.UseCacheInvalidator(cacheInvalidator)
+	}
+
+	// Track dependency health so handlers can report degraded subsystems
+	statusRegistry := health.NewRegistry()
+	statusRegistry.Describe(health.DependencyMessaging, func() map[string]interface{} {
+		details := map[string]interface{}{}
+		if publishedAt := eventDispatcher.LastPublishedAt(); publishedAt != nil {
+			details["last_published_at"] = publishedAt
+		}
+		return details
+	})
+	healthHandler := handlers.NewHealthHandler(statusRegistry)
 
 	// Initialize gRPC server
 	userGRPCServer := grpcserver.NewUserGRPCServer(userService, authService, tracer, cfg)
 
 	// Create Fiber app
-	app := fiber.New(fiber.Config{
-		AppName:               cfg.AppName,
-		DisableStartupMessage: true,
-		ReadTimeout:           30 * time.Second,
-		WriteTimeout:          30 * time.Second,
-		IdleTimeout:           60 * time.Second,
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			code := fiber.StatusInternalServerError
-
-			// Check for specific error types and set appropriate status codes
-			if err == fiber.ErrBadRequest {
-				code = fiber.StatusBadRequest
-			} else if err == fiber.ErrNotFound {
-				code = fiber.StatusNotFound
-			} else if err == fiber.ErrMethodNotAllowed {
-				code = fiber.StatusMethodNotAllowed
-			}
-
-			return c.Status(code).JSON(fiber.Map{
-				"success": false,
-				"message": err.Error(),
-			})
-		},
-	})
+	app := routes.NewApp(cfg)
 
 	// Set up middleware
-	app.Use(fiberzerolog.New(fiberzerolog.Config{
-		Logger: &log.Logger,
-	}))
+	app.Use(middleware.AccessLogMiddleware(cfg, &log.Logger))
+	app.Use(middleware.MetricsMiddleware(metricsCollector))
 	app.Use(recover.New())
 	app.Use(requestid.New())
-	app.Use(compress.New(compress.Config{
-		Level: compress.LevelBestSpeed,
-	}))
+	app.Use(middleware.HTTPSMiddleware(cfg))
+	app.Use(middleware.CompressionMiddleware(cfg))
 
 	// CORS configuration with specific origins
-	app.Use(cors.New(cors.Config{
-		AllowOrigins:     cfg.CorsAllowOrigins,
-		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, X-Request-ID",
-		ExposeHeaders:    "Content-Length, Content-Type",
-		AllowCredentials: true,
-		MaxAge:           86400,
-	}))
+	app.Use(middleware.CORSMiddleware(cfg))
+	app.Use(middleware.DegradedMiddleware(statusRegistry))
+	app.Use(middleware.ContentNegotiationMiddleware(cfg))
 
 	// Set up routes
-	routes.SetupRoutes(app, cfg, authHandler, userHandler, roleHandler, permissionHandler, authService)
+	registry := routes.SetupRoutes(app, cfg, authHandler, userHandler, roleHandler, permissionHandler, apiKeyHandler, adminHandler, rbacHandler, healthHandler, authService, apiKeyService)
+	if err := registry.CheckOverrides(); err != nil {
+		log.Fatal().Err(err).Msg("Invalid permission overrides")
+	}
 
 	// Create an explicit gRPC server variable for proper shutdown
 	var grpcServer *grpc.Server
 
+	// Start background workers
+	go inactivityLockService.Start(ctx)
+	healthChecks := map[string]health.CheckFunc{
+		health.DependencyDatabase:  db.Ping,
+		health.DependencyMessaging: eventDispatcher.Check,
+	}
+	// Without Redis at startup the service runs uncached for its lifetime, which is not a degradation
+	if redisClient != nil && redisClient.IsEnabled() {
+		healthChecks[health.DependencyCache] = redisClient.Ping
+	}
+	go statusRegistry.Watch(ctx, cfg.GetHealthCheckInterval(), healthChecks)
+	if permissionSnapshot != nil {
+		go permissionSnapshot.Start(ctx)
+	}
+
+	// Warm the cache in the background so startup is not blocked
+	if cfg.CacheWarmEnabled && redisClient != nil && redisClient.IsEnabled() {
+		cacheWarmer := services.NewCacheWarmer(userRepo, roleRepo, permissionRepo, cfg)
+		go func() {
+			if err := cacheWarmer.Warm(ctx); err != nil {
+				log.Warn().Err(err).Msg("Cache warming finished with errors")
+			}
+		}()
+	}
+
+	// Migrations have been applied and dependencies connected, so /readyz may report ready
+	// as soon as the servers start listening
+	statusRegistry.SetReady(true)
+
+	// Components are stopped in the configured order on shutdown
+	shutdownOrder, err := cfg.GetShutdownStages()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid shutdown stages")
+	}
+	shutdownPipeline, err := shutdown.NewPipeline([]shutdown.Stage{
+		{
+			Name: "grpc",
+			Stop: func(ctx context.Context) error {
+				if grpcServer != nil {
+					grpcServer.GracefulStop()
+				}
+				return nil
+			},
+			Force: func() {
+				if grpcServer != nil {
+					grpcServer.Stop()
+				}
+			},
+		},
+		{
+			Name: "http",
+			Stop: app.ShutdownWithContext,
+		},
+		{
+			// Flush events emitted while the servers drained
+			Name: "events",
+			Stop: eventDispatcher.Close,
+		},
+		{
+			// Run the jobs queued before the servers stopped
+			Name: "jobs",
+			Stop: jobQueue.Close,
+		},
+	}, shutdownOrder)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid shutdown stages")
+	}
+
 	// Set up signal handling for graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
@@ -304,6 +430,7 @@ func main() {
 		grpcServer = grpc.NewServer(
 			grpc.MaxConcurrentStreams(100),
 			grpc.MaxRecvMsgSize(4*1024*1024), // 4MB
+			grpc.UnaryInterceptor(grpcserver.AuthInterceptor(authService)),
 		)
 		pb.RegisterUserServiceServer(grpcServer, userGRPCServer)
 
@@ -317,66 +444,14 @@ func main() {
 	<-quit
 	log.Info().Msg("Received shutdown signal, initiating graceful shutdown...")
 
-	// Create a timeout context for graceful shutdown
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), gracefulTimeout)
-	defer shutdownCancel()
+	// Stop load balancers from routing new traffic here while draining
+	statusRegistry.SetReady(false)
 
 	// Cancel main context to stop background workers
 	cancel()
 
-	// Shutdown HTTP server (with timeout)
-	httpShutdownDone := make(chan struct{})
-	go func() {
-		log.Info().Msg("Shutting down HTTP server...")
-		// Handle different versions of Fiber
-		shutdownErr := app.Shutdown()
-		if shutdownErr != nil {
-			log.Error().Err(shutdownErr).Msg("HTTP server shutdown error")
-		} else {
-			log.Info().Msg("HTTP server shutdown complete")
-		}
-		close(httpShutdownDone)
-	}()
-
-	// Gracefully stop gRPC server
-	grpcShutdownDone := make(chan struct{})
-	go func() {
-		if grpcServer != nil {
-			log.Info().Msg("Shutting down gRPC server...")
-
-			// Use a separate goroutine to handle the graceful stop timeout
-			done := make(chan struct{})
-			go func() {
-				grpcServer.GracefulStop()
-				close(done)
-			}()
-
-			// Wait for either graceful stop to complete or context to timeout
-			select {
-			case <-done:
-				log.Info().Msg("gRPC server graceful shutdown complete")
-			case <-shutdownCtx.Done():
-				log.Warn().Msg("gRPC server graceful shutdown timed out, forcing stop")
-				grpcServer.Stop()
-			}
-		}
-		close(grpcShutdownDone)
-	}()
-
-	// Wait for all components to shut down with timeout handling
-	select {
-	case <-httpShutdownDone:
-		log.Debug().Msg("HTTP server shutdown done")
-	case <-shutdownCtx.Done():
-		log.Warn().Msg("HTTP server shutdown timed out")
-	}
-
-	select {
-	case <-grpcShutdownDone:
-		log.Debug().Msg("gRPC server shutdown done")
-	case <-shutdownCtx.Done():
-		log.Warn().Msg("gRPC server shutdown timed out")
-	}
+	// Drain each component in turn so in-flight requests finish before their dependencies stop
+	shutdownPipeline.Run()
 
 	// Final cleanup and exit
 	log.Info().Msg("All components shut down, service stopped")
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/health"
+)
+
+// selfCheckTimeout leaves room for the connection retries and their backoff
+const selfCheckTimeout = 30 * time.Second
+
+// runSelfCheck connects to each dependency with the usual retries, pings it, prints the
+// report as JSON and returns the process exit code. Nothing is migrated or served.
+func runSelfCheck(cfg *config.Config) int {
+	probes := []health.Probe{
+		{
+			Name:     health.DependencyDatabase,
+			Critical: true,
+			Run: func(ctx context.Context) error {
+				db, err := dbConnect(cfg)
+				if err != nil {
+					return err
+				}
+				defer db.Close()
+
+				return db.Ping(ctx)
+			},
+		},
+		{
+			// The service runs without caching when Redis is down
+			Name: health.DependencyCache,
+			Run: func(ctx context.Context) error {
+				redisClient, err := redisConnect(cfg)
+				if err != nil {
+					return err
+				}
+				defer redisClient.Close()
+
+				return redisClient.Ping(ctx)
+			},
+		},
+	}
+
+	report := health.SelfCheck(context.Background(), probes, selfCheckTimeout)
+
+	output, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode self-check report: %v\n", err)
+		return 1
+	}
+	fmt.Println(string(output))
+
+	if !report.Passed {
+		return 1
+	}
+	return 0
+}
@@ -1,15 +1,59 @@
 package config
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/joho/godotenv"
 	"github.com/rs/zerolog/log"
 )
 
+// MinJWTSecretLength is the shortest JWT_SECRET accepted by Validate
+const MinJWTSecretLength = 16
+
+// DefaultConfigFile is read by LoadConfig when no config file is named
+const DefaultConfigFile = ".env"
+
+// Sources of a setting, from lowest to highest precedence
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+)
+
+// Sensitive actions REAUTH_ACTIONS can name
+const (
+	ReauthDeleteUser    = "delete_user"
+	ReauthResetPassword = "reset_password"
+	ReauthChangeEmail   = "change_email"
+	ReauthCreateAPIKey  = "create_api_key"
+)
+
+// How MONGODB_TRANSACTIONS has multi-document writes use transactions
+const (
+	MongoTransactionsAuto     = "auto"
+	MongoTransactionsRequired = "required"
+	MongoTransactionsOff      = "off"
+)
+
+// ReauthActions lists every action REAUTH_ACTIONS can name
+var ReauthActions = []string{ReauthDeleteUser, ReauthResetPassword, ReauthChangeEmail, ReauthCreateAPIKey}
+
+// DBSSLModes lists the PostgreSQL sslmode values the driver supports: disable sends plaintext,
+// require encrypts without verifying the server, verify-ca checks its certificate and verify-full
+// its host name too
+var DBSSLModes = []string{"disable", "require", "verify-ca", "verify-full"}
+
 type Config struct {
 	AppName          string
 	AppEnv           string
@@ -18,6 +62,33 @@ type Config struct {
 	CorsAllowOrigins string
 	LogLevel         string
 
+	// Mask emails and usernames in logs and activity events
+	MaskPII bool
+
+	// Components stopped on shutdown, in order, with the seconds each may take to drain
+	// ("name=seconds", comma-separated)
+	ShutdownStages string
+
+	// Access logs: whether request headers and JSON bodies are logged, and the headers and the body
+	// fields or query parameters whose values are always redacted, comma-separated
+	AccessLogHeaders       bool
+	AccessLogBodies        bool
+	AccessLogRedactHeaders string
+	AccessLogRedactFields  string
+
+	// CORS
+	CorsAllowMethods     string
+	CorsAllowHeaders     string
+	CorsExposeHeaders    string
+	CorsAllowCredentials bool
+	CorsMaxAge           int
+
+	// Plaintext HTTP requests are redirected to HTTPS ("redirect"), rejected ("reject") or served
+	// ("off"). X-Forwarded-Proto is believed only from the trusted proxies, comma-separated IPs or
+	// CIDR ranges (empty believes no forwarded headers).
+	HTTPSEnforce   string
+	TrustedProxies string
+
 	// Database type (postgres or mongodb)
 	DBType string
 
@@ -26,107 +97,597 @@ type Config struct {
 	DBPort     string
 	DBName     string
 	DBUser     string
-	DBPassword string
+	DBPassword string `redact:"true"`
 	DBSSLMode  string
 
+	// CA certificate the server is verified against when DBSSLMode is not disable (empty uses the
+	// system roots for verify-ca and verify-full)
+	DBSSLRootCert string
+
+	// Slow query logging threshold in milliseconds (0 disables)
+	SlowQueryThresholdMs int
+
 	// MongoDB
 	MongoDBHost     string
 	MongoDBPort     string
 	MongoDBName     string
 	MongoDBUser     string
-	MongoDBPassword string
+	MongoDBPassword string `redact:"true"`
 	MongoDBAuthDB   string
 
+	// Connect over TLS, verifying the server against MongoDBTLSCAFile (empty uses the system roots)
+	MongoDBTLS       bool
+	MongoDBTLSCAFile string
+
+	// Read preference (primary, primaryPreferred, secondary, secondaryPreferred or nearest), and the
+	// write concern of ordinary and of critical writes such as user creation ("majority" or a number
+	// of acknowledging nodes; empty keeps the server default)
+	MongoDBReadPreference       string
+	MongoDBWriteConcern         string
+	MongoDBCriticalWriteConcern string
+
+	// MongoDBTransactions is how multi-document writes use transactions, which need a replica set:
+	// required (the default) refuses to start without transaction support, auto falls back to running
+	// the writes one after another on a standalone server, and off never uses transactions. Without
+	// transactions, changes checked after writing and request transactions are refused.
+	MongoDBTransactions string
+
 	// JWT
-	JWTSecret       string
+	JWTSecret       string `redact:"true"`
 	JWTExpireMinute int
+	// JWTRefreshExpireMinute is the refresh token lifetime; 0 issues no refresh tokens
+	JWTRefreshExpireMinute int
+	// JWTMaxRoles caps the roles embedded in an access token; a user with more gets a token without
+	// them, and checks look the roles up instead. 0 embeds all roles
+	JWTMaxRoles int
+	// PasswordResetTokenMinute is the lifetime of the tokens sent to users whose password an admin reset
+	PasswordResetTokenMinute int
+
+	// Reject tokens issued before the user's roles last changed
+	TokenRejectStaleRoles bool
+
+	// Sensitive actions requiring the user to have entered their password within the window,
+	// comma-separated (empty disables)
+	ReauthActions       string
+	ReauthMaxAgeMinutes int
 
 	// Redis
 	RedisHost     string
 	RedisPort     string
-	RedisPassword string
+	RedisPassword string `redact:"true"`
 	RedisDB       int
 	RedisCacheTTL int
+	// RedisOpTimeoutMs bounds each cache operation, retries included, so a hung Redis
+	// falls through to the database instead of stalling the request
+	RedisOpTimeoutMs int
+	RedisMaxRetries  int
+	// Pattern invalidation scans this many keys per SCAN and deletes them this many per UNLINK,
+	// optionally in the background so writes do not wait for it
+	RedisScanCount         int
+	RedisDeleteBatchSize   int
+	RedisAsyncInvalidation bool
 
 	// Tracing
 	JaegerEndpoint string
+
+	// Dependency health checks feeding /healthz and the X-Degraded header
+	HealthCheckIntervalSeconds int
+
+	// Connecting to the database and Redis at startup is tried this many times, waiting the initial
+	// backoff and then multiplier times longer, up to the maximum, between tries (plus jitter)
+	ConnectRetries           int
+	ConnectBackoffInitialMs  int
+	ConnectBackoffMaxMs      int
+	ConnectBackoffMultiplier float64
+
+	// Thresholds classifying the indicators at /admin/status as WARN or CRITICAL over the
+	// trailing window; the cache hit ratio alerts when low, the others when high
+	StatusWindowSeconds            int
+	StatusErrorRateWarn            float64
+	StatusErrorRateCritical        float64
+	StatusLatencyP99WarnMs         int
+	StatusLatencyP99CriticalMs     int
+	StatusCacheHitRatioWarn        float64
+	StatusCacheHitRatioCritical    float64
+	StatusDBPoolSaturationWarn     float64
+	StatusDBPoolSaturationCritical float64
+
+	// Routing; by default paths match regardless of case and trailing slash
+	RoutingStrict        bool
+	RoutingCaseSensitive bool
+
+	// Serve MessagePack to clients that ask for it in the Accept header
+	ResponseMsgpackEnabled bool
+
+	// Response compression: the level (-1 disabled, 0 default, 1 best speed, 2 best compression),
+	// the smallest body worth compressing and the compressible content types, comma-separated,
+	// where an entry ending in "/" such as "text/" matches the whole type
+	CompressLevel        int
+	CompressMinSize      int
+	CompressContentTypes string
+
+	// Resolve permissions from JWT roles against a cached snapshot
+	PermissionSnapshotEnabled       bool
+	PermissionSnapshotMaxAgeSeconds int
+
+	// Require permission names to be "resource:action"
+	PermissionNameEnforce bool
+
+	// Report checks against permissions that do not exist instead of plain denials
+	PermissionCheckStrict bool
+
+	// Create resource:action permissions that an RBAC import assigns to roles but does not define
+	AutoCreateMissingPermissions bool
+
+	// Create the built-in permissions missing from the catalog at startup
+	PermissionReconcileOnStartup bool
+
+	// Maximum role or permission IDs accepted in one request (0 disables the cap)
+	IDListLimit int
+
+	// Reject deleting, deactivating or demoting the last active admin
+	LastAdminProtection bool
+
+	// First admin created at startup when no active admin exists, replacing the default
+	// admin/adminpassword account (all empty keeps the default)
+	InitialAdminUsername string
+	InitialAdminEmail    string
+	InitialAdminPassword string `redact:"true"`
+
+	// Reject granting roles or role permissions the acting caller does not hold
+	DenyPrivilegeEscalation bool
+
+	// Order of the user list when no sort_by is given, as "field" or "field:asc|desc"
+	// (empty keeps newest first)
+	UserDefaultSort string
+
+	// Return user list pages whose users' roles could not all be loaded, with warnings,
+	// instead of failing them
+	ListPartialResults bool
+
+	// Concurrent requests allowed per heavy operation class, as "class=N" pairs,
+	// and how long an excess request waits for a slot before it is rejected
+	HeavyOpLimits         string
+	HeavyOpQueueTimeoutMs int
+
+	// Features switched on or off, as "name=true|false" pairs; unlisted ones stay on
+	FeatureFlags string
+
+	// Permissions replacing those a route declares, as "METHOD /path=resource:action" entries
+	// with several permissions joined by + (empty keeps the declared permissions)
+	PermissionOverrides string
+
+	// Preload hot entities into the cache at startup
+	CacheWarmEnabled     bool
+	CacheWarmTargets     string
+	CacheWarmRecentUsers int
+
+	// Login challenge (captcha) after repeated failures (0 threshold disables)
+	LoginChallengeThreshold     int
+	LoginChallengeWindowMinutes int
+	LoginChallengeProvider      string
+	LoginChallengeSecret        string `redact:"true"`
+
+	// Inactivity auto-lock (0 days disables)
+	InactivityLockDays            int
+	InactivityLockIntervalMinutes int
+	InactivityLockExemptRoles     string
+
+	// Password expiry; an expired password can only be changed (0 days disables)
+	PasswordMaxAgeDays int
+
+	// Server-side secrets HMAC-combined with passwords before hashing, comma-separated and newest
+	// first; the newest hashes, all verify (empty disables)
+	PasswordPepper string `redact:"true"`
+
+	// Roles granted to new users by email domain, as domain=role pairs; repeat a domain to grant
+	// several roles (empty disables)
+	DomainRoles string
+
+	// Whether new users start active, and the permission a caller needs to choose is_active on
+	// creation instead (false holds new accounts for approval)
+	DefaultUserActive            bool
+	UserActiveOverridePermission string
+
+	// Soft-delete users on DELETE, keeping them and their role assignments restorable, and the roles
+	// restored users get instead of their prior ones, comma-separated (empty keeps the prior roles)
+	UserSoftDelete   bool
+	UserRestoreRoles string
+
+	// Times a user just created or updated is read again when the read misses it, as a read replica
+	// that has not caught up yet would, and the delay between reads (0 retries disables)
+	ReadAfterWriteRetries      int
+	ReadAfterWriteRetryDelayMs int
+
+	// Activity events are published from a bounded buffer; a circuit breaker drops them
+	// while the broker keeps failing (0 threshold disables the breaker)
+	EventBufferSize              int
+	EventPublishTimeoutMs        int
+	EventBreakerFailureThreshold int
+	EventBreakerCooldownSeconds  int
+
+	// Background jobs such as password reset delivery run from a bounded buffer
+	JobBufferSize int
+
+	// sources records where each setting came from, keyed by environment variable name
+	sources map[string]string
 }
 
+// LoadConfig loads the configuration with LoadConfigFile, reading the config file named by CONFIG_FILE
+// or, when that is unset, .env if it exists
 func LoadConfig() (*Config, error) {
-	err := godotenv.Load()
+	return LoadConfigFile("")
+}
+
+// LoadConfigFile loads the configuration from three sources: environment variables override the
+// config file, which overrides the built-in defaults. The file holds KEY=VALUE lines named like the
+// environment variables. An empty path falls back to CONFIG_FILE, then to .env; only a file that was
+// named explicitly must exist.
+func LoadConfigFile(path string) (*Config, error) {
+	path = cmp.Or(path, os.Getenv("CONFIG_FILE"))
+	explicit := path != ""
+	if !explicit {
+		path = DefaultConfigFile
+	}
+
+	file, err := godotenv.Read(path)
 	if err != nil {
+		if explicit {
+			return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
 		log.Warn().Msg("Warning: .env file not found")
+		file = map[string]string{}
 	}
+	l := &loader{file: file, sources: make(map[string]string)}
+
+	redisDB, _ := strconv.Atoi(l.get("REDIS_DB", "0"))
+	redisCacheTTL, _ := strconv.Atoi(l.get("REDIS_CACHE_TTL", "3600"))
+	redisOpTimeoutMs, _ := strconv.Atoi(l.get("REDIS_OP_TIMEOUT_MS", "100"))
+	redisMaxRetries, _ := strconv.Atoi(l.get("REDIS_MAX_RETRIES", "1"))
+	redisScanCount, _ := strconv.Atoi(l.get("REDIS_SCAN_COUNT", "500"))
+	redisDeleteBatchSize, _ := strconv.Atoi(l.get("REDIS_DELETE_BATCH_SIZE", "500"))
+	redisAsyncInvalidation, _ := strconv.ParseBool(l.get("REDIS_ASYNC_INVALIDATION", "false"))
+	mongoDBTLS, _ := strconv.ParseBool(l.get("MONGODB_TLS", "false"))
+	jwtExpireMinute, _ := strconv.Atoi(l.get("JWT_EXPIRE_MINUTES", "60"))
+	jwtRefreshExpireMinute, _ := strconv.Atoi(l.get("JWT_REFRESH_EXPIRE_MINUTES", "0"))
+	jwtMaxRoles, _ := strconv.Atoi(l.get("JWT_MAX_ROLES", "0"))
+	reauthMaxAgeMinutes, _ := strconv.Atoi(l.get("REAUTH_MAX_AGE_MINUTES", "15"))
+	passwordResetTokenMinute, _ := strconv.Atoi(l.get("PASSWORD_RESET_TOKEN_MINUTES", "60"))
+	maskPII, _ := strconv.ParseBool(l.get("MASK_PII", "false"))
+	accessLogHeaders, _ := strconv.ParseBool(l.get("ACCESS_LOG_HEADERS", "false"))
+	accessLogBodies, _ := strconv.ParseBool(l.get("ACCESS_LOG_BODIES", "false"))
+	slowQueryThresholdMs, _ := strconv.Atoi(l.get("SLOW_QUERY_THRESHOLD_MS", "200"))
+	inactivityLockDays, _ := strconv.Atoi(l.get("INACTIVITY_LOCK_DAYS", "0"))
+	inactivityLockIntervalMinutes, _ := strconv.Atoi(l.get("INACTIVITY_LOCK_INTERVAL_MINUTES", "60"))
+	passwordMaxAgeDays, _ := strconv.Atoi(l.get("PASSWORD_MAX_AGE_DAYS", "0"))
+	eventBufferSize, _ := strconv.Atoi(l.get("EVENT_BUFFER_SIZE", "1000"))
+	jobBufferSize, _ := strconv.Atoi(l.get("JOB_BUFFER_SIZE", "100"))
+	eventPublishTimeoutMs, _ := strconv.Atoi(l.get("EVENT_PUBLISH_TIMEOUT_MS", "2000"))
+	eventBreakerFailureThreshold, _ := strconv.Atoi(l.get("EVENT_BREAKER_FAILURE_THRESHOLD", "5"))
+	eventBreakerCooldownSeconds, _ := strconv.Atoi(l.get("EVENT_BREAKER_COOLDOWN_SECONDS", "30"))
+	permissionSnapshotEnabled, _ := strconv.ParseBool(l.get("PERMISSION_SNAPSHOT_ENABLED", "false"))
+	permissionSnapshotMaxAgeSeconds, _ := strconv.Atoi(l.get("PERMISSION_SNAPSHOT_MAX_AGE_SECONDS", "60"))
+	permissionNameEnforce, _ := strconv.ParseBool(l.get("PERMISSION_NAME_ENFORCE", "true"))
+	listPartialResults, _ := strconv.ParseBool(l.get("LIST_PARTIAL_RESULTS", "false"))
+	permissionCheckStrict, _ := strconv.ParseBool(l.get("PERMISSION_CHECK_STRICT", "false"))
+	autoCreateMissingPermissions, _ := strconv.ParseBool(l.get("PERMISSION_AUTO_CREATE_MISSING", "false"))
+	permissionReconcileOnStartup, _ := strconv.ParseBool(l.get("PERMISSION_RECONCILE_ON_STARTUP", "false"))
+	idListLimit, _ := strconv.Atoi(l.get("ID_LIST_LIMIT", "100"))
+	lastAdminProtection, _ := strconv.ParseBool(l.get("LAST_ADMIN_PROTECTION", "true"))
+	denyPrivilegeEscalation, _ := strconv.ParseBool(l.get("DENY_PRIVILEGE_ESCALATION", "false"))
+	defaultUserActive, _ := strconv.ParseBool(l.get("DEFAULT_USER_ACTIVE", "true"))
+	userSoftDelete, _ := strconv.ParseBool(l.get("USER_SOFT_DELETE", "false"))
+	readAfterWriteRetries, _ := strconv.Atoi(l.get("READ_AFTER_WRITE_RETRIES", "2"))
+	readAfterWriteRetryDelayMs, _ := strconv.Atoi(l.get("READ_AFTER_WRITE_RETRY_DELAY_MS", "50"))
+	cacheWarmEnabled, _ := strconv.ParseBool(l.get("CACHE_WARM_ENABLED", "false"))
+	cacheWarmRecentUsers, _ := strconv.Atoi(l.get("CACHE_WARM_RECENT_USERS", "100"))
+	loginChallengeThreshold, _ := strconv.Atoi(l.get("LOGIN_CHALLENGE_THRESHOLD", "0"))
+	loginChallengeWindowMinutes, _ := strconv.Atoi(l.get("LOGIN_CHALLENGE_WINDOW_MINUTES", "15"))
+	corsAllowCredentials, _ := strconv.ParseBool(l.get("CORS_ALLOW_CREDENTIALS", "true"))
+	corsMaxAge, _ := strconv.Atoi(l.get("CORS_MAX_AGE", "86400"))
+	tokenRejectStaleRoles, _ := strconv.ParseBool(l.get("TOKEN_REJECT_STALE_ROLES", "false"))
+	healthCheckIntervalSeconds, _ := strconv.Atoi(l.get("HEALTH_CHECK_INTERVAL_SECONDS", "15"))
+	connectRetries, _ := strconv.Atoi(l.get("CONNECT_RETRIES", "3"))
+	connectBackoffInitialMs, _ := strconv.Atoi(l.get("CONNECT_BACKOFF_INITIAL_MS", "1000"))
+	connectBackoffMaxMs, _ := strconv.Atoi(l.get("CONNECT_BACKOFF_MAX_MS", "30000"))
+	connectBackoffMultiplier, _ := strconv.ParseFloat(l.get("CONNECT_BACKOFF_MULTIPLIER", "1.5"), 64)
+	statusWindowSeconds, _ := strconv.Atoi(l.get("STATUS_WINDOW_SECONDS", "300"))
+	statusErrorRateWarn, _ := strconv.ParseFloat(l.get("STATUS_ERROR_RATE_WARN", "0.01"), 64)
+	statusErrorRateCritical, _ := strconv.ParseFloat(l.get("STATUS_ERROR_RATE_CRITICAL", "0.05"), 64)
+	statusLatencyP99WarnMs, _ := strconv.Atoi(l.get("STATUS_LATENCY_P99_WARN_MS", "500"))
+	statusLatencyP99CriticalMs, _ := strconv.Atoi(l.get("STATUS_LATENCY_P99_CRITICAL_MS", "2000"))
+	statusCacheHitRatioWarn, _ := strconv.ParseFloat(l.get("STATUS_CACHE_HIT_RATIO_WARN", "0.8"), 64)
+	statusCacheHitRatioCritical, _ := strconv.ParseFloat(l.get("STATUS_CACHE_HIT_RATIO_CRITICAL", "0.5"), 64)
+	statusDBPoolSaturationWarn, _ := strconv.ParseFloat(l.get("STATUS_DB_POOL_SATURATION_WARN", "0.75"), 64)
+	statusDBPoolSaturationCritical, _ := strconv.ParseFloat(l.get("STATUS_DB_POOL_SATURATION_CRITICAL", "0.95"), 64)
+	routingStrict, _ := strconv.ParseBool(l.get("ROUTING_STRICT", "false"))
+	routingCaseSensitive, _ := strconv.ParseBool(l.get("ROUTING_CASE_SENSITIVE", "false"))
+	responseMsgpackEnabled, _ := strconv.ParseBool(l.get("RESPONSE_MSGPACK_ENABLED", "true"))
+	compressLevel, _ := strconv.Atoi(l.get("COMPRESS_LEVEL", "1"))
+	compressMinSize, _ := strconv.Atoi(l.get("COMPRESS_MIN_SIZE", "1024"))
+	heavyOpQueueTimeoutMs, _ := strconv.Atoi(l.get("HEAVY_OP_QUEUE_TIMEOUT_MS", "500"))
+
+	cfg := &Config{
+		AppName:          l.get("APP_NAME", "user-api"),
+		AppEnv:           l.get("APP_ENV", "development"),
+		ServerPort:       l.get("SERVER_PORT", "8080"),
+		GrpcPort:         l.get("GRPC_PORT", "50051"),
+		CorsAllowOrigins: l.get("CORS_ALLOW_ORIGINS", "http://localhost:3000,http://localhost:8080"),
+		LogLevel:         l.get("LOG_LEVEL", "debug"),
+		MaskPII:          maskPII,
+		ShutdownStages:   l.get("SHUTDOWN_STAGES", "grpc=10,http=10,events=5,jobs=5"),
+
+		// Access logs
+		AccessLogHeaders:       accessLogHeaders,
+		AccessLogBodies:        accessLogBodies,
+		AccessLogRedactHeaders: l.get("ACCESS_LOG_REDACT_HEADERS", "Authorization,Cookie,Set-Cookie,X-API-Key,X-Current-Password"),
+		AccessLogRedactFields:  l.get("ACCESS_LOG_REDACT_FIELDS", "password,current_password,new_password,access_token,refresh_token,token,key,secret,captcha_token"),
 
-	redisDB, _ := strconv.Atoi(getEnv("REDIS_DB", "0"))
-	redisCacheTTL, _ := strconv.Atoi(getEnv("REDIS_CACHE_TTL", "3600"))
-	jwtExpireMinute, _ := strconv.Atoi(getEnv("JWT_EXPIRE_MINUTES", "60"))
+		// CORS
+		CorsAllowMethods:     l.get("CORS_ALLOW_METHODS", "GET,POST,PUT,DELETE,OPTIONS"),
+		CorsAllowHeaders:     l.get("CORS_ALLOW_HEADERS", "Origin, Content-Type, Accept, Authorization, X-Request-ID, X-API-Key, X-Current-Password"),
+		CorsExposeHeaders:    l.get("CORS_EXPOSE_HEADERS", "Content-Length, Content-Type, Location, X-Degraded"),
+		CorsAllowCredentials: corsAllowCredentials,
+		CorsMaxAge:           corsMaxAge,
 
-	return &Config{
-		AppName:          getEnv("APP_NAME", "user-api"),
-		AppEnv:           getEnv("APP_ENV", "development"),
-		ServerPort:       getEnv("SERVER_PORT", "8080"),
-		GrpcPort:         getEnv("GRPC_PORT", "50051"),
-		CorsAllowOrigins: getEnv("CORS_ALLOW_ORIGINS", "http://localhost:3000,http://localhost:8080"),
-		LogLevel:         getEnv("LOG_LEVEL", "debug"),
+		// HTTPS enforcement
+		HTTPSEnforce:   strings.ToLower(l.get("HTTPS_ENFORCE", "off")),
+		TrustedProxies: l.get("TRUSTED_PROXIES", ""),
 
 		// Database type
-		DBType: getEnv("DB_TYPE", "postgres"),
+		DBType: l.get("DB_TYPE", "postgres"),
 
 		// PostgreSQL
-		DBHost:     getEnv("DB_HOST", "localhost"),
-		DBPort:     getEnv("DB_PORT", "5432"),
-		DBName:     getEnv("DB_NAME", "user-api"),
-		DBUser:     getEnv("DB_USER", "postgres"),
-		DBPassword: getEnv("DB_PASSWORD", "postgres"),
-		DBSSLMode:  getEnv("DB_SSL_MODE", "disable"),
+		DBHost:     l.get("DB_HOST", "localhost"),
+		DBPort:     l.get("DB_PORT", "5432"),
+		DBName:     l.get("DB_NAME", "user-api"),
+		DBUser:     l.get("DB_USER", "postgres"),
+		DBPassword: l.get("DB_PASSWORD", "postgres"),
+		DBSSLMode:  strings.ToLower(l.get("DB_SSL_MODE", "disable")),
+
+		DBSSLRootCert: l.get("DB_SSL_ROOT_CERT", ""),
+
+		// Slow query logging
+		SlowQueryThresholdMs: slowQueryThresholdMs,
 
 		// MongoDB
-		MongoDBHost:     getEnv("MONGODB_HOST", "localhost"),
-		MongoDBPort:     getEnv("MONGODB_PORT", "27017"),
-		MongoDBName:     getEnv("MONGODB_NAME", "user-api"),
-		MongoDBUser:     getEnv("MONGODB_USER", ""),
-		MongoDBPassword: getEnv("MONGODB_PASSWORD", ""),
-		MongoDBAuthDB:   getEnv("MONGODB_AUTH_DB", "admin"),
+		MongoDBHost:     l.get("MONGODB_HOST", "localhost"),
+		MongoDBPort:     l.get("MONGODB_PORT", "27017"),
+		MongoDBName:     l.get("MONGODB_NAME", "user-api"),
+		MongoDBUser:     l.get("MONGODB_USER", ""),
+		MongoDBPassword: l.get("MONGODB_PASSWORD", ""),
+		MongoDBAuthDB:   l.get("MONGODB_AUTH_DB", "admin"),
+
+		MongoDBTLS:       mongoDBTLS,
+		MongoDBTLSCAFile: l.get("MONGODB_TLS_CA_FILE", ""),
+
+		MongoDBReadPreference:       l.get("MONGODB_READ_PREFERENCE", "primary"),
+		MongoDBWriteConcern:         l.get("MONGODB_WRITE_CONCERN", ""),
+		MongoDBCriticalWriteConcern: l.get("MONGODB_CRITICAL_WRITE_CONCERN", "majority"),
+		MongoDBTransactions:         strings.ToLower(l.get("MONGODB_TRANSACTIONS", MongoTransactionsRequired)),
 
 		// JWT
-		JWTSecret:       getEnv("JWT_SECRET", "your-super-secret-key-here"),
-		JWTExpireMinute: jwtExpireMinute,
+		JWTSecret:              l.get("JWT_SECRET", "your-super-secret-key-here"),
+		JWTExpireMinute:        jwtExpireMinute,
+		JWTRefreshExpireMinute: jwtRefreshExpireMinute,
+		JWTMaxRoles:            jwtMaxRoles,
+
+		PasswordResetTokenMinute: passwordResetTokenMinute,
+
+		// Stale token rejection
+		TokenRejectStaleRoles: tokenRejectStaleRoles,
+
+		// Re-authentication for sensitive actions
+		ReauthActions:       strings.ToLower(l.get("REAUTH_ACTIONS", "")),
+		ReauthMaxAgeMinutes: reauthMaxAgeMinutes,
 
 		// Redis
-		RedisHost:     getEnv("REDIS_HOST", "localhost"),
-		RedisPort:     getEnv("REDIS_PORT", "6379"),
-		RedisPassword: getEnv("REDIS_PASSWORD", ""),
-		RedisDB:       redisDB,
-		RedisCacheTTL: redisCacheTTL,
+		RedisHost:        l.get("REDIS_HOST", "localhost"),
+		RedisPort:        l.get("REDIS_PORT", "6379"),
+		RedisPassword:    l.get("REDIS_PASSWORD", ""),
+		RedisDB:          redisDB,
+		RedisCacheTTL:    redisCacheTTL,
+		RedisOpTimeoutMs: redisOpTimeoutMs,
+		RedisMaxRetries:  redisMaxRetries,
+
+		RedisScanCount:         redisScanCount,
+		RedisDeleteBatchSize:   redisDeleteBatchSize,
+		RedisAsyncInvalidation: redisAsyncInvalidation,
 
 		// Tracing
-		JaegerEndpoint: getEnv("JAEGER_ENDPOINT", "http://localhost:14268/api/traces"),
-	}, nil
+		JaegerEndpoint: l.get("JAEGER_ENDPOINT", "http://localhost:14268/api/traces"),
+
+		// Health checks
+		HealthCheckIntervalSeconds: healthCheckIntervalSeconds,
+
+		// Connection retries
+		ConnectRetries:           connectRetries,
+		ConnectBackoffInitialMs:  connectBackoffInitialMs,
+		ConnectBackoffMaxMs:      connectBackoffMaxMs,
+		ConnectBackoffMultiplier: connectBackoffMultiplier,
+
+		// Status indicators
+		StatusWindowSeconds:            statusWindowSeconds,
+		StatusErrorRateWarn:            statusErrorRateWarn,
+		StatusErrorRateCritical:        statusErrorRateCritical,
+		StatusLatencyP99WarnMs:         statusLatencyP99WarnMs,
+		StatusLatencyP99CriticalMs:     statusLatencyP99CriticalMs,
+		StatusCacheHitRatioWarn:        statusCacheHitRatioWarn,
+		StatusCacheHitRatioCritical:    statusCacheHitRatioCritical,
+		StatusDBPoolSaturationWarn:     statusDBPoolSaturationWarn,
+		StatusDBPoolSaturationCritical: statusDBPoolSaturationCritical,
+
+		// Routing
+		RoutingStrict:        routingStrict,
+		RoutingCaseSensitive: routingCaseSensitive,
+
+		// Content negotiation
+		ResponseMsgpackEnabled: responseMsgpackEnabled,
+
+		// Compression
+		CompressLevel:        compressLevel,
+		CompressMinSize:      compressMinSize,
+		CompressContentTypes: l.get("COMPRESS_CONTENT_TYPES", "application/json,text/"),
+
+		// Permission snapshot
+		PermissionSnapshotEnabled:       permissionSnapshotEnabled,
+		PermissionSnapshotMaxAgeSeconds: permissionSnapshotMaxAgeSeconds,
+
+		// Permission naming
+		PermissionNameEnforce: permissionNameEnforce,
+		PermissionCheckStrict: permissionCheckStrict,
+
+		AutoCreateMissingPermissions: autoCreateMissingPermissions,
+		PermissionReconcileOnStartup: permissionReconcileOnStartup,
+
+		// ID list cap
+		IDListLimit: idListLimit,
+
+		// Last admin protection
+		LastAdminProtection: lastAdminProtection,
+
+		// Initial admin
+		InitialAdminUsername: strings.TrimSpace(l.get("INITIAL_ADMIN_USERNAME", "")),
+		InitialAdminEmail:    strings.TrimSpace(l.get("INITIAL_ADMIN_EMAIL", "")),
+		InitialAdminPassword: l.get("INITIAL_ADMIN_PASSWORD", ""),
+
+		// Privilege escalation guard
+		DenyPrivilegeEscalation: denyPrivilegeEscalation,
+
+		// Default user ordering
+		UserDefaultSort: l.get("USER_DEFAULT_SORT", ""),
+
+		// Partial list results
+		ListPartialResults: listPartialResults,
+
+		// Heavy operation limits
+		HeavyOpLimits:         l.get("HEAVY_OP_LIMITS", "bulk=2"),
+		HeavyOpQueueTimeoutMs: heavyOpQueueTimeoutMs,
+
+		// Feature flags
+		FeatureFlags: l.get("FEATURE_FLAGS", ""),
+
+		// Permission overrides
+		PermissionOverrides: l.get("PERMISSION_OVERRIDES", ""),
+
+		// Cache warming
+		CacheWarmEnabled:     cacheWarmEnabled,
+		CacheWarmTargets:     l.get("CACHE_WARM_TARGETS", "roles,permissions,users"),
+		CacheWarmRecentUsers: cacheWarmRecentUsers,
+
+		// Login challenge
+		LoginChallengeThreshold:     loginChallengeThreshold,
+		LoginChallengeWindowMinutes: loginChallengeWindowMinutes,
+		LoginChallengeProvider:      l.get("LOGIN_CHALLENGE_PROVIDER", "none"),
+		LoginChallengeSecret:        l.get("LOGIN_CHALLENGE_SECRET", ""),
+
+		// Inactivity auto-lock
+		InactivityLockDays:            inactivityLockDays,
+		InactivityLockIntervalMinutes: inactivityLockIntervalMinutes,
+		InactivityLockExemptRoles:     l.get("INACTIVITY_LOCK_EXEMPT_ROLES", "service"),
+
+		// Password expiry
+		PasswordMaxAgeDays: passwordMaxAgeDays,
+		PasswordPepper:     l.get("PASSWORD_PEPPER", ""),
+
+		// Automatic role assignment
+		DomainRoles: l.get("DOMAIN_ROLES", ""),
+
+		// Initial account state
+		DefaultUserActive:            defaultUserActive,
+		UserActiveOverridePermission: l.get("USER_ACTIVE_OVERRIDE_PERMISSION", "user:write"),
+
+		// Soft delete and restore
+		UserSoftDelete:   userSoftDelete,
+		UserRestoreRoles: l.get("USER_RESTORE_ROLES", ""),
+
+		// Read-after-write consistency
+		ReadAfterWriteRetries:      readAfterWriteRetries,
+		ReadAfterWriteRetryDelayMs: readAfterWriteRetryDelayMs,
+
+		// Activity event publishing
+		EventBufferSize:              eventBufferSize,
+		EventPublishTimeoutMs:        eventPublishTimeoutMs,
+		EventBreakerFailureThreshold: eventBreakerFailureThreshold,
+		EventBreakerCooldownSeconds:  eventBreakerCooldownSeconds,
+
+		// Background jobs
+		JobBufferSize: jobBufferSize,
+	}
+
+	cfg.sources = l.sources
+
+	if err := cfg.ValidateCORS(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// loader resolves settings from the environment, then the config file, then the built-in
+// defaults, and records which source supplied each one. Empty values count as unset.
+type loader struct {
+	file    map[string]string
+	sources map[string]string
 }
 
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+func (l *loader) get(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		l.sources[key] = SourceEnv
+		return value
 	}
-	return value
+	if value := l.file[key]; value != "" {
+		l.sources[key] = SourceFile
+		return value
+	}
+	l.sources[key] = SourceDefault
+	return defaultValue
+}
+
+// Sources returns the source that supplied each setting, keyed by environment variable name.
+// It is empty for a configuration not built by LoadConfig.
+func (c *Config) Sources() map[string]string {
+	sources := make(map[string]string, len(c.sources))
+	for key, source := range c.sources {
+		sources[key] = source
+	}
+	return sources
 }
 
 func (c *Config) GetDBConnString() string {
-	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+	connString := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		c.DBHost, c.DBPort, c.DBUser, c.DBPassword, c.DBName, c.DBSSLMode)
+	if c.DBSSLRootCert != "" {
+		connString += " sslrootcert=" + c.DBSSLRootCert
+	}
+	return connString
 }
 
 func (c *Config) GetMongoDBConnString() string {
+	options := url.Values{}
+	if c.MongoDBTLS {
+		options.Set("tls", "true")
+		if c.MongoDBTLSCAFile != "" {
+			options.Set("tlsCAFile", c.MongoDBTLSCAFile)
+		}
+	}
+
 	// If username and password are set, include them in the connection string
+	var connString string
 	if c.MongoDBUser != "" && c.MongoDBPassword != "" {
-		return fmt.Sprintf("mongodb://%s:%s@%s:%s/%s?authSource=%s",
-			c.MongoDBUser, c.MongoDBPassword, c.MongoDBHost, c.MongoDBPort, c.MongoDBName, c.MongoDBAuthDB)
+		options.Set("authSource", c.MongoDBAuthDB)
+		connString = fmt.Sprintf("mongodb://%s:%s@%s:%s/%s",
+			c.MongoDBUser, c.MongoDBPassword, c.MongoDBHost, c.MongoDBPort, c.MongoDBName)
+	} else {
+		// Otherwise, connect without authentication
+		connString = fmt.Sprintf("mongodb://%s:%s/%s",
+			c.MongoDBHost, c.MongoDBPort, c.MongoDBName)
+	}
+
+	if len(options) > 0 {
+		connString += "?" + options.Encode()
 	}
-	// Otherwise, connect without authentication
-	return fmt.Sprintf("mongodb://%s:%s/%s",
-		c.MongoDBHost, c.MongoDBPort, c.MongoDBName)
+	return connString
 }
 
 func (c *Config) GetRedisAddr() string {
@@ -136,3 +697,526 @@ func (c *Config) GetRedisAddr() string {
 func (c *Config) GetJWTExpiration() time.Duration {
 	return time.Duration(c.JWTExpireMinute) * time.Minute
 }
+
+func (c *Config) GetJWTRefreshExpiration() time.Duration {
+	return time.Duration(c.JWTRefreshExpireMinute) * time.Minute
+}
+
+func (c *Config) GetPasswordResetTokenExpiration() time.Duration {
+	return time.Duration(c.PasswordResetTokenMinute) * time.Minute
+}
+
+func (c *Config) GetSlowQueryThreshold() time.Duration {
+	return time.Duration(c.SlowQueryThresholdMs) * time.Millisecond
+}
+
+func (c *Config) GetHealthCheckInterval() time.Duration {
+	return time.Duration(c.HealthCheckIntervalSeconds) * time.Second
+}
+
+func (c *Config) GetConnectBackoffInitial() time.Duration {
+	return time.Duration(c.ConnectBackoffInitialMs) * time.Millisecond
+}
+
+func (c *Config) GetConnectBackoffMax() time.Duration {
+	return time.Duration(c.ConnectBackoffMaxMs) * time.Millisecond
+}
+
+func (c *Config) GetStatusWindow() time.Duration {
+	return time.Duration(c.StatusWindowSeconds) * time.Second
+}
+
+func (c *Config) GetPermissionSnapshotMaxAge() time.Duration {
+	return time.Duration(c.PermissionSnapshotMaxAgeSeconds) * time.Second
+}
+
+func (c *Config) GetLoginChallengeWindow() time.Duration {
+	return time.Duration(c.LoginChallengeWindowMinutes) * time.Minute
+}
+
+func (c *Config) GetInactivityLockThreshold() time.Duration {
+	return time.Duration(c.InactivityLockDays) * 24 * time.Hour
+}
+
+func (c *Config) GetPasswordMaxAge() time.Duration {
+	return time.Duration(c.PasswordMaxAgeDays) * 24 * time.Hour
+}
+
+// GetPasswordPeppers returns the configured peppers, newest first
+func (c *Config) GetPasswordPeppers() []string {
+	peppers := make([]string, 0)
+	for _, pepper := range strings.Split(c.PasswordPepper, ",") {
+		if pepper = strings.TrimSpace(pepper); pepper != "" {
+			peppers = append(peppers, pepper)
+		}
+	}
+	return peppers
+}
+
+func (c *Config) GetEventPublishTimeout() time.Duration {
+	return time.Duration(c.EventPublishTimeoutMs) * time.Millisecond
+}
+
+func (c *Config) GetEventBreakerCooldown() time.Duration {
+	return time.Duration(c.EventBreakerCooldownSeconds) * time.Second
+}
+
+func (c *Config) GetInactivityLockInterval() time.Duration {
+	return time.Duration(c.InactivityLockIntervalMinutes) * time.Minute
+}
+
+func (c *Config) GetInactivityLockExemptRoles() []string {
+	roles := make([]string, 0)
+	for _, role := range strings.Split(c.InactivityLockExemptRoles, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// GetHeavyOpLimits parses HEAVY_OP_LIMITS into the concurrency limit of each operation class
+func (c *Config) GetHeavyOpLimits() (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, pair := range strings.Split(c.HeavyOpLimits, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+
+		class, value, found := strings.Cut(pair, "=")
+		class = strings.TrimSpace(class)
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if !found || class == "" || err != nil || limit < 0 {
+			return nil, fmt.Errorf("HEAVY_OP_LIMITS entries must look like class=N with N >= 0, got %q", pair)
+		}
+		limits[class] = limit
+	}
+	return limits, nil
+}
+
+// GetFeatureFlags parses FEATURE_FLAGS into whether each listed feature is enabled
+func (c *Config) GetFeatureFlags() (map[string]bool, error) {
+	flags := make(map[string]bool)
+	for _, pair := range strings.Split(c.FeatureFlags, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+
+		name, value, found := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+		if !found || name == "" || err != nil {
+			return nil, fmt.Errorf("FEATURE_FLAGS entries must look like name=true or name=false, got %q", pair)
+		}
+		flags[name] = enabled
+	}
+	return flags, nil
+}
+
+// GetPermissionOverrides parses PERMISSION_OVERRIDES into the permissions required by each route,
+// keyed by "METHOD /path"
+func (c *Config) GetPermissionOverrides() (map[string][]string, error) {
+	overrides := make(map[string][]string)
+	for _, entry := range strings.Split(c.PermissionOverrides, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+
+		route, value, found := strings.Cut(entry, "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		path = strings.TrimSpace(path)
+		if !found || !hasPath || method == "" || !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("PERMISSION_OVERRIDES entries must look like METHOD /path=resource:action, got %q", entry)
+		}
+
+		permissions := make([]string, 0)
+		for _, permission := range strings.Split(value, "+") {
+			permission = strings.TrimSpace(permission)
+			resource, action, ok := strings.Cut(permission, ":")
+			if !ok || resource == "" || action == "" {
+				return nil, fmt.Errorf("PERMISSION_OVERRIDES permissions must look like resource:action, got %q", permission)
+			}
+			permissions = append(permissions, permission)
+		}
+		overrides[strings.ToUpper(method)+" "+path] = permissions
+	}
+	return overrides, nil
+}
+
+// GetDomainRoles parses DOMAIN_ROLES into the role names granted for each lowercase email domain
+func (c *Config) GetDomainRoles() (map[string][]string, error) {
+	roles := make(map[string][]string)
+	for _, pair := range strings.Split(c.DomainRoles, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+
+		domain, role, found := strings.Cut(pair, "=")
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+		role = strings.TrimSpace(role)
+		if !found || domain == "" || role == "" {
+			return nil, fmt.Errorf("DOMAIN_ROLES entries must look like domain=role, got %q", pair)
+		}
+		if !slices.Contains(roles[domain], role) {
+			roles[domain] = append(roles[domain], role)
+		}
+	}
+	return roles, nil
+}
+
+// ShutdownStage is a component stopped on shutdown and how long it may take to drain
+type ShutdownStage struct {
+	Name    string
+	Timeout time.Duration
+}
+
+// GetShutdownStages parses SHUTDOWN_STAGES into the stages in the order they run
+func (c *Config) GetShutdownStages() ([]ShutdownStage, error) {
+	stages := make([]ShutdownStage, 0)
+	for _, pair := range strings.Split(c.ShutdownStages, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+
+		name, value, found := strings.Cut(pair, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		seconds, err := strconv.Atoi(strings.TrimSpace(value))
+		if !found || name == "" || err != nil || seconds <= 0 {
+			return nil, fmt.Errorf("SHUTDOWN_STAGES entries must look like name=seconds with seconds > 0, got %q", pair)
+		}
+		if slices.ContainsFunc(stages, func(stage ShutdownStage) bool { return stage.Name == name }) {
+			return nil, fmt.Errorf("SHUTDOWN_STAGES lists %q more than once", name)
+		}
+		stages = append(stages, ShutdownStage{Name: name, Timeout: time.Duration(seconds) * time.Second})
+	}
+	return stages, nil
+}
+
+// GetHeavyOpQueueTimeout returns how long a request waits for a heavy operation slot
+func (c *Config) GetHeavyOpQueueTimeout() time.Duration {
+	return time.Duration(c.HeavyOpQueueTimeoutMs) * time.Millisecond
+}
+
+// GetAccessLogRedactHeaders returns the headers whose values are redacted in access logs
+func (c *Config) GetAccessLogRedactHeaders() []string {
+	headers := make([]string, 0)
+	for _, header := range strings.Split(c.AccessLogRedactHeaders, ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			headers = append(headers, header)
+		}
+	}
+	return headers
+}
+
+// GetReadAfterWriteRetryDelay returns the delay between reads of a user just written
+func (c *Config) GetReadAfterWriteRetryDelay() time.Duration {
+	return time.Duration(c.ReadAfterWriteRetryDelayMs) * time.Millisecond
+}
+
+// GetUserRestoreRoles returns the names of the roles restored users get, empty to keep their prior roles
+func (c *Config) GetUserRestoreRoles() []string {
+	roles := make([]string, 0)
+	for _, role := range strings.Split(c.UserRestoreRoles, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// GetReauthActions returns the sensitive actions requiring a recent re-authentication
+func (c *Config) GetReauthActions() []string {
+	actions := make([]string, 0)
+	for _, action := range strings.Split(c.ReauthActions, ",") {
+		if action = strings.TrimSpace(action); action != "" {
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
+
+// GetReauthMaxAge returns how recently the user must have entered their password for a sensitive action
+func (c *Config) GetReauthMaxAge() time.Duration {
+	return time.Duration(c.ReauthMaxAgeMinutes) * time.Minute
+}
+
+// GetTrustedProxies returns the proxies whose forwarded headers are believed
+func (c *Config) GetTrustedProxies() []string {
+	proxies := make([]string, 0)
+	for _, proxy := range strings.Split(c.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// GetAccessLogRedactFields returns the body fields and query parameters whose values are redacted in access logs
+func (c *Config) GetAccessLogRedactFields() []string {
+	fields := make([]string, 0)
+	for _, field := range strings.Split(c.AccessLogRedactFields, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// GetCompressContentTypes returns the lowercased content types that responses are compressed for
+func (c *Config) GetCompressContentTypes() []string {
+	contentTypes := make([]string, 0)
+	for _, contentType := range strings.Split(c.CompressContentTypes, ",") {
+		if contentType = strings.ToLower(strings.TrimSpace(contentType)); contentType != "" {
+			contentTypes = append(contentTypes, contentType)
+		}
+	}
+	return contentTypes
+}
+
+// GetUserDefaultSort splits USER_DEFAULT_SORT into the sort field and order; both are empty when unset
+func (c *Config) GetUserDefaultSort() (string, string) {
+	field, order, _ := strings.Cut(c.UserDefaultSort, ":")
+	return strings.TrimSpace(field), strings.TrimSpace(order)
+}
+
+func (c *Config) GetCacheWarmTargets() []string {
+	targets := make([]string, 0)
+	for _, target := range strings.Split(c.CacheWarmTargets, ",") {
+		if target = strings.TrimSpace(target); target != "" {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// ValidateCORS rejects CORS settings that browsers refuse or that would expose credentials to any origin
+func (c *Config) ValidateCORS() error {
+	if !c.CorsAllowCredentials {
+		return nil
+	}
+
+	for _, origin := range strings.Split(c.CorsAllowOrigins, ",") {
+		if strings.TrimSpace(origin) == "*" {
+			return fmt.Errorf("CORS_ALLOW_CREDENTIALS cannot be enabled with a wildcard CORS_ALLOW_ORIGINS")
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that the configuration can start the service and reports every problem at once
+func (c *Config) Validate() error {
+	var errs []error
+
+	switch {
+	case c.JWTSecret == "":
+		errs = append(errs, fmt.Errorf("JWT_SECRET is required"))
+	case len(c.JWTSecret) < MinJWTSecretLength:
+		errs = append(errs, fmt.Errorf("JWT_SECRET must be at least %d characters", MinJWTSecretLength))
+	}
+
+	if c.JWTExpireMinute <= 0 {
+		errs = append(errs, fmt.Errorf("JWT_EXPIRE_MINUTES must be positive, got %d", c.JWTExpireMinute))
+	}
+	if c.JWTRefreshExpireMinute < 0 {
+		errs = append(errs, fmt.Errorf("JWT_REFRESH_EXPIRE_MINUTES must not be negative, got %d", c.JWTRefreshExpireMinute))
+	}
+	for _, action := range c.GetReauthActions() {
+		if !slices.Contains(ReauthActions, action) {
+			errs = append(errs, fmt.Errorf("REAUTH_ACTIONS entries must be one of %s, got %q", strings.Join(ReauthActions, ", "), action))
+		}
+	}
+	if c.ReauthMaxAgeMinutes <= 0 {
+		errs = append(errs, fmt.Errorf("REAUTH_MAX_AGE_MINUTES must be positive, got %d", c.ReauthMaxAgeMinutes))
+	}
+	if c.JWTMaxRoles < 0 {
+		errs = append(errs, fmt.Errorf("JWT_MAX_ROLES must not be negative, got %d", c.JWTMaxRoles))
+	}
+	if c.ConnectRetries < 1 {
+		errs = append(errs, fmt.Errorf("CONNECT_RETRIES must be at least 1, got %d", c.ConnectRetries))
+	}
+	if c.ConnectBackoffInitialMs < 0 || c.ConnectBackoffMaxMs < c.ConnectBackoffInitialMs {
+		errs = append(errs, fmt.Errorf("CONNECT_BACKOFF_INITIAL_MS must be between 0 and CONNECT_BACKOFF_MAX_MS (%d), got %d", c.ConnectBackoffMaxMs, c.ConnectBackoffInitialMs))
+	}
+	if c.ConnectBackoffMultiplier < 1 {
+		errs = append(errs, fmt.Errorf("CONNECT_BACKOFF_MULTIPLIER must be at least 1, got %g", c.ConnectBackoffMultiplier))
+	}
+	if c.ReadAfterWriteRetries < 0 {
+		errs = append(errs, fmt.Errorf("READ_AFTER_WRITE_RETRIES must not be negative, got %d", c.ReadAfterWriteRetries))
+	}
+	if c.ReadAfterWriteRetryDelayMs < 0 {
+		errs = append(errs, fmt.Errorf("READ_AFTER_WRITE_RETRY_DELAY_MS must not be negative, got %d", c.ReadAfterWriteRetryDelayMs))
+	}
+
+	if err := c.validateInitialAdmin(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.PasswordResetTokenMinute <= 0 {
+		errs = append(errs, fmt.Errorf("PASSWORD_RESET_TOKEN_MINUTES must be positive, got %d", c.PasswordResetTokenMinute))
+	}
+
+	// Name and value of each port in use
+	ports := [][2]string{
+		{"SERVER_PORT", c.ServerPort},
+		{"GRPC_PORT", c.GrpcPort},
+		{"REDIS_PORT", c.RedisPort},
+	}
+
+	switch c.DBType {
+	case "postgres":
+		ports = append(ports, [2]string{"DB_PORT", c.DBPort})
+
+		if !slices.Contains(DBSSLModes, c.DBSSLMode) {
+			errs = append(errs, fmt.Errorf("DB_SSL_MODE must be one of %s, got %q", strings.Join(DBSSLModes, ", "), c.DBSSLMode))
+		}
+		if c.DBSSLRootCert != "" && c.DBSSLMode == "disable" {
+			errs = append(errs, fmt.Errorf("DB_SSL_ROOT_CERT cannot be used with DB_SSL_MODE=disable"))
+		}
+	case "mongodb":
+		ports = append(ports, [2]string{"MONGODB_PORT", c.MongoDBPort})
+
+		readPreferences := []string{"primary", "primarypreferred", "secondary", "secondarypreferred", "nearest"}
+		if !slices.Contains(readPreferences, strings.ToLower(c.MongoDBReadPreference)) {
+			errs = append(errs, fmt.Errorf("MONGODB_READ_PREFERENCE must be primary, primaryPreferred, secondary, secondaryPreferred or nearest, got %q", c.MongoDBReadPreference))
+		}
+		for _, concern := range [][2]string{
+			{"MONGODB_WRITE_CONCERN", c.MongoDBWriteConcern},
+			{"MONGODB_CRITICAL_WRITE_CONCERN", c.MongoDBCriticalWriteConcern},
+		} {
+			if err := validateWriteConcern(concern[0], concern[1]); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if !slices.Contains([]string{MongoTransactionsAuto, MongoTransactionsRequired, MongoTransactionsOff}, c.MongoDBTransactions) {
+			errs = append(errs, fmt.Errorf("MONGODB_TRANSACTIONS must be auto, required or off, got %q", c.MongoDBTransactions))
+		}
+		if c.MongoDBTLSCAFile != "" && !c.MongoDBTLS {
+			errs = append(errs, fmt.Errorf("MONGODB_TLS_CA_FILE needs MONGODB_TLS=true"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("DB_TYPE must be postgres or mongodb, got %q", c.DBType))
+	}
+
+	for _, port := range ports {
+		if err := validatePort(port[0], port[1]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if c.JaegerEndpoint != "" {
+		if err := validateURL("JAEGER_ENDPOINT", c.JaegerEndpoint); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := c.ValidateCORS(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if !slices.Contains([]string{"off", "redirect", "reject"}, c.HTTPSEnforce) {
+		errs = append(errs, fmt.Errorf("HTTPS_ENFORCE must be off, redirect or reject, got %q", c.HTTPSEnforce))
+	}
+	for _, proxy := range c.GetTrustedProxies() {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				errs = append(errs, fmt.Errorf("TRUSTED_PROXIES entries must be IP addresses or CIDR ranges, got %q", proxy))
+			}
+		}
+	}
+
+	if _, err := c.GetHeavyOpLimits(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if _, err := c.GetFeatureFlags(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if _, err := c.GetPermissionOverrides(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if _, err := c.GetDomainRoles(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if _, err := c.GetShutdownStages(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if resource, action, ok := strings.Cut(c.UserActiveOverridePermission, ":"); !ok || resource == "" || action == "" {
+		errs = append(errs, fmt.Errorf("USER_ACTIVE_OVERRIDE_PERMISSION must look like resource:action, got %q", c.UserActiveOverridePermission))
+	}
+
+	if c.CompressLevel < -1 || c.CompressLevel > 2 {
+		errs = append(errs, fmt.Errorf("COMPRESS_LEVEL must be between -1 and 2, got %d", c.CompressLevel))
+	}
+	if c.CompressMinSize < 0 {
+		errs = append(errs, fmt.Errorf("COMPRESS_MIN_SIZE must not be negative, got %d", c.CompressMinSize))
+	}
+
+	return errors.Join(errs...)
+}
+
+// HasInitialAdmin reports whether an initial admin is configured to replace the default admin
+func (c *Config) HasInitialAdmin() bool {
+	return c.InitialAdminUsername != "" || c.InitialAdminEmail != "" || c.InitialAdminPassword != ""
+}
+
+// validateInitialAdmin holds the initial admin to the rules users are created under
+func (c *Config) validateInitialAdmin() error {
+	if !c.HasInitialAdmin() {
+		return nil
+	}
+
+	var errs []error
+	if c.InitialAdminUsername == "" || c.InitialAdminEmail == "" || c.InitialAdminPassword == "" {
+		errs = append(errs, fmt.Errorf("INITIAL_ADMIN_USERNAME, INITIAL_ADMIN_EMAIL and INITIAL_ADMIN_PASSWORD must be set together"))
+	}
+	if c.InitialAdminUsername != "" {
+		alphanumeric := strings.IndexFunc(c.InitialAdminUsername, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) < 0
+		if len(c.InitialAdminUsername) < 6 || len(c.InitialAdminUsername) > 50 || !alphanumeric {
+			errs = append(errs, fmt.Errorf("INITIAL_ADMIN_USERNAME must be 6 to 50 letters or digits, got %q", c.InitialAdminUsername))
+		}
+	}
+	if c.InitialAdminEmail != "" {
+		if address, err := mail.ParseAddress(c.InitialAdminEmail); err != nil || address.Address != c.InitialAdminEmail {
+			errs = append(errs, fmt.Errorf("INITIAL_ADMIN_EMAIL must be an email address, got %q", c.InitialAdminEmail))
+		}
+	}
+	if c.InitialAdminPassword != "" && (len(c.InitialAdminPassword) < 8 || len(c.InitialAdminPassword) > 100) {
+		// The password itself is never echoed
+		errs = append(errs, fmt.Errorf("INITIAL_ADMIN_PASSWORD must be 8 to 100 characters"))
+	}
+	return errors.Join(errs...)
+}
+
+// validateWriteConcern accepts "majority", a number of acknowledging nodes, or empty for the server default
+func validateWriteConcern(name, value string) error {
+	if value == "" || value == "majority" {
+		return nil
+	}
+	if nodes, err := strconv.Atoi(value); err != nil || nodes < 0 {
+		return fmt.Errorf("%s must be majority or a number of nodes, got %q", name, value)
+	}
+	return nil
+}
+
+func validatePort(name, value string) error {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("%s must be a port number between 1 and 65535, got %q", name, value)
+	}
+	return nil
+}
+
+func validateURL(name, value string) error {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("%s must be an absolute URL such as http://host:port/path, got %q", name, value)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// RedactedValue replaces secrets that are set in sanitized output
+const RedactedValue = "[REDACTED]"
+
+// Sanitized returns the effective configuration keyed by snake_case field name (or its json tag).
+// Fields tagged `redact:"true"` are replaced with RedactedValue when set, or an empty string when unset,
+// so operators can see whether a secret is configured without seeing it.
+func (c *Config) Sanitized() map[string]interface{} {
+	value := reflect.ValueOf(c).Elem()
+	fields := value.Type()
+
+	sanitized := make(map[string]interface{}, fields.NumField())
+	for i := 0; i < fields.NumField(); i++ {
+		field := fields.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		key := field.Tag.Get("json")
+		if key == "" {
+			key = toSnakeCase(field.Name)
+		}
+		if field.Tag.Get("redact") == "true" {
+			if value.Field(i).IsZero() {
+				sanitized[key] = ""
+			} else {
+				sanitized[key] = RedactedValue
+			}
+			continue
+		}
+
+		sanitized[key] = value.Field(i).Interface()
+	}
+
+	return sanitized
+}
+
+// initialisms are split off the run of capitals they start, so that DBSSLMode becomes db_ssl_mode
+// rather than dbssl_mode
+var initialisms = []string{"DB", "SSL", "TLS", "CA"}
+
+// toSnakeCase converts a Go field name such as MongoDBHost to mongo_db_host
+func toSnakeCase(name string) string {
+	runes := []rune(name)
+
+	var words []string
+	for start := 0; start < len(runes); {
+		end := wordEnd(runes, start)
+		words = append(words, strings.ToLower(string(runes[start:end])))
+		start = end
+	}
+
+	return strings.Join(words, "_")
+}
+
+// wordEnd returns where the word of a field name starting at runes[start] ends: after a known
+// initialism followed by another capital, or before the capital that starts the next word
+func wordEnd(runes []rune, start int) int {
+	for _, initialism := range initialisms {
+		end := start + len(initialism)
+		if end <= len(runes) && string(runes[start:end]) == initialism && (end == len(runes) || unicode.IsUpper(runes[end])) {
+			return end
+		}
+	}
+
+	end := start + 1
+	for ; end < len(runes); end++ {
+		if !unicode.IsUpper(runes[end]) {
+			continue
+		}
+		prev := runes[end-1]
+		nextIsLower := end+1 < len(runes) && unicode.IsLower(runes[end+1])
+		if unicode.IsLower(prev) || unicode.IsDigit(prev) || nextIsLower {
+			break
+		}
+	}
+
+	return end
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitized(t *testing.T) {
+	cfg := &Config{
+		AppName:              "user-api",
+		DBType:               "postgres",
+		DBHost:               "db.internal",
+		DBSSLMode:            "require",
+		DBPassword:           "db-secret",
+		MongoDBPassword:      "mongo-secret",
+		JWTSecret:            "jwt-secret",
+		JWTExpireMinute:      60,
+		RedisPassword:        "",
+		LoginChallengeSecret: "captcha-secret",
+		PasswordPepper:       "pepper-secret-2,pepper-secret-1",
+		CacheWarmEnabled:     true,
+	}
+
+	sanitized := cfg.Sanitized()
+
+	t.Run("Secrets are redacted", func(t *testing.T) {
+		assert.Equal(t, RedactedValue, sanitized["db_password"])
+		assert.Equal(t, RedactedValue, sanitized["mongo_db_password"])
+		assert.Equal(t, RedactedValue, sanitized["jwt_secret"])
+		assert.Equal(t, RedactedValue, sanitized["login_challenge_secret"])
+		assert.Equal(t, RedactedValue, sanitized["password_pepper"])
+
+		// Unset secrets show as empty so operators can tell they are missing
+		assert.Equal(t, "", sanitized["redis_password"])
+
+		for _, value := range sanitized {
+			if s, ok := value.(string); ok {
+				assert.NotContains(t, s, "secret")
+			}
+		}
+	})
+
+	t.Run("Non-sensitive values are present", func(t *testing.T) {
+		assert.Equal(t, "user-api", sanitized["app_name"])
+		assert.Equal(t, "postgres", sanitized["db_type"])
+		assert.Equal(t, "db.internal", sanitized["db_host"])
+		assert.Equal(t, 60, sanitized["jwt_expire_minute"])
+		assert.Equal(t, true, sanitized["cache_warm_enabled"])
+		assert.Contains(t, sanitized, "slow_query_threshold_ms")
+		assert.Equal(t, "require", sanitized["db_ssl_mode"])
+	})
+
+	t.Run("Every secret-like field is tagged", func(t *testing.T) {
+		fields := reflect.TypeOf(Config{})
+		for i := 0; i < fields.NumField(); i++ {
+			field := fields.Field(i)
+			name := strings.ToLower(field.Name)
+			if strings.HasSuffix(name, "password") || strings.HasSuffix(name, "secret") || strings.HasSuffix(name, "token") {
+				assert.Equal(t, "true", field.Tag.Get("redact"), "field %s must be redacted", field.Name)
+			}
+		}
+	})
+}
+
+func TestToSnakeCase(t *testing.T) {
+	assert.Equal(t, "app_name", toSnakeCase("AppName"))
+	assert.Equal(t, "jwt_secret", toSnakeCase("JWTSecret"))
+	assert.Equal(t, "mongo_db_host", toSnakeCase("MongoDBHost"))
+	assert.Equal(t, "slow_query_threshold_ms", toSnakeCase("SlowQueryThresholdMs"))
+	assert.Equal(t, "db_ssl_mode", toSnakeCase("DBSSLMode"))
+	assert.Equal(t, "mongo_db_tls_ca_file", toSnakeCase("MongoDBTLSCAFile"))
+	assert.Equal(t, "https_enforce", toSnakeCase("HTTPSEnforce"))
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/chats/go-user-api/config"
@@ -11,23 +13,56 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// Pattern deletes scan and delete this many keys per call when not configured
+const (
+	defaultScanCount       = 500
+	defaultDeleteBatchSize = 500
+)
+
 // RedisClient is a wrapper for redis client
 type RedisClient struct {
-	client  *redis.Client
-	ctx     context.Context
-	enabled bool
-	ttl     time.Duration
+	client    *redis.Client
+	ctx       context.Context
+	enabled   bool
+	ttl       time.Duration
+	opTimeout time.Duration
+	observe   func(hit bool)
+
+	// Pattern deletes walk the keyspace with SCAN and delete in batches; asyncInvalidation
+	// runs DeleteByPattern in the background
+	scanCount         int64
+	deleteBatchSize   int
+	asyncInvalidation bool
+	// unlinkUnsupported is set once Redis rejects UNLINK (before 4.0), so DEL is used instead
+	unlinkUnsupported atomic.Bool
 }
 
 // NewRedisClient creates a new Redis client
 func NewRedisClient(cfg *config.Config) (*RedisClient, error) {
 	ctx := context.Background()
 
+	// go-redis treats 0 as its default of 3 retries and -1 as none
+	maxRetries := cfg.RedisMaxRetries
+	if maxRetries <= 0 {
+		maxRetries = -1
+	}
+
 	client := redis.NewClient(&redis.Options{
-		Addr:     cfg.GetRedisAddr(),
-		Password: cfg.RedisPassword,
-		DB:       cfg.RedisDB,
+		Addr:       cfg.GetRedisAddr(),
+		Password:   cfg.RedisPassword,
+		DB:         cfg.RedisDB,
+		MaxRetries: maxRetries,
 	})
+	opTimeout := time.Duration(cfg.RedisOpTimeoutMs) * time.Millisecond
+
+	scanCount := int64(cfg.RedisScanCount)
+	if scanCount <= 0 {
+		scanCount = defaultScanCount
+	}
+	deleteBatchSize := cfg.RedisDeleteBatchSize
+	if deleteBatchSize <= 0 {
+		deleteBatchSize = defaultDeleteBatchSize
+	}
 
 	// Test connection
 	_, err := client.Ping(ctx).Result()
@@ -35,29 +70,64 @@ func NewRedisClient(cfg *config.Config) (*RedisClient, error) {
 		// Log the error but continue without Redis
 		log.Warn().Err(err).Msg("Failed to connect to Redis, continuing without caching")
 		return &RedisClient{
-			client:  client,
-			ctx:     ctx,
-			enabled: false,
-			ttl:     time.Duration(cfg.RedisCacheTTL) * time.Second,
+			client:    client,
+			ctx:       ctx,
+			enabled:   false,
+			ttl:       time.Duration(cfg.RedisCacheTTL) * time.Second,
+			opTimeout: opTimeout,
+
+			scanCount:         scanCount,
+			deleteBatchSize:   deleteBatchSize,
+			asyncInvalidation: cfg.RedisAsyncInvalidation,
 		}, nil
 	}
 
 	log.Info().Msg("Connected to Redis successfully")
 	return &RedisClient{
-		client:  client,
-		ctx:     ctx,
-		enabled: true,
-		ttl:     time.Duration(cfg.RedisCacheTTL) * time.Second,
+		client:    client,
+		ctx:       ctx,
+		enabled:   true,
+		ttl:       time.Duration(cfg.RedisCacheTTL) * time.Second,
+		opTimeout: opTimeout,
+
+		scanCount:         scanCount,
+		deleteBatchSize:   deleteBatchSize,
+		asyncInvalidation: cfg.RedisAsyncInvalidation,
 	}, nil
 }
 
+// opContext returns the context for a single cache operation, bounded by the configured
+// timeout so that a hung Redis fails fast and callers fall through to the database
+func (c *RedisClient) opContext() (context.Context, context.CancelFunc) {
+	if c.opTimeout <= 0 {
+		return c.ctx, func() {}
+	}
+	return context.WithTimeout(c.ctx, c.opTimeout)
+}
+
+// UseLookupObserver sets a callback told whether each key read by Get or MGet was a hit; errors count as misses
+func (c *RedisClient) UseLookupObserver(observe func(hit bool)) {
+	c.observe = observe
+}
+
 // Get retrieves an item from the cache
 func (c *RedisClient) Get(key string, dest interface{}) (bool, error) {
 	if !c.enabled {
 		return false, nil
 	}
 
-	val, err := c.client.Get(c.ctx, key).Result()
+	found, err := c.get(key, dest)
+	if c.observe != nil {
+		c.observe(found)
+	}
+	return found, err
+}
+
+func (c *RedisClient) get(key string, dest interface{}) (bool, error) {
+	ctx, cancel := c.opContext()
+	defer cancel()
+
+	val, err := c.client.Get(ctx, key).Result()
 	if err == redis.Nil {
 		// Key does not exist
 		return false, nil
@@ -89,7 +159,90 @@ func (c *RedisClient) SetWithTTL(key string, value interface{}, ttl time.Duratio
 		return fmt.Errorf("failed to marshal data for caching: %w", err)
 	}
 
-	err = c.client.Set(c.ctx, key, data, ttl).Err()
+	ctx, cancel := c.opContext()
+	defer cancel()
+
+	err = c.client.Set(ctx, key, data, ttl).Err()
+	if err != nil {
+		return fmt.Errorf("failed to set cache: %w", err)
+	}
+
+	return nil
+}
+
+// MGet retrieves several items in one pipelined round-trip, decoding the value of keys[i] into dests[i].
+// The returned slice reports per key whether it was found; a value that fails to decode counts as not found.
+func (c *RedisClient) MGet(keys []string, dests []interface{}) ([]bool, error) {
+	if len(keys) != len(dests) {
+		return nil, fmt.Errorf("got %d keys but %d destinations", len(keys), len(dests))
+	}
+
+	found := make([]bool, len(keys))
+	if !c.enabled || len(keys) == 0 {
+		return found, nil
+	}
+
+	ctx, cancel := c.opContext()
+	defer cancel()
+
+	cmds := make([]*redis.StringCmd, len(keys))
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Get(ctx, key)
+		}
+		return nil
+	})
+	// A missing key fails its command with redis.Nil, which is not a pipeline failure
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get from cache: %w", err)
+	}
+
+	for i, cmd := range cmds {
+		val, err := cmd.Result()
+		if err != nil {
+			continue
+		}
+
+		if err := json.Unmarshal([]byte(val), dests[i]); err != nil {
+			log.Debug().Err(err).Str("key", keys[i]).Msg("Failed to unmarshal cached data")
+			continue
+		}
+		found[i] = true
+	}
+
+	if c.observe != nil {
+		for _, hit := range found {
+			c.observe(hit)
+		}
+	}
+
+	return found, nil
+}
+
+// MSet adds several items to the cache with the default TTL in one pipelined round-trip
+func (c *RedisClient) MSet(items map[string]interface{}) error {
+	if !c.enabled || len(items) == 0 {
+		return nil
+	}
+
+	values := make(map[string][]byte, len(items))
+	for key, value := range items {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to marshal data for caching: %w", err)
+		}
+		values[key] = data
+	}
+
+	ctx, cancel := c.opContext()
+	defer cancel()
+
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, data := range values {
+			pipe.Set(ctx, key, data, c.ttl)
+		}
+		return nil
+	})
 	if err != nil {
 		return fmt.Errorf("failed to set cache: %w", err)
 	}
@@ -103,7 +256,10 @@ func (c *RedisClient) Delete(key string) error {
 		return nil
 	}
 
-	err := c.client.Del(c.ctx, key).Err()
+	ctx, cancel := c.opContext()
+	defer cancel()
+
+	err := c.client.Del(ctx, key).Err()
 	if err != nil {
 		return fmt.Errorf("failed to delete from cache: %w", err)
 	}
@@ -111,29 +267,118 @@ func (c *RedisClient) Delete(key string) error {
 	return nil
 }
 
-// DeleteByPattern removes items from the cache matching a pattern
+// DeleteByPattern removes items from the cache matching a pattern. With asynchronous invalidation
+// the keys are deleted in the background and failures are only logged.
 func (c *RedisClient) DeleteByPattern(pattern string) error {
 	if !c.enabled {
 		return nil
 	}
 
-	// Find all keys matching the pattern
-	keys, err := c.client.Keys(c.ctx, pattern).Result()
-	if err != nil {
-		return fmt.Errorf("failed to find keys matching pattern: %w", err)
+	if c.asyncInvalidation {
+		go func() {
+			if _, err := c.deleteMatching(pattern); err != nil {
+				log.Warn().Err(err).Str("pattern", pattern).Msg("Failed to delete keys from cache in the background")
+			}
+		}()
+		return nil
 	}
 
-	if len(keys) == 0 {
-		return nil
+	_, err := c.deleteMatching(pattern)
+	return err
+}
+
+// Purge removes the given keys and every key matching the patterns, and returns how many
+// keys existed and were removed. It always runs synchronously so the count is known.
+func (c *RedisClient) Purge(keys []string, patterns []string) (int, error) {
+	if !c.enabled {
+		return 0, nil
 	}
 
-	// Delete all found keys
-	err = c.client.Del(c.ctx, keys...).Err()
+	deleted, err := c.unlinkBatches(keys)
 	if err != nil {
-		return fmt.Errorf("failed to delete keys from cache: %w", err)
+		return deleted, err
 	}
 
-	return nil
+	for _, pattern := range patterns {
+		count, err := c.deleteMatching(pattern)
+		deleted += count
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	return deleted, nil
+}
+
+// deleteMatching walks the keyspace with SCAN and deletes the matching keys in batches, so a
+// large keyspace neither blocks Redis on KEYS nor gets one huge delete. Every SCAN and delete
+// has its own operation budget. The keys are deleted once the scan completes, since deleting
+// mid-scan may make some SCAN implementations skip keys. It returns the number of keys deleted.
+func (c *RedisClient) deleteMatching(pattern string) (int, error) {
+	seen := make(map[string]struct{})
+	keys := make([]string, 0)
+
+	var cursor uint64
+	for {
+		ctx, cancel := c.opContext()
+		batch, next, err := c.client.Scan(ctx, cursor, pattern, c.scanCount).Result()
+		cancel()
+		if err != nil {
+			return 0, fmt.Errorf("failed to scan keys matching pattern: %w", err)
+		}
+
+		// SCAN may return a key more than once
+		for _, key := range batch {
+			if _, ok := seen[key]; !ok {
+				seen[key] = struct{}{}
+				keys = append(keys, key)
+			}
+		}
+
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	return c.unlinkBatches(keys)
+}
+
+// unlinkBatches deletes keys at most deleteBatchSize at a time and returns the number that existed
+func (c *RedisClient) unlinkBatches(keys []string) (int, error) {
+	deleted := 0
+	for start := 0; start < len(keys); start += c.deleteBatchSize {
+		count, err := c.unlink(keys[start:min(start+c.deleteBatchSize, len(keys))])
+		deleted += count
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// unlink deletes keys with UNLINK, which frees their memory in the background, falling back to DEL
+// on Redis versions without it. It returns the number of keys that existed.
+func (c *RedisClient) unlink(keys []string) (int, error) {
+	ctx, cancel := c.opContext()
+	defer cancel()
+
+	if !c.unlinkUnsupported.Load() {
+		deleted, err := c.client.Unlink(ctx, keys...).Result()
+		if err == nil {
+			return int(deleted), nil
+		}
+		if !strings.Contains(strings.ToLower(err.Error()), "unknown command") {
+			return 0, fmt.Errorf("failed to delete keys from cache: %w", err)
+		}
+		c.unlinkUnsupported.Store(true)
+	}
+
+	deleted, err := c.client.Del(ctx, keys...).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete keys from cache: %w", err)
+	}
+	return int(deleted), nil
 }
 
 // Close closes the Redis connection
@@ -144,6 +389,19 @@ func (c *RedisClient) Close() error {
 	return nil
 }
 
+// Ping checks that Redis is reachable and caching is enabled
+func (c *RedisClient) Ping(ctx context.Context) error {
+	if !c.enabled {
+		return fmt.Errorf("caching is disabled")
+	}
+
+	if err := c.client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("failed to ping Redis: %w", err)
+	}
+
+	return nil
+}
+
 // IsEnabled returns whether caching is enabled
 func (c *RedisClient) IsEnabled() bool {
 	return c.enabled
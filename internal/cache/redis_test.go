@@ -0,0 +1,319 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/chats/go-user-api/config"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingHook counts the commands and pipelines sent to Redis
+type countingHook struct {
+	commands  atomic.Int32
+	pipelines atomic.Int32
+}
+
+func (h *countingHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	h.commands.Add(1)
+	return ctx, nil
+}
+
+func (h *countingHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (h *countingHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	h.pipelines.Add(1)
+	return ctx, nil
+}
+
+func (h *countingHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+// recordingHook records the arguments of every command sent to Redis and can fail one command
+type recordingHook struct {
+	countingHook
+	mu       sync.Mutex
+	commands [][]interface{}
+	fail     string
+}
+
+func (h *recordingHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	h.mu.Lock()
+	h.commands = append(h.commands, cmd.Args())
+	h.mu.Unlock()
+
+	if cmd.Name() == h.fail {
+		return ctx, fmt.Errorf("ERR unknown command '%s'", h.fail)
+	}
+	return ctx, nil
+}
+
+// named returns the arguments of the recorded commands called name
+func (h *recordingHook) named(name string) [][]interface{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var commands [][]interface{}
+	for _, args := range h.commands {
+		if args[0] == name {
+			commands = append(commands, args)
+		}
+	}
+	return commands
+}
+
+func newTestRedisClient(t *testing.T) (*RedisClient, *miniredis.Miniredis, *countingHook) {
+	t.Helper()
+
+	redisServer := miniredis.RunT(t)
+	redisClient, err := NewRedisClient(&config.Config{
+		RedisHost:     redisServer.Host(),
+		RedisPort:     redisServer.Port(),
+		RedisCacheTTL: 60,
+	})
+	require.NoError(t, err)
+	require.True(t, redisClient.IsEnabled())
+	t.Cleanup(func() { redisClient.Close() })
+
+	hook := &countingHook{}
+	redisClient.client.AddHook(hook)
+
+	return redisClient, redisServer, hook
+}
+
+type cachedItem struct {
+	Name string `json:"name"`
+}
+
+func TestRedisClient_MGet(t *testing.T) {
+	t.Run("One pipeline with per-key results", func(t *testing.T) {
+		redisClient, redisServer, hook := newTestRedisClient(t)
+		require.NoError(t, redisServer.Set("item:a", `{"name":"a"}`))
+		require.NoError(t, redisServer.Set("item:c", `{"name":"c"}`))
+		require.NoError(t, redisServer.Set("item:bad", `not json`))
+
+		var a, b, c, bad cachedItem
+		found, err := redisClient.MGet(
+			[]string{"item:a", "item:b", "item:c", "item:bad"},
+			[]interface{}{&a, &b, &c, &bad},
+		)
+
+		require.NoError(t, err)
+		assert.Equal(t, []bool{true, false, true, false}, found)
+		assert.Equal(t, "a", a.Name)
+		assert.Empty(t, b.Name)
+		assert.Equal(t, "c", c.Name)
+		assert.Equal(t, int32(1), hook.pipelines.Load())
+		assert.Zero(t, hook.commands.Load())
+	})
+
+	t.Run("Every key missing", func(t *testing.T) {
+		redisClient, _, _ := newTestRedisClient(t)
+
+		var a, b cachedItem
+		found, err := redisClient.MGet([]string{"item:a", "item:b"}, []interface{}{&a, &b})
+
+		require.NoError(t, err)
+		assert.Equal(t, []bool{false, false}, found)
+	})
+
+	t.Run("Mismatched destinations", func(t *testing.T) {
+		redisClient, _, _ := newTestRedisClient(t)
+
+		_, err := redisClient.MGet([]string{"item:a", "item:b"}, []interface{}{&cachedItem{}})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("Caching disabled", func(t *testing.T) {
+		redisClient := &RedisClient{enabled: false}
+
+		found, err := redisClient.MGet([]string{"item:a"}, []interface{}{&cachedItem{}})
+
+		require.NoError(t, err)
+		assert.Equal(t, []bool{false}, found)
+	})
+}
+
+func TestRedisClient_MSet(t *testing.T) {
+	redisClient, redisServer, hook := newTestRedisClient(t)
+
+	err := redisClient.MSet(map[string]interface{}{
+		"item:a": cachedItem{Name: "a"},
+		"item:b": cachedItem{Name: "b"},
+		"item:c": cachedItem{Name: "c"},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), hook.pipelines.Load())
+	assert.Zero(t, hook.commands.Load())
+
+	for _, key := range []string{"item:a", "item:b", "item:c"} {
+		assert.True(t, redisServer.Exists(key))
+		assert.Equal(t, redisClient.ttl, redisServer.TTL(key))
+	}
+
+	var b cachedItem
+	found, err := redisClient.Get("item:b", &b)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "b", b.Name)
+}
+
+// newStalledRedisClient returns a client whose server accepts connections but never replies
+func newStalledRedisClient(t *testing.T, opTimeout time.Duration) *RedisClient {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+
+	client := redis.NewClient(&redis.Options{Addr: listener.Addr().String(), MaxRetries: 2})
+	t.Cleanup(func() { client.Close() })
+
+	return &RedisClient{
+		client:    client,
+		ctx:       context.Background(),
+		enabled:   true,
+		ttl:       time.Minute,
+		opTimeout: opTimeout,
+	}
+}
+
+func TestRedisClient_OperationTimeout(t *testing.T) {
+	operations := map[string]func(c *RedisClient) error{
+		"Get": func(c *RedisClient) error {
+			_, err := c.Get("item:a", &cachedItem{})
+			return err
+		},
+		"Set": func(c *RedisClient) error {
+			return c.Set("item:a", cachedItem{Name: "a"})
+		},
+		"MGet": func(c *RedisClient) error {
+			_, err := c.MGet([]string{"item:a"}, []interface{}{&cachedItem{}})
+			return err
+		},
+		"DeleteByPattern": func(c *RedisClient) error {
+			return c.DeleteByPattern("item:*")
+		},
+	}
+
+	for name, operation := range operations {
+		t.Run(name, func(t *testing.T) {
+			redisClient := newStalledRedisClient(t, 50*time.Millisecond)
+
+			start := time.Now()
+			err := operation(redisClient)
+
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+			// Retries share the one budget instead of each waiting out the read timeout
+			assert.Less(t, time.Since(start), time.Second)
+		})
+	}
+}
+
+func TestRedisClient_DeleteByPattern(t *testing.T) {
+	// seed stores 25 user list keys and a role key, and records the commands sent after it
+	seed := func(t *testing.T) (*RedisClient, *miniredis.Miniredis, *recordingHook) {
+		t.Helper()
+
+		redisClient, redisServer, _ := newTestRedisClient(t)
+		redisClient.scanCount = 10
+		redisClient.deleteBatchSize = 7
+		for i := 0; i < 25; i++ {
+			require.NoError(t, redisServer.Set(fmt.Sprintf("users:limit:10:offset:%d", i*10), "[]"))
+		}
+		require.NoError(t, redisServer.Set("role:admin", "{}"))
+
+		hook := &recordingHook{}
+		redisClient.client.AddHook(hook)
+		return redisClient, redisServer, hook
+	}
+
+	// unlinked returns the number of keys named across the delete commands, checking each batch size
+	unlinked := func(t *testing.T, commands [][]interface{}, batchSize int) int {
+		t.Helper()
+
+		total := 0
+		for _, args := range commands {
+			keys := len(args) - 1
+			assert.LessOrEqual(t, keys, batchSize)
+			total += keys
+		}
+		return total
+	}
+
+	t.Run("Scans and unlinks in batches", func(t *testing.T) {
+		redisClient, redisServer, hook := seed(t)
+
+		require.NoError(t, redisClient.DeleteByPattern("users:*"))
+
+		assert.Empty(t, hook.named("keys"), "KEYS blocks Redis")
+		scans := hook.named("scan")
+		assert.Greater(t, len(scans), 1)
+		for _, args := range scans {
+			assert.Equal(t, []interface{}{"count", int64(10)}, args[len(args)-2:])
+		}
+
+		unlinks := hook.named("unlink")
+		assert.Greater(t, len(unlinks), 1)
+		assert.Equal(t, 25, unlinked(t, unlinks, 7))
+		assert.Empty(t, hook.named("del"))
+
+		assert.Equal(t, []string{"role:admin"}, redisServer.Keys())
+	})
+
+	t.Run("Falls back to DEL without UNLINK", func(t *testing.T) {
+		redisClient, redisServer, hook := seed(t)
+		hook.fail = "unlink"
+
+		require.NoError(t, redisClient.DeleteByPattern("users:*"))
+		require.NoError(t, redisClient.DeleteByPattern("role:*"))
+
+		// UNLINK is only tried once
+		assert.Len(t, hook.named("unlink"), 1)
+		assert.Equal(t, 26, unlinked(t, hook.named("del"), 7))
+		assert.Empty(t, redisServer.Keys())
+	})
+
+	t.Run("Runs in the background when asynchronous", func(t *testing.T) {
+		redisClient, redisServer, _ := seed(t)
+		redisClient.asyncInvalidation = true
+
+		require.NoError(t, redisClient.DeleteByPattern("users:*"))
+
+		assert.Eventually(t, func() bool {
+			return len(redisServer.Keys()) == 1
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Purge counts the deleted keys", func(t *testing.T) {
+		redisClient, _, _ := seed(t)
+
+		deleted, err := redisClient.Purge([]string{"role:admin", "role:missing"}, []string{"users:*"})
+
+		require.NoError(t, err)
+		assert.Equal(t, 26, deleted)
+	})
+}
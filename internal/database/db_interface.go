@@ -10,6 +10,8 @@ type Database interface {
 	Connect(ctx context.Context) error
 	// Close closes the database connection
 	Close() error
+	// Ping checks that the database is reachable
+	Ping(ctx context.Context) error
 	// Migrate applies database migrations
 	Migrate() error
 	// GetImplementation returns the actual database implementation
@@ -7,10 +7,26 @@ CREATE TABLE IF NOT EXISTS users (
     first_name VARCHAR(100),
     last_name VARCHAR(100),
     is_active BOOLEAN NOT NULL DEFAULT true,
+    last_login_at TIMESTAMP WITH TIME ZONE,
     created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
 );
 
+-- Track last login for databases created before the column existed
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_login_at TIMESTAMP WITH TIME ZONE;
+
+-- Tokens issued before the user's roles last changed can be rejected
+ALTER TABLE users ADD COLUMN IF NOT EXISTS roles_changed_at TIMESTAMP WITH TIME ZONE;
+
+-- Tokens issued before the user's tokens were last revoked are rejected
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tokens_revoked_at TIMESTAMP WITH TIME ZONE;
+
+-- Password age counts from the last change; existing users start counting when the column is added
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_changed_at TIMESTAMP WITH TIME ZONE DEFAULT NOW();
+
+-- Soft-deleted users, such as duplicates merged into another account, are kept but hidden from lists
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
 CREATE TABLE IF NOT EXISTS roles (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     name VARCHAR(50) UNIQUE NOT NULL,
@@ -30,6 +46,9 @@ CREATE TABLE IF NOT EXISTS permissions (
     UNIQUE(resource, action)
 );
 
+-- Permissions can be grouped into a category for the catalog; existing ones start uncategorized
+ALTER TABLE permissions ADD COLUMN IF NOT EXISTS category VARCHAR(100) NOT NULL DEFAULT '';
+
 CREATE TABLE IF NOT EXISTS user_roles (
     user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
     role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
@@ -44,6 +63,19 @@ CREATE TABLE IF NOT EXISTS role_permissions (
     PRIMARY KEY (role_id, permission_id)
 );
 
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    key_hash VARCHAR(64) UNIQUE NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    permissions TEXT[] NOT NULL DEFAULT '{}',
+    created_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
 -- Insert default roles
 INSERT INTO roles (name, description) 
 VALUES 
@@ -103,14 +135,4 @@ FROM permissions
 WHERE action = 'read'
 ON CONFLICT DO NOTHING;
 
--- Create default admin user (password is 'adminpassword')
-INSERT INTO users (username, email, password, first_name, last_name) 
-VALUES ('admin', 'admin@example.com', '$2a$10$FPS/DKJWlcHvU1fJuDEYDO0IXNoXQw./hCBlh90AogplwklD7PylC', 'Admin', 'User')
-ON CONFLICT (username) DO NOTHING;
-
--- Assign admin role to admin user
-INSERT INTO user_roles (user_id, role_id)
-SELECT 
-    (SELECT id FROM users WHERE username = 'admin'),
-    (SELECT id FROM roles WHERE name = 'admin')
-ON CONFLICT DO NOTHING;
\ No newline at end of file
+-- The default admin user is created by PostgresDB.Migrate unless INITIAL_ADMIN_* replaces it
\ No newline at end of file
@@ -3,21 +3,87 @@ package database
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"strconv"
 	"time"
 
 	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 )
 
+// MaxGeneratedIDAttempts bounds the inserts tried with freshly generated IDs when the _id collides
+const MaxGeneratedIDAttempts = 3
+
+// Names of the unique indexes, as MongoDB derives them from the keys
+const (
+	IDIndex                        = "_id_"
+	UsersUsernameIndex             = "username_1"
+	UsersEmailIndex                = "email_1"
+	RolesNameIndex                 = "name_1"
+	PermissionsNameIndex           = "name_1"
+	PermissionsResourceActionIndex = "resource_1_action_1"
+)
+
+// duplicateKeyIndexPattern finds the violated index in a duplicate key error message
+var duplicateKeyIndexPattern = regexp.MustCompile(`index: (\S+)`)
+
+// DuplicateKeyIndex reports whether err is a duplicate key (E11000) error and returns the name of
+// the unique index it violated, or "" when the server did not name it
+func DuplicateKeyIndex(err error) (string, bool) {
+	if !mongo.IsDuplicateKeyError(err) {
+		return "", false
+	}
+
+	if match := duplicateKeyIndexPattern.FindStringSubmatch(err.Error()); match != nil {
+		return match[1], true
+	}
+	return "", true
+}
+
+// InsertUser inserts a user into the users collection, reporting a duplicate username or email as
+// models.ErrUsernameExists or models.ErrEmailExists. With retryID set, an _id collision draws a new ID
+// and inserts again, up to MaxGeneratedIDAttempts times. A failed write aborts a MongoDB transaction,
+// so inserts inside one pass false and leave the retry to whoever runs the transaction.
+func InsertUser(ctx context.Context, collection *mongo.Collection, user *models.User, retryID bool) error {
+	for attempt := 1; ; attempt++ {
+		_, err := collection.InsertOne(ctx, user)
+		if err == nil {
+			return nil
+		}
+
+		index, duplicate := DuplicateKeyIndex(err)
+		switch {
+		case duplicate && index == IDIndex && retryID && attempt < MaxGeneratedIDAttempts:
+			user.ID = uuid.New()
+			continue
+		case duplicate && index == UsersUsernameIndex:
+			return models.ErrUsernameExists
+		case duplicate && index == UsersEmailIndex:
+			return models.ErrEmailExists
+		}
+		return err
+	}
+}
+
 // MongoDB represents the MongoDB database connection
 type MongoDB struct {
 	Client   *mongo.Client
 	Database *mongo.Database
 	cfg      *config.Config
+
+	// criticalWrites is the write concern of writes that must survive a failover; nil keeps the client's
+	criticalWrites *writeconcern.WriteConcern
+
+	// sequentialWrites runs writes that would share a transaction one after another, for
+	// deployments without transaction support
+	sequentialWrites bool
 }
 
 // NewMongoDB creates a new MongoDB connection
@@ -27,9 +93,71 @@ func NewMongoDB(cfg *config.Config) (*MongoDB, error) {
 	}, nil
 }
 
+// ParseWriteConcern parses "majority" or a number of acknowledging nodes; empty returns nil,
+// which keeps the server default
+func ParseWriteConcern(value string) (*writeconcern.WriteConcern, error) {
+	switch value {
+	case "":
+		return nil, nil
+	case "majority":
+		return writeconcern.Majority(), nil
+	}
+
+	nodes, err := strconv.Atoi(value)
+	if err != nil || nodes < 0 {
+		return nil, fmt.Errorf("invalid write concern %q: must be majority or a number of nodes", value)
+	}
+	return &writeconcern.WriteConcern{W: nodes}, nil
+}
+
+// ClientOptions builds the client options from the configuration, including TLS, the read preference
+// and the write concern of ordinary writes
+func ClientOptions(cfg *config.Config) (*options.ClientOptions, error) {
+	clientOptions := options.Client().ApplyURI(cfg.GetMongoDBConnString())
+
+	// Reports an unreadable TLS CA file before connecting
+	if err := clientOptions.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid MongoDB connection options: %w", err)
+	}
+
+	mode, err := readpref.ModeFromString(cfg.MongoDBReadPreference)
+	if err != nil {
+		return nil, fmt.Errorf("invalid read preference %q: %w", cfg.MongoDBReadPreference, err)
+	}
+	readPreference, err := readpref.New(mode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid read preference %q: %w", cfg.MongoDBReadPreference, err)
+	}
+	clientOptions.SetReadPreference(readPreference)
+
+	writeConcern, err := ParseWriteConcern(cfg.MongoDBWriteConcern)
+	if err != nil {
+		return nil, err
+	}
+	if writeConcern != nil {
+		clientOptions.SetWriteConcern(writeConcern)
+	}
+
+	return clientOptions, nil
+}
+
 // Connect establishes a connection to the database
 func (db *MongoDB) Connect(ctx context.Context) error {
-	clientOptions := options.Client().ApplyURI(db.cfg.GetMongoDBConnString())
+	clientOptions, err := ClientOptions(db.cfg)
+	if err != nil {
+		return err
+	}
+
+	criticalWrites, err := ParseWriteConcern(db.cfg.MongoDBCriticalWriteConcern)
+	if err != nil {
+		return err
+	}
+
+	// Report slow commands when a threshold is configured
+	slowQueries := NewSlowQueryLogger(db.cfg.GetSlowQueryThreshold())
+	if slowQueries.Enabled() {
+		clientOptions.SetMonitor(slowQueries.CommandMonitor())
+	}
 
 	// Set a timeout for the connection
 	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
@@ -48,13 +176,88 @@ func (db *MongoDB) Connect(ctx context.Context) error {
 		return fmt.Errorf("failed to ping MongoDB: %w", err)
 	}
 
+	if err := db.configureTransactions(ctx, client); err != nil {
+		return err
+	}
+
 	db.Client = client
 	db.Database = client.Database(db.cfg.MongoDBName)
+	db.criticalWrites = criticalWrites
 
 	log.Info().Msg("Connected to MongoDB successfully")
 	return nil
 }
 
+// configureTransactions decides, following MONGODB_TRANSACTIONS, whether multi-document writes run
+// in transactions. A standalone server has none: auto falls back to sequential writes with a
+// warning and required fails.
+func (db *MongoDB) configureTransactions(ctx context.Context, client *mongo.Client) error {
+	if db.cfg.MongoDBTransactions == config.MongoTransactionsOff {
+		log.Info().Msg("MongoDB transactions are off; multi-document writes run one after another")
+		db.DisableTransactions()
+		return nil
+	}
+
+	supported, err := TransactionsSupported(ctx, client)
+	if err != nil {
+		return err
+	}
+	if supported {
+		return nil
+	}
+
+	if db.cfg.MongoDBTransactions == config.MongoTransactionsRequired {
+		return fmt.Errorf("MongoDB deployment does not support transactions: run it as a replica set (a single-node replica set will do) or set MONGODB_TRANSACTIONS=auto")
+	}
+
+	log.Warn().Msg("MongoDB deployment is a standalone server without transaction support; multi-document writes run one after another and are not atomic. Run a replica set to use transactions")
+	db.DisableTransactions()
+	return nil
+}
+
+// TransactionsSupported reports whether the deployment client is connected to supports
+// transactions, which takes a replica set member or a mongos router
+func TransactionsSupported(ctx context.Context, client *mongo.Client) (bool, error) {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return false, fmt.Errorf("failed to check MongoDB transaction support: %w", err)
+	}
+
+	return hello.SetName != "" || hello.Msg == "isdbgrid", nil
+}
+
+// DisableTransactions makes writes that would share a transaction run one after another instead
+func (db *MongoDB) DisableTransactions() {
+	db.sequentialWrites = true
+}
+
+// SupportsTransactions reports whether multi-document writes run in transactions
+func (db *MongoDB) SupportsTransactions() bool {
+	return !db.sequentialWrites
+}
+
+// WithTransaction runs fn in a session, inside a transaction when the deployment supports them and
+// one write after another otherwise, in which case a failure keeps the writes made before it
+func (db *MongoDB) WithTransaction(ctx context.Context, fn func(sc mongo.SessionContext) error) error {
+	session, err := db.Client.StartSession()
+	if err != nil {
+		return fmt.Errorf("failed to start MongoDB session: %w", err)
+	}
+	defer session.EndSession(ctx)
+
+	if !db.SupportsTransactions() {
+		return mongo.WithSession(ctx, session, fn)
+	}
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
 // Migrate creates initial collections and indexes for MongoDB
 func (db *MongoDB) Migrate() error {
 	log.Info().Msg("Setting up MongoDB collections and indexes...")
@@ -68,6 +271,7 @@ func (db *MongoDB) Migrate() error {
 		"permissions",
 		"user_roles",
 		"role_permissions",
+		"api_keys",
 	}
 
 	for _, collName := range collections {
@@ -151,6 +355,19 @@ func (db *MongoDB) Migrate() error {
 		return fmt.Errorf("failed to create indexes for role_permissions collection: %w", err)
 	}
 
+	// Index for api_keys collection
+	apiKeyIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "key_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+
+	_, err = db.Database.Collection("api_keys").Indexes().CreateMany(ctx, apiKeyIndexes)
+	if err != nil {
+		return fmt.Errorf("failed to create indexes for api_keys collection: %w", err)
+	}
+
 	// Insert default roles and permissions if needed
 	err = db.seedDefaultData(ctx)
 	if err != nil {
@@ -237,88 +454,17 @@ func (db *MongoDB) seedDefaultData(ctx context.Context) error {
 	if userReadPermCount == 0 {
 		log.Info().Msg("Seeding default permissions...")
 
-		defaultPermissions := []interface{}{
-			bson.M{
-				"_id":         generateObjectID(),
-				"name":        "user:read",
-				"resource":    "user",
-				"action":      "read",
-				"description": "View user information",
-				"created_at":  time.Now(),
-				"updated_at":  time.Now(),
-			},
-			bson.M{
-				"_id":         generateObjectID(),
-				"name":        "user:write",
-				"resource":    "user",
-				"action":      "write",
-				"description": "Create or modify users",
-				"created_at":  time.Now(),
-				"updated_at":  time.Now(),
-			},
-			bson.M{
-				"_id":         generateObjectID(),
-				"name":        "user:delete",
-				"resource":    "user",
-				"action":      "delete",
-				"description": "Delete users",
-				"created_at":  time.Now(),
-				"updated_at":  time.Now(),
-			},
-			bson.M{
+		defaultPermissions := make([]interface{}, 0, len(models.BuiltinPermissions))
+		for _, permission := range models.BuiltinPermissions {
+			defaultPermissions = append(defaultPermissions, bson.M{
 				"_id":         generateObjectID(),
-				"name":        "role:read",
-				"resource":    "role",
-				"action":      "read",
-				"description": "View role information",
+				"name":        permission.Name,
+				"resource":    permission.Resource,
+				"action":      permission.Action,
+				"description": permission.Description,
 				"created_at":  time.Now(),
 				"updated_at":  time.Now(),
-			},
-			bson.M{
-				"_id":         generateObjectID(),
-				"name":        "role:write",
-				"resource":    "role",
-				"action":      "write",
-				"description": "Create or modify roles",
-				"created_at":  time.Now(),
-				"updated_at":  time.Now(),
-			},
-			bson.M{
-				"_id":         generateObjectID(),
-				"name":        "role:delete",
-				"resource":    "role",
-				"action":      "delete",
-				"description": "Delete roles",
-				"created_at":  time.Now(),
-				"updated_at":  time.Now(),
-			},
-			bson.M{
-				"_id":         generateObjectID(),
-				"name":        "permission:read",
-				"resource":    "permission",
-				"action":      "read",
-				"description": "View permission information",
-				"created_at":  time.Now(),
-				"updated_at":  time.Now(),
-			},
-			bson.M{
-				"_id":         generateObjectID(),
-				"name":        "permission:write",
-				"resource":    "permission",
-				"action":      "write",
-				"description": "Create or modify permissions",
-				"created_at":  time.Now(),
-				"updated_at":  time.Now(),
-			},
-			bson.M{
-				"_id":         generateObjectID(),
-				"name":        "permission:delete",
-				"resource":    "permission",
-				"action":      "delete",
-				"description": "Delete permissions",
-				"created_at":  time.Now(),
-				"updated_at":  time.Now(),
-			},
+			})
 		}
 
 		_, err = db.Database.Collection("permissions").InsertMany(ctx, defaultPermissions)
@@ -327,6 +473,12 @@ func (db *MongoDB) seedDefaultData(ctx context.Context) error {
 		}
 	}
 
+	// A configured initial admin is created at startup instead of the default admin user, so only
+	// the admin role's permissions are seeded for it
+	if db.cfg.HasInitialAdmin() {
+		return db.seedAdminRolePermissions(ctx)
+	}
+
 	// Check if admin user exists
 	adminUserCount, err := db.Database.Collection("users").CountDocuments(ctx, bson.M{"username": "admin"})
 	if err != nil {
@@ -383,35 +535,63 @@ func (db *MongoDB) seedDefaultData(ctx context.Context) error {
 		}
 
 		// Assign all permissions to admin role
-		// First get all permissions
-		permissionsCursor, err := db.Database.Collection("permissions").Find(ctx, bson.M{})
-		if err != nil {
-			return fmt.Errorf("failed to find permissions: %w", err)
+		if err := db.grantAllPermissions(ctx, adminRoleDoc["_id"]); err != nil {
+			return err
 		}
-		defer permissionsCursor.Close(ctx)
-
-		// Assign each permission to admin role
-		var rolePermissions []interface{}
-		for permissionsCursor.Next(ctx) {
-			var permDoc bson.M
-			if err := permissionsCursor.Decode(&permDoc); err != nil {
-				return fmt.Errorf("failed to decode permission: %w", err)
-			}
-
-			rolePermission := bson.M{
-				"role_id":       adminRoleDoc["_id"],
-				"permission_id": permDoc["_id"],
-				"created_at":    time.Now(),
-			}
-
-			rolePermissions = append(rolePermissions, rolePermission)
+	}
+
+	return nil
+}
+
+// seedAdminRolePermissions grants the admin role every permission while it has none
+func (db *MongoDB) seedAdminRolePermissions(ctx context.Context) error {
+	var adminRoleDoc bson.M
+	err := db.Database.Collection("roles").FindOne(ctx, bson.M{"name": "admin"}).Decode(&adminRoleDoc)
+	if err != nil {
+		return fmt.Errorf("failed to find admin role: %w", err)
+	}
+
+	granted, err := db.Database.Collection("role_permissions").CountDocuments(ctx, bson.M{"role_id": adminRoleDoc["_id"]})
+	if err != nil {
+		return fmt.Errorf("failed to count admin role permissions: %w", err)
+	}
+	if granted > 0 {
+		return nil
+	}
+
+	return db.grantAllPermissions(ctx, adminRoleDoc["_id"])
+}
+
+// grantAllPermissions assigns every permission to the role
+func (db *MongoDB) grantAllPermissions(ctx context.Context, roleID interface{}) error {
+	// First get all permissions
+	permissionsCursor, err := db.Database.Collection("permissions").Find(ctx, bson.M{})
+	if err != nil {
+		return fmt.Errorf("failed to find permissions: %w", err)
+	}
+	defer permissionsCursor.Close(ctx)
+
+	// Assign each permission to the role
+	var rolePermissions []interface{}
+	for permissionsCursor.Next(ctx) {
+		var permDoc bson.M
+		if err := permissionsCursor.Decode(&permDoc); err != nil {
+			return fmt.Errorf("failed to decode permission: %w", err)
+		}
+
+		rolePermission := bson.M{
+			"role_id":       roleID,
+			"permission_id": permDoc["_id"],
+			"created_at":    time.Now(),
 		}
 
-		if len(rolePermissions) > 0 {
-			_, err = db.Database.Collection("role_permissions").InsertMany(ctx, rolePermissions)
-			if err != nil {
-				return fmt.Errorf("failed to assign permissions to admin role: %w", err)
-			}
+		rolePermissions = append(rolePermissions, rolePermission)
+	}
+
+	if len(rolePermissions) > 0 {
+		_, err = db.Database.Collection("role_permissions").InsertMany(ctx, rolePermissions)
+		if err != nil {
+			return fmt.Errorf("failed to assign permissions to admin role: %w", err)
 		}
 	}
 
@@ -432,6 +612,14 @@ func (db *MongoDB) Close() error {
 	return nil
 }
 
+// Ping checks that the database is reachable
+func (db *MongoDB) Ping(ctx context.Context) error {
+	if db.Client == nil {
+		return fmt.Errorf("MongoDB is not connected")
+	}
+	return db.Client.Ping(ctx, readpref.Primary())
+}
+
 // GetImplementation returns the actual database implementation
 func (db *MongoDB) GetImplementation() interface{} {
 	return db
@@ -441,3 +629,13 @@ func (db *MongoDB) GetImplementation() interface{} {
 func (db *MongoDB) GetCollection(name string) *mongo.Collection {
 	return db.Database.Collection(name)
 }
+
+// GetCriticalCollection returns a MongoDB collection whose writes use the critical write concern,
+// for writes such as user creation that must not be lost on failover. Writes inside a transaction
+// take the transaction's write concern instead.
+func (db *MongoDB) GetCriticalCollection(name string) *mongo.Collection {
+	if db.criticalWrites == nil {
+		return db.GetCollection(name)
+	}
+	return db.Database.Collection(name, options.Collection().SetWriteConcern(db.criticalWrites))
+}
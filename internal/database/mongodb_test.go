@@ -0,0 +1,196 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/chats/go-user-api/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+func TestClientOptions(t *testing.T) {
+	base := config.Config{MongoDBHost: "localhost", MongoDBPort: "27017", MongoDBName: "user_api"}
+
+	t.Run("Configured read preference and write concern", func(t *testing.T) {
+		cfg := base
+		cfg.MongoDBReadPreference = "secondaryPreferred"
+		cfg.MongoDBWriteConcern = "majority"
+
+		opts, err := ClientOptions(&cfg)
+		require.NoError(t, err)
+
+		assert.Equal(t, readpref.SecondaryPreferredMode, opts.ReadPreference.Mode())
+		assert.Equal(t, writeconcern.Majority(), opts.WriteConcern)
+	})
+
+	t.Run("Numeric write concern", func(t *testing.T) {
+		cfg := base
+		cfg.MongoDBReadPreference = "primary"
+		cfg.MongoDBWriteConcern = "2"
+
+		opts, err := ClientOptions(&cfg)
+		require.NoError(t, err)
+
+		assert.Equal(t, readpref.PrimaryMode, opts.ReadPreference.Mode())
+		assert.Equal(t, &writeconcern.WriteConcern{W: 2}, opts.WriteConcern)
+	})
+
+	t.Run("Empty write concern keeps the server default", func(t *testing.T) {
+		cfg := base
+		cfg.MongoDBReadPreference = "nearest"
+
+		opts, err := ClientOptions(&cfg)
+		require.NoError(t, err)
+
+		assert.Equal(t, readpref.NearestMode, opts.ReadPreference.Mode())
+		assert.Nil(t, opts.WriteConcern)
+	})
+
+	t.Run("Invalid read preference", func(t *testing.T) {
+		cfg := base
+		cfg.MongoDBReadPreference = "fastest"
+
+		_, err := ClientOptions(&cfg)
+		assert.Error(t, err)
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		cfg := base
+		cfg.MongoDBReadPreference = "primary"
+
+		opts, err := ClientOptions(&cfg)
+		require.NoError(t, err)
+		assert.Nil(t, opts.TLSConfig)
+
+		cfg.MongoDBTLS = true
+		opts, err = ClientOptions(&cfg)
+		require.NoError(t, err)
+		require.NotNil(t, opts.TLSConfig)
+		assert.Nil(t, opts.TLSConfig.RootCAs, "system roots verify the server without a CA file")
+	})
+
+	t.Run("Unreadable TLS CA file", func(t *testing.T) {
+		cfg := base
+		cfg.MongoDBReadPreference = "primary"
+		cfg.MongoDBTLS = true
+		cfg.MongoDBTLSCAFile = filepath.Join(t.TempDir(), "missing-ca.pem")
+
+		_, err := ClientOptions(&cfg)
+		assert.ErrorContains(t, err, "missing-ca.pem")
+	})
+}
+
+func TestParseWriteConcern(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    *writeconcern.WriteConcern
+		wantErr bool
+	}{
+		{value: "", want: nil},
+		{value: "majority", want: writeconcern.Majority()},
+		{value: "1", want: &writeconcern.WriteConcern{W: 1}},
+		{value: "0", want: &writeconcern.WriteConcern{W: 0}},
+		{value: "-1", wantErr: true},
+		{value: "all", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseWriteConcern(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestConfigureTransactions(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	configure := func(mt *mtest.T, mode string) (*MongoDB, error) {
+		db := &MongoDB{cfg: &config.Config{MongoDBTransactions: mode}}
+		err := db.configureTransactions(context.Background(), mt.Client)
+		return db, err
+	}
+
+	mt.Run("Replica set uses transactions", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "setName", Value: "rs0"}))
+
+		db, err := configure(mt, config.MongoTransactionsRequired)
+
+		require.NoError(mt, err)
+		assert.True(mt, db.SupportsTransactions())
+	})
+
+	mt.Run("Mongos uses transactions", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "msg", Value: "isdbgrid"}))
+
+		db, err := configure(mt, config.MongoTransactionsAuto)
+
+		require.NoError(mt, err)
+		assert.True(mt, db.SupportsTransactions())
+	})
+
+	mt.Run("Standalone falls back to sequential writes", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		db, err := configure(mt, config.MongoTransactionsAuto)
+
+		require.NoError(mt, err)
+		assert.False(mt, db.SupportsTransactions())
+	})
+
+	mt.Run("Standalone refused when transactions are required", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		_, err := configure(mt, config.MongoTransactionsRequired)
+
+		require.Error(mt, err)
+		assert.Contains(mt, err.Error(), "replica set")
+	})
+
+	mt.Run("Off skips the check", func(mt *mtest.T) {
+		db, err := configure(mt, config.MongoTransactionsOff)
+
+		require.NoError(mt, err)
+		assert.False(mt, db.SupportsTransactions())
+		assert.Empty(mt, mt.GetAllStartedEvents())
+	})
+}
+
+func TestMongoDB_WithTransactionSequential(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("Writes run without a transaction", func(mt *mtest.T) {
+		db := &MongoDB{Client: mt.Client, Database: mt.DB}
+		db.DisableTransactions()
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+
+		err := db.WithTransaction(context.Background(), func(sc mongo.SessionContext) error {
+			if _, err := db.GetCollection("users").DeleteOne(sc, bson.M{"username": "johndoe"}); err != nil {
+				return err
+			}
+			_, err := db.GetCollection("user_roles").DeleteMany(sc, bson.M{"username": "johndoe"})
+			return err
+		})
+		require.NoError(mt, err)
+
+		events := mt.GetAllStartedEvents()
+		require.Len(mt, events, 2)
+		for _, event := range events {
+			assert.Equal(mt, "delete", event.CommandName)
+			_, lookupErr := event.Command.LookupErr("startTransaction")
+			assert.Error(mt, lookupErr, "sequential writes must not start a transaction")
+		}
+	})
+}
@@ -2,26 +2,61 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
 
 	"github.com/chats/go-user-api/config"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/rs/zerolog/log"
 )
 
+// Names of the unique constraints, as PostgreSQL derives them from the columns
+const (
+	UsersUsernameKey             = "users_username_key"
+	UsersEmailKey                = "users_email_key"
+	RolesNameKey                 = "roles_name_key"
+	PermissionsNameKey           = "permissions_name_key"
+	PermissionsResourceActionKey = "permissions_resource_action_key"
+)
+
+// UniqueViolation reports whether err is a unique violation (23505) and returns the name of the
+// constraint it violated
+func UniqueViolation(err error) (string, bool) {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" {
+		return "", false
+	}
+	return pqErr.Constraint, true
+}
+
+// defaultAdminSQL creates the default admin user (password is 'adminpassword') and assigns it the admin role
+const defaultAdminSQL = `
+INSERT INTO users (username, email, password, first_name, last_name) 
+VALUES ('admin', 'admin@example.com', '$2a$10$FPS/DKJWlcHvU1fJuDEYDO0IXNoXQw./hCBlh90AogplwklD7PylC', 'Admin', 'User')
+ON CONFLICT (username) DO NOTHING;
+
+INSERT INTO user_roles (user_id, role_id)
+SELECT 
+    (SELECT id FROM users WHERE username = 'admin'),
+    (SELECT id FROM roles WHERE name = 'admin')
+ON CONFLICT DO NOTHING;`
+
 // PostgresDB represents the PostgreSQL database connection
 type PostgresDB struct {
 	*sqlx.DB
-	cfg *config.Config
+	cfg         *config.Config
+	slowQueries *SlowQueryLogger
 }
 
 // NewPostgresDB creates a new PostgreSQL database connection
 func NewPostgresDB(cfg *config.Config) (*PostgresDB, error) {
 	return &PostgresDB{
-		cfg: cfg,
+		cfg:         cfg,
+		slowQueries: NewSlowQueryLogger(cfg.GetSlowQueryThreshold()),
 	}, nil
 }
 
@@ -56,10 +91,47 @@ func (db *PostgresDB) Migrate() error {
 		return fmt.Errorf("failed to execute migration: %w", err)
 	}
 
+	// A configured initial admin is created at startup instead of the insecure default
+	if !db.cfg.HasInitialAdmin() {
+		if _, err := db.ExecContext(context.Background(), defaultAdminSQL); err != nil {
+			return fmt.Errorf("failed to seed default admin user: %w", err)
+		}
+	}
+
 	log.Info().Msg("PostgreSQL database migrations applied successfully")
 	return nil
 }
 
+// GetContext runs a single-row query and reports it if it is slow
+func (db *PostgresDB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	defer db.slowQueries.Track(ctx, query)()
+	return db.DB.GetContext(ctx, dest, query, args...)
+}
+
+// SelectContext runs a multi-row query and reports it if it is slow
+func (db *PostgresDB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	defer db.slowQueries.Track(ctx, query)()
+	return db.DB.SelectContext(ctx, dest, query, args...)
+}
+
+// ExecContext executes a statement and reports it if it is slow
+func (db *PostgresDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer db.slowQueries.Track(ctx, query)()
+	return db.DB.ExecContext(ctx, query, args...)
+}
+
+// QueryxContext runs a query returning rows and reports it if it is slow
+func (db *PostgresDB) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	defer db.slowQueries.Track(ctx, query)()
+	return db.DB.QueryxContext(ctx, query, args...)
+}
+
+// QueryRowxContext runs a query returning a single row and reports it if it is slow
+func (db *PostgresDB) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	defer db.slowQueries.Track(ctx, query)()
+	return db.DB.QueryRowxContext(ctx, query, args...)
+}
+
 // Close closes the database connection
 func (db *PostgresDB) Close() error {
 	if db.DB != nil {
@@ -68,6 +140,14 @@ func (db *PostgresDB) Close() error {
 	return nil
 }
 
+// Ping checks that the database is reachable
+func (db *PostgresDB) Ping(ctx context.Context) error {
+	if db.DB == nil {
+		return fmt.Errorf("PostgreSQL database is not connected")
+	}
+	return db.DB.PingContext(ctx)
+}
+
 // GetImplementation returns the actual database implementation
 func (db *PostgresDB) GetImplementation() interface{} {
 	return db.DB
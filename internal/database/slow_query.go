@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SlowQueryLogger logs database operations that exceed a configured threshold
+type SlowQueryLogger struct {
+	threshold time.Duration
+	logger    zerolog.Logger
+}
+
+// NewSlowQueryLogger creates a new slow query logger using the global logger.
+// A threshold of zero or less disables slow query logging.
+func NewSlowQueryLogger(threshold time.Duration) *SlowQueryLogger {
+	return NewSlowQueryLoggerWithLogger(threshold, log.Logger)
+}
+
+// NewSlowQueryLoggerWithLogger creates a new slow query logger writing to the given logger
+func NewSlowQueryLoggerWithLogger(threshold time.Duration, logger zerolog.Logger) *SlowQueryLogger {
+	return &SlowQueryLogger{
+		threshold: threshold,
+		logger:    logger,
+	}
+}
+
+// Enabled returns whether slow query logging is active
+func (l *SlowQueryLogger) Enabled() bool {
+	return l != nil && l.threshold > 0
+}
+
+// Track starts timing an operation and returns a function that records it when called
+func (l *SlowQueryLogger) Track(ctx context.Context, operation string) func() {
+	if !l.Enabled() {
+		return func() {}
+	}
+
+	start := time.Now()
+	return func() {
+		l.Observe(ctx, operation, time.Since(start))
+	}
+}
+
+// Observe logs the operation if its duration exceeds the threshold
+func (l *SlowQueryLogger) Observe(ctx context.Context, operation string, elapsed time.Duration) {
+	if !l.Enabled() || elapsed < l.threshold {
+		return
+	}
+
+	entry := l.logger.Warn().
+		Str("operation", compactQuery(operation)).
+		Dur("duration", elapsed).
+		Dur("threshold", l.threshold)
+
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.HasTraceID() {
+		entry = entry.Str("trace_id", spanCtx.TraceID().String())
+	}
+
+	entry.Msg("Slow query detected")
+}
+
+// CommandMonitor returns a MongoDB command monitor that reports slow commands
+func (l *SlowQueryLogger) CommandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			l.Observe(ctx, "mongodb:"+evt.DatabaseName+"."+evt.CommandName, evt.Duration)
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			l.Observe(ctx, "mongodb:"+evt.DatabaseName+"."+evt.CommandName, evt.Duration)
+		},
+	}
+}
+
+// compactQuery collapses whitespace so multi-line SQL fits on one log line
+func compactQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
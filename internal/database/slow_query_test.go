@@ -0,0 +1,73 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// slowOperation simulates a repository call that takes the given duration
+func slowOperation(ctx context.Context, l *SlowQueryLogger, query string, d time.Duration) {
+	defer l.Track(ctx, query)()
+	time.Sleep(d)
+}
+
+func TestSlowQueryLogger_LogsSlowOperation(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewSlowQueryLoggerWithLogger(10*time.Millisecond, zerolog.New(&buf))
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+
+	slowOperation(ctx, l, "SELECT *\n\t\tFROM users\n\t\tWHERE id = $1", 20*time.Millisecond)
+
+	out := buf.String()
+	assert.Contains(t, out, "Slow query detected")
+	assert.Contains(t, out, "SELECT * FROM users WHERE id = $1")
+	assert.Contains(t, out, traceID.String())
+}
+
+func TestSlowQueryLogger_IgnoresFastOperation(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewSlowQueryLoggerWithLogger(time.Second, zerolog.New(&buf))
+
+	slowOperation(context.Background(), l, "SELECT 1", 0)
+
+	assert.Empty(t, buf.String())
+}
+
+func TestSlowQueryLogger_Disabled(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewSlowQueryLoggerWithLogger(0, zerolog.New(&buf))
+
+	assert.False(t, l.Enabled())
+	l.Observe(context.Background(), "SELECT 1", time.Hour)
+
+	assert.Empty(t, buf.String())
+}
+
+func TestSlowQueryLogger_CommandMonitor(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewSlowQueryLoggerWithLogger(10*time.Millisecond, zerolog.New(&buf))
+
+	monitor := l.CommandMonitor()
+	monitor.Succeeded(context.Background(), &event.CommandSucceededEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{
+			CommandName:  "find",
+			DatabaseName: "user-api",
+			Duration:     50 * time.Millisecond,
+		},
+	})
+
+	assert.Contains(t, buf.String(), "mongodb:user-api.find")
+}
@@ -0,0 +1,276 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Publisher delivers an event to the message broker
+type Publisher interface {
+	Publish(ctx context.Context, event Envelope) error
+}
+
+// Pinger is implemented by publishers that can check their broker connection, such as a producer
+// reaching its brokers or a channel being open
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// LogPublisher writes events to the application log; it stands in until a broker producer is configured
+type LogPublisher struct{}
+
+// Publish logs the event
+func (LogPublisher) Publish(_ context.Context, event Envelope) error {
+	log.Info().
+		Str("event_id", event.EventID).
+		Str("event_type", event.Type).
+		Str("actor_id", event.Actor.ID).
+		Interface("payload", event.Payload).
+		Msg("Activity event")
+	return nil
+}
+
+// DispatcherConfig tunes the event buffer and the circuit breaker in front of the publisher
+type DispatcherConfig struct {
+	// BufferSize is the number of events queued for the worker; events beyond it are dropped
+	BufferSize int
+	// PublishTimeout bounds each publish call
+	PublishTimeout time.Duration
+	// FailureThreshold is the number of consecutive publish failures that open the breaker (0 disables it)
+	FailureThreshold int
+	// Cooldown is how long the breaker stays open before a publish is tried again
+	Cooldown time.Duration
+}
+
+// DispatcherStats counts what happened to emitted events
+type DispatcherStats struct {
+	Queued             int   `json:"queued"`
+	Published          int64 `json:"published"`
+	Failed             int64 `json:"failed"`
+	DroppedBufferFull  int64 `json:"dropped_buffer_full"`
+	DroppedBreakerOpen int64 `json:"dropped_breaker_open"`
+	BreakerOpen        bool  `json:"breaker_open"`
+	// LastPublishedAt is when an event was last published successfully; nil until one is
+	LastPublishedAt *time.Time `json:"last_published_at,omitempty"`
+}
+
+// Dispatcher publishes events from a bounded buffer on a background worker, so request handling
+// never waits on the broker. Events are dropped and counted when the buffer is full or the breaker
+// is open.
+type Dispatcher struct {
+	publisher      Publisher
+	publishTimeout time.Duration
+	breaker        *circuitBreaker
+	queue          chan Envelope
+
+	// mu guards closed against emits racing the queue being closed
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+
+	published          atomic.Int64
+	failed             atomic.Int64
+	droppedBufferFull  atomic.Int64
+	droppedBreakerOpen atomic.Int64
+	// lastPublishedAt is the Unix nanoseconds of the last successful publish, 0 before any
+	lastPublishedAt atomic.Int64
+}
+
+// NewDispatcher creates a dispatcher and starts its worker
+func NewDispatcher(publisher Publisher, cfg DispatcherConfig) *Dispatcher {
+	bufferSize := cfg.BufferSize
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+
+	d := &Dispatcher{
+		publisher:      publisher,
+		publishTimeout: cfg.PublishTimeout,
+		breaker:        newCircuitBreaker(cfg.FailureThreshold, cfg.Cooldown, time.Now),
+		queue:          make(chan Envelope, bufferSize),
+		done:           make(chan struct{}),
+	}
+	go d.run()
+
+	return d
+}
+
+// Emit queues an event without blocking and reports whether it was accepted
+func (d *Dispatcher) Emit(event Envelope) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		return false
+	}
+
+	if !d.breaker.Allow() {
+		d.droppedBreakerOpen.Add(1)
+		return false
+	}
+
+	select {
+	case d.queue <- event:
+		return true
+	default:
+		d.droppedBufferFull.Add(1)
+		log.Warn().Str("event_type", event.Type).Msg("Event buffer full, dropping event")
+		return false
+	}
+}
+
+// Stats returns the current event counters
+func (d *Dispatcher) Stats() DispatcherStats {
+	return DispatcherStats{
+		Queued:             len(d.queue),
+		Published:          d.published.Load(),
+		Failed:             d.failed.Load(),
+		DroppedBufferFull:  d.droppedBufferFull.Load(),
+		DroppedBreakerOpen: d.droppedBreakerOpen.Load(),
+		BreakerOpen:        !d.breaker.Allow(),
+		LastPublishedAt:    d.LastPublishedAt(),
+	}
+}
+
+// LastPublishedAt returns when an event was last published successfully, or nil before any was
+func (d *Dispatcher) LastPublishedAt() *time.Time {
+	nanos := d.lastPublishedAt.Load()
+	if nanos == 0 {
+		return nil
+	}
+	publishedAt := time.Unix(0, nanos)
+	return &publishedAt
+}
+
+// Check reports whether events can be published: the dispatcher is open, the breaker is closed and
+// the publisher, when it can tell, reaches its broker
+func (d *Dispatcher) Check(ctx context.Context) error {
+	d.mu.RLock()
+	closed := d.closed
+	d.mu.RUnlock()
+	if closed {
+		return errors.New("event dispatcher is closed")
+	}
+
+	if !d.breaker.Allow() {
+		return errors.New("event publishing is failing, circuit breaker is open")
+	}
+
+	if pinger, ok := d.publisher.(Pinger); ok {
+		if err := pinger.Ping(ctx); err != nil {
+			return fmt.Errorf("message broker is unreachable: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Close stops accepting events and waits for the worker to flush the buffer or for ctx to end
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run publishes queued events until the queue is closed and drained
+func (d *Dispatcher) run() {
+	defer close(d.done)
+
+	for event := range d.queue {
+		// Events queued before the breaker opened are dropped rather than left to time out
+		if !d.breaker.Allow() {
+			d.droppedBreakerOpen.Add(1)
+			continue
+		}
+
+		d.publish(event)
+	}
+}
+
+// publish delivers one event and feeds the result to the breaker
+func (d *Dispatcher) publish(event Envelope) {
+	ctx := context.Background()
+	if d.publishTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.publishTimeout)
+		defer cancel()
+	}
+
+	if err := d.publisher.Publish(ctx, event); err != nil {
+		d.failed.Add(1)
+		if d.breaker.RecordFailure() {
+			log.Warn().Err(err).Msg("Event publishing failing, circuit breaker opened")
+		}
+		log.Debug().Err(err).Str("event_id", event.EventID).Msg("Failed to publish event")
+		return
+	}
+
+	d.published.Add(1)
+	d.lastPublishedAt.Store(time.Now().UnixNano())
+	d.breaker.RecordSuccess()
+}
+
+// circuitBreaker opens after threshold consecutive failures and lets a publish through again once
+// cooldown has passed; another failure then reopens it
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+	failures  int
+	openedAt  time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration, now func() time.Time) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, now: now}
+}
+
+// Allow reports whether a publish may be attempted
+func (b *circuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return true
+	}
+	return b.now().Sub(b.openedAt) >= b.cooldown
+}
+
+// RecordFailure counts a failed publish and reports whether it opened the breaker
+func (b *circuitBreaker) RecordFailure() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return false
+	}
+
+	wasClosed := b.failures == b.threshold
+	b.openedAt = b.now()
+	return wasClosed
+}
+
+// RecordSuccess closes the breaker
+func (b *circuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+}
@@ -0,0 +1,217 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePublisher records published events; when started is set, each publish signals it and waits for a release
+type fakePublisher struct {
+	mu        sync.Mutex
+	published []Envelope
+	err       error
+	started   chan struct{}
+	release   chan struct{}
+}
+
+func (p *fakePublisher) Publish(_ context.Context, event Envelope) error {
+	if p.started != nil {
+		p.started <- struct{}{}
+		<-p.release
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil {
+		return p.err
+	}
+	p.published = append(p.published, event)
+	return nil
+}
+
+func (p *fakePublisher) Published() []Envelope {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]Envelope(nil), p.published...)
+}
+
+func TestDispatcher(t *testing.T) {
+	t.Run("Publishes emitted events and flushes on close", func(t *testing.T) {
+		publisher := &fakePublisher{}
+		dispatcher := NewDispatcher(publisher, DispatcherConfig{BufferSize: 10, FailureThreshold: 3, Cooldown: time.Minute})
+
+		for _, eventType := range []string{TypeUserCreated, TypeUserUpdated, TypeUserDeleted} {
+			assert.True(t, dispatcher.Emit(NewEvent(eventType).Build()))
+		}
+
+		require.NoError(t, dispatcher.Close(context.Background()))
+
+		published := publisher.Published()
+		require.Len(t, published, 3)
+		assert.Equal(t, TypeUserDeleted, published[2].Type)
+		assert.Equal(t, int64(3), dispatcher.Stats().Published)
+
+		// Nothing is accepted after close
+		assert.False(t, dispatcher.Emit(NewEvent(TypeUserCreated).Build()))
+	})
+
+	t.Run("Drops events when the buffer is full", func(t *testing.T) {
+		publisher := &fakePublisher{started: make(chan struct{}), release: make(chan struct{})}
+		dispatcher := NewDispatcher(publisher, DispatcherConfig{BufferSize: 1})
+
+		// The worker holds the first event, the second fills the buffer
+		require.True(t, dispatcher.Emit(NewEvent(TypeUserCreated).Build()))
+		<-publisher.started
+		require.True(t, dispatcher.Emit(NewEvent(TypeUserUpdated).Build()))
+
+		// Emit returns immediately instead of waiting on the stalled publisher
+		assert.False(t, dispatcher.Emit(NewEvent(TypeUserDeleted).Build()))
+		assert.False(t, dispatcher.Emit(NewEvent(TypeUserDeleted).Build()))
+
+		stats := dispatcher.Stats()
+		assert.Equal(t, int64(2), stats.DroppedBufferFull)
+		assert.Equal(t, 1, stats.Queued)
+
+		go func() {
+			for range publisher.started {
+				publisher.release <- struct{}{}
+			}
+		}()
+		publisher.release <- struct{}{}
+		require.NoError(t, dispatcher.Close(context.Background()))
+		close(publisher.started)
+
+		assert.Len(t, publisher.Published(), 2)
+	})
+
+	t.Run("Open breaker drops and counts events", func(t *testing.T) {
+		publisher := &fakePublisher{err: errors.New("broker unavailable")}
+		dispatcher := NewDispatcher(publisher, DispatcherConfig{BufferSize: 10, FailureThreshold: 2, Cooldown: time.Hour})
+
+		require.True(t, dispatcher.Emit(NewEvent(TypeUserCreated).Build()))
+		require.True(t, dispatcher.Emit(NewEvent(TypeUserUpdated).Build()))
+		require.Eventually(t, func() bool { return dispatcher.Stats().BreakerOpen }, time.Second, time.Millisecond)
+
+		assert.False(t, dispatcher.Emit(NewEvent(TypeUserDeleted).Build()))
+		assert.False(t, dispatcher.Emit(NewEvent(TypeUserDeleted).Build()))
+
+		stats := dispatcher.Stats()
+		assert.Equal(t, int64(2), stats.Failed)
+		assert.Equal(t, int64(2), stats.DroppedBreakerOpen)
+		assert.Zero(t, stats.Published)
+
+		require.NoError(t, dispatcher.Close(context.Background()))
+	})
+
+	t.Run("Close gives up when the context ends", func(t *testing.T) {
+		publisher := &fakePublisher{started: make(chan struct{}), release: make(chan struct{})}
+		dispatcher := NewDispatcher(publisher, DispatcherConfig{BufferSize: 1})
+
+		require.True(t, dispatcher.Emit(NewEvent(TypeUserCreated).Build()))
+		<-publisher.started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, dispatcher.Close(ctx), context.DeadlineExceeded)
+
+		close(publisher.release)
+	})
+}
+
+// pingingPublisher is a fakePublisher whose broker can be taken down
+type pingingPublisher struct {
+	fakePublisher
+	down error
+}
+
+func (p *pingingPublisher) Ping(context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.down
+}
+
+func (p *pingingPublisher) SetDown(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.down = err
+}
+
+func TestDispatcher_Check(t *testing.T) {
+	t.Run("Follows broker availability", func(t *testing.T) {
+		publisher := &pingingPublisher{}
+		dispatcher := NewDispatcher(publisher, DispatcherConfig{BufferSize: 10})
+		defer dispatcher.Close(context.Background())
+
+		assert.NoError(t, dispatcher.Check(context.Background()))
+
+		publisher.SetDown(errors.New("connection refused"))
+		err := dispatcher.Check(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "connection refused")
+
+		publisher.SetDown(nil)
+		assert.NoError(t, dispatcher.Check(context.Background()))
+	})
+
+	t.Run("Open breaker fails the check", func(t *testing.T) {
+		publisher := &fakePublisher{err: errors.New("broker unavailable")}
+		dispatcher := NewDispatcher(publisher, DispatcherConfig{BufferSize: 10, FailureThreshold: 1, Cooldown: time.Hour})
+		defer dispatcher.Close(context.Background())
+
+		require.True(t, dispatcher.Emit(NewEvent(TypeUserCreated).Build()))
+		require.Eventually(t, func() bool { return dispatcher.Stats().BreakerOpen }, time.Second, time.Millisecond)
+
+		assert.ErrorContains(t, dispatcher.Check(context.Background()), "circuit breaker")
+	})
+
+	t.Run("Closed dispatcher fails the check", func(t *testing.T) {
+		dispatcher := NewDispatcher(&fakePublisher{}, DispatcherConfig{BufferSize: 10})
+		require.NoError(t, dispatcher.Close(context.Background()))
+
+		assert.ErrorContains(t, dispatcher.Check(context.Background()), "closed")
+	})
+
+	t.Run("Records the last successful publish", func(t *testing.T) {
+		dispatcher := NewDispatcher(&fakePublisher{}, DispatcherConfig{BufferSize: 10})
+		assert.Nil(t, dispatcher.LastPublishedAt())
+
+		before := time.Now()
+		require.True(t, dispatcher.Emit(NewEvent(TypeUserCreated).Build()))
+		require.NoError(t, dispatcher.Close(context.Background()))
+
+		publishedAt := dispatcher.LastPublishedAt()
+		require.NotNil(t, publishedAt)
+		assert.False(t, publishedAt.Before(before))
+		assert.Equal(t, publishedAt, dispatcher.Stats().LastPublishedAt)
+	})
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	breaker := newCircuitBreaker(2, time.Minute, func() time.Time { return now })
+
+	assert.False(t, breaker.RecordFailure())
+	assert.True(t, breaker.Allow())
+	assert.True(t, breaker.RecordFailure())
+	assert.False(t, breaker.Allow())
+
+	// After the cooldown one publish is tried again; another failure reopens the breaker
+	now = now.Add(time.Minute)
+	assert.True(t, breaker.Allow())
+	breaker.RecordFailure()
+	assert.False(t, breaker.Allow())
+
+	now = now.Add(time.Minute)
+	breaker.RecordSuccess()
+	assert.True(t, breaker.Allow())
+}
@@ -0,0 +1,94 @@
+package events
+
+import (
+	"time"
+
+	"github.com/chats/go-user-api/internal/logger"
+	"github.com/google/uuid"
+)
+
+// SchemaVersion is the version of the envelope shape; bump it when fields are renamed or removed
+const SchemaVersion = 1
+
+// Activity event types
+const (
+	TypeUserLoggedIn     = "user.logged_in"
+	TypeUserCreated      = "user.created"
+	TypeUserUpdated      = "user.updated"
+	TypeUserDeleted      = "user.deleted"
+	TypeUserRolesChanged = "user.roles_changed"
+	TypeUserDeactivated  = "user.deactivated"
+)
+
+// Actor identifies who caused an event
+type Actor struct {
+	ID       string `json:"id"`
+	Username string `json:"username,omitempty"`
+	Type     string `json:"type"`
+}
+
+// Envelope is the stable top-level shape of every activity event; event-specific data goes in Payload
+type Envelope struct {
+	EventID   string                 `json:"event_id"`
+	Version   int                    `json:"version"`
+	Type      string                 `json:"type"`
+	Timestamp time.Time              `json:"timestamp"`
+	Actor     Actor                  `json:"actor"`
+	Payload   map[string]interface{} `json:"payload"`
+}
+
+// Builder assembles an Envelope
+type Builder struct {
+	envelope Envelope
+}
+
+// NewEvent starts an event of the given type with a fresh ID, the current schema version and time
+func NewEvent(eventType string) *Builder {
+	return &Builder{
+		envelope: Envelope{
+			EventID:   uuid.New().String(),
+			Version:   SchemaVersion,
+			Type:      eventType,
+			Timestamp: time.Now().UTC(),
+			Actor:     Actor{Type: "system"},
+			Payload:   make(map[string]interface{}),
+		},
+	}
+}
+
+// ByUser sets a user as the actor; the username is masked when PII masking is on
+func (b *Builder) ByUser(userID, username string) *Builder {
+	b.envelope.Actor = Actor{ID: userID, Username: logger.MaskUsername(username), Type: "user"}
+	return b
+}
+
+// ByAPIKey sets an API key as the actor
+func (b *Builder) ByAPIKey(keyID, name string) *Builder {
+	b.envelope.Actor = Actor{ID: keyID, Username: name, Type: "api_key"}
+	return b
+}
+
+// With adds a single payload field
+func (b *Builder) With(key string, value interface{}) *Builder {
+	b.envelope.Payload[key] = value
+	return b
+}
+
+// WithPayload merges the fields of payload into the event payload
+func (b *Builder) WithPayload(payload map[string]interface{}) *Builder {
+	for key, value := range payload {
+		b.envelope.Payload[key] = value
+	}
+	return b
+}
+
+// At overrides the event time
+func (b *Builder) At(timestamp time.Time) *Builder {
+	b.envelope.Timestamp = timestamp.UTC()
+	return b
+}
+
+// Build returns the assembled envelope
+func (b *Builder) Build() Envelope {
+	return b.envelope
+}
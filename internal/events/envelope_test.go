@@ -0,0 +1,98 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/chats/go-user-api/internal/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvelope(t *testing.T) {
+	userID := uuid.New().String()
+
+	tests := []struct {
+		name      string
+		event     Envelope
+		wantType  string
+		wantActor Actor
+	}{
+		{
+			name: "Login",
+			event: NewEvent(TypeUserLoggedIn).
+				ByUser(userID, "johndoe").
+				With("ip", "10.0.0.1").
+				Build(),
+			wantType:  TypeUserLoggedIn,
+			wantActor: Actor{ID: userID, Username: "johndoe", Type: "user"},
+		},
+		{
+			name: "Roles changed by an API key",
+			event: NewEvent(TypeUserRolesChanged).
+				ByAPIKey("key-1", "provisioning").
+				WithPayload(map[string]interface{}{"user_id": userID, "roles": []string{"editor"}}).
+				Build(),
+			wantType:  TypeUserRolesChanged,
+			wantActor: Actor{ID: "key-1", Username: "provisioning", Type: "api_key"},
+		},
+		{
+			name:      "System event",
+			event:     NewEvent(TypeUserUpdated).With("user_id", userID).With("is_active", false).Build(),
+			wantType:  TypeUserUpdated,
+			wantActor: Actor{Type: "system"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.event)
+			require.NoError(t, err)
+
+			var decoded map[string]interface{}
+			require.NoError(t, json.Unmarshal(data, &decoded))
+
+			for _, field := range []string{"event_id", "version", "type", "timestamp", "actor", "payload"} {
+				assert.Contains(t, decoded, field)
+			}
+			assert.Equal(t, float64(SchemaVersion), decoded["version"])
+			assert.Equal(t, tt.wantType, decoded["type"])
+
+			_, err = uuid.Parse(tt.event.EventID)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantActor, tt.event.Actor)
+			assert.NotEmpty(t, tt.event.Payload)
+		})
+	}
+
+	t.Run("Event IDs are unique", func(t *testing.T) {
+		assert.NotEqual(t, NewEvent(TypeUserCreated).Build().EventID, NewEvent(TypeUserCreated).Build().EventID)
+	})
+
+	t.Run("Empty payload is an object", func(t *testing.T) {
+		data, err := json.Marshal(NewEvent(TypeUserDeleted).Build())
+		require.NoError(t, err)
+
+		assert.Contains(t, string(data), `"payload":{}`)
+	})
+
+	t.Run("Timestamp is UTC", func(t *testing.T) {
+		at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("ICT", 7*60*60))
+
+		event := NewEvent(TypeUserCreated).At(at).Build()
+
+		assert.Equal(t, time.UTC, event.Timestamp.Location())
+		assert.True(t, at.Equal(event.Timestamp))
+	})
+
+	t.Run("Actor username is masked when PII masking is on", func(t *testing.T) {
+		logger.SetMaskPII(true)
+		defer logger.SetMaskPII(false)
+
+		event := NewEvent(TypeUserLoggedIn).ByUser(userID, "johndoe").Build()
+
+		assert.Equal(t, "jo***", event.Actor.Username)
+	})
+}
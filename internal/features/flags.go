@@ -0,0 +1,104 @@
+package features
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Endpoint features that can be switched off through FEATURE_FLAGS or at runtime
+const (
+	// BulkOps gates POST /users/bulk-deactivate and POST /rbac/import
+	BulkOps = "bulk_ops"
+	// Export gates GET /rbac/export
+	Export = "export"
+)
+
+// defaults lists every known feature; all are on, so gated endpoints stay available
+// unless a deployment turns them off
+var defaults = map[string]bool{
+	BulkOps: true,
+	Export:  true,
+}
+
+// ErrUnknownFlag is returned when setting a feature that does not exist
+var ErrUnknownFlag = errors.New("unknown feature flag")
+
+// Flag is the state of a feature; Overridden is set when it was changed at runtime
+type Flag struct {
+	Name       string `json:"name"`
+	Enabled    bool   `json:"enabled"`
+	Overridden bool   `json:"overridden"`
+}
+
+// Flags holds whether each feature is enabled: the configured value unless overridden at runtime.
+// Overrides are kept in memory only, so they apply to this instance until it restarts.
+// It is safe for concurrent use.
+type Flags struct {
+	mu         sync.RWMutex
+	configured map[string]bool
+	overrides  map[string]bool
+}
+
+// NewFlags creates the flags from the configured values, ignoring features that do not exist
+func NewFlags(configured map[string]bool) *Flags {
+	flags := &Flags{
+		configured: make(map[string]bool, len(defaults)),
+		overrides:  make(map[string]bool),
+	}
+	for name, enabled := range defaults {
+		flags.configured[name] = enabled
+	}
+
+	for name, enabled := range configured {
+		if _, known := defaults[name]; !known {
+			log.Warn().Str("flag", name).Msg("Ignoring unknown feature flag")
+			continue
+		}
+		flags.configured[name] = enabled
+	}
+	return flags
+}
+
+// Enabled reports whether the named feature is on; unknown features are off
+func (f *Flags) Enabled(name string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if enabled, ok := f.overrides[name]; ok {
+		return enabled
+	}
+	return f.configured[name]
+}
+
+// Set overrides the configured value of a feature until the instance restarts
+func (f *Flags) Set(name string, enabled bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, known := f.configured[name]; !known {
+		return ErrUnknownFlag
+	}
+	f.overrides[name] = enabled
+	return nil
+}
+
+// List returns every feature ordered by name
+func (f *Flags) List() []Flag {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	flags := make([]Flag, 0, len(f.configured))
+	for name, enabled := range f.configured {
+		override, overridden := f.overrides[name]
+		if overridden {
+			enabled = override
+		}
+		flags = append(flags, Flag{Name: name, Enabled: enabled, Overridden: overridden})
+	}
+
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags
+}
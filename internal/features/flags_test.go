@@ -0,0 +1,38 @@
+package features
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlags(t *testing.T) {
+	t.Run("Known features default to on", func(t *testing.T) {
+		flags := NewFlags(nil)
+
+		assert.True(t, flags.Enabled(BulkOps))
+		assert.True(t, flags.Enabled(Export))
+		assert.False(t, flags.Enabled("webhooks"))
+	})
+
+	t.Run("Configured values apply and unknown ones are ignored", func(t *testing.T) {
+		flags := NewFlags(map[string]bool{Export: false, "webhooks": true})
+
+		assert.False(t, flags.Enabled(Export))
+		assert.False(t, flags.Enabled("webhooks"))
+		assert.Len(t, flags.List(), 2)
+	})
+
+	t.Run("Runtime override", func(t *testing.T) {
+		flags := NewFlags(map[string]bool{Export: false})
+
+		assert.NoError(t, flags.Set(Export, true))
+		assert.True(t, flags.Enabled(Export))
+		assert.Equal(t, []Flag{
+			{Name: BulkOps, Enabled: true},
+			{Name: Export, Enabled: true, Overridden: true},
+		}, flags.List())
+
+		assert.ErrorIs(t, flags.Set("webhooks", true), ErrUnknownFlag)
+	})
+}
@@ -0,0 +1,155 @@
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Dependencies tracked by the registry
+const (
+	DependencyDatabase  = "database"
+	DependencyCache     = "cache"
+	DependencyMessaging = "messaging"
+)
+
+// Status is the last known health of a dependency
+type Status struct {
+	Healthy   bool      `json:"healthy"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+	// Details are read from the dependency when the status is, such as when it last succeeded
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// CheckFunc reports whether a dependency is reachable
+type CheckFunc func(ctx context.Context) error
+
+// DetailsFunc returns the current details of a dependency
+type DetailsFunc func() map[string]interface{}
+
+// Registry tracks the runtime health of the service's dependencies and whether it is ready for traffic.
+// It is safe for concurrent use, so handlers can read it while the watchdog updates it.
+type Registry struct {
+	mu       sync.RWMutex
+	statuses map[string]Status
+	details  map[string]DetailsFunc
+	ready    atomic.Bool
+}
+
+// NewRegistry creates an empty registry; dependencies are healthy until reported otherwise
+func NewRegistry() *Registry {
+	return &Registry{
+		statuses: make(map[string]Status),
+		details:  make(map[string]DetailsFunc),
+	}
+}
+
+// Set records the health of a dependency; a nil err marks it healthy
+func (r *Registry) Set(name string, err error) {
+	status := Status{
+		Healthy:   err == nil,
+		CheckedAt: time.Now(),
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	previous, known := r.statuses[name]
+	r.statuses[name] = status
+	r.mu.Unlock()
+
+	// Log transitions only, not every check
+	if !known || previous.Healthy != status.Healthy {
+		if status.Healthy {
+			log.Info().Str("dependency", name).Msg("Dependency is healthy")
+		} else {
+			log.Warn().Str("dependency", name).Str("error", status.Error).Msg("Dependency is degraded")
+		}
+	}
+}
+
+// Describe has the status of a dependency carry the details fn returns
+func (r *Registry) Describe(name string, fn DetailsFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.details[name] = fn
+}
+
+// SetReady marks whether the service may receive traffic. It starts out not ready.
+func (r *Registry) SetReady(ready bool) {
+	if r.ready.Swap(ready) != ready {
+		log.Info().Bool("ready", ready).Msg("Readiness changed")
+	}
+}
+
+// Ready reports whether the service may receive traffic
+func (r *Registry) Ready() bool {
+	return r.ready.Load()
+}
+
+// Statuses returns a copy of every recorded status, with the current details of described dependencies
+func (r *Registry) Statuses() map[string]Status {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	statuses := make(map[string]Status, len(r.statuses))
+	for name, status := range r.statuses {
+		if fn, ok := r.details[name]; ok {
+			status.Details = fn()
+		}
+		statuses[name] = status
+	}
+	return statuses
+}
+
+// Degraded returns the sorted names of the unhealthy dependencies
+func (r *Registry) Degraded() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	degraded := make([]string, 0)
+	for name, status := range r.statuses {
+		if !status.Healthy {
+			degraded = append(degraded, name)
+		}
+	}
+	sort.Strings(degraded)
+	return degraded
+}
+
+// Check runs every check once and records the results
+func (r *Registry) Check(ctx context.Context, checks map[string]CheckFunc) {
+	for name, check := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		r.Set(name, check(checkCtx))
+		cancel()
+	}
+}
+
+// Watch runs the checks immediately and then on every interval until ctx is done
+func (r *Registry) Watch(ctx context.Context, interval time.Duration, checks map[string]CheckFunc) {
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r.Check(ctx, checks)
+
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Dependency health watchdog stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
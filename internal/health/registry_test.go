@@ -0,0 +1,123 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Run("Toggling a dependency", func(t *testing.T) {
+		registry := NewRegistry()
+		registry.Set(DependencyDatabase, nil)
+		registry.Set(DependencyCache, nil)
+		assert.Empty(t, registry.Degraded())
+
+		registry.Set(DependencyCache, errors.New("connection refused"))
+		assert.Equal(t, []string{DependencyCache}, registry.Degraded())
+
+		status := registry.Statuses()[DependencyCache]
+		assert.False(t, status.Healthy)
+		assert.Equal(t, "connection refused", status.Error)
+		assert.False(t, status.CheckedAt.IsZero())
+
+		registry.Set(DependencyCache, nil)
+		assert.Empty(t, registry.Degraded())
+		assert.Empty(t, registry.Statuses()[DependencyCache].Error)
+	})
+
+	t.Run("Degraded dependencies are sorted", func(t *testing.T) {
+		registry := NewRegistry()
+		registry.Set(DependencyDatabase, errors.New("down"))
+		registry.Set(DependencyCache, errors.New("down"))
+
+		assert.Equal(t, []string{DependencyCache, DependencyDatabase}, registry.Degraded())
+	})
+
+	t.Run("Check records every result", func(t *testing.T) {
+		registry := NewRegistry()
+
+		registry.Check(context.Background(), map[string]CheckFunc{
+			DependencyDatabase: func(ctx context.Context) error { return nil },
+			DependencyCache:    func(ctx context.Context) error { return errors.New("caching is disabled") },
+		})
+
+		statuses := registry.Statuses()
+		assert.True(t, statuses[DependencyDatabase].Healthy)
+		assert.False(t, statuses[DependencyCache].Healthy)
+	})
+
+	t.Run("Described dependencies carry current details", func(t *testing.T) {
+		registry := NewRegistry()
+		count := 0
+		registry.Describe(DependencyMessaging, func() map[string]interface{} {
+			count++
+			return map[string]interface{}{"calls": count}
+		})
+		registry.Set(DependencyMessaging, nil)
+		registry.Set(DependencyCache, nil)
+
+		assert.Equal(t, 1, registry.Statuses()[DependencyMessaging].Details["calls"])
+		assert.Equal(t, 2, registry.Statuses()[DependencyMessaging].Details["calls"])
+		assert.Nil(t, registry.Statuses()[DependencyCache].Details)
+	})
+
+	t.Run("Watch checks until cancelled", func(t *testing.T) {
+		registry := NewRegistry()
+		ctx, cancel := context.WithCancel(context.Background())
+
+		var mu sync.Mutex
+		calls := 0
+		done := make(chan struct{})
+		go func() {
+			registry.Watch(ctx, time.Millisecond, map[string]CheckFunc{
+				DependencyCache: func(ctx context.Context) error {
+					mu.Lock()
+					defer mu.Unlock()
+					calls++
+					return nil
+				},
+			})
+			close(done)
+		}()
+
+		assert.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return calls >= 2
+		}, time.Second, time.Millisecond)
+
+		cancel()
+		<-done
+		assert.True(t, registry.Statuses()[DependencyCache].Healthy)
+	})
+
+	t.Run("Concurrent access", func(t *testing.T) {
+		registry := NewRegistry()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(2)
+			go func(i int) {
+				defer wg.Done()
+				if i%2 == 0 {
+					registry.Set(DependencyCache, errors.New("down"))
+				} else {
+					registry.Set(DependencyCache, nil)
+				}
+			}(i)
+			go func() {
+				defer wg.Done()
+				registry.Degraded()
+				registry.Statuses()
+			}()
+		}
+		wg.Wait()
+
+		assert.Len(t, registry.Statuses(), 1)
+	})
+}
@@ -0,0 +1,81 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Probe checks a single dependency for a self-check run. Run is expected to connect,
+// issue a lightweight request and release the connection again.
+type Probe struct {
+	Name string
+	// Critical probes fail the self-check; the others are reported only,
+	// matching dependencies the service can start without
+	Critical bool
+	Run      CheckFunc
+}
+
+// ProbeResult is the outcome of one probe
+type ProbeResult struct {
+	Name       string `json:"name"`
+	Critical   bool   `json:"critical"`
+	Healthy    bool   `json:"healthy"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// SelfCheckReport is the outcome of a self-check run
+type SelfCheckReport struct {
+	Passed bool          `json:"passed"`
+	Probes []ProbeResult `json:"probes"`
+}
+
+// SelfCheck runs every probe in order, each bounded by timeout, and reports all of them.
+// It passes when no critical probe fails.
+func SelfCheck(ctx context.Context, probes []Probe, timeout time.Duration) SelfCheckReport {
+	report := SelfCheckReport{
+		Passed: true,
+		Probes: make([]ProbeResult, 0, len(probes)),
+	}
+
+	for _, probe := range probes {
+		start := time.Now()
+		err := runProbe(ctx, probe, timeout)
+
+		result := ProbeResult{
+			Name:       probe.Name,
+			Critical:   probe.Critical,
+			Healthy:    err == nil,
+			DurationMs: time.Since(start).Milliseconds(),
+		}
+		if err != nil {
+			result.Error = err.Error()
+			if probe.Critical {
+				report.Passed = false
+			}
+		}
+
+		report.Probes = append(report.Probes, result)
+	}
+
+	return report
+}
+
+// runProbe runs a probe and gives up on it once the timeout passes, even if it ignores its context
+func runProbe(ctx context.Context, probe Probe, timeout time.Duration) error {
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- probe.Run(probeCtx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-probeCtx.Done():
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+}
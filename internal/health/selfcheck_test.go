@@ -0,0 +1,70 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelfCheck(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("connection refused") }
+	hanging := func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}
+
+	t.Run("Passes when every probe succeeds", func(t *testing.T) {
+		report := SelfCheck(context.Background(), []Probe{
+			{Name: DependencyDatabase, Critical: true, Run: ok},
+			{Name: DependencyCache, Run: ok},
+		}, time.Second)
+
+		assert.True(t, report.Passed)
+		assert.Len(t, report.Probes, 2)
+		assert.Equal(t, DependencyDatabase, report.Probes[0].Name)
+		assert.True(t, report.Probes[0].Healthy)
+		assert.Empty(t, report.Probes[0].Error)
+	})
+
+	t.Run("Fails on a critical probe and still runs the rest", func(t *testing.T) {
+		cacheRan := false
+		report := SelfCheck(context.Background(), []Probe{
+			{Name: DependencyDatabase, Critical: true, Run: failing},
+			{Name: DependencyCache, Run: func(ctx context.Context) error {
+				cacheRan = true
+				return nil
+			}},
+		}, time.Second)
+
+		assert.False(t, report.Passed)
+		assert.True(t, cacheRan)
+		assert.False(t, report.Probes[0].Healthy)
+		assert.Equal(t, "connection refused", report.Probes[0].Error)
+		assert.True(t, report.Probes[1].Healthy)
+	})
+
+	t.Run("Non-critical failures are reported without failing", func(t *testing.T) {
+		report := SelfCheck(context.Background(), []Probe{
+			{Name: DependencyDatabase, Critical: true, Run: ok},
+			{Name: DependencyCache, Run: failing},
+		}, time.Second)
+
+		assert.True(t, report.Passed)
+		assert.False(t, report.Probes[1].Healthy)
+	})
+
+	t.Run("A hung probe times out", func(t *testing.T) {
+		start := time.Now()
+		report := SelfCheck(context.Background(), []Probe{
+			{Name: DependencyDatabase, Critical: true, Run: hanging},
+		}, 20*time.Millisecond)
+
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+		assert.False(t, report.Passed)
+		assert.Contains(t, report.Probes[0].Error, "timed out")
+	})
+}
@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chats/go-user-api/internal/logger"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// TypePasswordReset is the type of jobs delivering a password reset token to a user
+const TypePasswordReset = "password_reset"
+
+// PasswordReset is the payload of a password reset job. It carries the reset token, never a password.
+type PasswordReset struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Notifier delivers a password reset token to the user, e.g. by email
+type Notifier interface {
+	SendPasswordReset(ctx context.Context, user *models.User, token string, expiresAt time.Time) error
+}
+
+// LogNotifier logs that a reset token would be delivered; it stands in until email delivery is
+// configured. The token itself is not logged.
+type LogNotifier struct{}
+
+// SendPasswordReset logs the delivery
+func (LogNotifier) SendPasswordReset(_ context.Context, user *models.User, _ string, expiresAt time.Time) error {
+	log.Info().
+		Str("user_id", user.ID.String()).
+		Str("email", logger.MaskEmail(user.Email)).
+		Time("expires_at", expiresAt).
+		Msg("Password reset token ready for delivery")
+	return nil
+}
+
+// UserLookup finds the user a job is for
+type UserLookup interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+}
+
+// PasswordResetHandler delivers password reset tokens through notifier, looking the user up when the
+// job runs so the token goes to their current address
+func PasswordResetHandler(users UserLookup, notifier Notifier) Handler {
+	return func(ctx context.Context, job Job) error {
+		var payload PasswordReset
+		if err := job.Decode(&payload); err != nil {
+			return err
+		}
+
+		user, err := users.GetByID(ctx, payload.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to get user for password reset: %w", err)
+		}
+
+		if err := notifier.SendPasswordReset(ctx, user, payload.Token, payload.ExpiresAt); err != nil {
+			return fmt.Errorf("failed to deliver password reset: %w", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,150 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// JobTimeout bounds each job run by the in-process queue
+const JobTimeout = 30 * time.Second
+
+// Errors returned when a job cannot be queued
+var (
+	ErrQueueClosed = errors.New("job queue is closed")
+	ErrQueueFull   = errors.New("job queue is full")
+	ErrUnknownJob  = errors.New("unknown job type")
+)
+
+// Job is a unit of background work. The payload is JSON, so a broker can carry the job unchanged.
+type Job struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Payload    json.RawMessage `json:"payload"`
+	EnqueuedAt time.Time       `json:"enqueued_at"`
+}
+
+// NewJob creates a job of the given type carrying payload
+func NewJob(jobType string, payload interface{}) (Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Job{}, fmt.Errorf("failed to encode %s job: %w", jobType, err)
+	}
+
+	return Job{
+		ID:         uuid.New().String(),
+		Type:       jobType,
+		Payload:    data,
+		EnqueuedAt: time.Now(),
+	}, nil
+}
+
+// Decode decodes the payload into v
+func (j Job) Decode(v interface{}) error {
+	if err := json.Unmarshal(j.Payload, v); err != nil {
+		return fmt.Errorf("failed to decode %s job: %w", j.Type, err)
+	}
+	return nil
+}
+
+// Queue accepts jobs for background processing
+type Queue interface {
+	Enqueue(ctx context.Context, job Job) error
+}
+
+// Handler processes a job of one type
+type Handler func(ctx context.Context, job Job) error
+
+// MemoryQueue runs jobs on a background worker from a bounded buffer; it stands in until a broker
+// consumer is configured. Jobs still queued when the process exits are lost.
+type MemoryQueue struct {
+	handlers map[string]Handler
+	queue    chan Job
+
+	// mu guards closed against enqueues racing the queue being closed
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+}
+
+// NewMemoryQueue creates a queue dispatching each job to the handler for its type and starts its worker
+func NewMemoryQueue(bufferSize int, handlers map[string]Handler) *MemoryQueue {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+
+	q := &MemoryQueue{
+		handlers: handlers,
+		queue:    make(chan Job, bufferSize),
+		done:     make(chan struct{}),
+	}
+	go q.run()
+
+	return q
+}
+
+// Enqueue queues a job without blocking
+func (q *MemoryQueue) Enqueue(_ context.Context, job Job) error {
+	if _, ok := q.handlers[job.Type]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownJob, job.Type)
+	}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	select {
+	case q.queue <- job:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting jobs and waits for the worker to run the queued ones or for ctx to end
+func (q *MemoryQueue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.queue)
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run processes queued jobs until the queue is closed and drained
+func (q *MemoryQueue) run() {
+	defer close(q.done)
+
+	for job := range q.queue {
+		q.process(job)
+	}
+}
+
+// process runs one job and logs its failure; failed jobs are not retried
+func (q *MemoryQueue) process(job Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), JobTimeout)
+	defer cancel()
+
+	if err := q.handlers[job.Type](ctx, job); err != nil {
+		log.Error().Err(err).
+			Str("job_id", job.ID).
+			Str("job_type", job.Type).
+			Msg("Job failed")
+	}
+}
@@ -0,0 +1,122 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryQueue(t *testing.T) {
+	t.Run("Jobs run on the handler for their type", func(t *testing.T) {
+		ran := make(chan Job, 1)
+		queue := NewMemoryQueue(1, map[string]Handler{
+			"greet": func(ctx context.Context, job Job) error {
+				ran <- job
+				return nil
+			},
+		})
+
+		job, err := NewJob("greet", map[string]string{"name": "john"})
+		require.NoError(t, err)
+		require.NoError(t, queue.Enqueue(context.Background(), job))
+		require.NoError(t, queue.Close(context.Background()))
+
+		got := <-ran
+		assert.Equal(t, job.ID, got.ID)
+		var payload map[string]string
+		require.NoError(t, got.Decode(&payload))
+		assert.Equal(t, "john", payload["name"])
+	})
+
+	t.Run("Unknown job type", func(t *testing.T) {
+		queue := NewMemoryQueue(1, nil)
+		defer queue.Close(context.Background())
+
+		err := queue.Enqueue(context.Background(), Job{Type: "greet"})
+
+		assert.ErrorIs(t, err, ErrUnknownJob)
+	})
+
+	t.Run("Full buffer", func(t *testing.T) {
+		release := make(chan struct{})
+		started := make(chan struct{}, 1)
+		queue := NewMemoryQueue(1, map[string]Handler{
+			"slow": func(ctx context.Context, job Job) error {
+				started <- struct{}{}
+				<-release
+				return nil
+			},
+		})
+		defer queue.Close(context.Background())
+		defer close(release)
+
+		// One job runs and one waits in the buffer, so a third does not fit
+		require.NoError(t, queue.Enqueue(context.Background(), Job{Type: "slow"}))
+		<-started
+		require.NoError(t, queue.Enqueue(context.Background(), Job{Type: "slow"}))
+
+		assert.ErrorIs(t, queue.Enqueue(context.Background(), Job{Type: "slow"}), ErrQueueFull)
+	})
+
+	t.Run("Closed queue", func(t *testing.T) {
+		queue := NewMemoryQueue(1, map[string]Handler{"greet": func(context.Context, Job) error { return nil }})
+		require.NoError(t, queue.Close(context.Background()))
+
+		assert.ErrorIs(t, queue.Enqueue(context.Background(), Job{Type: "greet"}), ErrQueueClosed)
+	})
+}
+
+// fakeUsers finds users by ID
+type fakeUsers map[uuid.UUID]*models.User
+
+func (f fakeUsers) GetByID(_ context.Context, id uuid.UUID) (*models.User, error) {
+	if user, ok := f[id]; ok {
+		return user, nil
+	}
+	return nil, errors.New("user not found")
+}
+
+// recordingNotifier records the reset tokens it delivers
+type recordingNotifier struct {
+	emails []string
+	tokens []string
+}
+
+func (n *recordingNotifier) SendPasswordReset(_ context.Context, user *models.User, token string, _ time.Time) error {
+	n.emails = append(n.emails, user.Email)
+	n.tokens = append(n.tokens, token)
+	return nil
+}
+
+func TestPasswordResetHandler(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Email: "john@example.com"}
+	users := fakeUsers{user.ID: user}
+
+	t.Run("Delivers the token to the user", func(t *testing.T) {
+		notifier := &recordingNotifier{}
+		job, err := NewJob(TypePasswordReset, PasswordReset{UserID: user.ID, Token: "reset-token", ExpiresAt: time.Now().Add(time.Hour)})
+		require.NoError(t, err)
+
+		require.NoError(t, PasswordResetHandler(users, notifier)(context.Background(), job))
+
+		assert.Equal(t, []string{"john@example.com"}, notifier.emails)
+		assert.Equal(t, []string{"reset-token"}, notifier.tokens)
+	})
+
+	t.Run("Unknown user", func(t *testing.T) {
+		notifier := &recordingNotifier{}
+		job, err := NewJob(TypePasswordReset, PasswordReset{UserID: uuid.New(), Token: "reset-token"})
+		require.NoError(t, err)
+
+		err = PasswordResetHandler(users, notifier)(context.Background(), job)
+
+		assert.ErrorContains(t, err, "failed to get user for password reset")
+		assert.Empty(t, notifier.tokens)
+	})
+}
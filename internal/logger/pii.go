@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// maskMarker replaces the hidden part of a masked value
+const maskMarker = "***"
+
+var maskPII atomic.Bool
+
+// SetMaskPII turns masking of emails and usernames in logs and activity events on or off
+func SetMaskPII(enabled bool) {
+	maskPII.Store(enabled)
+}
+
+// MaskEmail returns the email unchanged, or with only the first character of the local part
+// kept (a***@example.com) when masking is on
+func MaskEmail(email string) string {
+	if !maskPII.Load() || email == "" {
+		return email
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return maskPrefix(email, 1)
+	}
+
+	return maskPrefix(email[:at], 1) + email[at:]
+}
+
+// MaskUsername returns the username unchanged, or with only its first characters kept
+// (jo***) when masking is on
+func MaskUsername(username string) string {
+	if !maskPII.Load() || username == "" {
+		return username
+	}
+
+	// Short names keep less so they cannot be guessed from the prefix
+	keep := 2
+	if len([]rune(username)) <= 4 {
+		keep = 1
+	}
+	return maskPrefix(username, keep)
+}
+
+// maskPrefix keeps the first keep characters of value and replaces the rest with the marker
+func maskPrefix(value string, keep int) string {
+	runes := []rune(value)
+	if len(runes) <= keep {
+		return maskMarker
+	}
+	return string(runes[:keep]) + maskMarker
+}
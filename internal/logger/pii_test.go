@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskPII(t *testing.T) {
+	tests := []struct {
+		name     string
+		mask     func(string) string
+		value    string
+		masked   string
+		unmasked string
+	}{
+		{name: "Email", mask: MaskEmail, value: "alice@example.com", masked: "a***@example.com"},
+		{name: "Email without local part", mask: MaskEmail, value: "@example.com", masked: "***@example.com"},
+		{name: "Not an email", mask: MaskEmail, value: "alice", masked: "a***"},
+		{name: "Username", mask: MaskUsername, value: "johndoe", masked: "jo***"},
+		{name: "Short username", mask: MaskUsername, value: "bob", masked: "b***"},
+		{name: "Single character username", mask: MaskUsername, value: "x", masked: "***"},
+		{name: "Multibyte username", mask: MaskUsername, value: "สมชายใจดี", masked: "สม***"},
+		{name: "Empty", mask: MaskUsername, value: "", masked: ""},
+	}
+
+	t.Cleanup(func() { SetMaskPII(false) })
+
+	for _, tt := range tests {
+		t.Run(tt.name+" masked", func(t *testing.T) {
+			SetMaskPII(true)
+			assert.Equal(t, tt.masked, tt.mask(tt.value))
+		})
+		t.Run(tt.name+" unmasked", func(t *testing.T) {
+			SetMaskPII(false)
+			assert.Equal(t, tt.value, tt.mask(tt.value))
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/chats/go-user-api/config"
+)
+
+// Redactor replaces sensitive header values, JSON body fields and query parameters in access logs.
+// Names are matched case-insensitively.
+type Redactor struct {
+	headers map[string]struct{}
+	fields  map[string]struct{}
+}
+
+// NewRedactor creates a redactor for the given header names and body field or query parameter names
+func NewRedactor(headers, fields []string) *Redactor {
+	return &Redactor{
+		headers: nameSet(headers),
+		fields:  nameSet(fields),
+	}
+}
+
+func nameSet(names []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		set[strings.ToLower(name)] = struct{}{}
+	}
+	return set
+}
+
+// Header returns value, or the redacted marker when the header is sensitive
+func (r *Redactor) Header(name, value string) string {
+	if _, ok := r.headers[strings.ToLower(name)]; ok {
+		return config.RedactedValue
+	}
+	return value
+}
+
+// Query returns the raw query string with the values of sensitive parameters replaced
+func (r *Redactor) Query(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	params := strings.Split(rawQuery, "&")
+	for i, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		if _, ok := r.fields[strings.ToLower(name)]; ok {
+			params[i] = key + "=" + config.RedactedValue
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// JSON returns the body with the values of sensitive fields replaced at any depth. A body that is
+// not JSON cannot be inspected, so ok is false and the body must not be logged.
+func (r *Redactor) JSON(body []byte) (redacted []byte, ok bool) {
+	var document interface{}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return nil, false
+	}
+
+	redacted, err := json.Marshal(r.redactValue(document))
+	if err != nil {
+		return nil, false
+	}
+	return redacted, true
+}
+
+func (r *Redactor) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if _, ok := r.fields[strings.ToLower(key)]; ok {
+				v[key] = config.RedactedValue
+			} else {
+				v[key] = r.redactValue(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = r.redactValue(item)
+		}
+	}
+	return value
+}
@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxLatencySamples bounds the latencies kept for the p99; under heavy traffic the
+// window is effectively the most recent samples rather than the full duration
+const maxLatencySamples = 10000
+
+// PoolStats is the database connection pool usage at a point in time
+type PoolStats struct {
+	InUse   int
+	MaxOpen int
+}
+
+// Snapshot is the traffic observed over the collector's window
+type Snapshot struct {
+	Window      time.Duration
+	Requests    int
+	Errors      int
+	Latencies   int
+	LatencyP99  time.Duration
+	CacheHits   int
+	CacheMisses int
+	// Pool is nil when no database pool is reporting
+	Pool *PoolStats
+}
+
+// bucket counts the events of a single second
+type bucket struct {
+	second      int64
+	requests    int
+	errors      int
+	cacheHits   int
+	cacheMisses int
+}
+
+type latencySample struct {
+	at       time.Time
+	duration time.Duration
+}
+
+// Collector counts requests, server errors, latencies and cache lookups over a sliding window
+// of whole seconds. It is safe for concurrent use, so middleware can record while handlers read.
+type Collector struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu        sync.Mutex
+	buckets   []bucket
+	latencies []latencySample
+	next      int
+	pool      func() PoolStats
+}
+
+// NewCollector creates a collector over the given window, rounded up to whole seconds
+func NewCollector(window time.Duration) *Collector {
+	seconds := int((window + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	return &Collector{
+		window:  time.Duration(seconds) * time.Second,
+		now:     time.Now,
+		buckets: make([]bucket, seconds),
+	}
+}
+
+// UsePool sets the source of database pool usage; without one the saturation is not reported
+func (c *Collector) UsePool(stats func() PoolStats) {
+	c.mu.Lock()
+	c.pool = stats
+	c.mu.Unlock()
+}
+
+// ObserveRequest records a completed request; 5xx statuses count as errors
+func (c *Collector) ObserveRequest(status int, duration time.Duration) {
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b := c.bucket(now)
+	b.requests++
+	if status >= 500 {
+		b.errors++
+	}
+
+	sample := latencySample{at: now, duration: duration}
+	if len(c.latencies) < maxLatencySamples {
+		c.latencies = append(c.latencies, sample)
+		return
+	}
+	c.latencies[c.next] = sample
+	c.next = (c.next + 1) % maxLatencySamples
+}
+
+// ObserveCacheLookup records a cache read as a hit or a miss
+func (c *Collector) ObserveCacheLookup(hit bool) {
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b := c.bucket(now)
+	if hit {
+		b.cacheHits++
+	} else {
+		b.cacheMisses++
+	}
+}
+
+// bucket returns the bucket for the second of now, resetting it if it last held an older second.
+// The caller must hold c.mu.
+func (c *Collector) bucket(now time.Time) *bucket {
+	second := now.Unix()
+	b := &c.buckets[int(second%int64(len(c.buckets)))]
+	if b.second != second {
+		*b = bucket{second: second}
+	}
+	return b
+}
+
+// Snapshot sums the window and computes the p99 latency of the requests in it
+func (c *Collector) Snapshot() Snapshot {
+	now := c.now()
+	oldest := now.Unix() - int64(len(c.buckets)) + 1
+	since := now.Add(-c.window)
+
+	c.mu.Lock()
+	snapshot := Snapshot{Window: c.window}
+	for _, b := range c.buckets {
+		if b.second < oldest || b.second > now.Unix() {
+			continue
+		}
+		snapshot.Requests += b.requests
+		snapshot.Errors += b.errors
+		snapshot.CacheHits += b.cacheHits
+		snapshot.CacheMisses += b.cacheMisses
+	}
+
+	durations := make([]time.Duration, 0, len(c.latencies))
+	for _, sample := range c.latencies {
+		if sample.at.After(since) {
+			durations = append(durations, sample.duration)
+		}
+	}
+	pool := c.pool
+	c.mu.Unlock()
+
+	snapshot.Latencies = len(durations)
+	snapshot.LatencyP99 = percentile(durations, 0.99)
+
+	if pool != nil {
+		stats := pool()
+		snapshot.Pool = &stats
+	}
+
+	return snapshot
+}
+
+// percentile returns the nearest-rank percentile of durations, sorting them in place
+func percentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	rank := int(math.Ceil(float64(len(durations))*p)) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return durations[rank]
+}
@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"time"
+)
+
+// Level classifies an indicator against its thresholds
+type Level string
+
+// Indicator levels, from best to worst
+const (
+	LevelOK       Level = "OK"
+	LevelWarn     Level = "WARN"
+	LevelCritical Level = "CRITICAL"
+)
+
+// Indicator names reported by Evaluate
+const (
+	IndicatorErrorRate        = "error_rate"
+	IndicatorLatencyP99       = "latency_p99_ms"
+	IndicatorCacheHitRatio    = "cache_hit_ratio"
+	IndicatorDBPoolSaturation = "db_pool_saturation"
+)
+
+// Thresholds are the WARN and CRITICAL boundaries of each indicator. The error rate, latency and
+// pool saturation are worse when higher; the cache hit ratio is worse when lower.
+type Thresholds struct {
+	ErrorRateWarn          float64
+	ErrorRateCritical      float64
+	LatencyP99Warn         time.Duration
+	LatencyP99Critical     time.Duration
+	CacheHitRatioWarn      float64
+	CacheHitRatioCritical  float64
+	PoolSaturationWarn     float64
+	PoolSaturationCritical float64
+}
+
+// Indicator is a derived health figure and its level; Value is nil when nothing was observed
+type Indicator struct {
+	Name     string   `json:"name"`
+	Status   Level    `json:"status"`
+	Value    *float64 `json:"value"`
+	Warn     float64  `json:"warn"`
+	Critical float64  `json:"critical"`
+	Samples  int      `json:"samples"`
+}
+
+// Report is the level of every indicator; Status is the worst of them
+type Report struct {
+	Status        Level       `json:"status"`
+	WindowSeconds int         `json:"window_seconds"`
+	Indicators    []Indicator `json:"indicators"`
+}
+
+// Evaluate classifies a snapshot against the thresholds. An indicator with no samples is OK,
+// so an idle instance or a disabled cache does not raise alerts.
+func Evaluate(snapshot Snapshot, thresholds Thresholds) Report {
+	report := Report{
+		Status:        LevelOK,
+		WindowSeconds: int(snapshot.Window / time.Second),
+	}
+
+	add := func(indicator Indicator, lowerIsWorse bool) {
+		indicator.Status = classify(indicator.Value, indicator.Warn, indicator.Critical, lowerIsWorse)
+		if severity(indicator.Status) > severity(report.Status) {
+			report.Status = indicator.Status
+		}
+		report.Indicators = append(report.Indicators, indicator)
+	}
+
+	add(Indicator{
+		Name:     IndicatorErrorRate,
+		Value:    ratio(snapshot.Errors, snapshot.Requests),
+		Warn:     thresholds.ErrorRateWarn,
+		Critical: thresholds.ErrorRateCritical,
+		Samples:  snapshot.Requests,
+	}, false)
+
+	latency := Indicator{
+		Name:     IndicatorLatencyP99,
+		Warn:     milliseconds(thresholds.LatencyP99Warn),
+		Critical: milliseconds(thresholds.LatencyP99Critical),
+		Samples:  snapshot.Latencies,
+	}
+	if snapshot.Latencies > 0 {
+		value := milliseconds(snapshot.LatencyP99)
+		latency.Value = &value
+	}
+	add(latency, false)
+
+	lookups := snapshot.CacheHits + snapshot.CacheMisses
+	add(Indicator{
+		Name:     IndicatorCacheHitRatio,
+		Value:    ratio(snapshot.CacheHits, lookups),
+		Warn:     thresholds.CacheHitRatioWarn,
+		Critical: thresholds.CacheHitRatioCritical,
+		Samples:  lookups,
+	}, true)
+
+	pool := Indicator{
+		Name:     IndicatorDBPoolSaturation,
+		Warn:     thresholds.PoolSaturationWarn,
+		Critical: thresholds.PoolSaturationCritical,
+	}
+	if snapshot.Pool != nil {
+		pool.Value = ratio(snapshot.Pool.InUse, snapshot.Pool.MaxOpen)
+		pool.Samples = snapshot.Pool.MaxOpen
+	}
+	add(pool, false)
+
+	return report
+}
+
+// classify returns the level of value; reaching a threshold counts as crossing it
+func classify(value *float64, warn, critical float64, lowerIsWorse bool) Level {
+	if value == nil {
+		return LevelOK
+	}
+
+	v := *value
+	if lowerIsWorse {
+		// Negate so that the comparisons below read the same both ways
+		v, warn, critical = -v, -warn, -critical
+	}
+
+	switch {
+	case v >= critical:
+		return LevelCritical
+	case v >= warn:
+		return LevelWarn
+	default:
+		return LevelOK
+	}
+}
+
+func severity(level Level) int {
+	switch level {
+	case LevelCritical:
+		return 2
+	case LevelWarn:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// ratio returns part/whole, or nil when whole is zero
+func ratio(part, whole int) *float64 {
+	if whole <= 0 {
+		return nil
+	}
+	value := float64(part) / float64(whole)
+	return &value
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
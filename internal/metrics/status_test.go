@@ -0,0 +1,231 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testThresholds() Thresholds {
+	return Thresholds{
+		ErrorRateWarn:          0.01,
+		ErrorRateCritical:      0.05,
+		LatencyP99Warn:         500 * time.Millisecond,
+		LatencyP99Critical:     2 * time.Second,
+		CacheHitRatioWarn:      0.8,
+		CacheHitRatioCritical:  0.5,
+		PoolSaturationWarn:     0.75,
+		PoolSaturationCritical: 0.95,
+	}
+}
+
+func indicator(t *testing.T, report Report, name string) Indicator {
+	t.Helper()
+
+	for _, indicator := range report.Indicators {
+		if indicator.Name == name {
+			return indicator
+		}
+	}
+	require.Failf(t, "indicator missing", "%s not in report", name)
+	return Indicator{}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name     string
+		snapshot Snapshot
+		want     map[string]Level
+		overall  Level
+	}{
+		{
+			name:     "No traffic is OK",
+			snapshot: Snapshot{},
+			want: map[string]Level{
+				IndicatorErrorRate:        LevelOK,
+				IndicatorLatencyP99:       LevelOK,
+				IndicatorCacheHitRatio:    LevelOK,
+				IndicatorDBPoolSaturation: LevelOK,
+			},
+			overall: LevelOK,
+		},
+		{
+			name: "Healthy traffic",
+			snapshot: Snapshot{
+				Requests: 1000, Errors: 5,
+				Latencies: 1000, LatencyP99: 120 * time.Millisecond,
+				CacheHits: 95, CacheMisses: 5,
+				Pool: &PoolStats{InUse: 10, MaxOpen: 50},
+			},
+			want: map[string]Level{
+				IndicatorErrorRate:        LevelOK,
+				IndicatorLatencyP99:       LevelOK,
+				IndicatorCacheHitRatio:    LevelOK,
+				IndicatorDBPoolSaturation: LevelOK,
+			},
+			overall: LevelOK,
+		},
+		{
+			name: "Warnings",
+			snapshot: Snapshot{
+				Requests: 100, Errors: 2,
+				Latencies: 100, LatencyP99: 800 * time.Millisecond,
+				CacheHits: 70, CacheMisses: 30,
+				Pool: &PoolStats{InUse: 40, MaxOpen: 50},
+			},
+			want: map[string]Level{
+				IndicatorErrorRate:        LevelWarn,
+				IndicatorLatencyP99:       LevelWarn,
+				IndicatorCacheHitRatio:    LevelWarn,
+				IndicatorDBPoolSaturation: LevelWarn,
+			},
+			overall: LevelWarn,
+		},
+		{
+			name: "Critical",
+			snapshot: Snapshot{
+				Requests: 100, Errors: 10,
+				Latencies: 100, LatencyP99: 3 * time.Second,
+				CacheHits: 20, CacheMisses: 80,
+				Pool: &PoolStats{InUse: 50, MaxOpen: 50},
+			},
+			want: map[string]Level{
+				IndicatorErrorRate:        LevelCritical,
+				IndicatorLatencyP99:       LevelCritical,
+				IndicatorCacheHitRatio:    LevelCritical,
+				IndicatorDBPoolSaturation: LevelCritical,
+			},
+			overall: LevelCritical,
+		},
+		{
+			name: "Overall is the worst indicator",
+			snapshot: Snapshot{
+				Requests: 100, Errors: 0,
+				Latencies: 100, LatencyP99: 100 * time.Millisecond,
+				CacheHits: 40, CacheMisses: 60,
+			},
+			want: map[string]Level{
+				IndicatorErrorRate:        LevelOK,
+				IndicatorLatencyP99:       LevelOK,
+				IndicatorCacheHitRatio:    LevelCritical,
+				IndicatorDBPoolSaturation: LevelOK,
+			},
+			overall: LevelCritical,
+		},
+		{
+			name: "Reaching a threshold crosses it",
+			snapshot: Snapshot{
+				Requests: 100, Errors: 1,
+				Latencies: 100, LatencyP99: 2 * time.Second,
+				CacheHits: 80, CacheMisses: 20,
+			},
+			want: map[string]Level{
+				IndicatorErrorRate:        LevelWarn,
+				IndicatorLatencyP99:       LevelCritical,
+				IndicatorCacheHitRatio:    LevelWarn,
+				IndicatorDBPoolSaturation: LevelOK,
+			},
+			overall: LevelCritical,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Evaluate(tt.snapshot, testThresholds())
+
+			assert.Equal(t, tt.overall, report.Status)
+			assert.Len(t, report.Indicators, len(tt.want))
+			for name, level := range tt.want {
+				assert.Equal(t, level, indicator(t, report, name).Status, name)
+			}
+		})
+	}
+
+	t.Run("Values without samples are null", func(t *testing.T) {
+		report := Evaluate(Snapshot{}, testThresholds())
+
+		for _, indicator := range report.Indicators {
+			assert.Nil(t, indicator.Value, indicator.Name)
+		}
+	})
+
+	t.Run("Latency is reported in milliseconds", func(t *testing.T) {
+		report := Evaluate(Snapshot{Latencies: 1, LatencyP99: 1500 * time.Millisecond}, testThresholds())
+
+		latency := indicator(t, report, IndicatorLatencyP99)
+		require.NotNil(t, latency.Value)
+		assert.Equal(t, 1500.0, *latency.Value)
+		assert.Equal(t, 500.0, latency.Warn)
+		assert.Equal(t, 2000.0, latency.Critical)
+	})
+}
+
+func TestCollector(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	newCollector := func(window time.Duration) (*Collector, *time.Time) {
+		collector := NewCollector(window)
+		now := start
+		collector.now = func() time.Time { return now }
+		return collector, &now
+	}
+
+	t.Run("Counts requests, errors and cache lookups", func(t *testing.T) {
+		collector, _ := newCollector(time.Minute)
+
+		collector.ObserveRequest(200, 10*time.Millisecond)
+		collector.ObserveRequest(404, 10*time.Millisecond)
+		collector.ObserveRequest(503, 10*time.Millisecond)
+		collector.ObserveCacheLookup(true)
+		collector.ObserveCacheLookup(false)
+
+		snapshot := collector.Snapshot()
+		assert.Equal(t, time.Minute, snapshot.Window)
+		assert.Equal(t, 3, snapshot.Requests)
+		assert.Equal(t, 1, snapshot.Errors)
+		assert.Equal(t, 1, snapshot.CacheHits)
+		assert.Equal(t, 1, snapshot.CacheMisses)
+		assert.Nil(t, snapshot.Pool)
+	})
+
+	t.Run("Forgets traffic older than the window", func(t *testing.T) {
+		collector, now := newCollector(10 * time.Second)
+
+		collector.ObserveRequest(500, time.Second)
+		*now = now.Add(5 * time.Second)
+		collector.ObserveRequest(200, time.Millisecond)
+		assert.Equal(t, 2, collector.Snapshot().Requests)
+
+		*now = now.Add(7 * time.Second)
+		snapshot := collector.Snapshot()
+		assert.Equal(t, 1, snapshot.Requests)
+		assert.Equal(t, 0, snapshot.Errors)
+		assert.Equal(t, 1, snapshot.Latencies)
+		assert.Equal(t, time.Millisecond, snapshot.LatencyP99)
+
+		// The slot of the first request is reused once the ring wraps
+		collector.ObserveRequest(200, time.Millisecond)
+		assert.Equal(t, 2, collector.Snapshot().Requests)
+	})
+
+	t.Run("p99 latency", func(t *testing.T) {
+		collector, _ := newCollector(time.Minute)
+
+		for i := 1; i <= 100; i++ {
+			collector.ObserveRequest(200, time.Duration(i)*time.Millisecond)
+		}
+
+		assert.Equal(t, 99*time.Millisecond, collector.Snapshot().LatencyP99)
+	})
+
+	t.Run("Reports pool usage", func(t *testing.T) {
+		collector, _ := newCollector(time.Minute)
+		collector.UsePool(func() PoolStats { return PoolStats{InUse: 3, MaxOpen: 4} })
+
+		snapshot := collector.Snapshot()
+		require.NotNil(t, snapshot.Pool)
+		assert.Equal(t, PoolStats{InUse: 3, MaxOpen: 4}, *snapshot.Pool)
+	})
+}
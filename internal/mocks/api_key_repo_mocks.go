@@ -0,0 +1,50 @@
+package mocks
+
+import (
+	context "context"
+
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/google/uuid"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockAPIKeyRepository mocks the APIKeyRepositoryInterface
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.APIKey, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	args := m.Called(ctx, keyHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) GetAll(ctx context.Context) ([]*models.APIKey, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*models.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) GetByCreator(ctx context.Context, userID uuid.UUID) ([]*models.APIKey, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*models.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) Revoke(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
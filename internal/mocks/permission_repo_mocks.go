@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"context"
+	"time"
 
 	"github.com/chats/go-user-api/internal/models"
 	"github.com/chats/go-user-api/internal/repositories/transaction"
@@ -52,13 +53,18 @@ func (m *MockPermissionRepository) GetByResourceAction(ctx context.Context, reso
 	return args.Get(0).(*models.Permission), args.Error(1)
 }
 
+func (m *MockPermissionRepository) ExistsByResourceAction(ctx context.Context, resource, action string) (bool, error) {
+	args := m.Called(ctx, resource, action)
+	return args.Bool(0), args.Error(1)
+}
+
 func (m *MockPermissionRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Permission, error) {
 	args := m.Called(ctx, id)
 	return args.Get(0).(*models.Permission), args.Error(1)
 }
 
-func (m *MockPermissionRepository) GetAll(ctx context.Context) ([]*models.Permission, error) {
-	args := m.Called(ctx)
+func (m *MockPermissionRepository) GetAll(ctx context.Context, sort models.SortOptions) ([]*models.Permission, error) {
+	args := m.Called(ctx, sort)
 	return args.Get(0).([]*models.Permission), args.Error(1)
 }
 
@@ -67,6 +73,16 @@ func (m *MockPermissionRepository) GetByResource(ctx context.Context, resource s
 	return args.Get(0).([]*models.Permission), args.Error(1)
 }
 
+func (m *MockPermissionRepository) GetByAction(ctx context.Context, action string) ([]*models.Permission, error) {
+	args := m.Called(ctx, action)
+	return args.Get(0).([]*models.Permission), args.Error(1)
+}
+
+func (m *MockPermissionRepository) GetUsageCounts(ctx context.Context, permissionIDs []uuid.UUID) (map[uuid.UUID]int, error) {
+	args := m.Called(ctx, permissionIDs)
+	return args.Get(0).(map[uuid.UUID]int), args.Error(1)
+}
+
 func (m *MockPermissionRepository) Update(ctx context.Context, permission *models.Permission) error {
 	args := m.Called(ctx, permission)
 	return args.Error(0)
@@ -82,11 +98,80 @@ func (m *MockPermissionRepository) AssignPermissionsToRole(ctx context.Context,
 	return args.Error(0)
 }
 
+func (m *MockPermissionRepository) AddPermissionsToRole(ctx context.Context, roleID uuid.UUID, permissionIDs []uuid.UUID) ([]uuid.UUID, error) {
+	args := m.Called(ctx, roleID, permissionIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
+func (m *MockPermissionRepository) RemovePermissionsFromRole(ctx context.Context, roleID uuid.UUID, permissionIDs []uuid.UUID) ([]uuid.UUID, error) {
+	args := m.Called(ctx, roleID, permissionIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
 func (m *MockPermissionRepository) AssignRolesToUser(ctx context.Context, userID uuid.UUID, roleIDs []uuid.UUID) error {
 	args := m.Called(ctx, userID, roleIDs)
 	return args.Error(0)
 }
 
+func (m *MockPermissionRepository) RevokeUserTokens(ctx context.Context, userID uuid.UUID) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockPermissionRepository) DeactivateUsers(ctx context.Context, userIDs []uuid.UUID, deactivatedAt time.Time) ([]uuid.UUID, error) {
+	args := m.Called(ctx, userIDs, deactivatedAt)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
+func (m *MockPermissionRepository) SoftDeleteUser(ctx context.Context, userID uuid.UUID, deletedAt time.Time) error {
+	args := m.Called(ctx, userID, deletedAt)
+	return args.Error(0)
+}
+
+func (m *MockPermissionRepository) RestoreUser(ctx context.Context, userID uuid.UUID, restoredAt time.Time) error {
+	args := m.Called(ctx, userID, restoredAt)
+	return args.Error(0)
+}
+
+func (m *MockPermissionRepository) RevokeUserAPIKeys(ctx context.Context, userID uuid.UUID, revokedAt time.Time) (int, error) {
+	args := m.Called(ctx, userID, revokedAt)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPermissionRepository) ReassignAPIKeys(ctx context.Context, fromUserID, toUserID uuid.UUID) (int, error) {
+	args := m.Called(ctx, fromUserID, toUserID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPermissionRepository) DeleteUser(ctx context.Context, userID uuid.UUID) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockPermissionRepository) CountActiveUsersWithRole(ctx context.Context, roleName string) (int, error) {
+	args := m.Called(ctx, roleName)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPermissionRepository) DeleteRole(ctx context.Context, roleID uuid.UUID) error {
+	args := m.Called(ctx, roleID)
+	return args.Error(0)
+}
+
+func (m *MockPermissionRepository) DeletePermission(ctx context.Context, permissionID uuid.UUID) error {
+	args := m.Called(ctx, permissionID)
+	return args.Error(0)
+}
+
 func (m *MockPermissionRepository) CreatePermission(ctx context.Context, permission *models.Permission) error {
 	args := m.Called(ctx, permission)
 	return args.Error(0)
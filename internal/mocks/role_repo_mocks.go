@@ -35,8 +35,8 @@ func (m *MockRoleRepository) GetByName(ctx context.Context, name string) (*model
 	return args.Get(0).(*models.Role), args.Error(1)
 }
 
-func (m *MockRoleRepository) GetAll(ctx context.Context) ([]*models.Role, error) {
-	args := m.Called(ctx)
+func (m *MockRoleRepository) GetAll(ctx context.Context, includePermissions bool, sort models.SortOptions) ([]*models.Role, error) {
+	args := m.Called(ctx, includePermissions, sort)
 	return args.Get(0).([]*models.Role), args.Error(1)
 }
 
@@ -55,11 +55,28 @@ func (m *MockRoleRepository) GetRolePermissions(ctx context.Context, roleID uuid
 	return args.Get(0).([]models.Permission), args.Error(1)
 }
 
+func (m *MockRoleRepository) GetPermissionsByRoleIDs(ctx context.Context, roleIDs []uuid.UUID) (map[uuid.UUID][]models.Permission, error) {
+	args := m.Called(ctx, roleIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[uuid.UUID][]models.Permission), args.Error(1)
+}
+
+func (m *MockRoleRepository) GetCounts(ctx context.Context, roleID uuid.UUID) (int, int, error) {
+	args := m.Called(ctx, roleID)
+	return args.Int(0), args.Int(1), args.Error(2)
+}
+
 func (m *MockRoleRepository) AssignPermissionsToRole(ctx context.Context, roleID uuid.UUID, permissionIDs []uuid.UUID) error {
 	args := m.Called(ctx, roleID, permissionIDs)
 	return args.Error(0)
 }
 
+func (m *MockRoleRepository) InvalidatePermissionCache() {
+	m.Called()
+}
+
 func (m *MockRoleRepository) ExecuteTx(ctx context.Context, fn func(transaction.Repository) error) error {
 	args := m.Called(ctx, fn)
 
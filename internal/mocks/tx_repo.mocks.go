@@ -3,6 +3,7 @@ package mocks
 
 import (
 	context "context"
+	"time"
 
 	"github.com/chats/go-user-api/internal/models"
 	"github.com/chats/go-user-api/internal/repositories/transaction"
@@ -34,6 +35,49 @@ func (m *MockTxRepository) AssignRolesToUser(ctx context.Context, userID uuid.UU
 	return args.Error(0)
 }
 
+func (m *MockTxRepository) RevokeUserTokens(ctx context.Context, userID uuid.UUID) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockTxRepository) DeactivateUsers(ctx context.Context, userIDs []uuid.UUID, deactivatedAt time.Time) ([]uuid.UUID, error) {
+	args := m.Called(ctx, userIDs, deactivatedAt)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
+func (m *MockTxRepository) SoftDeleteUser(ctx context.Context, userID uuid.UUID, deletedAt time.Time) error {
+	args := m.Called(ctx, userID, deletedAt)
+	return args.Error(0)
+}
+
+func (m *MockTxRepository) RestoreUser(ctx context.Context, userID uuid.UUID, restoredAt time.Time) error {
+	args := m.Called(ctx, userID, restoredAt)
+	return args.Error(0)
+}
+
+func (m *MockTxRepository) RevokeUserAPIKeys(ctx context.Context, userID uuid.UUID, revokedAt time.Time) (int, error) {
+	args := m.Called(ctx, userID, revokedAt)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockTxRepository) ReassignAPIKeys(ctx context.Context, fromUserID, toUserID uuid.UUID) (int, error) {
+	args := m.Called(ctx, fromUserID, toUserID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockTxRepository) DeleteUser(ctx context.Context, userID uuid.UUID) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockTxRepository) CountActiveUsersWithRole(ctx context.Context, roleName string) (int, error) {
+	args := m.Called(ctx, roleName)
+	return args.Int(0), args.Error(1)
+}
+
 func (m *MockTxRepository) CreateRole(ctx context.Context, role *models.Role) error {
 	args := m.Called(ctx, role)
 	return args.Error(0)
@@ -49,6 +93,32 @@ func (m *MockTxRepository) AssignPermissionsToRole(ctx context.Context, roleID u
 	return args.Error(0)
 }
 
+func (m *MockTxRepository) AddPermissionsToRole(ctx context.Context, roleID uuid.UUID, permissionIDs []uuid.UUID) ([]uuid.UUID, error) {
+	args := m.Called(ctx, roleID, permissionIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
+func (m *MockTxRepository) RemovePermissionsFromRole(ctx context.Context, roleID uuid.UUID, permissionIDs []uuid.UUID) ([]uuid.UUID, error) {
+	args := m.Called(ctx, roleID, permissionIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
+func (m *MockTxRepository) DeleteRole(ctx context.Context, roleID uuid.UUID) error {
+	args := m.Called(ctx, roleID)
+	return args.Error(0)
+}
+
+func (m *MockTxRepository) DeletePermission(ctx context.Context, permissionID uuid.UUID) error {
+	args := m.Called(ctx, permissionID)
+	return args.Error(0)
+}
+
 func (m *MockTxRepository) CreatePermission(ctx context.Context, permission *models.Permission) error {
 	args := m.Called(ctx, permission)
 	return args.Error(0)
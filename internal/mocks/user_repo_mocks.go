@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"context"
+	"time"
 
 	"github.com/chats/go-user-api/internal/models"
 	"github.com/chats/go-user-api/internal/repositories/transaction"
@@ -22,6 +23,14 @@ func (m *MockUserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
+func (m *MockUserRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*models.User, error) {
+	args := m.Called(ctx, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.User), args.Error(1)
+}
+
 func (m *MockUserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	args := m.Called(ctx, username)
 	if args.Get(0) == nil {
@@ -30,8 +39,8 @@ func (m *MockUserRepository) GetByUsername(ctx context.Context, username string)
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockUserRepository) GetAll(ctx context.Context, limit, offset int) ([]*models.User, error) {
-	args := m.Called(ctx, limit, offset)
+func (m *MockUserRepository) GetAll(ctx context.Context, limit, offset int, sort models.SortOptions) ([]*models.User, error) {
+	args := m.Called(ctx, limit, offset, sort)
 	return args.Get(0).([]*models.User), args.Error(1)
 }
 
@@ -50,6 +59,11 @@ func (m *MockUserRepository) UpdatePassword(ctx context.Context, userID uuid.UUI
 	return args.Error(0)
 }
 
+func (m *MockUserRepository) RehashPassword(ctx context.Context, userID uuid.UUID, verifiedHash, hashedPassword string) (bool, error) {
+	args := m.Called(ctx, userID, verifiedHash, hashedPassword)
+	return args.Bool(0), args.Error(1)
+}
+
 func (m *MockUserRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
@@ -80,6 +94,34 @@ func (m *MockUserRepository) CountUsers(ctx context.Context) (int, error) {
 	return args.Int(0), args.Error(1)
 }
 
+func (m *MockUserRepository) UpdateLastLogin(ctx context.Context, userID uuid.UUID, loginAt time.Time) error {
+	args := m.Called(ctx, userID, loginAt)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) GetInactiveUsers(ctx context.Context, cutoff time.Time) ([]*models.User, error) {
+	args := m.Called(ctx, cutoff)
+	return args.Get(0).([]*models.User), args.Error(1)
+}
+
+func (m *MockUserRepository) GetRecentlyActiveUserIDs(ctx context.Context, limit int) ([]uuid.UUID, error) {
+	args := m.Called(ctx, limit)
+	return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
+func (m *MockUserRepository) GetUserIDsByFilter(ctx context.Context, filter models.UserFilter) ([]uuid.UUID, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
+func (m *MockUserRepository) InvalidateUser(userID uuid.UUID) {
+	m.Called(userID)
+}
+
+func (m *MockUserRepository) InvalidateDeletedUser(userID uuid.UUID) {
+	m.Called(userID)
+}
+
 func (m *MockUserRepository) ExecuteTx(ctx context.Context, fn func(transaction.Repository) error) error {
 	args := m.Called(ctx, fn)
 
@@ -91,3 +133,13 @@ func (m *MockUserRepository) ExecuteTx(ctx context.Context, fn func(transaction.
 
 	return args.Error(0)
 }
+
+func (m *MockUserRepository) GetRolesChangedAt(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(time.Time), args.Error(1)
+}
+
+func (m *MockUserRepository) GetTokensRevokedAt(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(time.Time), args.Error(1)
+}
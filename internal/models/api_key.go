@@ -0,0 +1,85 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrAPIKeyNotFound is returned when an API key looked up or revoked does not exist
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+// APIKey represents a service-to-service credential scoped to a set of permissions
+type APIKey struct {
+	ID          uuid.UUID  `json:"id" db:"id" bson:"_id,omitempty"`
+	Name        string     `json:"name" db:"name" bson:"name"`
+	KeyHash     string     `json:"-" db:"key_hash" bson:"key_hash"` // KeyHash is never included in JSON responses
+	Prefix      string     `json:"prefix" db:"prefix" bson:"prefix"`
+	Permissions []string   `json:"permissions" db:"-" bson:"permissions"`
+	CreatedBy   uuid.UUID  `json:"created_by" db:"created_by" bson:"created_by"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty" db:"expires_at" bson:"expires_at,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty" db:"revoked_at" bson:"revoked_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at" bson:"updated_at"`
+}
+
+// APIKeyCreateRequest represents a request to create an API key
+type APIKeyCreateRequest struct {
+	Name        string   `json:"name" validate:"required,min=3,max=100"`
+	Permissions []string `json:"permissions" validate:"required,min=1"`
+	ExpiresIn   int      `json:"expires_in_days"`
+}
+
+// APIKeyResponse represents an API key response format
+type APIKeyResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	Permissions []string   `json:"permissions"`
+	CreatedBy   uuid.UUID  `json:"created_by"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// APIKeyCreateResponse is returned once at creation and includes the plaintext key
+type APIKeyCreateResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+// IsRevoked reports whether the key has been revoked
+func (k *APIKey) IsRevoked() bool {
+	return k.RevokedAt != nil
+}
+
+// IsExpired reports whether the key has passed its expiry time
+func (k *APIKey) IsExpired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// HasPermission reports whether the key is scoped to the given resource and action
+func (k *APIKey) HasPermission(resource, action string) bool {
+	required := resource + ":" + action
+	for _, permission := range k.Permissions {
+		if permission == required {
+			return true
+		}
+	}
+	return false
+}
+
+// ToResponse converts APIKey to APIKeyResponse
+func (k *APIKey) ToResponse() APIKeyResponse {
+	return APIKeyResponse{
+		ID:          k.ID,
+		Name:        k.Name,
+		Prefix:      k.Prefix,
+		Permissions: k.Permissions,
+		CreatedBy:   k.CreatedBy,
+		ExpiresAt:   k.ExpiresAt,
+		RevokedAt:   k.RevokedAt,
+		CreatedAt:   k.CreatedAt,
+	}
+}
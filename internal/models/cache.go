@@ -0,0 +1,8 @@
+package models
+
+// CacheInvalidationResponse reports the cache keys cleared for one entity or a whole entity type
+type CacheInvalidationResponse struct {
+	Entity  string `json:"entity"`
+	ID      string `json:"id,omitempty"`
+	Cleared int    `json:"cleared"`
+}
@@ -0,0 +1,6 @@
+package models
+
+// FeatureFlagRequest switches a feature on or off at runtime
+type FeatureFlagRequest struct {
+	Enabled *bool `json:"enabled" validate:"required"`
+}
@@ -1,11 +1,58 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// ErrPermissionNotFound is returned when a permission assigned to a role does not exist
+var ErrPermissionNotFound = errors.New("permission not found")
+
+// Errors returned when creating or changing a permission would duplicate a unique field
+var (
+	ErrPermissionExists     = errors.New("permission already exists for this resource and action")
+	ErrPermissionNameExists = errors.New("permission name already exists")
+)
+
+// ErrBlankPermissionLookup is returned when a permission is looked up by an empty or blank resource or action
+var ErrBlankPermissionLookup = errors.New("permission lookup needs a resource and action that are not blank")
+
+// CheckPermissionLookup rejects an empty or whitespace-only resource or action, named by field, of a
+// permission lookup
+func CheckPermissionLookup(field, value string) error {
+	if strings.TrimSpace(value) == "" {
+		return fmt.Errorf("%w: %s is blank", ErrBlankPermissionLookup, field)
+	}
+	return nil
+}
+
+// MissingPermissionsError returns an error wrapping ErrPermissionNotFound that lists every requested
+// permission ID missing from existing, or nil when they all exist
+func MissingPermissionsError(requested, existing []uuid.UUID) error {
+	found := make(map[uuid.UUID]bool, len(existing))
+	for _, id := range existing {
+		found[id] = true
+	}
+
+	missing := make([]string, 0)
+	for _, id := range requested {
+		if !found[id] {
+			missing = append(missing, id.String())
+			// Report a repeated ID once
+			found[id] = true
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrPermissionNotFound, strings.Join(missing, ", "))
+}
+
 // Permission represents a permission in the system
 type Permission struct {
 	ID          uuid.UUID `json:"id" db:"id" bson:"_id,omitempty"`
@@ -13,16 +60,40 @@ type Permission struct {
 	Description string    `json:"description" db:"description" bson:"description"`
 	Resource    string    `json:"resource" db:"resource" bson:"resource"`
 	Action      string    `json:"action" db:"action" bson:"action"`
+	Category    string    `json:"category,omitempty" db:"category" bson:"category,omitempty"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at" bson:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at" bson:"updated_at"`
 }
 
+// PermissionDecision is the outcome of a permission check
+type PermissionDecision string
+
+const (
+	// PermissionGranted means one of the user's roles grants the permission
+	PermissionGranted PermissionDecision = "granted"
+	// PermissionDenied means the permission exists but the user does not hold it
+	PermissionDenied PermissionDecision = "denied"
+	// PermissionUndefined means no permission exists for the resource and action
+	PermissionUndefined PermissionDecision = "undefined"
+)
+
+// PermissionCheck explains the outcome of checking one permission for a user
+type PermissionCheck struct {
+	UserID      string             `json:"user_id"`
+	Permission  string             `json:"permission"`
+	Decision    PermissionDecision `json:"decision"`
+	Allowed     bool               `json:"allowed"`
+	GrantedBy   []string           `json:"granted_by"`
+	Explanation string             `json:"explanation"`
+}
+
 // PermissionCreateRequest represents a request to create a permission
 type PermissionCreateRequest struct {
-	Name        string `json:"name" validate:"required,min=3,max=100"`
+	Name        string `json:"name" validate:"omitempty,min=3,max=100"`
 	Description string `json:"description"`
 	Resource    string `json:"resource" validate:"required,min=1"`
 	Action      string `json:"action" validate:"required,min=1"`
+	Category    string `json:"category" validate:"omitempty,max=100"`
 }
 
 // PermissionUpdateRequest represents a request to update a permission
@@ -31,6 +102,7 @@ type PermissionUpdateRequest struct {
 	Description string `json:"description"`
 	Resource    string `json:"resource" validate:"omitempty,min=1"`
 	Action      string `json:"action" validate:"omitempty,min=1"`
+	Category    string `json:"category" validate:"omitempty,max=100"`
 }
 
 // PermissionResponse represents a permission response format
@@ -40,8 +112,11 @@ type PermissionResponse struct {
 	Description string    `json:"description"`
 	Resource    string    `json:"resource"`
 	Action      string    `json:"action"`
+	Category    string    `json:"category,omitempty"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	// RoleCount is the number of roles granting the permission, only set when usage is requested
+	RoleCount *int `json:"role_count,omitempty"`
 }
 
 // ToResponse converts Permission to PermissionResponse
@@ -52,7 +127,50 @@ func (p *Permission) ToResponse() PermissionResponse {
 		Description: p.Description,
 		Resource:    p.Resource,
 		Action:      p.Action,
+		Category:    p.Category,
 		CreatedAt:   p.CreatedAt,
 		UpdatedAt:   p.UpdatedAt,
 	}
 }
+
+// UncategorizedPermissions names the catalog category of permissions without one
+const UncategorizedPermissions = "uncategorized"
+
+// PermissionCatalog lists permissions grouped by category, then by resource
+type PermissionCatalog struct {
+	Categories []PermissionCategory `json:"categories"`
+}
+
+// PermissionCategory groups the permissions of one category by resource
+type PermissionCategory struct {
+	Name      string                    `json:"name"`
+	Resources []PermissionResourceGroup `json:"resources"`
+}
+
+// PermissionResourceGroup lists the permissions of one resource within a category
+type PermissionResourceGroup struct {
+	Resource    string               `json:"resource"`
+	Permissions []PermissionResponse `json:"permissions"`
+}
+
+// BuiltinPermissions are the permissions the code relies on. Deployments seeded before one was
+// added get it by reconciling the catalog; permissions created by admins are never removed.
+var BuiltinPermissions = []Permission{
+	{Name: "user:read", Resource: "user", Action: "read", Description: "View user information"},
+	{Name: "user:write", Resource: "user", Action: "write", Description: "Create or modify users"},
+	{Name: "user:delete", Resource: "user", Action: "delete", Description: "Delete users"},
+	{Name: "role:read", Resource: "role", Action: "read", Description: "View role information"},
+	{Name: "role:write", Resource: "role", Action: "write", Description: "Create or modify roles"},
+	{Name: "role:delete", Resource: "role", Action: "delete", Description: "Delete roles"},
+	{Name: "permission:read", Resource: "permission", Action: "read", Description: "View permission information"},
+	{Name: "permission:write", Resource: "permission", Action: "write", Description: "Create or modify permissions"},
+	{Name: "permission:delete", Resource: "permission", Action: "delete", Description: "Delete permissions"},
+}
+
+// PermissionReconcileResponse reports the built-in permissions a reconcile created or whose
+// description it restored, and how many already matched
+type PermissionReconcileResponse struct {
+	Created   []PermissionResponse `json:"created"`
+	Updated   []PermissionResponse `json:"updated"`
+	Unchanged int                  `json:"unchanged"`
+}
@@ -0,0 +1,37 @@
+package models
+
+// RBACDocumentVersion is the version of the RBAC export format
+const RBACDocumentVersion = 1
+
+// RBACDocument holds every role and permission and the role->permission mappings by name,
+// so it can be moved between environments whose IDs differ
+type RBACDocument struct {
+	Version     int              `json:"version"`
+	Permissions []RBACPermission `json:"permissions"`
+	Roles       []RBACRole       `json:"roles"`
+}
+
+// RBACPermission is a permission in an RBAC document
+type RBACPermission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Resource    string `json:"resource"`
+	Action      string `json:"action"`
+}
+
+// RBACRole is a role in an RBAC document with the names of its permissions
+type RBACRole struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// RBACImportResponse lists by name what an import created, updated and pruned
+type RBACImportResponse struct {
+	PermissionsCreated []string `json:"permissions_created"`
+	PermissionsUpdated []string `json:"permissions_updated"`
+	PermissionsDeleted []string `json:"permissions_deleted"`
+	RolesCreated       []string `json:"roles_created"`
+	RolesUpdated       []string `json:"roles_updated"`
+	RolesDeleted       []string `json:"roles_deleted"`
+}
@@ -1,11 +1,43 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// ErrRoleNotFound is returned when a role looked up or assigned to a user does not exist
+var ErrRoleNotFound = errors.New("role not found")
+
+// ErrRoleNameExists is returned when creating or renaming a role would duplicate its name
+var ErrRoleNameExists = errors.New("role name already exists")
+
+// MissingRolesError returns an error wrapping ErrRoleNotFound that lists every requested role ID
+// missing from existing, or nil when they all exist
+func MissingRolesError(requested, existing []uuid.UUID) error {
+	found := make(map[uuid.UUID]bool, len(existing))
+	for _, id := range existing {
+		found[id] = true
+	}
+
+	missing := make([]string, 0)
+	for _, id := range requested {
+		if !found[id] {
+			missing = append(missing, id.String())
+			// Report a repeated ID once
+			found[id] = true
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrRoleNotFound, strings.Join(missing, ", "))
+}
+
 // Role represents a user role in the system
 type Role struct {
 	ID          uuid.UUID    `json:"id" db:"id" bson:"_id,omitempty"`
@@ -30,6 +62,59 @@ type RoleUpdateRequest struct {
 	PermissionIDs []string `json:"permission_ids"`
 }
 
+// RolePermissionsValidateRequest represents a request to validate permission IDs for a role
+type RolePermissionsValidateRequest struct {
+	PermissionIDs []string `json:"permission_ids" validate:"required"`
+}
+
+// RolePermissionsValidationResponse reports which permission IDs could be assigned to a role
+type RolePermissionsValidationResponse struct {
+	Valid        bool     `json:"valid"`
+	ValidIDs     []string `json:"valid_ids"`
+	InvalidIDs   []string `json:"invalid_ids"`
+	UnknownIDs   []string `json:"unknown_ids"`
+	DuplicateIDs []string `json:"duplicate_ids"`
+}
+
+// RolePermissionsChangeRequest lists permission IDs to add to or remove from a role
+type RolePermissionsChangeRequest struct {
+	PermissionIDs []string `json:"permission_ids" validate:"required"`
+}
+
+// RolePermissionsChangeResponse reports an incremental change to a role's permissions. Changed lists
+// the IDs actually added or removed; IDs the role already held, or did not hold, are Unchanged.
+type RolePermissionsChangeResponse struct {
+	RoleID      uuid.UUID    `json:"role_id"`
+	Changed     []string     `json:"changed"`
+	Unchanged   []string     `json:"unchanged"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// RolePermissionDiffResponse compares the permissions of two roles
+type RolePermissionDiffResponse struct {
+	RoleID      uuid.UUID            `json:"role_id"`
+	OtherRoleID uuid.UUID            `json:"other_role_id"`
+	OnlyInRole  []PermissionResponse `json:"only_in_role"`
+	OnlyInOther []PermissionResponse `json:"only_in_other"`
+	InBoth      []PermissionResponse `json:"in_both"`
+}
+
+// RolePermissionImpactRequest proposes a new permission set for a role
+type RolePermissionImpactRequest struct {
+	PermissionIDs []string `json:"permission_ids" validate:"required"`
+}
+
+// RolePermissionImpactResponse reports what replacing a role's permissions would change. Every
+// member of the role is affected by a change, so AffectedUsers is RoleMembers unless nothing changes.
+type RolePermissionImpactResponse struct {
+	RoleID        uuid.UUID            `json:"role_id"`
+	Added         []PermissionResponse `json:"added"`
+	Removed       []PermissionResponse `json:"removed"`
+	Unchanged     []PermissionResponse `json:"unchanged"`
+	RoleMembers   int                  `json:"role_members"`
+	AffectedUsers int                  `json:"affected_users"`
+}
+
 // RoleResponse represents a role response format
 type RoleResponse struct {
 	ID          uuid.UUID    `json:"id"`
@@ -38,16 +123,38 @@ type RoleResponse struct {
 	CreatedAt   time.Time    `json:"created_at"`
 	UpdatedAt   time.Time    `json:"updated_at"`
 	Permissions []Permission `json:"permissions,omitempty"`
+	// UserCount and PermissionCount are the number of users holding the role and of permissions it
+	// grants, only set when counts are requested
+	UserCount       *int `json:"user_count,omitempty"`
+	PermissionCount *int `json:"permission_count,omitempty"`
+}
+
+// ResponseOptions controls which nested data a response carries
+type ResponseOptions struct {
+	// IncludePermissions keeps role permissions. Lists leave it unset so they never carry
+	// a permission set per role.
+	IncludePermissions bool
 }
 
-// ToResponse converts Role to RoleResponse
+// WithPermissions is the option for single-entity responses, which include role permissions
+var WithPermissions = ResponseOptions{IncludePermissions: true}
+
+// ToResponse converts Role to RoleResponse without its permissions
 func (r *Role) ToResponse() RoleResponse {
-	return RoleResponse{
+	return r.ToResponseWith(ResponseOptions{})
+}
+
+// ToResponseWith converts Role to RoleResponse, including its permissions if opts asks for them
+func (r *Role) ToResponseWith(opts ResponseOptions) RoleResponse {
+	response := RoleResponse{
 		ID:          r.ID,
 		Name:        r.Name,
 		Description: r.Description,
 		CreatedAt:   r.CreatedAt,
 		UpdatedAt:   r.UpdatedAt,
-		Permissions: r.Permissions,
 	}
+	if opts.IncludePermissions {
+		response.Permissions = r.Permissions
+	}
+	return response
 }
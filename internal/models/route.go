@@ -0,0 +1,22 @@
+package models
+
+// RouteAccess describes what a route requires of the caller
+type RouteAccess struct {
+	// Authenticated routes need a Bearer JWT or an X-API-Key
+	Authenticated bool `json:"authenticated"`
+	// Roles lists the roles of which the caller needs at least one
+	Roles []string `json:"roles,omitempty"`
+	// Self routes also let in the user named by the :id path parameter without the roles
+	Self bool `json:"self,omitempty"`
+	// Permissions lists the "resource:action" permissions the caller needs all of
+	Permissions []string `json:"permissions,omitempty"`
+	// Feature names the feature flag without which the route answers 404
+	Feature string `json:"feature,omitempty"`
+}
+
+// RouteInfo is an HTTP route in the route manifest
+type RouteInfo struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	RouteAccess
+}
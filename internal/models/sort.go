@@ -0,0 +1,70 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SortOrder is the direction of a list ordering
+type SortOrder string
+
+const (
+	// SortAsc orders from lowest to highest
+	SortAsc SortOrder = "asc"
+	// SortDesc orders from highest to lowest
+	SortDesc SortOrder = "desc"
+)
+
+// SortOptions selects how a list is ordered; the zero value keeps the default ordering
+type SortOptions struct {
+	Field string
+	Order SortOrder
+}
+
+// IsDefault reports whether no explicit ordering was requested
+func (s SortOptions) IsDefault() bool {
+	return s.Field == ""
+}
+
+// Sortable fields per entity; the names match both the PostgreSQL columns and the MongoDB fields
+var (
+	UserSortFields       = []string{"username", "email", "first_name", "last_name", "is_active", "last_login_at", "created_at", "updated_at"}
+	RoleSortFields       = []string{"name", "created_at", "updated_at"}
+	PermissionSortFields = []string{"name", "resource", "action", "category", "created_at", "updated_at"}
+)
+
+// ParseSortOptions validates the sort_by and order query values against the sortable fields of an entity
+func ParseSortOptions(sortBy, order string, allowed []string) (SortOptions, error) {
+	sortBy = strings.ToLower(strings.TrimSpace(sortBy))
+	order = strings.ToLower(strings.TrimSpace(order))
+
+	if sortBy == "" {
+		if order != "" {
+			return SortOptions{}, fmt.Errorf("order requires sort_by")
+		}
+		return SortOptions{}, nil
+	}
+
+	if !IsSortField(sortBy, allowed) {
+		return SortOptions{}, fmt.Errorf("invalid sort field %q, must be one of: %s", sortBy, strings.Join(allowed, ", "))
+	}
+
+	switch SortOrder(order) {
+	case "", SortAsc:
+		return SortOptions{Field: sortBy, Order: SortAsc}, nil
+	case SortDesc:
+		return SortOptions{Field: sortBy, Order: SortDesc}, nil
+	default:
+		return SortOptions{}, fmt.Errorf("invalid sort order %q, must be asc or desc", order)
+	}
+}
+
+// IsSortField reports whether field is one of the allowed sortable fields
+func IsSortField(field string, allowed []string) bool {
+	for _, f := range allowed {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSortOptions(t *testing.T) {
+	entities := map[string][]string{
+		"user":       UserSortFields,
+		"role":       RoleSortFields,
+		"permission": PermissionSortFields,
+	}
+
+	for entity, fields := range entities {
+		for _, field := range fields {
+			for _, order := range []SortOrder{SortAsc, SortDesc} {
+				t.Run(entity+" "+field+" "+string(order), func(t *testing.T) {
+					sort, err := ParseSortOptions(field, string(order), fields)
+
+					require.NoError(t, err)
+					assert.Equal(t, SortOptions{Field: field, Order: order}, sort)
+					assert.False(t, sort.IsDefault())
+				})
+			}
+		}
+	}
+
+	t.Run("Defaults when unspecified", func(t *testing.T) {
+		sort, err := ParseSortOptions("", "", UserSortFields)
+
+		require.NoError(t, err)
+		assert.True(t, sort.IsDefault())
+	})
+
+	t.Run("Order defaults to ascending", func(t *testing.T) {
+		sort, err := ParseSortOptions("username", "", UserSortFields)
+
+		require.NoError(t, err)
+		assert.Equal(t, SortAsc, sort.Order)
+	})
+
+	t.Run("Normalizes case", func(t *testing.T) {
+		sort, err := ParseSortOptions(" Email ", "DESC", UserSortFields)
+
+		require.NoError(t, err)
+		assert.Equal(t, SortOptions{Field: "email", Order: SortDesc}, sort)
+	})
+
+	t.Run("Invalid field", func(t *testing.T) {
+		for _, field := range []string{"password", "created_at; DROP TABLE users", "id desc", "description"} {
+			_, err := ParseSortOptions(field, "asc", UserSortFields)
+
+			assert.Error(t, err, field)
+			assert.Contains(t, err.Error(), "invalid sort field")
+		}
+	})
+
+	t.Run("Field allowed for another entity", func(t *testing.T) {
+		_, err := ParseSortOptions("resource", "asc", RoleSortFields)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("Invalid order", func(t *testing.T) {
+		_, err := ParseSortOptions("name", "sideways", RoleSortFields)
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid sort order")
+	})
+
+	t.Run("Order without sort_by", func(t *testing.T) {
+		_, err := ParseSortOptions("", "desc", RoleSortFields)
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "order requires sort_by")
+	})
+}
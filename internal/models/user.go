@@ -1,24 +1,43 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/chats/go-user-api/internal/utils"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUserNotFound is returned when looking up a user that does not exist
+var ErrUserNotFound = errors.New("user not found")
+
+// Errors returned when creating or renaming a user would duplicate a unique field
+var (
+	ErrUsernameExists = errors.New("username already exists")
+	ErrEmailExists    = errors.New("email already exists")
 )
 
 // User represents a user in the system
 type User struct {
-	ID        uuid.UUID `json:"id" db:"id" bson:"_id,omitempty"`
-	Username  string    `json:"username" db:"username" bson:"username"`
-	Email     string    `json:"email" db:"email" bson:"email"`
-	Password  string    `json:"-" db:"password" bson:"password"` // Password is not included in JSON responses
-	FirstName string    `json:"first_name" db:"first_name" bson:"first_name"`
-	LastName  string    `json:"last_name" db:"last_name" bson:"last_name"`
-	IsActive  bool      `json:"is_active" db:"is_active" bson:"is_active"`
-	CreatedAt time.Time `json:"created_at" db:"created_at" bson:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at" bson:"updated_at"`
-	Roles     []Role    `json:"roles,omitempty" db:"-" bson:"roles,omitempty"`
+	ID          uuid.UUID  `json:"id" db:"id" bson:"_id,omitempty"`
+	Username    string     `json:"username" db:"username" bson:"username"`
+	Email       string     `json:"email" db:"email" bson:"email"`
+	Password    string     `json:"-" db:"password" bson:"password"` // Password is not included in JSON responses
+	FirstName   string     `json:"first_name" db:"first_name" bson:"first_name"`
+	LastName    string     `json:"last_name" db:"last_name" bson:"last_name"`
+	IsActive    bool       `json:"is_active" db:"is_active" bson:"is_active"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty" db:"last_login_at" bson:"last_login_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at" bson:"updated_at"`
+	Roles       []Role     `json:"roles,omitempty" db:"-" bson:"roles,omitempty"`
+
+	// PasswordChangedAt is unset for users stored before it was tracked
+	PasswordChangedAt *time.Time `json:"password_changed_at,omitempty" db:"password_changed_at" bson:"password_changed_at,omitempty"`
+
+	// DeletedAt is set when the user was soft-deleted, e.g. merged into another account
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at" bson:"deleted_at,omitempty"`
 }
 
 // UserCreateRequest represents the request to create a new user
@@ -29,6 +48,9 @@ type UserCreateRequest struct {
 	FirstName string   `json:"first_name" validate:"max=150"`
 	LastName  string   `json:"last_name"  validate:"max=150"`
 	RoleIDs   []string `json:"role_ids"`
+
+	// IsActive overrides whether the user starts active; it needs the configured override permission
+	IsActive *bool `json:"is_active,omitempty"`
 }
 
 // UserUpdateRequest represents the request to update a user
@@ -42,23 +64,211 @@ type UserUpdateRequest struct {
 	RoleIDs   []string `json:"role_ids"`
 }
 
+// Role transfer modes
+const (
+	RoleTransferCopy = "copy"
+	RoleTransferMove = "move"
+)
+
+// RoleTransferRequest represents a request to hand one user's roles to another
+type RoleTransferRequest struct {
+	Mode             string `json:"mode"` // copy (default) or move
+	DeactivateSource bool   `json:"deactivate_source"`
+}
+
+// RoleTransferResponse reports which roles a transfer assigned
+type RoleTransferResponse struct {
+	SourceUserID      uuid.UUID `json:"source_user_id"`
+	TargetUserID      uuid.UUID `json:"target_user_id"`
+	Mode              string    `json:"mode"`
+	TransferredRoles  []string  `json:"transferred_roles"`
+	AlreadyAssigned   []string  `json:"already_assigned_roles"`
+	SourceDeactivated bool      `json:"source_deactivated"`
+}
+
+// UserMergeResponse summarizes merging a duplicate source user into a target user
+type UserMergeResponse struct {
+	SourceUserID      uuid.UUID `json:"source_user_id"`
+	TargetUserID      uuid.UUID `json:"target_user_id"`
+	MergedRoles       []string  `json:"merged_roles"`
+	AlreadyAssigned   []string  `json:"already_assigned_roles"`
+	APIKeysReassigned int       `json:"api_keys_reassigned"`
+	SourceDeletedAt   time.Time `json:"source_deleted_at"`
+}
+
+// UserPolicyVersion identifies the schema of UserPolicy, so consumers can detect changes to it
+const UserPolicyVersion = "1"
+
+// UserPolicy is a user's complete access as one document: their roles with each role's permissions,
+// and the effective permission set. Permissions are "resource:action" strings, sorted.
+type UserPolicy struct {
+	Version              string             `json:"version"`
+	UserID               uuid.UUID          `json:"user_id"`
+	Username             string             `json:"username"`
+	Roles                []PolicyRole       `json:"roles"`
+	EffectivePermissions []PolicyPermission `json:"effective_permissions"`
+}
+
+// PolicyRole lists the permissions a role grants
+type PolicyRole struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Permissions []string  `json:"permissions"`
+}
+
+// PolicyPermission is one effective permission with the roles granting it
+type PolicyPermission struct {
+	Permission string   `json:"permission"`
+	GrantedBy  []string `json:"granted_by"`
+}
+
+// UserFilter selects users for bulk operations and searches; unset fields match every user.
+// Query matches part of the username, email, first or last name, ignoring case.
+// CreatedBefore and CreatedAfter are exclusive bounds, CreatedFrom and CreatedTo inclusive ones.
+type UserFilter struct {
+	Query         string     `json:"query"`
+	RoleName      string     `json:"role_name"`
+	IsActive      *bool      `json:"is_active"`
+	CreatedBefore *time.Time `json:"created_before"`
+	CreatedAfter  *time.Time `json:"created_after"`
+	CreatedFrom   *time.Time `json:"created_from"`
+	CreatedTo     *time.Time `json:"created_to"`
+}
+
+// IsEmpty reports whether the filter sets no criterion and so matches every user
+func (f UserFilter) IsEmpty() bool {
+	return f.Query == "" && f.RoleName == "" && f.IsActive == nil &&
+		f.CreatedBefore == nil && f.CreatedAfter == nil && f.CreatedFrom == nil && f.CreatedTo == nil
+}
+
+// createdDateLayout is the date-only form accepted for created ranges besides RFC 3339
+const createdDateLayout = "2006-01-02"
+
+// ParseCreatedRange parses the created_from and created_to query values into inclusive bounds;
+// an empty value leaves that side open. Values are RFC 3339 timestamps or YYYY-MM-DD dates, and
+// a date-only created_to covers the whole day.
+func ParseCreatedRange(from, to string) (*time.Time, *time.Time, error) {
+	createdFrom, err := parseCreatedBound("created_from", from, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	createdTo, err := parseCreatedBound("created_to", to, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if createdFrom != nil && createdTo != nil && createdFrom.After(*createdTo) {
+		return nil, nil, fmt.Errorf("created_from must not be after created_to")
+	}
+
+	return createdFrom, createdTo, nil
+}
+
+// parseCreatedBound parses one side of a created range; endOfDay extends a date-only value to its last instant
+func parseCreatedBound(name, value string, endOfDay bool) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+
+	t, err := time.Parse(createdDateLayout, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q, must be an RFC 3339 timestamp or YYYY-MM-DD date", name, value)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return &t, nil
+}
+
+// BulkDeactivateRequest represents a request to deactivate every user matching a filter
+type BulkDeactivateRequest struct {
+	Filter UserFilter `json:"filter"`
+	DryRun bool       `json:"dry_run"`
+}
+
+// BulkDeactivateResponse reports how many users a bulk deactivation matched and deactivated.
+// On a dry run Deactivated is the number that would have been deactivated.
+type BulkDeactivateResponse struct {
+	DryRun      bool `json:"dry_run"`
+	Matched     int  `json:"matched"`
+	Deactivated int  `json:"deactivated"`
+}
+
+// Columns of a user import CSV. The header names them in any order; username, email and password are
+// required, and the password column holds a password or "generate".
+const (
+	ImportColumnUsername  = "username"
+	ImportColumnEmail     = "email"
+	ImportColumnFirstName = "first_name"
+	ImportColumnLastName  = "last_name"
+	ImportColumnRoles     = "roles"
+	ImportColumnPassword  = "password"
+)
+
+// ImportGeneratePassword in the password column has a password generated for the user
+const ImportGeneratePassword = "generate"
+
+// Outcomes of a user import row
+const (
+	ImportRowCreated   = "created"
+	ImportRowValid     = "valid"
+	ImportRowDuplicate = "duplicate"
+	ImportRowInvalid   = "invalid"
+	ImportRowFailed    = "failed"
+)
+
+// UserImportRow reports what a user import did with one CSV row. Row is the line number, the header
+// being line 1.
+type UserImportRow struct {
+	Row      int        `json:"row"`
+	Username string     `json:"username"`
+	Status   string     `json:"status"`
+	UserID   *uuid.UUID `json:"user_id,omitempty"`
+	// GeneratedPassword is set on created users whose password was generated
+	GeneratedPassword string `json:"generated_password,omitempty"`
+	Error             string `json:"error,omitempty"`
+}
+
+// UserImportResponse reports a user import row by row. On a dry run nothing is created and the rows
+// that would have been are valid.
+type UserImportResponse struct {
+	DryRun     bool            `json:"dry_run"`
+	Total      int             `json:"total"`
+	Created    int             `json:"created"`
+	Valid      int             `json:"valid"`
+	Duplicates int             `json:"duplicates"`
+	Invalid    int             `json:"invalid"`
+	Failed     int             `json:"failed"`
+	Rows       []UserImportRow `json:"rows"`
+}
+
 // UserResponse represents the user response format
 type UserResponse struct {
-	ID        uuid.UUID `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	FirstName string    `json:"first_name"`
-	LastName  string    `json:"last_name"`
-	IsActive  bool      `json:"is_active"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Roles     []Role    `json:"roles,omitempty"`
+	ID          uuid.UUID      `json:"id"`
+	Username    string         `json:"username"`
+	Email       string         `json:"email"`
+	FirstName   string         `json:"first_name"`
+	LastName    string         `json:"last_name"`
+	IsActive    bool           `json:"is_active"`
+	LastLoginAt *time.Time     `json:"last_login_at,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	Roles       []RoleResponse `json:"roles,omitempty"`
+	DeletedAt   *time.Time     `json:"deleted_at,omitempty"`
 }
 
 // LoginRequest represents a login request
 type LoginRequest struct {
-	Username string `json:"username" validate:"required"`
-	Password string `json:"password" validate:"required"`
+	Username     string `json:"username" validate:"required"`
+	Password     string `json:"password" validate:"required"`
+	CaptchaToken string `json:"captcha_token,omitempty"`
+	ClientIP     string `json:"-"`
 }
 
 // LoginResponse represents a login response
@@ -67,35 +277,95 @@ type LoginResponse struct {
 	TokenType   string       `json:"token_type"`
 	ExpiresIn   int          `json:"expires_in"`
 	User        UserResponse `json:"user"`
+
+	// MustChangePassword is set when the password has expired; the token then only allows changing it
+	MustChangePassword bool `json:"must_change_password"`
+
+	// RefreshToken obtains new tokens at /auth/refresh; unset when refresh tokens are disabled
+	RefreshToken     string `json:"refresh_token,omitempty"`
+	RefreshExpiresIn int    `json:"refresh_expires_in,omitempty"`
 }
 
-// HashPassword hashes a plaintext password
-func (u *User) HashPassword(plainPassword string) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(plainPassword), bcrypt.DefaultCost)
+// RefreshTokenRequest represents a request to exchange a refresh token for new tokens
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// HashPassword hashes a plaintext password, combined with the newest pepper when peppers are given
+func (u *User) HashPassword(plainPassword string, peppers ...string) error {
+	hashedPassword, err := utils.HashPassword(plainPassword, peppers...)
 	if err != nil {
 		return err
 	}
-	u.Password = string(hashedPassword)
+	u.Password = hashedPassword
 	return nil
 }
 
-// CheckPassword verifies the password against the stored hash
-func (u *User) CheckPassword(plainPassword string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(plainPassword))
-	return err == nil
+// CheckPassword verifies the password against the stored hash, trying each pepper
+func (u *User) CheckPassword(plainPassword string, peppers ...string) bool {
+	return utils.CheckPassword(plainPassword, u.Password, peppers...)
+}
+
+// PasswordExpired reports whether the password is older than maxAge at now. Passwords stored before
+// their change time was tracked count from when the user was created. A maxAge of 0 never expires.
+func (u *User) PasswordExpired(maxAge time.Duration, now time.Time) bool {
+	if maxAge <= 0 {
+		return false
+	}
+
+	changedAt := u.CreatedAt
+	if u.PasswordChangedAt != nil {
+		changedAt = *u.PasswordChangedAt
+	}
+	return now.Sub(changedAt) > maxAge
 }
 
-// ToResponse converts User to UserResponse
+// ToResponse converts User to UserResponse without role permissions
 func (u *User) ToResponse() UserResponse {
+	return u.ToResponseWith(ResponseOptions{})
+}
+
+// ToResponseWith converts User to UserResponse, applying opts to each role
+func (u *User) ToResponseWith(opts ResponseOptions) UserResponse {
+	var roles []RoleResponse
+	if len(u.Roles) > 0 {
+		roles = make([]RoleResponse, len(u.Roles))
+		for i := range u.Roles {
+			roles[i] = u.Roles[i].ToResponseWith(opts)
+		}
+	}
+
 	return UserResponse{
-		ID:        u.ID,
-		Username:  u.Username,
-		Email:     u.Email,
-		FirstName: u.FirstName,
-		LastName:  u.LastName,
-		IsActive:  u.IsActive,
-		CreatedAt: u.CreatedAt,
-		UpdatedAt: u.UpdatedAt,
-		Roles:     u.Roles,
+		ID:          u.ID,
+		Username:    u.Username,
+		Email:       u.Email,
+		FirstName:   u.FirstName,
+		LastName:    u.LastName,
+		IsActive:    u.IsActive,
+		LastLoginAt: u.LastLoginAt,
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+		Roles:       roles,
+		DeletedAt:   u.DeletedAt,
+	}
+}
+
+// LastActivityAt returns the last login time, or the creation time if the user never logged in
+func (u *User) LastActivityAt() time.Time {
+	if u.LastLoginAt != nil {
+		return *u.LastLoginAt
+	}
+	return u.CreatedAt
+}
+
+// HasRole checks if the user has one of the given roles
+func (u *User) HasRole(names ...string) bool {
+	for _, role := range u.Roles {
+		for _, name := range names {
+			if role.Name == name {
+				return true
+			}
+		}
 	}
+	return false
 }
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCreatedRange(t *testing.T) {
+	t.Run("Open when unspecified", func(t *testing.T) {
+		from, to, err := ParseCreatedRange("", "")
+
+		require.NoError(t, err)
+		assert.Nil(t, from)
+		assert.Nil(t, to)
+	})
+
+	t.Run("RFC 3339 timestamps", func(t *testing.T) {
+		from, to, err := ParseCreatedRange("2024-01-01T00:00:00Z", "2024-01-31T12:30:00+02:00")
+
+		require.NoError(t, err)
+		assert.True(t, from.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+		assert.True(t, to.Equal(time.Date(2024, 1, 31, 10, 30, 0, 0, time.UTC)))
+	})
+
+	t.Run("Date-only created_to covers the whole day", func(t *testing.T) {
+		from, to, err := ParseCreatedRange("2024-01-01", "2024-01-01")
+
+		require.NoError(t, err)
+		assert.True(t, from.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+		assert.True(t, to.Equal(time.Date(2024, 1, 1, 23, 59, 59, 999999999, time.UTC)))
+	})
+
+	t.Run("Invalid format", func(t *testing.T) {
+		_, _, err := ParseCreatedRange("01/02/2024", "")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid created_from")
+	})
+
+	t.Run("From after to", func(t *testing.T) {
+		_, _, err := ParseCreatedRange("2024-02-01", "2024-01-01")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must not be after")
+	})
+}
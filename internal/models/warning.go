@@ -0,0 +1,26 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Warning reports an item of a list that could only be partly assembled
+type Warning struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+}
+
+// PartialResultError is returned alongside a list whose items were not all fully assembled.
+// The list is still usable; each warning describes what is missing from an item.
+type PartialResultError struct {
+	Warnings []Warning
+}
+
+func (e *PartialResultError) Error() string {
+	messages := make([]string, len(e.Warnings))
+	for i, warning := range e.Warnings {
+		messages[i] = warning.ID + ": " + warning.Message
+	}
+	return fmt.Sprintf("%d list items incomplete: %s", len(e.Warnings), strings.Join(messages, "; "))
+}
@@ -0,0 +1,159 @@
+// Package msgpack encodes MessagePack response bodies. Values go through encoding/json first,
+// so struct tags, omitempty and custom marshalers behave exactly as in JSON responses,
+// and a MessagePack body carries the same structure as its JSON equivalent.
+package msgpack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ContentType is the media type of MessagePack bodies
+const ContentType = "application/msgpack"
+
+// Marshal returns the MessagePack encoding of v
+func Marshal(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var tree interface{}
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := encode(&buf, tree); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encode writes a value produced by decoding JSON with UseNumber
+func encode(buf *bytes.Buffer, v interface{}) error {
+	switch value := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if value {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			encodeInt(buf, i)
+			return nil
+		}
+		f, err := value.Float64()
+		if err != nil {
+			return fmt.Errorf("msgpack: invalid number %q", value)
+		}
+		buf.WriteByte(0xcb)
+		writeUint(buf, math.Float64bits(f), 8)
+	case string:
+		encodeString(buf, value)
+	case []interface{}:
+		writeHeader(buf, len(value), 0x90, 0xdc, 0xdd)
+		for _, item := range value {
+			if err := encode(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		// Sorted keys keep the encoding deterministic
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		writeHeader(buf, len(value), 0x80, 0xde, 0xdf)
+		for _, key := range keys {
+			encodeString(buf, key)
+			if err := encode(buf, value[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unsupported type %T", v)
+	}
+	return nil
+}
+
+// encodeInt writes i in the smallest integer format that holds it
+func encodeInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0 && i <= math.MaxInt8:
+		buf.WriteByte(byte(i))
+	case i >= -32 && i < 0:
+		buf.WriteByte(byte(0xe0 | (i + 32)))
+	case i >= 0 && i <= math.MaxUint8:
+		buf.WriteByte(0xcc)
+		writeUint(buf, uint64(i), 1)
+	case i >= 0 && i <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		writeUint(buf, uint64(i), 2)
+	case i >= 0 && i <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		writeUint(buf, uint64(i), 4)
+	case i >= math.MinInt8 && i < 0:
+		buf.WriteByte(0xd0)
+		writeUint(buf, uint64(i), 1)
+	case i >= math.MinInt16 && i < 0:
+		buf.WriteByte(0xd1)
+		writeUint(buf, uint64(i), 2)
+	case i >= math.MinInt32 && i < 0:
+		buf.WriteByte(0xd2)
+		writeUint(buf, uint64(i), 4)
+	default:
+		buf.WriteByte(0xd3)
+		writeUint(buf, uint64(i), 8)
+	}
+}
+
+func encodeString(buf *bytes.Buffer, s string) {
+	switch n := len(s); {
+	case n < 32:
+		buf.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xd9)
+		writeUint(buf, uint64(n), 1)
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xda)
+		writeUint(buf, uint64(n), 2)
+	default:
+		buf.WriteByte(0xdb)
+		writeUint(buf, uint64(n), 4)
+	}
+	buf.WriteString(s)
+}
+
+// writeHeader writes an array or map header using the fix, 16-bit or 32-bit form
+func writeHeader(buf *bytes.Buffer, n int, fix, code16, code32 byte) {
+	switch {
+	case n < 16:
+		buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(code16)
+		writeUint(buf, uint64(n), 2)
+	default:
+		buf.WriteByte(code32)
+		writeUint(buf, uint64(n), 4)
+	}
+}
+
+// writeUint writes the low size bytes of v in big-endian order
+func writeUint(buf *bytes.Buffer, v uint64, size int) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	buf.Write(b[8-size:])
+}
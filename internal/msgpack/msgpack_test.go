@@ -0,0 +1,96 @@
+package msgpack
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshal_Formats(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  []byte
+	}{
+		{"Nil", nil, []byte{0xc0}},
+		{"True", true, []byte{0xc3}},
+		{"False", false, []byte{0xc2}},
+		{"Positive fixint", 5, []byte{0x05}},
+		{"Negative fixint", -1, []byte{0xff}},
+		{"Uint8", 200, []byte{0xcc, 0xc8}},
+		{"Uint16", 1000, []byte{0xcd, 0x03, 0xe8}},
+		{"Int8", -100, []byte{0xd0, 0x9c}},
+		{"Int64", int64(math.MinInt64), []byte{0xd3, 0x80, 0, 0, 0, 0, 0, 0, 0}},
+		{"Float64", 1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"Fixstr", "abc", []byte{0xa3, 'a', 'b', 'c'}},
+		{"Fixarray", []int{1, 2}, []byte{0x92, 0x01, 0x02}},
+		{"Fixmap with sorted keys", map[string]int{"b": 2, "a": 1}, []byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x02}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Marshal(tt.value)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, data)
+		})
+	}
+}
+
+func TestMarshal_Lengths(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  interface{}
+		header []byte
+	}{
+		{"Str8", strings.Repeat("a", 32), []byte{0xd9, 32}},
+		{"Str16", strings.Repeat("a", 256), []byte{0xda, 0x01, 0x00}},
+		{"Str32", strings.Repeat("a", 70000), []byte{0xdb, 0x00, 0x01, 0x11, 0x70}},
+		{"Array16", make([]int, 16), []byte{0xdc, 0x00, 0x10}},
+		{"Map16", func() map[string]int {
+			m := make(map[string]int, 16)
+			for i := 0; i < 16; i++ {
+				m[strings.Repeat("k", i+1)] = i
+			}
+			return m
+		}(), []byte{0xde, 0x00, 0x10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Marshal(tt.value)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.header, data[:len(tt.header)])
+		})
+	}
+}
+
+func TestMarshal_FollowsJSON(t *testing.T) {
+	type user struct {
+		ID          uuid.UUID  `json:"id"`
+		LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+		CreatedAt   time.Time  `json:"created_at"`
+		Secret      string     `json:"-"`
+	}
+
+	id := uuid.New()
+	createdAt := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	data, err := Marshal(user{ID: id, CreatedAt: createdAt, Secret: "not encoded"})
+	require.NoError(t, err)
+
+	// Tagged names, omitted empty fields and values marshaled as JSON strings, keys sorted
+	want, err := Marshal(map[string]string{
+		"created_at": "2024-05-06T07:08:09Z",
+		"id":         id.String(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, want, data)
+	assert.NotContains(t, string(data), "not encoded")
+}
@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"fmt"
+
+	"github.com/chats/go-user-api/internal/cache"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// CacheInvalidator clears the cached copies of an entity on demand, for when the cache has drifted
+// from the database. It knows the keys the repositories write, which are the same for both backends.
+type CacheInvalidator struct {
+	cache *cache.RedisClient
+}
+
+// NewCacheInvalidator creates a new cache invalidator
+func NewCacheInvalidator(cache *cache.RedisClient) *CacheInvalidator {
+	return &CacheInvalidator{
+		cache: cache,
+	}
+}
+
+// InvalidateUser clears a user's entries and the user lists, and returns the number of keys cleared
+func (i *CacheInvalidator) InvalidateUser(id uuid.UUID) (int, error) {
+	return i.purge(userKeysFor(i.cache, id), "users:*")
+}
+
+// userKeysFor returns the keys of a user's entries. The username entry is found through the username
+// recorded by cacheUserByUsername, or else through the cached user.
+func userKeysFor(c *cache.RedisClient, id uuid.UUID) []string {
+	keys := []string{
+		fmt.Sprintf("user:%s", id.String()),
+		fmt.Sprintf("user:%s:roles_changed_at", id.String()),
+		fmt.Sprintf("user:%s:tokens_revoked_at", id.String()),
+		usernameRecordKey(id),
+	}
+
+	var username string
+	if found, _ := c.Get(usernameRecordKey(id), &username); !found {
+		var user models.User
+		if found, _ := c.Get(keys[0], &user); found {
+			username = user.Username
+		}
+	}
+	if username != "" {
+		keys = append(keys, fmt.Sprintf("user:username:%s", username))
+	}
+
+	return keys
+}
+
+// usernameRecordKey holds the username a user was last cached under
+func usernameRecordKey(id uuid.UUID) string {
+	return fmt.Sprintf("user:%s:username", id.String())
+}
+
+// cacheUserByUsername caches a user under its username and records the username, so userKeysFor
+// finds the entry even when the user is not cached by ID
+func cacheUserByUsername(c *cache.RedisClient, user models.User) {
+	if err := c.Set(fmt.Sprintf("user:username:%s", user.Username), user); err != nil {
+		log.Debug().Err(err).Msg("Failed to cache user")
+		return
+	}
+
+	// Written second, so it does not expire before the entry it points to
+	if err := c.Set(usernameRecordKey(user.ID), user.Username); err != nil {
+		log.Debug().Err(err).Msg("Failed to record cached username")
+	}
+}
+
+// InvalidateRole clears a role's entries, the role lists and the permissions resolved from roles,
+// and returns the number of keys cleared. The name entry is found through the cached role.
+func (i *CacheInvalidator) InvalidateRole(id uuid.UUID) (int, error) {
+	keys := []string{fmt.Sprintf("role:%s", id.String())}
+
+	var role models.Role
+	if found, _ := i.cache.Get(keys[0], &role); found && role.Name != "" {
+		keys = append(keys, fmt.Sprintf("role:name:%s", role.Name))
+	}
+
+	// Role sets are keyed by a hash of their IDs, so every set is cleared
+	return i.purge(keys, "roles:*", roleSetPermissionsPrefix+"*", "user:permissions:*")
+}
+
+// InvalidatePermission clears a permission's entries, the permission lists and the roles embedding
+// permissions, and returns the number of keys cleared. The resource and action entry is found
+// through the cached permission.
+func (i *CacheInvalidator) InvalidatePermission(id uuid.UUID) (int, error) {
+	keys := []string{fmt.Sprintf("permission:%s", id.String())}
+
+	var permission models.Permission
+	if found, _ := i.cache.Get(keys[0], &permission); found && permission.Resource != "" {
+		keys = append(keys, fmt.Sprintf("permission:resource:%s:action:%s", permission.Resource, permission.Action))
+	}
+
+	return i.purge(keys, "permissions:*", "role:*", "roles:*", "user:permissions:*")
+}
+
+// InvalidateAllUsers clears every cached user and user list
+func (i *CacheInvalidator) InvalidateAllUsers() (int, error) {
+	return i.purge(nil, "user:*", "users:*")
+}
+
+// InvalidateAllRoles clears every cached role, role list and the permissions resolved from roles
+func (i *CacheInvalidator) InvalidateAllRoles() (int, error) {
+	return i.purge(nil, "role:*", "roles:*", roleSetPermissionsPrefix+"*", "user:permissions:*")
+}
+
+// InvalidateAllPermissions clears every cached permission, permission list and the roles embedding them
+func (i *CacheInvalidator) InvalidateAllPermissions() (int, error) {
+	return i.purge(nil, "permission:*", "permissions:*", "role:*", "roles:*", "user:permissions:*")
+}
+
+func (i *CacheInvalidator) purge(keys []string, patterns ...string) (int, error) {
+	cleared, err := i.cache.Purge(keys, patterns)
+	if err != nil {
+		log.Error().Err(err).Strs("patterns", patterns).Msg("Failed to invalidate cache")
+		return 0, err
+	}
+	return cleared, nil
+}
@@ -0,0 +1,147 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheInvalidator(t *testing.T) {
+	userID := uuid.New()
+	otherUserID := uuid.New()
+	roleID := uuid.New()
+	otherRoleID := uuid.New()
+	permissionID := uuid.New()
+	otherPermissionID := uuid.New()
+
+	// seed caches the entries written by the repositories for two of each entity
+	seed := func(t *testing.T) (*CacheInvalidator, *miniredis.Miniredis) {
+		t.Helper()
+
+		redisClient, redisServer := newTestRedisClient(t)
+		entries := map[string]interface{}{
+			"user:" + userID.String():                        models.User{ID: userID, Username: "johndoe"},
+			"user:" + userID.String() + ":roles_changed_at":  "2024-01-01T00:00:00Z",
+			"user:" + userID.String() + ":tokens_revoked_at": "2024-01-01T00:00:00Z",
+			"user:username:johndoe":                          models.User{ID: userID, Username: "johndoe"},
+			"user:" + otherUserID.String():                   models.User{ID: otherUserID, Username: "janedoe"},
+			"user:username:janedoe":                          models.User{ID: otherUserID, Username: "janedoe"},
+			"users:count":                                    2,
+			"role:" + roleID.String():                        models.Role{ID: roleID, Name: "editor"},
+			"role:name:editor":                               models.Role{ID: roleID, Name: "editor"},
+			"role:" + otherRoleID.String():                   models.Role{ID: otherRoleID, Name: "viewer"},
+			"role:name:viewer":                               models.Role{ID: otherRoleID, Name: "viewer"},
+			"roles:all":                                      []models.Role{},
+			roleSetPermissionsPrefix + "abc":                 []models.Permission{},
+			"permission:" + permissionID.String():            models.Permission{ID: permissionID, Resource: "user", Action: "read"},
+			"permission:resource:user:action:read":           models.Permission{ID: permissionID, Resource: "user", Action: "read"},
+			"permission:" + otherPermissionID.String():       models.Permission{ID: otherPermissionID, Resource: "role", Action: "read"},
+			"permission:resource:role:action:read":           models.Permission{ID: otherPermissionID, Resource: "role", Action: "read"},
+			"permissions:all":                                []models.Permission{},
+			"apikey:hash:abc":                                "key",
+		}
+		require.NoError(t, redisClient.MSet(entries))
+
+		return NewCacheInvalidator(redisClient), redisServer
+	}
+
+	tests := []struct {
+		name       string
+		invalidate func(i *CacheInvalidator) (int, error)
+		cleared    []string
+	}{
+		{
+			name:       "User",
+			invalidate: func(i *CacheInvalidator) (int, error) { return i.InvalidateUser(userID) },
+			cleared: []string{
+				"user:" + userID.String(),
+				"user:" + userID.String() + ":roles_changed_at",
+				"user:" + userID.String() + ":tokens_revoked_at",
+				"user:username:johndoe",
+				"users:count",
+			},
+		},
+		{
+			name:       "Role",
+			invalidate: func(i *CacheInvalidator) (int, error) { return i.InvalidateRole(roleID) },
+			cleared: []string{
+				"role:" + roleID.String(),
+				"role:name:editor",
+				"roles:all",
+				roleSetPermissionsPrefix + "abc",
+			},
+		},
+		{
+			name:       "Permission",
+			invalidate: func(i *CacheInvalidator) (int, error) { return i.InvalidatePermission(permissionID) },
+			cleared: []string{
+				"permission:" + permissionID.String(),
+				"permission:resource:user:action:read",
+				"permissions:all",
+				roleSetPermissionsPrefix + "abc",
+				// Roles embed their permissions
+				"role:" + roleID.String(),
+				"role:name:editor",
+				"role:" + otherRoleID.String(),
+				"role:name:viewer",
+				"roles:all",
+			},
+		},
+		{
+			name:       "All users",
+			invalidate: (*CacheInvalidator).InvalidateAllUsers,
+			cleared: []string{
+				"user:" + userID.String(),
+				"user:" + userID.String() + ":roles_changed_at",
+				"user:" + userID.String() + ":tokens_revoked_at",
+				"user:username:johndoe",
+				"user:" + otherUserID.String(),
+				"user:username:janedoe",
+				"users:count",
+			},
+		},
+		{
+			name:       "All roles",
+			invalidate: (*CacheInvalidator).InvalidateAllRoles,
+			cleared: []string{
+				"role:" + roleID.String(),
+				"role:name:editor",
+				"role:" + otherRoleID.String(),
+				"role:name:viewer",
+				"roles:all",
+				roleSetPermissionsPrefix + "abc",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invalidator, redisServer := seed(t)
+			before := redisServer.Keys()
+
+			cleared, err := tt.invalidate(invalidator)
+
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.cleared), cleared)
+			for _, key := range tt.cleared {
+				assert.False(t, redisServer.Exists(key), "%s should be cleared", key)
+			}
+			assert.Len(t, redisServer.Keys(), len(before)-len(tt.cleared), "other keys should be intact")
+		})
+	}
+
+	t.Run("Username is kept when the user is not cached", func(t *testing.T) {
+		invalidator, redisServer := seed(t)
+		redisServer.Del("user:" + userID.String())
+
+		cleared, err := invalidator.InvalidateUser(userID)
+
+		require.NoError(t, err)
+		assert.Equal(t, 3, cleared)
+		assert.True(t, redisServer.Exists("user:username:johndoe"))
+	})
+}
@@ -0,0 +1,180 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chats/go-user-api/internal/cache"
+	"github.com/chats/go-user-api/internal/database"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoAPIKeyRepository handles database operations for API keys with MongoDB
+type MongoAPIKeyRepository struct {
+	db    *database.MongoDB
+	cache *cache.RedisClient
+}
+
+// NewMongoAPIKeyRepository creates a new MongoDB API key repository
+func NewMongoAPIKeyRepository(db *database.MongoDB, cache *cache.RedisClient) *MongoAPIKeyRepository {
+	return &MongoAPIKeyRepository{
+		db:    db,
+		cache: cache,
+	}
+}
+
+// apiKeysCollection returns the MongoDB collection for API keys
+func (r *MongoAPIKeyRepository) apiKeysCollection() *mongo.Collection {
+	return r.db.GetCollection("api_keys")
+}
+
+// Create creates a new API key in the database
+func (r *MongoAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	// Generate UUID if not provided
+	if key.ID == uuid.Nil {
+		key.ID = uuid.New()
+	}
+
+	// Set timestamps if not provided
+	now := time.Now()
+	if key.CreatedAt.IsZero() {
+		key.CreatedAt = now
+	}
+	if key.UpdatedAt.IsZero() {
+		key.UpdatedAt = now
+	}
+
+	// Insert into database
+	_, err := r.apiKeysCollection().InsertOne(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to create API key in MongoDB: %w", err)
+	}
+
+	// Clear cache
+	r.invalidateAPIKeyCache()
+
+	return nil
+}
+
+// GetByID retrieves an API key by ID
+func (r *MongoAPIKeyRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.APIKey, error) {
+	var key models.APIKey
+
+	err := r.apiKeysCollection().FindOne(ctx, bson.M{"_id": id}).Decode(&key)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, models.ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get API key from MongoDB: %w", err)
+	}
+
+	return &key, nil
+}
+
+// GetByHash retrieves an API key by the hash of its secret
+func (r *MongoAPIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	cacheKey := apiKeyHashCacheKey(keyHash)
+
+	// Try to get from cache first
+	var key models.APIKey
+	found, err := r.cache.Get(cacheKey, &key)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get API key from cache")
+	}
+
+	if found {
+		key.KeyHash = keyHash
+		return &key, nil
+	}
+
+	// If not in cache, get from database
+	err = r.apiKeysCollection().FindOne(ctx, bson.M{"key_hash": keyHash}).Decode(&key)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, models.ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get API key from MongoDB: %w", err)
+	}
+
+	// Cache the API key
+	if err := r.cache.Set(cacheKey, key); err != nil {
+		log.Debug().Err(err).Msg("Failed to cache API key")
+	}
+
+	return &key, nil
+}
+
+// GetAll retrieves all API keys
+func (r *MongoAPIKeyRepository) GetAll(ctx context.Context) ([]*models.APIKey, error) {
+	return r.findAPIKeys(ctx, bson.M{})
+}
+
+// GetByCreator retrieves the API keys created by a user
+func (r *MongoAPIKeyRepository) GetByCreator(ctx context.Context, userID uuid.UUID) ([]*models.APIKey, error) {
+	return r.findAPIKeys(ctx, bson.M{"created_by": userID})
+}
+
+// findAPIKeys retrieves the API keys matching filter, newest first
+func (r *MongoAPIKeyRepository) findAPIKeys(ctx context.Context, filter bson.M) ([]*models.APIKey, error) {
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}})
+
+	cursor, err := r.apiKeysCollection().Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API keys from MongoDB: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	keys := make([]*models.APIKey, 0)
+	for cursor.Next(ctx) {
+		var key models.APIKey
+		if err := cursor.Decode(&key); err != nil {
+			return nil, fmt.Errorf("failed to decode API key from MongoDB: %w", err)
+		}
+		keys = append(keys, &key)
+	}
+
+	return keys, nil
+}
+
+// Revoke marks an API key as revoked
+func (r *MongoAPIKeyRepository) Revoke(ctx context.Context, id uuid.UUID) error {
+	now := time.Now()
+	filter := bson.M{"_id": id, "revoked_at": bson.M{"$exists": false}}
+	update := bson.M{
+		"$set": bson.M{
+			"revoked_at": now,
+			"updated_at": now,
+		},
+	}
+
+	var revoked models.APIKey
+	err := r.apiKeysCollection().FindOneAndUpdate(ctx, filter, update).Decode(&revoked)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.ErrAPIKeyNotFound
+		}
+		return fmt.Errorf("failed to revoke API key in MongoDB: %w", err)
+	}
+
+	// Drop the cached key synchronously, so it stops authenticating now even when
+	// pattern invalidation runs in the background
+	if err := r.cache.Delete(apiKeyHashCacheKey(revoked.KeyHash)); err != nil {
+		log.Warn().Err(err).Str("api_key_id", id.String()).Msg("Failed to delete revoked API key from cache")
+	}
+	r.invalidateAPIKeyCache()
+
+	return nil
+}
+
+// invalidateAPIKeyCache clears all API key related cache
+func (r *MongoAPIKeyRepository) invalidateAPIKeyCache() {
+	if err := r.cache.DeleteByPattern("apikey:*"); err != nil {
+		log.Debug().Err(err).Msg("Failed to invalidate API key cache")
+	}
+}
@@ -0,0 +1,28 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// linkUpserts returns one upsert per ID linking it to the owner, e.g. a user to each of its roles.
+// Unlike an insert, upserting a link that already exists, from a duplicated ID or a concurrent
+// assignment, leaves it alone rather than failing the unique index and the transaction with it.
+func linkUpserts(ownerField string, ownerID uuid.UUID, linkField string, ids []uuid.UUID) []mongo.WriteModel {
+	now := time.Now()
+	writes := make([]mongo.WriteModel, 0, len(ids))
+	for _, id := range ids {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{ownerField: ownerID, linkField: id}).
+			SetUpdate(bson.M{"$setOnInsert": bson.M{"created_at": now}}).
+			SetUpsert(true))
+	}
+	return writes
+}
+
+// unorderedWrites runs every write even if an earlier one fails
+var unorderedWrites = options.BulkWrite().SetOrdered(false)
@@ -29,6 +29,11 @@ func NewMongoPermissionRepository(db *database.MongoDB, cache *cache.RedisClient
 	}
 }
 
+// rolePermissionsCollection returns the MongoDB collection linking roles to permissions
+func (r *MongoPermissionRepository) rolePermissionsCollection() *mongo.Collection {
+	return r.db.GetCollection("role_permissions")
+}
+
 // permissionsCollection returns the MongoDB collection for permissions
 func (r *MongoPermissionRepository) permissionsCollection() *mongo.Collection {
 	return r.db.GetCollection("permissions")
@@ -53,6 +58,13 @@ func (r *MongoPermissionRepository) Create(ctx context.Context, permission *mode
 	// Insert into database
 	_, err := r.permissionsCollection().InsertOne(ctx, permission)
 	if err != nil {
+		index, duplicate := database.DuplicateKeyIndex(err)
+		switch {
+		case duplicate && index == database.PermissionsResourceActionIndex:
+			return models.ErrPermissionExists
+		case duplicate && index == database.PermissionsNameIndex:
+			return models.ErrPermissionNameExists
+		}
 		return fmt.Errorf("failed to create permission in MongoDB: %w", err)
 	}
 
@@ -100,8 +112,15 @@ func (r *MongoPermissionRepository) GetByID(ctx context.Context, id uuid.UUID) (
 	return &permission, nil
 }
 
-// GetByResourceAction retrieves a permission by resource and action
+// GetByResourceAction retrieves a permission by resource and action, neither of which may be blank
 func (r *MongoPermissionRepository) GetByResourceAction(ctx context.Context, resource, action string) (*models.Permission, error) {
+	if err := models.CheckPermissionLookup("resource", resource); err != nil {
+		return nil, err
+	}
+	if err := models.CheckPermissionLookup("action", action); err != nil {
+		return nil, err
+	}
+
 	cacheKey := fmt.Sprintf("permission:resource:%s:action:%s", resource, action)
 
 	// Try to get from cache first
@@ -138,9 +157,21 @@ func (r *MongoPermissionRepository) GetByResourceAction(ctx context.Context, res
 	return &permission, nil
 }
 
-// GetAll retrieves all permissions
-func (r *MongoPermissionRepository) GetAll(ctx context.Context) ([]*models.Permission, error) {
-	cacheKey := "permissions:all"
+// ExistsByResourceAction reports whether a permission is defined for the resource and action
+func (r *MongoPermissionRepository) ExistsByResourceAction(ctx context.Context, resource, action string) (bool, error) {
+	filter := bson.M{"resource": resource, "action": action}
+
+	count, err := r.permissionsCollection().CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, fmt.Errorf("failed to check permission in MongoDB: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// GetAll retrieves all permissions ordered by sort, or by resource and action by default
+func (r *MongoPermissionRepository) GetAll(ctx context.Context, sort models.SortOptions) ([]*models.Permission, error) {
+	cacheKey := sortCacheKey("permissions:all", sort)
 
 	// Try to get from cache first
 	var permissions []*models.Permission
@@ -155,7 +186,7 @@ func (r *MongoPermissionRepository) GetAll(ctx context.Context) ([]*models.Permi
 
 	// If not in cache, get from database
 	findOptions := options.Find()
-	findOptions.SetSort(bson.D{{Key: "resource", Value: 1}, {Key: "action", Value: 1}})
+	findOptions.SetSort(sortDocument(sort, models.PermissionSortFields, bson.D{{Key: "resource", Value: 1}, {Key: "action", Value: 1}, {Key: "_id", Value: 1}}))
 
 	cursor, err := r.permissionsCollection().Find(ctx, bson.M{}, findOptions)
 	if err != nil {
@@ -192,6 +223,7 @@ func (r *MongoPermissionRepository) Update(ctx context.Context, permission *mode
 			"description": permission.Description,
 			"resource":    permission.Resource,
 			"action":      permission.Action,
+			"category":    permission.Category,
 			"updated_at":  permission.UpdatedAt,
 		},
 	}
@@ -230,8 +262,12 @@ func (r *MongoPermissionRepository) Delete(ctx context.Context, id uuid.UUID) er
 	return nil
 }
 
-// GetByResource retrieves all permissions for a specific resource
+// GetByResource retrieves all permissions for a specific resource, which may not be blank
 func (r *MongoPermissionRepository) GetByResource(ctx context.Context, resource string) ([]*models.Permission, error) {
+	if err := models.CheckPermissionLookup("resource", resource); err != nil {
+		return nil, err
+	}
+
 	cacheKey := fmt.Sprintf("permissions:resource:%s", resource)
 
 	// Try to get from cache first
@@ -247,7 +283,54 @@ func (r *MongoPermissionRepository) GetByResource(ctx context.Context, resource
 
 	// If not in cache, get from database
 	filter := bson.M{"resource": resource}
-	findOptions := options.Find().SetSort(bson.D{{Key: "action", Value: 1}})
+	findOptions := options.Find().SetSort(bson.D{{Key: "action", Value: 1}, {Key: "_id", Value: 1}})
+
+	cursor, err := r.permissionsCollection().Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permissions from MongoDB: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	permissions = make([]*models.Permission, 0)
+	for cursor.Next(ctx) {
+		var permission models.Permission
+		if err := cursor.Decode(&permission); err != nil {
+			return nil, fmt.Errorf("failed to decode permission from MongoDB: %w", err)
+		}
+
+		permissions = append(permissions, &permission)
+	}
+
+	// Cache the permissions
+	if err := r.cache.Set(cacheKey, permissions); err != nil {
+		log.Debug().Err(err).Msg("Failed to cache permissions")
+	}
+
+	return permissions, nil
+}
+
+// GetByAction retrieves the permissions for an action across every resource, which may not be blank
+func (r *MongoPermissionRepository) GetByAction(ctx context.Context, action string) ([]*models.Permission, error) {
+	if err := models.CheckPermissionLookup("action", action); err != nil {
+		return nil, err
+	}
+
+	cacheKey := fmt.Sprintf("permissions:action:%s", action)
+
+	// Try to get from cache first
+	var permissions []*models.Permission
+	found, err := r.cache.Get(cacheKey, &permissions)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get permissions from cache")
+	}
+
+	if found {
+		return permissions, nil
+	}
+
+	// If not in cache, get from database
+	filter := bson.M{"action": action}
+	findOptions := options.Find().SetSort(bson.D{{Key: "resource", Value: 1}, {Key: "_id", Value: 1}})
 
 	cursor, err := r.permissionsCollection().Find(ctx, filter, findOptions)
 	if err != nil {
@@ -273,6 +356,36 @@ func (r *MongoPermissionRepository) GetByResource(ctx context.Context, resource
 	return permissions, nil
 }
 
+// GetUsageCounts counts the roles holding each of the permissions in a single aggregation. Unused
+// permissions are absent from the result. Counts are not cached, as role changes would stale them.
+func (r *MongoPermissionRepository) GetUsageCounts(ctx context.Context, permissionIDs []uuid.UUID) (map[uuid.UUID]int, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"permission_id": bson.M{"$in": permissionIDs}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$permission_id", "role_count": bson.M{"$sum": 1}}}},
+	}
+
+	cursor, err := r.rolePermissionsCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count permission usage in MongoDB: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		PermissionID uuid.UUID `bson:"_id"`
+		RoleCount    int       `bson:"role_count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, fmt.Errorf("failed to decode permission usage from MongoDB: %w", err)
+	}
+
+	counts := make(map[uuid.UUID]int, len(rows))
+	for _, row := range rows {
+		counts[row.PermissionID] = row.RoleCount
+	}
+
+	return counts, nil
+}
+
 // invalidatePermissionCache clears all permission-related cache
 func (r *MongoPermissionRepository) invalidatePermissionCache() {
 	if err := r.cache.DeleteByPattern("permission:*"); err != nil {
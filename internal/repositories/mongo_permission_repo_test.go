@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chats/go-user-api/internal/database"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestMongoPermissionRepository_GetUsageCounts(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("counts roles in one aggregation", func(mt *mtest.T) {
+		redisClient, _ := newTestRedisClient(mt.T)
+		repo := NewMongoPermissionRepository(&database.MongoDB{Client: mt.Client, Database: mt.DB}, redisClient)
+		shared, single, unused := uuid.New(), uuid.New(), uuid.New()
+
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, mt.DB.Name()+".role_permissions", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: shared}, {Key: "role_count", Value: 3}},
+			bson.D{{Key: "_id", Value: single}, {Key: "role_count", Value: 1}},
+		))
+
+		counts, err := repo.GetUsageCounts(context.Background(), []uuid.UUID{shared, single, unused})
+
+		require.NoError(mt, err)
+		assert.Equal(mt, map[uuid.UUID]int{shared: 3, single: 1}, counts)
+		assert.Zero(mt, counts[unused])
+
+		events := mt.GetAllStartedEvents()
+		require.Len(mt, events, 1)
+		assert.Equal(mt, "aggregate", events[0].CommandName)
+		assert.Equal(mt, "role_permissions", events[0].Command.Lookup("aggregate").StringValue())
+	})
+}
+
+func TestMongoPermissionRepository_Create(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	tests := []struct {
+		index string
+		want  error
+	}{
+		{index: "resource_1_action_1", want: models.ErrPermissionExists},
+		{index: "name_1", want: models.ErrPermissionNameExists},
+	}
+
+	for _, tt := range tests {
+		mt.Run("translates a duplicate on "+tt.index, func(mt *mtest.T) {
+			redisClient, _ := newTestRedisClient(mt.T)
+			repo := NewMongoPermissionRepository(&database.MongoDB{Client: mt.Client, Database: mt.DB}, redisClient)
+			mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{
+				Code:    11000,
+				Message: "E11000 duplicate key error collection: " + mt.DB.Name() + ".permissions index: " + tt.index + " dup key: { : \"user\" }",
+			}))
+
+			err := repo.Create(context.Background(), &models.Permission{Name: "user:read", Resource: "user", Action: "read"})
+
+			assert.ErrorIs(mt, err, tt.want)
+		})
+	}
+}
+
+func TestMongoPermissionRepository_BlankLookup(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("rejected without a query", func(mt *mtest.T) {
+		redisClient, _ := newTestRedisClient(mt.T)
+		repo := NewMongoPermissionRepository(&database.MongoDB{Client: mt.Client, Database: mt.DB}, redisClient)
+
+		_, err := repo.GetByResource(context.Background(), " ")
+		assert.ErrorIs(mt, err, models.ErrBlankPermissionLookup)
+
+		_, err = repo.GetByResourceAction(context.Background(), "", "read")
+		assert.ErrorIs(mt, err, models.ErrBlankPermissionLookup)
+		assert.ErrorContains(mt, err, "resource is blank")
+
+		assert.Empty(mt, mt.GetAllStartedEvents())
+	})
+}
@@ -44,6 +44,11 @@ func (r *MongoRoleRepository) permissionsCollection() *mongo.Collection {
 	return r.db.GetCollection("permissions")
 }
 
+// userRolesCollection returns the MongoDB collection for user-roles relationship
+func (r *MongoRoleRepository) userRolesCollection() *mongo.Collection {
+	return r.db.GetCollection("user_roles")
+}
+
 // Create creates a new role in the database
 func (r *MongoRoleRepository) Create(ctx context.Context, role *models.Role) error {
 	// Generate UUID if not provided
@@ -63,6 +68,9 @@ func (r *MongoRoleRepository) Create(ctx context.Context, role *models.Role) err
 	// Insert into database
 	_, err := r.rolesCollection().InsertOne(ctx, role)
 	if err != nil {
+		if index, duplicate := database.DuplicateKeyIndex(err); duplicate && index == database.RolesNameIndex {
+			return models.ErrRoleNameExists
+		}
 		return fmt.Errorf("failed to create role in MongoDB: %w", err)
 	}
 
@@ -99,7 +107,7 @@ func (r *MongoRoleRepository) GetByID(ctx context.Context, id uuid.UUID) (*model
 	result := r.rolesCollection().FindOne(ctx, filter)
 	if result.Err() != nil {
 		if result.Err() == mongo.ErrNoDocuments {
-			return nil, fmt.Errorf("role not found")
+			return nil, models.ErrRoleNotFound
 		}
 		return nil, fmt.Errorf("failed to get role from MongoDB: %w", result.Err())
 	}
@@ -174,9 +182,9 @@ func (r *MongoRoleRepository) GetByName(ctx context.Context, name string) (*mode
 	return &role, nil
 }
 
-// GetAll retrieves all roles
-func (r *MongoRoleRepository) GetAll(ctx context.Context) ([]*models.Role, error) {
-	cacheKey := "roles:all"
+// GetAll retrieves all roles ordered by sort (name by default), loading their permissions in one batch when includePermissions is set
+func (r *MongoRoleRepository) GetAll(ctx context.Context, includePermissions bool, sort models.SortOptions) ([]*models.Role, error) {
+	cacheKey := sortCacheKey("roles:all", sort)
 
 	// Try to get from cache first
 	var roles []*models.Role
@@ -185,48 +193,48 @@ func (r *MongoRoleRepository) GetAll(ctx context.Context) ([]*models.Role, error
 		log.Debug().Err(err).Msg("Failed to get roles from cache")
 	}
 
-	if found {
-		// Get permissions for each role
-		for i, role := range roles {
-			permissions, err := r.GetRolePermissions(ctx, role.ID)
-			if err != nil {
-				return nil, err
-			}
-			roles[i].Permissions = permissions
+	if !found {
+		// If not in cache, get from database
+		findOptions := options.Find()
+		findOptions.SetSort(sortDocument(sort, models.RoleSortFields, bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}))
+
+		cursor, err := r.rolesCollection().Find(ctx, bson.M{}, findOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get roles from MongoDB: %w", err)
 		}
-		return roles, nil
-	}
+		defer cursor.Close(ctx)
 
-	// If not in cache, get from database
-	findOptions := options.Find()
-	findOptions.SetSort(bson.D{{Key: "name", Value: 1}})
+		roles = make([]*models.Role, 0)
+		for cursor.Next(ctx) {
+			var role models.Role
+			if err := cursor.Decode(&role); err != nil {
+				return nil, fmt.Errorf("failed to decode role from MongoDB: %w", err)
+			}
+			roles = append(roles, &role)
+		}
 
-	cursor, err := r.rolesCollection().Find(ctx, bson.M{}, findOptions)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get roles from MongoDB: %w", err)
+		// Cache the roles; permissions are always loaded fresh
+		if err := r.cache.Set(cacheKey, roles); err != nil {
+			log.Debug().Err(err).Msg("Failed to cache roles")
+		}
 	}
-	defer cursor.Close(ctx)
 
-	roles = make([]*models.Role, 0)
-	for cursor.Next(ctx) {
-		var role models.Role
-		if err := cursor.Decode(&role); err != nil {
-			return nil, fmt.Errorf("failed to decode role from MongoDB: %w", err)
-		}
+	if !includePermissions || len(roles) == 0 {
+		return roles, nil
+	}
 
-		// Get permissions for the role
-		permissions, err := r.GetRolePermissions(ctx, role.ID)
-		if err != nil {
-			return nil, err
-		}
-		role.Permissions = permissions
+	roleIDs := make([]uuid.UUID, len(roles))
+	for i, role := range roles {
+		roleIDs[i] = role.ID
+	}
 
-		roles = append(roles, &role)
+	permissionsByRole, err := r.GetPermissionsByRoleIDs(ctx, roleIDs)
+	if err != nil {
+		return nil, err
 	}
 
-	// Cache the roles
-	if err := r.cache.Set(cacheKey, roles); err != nil {
-		log.Debug().Err(err).Msg("Failed to cache roles")
+	for _, role := range roles {
+		role.Permissions = permissionsByRole[role.ID]
 	}
 
 	return roles, nil
@@ -256,6 +264,8 @@ func (r *MongoRoleRepository) Update(ctx context.Context, role *models.Role) err
 
 	// Clear cache
 	r.invalidateRoleCache()
+	// Cached users embed their roles
+	r.invalidateUserCache()
 
 	return nil
 }
@@ -281,6 +291,8 @@ func (r *MongoRoleRepository) Delete(ctx context.Context, id uuid.UUID) error {
 
 	// Clear cache
 	r.invalidateRoleCache()
+	// Cached users embed their roles
+	r.invalidateUserCache()
 
 	return nil
 }
@@ -302,18 +314,9 @@ func (r *MongoRoleRepository) AssignPermissionsToRole(ctx context.Context, roleI
 			return fmt.Errorf("failed to remove existing permissions: %w", err)
 		}
 
-		// Assign new permissions
+		// Assign new permissions; duplicates, including concurrent assignments, are skipped
 		if len(permissionIDs) > 0 {
-			rolePermissions := make([]interface{}, 0, len(permissionIDs))
-			for _, permissionID := range permissionIDs {
-				rolePermissions = append(rolePermissions, bson.M{
-					"role_id":       roleID,
-					"permission_id": permissionID,
-					"created_at":    time.Now(),
-				})
-			}
-
-			_, err = r.rolePermissionsCollection().InsertMany(sessionContext, rolePermissions)
+			_, err = r.rolePermissionsCollection().BulkWrite(sessionContext, linkUpserts("role_id", roleID, "permission_id", permissionIDs), unorderedWrites)
 			if err != nil {
 				return fmt.Errorf("failed to assign permissions: %w", err)
 			}
@@ -375,6 +378,96 @@ func (r *MongoRoleRepository) GetRolePermissions(ctx context.Context, roleID uui
 	return permissions, nil
 }
 
+// GetCounts counts the users holding the role, leaving out deleted users, and the permissions it
+// grants, each in a single server-side count. Counts are not cached, as assignments would stale them.
+func (r *MongoRoleRepository) GetCounts(ctx context.Context, roleID uuid.UUID) (int, int, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"role_id": roleID}}},
+		{{Key: "$lookup", Value: bson.M{"from": "users", "localField": "user_id", "foreignField": "_id", "as": "user"}}},
+		{{Key: "$match", Value: bson.M{"user": bson.M{"$elemMatch": bson.M{"deleted_at": nil}}}}},
+		{{Key: "$count", Value: "user_count"}},
+	}
+
+	cursor, err := r.userRolesCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count role users in MongoDB: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	// $count returns no document at all when no user holds the role
+	var rows []struct {
+		UserCount int `bson:"user_count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return 0, 0, fmt.Errorf("failed to decode role user count from MongoDB: %w", err)
+	}
+	userCount := 0
+	if len(rows) > 0 {
+		userCount = rows[0].UserCount
+	}
+
+	permissionCount, err := r.rolePermissionsCollection().CountDocuments(ctx, bson.M{"role_id": roleID})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count role permissions in MongoDB: %w", err)
+	}
+
+	return userCount, int(permissionCount), nil
+}
+
+// GetPermissionsByRoleIDs retrieves the permissions of several roles with one lookup per collection
+func (r *MongoRoleRepository) GetPermissionsByRoleIDs(ctx context.Context, roleIDs []uuid.UUID) (map[uuid.UUID][]models.Permission, error) {
+	cursor, err := r.rolePermissionsCollection().Find(ctx, bson.M{"role_id": bson.M{"$in": roleIDs}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role permissions from MongoDB: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var rolePermissions []struct {
+		RoleID       uuid.UUID `bson:"role_id"`
+		PermissionID uuid.UUID `bson:"permission_id"`
+	}
+	if err := cursor.All(ctx, &rolePermissions); err != nil {
+		return nil, fmt.Errorf("failed to decode role permissions: %w", err)
+	}
+
+	permissionsByRole := make(map[uuid.UUID][]models.Permission, len(roleIDs))
+	if len(rolePermissions) == 0 {
+		return permissionsByRole, nil
+	}
+
+	permissionIDs := make([]uuid.UUID, 0, len(rolePermissions))
+	for _, rolePermission := range rolePermissions {
+		permissionIDs = append(permissionIDs, rolePermission.PermissionID)
+	}
+
+	permissionCursor, err := r.permissionsCollection().Find(ctx, bson.M{"_id": bson.M{"$in": permissionIDs}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permissions from MongoDB: %w", err)
+	}
+	defer permissionCursor.Close(ctx)
+
+	var permissions []models.Permission
+	if err := permissionCursor.All(ctx, &permissions); err != nil {
+		return nil, fmt.Errorf("failed to decode permissions: %w", err)
+	}
+
+	permissionsByID := make(map[uuid.UUID]models.Permission, len(permissions))
+	for _, permission := range permissions {
+		permissionsByID[permission.ID] = permission
+	}
+
+	for _, rolePermission := range rolePermissions {
+		permission, ok := permissionsByID[rolePermission.PermissionID]
+		if !ok {
+			log.Debug().Str("permission_id", rolePermission.PermissionID.String()).Msg("Permission not found")
+			continue
+		}
+		permissionsByRole[rolePermission.RoleID] = append(permissionsByRole[rolePermission.RoleID], permission)
+	}
+
+	return permissionsByRole, nil
+}
+
 // invalidateRoleCache clears all role-related cache
 func (r *MongoRoleRepository) invalidateRoleCache() {
 	if err := r.cache.DeleteByPattern("role:*"); err != nil {
@@ -391,4 +484,27 @@ func (r *MongoRoleRepository) invalidateUserPermissionCache() {
 	if err := r.cache.DeleteByPattern("user:permissions:*"); err != nil {
 		log.Debug().Err(err).Msg("Failed to invalidate user permission cache")
 	}
+
+	// Permissions resolved from role sets that may include the changed role
+	if err := r.cache.DeleteByPattern(roleSetPermissionsPrefix + "*"); err != nil {
+		log.Debug().Err(err).Msg("Failed to invalidate role set permission cache")
+	}
+}
+
+// InvalidatePermissionCache clears cached roles and resolved permissions after role permissions
+// changed outside the repository, e.g. in a transaction
+func (r *MongoRoleRepository) InvalidatePermissionCache() {
+	r.invalidateRoleCache()
+	r.invalidateUserPermissionCache()
+}
+
+// invalidateUserCache clears cached users, which embed their roles
+func (r *MongoRoleRepository) invalidateUserCache() {
+	if err := r.cache.DeleteByPattern("user:*"); err != nil {
+		log.Debug().Err(err).Msg("Failed to invalidate user cache")
+	}
+
+	if err := r.cache.DeleteByPattern("users:*"); err != nil {
+		log.Debug().Err(err).Msg("Failed to invalidate users cache")
+	}
 }
@@ -0,0 +1,140 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/chats/go-user-api/internal/database"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// mockRoleListResponses queues the responses for listing count roles, each granted one read permission
+func mockRoleListResponses(mt *mtest.T, count int, includePermissions bool) {
+	roles := make([]bson.D, count)
+	rolePermissions := make([]bson.D, count)
+	permissions := make([]bson.D, count)
+	for i := 0; i < count; i++ {
+		roleID, permissionID := uuid.New(), uuid.New()
+		roles[i] = bson.D{{Key: "_id", Value: roleID}, {Key: "name", Value: "role-" + roleID.String()}}
+		rolePermissions[i] = bson.D{{Key: "role_id", Value: roleID}, {Key: "permission_id", Value: permissionID}}
+		permissions[i] = bson.D{{Key: "_id", Value: permissionID}, {Key: "name", Value: "user:read"}, {Key: "resource", Value: "user"}, {Key: "action", Value: "read"}}
+	}
+
+	ns := mt.DB.Name()
+	mt.AddMockResponses(mtest.CreateCursorResponse(0, ns+".roles", mtest.FirstBatch, roles...))
+	if includePermissions {
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, ns+".role_permissions", mtest.FirstBatch, rolePermissions...),
+			mtest.CreateCursorResponse(0, ns+".permissions", mtest.FirstBatch, permissions...),
+		)
+	}
+}
+
+func TestMongoRoleRepository_GetAll(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	newRepo := func(mt *mtest.T) *MongoRoleRepository {
+		redisClient, _ := newTestRedisClient(mt.T)
+		return NewMongoRoleRepository(&database.MongoDB{Client: mt.Client, Database: mt.DB}, redisClient)
+	}
+
+	for _, count := range []int{1, 5, 20} {
+		mt.Run(fmt.Sprintf("batches permission loading for %d roles", count), func(mt *mtest.T) {
+			repo := newRepo(mt)
+			mockRoleListResponses(mt, count, true)
+
+			roles, err := repo.GetAll(context.Background(), true, models.SortOptions{})
+			require.NoError(mt, err)
+			require.Len(mt, roles, count)
+			for _, role := range roles {
+				require.Len(mt, role.Permissions, 1)
+				assert.Equal(mt, "user:read", role.Permissions[0].Name)
+			}
+
+			// roles, role_permissions and permissions, however many roles there are
+			assert.Len(mt, mt.GetAllStartedEvents(), 3)
+		})
+	}
+
+	mt.Run("excludes permissions", func(mt *mtest.T) {
+		repo := newRepo(mt)
+		mockRoleListResponses(mt, 3, false)
+
+		roles, err := repo.GetAll(context.Background(), false, models.SortOptions{})
+		require.NoError(mt, err)
+		require.Len(mt, roles, 3)
+		for _, role := range roles {
+			assert.Nil(mt, role.Permissions)
+		}
+
+		assert.Len(mt, mt.GetAllStartedEvents(), 1)
+	})
+}
+
+func TestMongoRoleRepository_Create(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("translates a duplicate name", func(mt *mtest.T) {
+		redisClient, _ := newTestRedisClient(mt.T)
+		repo := NewMongoRoleRepository(&database.MongoDB{Client: mt.Client, Database: mt.DB}, redisClient)
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{
+			Code:    11000,
+			Message: "E11000 duplicate key error collection: " + mt.DB.Name() + ".roles index: name_1 dup key: { : \"editor\" }",
+		}))
+
+		err := repo.Create(context.Background(), &models.Role{Name: "editor"})
+
+		assert.ErrorIs(mt, err, models.ErrRoleNameExists)
+	})
+}
+
+func TestMongoRoleRepository_GetCounts(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	newRepo := func(mt *mtest.T) *MongoRoleRepository {
+		redisClient, _ := newTestRedisClient(mt.T)
+		return NewMongoRoleRepository(&database.MongoDB{Client: mt.Client, Database: mt.DB}, redisClient)
+	}
+
+	mt.Run("counts users and permissions server-side", func(mt *mtest.T) {
+		ns := mt.DB.Name()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, ns+".user_roles", mtest.FirstBatch, bson.D{{Key: "user_count", Value: 3}}),
+			mtest.CreateCursorResponse(0, ns+".role_permissions", mtest.FirstBatch, bson.D{{Key: "n", Value: 4}}),
+		)
+
+		userCount, permissionCount, err := newRepo(mt).GetCounts(context.Background(), uuid.New())
+
+		require.NoError(mt, err)
+		assert.Equal(mt, 3, userCount)
+		assert.Equal(mt, 4, permissionCount)
+
+		// Both are aggregations; no user or permission document is fetched
+		events := mt.GetAllStartedEvents()
+		require.Len(mt, events, 2)
+		assert.Equal(mt, "aggregate", events[0].CommandName)
+		assert.Equal(mt, "user_roles", events[0].Command.Lookup("aggregate").StringValue())
+		assert.Equal(mt, "aggregate", events[1].CommandName)
+		assert.Equal(mt, "role_permissions", events[1].Command.Lookup("aggregate").StringValue())
+	})
+
+	mt.Run("reports zeros for an empty role", func(mt *mtest.T) {
+		ns := mt.DB.Name()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, ns+".user_roles", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, ns+".role_permissions", mtest.FirstBatch),
+		)
+
+		userCount, permissionCount, err := newRepo(mt).GetCounts(context.Background(), uuid.New())
+
+		require.NoError(mt, err)
+		assert.Zero(mt, userCount)
+		assert.Zero(mt, permissionCount)
+	})
+}
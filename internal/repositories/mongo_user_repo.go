@@ -2,7 +2,9 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/chats/go-user-api/internal/cache"
@@ -11,6 +13,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -34,6 +37,11 @@ func (r *MongoUserRepository) usersCollection() *mongo.Collection {
 	return r.db.GetCollection("users")
 }
 
+// criticalUsersCollection returns the users collection with the critical write concern, for creating users
+func (r *MongoUserRepository) criticalUsersCollection() *mongo.Collection {
+	return r.db.GetCriticalCollection("users")
+}
+
 // userRolesCollection returns the MongoDB collection for user-roles relationship
 func (r *MongoUserRepository) userRolesCollection() *mongo.Collection {
 	return r.db.GetCollection("user_roles")
@@ -57,7 +65,8 @@ func (r *MongoUserRepository) rolePermissionsCollection() *mongo.Collection {
 // Create creates a new user in the database
 func (r *MongoUserRepository) Create(ctx context.Context, user *models.User) error {
 	// Generate UUID if not provided
-	if user.ID == uuid.Nil {
+	generatedID := user.ID == uuid.Nil
+	if generatedID {
 		user.ID = uuid.New()
 	}
 
@@ -70,9 +79,11 @@ func (r *MongoUserRepository) Create(ctx context.Context, user *models.User) err
 		user.UpdatedAt = now
 	}
 
-	// Insert into database
-	_, err := r.usersCollection().InsertOne(ctx, user)
-	if err != nil {
+	// Insert into database, drawing a new ID if a generated one collides
+	if err := database.InsertUser(ctx, r.criticalUsersCollection(), user, generatedID); err != nil {
+		if errors.Is(err, models.ErrUsernameExists) || errors.Is(err, models.ErrEmailExists) {
+			return err
+		}
 		return fmt.Errorf("failed to create user in MongoDB: %w", err)
 	}
 
@@ -93,13 +104,8 @@ func (r *MongoUserRepository) GetByID(ctx context.Context, id uuid.UUID) (*model
 		log.Debug().Err(err).Msg("Failed to get user from cache")
 	}
 
+	// Cached users already include their roles
 	if found {
-		// Get roles for the user
-		roles, err := r.GetUserRoles(ctx, id)
-		if err != nil {
-			return nil, err
-		}
-		user.Roles = roles
 		return &user, nil
 	}
 
@@ -109,7 +115,7 @@ func (r *MongoUserRepository) GetByID(ctx context.Context, id uuid.UUID) (*model
 	result := r.usersCollection().FindOne(ctx, filter)
 	if result.Err() != nil {
 		if result.Err() == mongo.ErrNoDocuments {
-			return nil, fmt.Errorf("user not found")
+			return nil, models.ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to get user from MongoDB: %w", result.Err())
 	}
@@ -133,6 +139,36 @@ func (r *MongoUserRepository) GetByID(ctx context.Context, id uuid.UUID) (*model
 	return &user, nil
 }
 
+// GetByIDs retrieves several users with their roles; unknown IDs are skipped
+func (r *MongoUserRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*models.User, error) {
+	return getUsersByIDs(r.cache, ids, func(missing []uuid.UUID) ([]*models.User, error) {
+		cursor, err := r.usersCollection().Find(ctx, bson.M{"_id": bson.M{"$in": missing}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get users from MongoDB: %w", err)
+		}
+		defer cursor.Close(ctx)
+
+		users := make([]*models.User, 0, len(missing))
+		for cursor.Next(ctx) {
+			var user models.User
+			if err := cursor.Decode(&user); err != nil {
+				return nil, fmt.Errorf("failed to decode user from MongoDB: %w", err)
+			}
+
+			// Get roles for the user
+			roles, err := r.GetUserRoles(ctx, user.ID)
+			if err != nil {
+				return nil, err
+			}
+			user.Roles = roles
+
+			users = append(users, &user)
+		}
+
+		return users, nil
+	})
+}
+
 // GetByUsername retrieves a user by username
 func (r *MongoUserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	cacheKey := fmt.Sprintf("user:username:%s", username)
@@ -155,12 +191,12 @@ func (r *MongoUserRepository) GetByUsername(ctx context.Context, username string
 	}
 
 	// If not in cache, get from database
-	filter := bson.M{"username": username}
+	filter := bson.M{"username": username, "deleted_at": nil}
 
 	result := r.usersCollection().FindOne(ctx, filter)
 	if result.Err() != nil {
 		if result.Err() == mongo.ErrNoDocuments {
-			return nil, fmt.Errorf("user not found")
+			return nil, models.ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to get user from MongoDB: %w", result.Err())
 	}
@@ -177,16 +213,14 @@ func (r *MongoUserRepository) GetByUsername(ctx context.Context, username string
 	user.Roles = roles
 
 	// Cache the user
-	if err := r.cache.Set(cacheKey, user); err != nil {
-		log.Debug().Err(err).Msg("Failed to cache user")
-	}
+	cacheUserByUsername(r.cache, user)
 
 	return &user, nil
 }
 
-// GetAll retrieves all users with pagination
-func (r *MongoUserRepository) GetAll(ctx context.Context, limit, offset int) ([]*models.User, error) {
-	cacheKey := fmt.Sprintf("users:limit:%d:offset:%d", limit, offset)
+// GetAll retrieves all users with pagination, ordered by sort or newest first by default
+func (r *MongoUserRepository) GetAll(ctx context.Context, limit, offset int, sort models.SortOptions) ([]*models.User, error) {
+	cacheKey := sortCacheKey(fmt.Sprintf("users:limit:%d:offset:%d", limit, offset), sort)
 
 	// Try to get from cache first
 	var users []*models.User
@@ -195,15 +229,8 @@ func (r *MongoUserRepository) GetAll(ctx context.Context, limit, offset int) ([]
 		log.Debug().Err(err).Msg("Failed to get users from cache")
 	}
 
+	// Cached users already include their roles
 	if found {
-		// Get roles for each user
-		for i, user := range users {
-			roles, err := r.GetUserRoles(ctx, user.ID)
-			if err != nil {
-				return nil, err
-			}
-			users[i].Roles = roles
-		}
 		return users, nil
 	}
 
@@ -211,31 +238,43 @@ func (r *MongoUserRepository) GetAll(ctx context.Context, limit, offset int) ([]
 	findOptions := options.Find()
 	findOptions.SetLimit(int64(limit))
 	findOptions.SetSkip(int64(offset))
-	findOptions.SetSort(bson.D{{Key: "created_at", Value: -1}})
+	findOptions.SetSort(sortDocument(sort, models.UserSortFields, bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}))
 
-	cursor, err := r.usersCollection().Find(ctx, bson.M{}, findOptions)
+	cursor, err := r.usersCollection().Find(ctx, bson.M{"deleted_at": nil}, findOptions)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get users from MongoDB: %w", err)
 	}
 	defer cursor.Close(ctx)
 
 	users = make([]*models.User, 0)
+	warnings := make([]models.Warning, 0)
 	for cursor.Next(ctx) {
 		var user models.User
 		if err := cursor.Decode(&user); err != nil {
 			return nil, fmt.Errorf("failed to decode user from MongoDB: %w", err)
 		}
 
-		// Get roles for the user
-		roles, err := r.GetUserRoles(ctx, user.ID)
+		// Get roles for the user; a failed lookup or a role that no longer exists is reported
+		roles, missing, err := r.userRoles(ctx, user.ID)
 		if err != nil {
-			return nil, err
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			warnings = append(warnings, models.Warning{ID: user.ID.String(), Message: err.Error()})
+		}
+		for _, roleID := range missing {
+			warnings = append(warnings, models.Warning{ID: user.ID.String(), Message: fmt.Sprintf("assigned role %s not found", roleID)})
 		}
 		user.Roles = roles
 
 		users = append(users, &user)
 	}
 
+	// A partial page is not cached, so the next request retries it
+	if len(warnings) > 0 {
+		return users, &models.PartialResultError{Warnings: warnings}
+	}
+
 	// Cache the users
 	if err := r.cache.Set(cacheKey, users); err != nil {
 		log.Debug().Err(err).Msg("Failed to cache users")
@@ -277,11 +316,13 @@ func (r *MongoUserRepository) Update(ctx context.Context, user *models.User) err
 
 // UpdatePassword updates a user's password
 func (r *MongoUserRepository) UpdatePassword(ctx context.Context, userID uuid.UUID, hashedPassword string) error {
+	now := time.Now()
 	filter := bson.M{"_id": userID}
 	update := bson.M{
 		"$set": bson.M{
-			"password":   hashedPassword,
-			"updated_at": time.Now(),
+			"password":            hashedPassword,
+			"updated_at":          now,
+			"password_changed_at": now,
 		},
 	}
 
@@ -300,27 +341,72 @@ func (r *MongoUserRepository) UpdatePassword(ctx context.Context, userID uuid.UU
 	return nil
 }
 
-// Delete deletes a user from the database
-func (r *MongoUserRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	filter := bson.M{"_id": id}
+// RehashPassword replaces the stored hash of an unchanged password, e.g. after a pepper rotation,
+// so the password keeps its age. It only writes while the stored hash is still verifiedHash, so a
+// password changed since it was verified is kept; false reports that nothing was replaced.
+func (r *MongoUserRepository) RehashPassword(ctx context.Context, userID uuid.UUID, verifiedHash, hashedPassword string) (bool, error) {
+	filter := bson.M{"_id": userID, "password": verifiedHash}
+	update := bson.M{"$set": bson.M{"password": hashedPassword}}
 
-	result, err := r.usersCollection().DeleteOne(ctx, filter)
+	result, err := r.usersCollection().UpdateOne(ctx, filter, update)
 	if err != nil {
-		return fmt.Errorf("failed to delete user from MongoDB: %w", err)
+		return false, fmt.Errorf("failed to rehash password in MongoDB: %w", err)
 	}
 
-	if result.DeletedCount == 0 {
-		return fmt.Errorf("user not found")
+	if result.MatchedCount == 0 {
+		return false, nil
 	}
 
-	// Also delete user roles relationships
-	_, err = r.userRolesCollection().DeleteMany(ctx, bson.M{"user_id": id})
+	// Clear cache
+	r.invalidateUserCache()
+
+	return true, nil
+}
+
+// userOwnedCollections maps collections holding rows that belong to a user to their user reference field
+var userOwnedCollections = map[string]string{
+	"user_roles": "user_id",
+	"api_keys":   "created_by",
+}
+
+// Delete deletes a user and every document owned by the user in a single transaction
+func (r *MongoUserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	// Execute transaction
+	deleted := false
+	err := r.db.WithTransaction(ctx, func(sessionContext mongo.SessionContext) error {
+		result, err := r.usersCollection().DeleteOne(sessionContext, bson.M{"_id": id})
+		if err != nil {
+			return fmt.Errorf("failed to delete user from MongoDB: %w", err)
+		}
+
+		if result.DeletedCount == 0 {
+			return nil
+		}
+
+		// Remove documents owned by the user
+		for collection, field := range userOwnedCollections {
+			if _, err := r.db.GetCollection(collection).DeleteMany(sessionContext, bson.M{field: id}); err != nil {
+				return fmt.Errorf("failed to delete user-owned documents from %s: %w", collection, err)
+			}
+		}
+
+		deleted = true
+		return nil
+	})
+
 	if err != nil {
-		log.Debug().Err(err).Msg("Failed to delete user roles relationships")
+		return fmt.Errorf("failed to delete user transaction: %w", err)
 	}
 
-	// Clear cache
+	if !deleted {
+		return fmt.Errorf("user not found")
+	}
+
+	// Clear user cache, and cached API keys the user created
 	r.invalidateUserCache()
+	if err := r.cache.DeleteByPattern("apikey:*"); err != nil {
+		log.Debug().Err(err).Msg("Failed to invalidate API key cache")
+	}
 
 	return nil
 }
@@ -336,29 +422,37 @@ func (r *MongoUserRepository) AssignRolesToUser(ctx context.Context, userID uuid
 
 	// Execute transaction
 	err = mongo.WithSession(ctx, session, func(sessionContext mongo.SessionContext) error {
+		// MongoDB has no foreign keys, so check every role exists in one lookup
+		if len(roleIDs) > 0 {
+			existing, err := r.existingRoleIDs(sessionContext, roleIDs)
+			if err != nil {
+				return fmt.Errorf("failed to look up roles: %w", err)
+			}
+			if err := models.MissingRolesError(roleIDs, existing); err != nil {
+				return err
+			}
+		}
+
 		// Remove existing roles
 		_, err := r.userRolesCollection().DeleteMany(sessionContext, bson.M{"user_id": userID})
 		if err != nil {
 			return fmt.Errorf("failed to remove existing roles: %w", err)
 		}
 
-		// Assign new roles
+		// Assign new roles; duplicates, including concurrent assignments, are skipped
 		if len(roleIDs) > 0 {
-			userRoles := make([]interface{}, 0, len(roleIDs))
-			for _, roleID := range roleIDs {
-				userRoles = append(userRoles, bson.M{
-					"user_id":    userID,
-					"role_id":    roleID,
-					"created_at": time.Now(),
-				})
-			}
-
-			_, err = r.userRolesCollection().InsertMany(sessionContext, userRoles)
+			_, err = r.userRolesCollection().BulkWrite(sessionContext, linkUpserts("user_id", userID, "role_id", roleIDs), unorderedWrites)
 			if err != nil {
 				return fmt.Errorf("failed to assign roles: %w", err)
 			}
 		}
 
+		// Record the change so tokens carrying the old roles can be rejected
+		_, err = r.usersCollection().UpdateOne(sessionContext, bson.M{"_id": userID}, bson.M{"$set": bson.M{"roles_changed_at": time.Now()}})
+		if err != nil {
+			return fmt.Errorf("failed to record role change: %w", err)
+		}
+
 		return nil
 	})
 
@@ -366,18 +460,24 @@ func (r *MongoUserRepository) AssignRolesToUser(ctx context.Context, userID uuid
 		return fmt.Errorf("failed to assign roles transaction: %w", err)
 	}
 
-	// Clear cache
-	r.invalidateUserCache()
+	// Clear the cached user, whose roles are part of the cached entry
+	r.InvalidateUser(userID)
 
 	return nil
 }
 
 // GetUserRoles retrieves all roles for a user
 func (r *MongoUserRepository) GetUserRoles(ctx context.Context, userID uuid.UUID) ([]models.Role, error) {
+	roles, _, err := r.userRoles(ctx, userID)
+	return roles, err
+}
+
+// userRoles retrieves the roles assigned to a user, and the IDs of assigned roles that no longer exist
+func (r *MongoUserRepository) userRoles(ctx context.Context, userID uuid.UUID) ([]models.Role, []uuid.UUID, error) {
 	// Get role IDs assigned to the user
 	cursor, err := r.userRolesCollection().Find(ctx, bson.M{"user_id": userID})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user roles from MongoDB: %w", err)
+		return nil, nil, fmt.Errorf("failed to get user roles from MongoDB: %w", err)
 	}
 	defer cursor.Close(ctx)
 
@@ -387,13 +487,14 @@ func (r *MongoUserRepository) GetUserRoles(ctx context.Context, userID uuid.UUID
 			RoleID uuid.UUID `bson:"role_id"`
 		}
 		if err := cursor.Decode(&userRole); err != nil {
-			return nil, fmt.Errorf("failed to decode user role: %w", err)
+			return nil, nil, fmt.Errorf("failed to decode user role: %w", err)
 		}
 		roleIDs = append(roleIDs, userRole.RoleID)
 	}
 
 	// Get role details for each role ID
 	roles := make([]models.Role, 0, len(roleIDs))
+	missing := make([]uuid.UUID, 0)
 	for _, roleID := range roleIDs {
 		filter := bson.M{"_id": roleID}
 		var role models.Role
@@ -402,15 +503,16 @@ func (r *MongoUserRepository) GetUserRoles(ctx context.Context, userID uuid.UUID
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
 				log.Debug().Str("role_id", roleID.String()).Msg("Role not found")
+				missing = append(missing, roleID)
 				continue
 			}
-			return nil, fmt.Errorf("failed to get role from MongoDB: %w", err)
+			return nil, nil, fmt.Errorf("failed to get role from MongoDB: %w", err)
 		}
 
 		roles = append(roles, role)
 	}
 
-	return roles, nil
+	return roles, missing, nil
 }
 
 // GetUserPermissions retrieves all permissions for a user
@@ -433,6 +535,18 @@ func (r *MongoUserRepository) GetUserPermissions(ctx context.Context, userID uui
 		roleIDs = append(roleIDs, userRole.RoleID)
 	}
 
+	// Users holding the same roles share one cached resolution
+	cacheKey := roleSetCacheKey(roleIDs)
+	var cached []models.Permission
+	found, err := r.cache.Get(cacheKey, &cached)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get role set permissions from cache")
+	}
+
+	if found {
+		return cached, nil
+	}
+
 	// Now, get all permission IDs assigned to these roles
 	permissionMap := make(map[uuid.UUID]bool)
 	for _, roleID := range roleIDs {
@@ -477,57 +591,50 @@ func (r *MongoUserRepository) GetUserPermissions(ctx context.Context, userID uui
 		permissions = append(permissions, permission)
 	}
 
+	// Cache the permissions
+	if err := r.cache.Set(cacheKey, permissions); err != nil {
+		log.Debug().Err(err).Msg("Failed to cache role set permissions")
+	}
+
 	return permissions, nil
 }
 
-// HasPermission checks if a user has a specific permission
+// HasPermission checks if a user has a specific permission.
+// The user's roles, their grants and the matching permission are resolved in a single aggregation.
 func (r *MongoUserRepository) HasPermission(ctx context.Context, userID uuid.UUID, resource, action string) (bool, error) {
-	// Get all role IDs assigned to the user
-	userRolesCursor, err := r.userRolesCollection().Find(ctx, bson.M{"user_id": userID})
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userID}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "role_permissions",
+			"localField":   "role_id",
+			"foreignField": "role_id",
+			"as":           "grants",
+		}}},
+		{{Key: "$unwind", Value: "$grants"}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "permissions",
+			"localField":   "grants.permission_id",
+			"foreignField": "_id",
+			"as":           "permission",
+		}}},
+		{{Key: "$match", Value: bson.M{"permission": bson.M{"$elemMatch": bson.M{"resource": resource, "action": action}}}}},
+		{{Key: "$limit", Value: 1}},
+		{{Key: "$project", Value: bson.M{"_id": 1}}},
+	}
+
+	cursor, err := r.userRolesCollection().Aggregate(ctx, pipeline)
 	if err != nil {
-		return false, fmt.Errorf("failed to get user roles from MongoDB: %w", err)
-	}
-	defer userRolesCursor.Close(ctx)
-
-	roleIDs := make([]uuid.UUID, 0)
-	for userRolesCursor.Next(ctx) {
-		var userRole struct {
-			RoleID uuid.UUID `bson:"role_id"`
-		}
-		if err := userRolesCursor.Decode(&userRole); err != nil {
-			return false, fmt.Errorf("failed to decode user role: %w", err)
-		}
-		roleIDs = append(roleIDs, userRole.RoleID)
+		return false, fmt.Errorf("failed to check user permission in MongoDB: %w", err)
 	}
+	defer cursor.Close(ctx)
 
-	if len(roleIDs) == 0 {
-		return false, nil
-	}
-
-	// First, find the permission with the specified resource and action
-	filter := bson.M{"resource": resource, "action": action}
-	var permission models.Permission
-
-	err = r.permissionsCollection().FindOne(ctx, filter).Decode(&permission)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			// Permission does not exist
-			return false, nil
-		}
-		return false, fmt.Errorf("failed to get permission from MongoDB: %w", err)
+	// Any document means one of the user's roles grants the permission
+	if cursor.Next(ctx) {
+		return true, nil
 	}
 
-	// Now check if any of the user's roles has this permission
-	for _, roleID := range roleIDs {
-		filter = bson.M{"role_id": roleID, "permission_id": permission.ID}
-		count, err := r.rolePermissionsCollection().CountDocuments(ctx, filter)
-		if err != nil {
-			return false, fmt.Errorf("failed to check role permission: %w", err)
-		}
-
-		if count > 0 {
-			return true, nil
-		}
+	if err := cursor.Err(); err != nil {
+		return false, fmt.Errorf("failed to check user permission in MongoDB: %w", err)
 	}
 
 	return false, nil
@@ -549,7 +656,7 @@ func (r *MongoUserRepository) CountUsers(ctx context.Context) (int, error) {
 	}
 
 	// If not in cache, get from database
-	count64, err := r.usersCollection().CountDocuments(ctx, bson.M{})
+	count64, err := r.usersCollection().CountDocuments(ctx, bson.M{"deleted_at": nil})
 	if err != nil {
 		return 0, fmt.Errorf("failed to count users in MongoDB: %w", err)
 	}
@@ -564,6 +671,272 @@ func (r *MongoUserRepository) CountUsers(ctx context.Context) (int, error) {
 	return count, nil
 }
 
+// UpdateLastLogin records the time of a user's most recent successful login
+func (r *MongoUserRepository) UpdateLastLogin(ctx context.Context, userID uuid.UUID, loginAt time.Time) error {
+	filter := bson.M{"_id": userID}
+	update := bson.M{
+		"$set": bson.M{
+			"last_login_at": loginAt,
+		},
+	}
+
+	if _, err := r.usersCollection().UpdateOne(ctx, filter, update); err != nil {
+		return fmt.Errorf("failed to update last login in MongoDB: %w", err)
+	}
+
+	// Clear the cached user
+	r.InvalidateUser(userID)
+
+	return nil
+}
+
+// GetUserIDsByFilter returns the IDs of users matching every criterion set on the filter, oldest first
+func (r *MongoUserRepository) GetUserIDsByFilter(ctx context.Context, filter models.UserFilter) ([]uuid.UUID, error) {
+	// Soft-deleted users never match
+	query := bson.M{"deleted_at": nil}
+
+	if filter.Query != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(filter.Query), Options: "i"}
+		query["$or"] = bson.A{
+			bson.M{"username": pattern},
+			bson.M{"email": pattern},
+			bson.M{"first_name": pattern},
+			bson.M{"last_name": pattern},
+		}
+	}
+	if filter.RoleName != "" {
+		var role struct {
+			ID uuid.UUID `bson:"_id"`
+		}
+		err := r.rolesCollection().FindOne(ctx, bson.M{"name": filter.RoleName}).Decode(&role)
+		if err == mongo.ErrNoDocuments {
+			return []uuid.UUID{}, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get role from MongoDB: %w", err)
+		}
+
+		memberIDs, err := r.userRolesCollection().Distinct(ctx, "user_id", bson.M{"role_id": role.ID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get role members from MongoDB: %w", err)
+		}
+		query["_id"] = bson.M{"$in": memberIDs}
+	}
+	if filter.IsActive != nil {
+		query["is_active"] = *filter.IsActive
+	}
+
+	createdAt := bson.M{}
+	if filter.CreatedBefore != nil {
+		createdAt["$lt"] = *filter.CreatedBefore
+	}
+	if filter.CreatedAfter != nil {
+		createdAt["$gt"] = *filter.CreatedAfter
+	}
+	if filter.CreatedFrom != nil {
+		createdAt["$gte"] = *filter.CreatedFrom
+	}
+	if filter.CreatedTo != nil {
+		createdAt["$lte"] = *filter.CreatedTo
+	}
+	if len(createdAt) > 0 {
+		query["created_at"] = createdAt
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetProjection(bson.M{"_id": 1})
+
+	cursor, err := r.usersCollection().Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users by filter from MongoDB: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	userIDs := make([]uuid.UUID, 0)
+	for cursor.Next(ctx) {
+		var user struct {
+			ID uuid.UUID `bson:"_id"`
+		}
+		if err := cursor.Decode(&user); err != nil {
+			return nil, fmt.Errorf("failed to decode user from MongoDB: %w", err)
+		}
+		userIDs = append(userIDs, user.ID)
+	}
+
+	return userIDs, nil
+}
+
+// existingRoleIDs returns which of the given role IDs exist
+func (r *MongoUserRepository) existingRoleIDs(ctx context.Context, roleIDs []uuid.UUID) ([]uuid.UUID, error) {
+	cursor, err := r.rolesCollection().Find(ctx, bson.M{"_id": bson.M{"$in": roleIDs}}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	var roles []struct {
+		ID uuid.UUID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &roles); err != nil {
+		return nil, err
+	}
+
+	existing := make([]uuid.UUID, len(roles))
+	for i, role := range roles {
+		existing[i] = role.ID
+	}
+	return existing, nil
+}
+
+// GetRolesChangedAt returns when the user's roles last changed, or the zero time if they never did
+func (r *MongoUserRepository) GetRolesChangedAt(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	cacheKey := fmt.Sprintf("user:%s:roles_changed_at", userID.String())
+
+	// Try to get from cache first
+	var changedAt time.Time
+	found, err := r.cache.Get(cacheKey, &changedAt)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get roles changed at from cache")
+	}
+
+	if found {
+		return changedAt, nil
+	}
+
+	// If not in cache, get from database
+	findOptions := options.FindOne().SetProjection(bson.M{"roles_changed_at": 1})
+
+	var result struct {
+		RolesChangedAt *time.Time `bson:"roles_changed_at"`
+	}
+	if err := r.usersCollection().FindOne(ctx, bson.M{"_id": userID}, findOptions).Decode(&result); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return time.Time{}, fmt.Errorf("user not found")
+		}
+		return time.Time{}, fmt.Errorf("failed to get roles changed at from MongoDB: %w", err)
+	}
+
+	if result.RolesChangedAt != nil {
+		changedAt = *result.RolesChangedAt
+	}
+
+	// Cache the timestamp
+	if err := r.cache.Set(cacheKey, changedAt); err != nil {
+		log.Debug().Err(err).Msg("Failed to cache roles changed at")
+	}
+
+	return changedAt, nil
+}
+
+// GetTokensRevokedAt returns when the user's tokens were last revoked, or the zero time if they never were
+func (r *MongoUserRepository) GetTokensRevokedAt(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	cacheKey := fmt.Sprintf("user:%s:tokens_revoked_at", userID.String())
+
+	// Try to get from cache first
+	var revokedAt time.Time
+	found, err := r.cache.Get(cacheKey, &revokedAt)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get tokens revoked at from cache")
+	}
+
+	if found {
+		return revokedAt, nil
+	}
+
+	// If not in cache, get from database
+	findOptions := options.FindOne().SetProjection(bson.M{"tokens_revoked_at": 1})
+
+	var result struct {
+		TokensRevokedAt *time.Time `bson:"tokens_revoked_at"`
+	}
+	if err := r.usersCollection().FindOne(ctx, bson.M{"_id": userID}, findOptions).Decode(&result); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return time.Time{}, fmt.Errorf("user not found")
+		}
+		return time.Time{}, fmt.Errorf("failed to get tokens revoked at from MongoDB: %w", err)
+	}
+
+	if result.TokensRevokedAt != nil {
+		revokedAt = *result.TokensRevokedAt
+	}
+
+	// Cache the timestamp
+	if err := r.cache.Set(cacheKey, revokedAt); err != nil {
+		log.Debug().Err(err).Msg("Failed to cache tokens revoked at")
+	}
+
+	return revokedAt, nil
+}
+
+// GetInactiveUsers retrieves active users whose last login (or creation, if they never logged in) is before the cutoff
+func (r *MongoUserRepository) GetInactiveUsers(ctx context.Context, cutoff time.Time) ([]*models.User, error) {
+	filter := bson.M{
+		"is_active": true,
+		"$or": bson.A{
+			bson.M{"last_login_at": bson.M{"$lt": cutoff}},
+			bson.M{"last_login_at": nil, "created_at": bson.M{"$lt": cutoff}},
+		},
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+
+	cursor, err := r.usersCollection().Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inactive users from MongoDB: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	users := make([]*models.User, 0)
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			return nil, fmt.Errorf("failed to decode user from MongoDB: %w", err)
+		}
+
+		// Get roles for the user
+		roles, err := r.GetUserRoles(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		user.Roles = roles
+
+		users = append(users, &user)
+	}
+
+	return users, nil
+}
+
+// GetRecentlyActiveUserIDs retrieves the IDs of the active users who logged in most recently
+func (r *MongoUserRepository) GetRecentlyActiveUserIDs(ctx context.Context, limit int) ([]uuid.UUID, error) {
+	filter := bson.M{
+		"is_active":     true,
+		"last_login_at": bson.M{"$ne": nil},
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "last_login_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{"_id": 1})
+
+	cursor, err := r.usersCollection().Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recently active users from MongoDB: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	userIDs := make([]uuid.UUID, 0)
+	for cursor.Next(ctx) {
+		var user struct {
+			ID uuid.UUID `bson:"_id"`
+		}
+		if err := cursor.Decode(&user); err != nil {
+			return nil, fmt.Errorf("failed to decode user from MongoDB: %w", err)
+		}
+		userIDs = append(userIDs, user.ID)
+	}
+
+	return userIDs, nil
+}
+
 // invalidateUserCache clears all user-related cache
 func (r *MongoUserRepository) invalidateUserCache() {
 	if err := r.cache.DeleteByPattern("user:*"); err != nil {
@@ -574,3 +947,21 @@ func (r *MongoUserRepository) invalidateUserCache() {
 		log.Debug().Err(err).Msg("Failed to invalidate users cache")
 	}
 }
+
+// InvalidateUser clears the cached entries for a single user, including its username entry, along
+// with cached user lists
+func (r *MongoUserRepository) InvalidateUser(userID uuid.UUID) {
+	if _, err := r.cache.Purge(userKeysFor(r.cache, userID), []string{"users:*"}); err != nil {
+		log.Debug().Err(err).Msg("Failed to invalidate user cache")
+	}
+}
+
+// InvalidateDeletedUser drops cached copies of a user deleted in a transaction, along with cached
+// API keys the user created
+func (r *MongoUserRepository) InvalidateDeletedUser(userID uuid.UUID) {
+	r.InvalidateUser(userID)
+
+	if err := r.cache.DeleteByPattern("apikey:*"); err != nil {
+		log.Debug().Err(err).Msg("Failed to invalidate API key cache")
+	}
+}
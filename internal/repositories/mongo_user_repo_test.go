@@ -0,0 +1,318 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/chats/go-user-api/internal/database"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestMongoUserRepository_HasPermission(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	newRepo := func(mt *mtest.T) *MongoUserRepository {
+		redisClient, _ := newTestRedisClient(mt.T)
+		return NewMongoUserRepository(&database.MongoDB{Client: mt.Client, Database: mt.DB}, redisClient)
+	}
+
+	// assertSingleAggregate checks the whole check ran as one aggregation on user_roles
+	assertSingleAggregate := func(mt *mtest.T, userID uuid.UUID) {
+		events := mt.GetAllStartedEvents()
+		require.Len(mt, events, 1)
+		assert.Equal(mt, "aggregate", events[0].CommandName)
+		assert.Equal(mt, "user_roles", events[0].Command.Lookup("aggregate").StringValue())
+
+		stages, err := events[0].Command.Lookup("pipeline").Array().Values()
+		require.NoError(mt, err)
+		require.NotEmpty(mt, stages)
+
+		var match struct {
+			Match struct {
+				UserID uuid.UUID `bson:"user_id"`
+			} `bson:"$match"`
+		}
+		require.NoError(mt, stages[0].Unmarshal(&match))
+		assert.Equal(mt, userID, match.Match.UserID)
+	}
+
+	mt.Run("granted", func(mt *mtest.T) {
+		repo := newRepo(mt)
+		userID := uuid.New()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, mt.DB.Name()+".user_roles", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: uuid.New()}},
+		))
+
+		allowed, err := repo.HasPermission(context.Background(), userID, "user", "read")
+
+		require.NoError(mt, err)
+		assert.True(mt, allowed)
+		assertSingleAggregate(mt, userID)
+	})
+
+	mt.Run("denied", func(mt *mtest.T) {
+		repo := newRepo(mt)
+		userID := uuid.New()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, mt.DB.Name()+".user_roles", mtest.FirstBatch))
+
+		allowed, err := repo.HasPermission(context.Background(), userID, "user", "delete")
+
+		require.NoError(mt, err)
+		assert.False(mt, allowed)
+		assertSingleAggregate(mt, userID)
+	})
+
+	mt.Run("database error", func(mt *mtest.T) {
+		repo := newRepo(mt)
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 1, Message: "boom"}))
+
+		allowed, err := repo.HasPermission(context.Background(), uuid.New(), "user", "read")
+
+		assert.Error(mt, err)
+		assert.False(mt, allowed)
+	})
+}
+
+func TestMongoUserRepository_AssignRolesToUser(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	newRepo := func(mt *mtest.T) *MongoUserRepository {
+		redisClient, _ := newTestRedisClient(mt.T)
+		return NewMongoUserRepository(&database.MongoDB{Client: mt.Client, Database: mt.DB}, redisClient)
+	}
+
+	mt.Run("all roles exist", func(mt *mtest.T) {
+		repo := newRepo(mt)
+		roleIDs := []uuid.UUID{uuid.New(), uuid.New()}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, mt.DB.Name()+".roles", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: roleIDs[0]}},
+				bson.D{{Key: "_id", Value: roleIDs[1]}},
+			),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+
+		err := repo.AssignRolesToUser(context.Background(), uuid.New(), roleIDs)
+
+		require.NoError(mt, err)
+		events := mt.GetAllStartedEvents()
+		require.Len(mt, events, 4)
+		assert.Equal(mt, "find", events[0].CommandName)
+		assert.Equal(mt, "update", events[2].CommandName)
+	})
+
+	mt.Run("duplicate roles are upserted", func(mt *mtest.T) {
+		repo := newRepo(mt)
+		roleID := uuid.New()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, mt.DB.Name()+".roles", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: roleID}},
+			),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}, bson.E{Key: "nModified", Value: 0}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+
+		err := repo.AssignRolesToUser(context.Background(), uuid.New(), []uuid.UUID{roleID, roleID})
+
+		require.NoError(mt, err)
+		events := mt.GetAllStartedEvents()
+		require.Len(mt, events, 4)
+
+		// One unordered update command upserting each link rather than inserting it
+		update := events[2].Command
+		assert.Equal(mt, "update", events[2].CommandName)
+		assert.False(mt, update.Lookup("ordered").Boolean())
+		statements, err := update.Lookup("updates").Array().Values()
+		require.NoError(mt, err)
+		require.Len(mt, statements, 2)
+		for _, statement := range statements {
+			assert.True(mt, statement.Document().Lookup("upsert").Boolean())
+		}
+	})
+
+	mt.Run("missing role", func(mt *mtest.T) {
+		repo := newRepo(mt)
+		roleID := uuid.New()
+		missingID := uuid.New()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, mt.DB.Name()+".roles", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: roleID}},
+		))
+
+		err := repo.AssignRolesToUser(context.Background(), uuid.New(), []uuid.UUID{roleID, missingID})
+
+		require.Error(mt, err)
+		assert.True(mt, errors.Is(err, models.ErrRoleNotFound))
+		assert.Contains(mt, err.Error(), missingID.String())
+
+		// Only the lookup ran; no assignment was removed or inserted
+		events := mt.GetAllStartedEvents()
+		require.Len(mt, events, 1)
+		assert.Equal(mt, "find", events[0].CommandName)
+	})
+}
+
+func TestMongoUserRepository_RehashPassword(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	newRepo := func(mt *mtest.T) *MongoUserRepository {
+		redisClient, _ := newTestRedisClient(mt.T)
+		return NewMongoUserRepository(&database.MongoDB{Client: mt.Client, Database: mt.DB}, redisClient)
+	}
+
+	mt.Run("verified hash is replaced", func(mt *mtest.T) {
+		repo := newRepo(mt)
+		userID := uuid.New()
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		rehashed, err := repo.RehashPassword(context.Background(), userID, "old-hash", "new-hash")
+
+		require.NoError(mt, err)
+		assert.True(mt, rehashed)
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		filter := update.Lookup("q").Document()
+		assert.Equal(mt, "old-hash", filter.Lookup("password").StringValue())
+	})
+
+	mt.Run("password changed since verification is kept", func(mt *mtest.T) {
+		repo := newRepo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}))
+
+		rehashed, err := repo.RehashPassword(context.Background(), uuid.New(), "old-hash", "new-hash")
+
+		require.NoError(mt, err)
+		assert.False(mt, rehashed)
+	})
+}
+
+func TestMongoUserRepository_Create(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	newRepo := func(mt *mtest.T) *MongoUserRepository {
+		redisClient, _ := newTestRedisClient(mt.T)
+		return NewMongoUserRepository(&database.MongoDB{Client: mt.Client, Database: mt.DB}, redisClient)
+	}
+
+	// duplicateKey is the server's response to an insert violating the unique index
+	duplicateKey := func(mt *mtest.T, index string) bson.D {
+		return mtest.CreateWriteErrorsResponse(mtest.WriteError{
+			Code:    11000,
+			Message: "E11000 duplicate key error collection: " + mt.DB.Name() + ".users index: " + index + " dup key: { : \"johndoe\" }",
+		})
+	}
+
+	// insertedIDs returns the _id of every insert sent to the server
+	insertedIDs := func(mt *mtest.T) []uuid.UUID {
+		ids := make([]uuid.UUID, 0)
+		for _, event := range mt.GetAllStartedEvents() {
+			if event.CommandName != "insert" {
+				continue
+			}
+			var command struct {
+				Documents []struct {
+					ID uuid.UUID `bson:"_id"`
+				} `bson:"documents"`
+			}
+			require.NoError(mt, bson.Unmarshal(event.Command, &command))
+			for _, document := range command.Documents {
+				ids = append(ids, document.ID)
+			}
+		}
+		return ids
+	}
+
+	mt.Run("translates a duplicate username", func(mt *mtest.T) {
+		mt.AddMockResponses(duplicateKey(mt, "username_1"))
+
+		err := newRepo(mt).Create(context.Background(), &models.User{Username: "johndoe", Email: "john@example.com"})
+
+		assert.ErrorIs(mt, err, models.ErrUsernameExists)
+	})
+
+	mt.Run("translates a duplicate email", func(mt *mtest.T) {
+		mt.AddMockResponses(duplicateKey(mt, "email_1"))
+
+		err := newRepo(mt).Create(context.Background(), &models.User{Username: "johndoe", Email: "john@example.com"})
+
+		assert.ErrorIs(mt, err, models.ErrEmailExists)
+	})
+
+	mt.Run("retries a generated ID that collides", func(mt *mtest.T) {
+		mt.AddMockResponses(duplicateKey(mt, "_id_"), mtest.CreateSuccessResponse())
+		user := &models.User{Username: "johndoe", Email: "john@example.com"}
+
+		err := newRepo(mt).Create(context.Background(), user)
+
+		require.NoError(mt, err)
+		ids := insertedIDs(mt)
+		require.Len(mt, ids, 2)
+		assert.NotEqual(mt, ids[0], ids[1])
+		assert.Equal(mt, ids[1], user.ID)
+	})
+
+	mt.Run("gives up after repeated ID collisions", func(mt *mtest.T) {
+		for i := 0; i < database.MaxGeneratedIDAttempts; i++ {
+			mt.AddMockResponses(duplicateKey(mt, "_id_"))
+		}
+
+		err := newRepo(mt).Create(context.Background(), &models.User{Username: "johndoe", Email: "john@example.com"})
+
+		assert.Error(mt, err)
+		assert.Len(mt, insertedIDs(mt), database.MaxGeneratedIDAttempts)
+	})
+
+	mt.Run("does not retry a caller-chosen ID", func(mt *mtest.T) {
+		mt.AddMockResponses(duplicateKey(mt, "_id_"))
+
+		err := newRepo(mt).Create(context.Background(), &models.User{ID: uuid.New(), Username: "johndoe", Email: "john@example.com"})
+
+		assert.Error(mt, err)
+		assert.NotErrorIs(mt, err, models.ErrUsernameExists)
+		assert.Len(mt, insertedIDs(mt), 1)
+	})
+}
+
+func TestMongoUserRepository_GetAll_DanglingRole(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("reports the missing role with the partial page", func(mt *mtest.T) {
+		redisClient, mr := newTestRedisClient(mt.T)
+		repo := NewMongoUserRepository(&database.MongoDB{Client: mt.Client, Database: mt.DB}, redisClient)
+		userID, roleID, danglingID := uuid.New(), uuid.New(), uuid.New()
+
+		ns := mt.DB.Name()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, ns+".users", mtest.FirstBatch, bson.D{{Key: "_id", Value: userID}, {Key: "username", Value: "johndoe"}}),
+			mtest.CreateCursorResponse(0, ns+".user_roles", mtest.FirstBatch,
+				bson.D{{Key: "user_id", Value: userID}, {Key: "role_id", Value: roleID}},
+				bson.D{{Key: "user_id", Value: userID}, {Key: "role_id", Value: danglingID}},
+			),
+			mtest.CreateCursorResponse(0, ns+".roles", mtest.FirstBatch, bson.D{{Key: "_id", Value: roleID}, {Key: "name", Value: "editor"}}),
+			mtest.CreateCursorResponse(0, ns+".roles", mtest.FirstBatch),
+		)
+
+		users, err := repo.GetAll(context.Background(), 10, 0, models.SortOptions{})
+
+		var partial *models.PartialResultError
+		require.ErrorAs(mt, err, &partial)
+		require.Len(mt, partial.Warnings, 1)
+		assert.Equal(mt, userID.String(), partial.Warnings[0].ID)
+		assert.Contains(mt, partial.Warnings[0].Message, danglingID.String())
+
+		// The user is still listed, with the roles that exist
+		require.Len(mt, users, 1)
+		require.Len(mt, users[0].Roles, 1)
+		assert.Equal(mt, "editor", users[0].Roles[0].Name)
+
+		// A partial page is not cached
+		assert.Empty(mt, mr.Keys())
+	})
+}
@@ -2,6 +2,7 @@ package mongodb
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -9,23 +10,38 @@ import (
 	"github.com/chats/go-user-api/internal/models"
 	"github.com/chats/go-user-api/internal/repositories/transaction"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // MongoTx wraps MongoDB session for transaction management
 type MongoTx struct {
 	session mongo.Session
 	ctx     mongo.SessionContext
+
+	// sequential is set when the deployment has no transactions, so the writes were applied as
+	// they ran and there is nothing to commit or roll back
+	sequential bool
 }
 
 // Commit implements the Executor interface
 func (tx *MongoTx) Commit() error {
+	if tx.sequential {
+		tx.session.EndSession(tx.ctx)
+		return nil
+	}
 	return tx.ctx.CommitTransaction(tx.ctx)
 }
 
 // Rollback implements the Executor interface
 func (tx *MongoTx) Rollback() error {
+	if tx.sequential {
+		log.Warn().Msg("MongoDB has no transactions; writes made before the failure are kept")
+		tx.session.EndSession(tx.ctx)
+		return nil
+	}
 	return tx.ctx.AbortTransaction(tx.ctx)
 }
 
@@ -33,11 +49,20 @@ func (tx *MongoTx) Rollback() error {
 type TxRepository struct {
 	db  *database.MongoDB
 	ctx mongo.SessionContext
+
+	// sequential is set when the writes are applied as they run and cannot be rolled back
+	sequential bool
 }
 
 // Ensure TxRepository implements transaction.Repository
 var _ transaction.Repository = (*TxRepository)(nil)
 
+// Sequential reports whether the writes are applied as they run, without a transaction to roll
+// back; see transaction.RequireAtomic
+func (r *TxRepository) Sequential() bool {
+	return r.sequential
+}
+
 // usersCollection returns the MongoDB collection for users
 func (r *TxRepository) usersCollection() *mongo.Collection {
 	return r.db.GetCollection("users")
@@ -58,12 +83,18 @@ func (r *TxRepository) permissionsCollection() *mongo.Collection {
 	return r.db.GetCollection("permissions")
 }
 
+// apiKeysCollection returns the MongoDB collection for API keys
+func (r *TxRepository) apiKeysCollection() *mongo.Collection {
+	return r.db.GetCollection("api_keys")
+}
+
 // rolePermissionsCollection returns the MongoDB collection for role-permissions relationship
 func (r *TxRepository) rolePermissionsCollection() *mongo.Collection {
 	return r.db.GetCollection("role_permissions")
 }
 
-// NewTransactionManager creates a new transaction manager for MongoDB
+// NewTransactionManager creates a new transaction manager for MongoDB. When the deployment does not
+// support transactions, the writes of a transaction run one after another in a plain session.
 func NewTransactionManager(db *database.MongoDB) transaction.Manager[transaction.Repository] {
 	beginTx := func(ctx context.Context) (*MongoTx, error) {
 		session, err := db.Client.StartSession()
@@ -71,6 +102,14 @@ func NewTransactionManager(db *database.MongoDB) transaction.Manager[transaction
 			return nil, fmt.Errorf("failed to start MongoDB session: %w", err)
 		}
 
+		if !db.SupportsTransactions() {
+			return &MongoTx{
+				session:    session,
+				ctx:        mongo.NewSessionContext(ctx, session),
+				sequential: true,
+			}, nil
+		}
+
 		sessCtx, err := session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
 			return sc, nil
 		})
@@ -88,8 +127,9 @@ func NewTransactionManager(db *database.MongoDB) transaction.Manager[transaction
 
 	createRepo := func(tx *MongoTx) transaction.Repository {
 		return &TxRepository{
-			db:  db,
-			ctx: tx.ctx,
+			db:         db,
+			ctx:        tx.ctx,
+			sequential: tx.sequential,
 		}
 	}
 
@@ -99,7 +139,8 @@ func NewTransactionManager(db *database.MongoDB) transaction.Manager[transaction
 // CreateUser creates a new user within a transaction
 func (r *TxRepository) CreateUser(ctx context.Context, user *models.User) error {
 	// Generate UUID if not provided
-	if user.ID == uuid.Nil {
+	generatedID := user.ID == uuid.Nil
+	if generatedID {
 		user.ID = uuid.New()
 	}
 
@@ -112,9 +153,12 @@ func (r *TxRepository) CreateUser(ctx context.Context, user *models.User) error
 		user.UpdatedAt = now
 	}
 
-	// Insert into database
-	_, err := r.usersCollection().InsertOne(r.ctx, user)
-	if err != nil {
+	// Insert into database. A failed insert aborts the transaction, so a colliding generated ID is
+	// only drawn again when the writes run without one.
+	if err := database.InsertUser(r.ctx, r.usersCollection(), user, generatedID && r.sequential); err != nil {
+		if errors.Is(err, models.ErrUsernameExists) || errors.Is(err, models.ErrEmailExists) {
+			return err
+		}
 		return fmt.Errorf("failed to create user in MongoDB transaction: %w", err)
 	}
 
@@ -151,11 +195,13 @@ func (r *TxRepository) UpdateUser(ctx context.Context, user *models.User) error
 
 // UpdateUserPassword updates a user password within a transaction
 func (r *TxRepository) UpdateUserPassword(ctx context.Context, userID uuid.UUID, hashedPassword string) error {
+	now := time.Now()
 	filter := bson.M{"_id": userID}
 	update := bson.M{
 		"$set": bson.M{
-			"password":   hashedPassword,
-			"updated_at": time.Now(),
+			"password":            hashedPassword,
+			"updated_at":          now,
+			"password_changed_at": now,
 		},
 	}
 
@@ -173,32 +219,227 @@ func (r *TxRepository) UpdateUserPassword(ctx context.Context, userID uuid.UUID,
 
 // AssignRolesToUser assigns roles to a user within a transaction
 func (r *TxRepository) AssignRolesToUser(ctx context.Context, userID uuid.UUID, roleIDs []uuid.UUID) error {
+	// MongoDB has no foreign keys, so check every role exists in one lookup
+	if len(roleIDs) > 0 {
+		existing, err := r.existingRoleIDs(r.ctx, roleIDs)
+		if err != nil {
+			return fmt.Errorf("failed to look up roles in MongoDB transaction: %w", err)
+		}
+		if err := models.MissingRolesError(roleIDs, existing); err != nil {
+			return err
+		}
+	}
+
 	// Remove existing roles
 	_, err := r.userRolesCollection().DeleteMany(r.ctx, bson.M{"user_id": userID})
 	if err != nil {
 		return fmt.Errorf("failed to remove existing roles in MongoDB transaction: %w", err)
 	}
 
-	// Assign new roles
+	// Assign new roles; duplicates, including concurrent assignments, are skipped
 	if len(roleIDs) > 0 {
-		userRoles := make([]interface{}, 0, len(roleIDs))
-		for _, roleID := range roleIDs {
-			userRoles = append(userRoles, bson.M{
-				"user_id":    userID,
-				"role_id":    roleID,
-				"created_at": time.Now(),
-			})
-		}
-
-		_, err = r.userRolesCollection().InsertMany(r.ctx, userRoles)
+		_, err = r.userRolesCollection().BulkWrite(r.ctx, linkUpserts("user_id", userID, "role_id", roleIDs), unorderedWrites)
 		if err != nil {
 			return fmt.Errorf("failed to assign roles in MongoDB transaction: %w", err)
 		}
 	}
 
+	// Record the change so tokens carrying the old roles can be rejected
+	_, err = r.usersCollection().UpdateOne(r.ctx, bson.M{"_id": userID}, bson.M{"$set": bson.M{"roles_changed_at": time.Now()}})
+	if err != nil {
+		return fmt.Errorf("failed to record role change in MongoDB transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeUserTokens revokes every token issued to the user so far
+func (r *TxRepository) RevokeUserTokens(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.usersCollection().UpdateOne(r.ctx, bson.M{"_id": userID}, bson.M{"$set": bson.M{"tokens_revoked_at": time.Now()}})
+	if err != nil {
+		return fmt.Errorf("failed to revoke user tokens in MongoDB transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DeactivateUsers deactivates the given users within a transaction, touching only is_active and
+// updated_at, and returns the IDs of the users that were still active
+func (r *TxRepository) DeactivateUsers(ctx context.Context, userIDs []uuid.UUID, deactivatedAt time.Time) ([]uuid.UUID, error) {
+	cursor, err := r.usersCollection().Find(r.ctx,
+		bson.M{"_id": bson.M{"$in": userIDs}, "is_active": true, "deleted_at": nil},
+		options.Find().SetProjection(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up active users in MongoDB transaction: %w", err)
+	}
+
+	var active []struct {
+		ID uuid.UUID `bson:"_id"`
+	}
+	if err := cursor.All(r.ctx, &active); err != nil {
+		return nil, fmt.Errorf("failed to decode active users in MongoDB transaction: %w", err)
+	}
+
+	deactivated := make([]uuid.UUID, len(active))
+	for i, user := range active {
+		deactivated[i] = user.ID
+	}
+	if len(deactivated) == 0 {
+		return deactivated, nil
+	}
+
+	update := bson.M{"$set": bson.M{"is_active": false, "updated_at": deactivatedAt}}
+	if _, err := r.usersCollection().UpdateMany(r.ctx, bson.M{"_id": bson.M{"$in": deactivated}}, update); err != nil {
+		return nil, fmt.Errorf("failed to deactivate users in MongoDB transaction: %w", err)
+	}
+
+	return deactivated, nil
+}
+
+// SoftDeleteUser deactivates a user and marks it deleted within a transaction, keeping the document
+func (r *TxRepository) SoftDeleteUser(ctx context.Context, userID uuid.UUID, deletedAt time.Time) error {
+	filter := bson.M{"_id": userID, "deleted_at": nil}
+	update := bson.M{
+		"$set": bson.M{
+			"is_active":  false,
+			"deleted_at": deletedAt,
+			"updated_at": deletedAt,
+		},
+	}
+
+	result, err := r.usersCollection().UpdateOne(r.ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("failed to soft-delete user in MongoDB transaction: %w", err)
+	}
+
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("user not found or already deleted")
+	}
+
+	return nil
+}
+
+// RestoreUser reactivates a soft-deleted user within a transaction. The user's role assignments
+// were kept when it was deleted, so it regains them.
+func (r *TxRepository) RestoreUser(ctx context.Context, userID uuid.UUID, restoredAt time.Time) error {
+	filter := bson.M{"_id": userID, "deleted_at": bson.M{"$ne": nil}}
+	update := bson.M{
+		"$set": bson.M{
+			"is_active":  true,
+			"updated_at": restoredAt,
+		},
+		"$unset": bson.M{"deleted_at": ""},
+	}
+
+	result, err := r.usersCollection().UpdateOne(r.ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("failed to restore user in MongoDB transaction: %w", err)
+	}
+
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("user not found or not deleted")
+	}
+
+	return nil
+}
+
+// RevokeUserAPIKeys revokes every unrevoked API key created by a user within a transaction and
+// returns how many were revoked
+func (r *TxRepository) RevokeUserAPIKeys(ctx context.Context, userID uuid.UUID, revokedAt time.Time) (int, error) {
+	update := bson.M{
+		"$set": bson.M{
+			"revoked_at": revokedAt,
+			"updated_at": revokedAt,
+		},
+	}
+
+	result, err := r.apiKeysCollection().UpdateMany(r.ctx, bson.M{"created_by": userID, "revoked_at": bson.M{"$exists": false}}, update)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke API keys in MongoDB transaction: %w", err)
+	}
+
+	return int(result.ModifiedCount), nil
+}
+
+// ReassignAPIKeys moves every API key created by one user to another within a transaction
+// and returns how many were moved
+func (r *TxRepository) ReassignAPIKeys(ctx context.Context, fromUserID, toUserID uuid.UUID) (int, error) {
+	update := bson.M{
+		"$set": bson.M{
+			"created_by": toUserID,
+			"updated_at": time.Now(),
+		},
+	}
+
+	result, err := r.apiKeysCollection().UpdateMany(r.ctx, bson.M{"created_by": fromUserID}, update)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reassign API keys in MongoDB transaction: %w", err)
+	}
+
+	return int(result.ModifiedCount), nil
+}
+
+// DeleteUser deletes a user and every document owned by the user within a transaction
+func (r *TxRepository) DeleteUser(ctx context.Context, userID uuid.UUID) error {
+	result, err := r.usersCollection().DeleteOne(r.ctx, bson.M{"_id": userID})
+	if err != nil {
+		return fmt.Errorf("failed to delete user in MongoDB transaction: %w", err)
+	}
+
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	if _, err := r.userRolesCollection().DeleteMany(r.ctx, bson.M{"user_id": userID}); err != nil {
+		return fmt.Errorf("failed to delete user roles in MongoDB transaction: %w", err)
+	}
+	if _, err := r.apiKeysCollection().DeleteMany(r.ctx, bson.M{"created_by": userID}); err != nil {
+		return fmt.Errorf("failed to delete user API keys in MongoDB transaction: %w", err)
+	}
+
 	return nil
 }
 
+// CountActiveUsersWithRole counts the active users holding the named role within a transaction.
+// It also writes to the role document, so concurrent transactions removing holders of the role
+// conflict and cannot both commit on a count taken before the other's change.
+func (r *TxRepository) CountActiveUsersWithRole(ctx context.Context, roleName string) (int, error) {
+	var role struct {
+		ID uuid.UUID `bson:"_id"`
+	}
+	err := r.rolesCollection().FindOneAndUpdate(r.ctx,
+		bson.M{"name": roleName},
+		bson.M{"$currentDate": bson.M{"holders_checked_at": true}},
+		options.FindOneAndUpdate().SetProjection(bson.M{"_id": 1}),
+	).Decode(&role)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up role in MongoDB transaction: %w", err)
+	}
+
+	userIDs, err := r.userRolesCollection().Distinct(r.ctx, "user_id", bson.M{"role_id": role.ID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up users with role in MongoDB transaction: %w", err)
+	}
+	if len(userIDs) == 0 {
+		return 0, nil
+	}
+
+	count, err := r.usersCollection().CountDocuments(r.ctx, bson.M{
+		"_id":        bson.M{"$in": userIDs},
+		"is_active":  true,
+		"deleted_at": nil,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count users with role in MongoDB transaction: %w", err)
+	}
+
+	return int(count), nil
+}
+
 // CreateRole creates a new role within a transaction
 func (r *TxRepository) CreateRole(ctx context.Context, role *models.Role) error {
 	// Generate UUID if not provided
@@ -218,6 +459,9 @@ func (r *TxRepository) CreateRole(ctx context.Context, role *models.Role) error
 	// Insert into database
 	_, err := r.rolesCollection().InsertOne(r.ctx, role)
 	if err != nil {
+		if index, duplicate := database.DuplicateKeyIndex(err); duplicate && index == database.RolesNameIndex {
+			return models.ErrRoleNameExists
+		}
 		return fmt.Errorf("failed to create role in MongoDB transaction: %w", err)
 	}
 
@@ -257,18 +501,9 @@ func (r *TxRepository) AssignPermissionsToRole(ctx context.Context, roleID uuid.
 		return fmt.Errorf("failed to remove existing permissions in MongoDB transaction: %w", err)
 	}
 
-	// Assign new permissions
+	// Assign new permissions; duplicates, including concurrent assignments, are skipped
 	if len(permissionIDs) > 0 {
-		rolePermissions := make([]interface{}, 0, len(permissionIDs))
-		for _, permissionID := range permissionIDs {
-			rolePermissions = append(rolePermissions, bson.M{
-				"role_id":       roleID,
-				"permission_id": permissionID,
-				"created_at":    time.Now(),
-			})
-		}
-
-		_, err = r.rolePermissionsCollection().InsertMany(r.ctx, rolePermissions)
+		_, err = r.rolePermissionsCollection().BulkWrite(r.ctx, linkUpserts("role_id", roleID, "permission_id", permissionIDs), unorderedWrites)
 		if err != nil {
 			return fmt.Errorf("failed to assign permissions in MongoDB transaction: %w", err)
 		}
@@ -277,6 +512,131 @@ func (r *TxRepository) AssignPermissionsToRole(ctx context.Context, roleID uuid.
 	return nil
 }
 
+// AddPermissionsToRole grants a role the given permissions within a transaction, keeping the ones it
+// already holds, and returns the IDs that were newly granted
+func (r *TxRepository) AddPermissionsToRole(ctx context.Context, roleID uuid.UUID, permissionIDs []uuid.UUID) ([]uuid.UUID, error) {
+	held, err := r.rolePermissionIDs(roleID, permissionIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	added := make([]uuid.UUID, 0, len(permissionIDs))
+	for _, permissionID := range permissionIDs {
+		if held[permissionID] {
+			continue
+		}
+		held[permissionID] = true
+		added = append(added, permissionID)
+	}
+
+	// Upsert so that a concurrent grant of the same permission does not fail the transaction
+	if len(added) > 0 {
+		if _, err := r.rolePermissionsCollection().BulkWrite(r.ctx, linkUpserts("role_id", roleID, "permission_id", added), unorderedWrites); err != nil {
+			return nil, fmt.Errorf("failed to add permissions in MongoDB transaction: %w", err)
+		}
+	}
+
+	return added, nil
+}
+
+// RemovePermissionsFromRole revokes the given permissions from a role within a transaction, keeping
+// the others, and returns the IDs the role actually held
+func (r *TxRepository) RemovePermissionsFromRole(ctx context.Context, roleID uuid.UUID, permissionIDs []uuid.UUID) ([]uuid.UUID, error) {
+	held, err := r.rolePermissionIDs(roleID, permissionIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := make([]uuid.UUID, 0, len(held))
+	for _, permissionID := range permissionIDs {
+		if held[permissionID] {
+			delete(held, permissionID)
+			removed = append(removed, permissionID)
+		}
+	}
+
+	if len(removed) > 0 {
+		filter := bson.M{"role_id": roleID, "permission_id": bson.M{"$in": removed}}
+		if _, err := r.rolePermissionsCollection().DeleteMany(r.ctx, filter); err != nil {
+			return nil, fmt.Errorf("failed to remove permissions in MongoDB transaction: %w", err)
+		}
+	}
+
+	return removed, nil
+}
+
+// linkUpserts returns one upsert per ID linking it to the owner, e.g. a user to each of its roles.
+// Unlike an insert, upserting a link that already exists, from a duplicated ID or a concurrent
+// assignment, leaves it alone rather than failing the unique index and the transaction with it.
+func linkUpserts(ownerField string, ownerID uuid.UUID, linkField string, ids []uuid.UUID) []mongo.WriteModel {
+	now := time.Now()
+	writes := make([]mongo.WriteModel, 0, len(ids))
+	for _, id := range ids {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{ownerField: ownerID, linkField: id}).
+			SetUpdate(bson.M{"$setOnInsert": bson.M{"created_at": now}}).
+			SetUpsert(true))
+	}
+	return writes
+}
+
+// unorderedWrites runs every write even if an earlier one fails
+var unorderedWrites = options.BulkWrite().SetOrdered(false)
+
+// rolePermissionIDs checks every given permission exists, since MongoDB has no foreign keys, and
+// returns which of them the role holds
+func (r *TxRepository) rolePermissionIDs(roleID uuid.UUID, permissionIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+	existing, err := r.permissionsCollection().Distinct(r.ctx, "_id", bson.M{"_id": bson.M{"$in": permissionIDs}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up permissions in MongoDB transaction: %w", err)
+	}
+	existingIDs := make([]uuid.UUID, 0, len(existing))
+	for _, id := range existing {
+		if permissionID, ok := id.(uuid.UUID); ok {
+			existingIDs = append(existingIDs, permissionID)
+		}
+	}
+	if err := models.MissingPermissionsError(permissionIDs, existingIDs); err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.rolePermissionsCollection().Find(r.ctx,
+		bson.M{"role_id": roleID, "permission_id": bson.M{"$in": permissionIDs}},
+		options.Find().SetProjection(bson.M{"permission_id": 1}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up role permissions in MongoDB transaction: %w", err)
+	}
+
+	var rolePermissions []struct {
+		PermissionID uuid.UUID `bson:"permission_id"`
+	}
+	if err := cursor.All(r.ctx, &rolePermissions); err != nil {
+		return nil, fmt.Errorf("failed to decode role permissions in MongoDB transaction: %w", err)
+	}
+
+	held := make(map[uuid.UUID]bool, len(rolePermissions))
+	for _, rolePermission := range rolePermissions {
+		held[rolePermission.PermissionID] = true
+	}
+	return held, nil
+}
+
+// DeleteRole deletes a role and its user and permission assignments within a transaction
+func (r *TxRepository) DeleteRole(ctx context.Context, roleID uuid.UUID) error {
+	if _, err := r.rolesCollection().DeleteOne(r.ctx, bson.M{"_id": roleID}); err != nil {
+		return fmt.Errorf("failed to delete role in MongoDB transaction: %w", err)
+	}
+	if _, err := r.rolePermissionsCollection().DeleteMany(r.ctx, bson.M{"role_id": roleID}); err != nil {
+		return fmt.Errorf("failed to delete role permissions in MongoDB transaction: %w", err)
+	}
+	if _, err := r.userRolesCollection().DeleteMany(r.ctx, bson.M{"role_id": roleID}); err != nil {
+		return fmt.Errorf("failed to delete user roles in MongoDB transaction: %w", err)
+	}
+
+	return nil
+}
+
 // CreatePermission creates a new permission within a transaction
 func (r *TxRepository) CreatePermission(ctx context.Context, permission *models.Permission) error {
 	// Generate UUID if not provided
@@ -296,6 +656,13 @@ func (r *TxRepository) CreatePermission(ctx context.Context, permission *models.
 	// Insert into database
 	_, err := r.permissionsCollection().InsertOne(r.ctx, permission)
 	if err != nil {
+		index, duplicate := database.DuplicateKeyIndex(err)
+		switch {
+		case duplicate && index == database.PermissionsResourceActionIndex:
+			return models.ErrPermissionExists
+		case duplicate && index == database.PermissionsNameIndex:
+			return models.ErrPermissionNameExists
+		}
 		return fmt.Errorf("failed to create permission in MongoDB transaction: %w", err)
 	}
 
@@ -313,6 +680,7 @@ func (r *TxRepository) UpdatePermission(ctx context.Context, permission *models.
 			"description": permission.Description,
 			"resource":    permission.Resource,
 			"action":      permission.Action,
+			"category":    permission.Category,
 			"updated_at":  permission.UpdatedAt,
 		},
 	}
@@ -328,3 +696,36 @@ func (r *TxRepository) UpdatePermission(ctx context.Context, permission *models.
 
 	return nil
 }
+
+// DeletePermission deletes a permission and its role assignments within a transaction
+func (r *TxRepository) DeletePermission(ctx context.Context, permissionID uuid.UUID) error {
+	if _, err := r.permissionsCollection().DeleteOne(r.ctx, bson.M{"_id": permissionID}); err != nil {
+		return fmt.Errorf("failed to delete permission in MongoDB transaction: %w", err)
+	}
+	if _, err := r.rolePermissionsCollection().DeleteMany(r.ctx, bson.M{"permission_id": permissionID}); err != nil {
+		return fmt.Errorf("failed to delete role permissions in MongoDB transaction: %w", err)
+	}
+
+	return nil
+}
+
+// existingRoleIDs returns which of the given role IDs exist
+func (r *TxRepository) existingRoleIDs(ctx context.Context, roleIDs []uuid.UUID) ([]uuid.UUID, error) {
+	cursor, err := r.rolesCollection().Find(ctx, bson.M{"_id": bson.M{"$in": roleIDs}}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	var roles []struct {
+		ID uuid.UUID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &roles); err != nil {
+		return nil, err
+	}
+
+	existing := make([]uuid.UUID, len(roles))
+	for i, role := range roles {
+		existing[i] = role.ID
+	}
+	return existing, nil
+}
@@ -0,0 +1,183 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chats/go-user-api/internal/database"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/repositories/transaction"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestTransactionManager_WithoutTransactionSupport(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	newManager := func(mt *mtest.T) transaction.Manager[transaction.Repository] {
+		db := &database.MongoDB{Client: mt.Client, Database: mt.DB}
+		db.DisableTransactions()
+		return NewTransactionManager(db)
+	}
+
+	// assertNoTransaction checks every command ran on its own, outside a transaction
+	assertNoTransaction := func(mt *mtest.T) {
+		for _, event := range mt.GetAllStartedEvents() {
+			assert.NotContains(mt, []string{"commitTransaction", "abortTransaction"}, event.CommandName)
+			_, err := event.Command.LookupErr("startTransaction")
+			assert.Error(mt, err, "%s must not start a transaction", event.CommandName)
+		}
+	}
+
+	mt.Run("Writes run one after another", func(mt *mtest.T) {
+		manager := newManager(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+
+		err := manager.ExecuteTx(context.Background(), func(tx transaction.Repository) error {
+			if err := tx.RevokeUserTokens(context.Background(), uuid.New()); err != nil {
+				return err
+			}
+			return tx.RevokeUserTokens(context.Background(), uuid.New())
+		})
+
+		require.NoError(mt, err)
+		assert.Len(mt, mt.GetAllStartedEvents(), 2)
+		assertNoTransaction(mt)
+	})
+
+	mt.Run("Failure keeps earlier writes and returns the error", func(mt *mtest.T) {
+		manager := newManager(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		failure := errors.New("role not found")
+
+		err := manager.ExecuteTx(context.Background(), func(tx transaction.Repository) error {
+			if err := tx.RevokeUserTokens(context.Background(), uuid.New()); err != nil {
+				return err
+			}
+			return failure
+		})
+
+		assert.ErrorIs(mt, err, failure)
+		assert.Len(mt, mt.GetAllStartedEvents(), 1)
+		assertNoTransaction(mt)
+	})
+	mt.Run("Changes that must roll back are refused", func(mt *mtest.T) {
+		manager := newManager(mt)
+
+		err := manager.ExecuteTx(context.Background(), func(tx transaction.Repository) error {
+			return transaction.RequireAtomic(tx)
+		})
+
+		assert.ErrorIs(mt, err, transaction.ErrNotAtomic)
+		assert.Empty(mt, mt.GetAllStartedEvents())
+	})
+}
+
+func TestTxRepository_DeactivateUsers(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("Only is_active and updated_at are written", func(mt *mtest.T) {
+		db := &database.MongoDB{Client: mt.Client, Database: mt.DB}
+		db.DisableTransactions()
+		active, deactivated := uuid.New(), uuid.New()
+		ns := mt.DB.Name() + ".users"
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, bson.D{{Key: "_id", Value: active}}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		var result []uuid.UUID
+		err := NewTransactionManager(db).ExecuteTx(context.Background(), func(tx transaction.Repository) error {
+			var err error
+			result, err = tx.DeactivateUsers(context.Background(), []uuid.UUID{active, deactivated}, time.Now())
+			return err
+		})
+
+		require.NoError(mt, err)
+		assert.Equal(mt, []uuid.UUID{active}, result)
+		mt.GetStartedEvent()
+		update := mt.GetStartedEvent()
+		require.Equal(mt, "update", update.CommandName)
+		set := update.Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u", "$set").Document()
+		elements, err := set.Elements()
+		require.NoError(mt, err)
+		keys := make([]string, len(elements))
+		for i, element := range elements {
+			keys[i] = element.Key()
+		}
+		assert.ElementsMatch(mt, []string{"is_active", "updated_at"}, keys)
+	})
+
+	mt.Run("No update when every user is already inactive", func(mt *mtest.T) {
+		db := &database.MongoDB{Client: mt.Client, Database: mt.DB}
+		db.DisableTransactions()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, mt.DB.Name()+".users", mtest.FirstBatch))
+
+		err := NewTransactionManager(db).ExecuteTx(context.Background(), func(tx transaction.Repository) error {
+			result, err := tx.DeactivateUsers(context.Background(), []uuid.UUID{uuid.New()}, time.Now())
+			assert.Empty(mt, result)
+			return err
+		})
+
+		require.NoError(mt, err)
+		assert.Len(mt, mt.GetAllStartedEvents(), 1)
+	})
+}
+
+func TestTxRepository_CreateUser(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	// createUser creates a user through a transaction repository whose writes run in a transaction
+	// unless sequential
+	createUser := func(mt *mtest.T, sequential bool) error {
+		session, err := mt.Client.StartSession()
+		require.NoError(mt, err)
+		defer session.EndSession(context.Background())
+
+		repo := &TxRepository{
+			db:         &database.MongoDB{Client: mt.Client, Database: mt.DB},
+			ctx:        mongo.NewSessionContext(context.Background(), session),
+			sequential: sequential,
+		}
+		return repo.CreateUser(context.Background(), &models.User{Username: "johndoe"})
+	}
+	idCollision := func(mt *mtest.T) bson.D {
+		return mtest.CreateWriteErrorsResponse(mtest.WriteError{
+			Code:    11000,
+			Message: "E11000 duplicate key error collection: " + mt.DB.Name() + ".users index: _id_ dup key: { : \"id\" }",
+		})
+	}
+	inserts := func(mt *mtest.T) int {
+		count := 0
+		for _, event := range mt.GetAllStartedEvents() {
+			if event.CommandName == "insert" {
+				count++
+			}
+		}
+		return count
+	}
+
+	mt.Run("A colliding generated ID fails the transaction", func(mt *mtest.T) {
+		mt.AddMockResponses(idCollision(mt), mtest.CreateSuccessResponse())
+
+		err := createUser(mt, false)
+
+		assert.Error(mt, err)
+		assert.Equal(mt, 1, inserts(mt))
+	})
+
+	mt.Run("Sequential writes draw a new ID", func(mt *mtest.T) {
+		mt.AddMockResponses(idCollision(mt), mtest.CreateSuccessResponse())
+
+		err := createUser(mt, true)
+
+		assert.NoError(mt, err)
+		assert.Equal(mt, 2, inserts(mt))
+	})
+}
@@ -3,12 +3,14 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/chats/go-user-api/internal/database"
 	"github.com/chats/go-user-api/internal/models"
 	"github.com/chats/go-user-api/internal/repositories/transaction"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // PostgresTx implements transaction.Executor
@@ -67,6 +69,12 @@ func (r *TxRepository) CreateUser(ctx context.Context, user *models.User) error
 	).Scan(&user.ID)
 
 	if err != nil {
+		switch constraint, _ := database.UniqueViolation(err); constraint {
+		case database.UsersUsernameKey:
+			return models.ErrUsernameExists
+		case database.UsersEmailKey:
+			return models.ErrEmailExists
+		}
 		return fmt.Errorf("failed to create user in transaction: %w", err)
 	}
 
@@ -104,7 +112,7 @@ func (r *TxRepository) UpdateUser(ctx context.Context, user *models.User) error
 func (r *TxRepository) UpdateUserPassword(ctx context.Context, userID uuid.UUID, hashedPassword string) error {
 	query := `
 		UPDATE users
-		SET password = $1, updated_at = NOW()
+		SET password = $1, updated_at = NOW(), password_changed_at = NOW()
 		WHERE id = $2
 	`
 
@@ -118,17 +126,28 @@ func (r *TxRepository) UpdateUserPassword(ctx context.Context, userID uuid.UUID,
 
 // AssignRolesToUser assigns roles to a user within a transaction
 func (r *TxRepository) AssignRolesToUser(ctx context.Context, userID uuid.UUID, roleIDs []uuid.UUID) error {
+	// Check every role exists in one lookup rather than failing on a foreign key
+	if len(roleIDs) > 0 {
+		existing := make([]uuid.UUID, 0, len(roleIDs))
+		if err := r.tx.SelectContext(ctx, &existing, "SELECT id FROM roles WHERE id = ANY($1)", pq.Array(roleIDs)); err != nil {
+			return fmt.Errorf("failed to look up roles in transaction: %w", err)
+		}
+		if err := models.MissingRolesError(roleIDs, existing); err != nil {
+			return err
+		}
+	}
+
 	// Remove existing roles
 	_, err := r.tx.ExecContext(ctx, "DELETE FROM user_roles WHERE user_id = $1", userID)
 	if err != nil {
 		return fmt.Errorf("failed to remove existing roles in transaction: %w", err)
 	}
 
-	// Assign new roles
+	// Assign new roles; duplicates, including concurrent assignments, are skipped
 	for _, roleID := range roleIDs {
 		_, err = r.tx.ExecContext(
 			ctx,
-			"INSERT INTO user_roles (user_id, role_id) VALUES ($1, $2)",
+			"INSERT INTO user_roles (user_id, role_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
 			userID,
 			roleID,
 		)
@@ -137,9 +156,178 @@ func (r *TxRepository) AssignRolesToUser(ctx context.Context, userID uuid.UUID,
 		}
 	}
 
+	// Record the change so tokens carrying the old roles can be rejected
+	_, err = r.tx.ExecContext(ctx, "UPDATE users SET roles_changed_at = NOW() WHERE id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("failed to record role change in transaction: %w", err)
+	}
+
 	return nil
 }
 
+// RevokeUserTokens revokes every token issued to the user so far
+func (r *TxRepository) RevokeUserTokens(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.tx.ExecContext(ctx, "UPDATE users SET tokens_revoked_at = NOW() WHERE id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke user tokens in transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DeactivateUsers deactivates the given users within a transaction, touching only is_active and
+// updated_at, and returns the IDs of the users that were still active
+func (r *TxRepository) DeactivateUsers(ctx context.Context, userIDs []uuid.UUID, deactivatedAt time.Time) ([]uuid.UUID, error) {
+	query := `
+		UPDATE users
+		SET is_active = false, updated_at = $1
+		WHERE id = ANY($2) AND is_active = true AND deleted_at IS NULL
+		RETURNING id
+	`
+
+	deactivated := make([]uuid.UUID, 0, len(userIDs))
+	if err := r.tx.SelectContext(ctx, &deactivated, query, deactivatedAt, pq.Array(userIDs)); err != nil {
+		return nil, fmt.Errorf("failed to deactivate users in transaction: %w", err)
+	}
+
+	return deactivated, nil
+}
+
+// SoftDeleteUser deactivates a user and marks it deleted within a transaction, keeping the row
+func (r *TxRepository) SoftDeleteUser(ctx context.Context, userID uuid.UUID, deletedAt time.Time) error {
+	query := `
+		UPDATE users
+		SET is_active = false, deleted_at = $1, updated_at = $1
+		WHERE id = $2 AND deleted_at IS NULL
+	`
+
+	result, err := r.tx.ExecContext(ctx, query, deletedAt, userID)
+	if err != nil {
+		return fmt.Errorf("failed to soft-delete user in transaction: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found or already deleted")
+	}
+
+	return nil
+}
+
+// RestoreUser reactivates a soft-deleted user within a transaction. The user's role assignments
+// were kept when it was deleted, so it regains them.
+func (r *TxRepository) RestoreUser(ctx context.Context, userID uuid.UUID, restoredAt time.Time) error {
+	query := `
+		UPDATE users
+		SET is_active = true, deleted_at = NULL, updated_at = $1
+		WHERE id = $2 AND deleted_at IS NOT NULL
+	`
+
+	result, err := r.tx.ExecContext(ctx, query, restoredAt, userID)
+	if err != nil {
+		return fmt.Errorf("failed to restore user in transaction: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found or not deleted")
+	}
+
+	return nil
+}
+
+// RevokeUserAPIKeys revokes every unrevoked API key created by a user within a transaction and
+// returns how many were revoked
+func (r *TxRepository) RevokeUserAPIKeys(ctx context.Context, userID uuid.UUID, revokedAt time.Time) (int, error) {
+	result, err := r.tx.ExecContext(ctx,
+		"UPDATE api_keys SET revoked_at = $1, updated_at = $1 WHERE created_by = $2 AND revoked_at IS NULL",
+		revokedAt, userID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke API keys in transaction: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return int(rowsAffected), nil
+}
+
+// ReassignAPIKeys moves every API key created by one user to another within a transaction
+// and returns how many were moved
+func (r *TxRepository) ReassignAPIKeys(ctx context.Context, fromUserID, toUserID uuid.UUID) (int, error) {
+	result, err := r.tx.ExecContext(ctx,
+		"UPDATE api_keys SET created_by = $1, updated_at = NOW() WHERE created_by = $2",
+		toUserID, fromUserID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reassign API keys in transaction: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return int(rowsAffected), nil
+}
+
+// DeleteUser deletes a user and every row owned by the user within a transaction
+func (r *TxRepository) DeleteUser(ctx context.Context, userID uuid.UUID) error {
+	for _, query := range []string{
+		"DELETE FROM user_roles WHERE user_id = $1",
+		"DELETE FROM api_keys WHERE created_by = $1",
+	} {
+		if _, err := r.tx.ExecContext(ctx, query, userID); err != nil {
+			return fmt.Errorf("failed to delete user-owned rows in transaction: %w", err)
+		}
+	}
+
+	result, err := r.tx.ExecContext(ctx, "DELETE FROM users WHERE id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete user in transaction: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+// CountActiveUsersWithRole counts the active users holding the named role within a transaction.
+// Their rows stay locked until the transaction ends, so concurrent transactions removing
+// holders of the role wait for each other and each counts what the other left.
+func (r *TxRepository) CountActiveUsersWithRole(ctx context.Context, roleName string) (int, error) {
+	query := `
+		SELECT u.id
+		FROM users u
+		JOIN user_roles ur ON ur.user_id = u.id
+		JOIN roles r ON r.id = ur.role_id
+		WHERE r.name = $1 AND u.is_active = true AND u.deleted_at IS NULL
+		FOR UPDATE OF u
+	`
+
+	var userIDs []uuid.UUID
+	if err := r.tx.SelectContext(ctx, &userIDs, query, roleName); err != nil {
+		return 0, fmt.Errorf("failed to count users with role in transaction: %w", err)
+	}
+
+	return len(userIDs), nil
+}
+
 // CreateRole creates a new role within a transaction
 func (r *TxRepository) CreateRole(ctx context.Context, role *models.Role) error {
 	query := `
@@ -158,6 +346,9 @@ func (r *TxRepository) CreateRole(ctx context.Context, role *models.Role) error
 	).Scan(&role.ID)
 
 	if err != nil {
+		if constraint, _ := database.UniqueViolation(err); constraint == database.RolesNameKey {
+			return models.ErrRoleNameExists
+		}
 		return fmt.Errorf("failed to create role in transaction: %w", err)
 	}
 
@@ -196,11 +387,11 @@ func (r *TxRepository) AssignPermissionsToRole(ctx context.Context, roleID uuid.
 		return fmt.Errorf("failed to remove existing permissions in transaction: %w", err)
 	}
 
-	// Assign new permissions
+	// Assign new permissions; duplicates, including concurrent assignments, are skipped
 	for _, permissionID := range permissionIDs {
 		_, err = r.tx.ExecContext(
 			ctx,
-			"INSERT INTO role_permissions (role_id, permission_id) VALUES ($1, $2)",
+			"INSERT INTO role_permissions (role_id, permission_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
 			roleID,
 			permissionID,
 		)
@@ -212,11 +403,70 @@ func (r *TxRepository) AssignPermissionsToRole(ctx context.Context, roleID uuid.
 	return nil
 }
 
+// AddPermissionsToRole grants a role the given permissions within a transaction, keeping the ones it
+// already holds, and returns the IDs that were newly granted
+func (r *TxRepository) AddPermissionsToRole(ctx context.Context, roleID uuid.UUID, permissionIDs []uuid.UUID) ([]uuid.UUID, error) {
+	// Check every permission exists in one lookup rather than failing on a foreign key
+	existing := make([]uuid.UUID, 0, len(permissionIDs))
+	if err := r.tx.SelectContext(ctx, &existing, "SELECT id FROM permissions WHERE id = ANY($1)", pq.Array(permissionIDs)); err != nil {
+		return nil, fmt.Errorf("failed to look up permissions in transaction: %w", err)
+	}
+	if err := models.MissingPermissionsError(permissionIDs, existing); err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO role_permissions (role_id, permission_id)
+		SELECT $1, unnest($2::uuid[])
+		ON CONFLICT (role_id, permission_id) DO NOTHING
+		RETURNING permission_id
+	`
+
+	added := make([]uuid.UUID, 0, len(permissionIDs))
+	if err := r.tx.SelectContext(ctx, &added, query, roleID, pq.Array(permissionIDs)); err != nil {
+		return nil, fmt.Errorf("failed to add permissions in transaction: %w", err)
+	}
+
+	return added, nil
+}
+
+// RemovePermissionsFromRole revokes the given permissions from a role within a transaction, keeping
+// the others, and returns the IDs the role actually held
+func (r *TxRepository) RemovePermissionsFromRole(ctx context.Context, roleID uuid.UUID, permissionIDs []uuid.UUID) ([]uuid.UUID, error) {
+	existing := make([]uuid.UUID, 0, len(permissionIDs))
+	if err := r.tx.SelectContext(ctx, &existing, "SELECT id FROM permissions WHERE id = ANY($1)", pq.Array(permissionIDs)); err != nil {
+		return nil, fmt.Errorf("failed to look up permissions in transaction: %w", err)
+	}
+	if err := models.MissingPermissionsError(permissionIDs, existing); err != nil {
+		return nil, err
+	}
+
+	removed := make([]uuid.UUID, 0, len(permissionIDs))
+	if err := r.tx.SelectContext(ctx, &removed,
+		"DELETE FROM role_permissions WHERE role_id = $1 AND permission_id = ANY($2) RETURNING permission_id",
+		roleID, pq.Array(permissionIDs),
+	); err != nil {
+		return nil, fmt.Errorf("failed to remove permissions in transaction: %w", err)
+	}
+
+	return removed, nil
+}
+
+// DeleteRole deletes a role within a transaction; its user and permission assignments cascade
+func (r *TxRepository) DeleteRole(ctx context.Context, roleID uuid.UUID) error {
+	_, err := r.tx.ExecContext(ctx, "DELETE FROM roles WHERE id = $1", roleID)
+	if err != nil {
+		return fmt.Errorf("failed to delete role in transaction: %w", err)
+	}
+
+	return nil
+}
+
 // CreatePermission creates a new permission within a transaction
 func (r *TxRepository) CreatePermission(ctx context.Context, permission *models.Permission) error {
 	query := `
-		INSERT INTO permissions (name, description, resource, action, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO permissions (name, description, resource, action, category, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id
 	`
 
@@ -227,11 +477,18 @@ func (r *TxRepository) CreatePermission(ctx context.Context, permission *models.
 		permission.Description,
 		permission.Resource,
 		permission.Action,
+		permission.Category,
 		permission.CreatedAt,
 		permission.UpdatedAt,
 	).Scan(&permission.ID)
 
 	if err != nil {
+		switch constraint, _ := database.UniqueViolation(err); constraint {
+		case database.PermissionsResourceActionKey:
+			return models.ErrPermissionExists
+		case database.PermissionsNameKey:
+			return models.ErrPermissionNameExists
+		}
 		return fmt.Errorf("failed to create permission in transaction: %w", err)
 	}
 
@@ -242,8 +499,8 @@ func (r *TxRepository) CreatePermission(ctx context.Context, permission *models.
 func (r *TxRepository) UpdatePermission(ctx context.Context, permission *models.Permission) error {
 	query := `
 		UPDATE permissions
-		SET name = $1, description = $2, resource = $3, action = $4, updated_at = $5
-		WHERE id = $6
+		SET name = $1, description = $2, resource = $3, action = $4, category = $5, updated_at = $6
+		WHERE id = $7
 	`
 
 	_, err := r.tx.ExecContext(
@@ -253,6 +510,7 @@ func (r *TxRepository) UpdatePermission(ctx context.Context, permission *models.
 		permission.Description,
 		permission.Resource,
 		permission.Action,
+		permission.Category,
 		permission.UpdatedAt,
 		permission.ID,
 	)
@@ -263,3 +521,13 @@ func (r *TxRepository) UpdatePermission(ctx context.Context, permission *models.
 
 	return nil
 }
+
+// DeletePermission deletes a permission within a transaction; its role assignments cascade
+func (r *TxRepository) DeletePermission(ctx context.Context, permissionID uuid.UUID) error {
+	_, err := r.tx.ExecContext(ctx, "DELETE FROM permissions WHERE id = $1", permissionID)
+	if err != nil {
+		return fmt.Errorf("failed to delete permission in transaction: %w", err)
+	}
+
+	return nil
+}
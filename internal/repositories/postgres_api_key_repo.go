@@ -0,0 +1,207 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/chats/go-user-api/internal/cache"
+	"github.com/chats/go-user-api/internal/database"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// APIKeyRepository handles database operations for API keys
+type APIKeyRepository struct {
+	db    *database.PostgresDB
+	cache *cache.RedisClient
+}
+
+// Ensure APIKeyRepository implements APIKeyRepositoryInterface
+var _ APIKeyRepositoryInterface = (*APIKeyRepository)(nil)
+
+// NewAPIKeyRepository creates a new API key repository
+func NewAPIKeyRepository(db *database.PostgresDB, cache *cache.RedisClient) *APIKeyRepository {
+	return &APIKeyRepository{
+		db:    db,
+		cache: cache,
+	}
+}
+
+const apiKeyColumns = `id, name, key_hash, prefix, permissions, created_by, expires_at, revoked_at, created_at, updated_at`
+
+// Create creates a new API key in the database
+func (r *APIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	query := `
+		INSERT INTO api_keys (name, key_hash, prefix, permissions, created_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := r.db.QueryRowxContext(
+		ctx,
+		query,
+		key.Name,
+		key.KeyHash,
+		key.Prefix,
+		pq.Array(key.Permissions),
+		key.CreatedBy,
+		key.ExpiresAt,
+	).Scan(&key.ID, &key.CreatedAt, &key.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	// Clear API key cache
+	r.invalidateAPIKeyCache()
+
+	return nil
+}
+
+// GetByID retrieves an API key by ID
+func (r *APIKeyRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1`
+
+	key, err := r.scanAPIKey(r.db.QueryRowxContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	return key, nil
+}
+
+// GetByHash retrieves an API key by the hash of its secret
+func (r *APIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	cacheKey := apiKeyHashCacheKey(keyHash)
+
+	// Try to get from cache first
+	var cached models.APIKey
+	found, err := r.cache.Get(cacheKey, &cached)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get API key from cache")
+	}
+
+	if found {
+		cached.KeyHash = keyHash
+		return &cached, nil
+	}
+
+	// If not in cache, get from database
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1`
+
+	key, err := r.scanAPIKey(r.db.QueryRowxContext(ctx, query, keyHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	// Cache the API key
+	if err := r.cache.Set(cacheKey, key); err != nil {
+		log.Debug().Err(err).Msg("Failed to cache API key")
+	}
+
+	return key, nil
+}
+
+// GetAll retrieves all API keys
+func (r *APIKeyRepository) GetAll(ctx context.Context) ([]*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY created_at DESC, id DESC`
+
+	return r.queryAPIKeys(ctx, query)
+}
+
+// GetByCreator retrieves the API keys created by a user
+func (r *APIKeyRepository) GetByCreator(ctx context.Context, userID uuid.UUID) ([]*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE created_by = $1 ORDER BY created_at DESC, id DESC`
+
+	return r.queryAPIKeys(ctx, query, userID)
+}
+
+// queryAPIKeys runs a query selecting apiKeyColumns and scans every row
+func (r *APIKeyRepository) queryAPIKeys(ctx context.Context, query string, args ...interface{}) ([]*models.APIKey, error) {
+	rows, err := r.db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]*models.APIKey, 0)
+	for rows.Next() {
+		key, err := r.scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// Revoke marks an API key as revoked
+func (r *APIKeyRepository) Revoke(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE api_keys
+		SET revoked_at = $1, updated_at = $1
+		WHERE id = $2 AND revoked_at IS NULL
+		RETURNING key_hash
+	`
+
+	var keyHash string
+	if err := r.db.QueryRowxContext(ctx, query, time.Now(), id).Scan(&keyHash); err != nil {
+		if err == sql.ErrNoRows {
+			return models.ErrAPIKeyNotFound
+		}
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	// Drop the cached key synchronously, so it stops authenticating now even when
+	// pattern invalidation runs in the background
+	if err := r.cache.Delete(apiKeyHashCacheKey(keyHash)); err != nil {
+		log.Warn().Err(err).Str("api_key_id", id.String()).Msg("Failed to delete revoked API key from cache")
+	}
+	r.invalidateAPIKeyCache()
+
+	return nil
+}
+
+// scanAPIKey scans a single api_keys row, including the permissions array
+func (r *APIKeyRepository) scanAPIKey(row interface{ Scan(...interface{}) error }) (*models.APIKey, error) {
+	var key models.APIKey
+	err := row.Scan(
+		&key.ID,
+		&key.Name,
+		&key.KeyHash,
+		&key.Prefix,
+		pq.Array(&key.Permissions),
+		&key.CreatedBy,
+		&key.ExpiresAt,
+		&key.RevokedAt,
+		&key.CreatedAt,
+		&key.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// apiKeyHashCacheKey is the cache key of the API key with the given hash
+func apiKeyHashCacheKey(keyHash string) string {
+	return "apikey:hash:" + keyHash
+}
+
+// invalidateAPIKeyCache clears all API key related cache
+func (r *APIKeyRepository) invalidateAPIKeyCache() {
+	if err := r.cache.DeleteByPattern("apikey:*"); err != nil {
+		log.Debug().Err(err).Msg("Failed to invalidate API key cache")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/chats/go-user-api/internal/database"
 	"github.com/chats/go-user-api/internal/models"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/rs/zerolog/log"
 )
 
@@ -33,8 +34,8 @@ func NewPermissionRepository(db *database.PostgresDB, cache *cache.RedisClient)
 // Create creates a new permission in the database
 func (r *PermissionRepository) Create(ctx context.Context, permission *models.Permission) error {
 	query := `
-		INSERT INTO permissions (name, description, resource, action)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO permissions (name, description, resource, action, category)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id, created_at, updated_at
 	`
 
@@ -45,9 +46,16 @@ func (r *PermissionRepository) Create(ctx context.Context, permission *models.Pe
 		permission.Description,
 		permission.Resource,
 		permission.Action,
+		permission.Category,
 	).Scan(&permission.ID, &permission.CreatedAt, &permission.UpdatedAt)
 
 	if err != nil {
+		switch constraint, _ := database.UniqueViolation(err); constraint {
+		case database.PermissionsResourceActionKey:
+			return models.ErrPermissionExists
+		case database.PermissionsNameKey:
+			return models.ErrPermissionNameExists
+		}
 		return fmt.Errorf("failed to create permission: %w", err)
 	}
 
@@ -74,7 +82,7 @@ func (r *PermissionRepository) GetByID(ctx context.Context, id uuid.UUID) (*mode
 
 	// If not in cache, get from database
 	query := `
-		SELECT id, name, description, resource, action, created_at, updated_at
+		SELECT id, name, description, resource, action, category, created_at, updated_at
 		FROM permissions
 		WHERE id = $1
 	`
@@ -94,8 +102,15 @@ func (r *PermissionRepository) GetByID(ctx context.Context, id uuid.UUID) (*mode
 	return &permission, nil
 }
 
-// GetByResourceAction retrieves a permission by resource and action
+// GetByResourceAction retrieves a permission by resource and action, neither of which may be blank
 func (r *PermissionRepository) GetByResourceAction(ctx context.Context, resource, action string) (*models.Permission, error) {
+	if err := models.CheckPermissionLookup("resource", resource); err != nil {
+		return nil, err
+	}
+	if err := models.CheckPermissionLookup("action", action); err != nil {
+		return nil, err
+	}
+
 	cacheKey := fmt.Sprintf("permission:resource:%s:action:%s", resource, action)
 
 	// Try to get from cache first
@@ -111,7 +126,7 @@ func (r *PermissionRepository) GetByResourceAction(ctx context.Context, resource
 
 	// If not in cache, get from database
 	query := `
-		SELECT id, name, description, resource, action, created_at, updated_at
+		SELECT id, name, description, resource, action, category, created_at, updated_at
 		FROM permissions
 		WHERE resource = $1 AND action = $2
 	`
@@ -131,9 +146,21 @@ func (r *PermissionRepository) GetByResourceAction(ctx context.Context, resource
 	return &permission, nil
 }
 
-// GetAll retrieves all permissions
-func (r *PermissionRepository) GetAll(ctx context.Context) ([]*models.Permission, error) {
-	cacheKey := "permissions:all"
+// ExistsByResourceAction reports whether a permission is defined for the resource and action
+func (r *PermissionRepository) ExistsByResourceAction(ctx context.Context, resource, action string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM permissions WHERE resource = $1 AND action = $2)`
+
+	var exists bool
+	if err := r.db.GetContext(ctx, &exists, query, resource, action); err != nil {
+		return false, fmt.Errorf("failed to check permission: %w", err)
+	}
+
+	return exists, nil
+}
+
+// GetAll retrieves all permissions ordered by sort, or by resource and action by default
+func (r *PermissionRepository) GetAll(ctx context.Context, sort models.SortOptions) ([]*models.Permission, error) {
+	cacheKey := sortCacheKey("permissions:all", sort)
 
 	// Try to get from cache first
 	var permissions []*models.Permission
@@ -147,11 +174,11 @@ func (r *PermissionRepository) GetAll(ctx context.Context) ([]*models.Permission
 	}
 
 	// If not in cache, get from database
-	query := `
-		SELECT id, name, description, resource, action, created_at, updated_at
+	query := fmt.Sprintf(`
+		SELECT id, name, description, resource, action, category, created_at, updated_at
 		FROM permissions
-		ORDER BY resource, action
-	`
+		ORDER BY %s
+	`, orderByClause(sort, models.PermissionSortFields, "resource, action, id"))
 
 	rows, err := r.db.QueryxContext(ctx, query)
 	if err != nil {
@@ -182,8 +209,8 @@ func (r *PermissionRepository) Update(ctx context.Context, permission *models.Pe
 
 	query := `
 		UPDATE permissions
-		SET name = $1, description = $2, resource = $3, action = $4, updated_at = $5
-		WHERE id = $6
+		SET name = $1, description = $2, resource = $3, action = $4, category = $5, updated_at = $6
+		WHERE id = $7
 	`
 
 	_, err := r.db.ExecContext(
@@ -193,6 +220,7 @@ func (r *PermissionRepository) Update(ctx context.Context, permission *models.Pe
 		permission.Description,
 		permission.Resource,
 		permission.Action,
+		permission.Category,
 		permission.UpdatedAt,
 		permission.ID,
 	)
@@ -231,8 +259,12 @@ func (r *PermissionRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
-// GetByResource retrieves all permissions for a specific resource
+// GetByResource retrieves all permissions for a specific resource, which may not be blank
 func (r *PermissionRepository) GetByResource(ctx context.Context, resource string) ([]*models.Permission, error) {
+	if err := models.CheckPermissionLookup("resource", resource); err != nil {
+		return nil, err
+	}
+
 	cacheKey := fmt.Sprintf("permissions:resource:%s", resource)
 
 	// Try to get from cache first
@@ -248,10 +280,10 @@ func (r *PermissionRepository) GetByResource(ctx context.Context, resource strin
 
 	// If not in cache, get from database
 	query := `
-		SELECT id, name, description, resource, action, created_at, updated_at
+		SELECT id, name, description, resource, action, category, created_at, updated_at
 		FROM permissions
 		WHERE resource = $1
-		ORDER BY action
+		ORDER BY action, id
 	`
 
 	rows, err := r.db.QueryxContext(ctx, query, resource)
@@ -277,6 +309,82 @@ func (r *PermissionRepository) GetByResource(ctx context.Context, resource strin
 	return permissions, nil
 }
 
+// GetByAction retrieves the permissions for an action across every resource, which may not be blank
+func (r *PermissionRepository) GetByAction(ctx context.Context, action string) ([]*models.Permission, error) {
+	if err := models.CheckPermissionLookup("action", action); err != nil {
+		return nil, err
+	}
+
+	cacheKey := fmt.Sprintf("permissions:action:%s", action)
+
+	// Try to get from cache first
+	var permissions []*models.Permission
+	found, err := r.cache.Get(cacheKey, &permissions)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get permissions from cache")
+	}
+
+	if found {
+		return permissions, nil
+	}
+
+	// If not in cache, get from database
+	query := `
+		SELECT id, name, description, resource, action, category, created_at, updated_at
+		FROM permissions
+		WHERE action = $1
+		ORDER BY resource, id
+	`
+
+	rows, err := r.db.QueryxContext(ctx, query, action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permissions: %w", err)
+	}
+	defer rows.Close()
+
+	permissions = make([]*models.Permission, 0)
+	for rows.Next() {
+		var permission models.Permission
+		if err := rows.StructScan(&permission); err != nil {
+			return nil, fmt.Errorf("failed to scan permission: %w", err)
+		}
+		permissions = append(permissions, &permission)
+	}
+
+	// Cache the permissions
+	if err := r.cache.Set(cacheKey, permissions); err != nil {
+		log.Debug().Err(err).Msg("Failed to cache permissions")
+	}
+
+	return permissions, nil
+}
+
+// GetUsageCounts counts the roles holding each of the permissions in a single query. Unused
+// permissions are absent from the result. Counts are not cached, as role changes would stale them.
+func (r *PermissionRepository) GetUsageCounts(ctx context.Context, permissionIDs []uuid.UUID) (map[uuid.UUID]int, error) {
+	query := `
+		SELECT permission_id, COUNT(*) AS role_count
+		FROM role_permissions
+		WHERE permission_id = ANY($1)
+		GROUP BY permission_id
+	`
+
+	var rows []struct {
+		PermissionID uuid.UUID `db:"permission_id"`
+		RoleCount    int       `db:"role_count"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, query, pq.Array(permissionIDs)); err != nil {
+		return nil, fmt.Errorf("failed to count permission usage: %w", err)
+	}
+
+	counts := make(map[uuid.UUID]int, len(rows))
+	for _, row := range rows {
+		counts[row.PermissionID] = row.RoleCount
+	}
+
+	return counts, nil
+}
+
 // invalidatePermissionCache clears all permission-related cache
 func (r *PermissionRepository) invalidatePermissionCache() {
 	if err := r.cache.DeleteByPattern("permission:*"); err != nil {
@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/chats/go-user-api/internal/database"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestPermissionRepository wires a PermissionRepository to sqlmock and an in-memory Redis
+func newTestPermissionRepository(t *testing.T) (*PermissionRepository, sqlmock.Sqlmock) {
+	t.Helper()
+
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { mockDB.Close() })
+
+	redisClient, _ := newTestRedisClient(t)
+
+	db := &database.PostgresDB{DB: sqlx.NewDb(mockDB, "postgres")}
+
+	return NewPermissionRepository(db, redisClient), mock
+}
+
+func TestPermissionRepository_GetUsageCounts(t *testing.T) {
+	repo, mock := newTestPermissionRepository(t)
+	shared, single, unused := uuid.New(), uuid.New(), uuid.New()
+
+	// One grouped query for every permission; unused permissions have no row
+	mock.ExpectQuery(regexp.QuoteMeta("FROM role_permissions WHERE permission_id = ANY($1) GROUP BY permission_id")).
+		WillReturnRows(sqlmock.NewRows([]string{"permission_id", "role_count"}).
+			AddRow(shared, 3).
+			AddRow(single, 1))
+
+	counts, err := repo.GetUsageCounts(context.Background(), []uuid.UUID{shared, single, unused})
+
+	require.NoError(t, err)
+	assert.Equal(t, map[uuid.UUID]int{shared: 3, single: 1}, counts)
+	assert.Zero(t, counts[unused])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPermissionRepository_BlankLookup(t *testing.T) {
+	t.Run("Blank resource or action rejected without a query", func(t *testing.T) {
+		repo, mock := newTestPermissionRepository(t)
+
+		_, err := repo.GetByResource(context.Background(), "")
+		assert.ErrorIs(t, err, models.ErrBlankPermissionLookup)
+
+		_, err = repo.GetByResource(context.Background(), "  ")
+		assert.ErrorIs(t, err, models.ErrBlankPermissionLookup)
+
+		_, err = repo.GetByResourceAction(context.Background(), "user", " ")
+		assert.ErrorIs(t, err, models.ErrBlankPermissionLookup)
+		assert.ErrorContains(t, err, "action is blank")
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Resource lookup", func(t *testing.T) {
+		repo, mock := newTestPermissionRepository(t)
+		mock.ExpectQuery(regexp.QuoteMeta("WHERE resource = $1")).
+			WithArgs("user").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "resource", "action"}).
+				AddRow(uuid.New(), "user:read", "user", "read"))
+
+		permissions, err := repo.GetByResource(context.Background(), "user")
+
+		require.NoError(t, err)
+		require.Len(t, permissions, 1)
+		assert.Equal(t, "user:read", permissions[0].Name)
+	})
+}
+
+func TestPermissionRepository_GetByAction(t *testing.T) {
+	repo, mock := newTestPermissionRepository(t)
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE action = $1")).
+		WithArgs("read").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "resource", "action"}).
+			AddRow(uuid.New(), "role:read", "role", "read").
+			AddRow(uuid.New(), "user:read", "user", "read"))
+
+	permissions, err := repo.GetByAction(context.Background(), "read")
+
+	require.NoError(t, err)
+	require.Len(t, permissions, 2)
+	assert.Equal(t, "role", permissions[0].Resource)
+	assert.Equal(t, "user", permissions[1].Resource)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = repo.GetByAction(context.Background(), " ")
+	assert.ErrorIs(t, err, models.ErrBlankPermissionLookup)
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/chats/go-user-api/internal/database"
 	"github.com/chats/go-user-api/internal/models"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/rs/zerolog/log"
 )
 
@@ -46,6 +47,9 @@ func (r *RoleRepository) Create(ctx context.Context, role *models.Role) error {
 	).Scan(&role.ID, &role.CreatedAt, &role.UpdatedAt)
 
 	if err != nil {
+		if constraint, _ := database.UniqueViolation(err); constraint == database.RolesNameKey {
+			return models.ErrRoleNameExists
+		}
 		return fmt.Errorf("failed to create role: %w", err)
 	}
 
@@ -85,7 +89,7 @@ func (r *RoleRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Rol
 
 	if err := r.db.GetContext(ctx, &role, query, id); err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("role not found")
+			return nil, models.ErrRoleNotFound
 		}
 		return nil, fmt.Errorf("failed to get role: %w", err)
 	}
@@ -155,9 +159,9 @@ func (r *RoleRepository) GetByName(ctx context.Context, name string) (*models.Ro
 	return &role, nil
 }
 
-// GetAll retrieves all roles
-func (r *RoleRepository) GetAll(ctx context.Context) ([]*models.Role, error) {
-	cacheKey := "roles:all"
+// GetAll retrieves all roles ordered by sort (name by default), loading their permissions in one batch when includePermissions is set
+func (r *RoleRepository) GetAll(ctx context.Context, includePermissions bool, sort models.SortOptions) ([]*models.Role, error) {
+	cacheKey := sortCacheKey("roles:all", sort)
 
 	// Try to get from cache first
 	var roles []*models.Role
@@ -166,51 +170,41 @@ func (r *RoleRepository) GetAll(ctx context.Context) ([]*models.Role, error) {
 		log.Debug().Err(err).Msg("Failed to get roles from cache")
 	}
 
-	if found {
-		// Get permissions for each role
-		for i, role := range roles {
-			permissions, err := r.GetRolePermissions(ctx, role.ID)
-			if err != nil {
-				return nil, err
-			}
-			roles[i].Permissions = permissions
+	if !found {
+		// If not in cache, get from database
+		query := fmt.Sprintf(`
+			SELECT id, name, description, created_at, updated_at
+			FROM roles
+			ORDER BY %s
+		`, orderByClause(sort, models.RoleSortFields, "name, id"))
+
+		roles = make([]*models.Role, 0)
+		if err := r.db.SelectContext(ctx, &roles, query); err != nil {
+			return nil, fmt.Errorf("failed to get roles: %w", err)
 		}
-		return roles, nil
-	}
 
-	// If not in cache, get from database
-	query := `
-		SELECT id, name, description, created_at, updated_at
-		FROM roles
-		ORDER BY name
-	`
-
-	rows, err := r.db.QueryxContext(ctx, query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get roles: %w", err)
+		// Cache the roles; permissions are always loaded fresh
+		if err := r.cache.Set(cacheKey, roles); err != nil {
+			log.Debug().Err(err).Msg("Failed to cache roles")
+		}
 	}
-	defer rows.Close()
 
-	roles = make([]*models.Role, 0)
-	for rows.Next() {
-		var role models.Role
-		if err := rows.StructScan(&role); err != nil {
-			return nil, fmt.Errorf("failed to scan role: %w", err)
-		}
+	if !includePermissions || len(roles) == 0 {
+		return roles, nil
+	}
 
-		// Get permissions for the role
-		permissions, err := r.GetRolePermissions(ctx, role.ID)
-		if err != nil {
-			return nil, err
-		}
-		role.Permissions = permissions
+	roleIDs := make([]uuid.UUID, len(roles))
+	for i, role := range roles {
+		roleIDs[i] = role.ID
+	}
 
-		roles = append(roles, &role)
+	permissionsByRole, err := r.GetPermissionsByRoleIDs(ctx, roleIDs)
+	if err != nil {
+		return nil, err
 	}
 
-	// Cache the roles
-	if err := r.cache.Set(cacheKey, roles); err != nil {
-		log.Debug().Err(err).Msg("Failed to cache roles")
+	for _, role := range roles {
+		role.Permissions = permissionsByRole[role.ID]
 	}
 
 	return roles, nil
@@ -241,6 +235,8 @@ func (r *RoleRepository) Update(ctx context.Context, role *models.Role) error {
 
 	// Clear role cache
 	r.invalidateRoleCache()
+	// Cached users embed their roles
+	r.invalidateUserCache()
 
 	return nil
 }
@@ -265,6 +261,8 @@ func (r *RoleRepository) Delete(ctx context.Context, id uuid.UUID) error {
 
 	// Clear role cache
 	r.invalidateRoleCache()
+	// Cached users embed their roles
+	r.invalidateUserCache()
 
 	return nil
 }
@@ -284,11 +282,11 @@ func (r *RoleRepository) AssignPermissionsToRole(ctx context.Context, roleID uui
 		return fmt.Errorf("failed to remove existing permissions: %w", err)
 	}
 
-	// Assign new permissions
+	// Assign new permissions; duplicates, including concurrent assignments, are skipped
 	for _, permissionID := range permissionIDs {
 		_, err = tx.ExecContext(
 			ctx,
-			"INSERT INTO role_permissions (role_id, permission_id) VALUES ($1, $2)",
+			"INSERT INTO role_permissions (role_id, permission_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
 			roleID,
 			permissionID,
 		)
@@ -314,7 +312,7 @@ func (r *RoleRepository) AssignPermissionsToRole(ctx context.Context, roleID uui
 // GetRolePermissions retrieves all permissions for a role
 func (r *RoleRepository) GetRolePermissions(ctx context.Context, roleID uuid.UUID) ([]models.Permission, error) {
 	query := `
-		SELECT p.id, p.name, p.description, p.resource, p.action, p.created_at, p.updated_at
+		SELECT p.id, p.name, p.description, p.resource, p.action, p.category, p.created_at, p.updated_at
 		FROM permissions p
 		JOIN role_permissions rp ON p.id = rp.permission_id
 		WHERE rp.role_id = $1
@@ -329,6 +327,52 @@ func (r *RoleRepository) GetRolePermissions(ctx context.Context, roleID uuid.UUI
 	return permissions, nil
 }
 
+// GetCounts counts the users holding the role, leaving out deleted users, and the permissions it
+// grants in a single query. Counts are not cached, as assignments would stale them.
+func (r *RoleRepository) GetCounts(ctx context.Context, roleID uuid.UUID) (int, int, error) {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM user_roles ur JOIN users u ON u.id = ur.user_id
+				WHERE ur.role_id = $1 AND u.deleted_at IS NULL) AS user_count,
+			(SELECT COUNT(*) FROM role_permissions WHERE role_id = $1) AS permission_count
+	`
+
+	var counts struct {
+		UserCount       int `db:"user_count"`
+		PermissionCount int `db:"permission_count"`
+	}
+	if err := r.db.GetContext(ctx, &counts, query, roleID); err != nil {
+		return 0, 0, fmt.Errorf("failed to count role users and permissions: %w", err)
+	}
+
+	return counts.UserCount, counts.PermissionCount, nil
+}
+
+// GetPermissionsByRoleIDs retrieves the permissions of several roles in a single query
+func (r *RoleRepository) GetPermissionsByRoleIDs(ctx context.Context, roleIDs []uuid.UUID) (map[uuid.UUID][]models.Permission, error) {
+	query := `
+		SELECT rp.role_id, p.id, p.name, p.description, p.resource, p.action, p.category, p.created_at, p.updated_at
+		FROM permissions p
+		JOIN role_permissions rp ON p.id = rp.permission_id
+		WHERE rp.role_id = ANY($1)
+	`
+
+	var rows []struct {
+		RoleID uuid.UUID `db:"role_id"`
+		models.Permission
+	}
+	if err := r.db.SelectContext(ctx, &rows, query, pq.Array(roleIDs)); err != nil {
+		return nil, fmt.Errorf("failed to get role permissions: %w", err)
+	}
+
+	permissionsByRole := make(map[uuid.UUID][]models.Permission, len(roleIDs))
+	for _, row := range rows {
+		permissionsByRole[row.RoleID] = append(permissionsByRole[row.RoleID], row.Permission)
+	}
+
+	return permissionsByRole, nil
+}
+
 // invalidateRoleCache clears all role-related cache
 func (r *RoleRepository) invalidateRoleCache() {
 	if err := r.cache.DeleteByPattern("role:*"); err != nil {
@@ -345,4 +389,27 @@ func (r *RoleRepository) invalidateUserPermissionCache() {
 	if err := r.cache.DeleteByPattern("user:permissions:*"); err != nil {
 		log.Debug().Err(err).Msg("Failed to invalidate user permission cache")
 	}
+
+	// Permissions resolved from role sets that may include the changed role
+	if err := r.cache.DeleteByPattern(roleSetPermissionsPrefix + "*"); err != nil {
+		log.Debug().Err(err).Msg("Failed to invalidate role set permission cache")
+	}
+}
+
+// InvalidatePermissionCache clears cached roles and resolved permissions after role permissions
+// changed outside the repository, e.g. in a transaction
+func (r *RoleRepository) InvalidatePermissionCache() {
+	r.invalidateRoleCache()
+	r.invalidateUserPermissionCache()
+}
+
+// invalidateUserCache clears cached users, which embed their roles
+func (r *RoleRepository) invalidateUserCache() {
+	if err := r.cache.DeleteByPattern("user:*"); err != nil {
+		log.Debug().Err(err).Msg("Failed to invalidate user cache")
+	}
+
+	if err := r.cache.DeleteByPattern("users:*"); err != nil {
+		log.Debug().Err(err).Msg("Failed to invalidate users cache")
+	}
 }
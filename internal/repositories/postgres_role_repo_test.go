@@ -0,0 +1,166 @@
+package repositories
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/chats/go-user-api/internal/database"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var rolePermissionColumns = []string{"role_id", "id", "name", "description", "resource", "action", "created_at", "updated_at"}
+
+// newTestRoleRepository wires a RoleRepository to sqlmock and an in-memory Redis
+func newTestRoleRepository(t *testing.T) (*RoleRepository, sqlmock.Sqlmock) {
+	t.Helper()
+
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { mockDB.Close() })
+
+	redisClient, _ := newTestRedisClient(t)
+
+	db := &database.PostgresDB{DB: sqlx.NewDb(mockDB, "postgres")}
+
+	return NewRoleRepository(db, redisClient), mock
+}
+
+// expectRoleList expects the role list query and a single batched permission query,
+// granting every role one read permission
+func expectRoleList(mock sqlmock.Sqlmock, count int, includePermissions bool) []uuid.UUID {
+	now := time.Now()
+	roleIDs := make([]uuid.UUID, count)
+	roleRows := sqlmock.NewRows(roleColumns)
+	permissionRows := sqlmock.NewRows(rolePermissionColumns)
+	for i := range roleIDs {
+		roleIDs[i] = uuid.New()
+		roleRows.AddRow(roleIDs[i], "role-"+roleIDs[i].String(), "", now, now)
+		permissionRows.AddRow(roleIDs[i], uuid.New(), "user:read", "", "user", "read", now, now)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM roles")).WillReturnRows(roleRows)
+	if includePermissions {
+		mock.ExpectQuery(regexp.QuoteMeta("WHERE rp.role_id = ANY($1)")).
+			WithArgs(sqlmock.AnyArg()).
+			WillReturnRows(permissionRows)
+	}
+
+	return roleIDs
+}
+
+func TestRoleRepository_GetAll_BatchesPermissionLoading(t *testing.T) {
+	for _, count := range []int{1, 5, 20} {
+		repo, mock := newTestRoleRepository(t)
+
+		// One query for the roles and one for all of their permissions,
+		// however many roles there are
+		expectRoleList(mock, count, true)
+
+		roles, err := repo.GetAll(context.Background(), true, models.SortOptions{})
+		require.NoError(t, err)
+		require.Len(t, roles, count)
+		for _, role := range roles {
+			require.Len(t, role.Permissions, 1)
+			assert.Equal(t, "user:read", role.Permissions[0].Name)
+		}
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestRoleRepository_GetAll_ExcludesPermissions(t *testing.T) {
+	repo, mock := newTestRoleRepository(t)
+
+	expectRoleList(mock, 3, false)
+
+	roles, err := repo.GetAll(context.Background(), false, models.SortOptions{})
+	require.NoError(t, err)
+	require.Len(t, roles, 3)
+	for _, role := range roles {
+		assert.Nil(t, role.Permissions)
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRoleRepository_GetAll_CacheHitLoadsPermissionsOnce(t *testing.T) {
+	repo, mock := newTestRoleRepository(t)
+	ctx := context.Background()
+
+	roleIDs := expectRoleList(mock, 3, true)
+	_, err := repo.GetAll(ctx, true, models.SortOptions{})
+	require.NoError(t, err)
+
+	// Cached roles still need their permissions, in a single query
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE rp.role_id = ANY($1)")).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(rolePermissionColumns).
+			AddRow(roleIDs[0], uuid.New(), "user:write", "", "user", "write", time.Now(), time.Now()))
+
+	roles, err := repo.GetAll(ctx, true, models.SortOptions{})
+	require.NoError(t, err)
+	require.Len(t, roles, 3)
+	assert.Len(t, roles[0].Permissions, 1)
+	assert.Empty(t, roles[1].Permissions)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRoleRepository_AssignPermissionsToRole_DuplicatePermissions(t *testing.T) {
+	repo, mock := newTestRoleRepository(t)
+	roleID := uuid.New()
+	permissionID := uuid.New()
+
+	// The second insert of the same pair conflicts and affects no rows instead of failing
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM role_permissions WHERE role_id = $1")).
+		WithArgs(roleID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO role_permissions (role_id, permission_id) VALUES ($1, $2) ON CONFLICT DO NOTHING")).
+		WithArgs(roleID, permissionID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO role_permissions (role_id, permission_id) VALUES ($1, $2) ON CONFLICT DO NOTHING")).
+		WithArgs(roleID, permissionID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	assert.NoError(t, repo.AssignPermissionsToRole(context.Background(), roleID, []uuid.UUID{permissionID, permissionID}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRoleRepository_GetCounts(t *testing.T) {
+	tests := []struct {
+		name            string
+		userCount       int
+		permissionCount int
+	}{
+		{name: "Role with several users and permissions", userCount: 3, permissionCount: 4},
+		{name: "Empty role", userCount: 0, permissionCount: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, mock := newTestRoleRepository(t)
+			roleID := uuid.New()
+
+			// One query counts both, without loading the users or permissions
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM user_roles")).
+				WithArgs(roleID).
+				WillReturnRows(sqlmock.NewRows([]string{"user_count", "permission_count"}).AddRow(tt.userCount, tt.permissionCount))
+
+			userCount, permissionCount, err := repo.GetCounts(context.Background(), roleID)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.userCount, userCount)
+			assert.Equal(t, tt.permissionCount, permissionCount)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
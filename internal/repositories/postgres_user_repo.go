@@ -4,12 +4,14 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/chats/go-user-api/internal/cache"
 	"github.com/chats/go-user-api/internal/database"
 	"github.com/chats/go-user-api/internal/models"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/rs/zerolog/log"
 )
 
@@ -50,6 +52,12 @@ func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
 	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
+		switch constraint, _ := database.UniqueViolation(err); constraint {
+		case database.UsersUsernameKey:
+			return models.ErrUsernameExists
+		case database.UsersEmailKey:
+			return models.ErrEmailExists
+		}
 		return fmt.Errorf("failed to create user: %w", err)
 	}
 
@@ -70,26 +78,21 @@ func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Use
 		log.Debug().Err(err).Msg("Failed to get user from cache")
 	}
 
+	// Cached users already include their roles
 	if found {
-		// Get roles for the user
-		roles, err := r.GetUserRoles(ctx, id)
-		if err != nil {
-			return nil, err
-		}
-		user.Roles = roles
 		return &user, nil
 	}
 
 	// If not in cache, get from database
 	query := `
-		SELECT id, username, email, password, first_name, last_name, is_active, created_at, updated_at
+		SELECT id, username, email, password, first_name, last_name, is_active, last_login_at, created_at, updated_at, password_changed_at, deleted_at
 		FROM users
 		WHERE id = $1
 	`
 
 	if err := r.db.GetContext(ctx, &user, query, id); err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+			return nil, models.ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
@@ -109,6 +112,32 @@ func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Use
 	return &user, nil
 }
 
+// GetByIDs retrieves several users with their roles; unknown IDs are skipped
+func (r *UserRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*models.User, error) {
+	return getUsersByIDs(r.cache, ids, func(missing []uuid.UUID) ([]*models.User, error) {
+		query := `
+			SELECT id, username, email, password, first_name, last_name, is_active, last_login_at, created_at, updated_at, password_changed_at, deleted_at
+			FROM users
+			WHERE id = ANY($1)
+		`
+
+		var users []*models.User
+		if err := r.db.SelectContext(ctx, &users, query, pq.Array(missing)); err != nil {
+			return nil, fmt.Errorf("failed to get users: %w", err)
+		}
+
+		rolesByUser, err := r.getRolesByUserIDs(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			user.Roles = rolesByUser[user.ID]
+		}
+
+		return users, nil
+	})
+}
+
 // GetByUsername retrieves a user by username
 func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	cacheKey := fmt.Sprintf("user:username:%s", username)
@@ -132,14 +161,14 @@ func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*m
 
 	// If not in cache, get from database
 	query := `
-		SELECT id, username, email, password, first_name, last_name, is_active, created_at, updated_at
+		SELECT id, username, email, password, first_name, last_name, is_active, last_login_at, created_at, updated_at, password_changed_at, deleted_at
 		FROM users
-		WHERE username = $1
+		WHERE username = $1 AND deleted_at IS NULL
 	`
 
 	if err := r.db.GetContext(ctx, &user, query, username); err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+			return nil, models.ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
@@ -152,16 +181,14 @@ func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*m
 	user.Roles = roles
 
 	// Cache the user
-	if err := r.cache.Set(cacheKey, user); err != nil {
-		log.Debug().Err(err).Msg("Failed to cache user")
-	}
+	cacheUserByUsername(r.cache, user)
 
 	return &user, nil
 }
 
-// GetAll retrieves all users with pagination
-func (r *UserRepository) GetAll(ctx context.Context, limit, offset int) ([]*models.User, error) {
-	cacheKey := fmt.Sprintf("users:limit:%d:offset:%d", limit, offset)
+// GetAll retrieves all users with pagination, ordered by sort or newest first by default
+func (r *UserRepository) GetAll(ctx context.Context, limit, offset int, sort models.SortOptions) ([]*models.User, error) {
+	cacheKey := sortCacheKey(fmt.Sprintf("users:limit:%d:offset:%d", limit, offset), sort)
 
 	// Try to get from cache first
 	var users []*models.User
@@ -170,25 +197,19 @@ func (r *UserRepository) GetAll(ctx context.Context, limit, offset int) ([]*mode
 		log.Debug().Err(err).Msg("Failed to get users from cache")
 	}
 
+	// Cached users already include their roles
 	if found {
-		// Get roles for each user
-		for i, user := range users {
-			roles, err := r.GetUserRoles(ctx, user.ID)
-			if err != nil {
-				return nil, err
-			}
-			users[i].Roles = roles
-		}
 		return users, nil
 	}
 
 	// If not in cache, get from database
-	query := `
-		SELECT id, username, email, password, first_name, last_name, is_active, created_at, updated_at
+	query := fmt.Sprintf(`
+		SELECT id, username, email, password, first_name, last_name, is_active, last_login_at, created_at, updated_at, password_changed_at, deleted_at
 		FROM users
-		ORDER BY created_at DESC
+		WHERE deleted_at IS NULL
+		ORDER BY %s
 		LIMIT $1 OFFSET $2
-	`
+	`, orderByClause(sort, models.UserSortFields, "created_at DESC, id DESC"))
 
 	rows, err := r.db.QueryxContext(ctx, query, limit, offset)
 	if err != nil {
@@ -197,22 +218,31 @@ func (r *UserRepository) GetAll(ctx context.Context, limit, offset int) ([]*mode
 	defer rows.Close()
 
 	users = make([]*models.User, 0)
+	warnings := make([]models.Warning, 0)
 	for rows.Next() {
 		var user models.User
 		if err := rows.StructScan(&user); err != nil {
 			return nil, fmt.Errorf("failed to scan user: %w", err)
 		}
 
-		// Get roles for the user
+		// Get roles for the user; a failed lookup leaves the user without roles and is reported
 		roles, err := r.GetUserRoles(ctx, user.ID)
 		if err != nil {
-			return nil, err
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			warnings = append(warnings, models.Warning{ID: user.ID.String(), Message: err.Error()})
 		}
 		user.Roles = roles
 
 		users = append(users, &user)
 	}
 
+	// A partial page is not cached, so the next request retries it
+	if len(warnings) > 0 {
+		return users, &models.PartialResultError{Warnings: warnings}
+	}
+
 	// Cache the users
 	if err := r.cache.Set(cacheKey, users); err != nil {
 		log.Debug().Err(err).Msg("Failed to cache users")
@@ -257,7 +287,7 @@ func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
 func (r *UserRepository) UpdatePassword(ctx context.Context, userID uuid.UUID, hashedPassword string) error {
 	query := `
 		UPDATE users
-		SET password = $1, updated_at = $2
+		SET password = $1, updated_at = $2, password_changed_at = $2
 		WHERE id = $3
 	`
 
@@ -279,26 +309,81 @@ func (r *UserRepository) UpdatePassword(ctx context.Context, userID uuid.UUID, h
 	return nil
 }
 
-// Delete deletes a user from the database
+// RehashPassword replaces the stored hash of an unchanged password, e.g. after a pepper rotation,
+// so the password keeps its age. It only writes while the stored hash is still verifiedHash, so a
+// password changed since it was verified is kept; false reports that nothing was replaced.
+func (r *UserRepository) RehashPassword(ctx context.Context, userID uuid.UUID, verifiedHash, hashedPassword string) (bool, error) {
+	query := `UPDATE users SET password = $1 WHERE id = $2 AND password = $3`
+
+	result, err := r.db.ExecContext(ctx, query, hashedPassword, userID, verifiedHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to rehash password: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	// Clear user cache
+	r.invalidateUserCache()
+
+	return true, nil
+}
+
+// userOwnedRowQueries remove rows that belong to a user; they run before the user row is deleted
+var userOwnedRowQueries = []string{
+	"DELETE FROM user_roles WHERE user_id = $1",
+	"DELETE FROM api_keys WHERE created_by = $1",
+}
+
+// Delete deletes a user and every row owned by the user in a single transaction
 func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM users WHERE id = $1`
+	// Start a transaction
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
 
-	result, err := r.db.ExecContext(ctx, query, id)
+	// Remove rows owned by the user
+	for _, query := range userOwnedRowQueries {
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to delete user-owned rows: %w", err)
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id)
 	if err != nil {
+		tx.Rollback()
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
+		tx.Rollback()
 		return fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
 	if rowsAffected == 0 {
+		tx.Rollback()
 		return fmt.Errorf("user not found")
 	}
 
-	// Clear user cache
+	// Commit the transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Clear user cache, and cached API keys the user created
 	r.invalidateUserCache()
+	if err := r.cache.DeleteByPattern("apikey:*"); err != nil {
+		log.Debug().Err(err).Msg("Failed to invalidate API key cache")
+	}
 
 	return nil
 }
@@ -311,6 +396,19 @@ func (r *UserRepository) AssignRolesToUser(ctx context.Context, userID uuid.UUID
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 
+	// Check every role exists in one lookup rather than failing on a foreign key
+	if len(roleIDs) > 0 {
+		existing := make([]uuid.UUID, 0, len(roleIDs))
+		if err := tx.SelectContext(ctx, &existing, "SELECT id FROM roles WHERE id = ANY($1)", pq.Array(roleIDs)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to look up roles: %w", err)
+		}
+		if err := models.MissingRolesError(roleIDs, existing); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
 	// Remove existing roles
 	_, err = tx.ExecContext(ctx, "DELETE FROM user_roles WHERE user_id = $1", userID)
 	if err != nil {
@@ -318,11 +416,11 @@ func (r *UserRepository) AssignRolesToUser(ctx context.Context, userID uuid.UUID
 		return fmt.Errorf("failed to remove existing roles: %w", err)
 	}
 
-	// Assign new roles
+	// Assign new roles; duplicates, including concurrent assignments, are skipped
 	for _, roleID := range roleIDs {
 		_, err = tx.ExecContext(
 			ctx,
-			"INSERT INTO user_roles (user_id, role_id) VALUES ($1, $2)",
+			"INSERT INTO user_roles (user_id, role_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
 			userID,
 			roleID,
 		)
@@ -332,13 +430,20 @@ func (r *UserRepository) AssignRolesToUser(ctx context.Context, userID uuid.UUID
 		}
 	}
 
+	// Record the change so tokens carrying the old roles can be rejected
+	_, err = tx.ExecContext(ctx, "UPDATE users SET roles_changed_at = NOW() WHERE id = $1", userID)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to record role change: %w", err)
+	}
+
 	// Commit the transaction
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	// Clear user cache
-	r.invalidateUserCache()
+	// Clear the cached user, whose roles are part of the cached entry
+	r.InvalidateUser(userID)
 
 	return nil
 }
@@ -361,22 +466,70 @@ func (r *UserRepository) GetUserRoles(ctx context.Context, userID uuid.UUID) ([]
 	return roles, nil
 }
 
-// GetUserPermissions retrieves all permissions for a user
+// getRolesByUserIDs retrieves the roles of several users in a single query
+func (r *UserRepository) getRolesByUserIDs(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID][]models.Role, error) {
+	query := `
+		SELECT ur.user_id, r.id, r.name, r.description, r.created_at, r.updated_at
+		FROM roles r
+		JOIN user_roles ur ON r.id = ur.role_id
+		WHERE ur.user_id = ANY($1)
+	`
+
+	var rows []struct {
+		UserID uuid.UUID `db:"user_id"`
+		models.Role
+	}
+	if err := r.db.SelectContext(ctx, &rows, query, pq.Array(userIDs)); err != nil {
+		return nil, fmt.Errorf("failed to get user roles: %w", err)
+	}
+
+	rolesByUser := make(map[uuid.UUID][]models.Role, len(userIDs))
+	for _, row := range rows {
+		rolesByUser[row.UserID] = append(rolesByUser[row.UserID], row.Role)
+	}
+
+	return rolesByUser, nil
+}
+
+// GetUserPermissions retrieves all permissions for a user. The permissions are cached per role set,
+// so users holding the same roles share one resolution.
 func (r *UserRepository) GetUserPermissions(ctx context.Context, userID uuid.UUID) ([]models.Permission, error) {
+	var roleIDs []uuid.UUID
+	if err := r.db.SelectContext(ctx, &roleIDs, `SELECT role_id FROM user_roles WHERE user_id = $1`, userID); err != nil {
+		return nil, fmt.Errorf("failed to get user roles: %w", err)
+	}
+
+	cacheKey := roleSetCacheKey(roleIDs)
+
+	// Try to get from cache first
+	var permissions []models.Permission
+	found, err := r.cache.Get(cacheKey, &permissions)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get role set permissions from cache")
+	}
+
+	if found {
+		return permissions, nil
+	}
+
+	// If not in cache, get from database
 	query := `
-		SELECT DISTINCT p.id, p.name, p.description, p.resource, p.action, p.created_at, p.updated_at
+		SELECT DISTINCT p.id, p.name, p.description, p.resource, p.action, p.category, p.created_at, p.updated_at
 		FROM permissions p
 		JOIN role_permissions rp ON p.id = rp.permission_id
-		JOIN user_roles ur ON rp.role_id = ur.role_id
-		WHERE ur.user_id = $1
+		WHERE rp.role_id = ANY($1)
 	`
 
-	var permissions []models.Permission
-	err := r.db.SelectContext(ctx, &permissions, query, userID)
-	if err != nil {
+	permissions = make([]models.Permission, 0)
+	if err := r.db.SelectContext(ctx, &permissions, query, pq.Array(roleIDs)); err != nil {
 		return nil, fmt.Errorf("failed to get user permissions: %w", err)
 	}
 
+	// Cache the permissions
+	if err := r.cache.Set(cacheKey, permissions); err != nil {
+		log.Debug().Err(err).Msg("Failed to cache role set permissions")
+	}
+
 	return permissions, nil
 }
 
@@ -417,7 +570,7 @@ func (r *UserRepository) CountUsers(ctx context.Context) (int, error) {
 	}
 
 	// If not in cache, get from database
-	query := `SELECT COUNT(*) FROM users`
+	query := `SELECT COUNT(*) FROM users WHERE deleted_at IS NULL`
 
 	if err := r.db.GetContext(ctx, &count, query); err != nil {
 		return 0, fmt.Errorf("failed to count users: %w", err)
@@ -431,6 +584,201 @@ func (r *UserRepository) CountUsers(ctx context.Context) (int, error) {
 	return count, nil
 }
 
+// UpdateLastLogin records the time of a user's most recent successful login
+func (r *UserRepository) UpdateLastLogin(ctx context.Context, userID uuid.UUID, loginAt time.Time) error {
+	query := `UPDATE users SET last_login_at = $1 WHERE id = $2`
+
+	if _, err := r.db.ExecContext(ctx, query, loginAt, userID); err != nil {
+		return fmt.Errorf("failed to update last login: %w", err)
+	}
+
+	// Clear the cached user
+	r.InvalidateUser(userID)
+
+	return nil
+}
+
+// GetInactiveUsers retrieves active users whose last login (or creation, if they never logged in) is before the cutoff
+func (r *UserRepository) GetInactiveUsers(ctx context.Context, cutoff time.Time) ([]*models.User, error) {
+	query := `
+		SELECT id, username, email, password, first_name, last_name, is_active, last_login_at, created_at, updated_at, password_changed_at, deleted_at
+		FROM users
+		WHERE is_active = true AND COALESCE(last_login_at, created_at) < $1
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.QueryxContext(ctx, query, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inactive users: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]*models.User, 0)
+	for rows.Next() {
+		var user models.User
+		if err := rows.StructScan(&user); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, &user)
+	}
+
+	// Get roles for each user
+	for _, user := range users {
+		roles, err := r.GetUserRoles(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		user.Roles = roles
+	}
+
+	return users, nil
+}
+
+// GetRecentlyActiveUserIDs retrieves the IDs of the active users who logged in most recently
+func (r *UserRepository) GetRecentlyActiveUserIDs(ctx context.Context, limit int) ([]uuid.UUID, error) {
+	query := `
+		SELECT id
+		FROM users
+		WHERE is_active = true AND last_login_at IS NOT NULL
+		ORDER BY last_login_at DESC, id DESC
+		LIMIT $1
+	`
+
+	userIDs := make([]uuid.UUID, 0)
+	if err := r.db.SelectContext(ctx, &userIDs, query, limit); err != nil {
+		return nil, fmt.Errorf("failed to get recently active users: %w", err)
+	}
+
+	return userIDs, nil
+}
+
+// likeEscaper escapes LIKE wildcards so a search query matches literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// GetUserIDsByFilter returns the IDs of users matching every criterion set on the filter, oldest first
+func (r *UserRepository) GetUserIDsByFilter(ctx context.Context, filter models.UserFilter) ([]uuid.UUID, error) {
+	// Soft-deleted users never match
+	conditions := []string{"u.deleted_at IS NULL"}
+	args := make([]interface{}, 0, 7)
+
+	if filter.Query != "" {
+		args = append(args, "%"+likeEscaper.Replace(filter.Query)+"%")
+		conditions = append(conditions, fmt.Sprintf(
+			"(u.username ILIKE $%[1]d OR u.email ILIKE $%[1]d OR u.first_name ILIKE $%[1]d OR u.last_name ILIKE $%[1]d)", len(args)))
+	}
+	if filter.RoleName != "" {
+		args = append(args, filter.RoleName)
+		conditions = append(conditions, fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM user_roles ur JOIN roles r ON r.id = ur.role_id WHERE ur.user_id = u.id AND r.name = $%d)", len(args)))
+	}
+	if filter.IsActive != nil {
+		args = append(args, *filter.IsActive)
+		conditions = append(conditions, fmt.Sprintf("u.is_active = $%d", len(args)))
+	}
+	if filter.CreatedBefore != nil {
+		args = append(args, *filter.CreatedBefore)
+		conditions = append(conditions, fmt.Sprintf("u.created_at < $%d", len(args)))
+	}
+	if filter.CreatedAfter != nil {
+		args = append(args, *filter.CreatedAfter)
+		conditions = append(conditions, fmt.Sprintf("u.created_at > $%d", len(args)))
+	}
+	switch {
+	case filter.CreatedFrom != nil && filter.CreatedTo != nil:
+		args = append(args, *filter.CreatedFrom, *filter.CreatedTo)
+		conditions = append(conditions, fmt.Sprintf("u.created_at BETWEEN $%d AND $%d", len(args)-1, len(args)))
+	case filter.CreatedFrom != nil:
+		args = append(args, *filter.CreatedFrom)
+		conditions = append(conditions, fmt.Sprintf("u.created_at >= $%d", len(args)))
+	case filter.CreatedTo != nil:
+		args = append(args, *filter.CreatedTo)
+		conditions = append(conditions, fmt.Sprintf("u.created_at <= $%d", len(args)))
+	}
+
+	query := "SELECT u.id FROM users u WHERE " + strings.Join(conditions, " AND ") + " ORDER BY u.created_at, u.id"
+
+	userIDs := make([]uuid.UUID, 0)
+	if err := r.db.SelectContext(ctx, &userIDs, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get users by filter: %w", err)
+	}
+
+	return userIDs, nil
+}
+
+// GetRolesChangedAt returns when the user's roles last changed, or the zero time if they never did
+func (r *UserRepository) GetRolesChangedAt(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	cacheKey := fmt.Sprintf("user:%s:roles_changed_at", userID.String())
+
+	// Try to get from cache first
+	var changedAt time.Time
+	found, err := r.cache.Get(cacheKey, &changedAt)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get roles changed at from cache")
+	}
+
+	if found {
+		return changedAt, nil
+	}
+
+	// If not in cache, get from database
+	var rolesChangedAt sql.NullTime
+	query := `SELECT roles_changed_at FROM users WHERE id = $1`
+	if err := r.db.GetContext(ctx, &rolesChangedAt, query, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return time.Time{}, fmt.Errorf("user not found")
+		}
+		return time.Time{}, fmt.Errorf("failed to get roles changed at: %w", err)
+	}
+
+	if rolesChangedAt.Valid {
+		changedAt = rolesChangedAt.Time
+	}
+
+	// Cache the timestamp
+	if err := r.cache.Set(cacheKey, changedAt); err != nil {
+		log.Debug().Err(err).Msg("Failed to cache roles changed at")
+	}
+
+	return changedAt, nil
+}
+
+// GetTokensRevokedAt returns when the user's tokens were last revoked, or the zero time if they never were
+func (r *UserRepository) GetTokensRevokedAt(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	cacheKey := fmt.Sprintf("user:%s:tokens_revoked_at", userID.String())
+
+	// Try to get from cache first
+	var revokedAt time.Time
+	found, err := r.cache.Get(cacheKey, &revokedAt)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get tokens revoked at from cache")
+	}
+
+	if found {
+		return revokedAt, nil
+	}
+
+	// If not in cache, get from database
+	var tokensRevokedAt sql.NullTime
+	query := `SELECT tokens_revoked_at FROM users WHERE id = $1`
+	if err := r.db.GetContext(ctx, &tokensRevokedAt, query, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return time.Time{}, fmt.Errorf("user not found")
+		}
+		return time.Time{}, fmt.Errorf("failed to get tokens revoked at: %w", err)
+	}
+
+	if tokensRevokedAt.Valid {
+		revokedAt = tokensRevokedAt.Time
+	}
+
+	// Cache the timestamp
+	if err := r.cache.Set(cacheKey, revokedAt); err != nil {
+		log.Debug().Err(err).Msg("Failed to cache tokens revoked at")
+	}
+
+	return revokedAt, nil
+}
+
 // invalidateUserCache clears all user-related cache
 func (r *UserRepository) invalidateUserCache() {
 	if err := r.cache.DeleteByPattern("user:*"); err != nil {
@@ -441,3 +789,21 @@ func (r *UserRepository) invalidateUserCache() {
 		log.Debug().Err(err).Msg("Failed to invalidate users cache")
 	}
 }
+
+// InvalidateUser clears the cached entries for a single user, including its username entry, along
+// with cached user lists
+func (r *UserRepository) InvalidateUser(userID uuid.UUID) {
+	if _, err := r.cache.Purge(userKeysFor(r.cache, userID), []string{"users:*"}); err != nil {
+		log.Debug().Err(err).Msg("Failed to invalidate user cache")
+	}
+}
+
+// InvalidateDeletedUser drops cached copies of a user deleted in a transaction, along with cached
+// API keys the user created
+func (r *UserRepository) InvalidateDeletedUser(userID uuid.UUID) {
+	r.InvalidateUser(userID)
+
+	if err := r.cache.DeleteByPattern("apikey:*"); err != nil {
+		log.Debug().Err(err).Msg("Failed to invalidate API key cache")
+	}
+}
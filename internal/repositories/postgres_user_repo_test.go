@@ -0,0 +1,603 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/cache"
+	"github.com/chats/go-user-api/internal/database"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	userColumns = []string{"id", "username", "email", "password", "first_name", "last_name", "is_active", "created_at", "updated_at"}
+	roleColumns = []string{"id", "name", "description", "created_at", "updated_at"}
+)
+
+// newTestRedisClient starts an in-memory Redis and returns a cache client connected to it
+func newTestRedisClient(t *testing.T) (*cache.RedisClient, *miniredis.Miniredis) {
+	t.Helper()
+
+	redisServer := miniredis.RunT(t)
+	redisClient, err := cache.NewRedisClient(&config.Config{
+		RedisHost:     redisServer.Host(),
+		RedisPort:     redisServer.Port(),
+		RedisCacheTTL: 60,
+	})
+	require.NoError(t, err)
+	require.True(t, redisClient.IsEnabled())
+	t.Cleanup(func() { redisClient.Close() })
+
+	return redisClient, redisServer
+}
+
+// newTestUserRepository wires a UserRepository to sqlmock and an in-memory Redis
+func newTestUserRepository(t *testing.T) (*UserRepository, sqlmock.Sqlmock, *miniredis.Miniredis) {
+	t.Helper()
+
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { mockDB.Close() })
+
+	redisClient, redisServer := newTestRedisClient(t)
+
+	db := &database.PostgresDB{DB: sqlx.NewDb(mockDB, "postgres")}
+
+	return NewUserRepository(db, redisClient), mock, redisServer
+}
+
+func expectUserRow(mock sqlmock.Sqlmock, id uuid.UUID) {
+	now := time.Now()
+	mock.ExpectQuery(regexp.QuoteMeta("FROM users")).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(id, "johndoe", "john@example.com", "hashed", "John", "Doe", true, now, now))
+}
+
+func expectUserRoles(mock sqlmock.Sqlmock, userID uuid.UUID, roleName string) {
+	now := time.Now()
+	mock.ExpectQuery(regexp.QuoteMeta("FROM roles r")).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows(roleColumns).
+			AddRow(uuid.New(), roleName, roleName+" role", now, now))
+}
+
+func TestUserRepository_GetByID_CacheHitSkipsRoleQuery(t *testing.T) {
+	repo, mock, _ := newTestUserRepository(t)
+	ctx := context.Background()
+	userID := uuid.New()
+
+	// First call populates the cache with the assembled user
+	expectUserRow(mock, userID)
+	expectUserRoles(mock, userID, "admin")
+
+	user, err := repo.GetByID(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, user.Roles, 1)
+
+	// Second call must be served entirely from cache
+	cached, err := repo.GetByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, cached.ID)
+	assert.Len(t, cached.Roles, 1)
+	assert.Equal(t, "admin", cached.Roles[0].Name)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_GetByIDs_LoadsOnlyCacheMisses(t *testing.T) {
+	repo, mock, redisServer := newTestUserRepository(t)
+	ctx := context.Background()
+	cachedID := uuid.New()
+	missingID := uuid.New()
+	unknownID := uuid.New()
+	now := time.Now()
+
+	// Populate the cache for one user
+	expectUserRow(mock, cachedID)
+	expectUserRoles(mock, cachedID, "admin")
+	_, err := repo.GetByID(ctx, cachedID)
+	require.NoError(t, err)
+
+	// Only the misses are loaded, with their roles in one query
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE id = ANY($1)")).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(missingID, "janedoe", "jane@example.com", "hashed", "Jane", "Doe", true, now, now))
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE ur.user_id = ANY($1)")).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(append([]string{"user_id"}, roleColumns...)).
+			AddRow(missingID, uuid.New(), "editor", "editor role", now, now))
+
+	users, err := repo.GetByIDs(ctx, []uuid.UUID{cachedID, unknownID, missingID, cachedID})
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	assert.Equal(t, cachedID, users[0].ID)
+	assert.Equal(t, "admin", users[0].Roles[0].Name)
+	assert.Equal(t, missingID, users[1].ID)
+	assert.Equal(t, "editor", users[1].Roles[0].Name)
+	assert.True(t, redisServer.Exists(fmt.Sprintf("user:%s", missingID)))
+	assert.False(t, redisServer.Exists(fmt.Sprintf("user:%s", unknownID)))
+
+	// Both users are now served from cache
+	cached, err := repo.GetByIDs(ctx, []uuid.UUID{missingID, cachedID})
+	require.NoError(t, err)
+	require.Len(t, cached, 2)
+	assert.Equal(t, missingID, cached[0].ID)
+	assert.Equal(t, "editor", cached[0].Roles[0].Name)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_GetAll_CacheHitSkipsRoleQuery(t *testing.T) {
+	repo, mock, _ := newTestUserRepository(t)
+	ctx := context.Background()
+	userID := uuid.New()
+	now := time.Now()
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM users")).
+		WithArgs(10, 0).
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userID, "johndoe", "john@example.com", "hashed", "John", "Doe", true, now, now))
+	expectUserRoles(mock, userID, "user")
+
+	users, err := repo.GetAll(ctx, 10, 0, models.SortOptions{})
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+
+	cached, err := repo.GetAll(ctx, 10, 0, models.SortOptions{})
+	require.NoError(t, err)
+	require.Len(t, cached, 1)
+	assert.Equal(t, "user", cached[0].Roles[0].Name)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_AssignRolesToUser_BustsUserEntry(t *testing.T) {
+	repo, mock, redisServer := newTestUserRepository(t)
+	ctx := context.Background()
+	userID := uuid.New()
+	otherID := uuid.New()
+	roleID := uuid.New()
+
+	// Populate the cache for two users
+	expectUserRow(mock, userID)
+	expectUserRoles(mock, userID, "user")
+	_, err := repo.GetByID(ctx, userID)
+	require.NoError(t, err)
+
+	expectUserRow(mock, otherID)
+	expectUserRoles(mock, otherID, "user")
+	_, err = repo.GetByID(ctx, otherID)
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM roles WHERE id = ANY($1)")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(roleID))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM user_roles")).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO user_roles")).
+		WithArgs(userID, roleID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET roles_changed_at = NOW()")).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.AssignRolesToUser(ctx, userID, []uuid.UUID{roleID}))
+
+	assert.False(t, redisServer.Exists(fmt.Sprintf("user:%s", userID)))
+	assert.True(t, redisServer.Exists(fmt.Sprintf("user:%s", otherID)))
+
+	// The next read reloads the user with the new roles
+	expectUserRow(mock, userID)
+	expectUserRoles(mock, userID, "admin")
+
+	user, err := repo.GetByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, "admin", user.Roles[0].Name)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_Create_UniqueViolation(t *testing.T) {
+	tests := []struct {
+		constraint string
+		want       error
+	}{
+		{constraint: "users_username_key", want: models.ErrUsernameExists},
+		{constraint: "users_email_key", want: models.ErrEmailExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			repo, mock, _ := newTestUserRepository(t)
+
+			mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO users")).
+				WillReturnError(&pq.Error{Code: "23505", Constraint: tt.constraint})
+
+			err := repo.Create(context.Background(), &models.User{Username: "johndoe", Email: "john@example.com"})
+
+			assert.ErrorIs(t, err, tt.want)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_AssignRolesToUser_MissingRole(t *testing.T) {
+	repo, mock, _ := newTestUserRepository(t)
+	ctx := context.Background()
+	userID := uuid.New()
+	roleID := uuid.New()
+	missingID := uuid.New()
+
+	// Nothing is removed or inserted when a role is missing
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM roles WHERE id = ANY($1)")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(roleID))
+	mock.ExpectRollback()
+
+	err := repo.AssignRolesToUser(ctx, userID, []uuid.UUID{roleID, missingID, missingID})
+
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, models.ErrRoleNotFound))
+	assert.Equal(t, "role not found: "+missingID.String(), err.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_AssignRolesToUser_DuplicateRoles(t *testing.T) {
+	repo, mock, _ := newTestUserRepository(t)
+	ctx := context.Background()
+	userID := uuid.New()
+	roleID := uuid.New()
+
+	// The second insert of the same pair conflicts and affects no rows instead of failing
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM roles WHERE id = ANY($1)")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(roleID))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM user_roles")).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO user_roles (user_id, role_id) VALUES ($1, $2) ON CONFLICT DO NOTHING")).
+		WithArgs(userID, roleID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO user_roles (user_id, role_id) VALUES ($1, $2) ON CONFLICT DO NOTHING")).
+		WithArgs(userID, roleID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET roles_changed_at = NOW()")).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, repo.AssignRolesToUser(ctx, userID, []uuid.UUID{roleID, roleID}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_Delete_CascadesUserRoles(t *testing.T) {
+	repo, mock, redisServer := newTestUserRepository(t)
+	ctx := context.Background()
+	userID := uuid.New()
+
+	// Populate the cache so the delete has something to clear
+	expectUserRow(mock, userID)
+	expectUserRoles(mock, userID, "admin")
+	_, err := repo.GetByID(ctx, userID)
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM user_roles WHERE user_id = $1")).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM api_keys WHERE created_by = $1")).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM users WHERE id = $1")).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.Delete(ctx, userID))
+
+	assert.False(t, redisServer.Exists(fmt.Sprintf("user:%s", userID)))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_Delete_RollsBackWhenUserMissing(t *testing.T) {
+	repo, mock, _ := newTestUserRepository(t)
+	ctx := context.Background()
+	userID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM user_roles WHERE user_id = $1")).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM api_keys WHERE created_by = $1")).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM users WHERE id = $1")).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err := repo.Delete(ctx, userID)
+
+	assert.EqualError(t, err, "user not found")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_InvalidateUser_ClearsUsernameEntry(t *testing.T) {
+	repo, mock, redisServer := newTestUserRepository(t)
+	ctx := context.Background()
+	userID := uuid.New()
+
+	// Cache the user by username only
+	now := time.Now()
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE username = $1")).
+		WithArgs("johndoe").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userID, "johndoe", "john@example.com", "hashed", "John", "Doe", true, now, now))
+	expectUserRoles(mock, userID, "admin")
+	_, err := repo.GetByUsername(ctx, "johndoe")
+	require.NoError(t, err)
+	require.True(t, redisServer.Exists("user:username:johndoe"))
+	require.False(t, redisServer.Exists(fmt.Sprintf("user:%s", userID)))
+
+	repo.InvalidateUser(userID)
+
+	assert.False(t, redisServer.Exists("user:username:johndoe"))
+	assert.False(t, redisServer.Exists(fmt.Sprintf("user:%s:username", userID)))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_RehashPassword(t *testing.T) {
+	query := regexp.QuoteMeta("UPDATE users SET password = $1 WHERE id = $2 AND password = $3")
+
+	t.Run("Verified hash is replaced", func(t *testing.T) {
+		repo, mock, _ := newTestUserRepository(t)
+		userID := uuid.New()
+
+		mock.ExpectExec(query).
+			WithArgs("new-hash", userID, "old-hash").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		rehashed, err := repo.RehashPassword(context.Background(), userID, "old-hash", "new-hash")
+
+		require.NoError(t, err)
+		assert.True(t, rehashed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Password changed since verification is kept", func(t *testing.T) {
+		repo, mock, _ := newTestUserRepository(t)
+		userID := uuid.New()
+
+		mock.ExpectExec(query).
+			WithArgs("new-hash", userID, "old-hash").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		rehashed, err := repo.RehashPassword(context.Background(), userID, "old-hash", "new-hash")
+
+		require.NoError(t, err)
+		assert.False(t, rehashed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUserRepository_GetRolesChangedAt(t *testing.T) {
+	repo, mock, redisServer := newTestUserRepository(t)
+	ctx := context.Background()
+	userID := uuid.New()
+	changedAt := time.Now().UTC().Truncate(time.Second)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT roles_changed_at FROM users")).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"roles_changed_at"}).AddRow(changedAt))
+
+	got, err := repo.GetRolesChangedAt(ctx, userID)
+	require.NoError(t, err)
+	assert.True(t, changedAt.Equal(got))
+
+	// Second call is served from cache
+	got, err = repo.GetRolesChangedAt(ctx, userID)
+	require.NoError(t, err)
+	assert.True(t, changedAt.Equal(got))
+	assert.True(t, redisServer.Exists(fmt.Sprintf("user:%s:roles_changed_at", userID)))
+
+	// Invalidating the user drops the cached timestamp
+	repo.InvalidateUser(userID)
+	assert.False(t, redisServer.Exists(fmt.Sprintf("user:%s:roles_changed_at", userID)))
+
+	// Users whose roles never changed report the zero time
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT roles_changed_at FROM users")).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"roles_changed_at"}).AddRow(nil))
+
+	got, err = repo.GetRolesChangedAt(ctx, userID)
+	require.NoError(t, err)
+	assert.True(t, got.IsZero())
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_GetTokensRevokedAt(t *testing.T) {
+	repo, mock, redisServer := newTestUserRepository(t)
+	ctx := context.Background()
+	userID := uuid.New()
+	revokedAt := time.Now().UTC().Truncate(time.Second)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT tokens_revoked_at FROM users")).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"tokens_revoked_at"}).AddRow(revokedAt))
+
+	got, err := repo.GetTokensRevokedAt(ctx, userID)
+	require.NoError(t, err)
+	assert.True(t, revokedAt.Equal(got))
+
+	// Second call is served from cache
+	got, err = repo.GetTokensRevokedAt(ctx, userID)
+	require.NoError(t, err)
+	assert.True(t, revokedAt.Equal(got))
+
+	// Invalidating the user drops the cached timestamp
+	repo.InvalidateUser(userID)
+	assert.False(t, redisServer.Exists(fmt.Sprintf("user:%s:tokens_revoked_at", userID)))
+
+	// Users whose tokens were never revoked report the zero time
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT tokens_revoked_at FROM users")).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"tokens_revoked_at"}).AddRow(nil))
+
+	got, err = repo.GetTokensRevokedAt(ctx, userID)
+	require.NoError(t, err)
+	assert.True(t, got.IsZero())
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_GetUserIDsByFilter(t *testing.T) {
+	repo, mock, _ := newTestUserRepository(t)
+	ctx := context.Background()
+	userID := uuid.New()
+	active := true
+	createdBefore := time.Now().UTC()
+
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"SELECT u.id FROM users u WHERE u.deleted_at IS NULL AND EXISTS (SELECT 1 FROM user_roles ur JOIN roles r ON r.id = ur.role_id WHERE ur.user_id = u.id AND r.name = $1) AND u.is_active = $2 AND u.created_at < $3 ORDER BY u.created_at, u.id")).
+		WithArgs("contractor", true, createdBefore).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(userID))
+
+	userIDs, err := repo.GetUserIDsByFilter(ctx, models.UserFilter{
+		RoleName:      "contractor",
+		IsActive:      &active,
+		CreatedBefore: &createdBefore,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{userID}, userIDs)
+
+	// Placeholders are numbered by the criteria actually set
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT u.id FROM users u WHERE u.deleted_at IS NULL AND u.created_at > $1 ORDER BY u.created_at, u.id")).
+		WithArgs(createdBefore).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	userIDs, err = repo.GetUserIDsByFilter(ctx, models.UserFilter{CreatedAfter: &createdBefore})
+	require.NoError(t, err)
+	assert.Empty(t, userIDs)
+
+	// Wildcards in the query match literally
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"SELECT u.id FROM users u WHERE u.deleted_at IS NULL AND (u.username ILIKE $1 OR u.email ILIKE $1 OR u.first_name ILIKE $1 OR u.last_name ILIKE $1) ORDER BY u.created_at, u.id")).
+		WithArgs(`%50\%\_off%`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(userID))
+
+	userIDs, err = repo.GetUserIDsByFilter(ctx, models.UserFilter{Query: "50%_off"})
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{userID}, userIDs)
+
+	// An inclusive created range uses BETWEEN, a single bound an inclusive comparison
+	createdFrom := createdBefore.AddDate(0, -1, 0)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT u.id FROM users u WHERE u.deleted_at IS NULL AND u.is_active = $1 AND u.created_at BETWEEN $2 AND $3 ORDER BY u.created_at, u.id")).
+		WithArgs(true, createdFrom, createdBefore).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(userID))
+
+	userIDs, err = repo.GetUserIDsByFilter(ctx, models.UserFilter{IsActive: &active, CreatedFrom: &createdFrom, CreatedTo: &createdBefore})
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{userID}, userIDs)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT u.id FROM users u WHERE u.deleted_at IS NULL AND u.created_at <= $1 ORDER BY u.created_at, u.id")).
+		WithArgs(createdBefore).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	userIDs, err = repo.GetUserIDsByFilter(ctx, models.UserFilter{CreatedTo: &createdBefore})
+	require.NoError(t, err)
+	assert.Empty(t, userIDs)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_GetUserPermissions_SharedByRoleSet(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { mockDB.Close() })
+
+	redisClient, _ := newTestRedisClient(t)
+	db := &database.PostgresDB{DB: sqlx.NewDb(mockDB, "postgres")}
+	userRepo := NewUserRepository(db, redisClient)
+	roleRepo := NewRoleRepository(db, redisClient)
+
+	ctx := context.Background()
+	editor, viewer := uuid.New(), uuid.New()
+	alice, bob := uuid.New(), uuid.New()
+
+	expectRoleIDs := func(userID uuid.UUID, roleIDs ...uuid.UUID) {
+		rows := sqlmock.NewRows([]string{"role_id"})
+		for _, roleID := range roleIDs {
+			rows.AddRow(roleID)
+		}
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT role_id FROM user_roles WHERE user_id = $1")).
+			WithArgs(userID).
+			WillReturnRows(rows)
+	}
+	expectPermissions := func(action string) {
+		now := time.Now()
+		mock.ExpectQuery(regexp.QuoteMeta("WHERE rp.role_id = ANY($1)")).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "resource", "action", "category", "created_at", "updated_at"}).
+				AddRow(uuid.New(), "user:"+action, "", "user", action, "", now, now))
+	}
+
+	// Alice's role set is resolved from the database
+	expectRoleIDs(alice, editor, viewer)
+	expectPermissions("read")
+	first, err := userRepo.GetUserPermissions(ctx, alice)
+	require.NoError(t, err)
+
+	// Bob holds the same roles, listed in another order, and is served Alice's entry
+	expectRoleIDs(bob, viewer, editor)
+	second, err := userRepo.GetUserPermissions(ctx, bob)
+	require.NoError(t, err)
+
+	assert.Equal(t, first[0].ID, second[0].ID)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// Changing the permissions of one of the roles invalidates the shared entry
+	permissionID := uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM role_permissions WHERE role_id = $1")).
+		WithArgs(editor).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO role_permissions (role_id, permission_id) VALUES ($1, $2)")).
+		WithArgs(editor, permissionID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	require.NoError(t, roleRepo.AssignPermissionsToRole(ctx, editor, []uuid.UUID{permissionID}))
+
+	expectRoleIDs(bob, viewer, editor)
+	expectPermissions("write")
+	third, err := userRepo.GetUserPermissions(ctx, bob)
+	require.NoError(t, err)
+
+	require.Len(t, third, 1)
+	assert.Equal(t, "user:write", third[0].Name)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRoleSetCacheKey(t *testing.T) {
+	first, second := uuid.New(), uuid.New()
+
+	assert.Equal(t, roleSetCacheKey([]uuid.UUID{first, second}), roleSetCacheKey([]uuid.UUID{second, first}))
+	assert.NotEqual(t, roleSetCacheKey([]uuid.UUID{first}), roleSetCacheKey([]uuid.UUID{first, second}))
+	assert.True(t, strings.HasPrefix(roleSetCacheKey(nil), roleSetPermissionsPrefix))
+}
@@ -89,3 +89,30 @@ func (f *RepositoryFactory) CreatePermissionRepository() (PermissionRepositoryIn
 		return nil, fmt.Errorf("unsupported database type: %s", f.cfg.DBType)
 	}
 }
+
+// CreateAPIKeyRepository creates an API key repository based on database type
+func (f *RepositoryFactory) CreateAPIKeyRepository() (APIKeyRepositoryInterface, error) {
+	switch f.cfg.DBType {
+	case "postgres":
+		// We need to cast the database to PostgresDB
+		postgresDB, ok := f.db.GetImplementation().(*database.PostgresDB)
+		if !ok {
+			return nil, fmt.Errorf("failed to cast database implementation to PostgresDB")
+		}
+		return NewAPIKeyRepository(postgresDB, f.cache), nil
+	case "mongodb":
+		// We need to cast the database to MongoDB
+		mongoDB, ok := f.db.GetImplementation().(*database.MongoDB)
+		if !ok {
+			return nil, fmt.Errorf("failed to cast database implementation to MongoDB")
+		}
+		return NewMongoAPIKeyRepository(mongoDB, f.cache), nil
+	default:
+		return nil, fmt.Errorf("unsupported database type: %s", f.cfg.DBType)
+	}
+}
+
+// CreateCacheInvalidator creates a cache invalidator; the cache keys do not depend on the database type
+func (f *RepositoryFactory) CreateCacheInvalidator() *CacheInvalidator {
+	return NewCacheInvalidator(f.cache)
+}
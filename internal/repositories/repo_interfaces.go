@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"context"
+	"time"
 
 	"github.com/chats/go-user-api/internal/models"
 	"github.com/google/uuid"
@@ -11,16 +12,26 @@ import (
 type UserRepositoryInterface interface {
 	Create(ctx context.Context, user *models.User) error
 	GetByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*models.User, error)
 	GetByUsername(ctx context.Context, username string) (*models.User, error)
-	GetAll(ctx context.Context, limit, offset int) ([]*models.User, error)
+	GetAll(ctx context.Context, limit, offset int, sort models.SortOptions) ([]*models.User, error)
 	Update(ctx context.Context, user *models.User) error
 	UpdatePassword(ctx context.Context, userID uuid.UUID, hashedPassword string) error
+	RehashPassword(ctx context.Context, userID uuid.UUID, verifiedHash, hashedPassword string) (bool, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 	GetUserRoles(ctx context.Context, userID uuid.UUID) ([]models.Role, error)
 	GetUserPermissions(ctx context.Context, userID uuid.UUID) ([]models.Permission, error)
 	AssignRolesToUser(ctx context.Context, userID uuid.UUID, roleIDs []uuid.UUID) error
 	HasPermission(ctx context.Context, userID uuid.UUID, resource, action string) (bool, error)
 	CountUsers(ctx context.Context) (int, error)
+	UpdateLastLogin(ctx context.Context, userID uuid.UUID, loginAt time.Time) error
+	GetInactiveUsers(ctx context.Context, cutoff time.Time) ([]*models.User, error)
+	GetRecentlyActiveUserIDs(ctx context.Context, limit int) ([]uuid.UUID, error)
+	GetUserIDsByFilter(ctx context.Context, filter models.UserFilter) ([]uuid.UUID, error)
+	GetRolesChangedAt(ctx context.Context, userID uuid.UUID) (time.Time, error)
+	GetTokensRevokedAt(ctx context.Context, userID uuid.UUID) (time.Time, error)
+	InvalidateUser(userID uuid.UUID)
+	InvalidateDeletedUser(userID uuid.UUID)
 }
 
 // RoleRepository defines the interface for role repository operations
@@ -28,11 +39,14 @@ type RoleRepositoryInterface interface {
 	Create(ctx context.Context, role *models.Role) error
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Role, error)
 	GetByName(ctx context.Context, name string) (*models.Role, error)
-	GetAll(ctx context.Context) ([]*models.Role, error)
+	GetAll(ctx context.Context, includePermissions bool, sort models.SortOptions) ([]*models.Role, error)
 	Update(ctx context.Context, role *models.Role) error
 	Delete(ctx context.Context, id uuid.UUID) error
 	GetRolePermissions(ctx context.Context, roleID uuid.UUID) ([]models.Permission, error)
+	GetPermissionsByRoleIDs(ctx context.Context, roleIDs []uuid.UUID) (map[uuid.UUID][]models.Permission, error)
+	GetCounts(ctx context.Context, roleID uuid.UUID) (userCount, permissionCount int, err error)
 	AssignPermissionsToRole(ctx context.Context, roleID uuid.UUID, permissionIDs []uuid.UUID) error
+	InvalidatePermissionCache()
 }
 
 // PermissionRepository defines the interface for permission repository operations
@@ -40,8 +54,21 @@ type PermissionRepositoryInterface interface {
 	Create(ctx context.Context, permission *models.Permission) error
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Permission, error)
 	GetByResourceAction(ctx context.Context, resource, action string) (*models.Permission, error)
-	GetAll(ctx context.Context) ([]*models.Permission, error)
+	ExistsByResourceAction(ctx context.Context, resource, action string) (bool, error)
+	GetAll(ctx context.Context, sort models.SortOptions) ([]*models.Permission, error)
 	GetByResource(ctx context.Context, resource string) ([]*models.Permission, error)
+	GetByAction(ctx context.Context, action string) ([]*models.Permission, error)
+	GetUsageCounts(ctx context.Context, permissionIDs []uuid.UUID) (map[uuid.UUID]int, error)
 	Update(ctx context.Context, permission *models.Permission) error
 	Delete(ctx context.Context, id uuid.UUID) error
 }
+
+// APIKeyRepositoryInterface defines the API key repository operations
+type APIKeyRepositoryInterface interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.APIKey, error)
+	GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	GetAll(ctx context.Context) ([]*models.APIKey, error)
+	GetByCreator(ctx context.Context, userID uuid.UUID) ([]*models.APIKey, error)
+	Revoke(ctx context.Context, id uuid.UUID) error
+}
@@ -0,0 +1,27 @@
+package repositories
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// roleSetPermissionsPrefix prefixes the cached permissions resolved from a set of roles. It sits under
+// "permissions:", so permission changes clear these entries along with the other permission caches.
+const roleSetPermissionsPrefix = "permissions:roleset:"
+
+// roleSetCacheKey returns the cache key of the permissions resolved from a set of roles. The IDs are
+// sorted before hashing, so every user holding the same roles shares one entry.
+func roleSetCacheKey(roleIDs []uuid.UUID) string {
+	ids := make([]string, len(roleIDs))
+	for i, id := range roleIDs {
+		ids[i] = id.String()
+	}
+	sort.Strings(ids)
+
+	sum := sha256.Sum256([]byte(strings.Join(ids, ",")))
+	return roleSetPermissionsPrefix + hex.EncodeToString(sum[:])
+}
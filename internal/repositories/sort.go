@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chats/go-user-api/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// orderByClause returns the SQL ORDER BY expression for sort, or defaultClause when no ordering was requested.
+// The field is only interpolated after it is matched against the allow-list. Rows sharing the sorted value
+// are ordered by id in the same direction, so pages never overlap or skip rows; defaultClause must end with
+// id for the same reason.
+func orderByClause(sort models.SortOptions, allowed []string, defaultClause string) string {
+	if sort.IsDefault() || !models.IsSortField(sort.Field, allowed) {
+		return defaultClause
+	}
+
+	direction := "ASC"
+	if sort.Order == models.SortDesc {
+		direction = "DESC"
+	}
+
+	return fmt.Sprintf("%s %s, id %s", sort.Field, direction, direction)
+}
+
+// sortDocument returns the MongoDB sort document for sort, or defaultSort when no ordering was requested.
+// Like orderByClause, ties are broken by _id and defaultSort must end with it.
+func sortDocument(sort models.SortOptions, allowed []string, defaultSort bson.D) bson.D {
+	if sort.IsDefault() || !models.IsSortField(sort.Field, allowed) {
+		return defaultSort
+	}
+
+	direction := 1
+	if sort.Order == models.SortDesc {
+		direction = -1
+	}
+
+	return bson.D{{Key: sort.Field, Value: direction}, {Key: "_id", Value: direction}}
+}
+
+// sortCacheKey appends the ordering to a list cache key; the default ordering keeps the original key
+func sortCacheKey(cacheKey string, sort models.SortOptions) string {
+	if sort.IsDefault() {
+		return cacheKey
+	}
+
+	order := sort.Order
+	if order == "" {
+		order = models.SortAsc
+	}
+
+	return fmt.Sprintf("%s:sort:%s:%s", cacheKey, sort.Field, strings.ToLower(string(order)))
+}
@@ -0,0 +1,313 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/chats/go-user-api/internal/database"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+var sortOrders = map[models.SortOrder]string{
+	models.SortAsc:  "ASC",
+	models.SortDesc: "DESC",
+}
+
+// newTestPostgresDB returns a PostgresDB backed by sqlmock
+func newTestPostgresDB(t *testing.T) (*database.PostgresDB, sqlmock.Sqlmock) {
+	t.Helper()
+
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { mockDB.Close() })
+
+	return &database.PostgresDB{DB: sqlx.NewDb(mockDB, "postgres")}, mock
+}
+
+func TestOrderByClause(t *testing.T) {
+	assert.Equal(t, "created_at DESC, id DESC", orderByClause(models.SortOptions{}, models.UserSortFields, "created_at DESC, id DESC"))
+	assert.Equal(t, "email ASC, id ASC", orderByClause(models.SortOptions{Field: "email", Order: models.SortAsc}, models.UserSortFields, "created_at DESC, id DESC"))
+	assert.Equal(t, "email DESC, id DESC", orderByClause(models.SortOptions{Field: "email", Order: models.SortDesc}, models.UserSortFields, "created_at DESC, id DESC"))
+
+	// Fields outside the allow-list are never interpolated
+	assert.Equal(t, "name, id", orderByClause(models.SortOptions{Field: "name; DROP TABLE roles", Order: models.SortAsc}, models.RoleSortFields, "name, id"))
+}
+
+func TestSortCacheKey(t *testing.T) {
+	assert.Equal(t, "roles:all", sortCacheKey("roles:all", models.SortOptions{}))
+	assert.Equal(t, "roles:all:sort:name:asc", sortCacheKey("roles:all", models.SortOptions{Field: "name", Order: models.SortAsc}))
+	assert.Equal(t, "roles:all:sort:name:desc", sortCacheKey("roles:all", models.SortOptions{Field: "name", Order: models.SortDesc}))
+}
+
+func TestUserRepository_GetAll_Sort(t *testing.T) {
+	for _, field := range models.UserSortFields {
+		for order, direction := range sortOrders {
+			t.Run(field+" "+string(order), func(t *testing.T) {
+				db, mock := newTestPostgresDB(t)
+				redisClient, _ := newTestRedisClient(t)
+				repo := NewUserRepository(db, redisClient)
+
+				mock.ExpectQuery(regexp.QuoteMeta("ORDER BY "+field+" "+direction+", id "+direction)).
+					WithArgs(10, 0).
+					WillReturnRows(sqlmock.NewRows(userColumns))
+
+				_, err := repo.GetAll(context.Background(), 10, 0, models.SortOptions{Field: field, Order: order})
+
+				require.NoError(t, err)
+				assert.NoError(t, mock.ExpectationsWereMet())
+			})
+		}
+	}
+
+	t.Run("Default ordering", func(t *testing.T) {
+		db, mock := newTestPostgresDB(t)
+		redisClient, _ := newTestRedisClient(t)
+		repo := NewUserRepository(db, redisClient)
+
+		mock.ExpectQuery(regexp.QuoteMeta("ORDER BY created_at DESC, id DESC")).
+			WithArgs(10, 0).
+			WillReturnRows(sqlmock.NewRows(userColumns))
+
+		_, err := repo.GetAll(context.Background(), 10, 0, models.SortOptions{})
+
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestRoleRepository_GetAll_Sort(t *testing.T) {
+	for _, field := range models.RoleSortFields {
+		for order, direction := range sortOrders {
+			t.Run(field+" "+string(order), func(t *testing.T) {
+				repo, mock := newTestRoleRepository(t)
+
+				mock.ExpectQuery(regexp.QuoteMeta("ORDER BY " + field + " " + direction + ", id " + direction)).
+					WillReturnRows(sqlmock.NewRows(roleColumns))
+
+				_, err := repo.GetAll(context.Background(), false, models.SortOptions{Field: field, Order: order})
+
+				require.NoError(t, err)
+				assert.NoError(t, mock.ExpectationsWereMet())
+			})
+		}
+	}
+
+	t.Run("Orderings are cached separately", func(t *testing.T) {
+		repo, mock := newTestRoleRepository(t)
+
+		mock.ExpectQuery(regexp.QuoteMeta("ORDER BY name, id\n")).
+			WillReturnRows(sqlmock.NewRows(roleColumns))
+		mock.ExpectQuery(regexp.QuoteMeta("ORDER BY name DESC, id DESC")).
+			WillReturnRows(sqlmock.NewRows(roleColumns))
+
+		_, err := repo.GetAll(context.Background(), false, models.SortOptions{})
+		require.NoError(t, err)
+		_, err = repo.GetAll(context.Background(), false, models.SortOptions{Field: "name", Order: models.SortDesc})
+		require.NoError(t, err)
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPermissionRepository_GetAll_Sort(t *testing.T) {
+	permissionColumns := []string{"id", "name", "description", "resource", "action", "created_at", "updated_at"}
+
+	for _, field := range models.PermissionSortFields {
+		for order, direction := range sortOrders {
+			t.Run(field+" "+string(order), func(t *testing.T) {
+				db, mock := newTestPostgresDB(t)
+				redisClient, _ := newTestRedisClient(t)
+				repo := NewPermissionRepository(db, redisClient)
+
+				mock.ExpectQuery(regexp.QuoteMeta("ORDER BY " + field + " " + direction + ", id " + direction)).
+					WillReturnRows(sqlmock.NewRows(permissionColumns))
+
+				_, err := repo.GetAll(context.Background(), models.SortOptions{Field: field, Order: order})
+
+				require.NoError(t, err)
+				assert.NoError(t, mock.ExpectationsWereMet())
+			})
+		}
+	}
+
+	t.Run("Default ordering", func(t *testing.T) {
+		db, mock := newTestPostgresDB(t)
+		redisClient, _ := newTestRedisClient(t)
+		repo := NewPermissionRepository(db, redisClient)
+
+		mock.ExpectQuery(regexp.QuoteMeta("ORDER BY resource, action, id")).
+			WillReturnRows(sqlmock.NewRows(permissionColumns))
+
+		_, err := repo.GetAll(context.Background(), models.SortOptions{})
+
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestMongoRepositories_GetAll_Sort(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	// assertFindSort checks the sort document sent with the single find command
+	assertFindSort := func(mt *mtest.T, collection string, expected bson.D) {
+		events := mt.GetAllStartedEvents()
+		require.Len(mt, events, 1)
+		assert.Equal(mt, "find", events[0].CommandName)
+		assert.Equal(mt, collection, events[0].Command.Lookup("find").StringValue())
+
+		var sort bson.D
+		require.NoError(mt, events[0].Command.Lookup("sort").Unmarshal(&sort))
+		assert.Equal(mt, expected, sort)
+	}
+
+	entities := []struct {
+		collection string
+		fields     []string
+		defaultDoc bson.D
+		getAll     func(mt *mtest.T, sort models.SortOptions) error
+	}{
+		{
+			collection: "users",
+			fields:     models.UserSortFields,
+			defaultDoc: bson.D{{Key: "created_at", Value: int32(-1)}, {Key: "_id", Value: int32(-1)}},
+			getAll: func(mt *mtest.T, sort models.SortOptions) error {
+				redisClient, _ := newTestRedisClient(mt.T)
+				repo := NewMongoUserRepository(&database.MongoDB{Client: mt.Client, Database: mt.DB}, redisClient)
+				_, err := repo.GetAll(context.Background(), 10, 0, sort)
+				return err
+			},
+		},
+		{
+			collection: "roles",
+			fields:     models.RoleSortFields,
+			defaultDoc: bson.D{{Key: "name", Value: int32(1)}, {Key: "_id", Value: int32(1)}},
+			getAll: func(mt *mtest.T, sort models.SortOptions) error {
+				redisClient, _ := newTestRedisClient(mt.T)
+				repo := NewMongoRoleRepository(&database.MongoDB{Client: mt.Client, Database: mt.DB}, redisClient)
+				_, err := repo.GetAll(context.Background(), false, sort)
+				return err
+			},
+		},
+		{
+			collection: "permissions",
+			fields:     models.PermissionSortFields,
+			defaultDoc: bson.D{{Key: "resource", Value: int32(1)}, {Key: "action", Value: int32(1)}, {Key: "_id", Value: int32(1)}},
+			getAll: func(mt *mtest.T, sort models.SortOptions) error {
+				redisClient, _ := newTestRedisClient(mt.T)
+				repo := NewMongoPermissionRepository(&database.MongoDB{Client: mt.Client, Database: mt.DB}, redisClient)
+				_, err := repo.GetAll(context.Background(), sort)
+				return err
+			},
+		},
+	}
+
+	for _, entity := range entities {
+		for _, field := range entity.fields {
+			for order := range sortOrders {
+				mt.Run(entity.collection+" "+field+" "+string(order), func(mt *mtest.T) {
+					mt.AddMockResponses(mtest.CreateCursorResponse(0, mt.DB.Name()+"."+entity.collection, mtest.FirstBatch))
+
+					require.NoError(mt, entity.getAll(mt, models.SortOptions{Field: field, Order: order}))
+
+					direction := int32(1)
+					if order == models.SortDesc {
+						direction = -1
+					}
+					assertFindSort(mt, entity.collection, bson.D{{Key: field, Value: direction}, {Key: "_id", Value: direction}})
+				})
+			}
+		}
+
+		mt.Run(entity.collection+" default ordering", func(mt *mtest.T) {
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, mt.DB.Name()+"."+entity.collection, mtest.FirstBatch))
+
+			require.NoError(mt, entity.getAll(mt, models.SortOptions{}))
+
+			assertFindSort(mt, entity.collection, entity.defaultDoc)
+		})
+	}
+}
+
+func TestMongoUserRepository_GetAll_StablePages(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	// Bulk inserts stamp every user with the same created_at, so only the IDs differ
+	ids := make([]uuid.UUID, 25)
+	for i := range ids {
+		ids[i] = uuid.New()
+	}
+
+	// page applies a find command's sort, skip and limit to the users the way the server would,
+	// starting from an arbitrary storage order
+	page := func(mt *mtest.T, command bson.Raw, stored []uuid.UUID) []uuid.UUID {
+		var sort bson.D
+		require.NoError(mt, command.Lookup("sort").Unmarshal(&sort))
+		skip := int(command.Lookup("skip").AsInt64())
+		limit := int(command.Lookup("limit").AsInt64())
+
+		sorted := slices.Clone(stored)
+		slices.SortStableFunc(sorted, func(a, b uuid.UUID) int {
+			for _, key := range sort {
+				var c int
+				switch key.Key {
+				case "created_at":
+					c = 0 // All equal
+				case "_id":
+					c = bytes.Compare(a[:], b[:])
+				default:
+					require.Failf(mt, "unexpected sort key", "%s", key.Key)
+				}
+				if c != 0 {
+					return c * int(key.Value.(int32))
+				}
+			}
+			return 0
+		})
+
+		end := min(skip+limit, len(sorted))
+		if skip > end {
+			return nil
+		}
+		return sorted[skip:end]
+	}
+
+	mt.Run("Pages do not overlap and cover every user", func(mt *mtest.T) {
+		redisClient, _ := newTestRedisClient(mt.T)
+		repo := NewMongoUserRepository(&database.MongoDB{Client: mt.Client, Database: mt.DB}, redisClient)
+
+		for offset := 0; offset < len(ids); offset += 10 {
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, mt.DB.Name()+".users", mtest.FirstBatch))
+			_, err := repo.GetAll(context.Background(), 10, offset, models.SortOptions{})
+			require.NoError(mt, err)
+		}
+
+		events := mt.GetAllStartedEvents()
+		require.Len(mt, events, 3)
+
+		// Two storage orders stand in for the server returning ties in a different order each time
+		reversed := slices.Clone(ids)
+		slices.Reverse(reversed)
+
+		seen := make(map[uuid.UUID]bool)
+		for _, event := range events {
+			first := page(mt, event.Command, ids)
+			assert.Equal(mt, first, page(mt, event.Command, reversed), "page order depends on storage order")
+
+			for _, id := range first {
+				assert.False(mt, seen[id], "%s is on more than one page", id)
+				seen[id] = true
+			}
+		}
+		assert.Len(mt, seen, len(ids))
+	})
+}
@@ -0,0 +1,62 @@
+package transaction
+
+import (
+	"context"
+	"sync"
+)
+
+// ambientKey identifies the ambient transaction in a context
+type ambientKey struct{}
+
+// AmbientKey is the context key of the ambient transaction. HTTP middleware sets it as a request
+// user value, since handlers derive their contexts from the request.
+var AmbientKey = ambientKey{}
+
+// commitHooksKey identifies the commit hooks of the ambient transaction in a context
+type commitHooksKey struct{}
+
+// CommitHooksKey is the context key of the ambient transaction's commit hooks, set alongside AmbientKey
+var CommitHooksKey = commitHooksKey{}
+
+// ContextWithAmbient returns a context carrying repo as the ambient transaction
+func ContextWithAmbient[T any](ctx context.Context, repo T) context.Context {
+	return context.WithValue(ctx, AmbientKey, repo)
+}
+
+// AmbientFromContext returns the ambient transaction the context carries, if any
+func AmbientFromContext[T any](ctx context.Context) (T, bool) {
+	repo, ok := ctx.Value(AmbientKey).(T)
+	return repo, ok
+}
+
+// CommitHooks collects the functions to run once an ambient transaction commits
+type CommitHooks struct {
+	mu    sync.Mutex
+	hooks []func()
+}
+
+// Run calls the collected functions in the order they were added
+func (h *CommitHooks) Run() {
+	h.mu.Lock()
+	hooks := h.hooks
+	h.hooks = nil
+	h.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+// AfterCommit runs fn once the ambient transaction ctx carries has committed, and is dropped if it
+// rolls back. Without an ambient transaction fn runs right away, since a transaction begun by
+// ExecuteTx has committed by the time it returns. Cache invalidation and events that must not be
+// seen before the changes are visible go through it.
+func AfterCommit(ctx context.Context, fn func()) {
+	if hooks, ok := ctx.Value(CommitHooksKey).(*CommitHooks); ok {
+		hooks.mu.Lock()
+		hooks.hooks = append(hooks.hooks, fn)
+		hooks.mu.Unlock()
+		return
+	}
+	fn()
+}
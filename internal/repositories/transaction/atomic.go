@@ -0,0 +1,23 @@
+package transaction
+
+import "errors"
+
+// ErrNotAtomic is returned for changes that are only safe when they can be rolled back, such as
+// those checked after writing, when the database runs transactions as sequential writes
+var ErrNotAtomic = errors.New("this change needs database transactions, which the deployment does not support")
+
+// sequential is implemented by transaction repositories that may apply their writes as they run,
+// without a transaction to roll back
+type sequential interface {
+	Sequential() bool
+}
+
+// RequireAtomic fails with ErrNotAtomic when repo cannot roll back its writes. Changes that write
+// first and check afterwards, or span several documents that must change together, call it before
+// writing anything.
+func RequireAtomic(repo any) error {
+	if s, ok := repo.(sequential); ok && s.Sequential() {
+		return ErrNotAtomic
+	}
+	return nil
+}
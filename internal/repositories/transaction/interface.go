@@ -2,6 +2,7 @@ package transaction
 
 import (
 	"context"
+	"time"
 
 	"github.com/chats/go-user-api/internal/models"
 	"github.com/google/uuid"
@@ -13,6 +14,14 @@ type UserOperations interface {
 	UpdateUser(ctx context.Context, user *models.User) error
 	UpdateUserPassword(ctx context.Context, userID uuid.UUID, hashedPassword string) error
 	AssignRolesToUser(ctx context.Context, userID uuid.UUID, roleIDs []uuid.UUID) error
+	RevokeUserTokens(ctx context.Context, userID uuid.UUID) error
+	DeactivateUsers(ctx context.Context, userIDs []uuid.UUID, deactivatedAt time.Time) ([]uuid.UUID, error)
+	SoftDeleteUser(ctx context.Context, userID uuid.UUID, deletedAt time.Time) error
+	RestoreUser(ctx context.Context, userID uuid.UUID, restoredAt time.Time) error
+	RevokeUserAPIKeys(ctx context.Context, userID uuid.UUID, revokedAt time.Time) (int, error)
+	ReassignAPIKeys(ctx context.Context, fromUserID, toUserID uuid.UUID) (int, error)
+	DeleteUser(ctx context.Context, userID uuid.UUID) error
+	CountActiveUsersWithRole(ctx context.Context, roleName string) (int, error)
 }
 
 // RoleOperations defines role-related transaction operations
@@ -20,12 +29,16 @@ type RoleOperations interface {
 	CreateRole(ctx context.Context, role *models.Role) error
 	UpdateRole(ctx context.Context, role *models.Role) error
 	AssignPermissionsToRole(ctx context.Context, roleID uuid.UUID, permissionIDs []uuid.UUID) error
+	AddPermissionsToRole(ctx context.Context, roleID uuid.UUID, permissionIDs []uuid.UUID) ([]uuid.UUID, error)
+	RemovePermissionsFromRole(ctx context.Context, roleID uuid.UUID, permissionIDs []uuid.UUID) ([]uuid.UUID, error)
+	DeleteRole(ctx context.Context, roleID uuid.UUID) error
 }
 
 // PermissionOperations defines permission-related transaction operations
 type PermissionOperations interface {
 	CreatePermission(ctx context.Context, permission *models.Permission) error
 	UpdatePermission(ctx context.Context, permission *models.Permission) error
+	DeletePermission(ctx context.Context, permissionID uuid.UUID) error
 }
 
 // Repository combines all transaction operations
@@ -34,8 +34,13 @@ func NewGenericManager[T any, E Executor](
 	}
 }
 
-// ExecuteTx implements the Manager interface
+// ExecuteTx implements the Manager interface. When ctx carries an ambient transaction, fn runs
+// in it instead and whoever began it commits or rolls back.
 func (m *GenericManager[T, E]) ExecuteTx(ctx context.Context, fn func(repo T) error) error {
+	if repo, ok := AmbientFromContext[T](ctx); ok {
+		return fn(repo)
+	}
+
 	tx, err := m.beginTx(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
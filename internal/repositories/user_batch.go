@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"fmt"
+
+	"github.com/chats/go-user-api/internal/cache"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// getUsersByIDs serves a batch lookup from the per-user cache entries in one round-trip, loads
+// only the misses through load and caches them in a second round-trip. Users are returned in
+// the order of ids; unknown IDs are skipped.
+func getUsersByIDs(
+	redisClient *cache.RedisClient,
+	ids []uuid.UUID,
+	load func(missing []uuid.UUID) ([]*models.User, error),
+) ([]*models.User, error) {
+	if len(ids) == 0 {
+		return []*models.User{}, nil
+	}
+
+	keys := make([]string, len(ids))
+	cached := make([]models.User, len(ids))
+	dests := make([]interface{}, len(ids))
+	for i, id := range ids {
+		keys[i] = fmt.Sprintf("user:%s", id.String())
+		dests[i] = &cached[i]
+	}
+
+	found, err := redisClient.MGet(keys, dests)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get users from cache")
+		found = make([]bool, len(ids))
+	}
+
+	// Cached users already include their roles
+	users := make(map[uuid.UUID]*models.User, len(ids))
+	missing := make([]uuid.UUID, 0)
+	for i, id := range ids {
+		if found[i] {
+			users[id] = &cached[i]
+		} else if _, ok := users[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+
+	if len(missing) > 0 {
+		loaded, err := load(missing)
+		if err != nil {
+			return nil, err
+		}
+
+		items := make(map[string]interface{}, len(loaded))
+		for _, user := range loaded {
+			users[user.ID] = user
+			items[fmt.Sprintf("user:%s", user.ID.String())] = user
+		}
+
+		if err := redisClient.MSet(items); err != nil {
+			log.Debug().Err(err).Msg("Failed to cache users")
+		}
+	}
+
+	result := make([]*models.User, 0, len(users))
+	for _, id := range ids {
+		if user, ok := users[id]; ok {
+			result = append(result, user)
+			// Return each user once even if its ID is repeated
+			delete(users, id)
+		}
+	}
+
+	return result, nil
+}
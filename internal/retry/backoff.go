@@ -0,0 +1,54 @@
+package retry
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Backoff retries an operation with exponentially growing delays between attempts
+type Backoff struct {
+	// Attempts is the total number of tries, the first included
+	Attempts int
+	// Initial is the delay before the first retry; each later delay is Multiplier times the
+	// previous one, up to Max
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+// Delay returns the delay before the given retry (1 for the first), without jitter
+func (b Backoff) Delay(retry int) time.Duration {
+	delay := b.Initial
+	for i := 1; i < retry && delay < b.Max; i++ {
+		delay = time.Duration(float64(delay) * b.Multiplier)
+	}
+	if delay > b.Max {
+		delay = b.Max
+	}
+	return delay
+}
+
+// jitter adds up to half the delay, so instances restarted together do not retry in lockstep
+func jitter(delay time.Duration) time.Duration {
+	if delay < 2 {
+		return delay
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/2))
+}
+
+// Do runs op until it succeeds or every attempt has failed, sleeping between attempts, and
+// returns the last error
+func (b Backoff) Do(op func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = op(); err == nil || attempt >= b.Attempts {
+			return err
+		}
+
+		wait := jitter(b.Delay(attempt))
+		log.Warn().Err(err).Dur("retry_in", wait).Int("attempt", attempt).Msg("Retrying connection")
+		time.Sleep(wait)
+	}
+}
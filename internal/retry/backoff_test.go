@@ -0,0 +1,84 @@
+package retry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff_Delay(t *testing.T) {
+	tests := []struct {
+		name    string
+		backoff Backoff
+		want    []time.Duration
+	}{
+		{
+			name:    "Defaults",
+			backoff: Backoff{Initial: time.Second, Max: 30 * time.Second, Multiplier: 1.5},
+			want:    []time.Duration{time.Second, 1500 * time.Millisecond, 2250 * time.Millisecond, 3375 * time.Millisecond},
+		},
+		{
+			name:    "Capped at the maximum",
+			backoff: Backoff{Initial: 100 * time.Millisecond, Max: 300 * time.Millisecond, Multiplier: 2},
+			want:    []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond},
+		},
+		{
+			name:    "Constant delay",
+			backoff: Backoff{Initial: 50 * time.Millisecond, Max: time.Second, Multiplier: 1},
+			want:    []time.Duration{50 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond},
+		},
+		{
+			name:    "Initial above the maximum",
+			backoff: Backoff{Initial: 5 * time.Second, Max: 2 * time.Second, Multiplier: 2},
+			want:    []time.Duration{2 * time.Second, 2 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, want := range tt.want {
+				assert.Equal(t, want, tt.backoff.Delay(i+1), "retry %d", i+1)
+			}
+		})
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		delay := jitter(time.Second)
+		assert.GreaterOrEqual(t, delay, time.Second)
+		assert.Less(t, delay, 1500*time.Millisecond)
+	}
+	assert.Equal(t, time.Duration(0), jitter(0))
+}
+
+func TestBackoff_Do(t *testing.T) {
+	backoff := Backoff{Attempts: 3, Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 1}
+
+	t.Run("Stops at the first success", func(t *testing.T) {
+		calls := 0
+		err := backoff.Do(func() error {
+			calls++
+			if calls < 2 {
+				return errors.New("connection refused")
+			}
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("Returns the last error once attempts run out", func(t *testing.T) {
+		calls := 0
+		err := backoff.Do(func() error {
+			calls++
+			return errors.New("connection refused")
+		})
+
+		assert.EqualError(t, err, "connection refused")
+		assert.Equal(t, 3, calls)
+	})
+}
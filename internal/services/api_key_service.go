@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/repositories"
+	"github.com/chats/go-user-api/internal/utils"
+)
+
+// ErrAPIKeyNotFound is returned when an API key does not exist or belongs to another user
+var ErrAPIKeyNotFound = models.ErrAPIKeyNotFound
+
+// APIKeyService handles API key operations
+type APIKeyService struct {
+	apiKeyRepo repositories.APIKeyRepositoryInterface
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService(apiKeyRepo repositories.APIKeyRepositoryInterface) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo: apiKeyRepo,
+	}
+}
+
+// CreateAPIKey creates a new API key; the plaintext key is only returned here
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, createdBy string, request models.APIKeyCreateRequest) (*models.APIKeyCreateResponse, error) {
+	// Parse creator ID
+	creatorID, err := parseID("user", createdBy)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(request.Name) == "" {
+		return nil, fmt.Errorf("API key name is required")
+	}
+
+	if len(request.Permissions) == 0 {
+		return nil, fmt.Errorf("at least one permission is required")
+	}
+
+	// Permissions are expressed as resource:action
+	for _, permission := range request.Permissions {
+		parts := strings.Split(permission, ":")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid permission %q: expected resource:action", permission)
+		}
+	}
+
+	// Generate the key
+	rawKey, prefix, err := utils.GenerateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	key := &models.APIKey{
+		Name:        request.Name,
+		KeyHash:     utils.HashAPIKey(rawKey),
+		Prefix:      prefix,
+		Permissions: request.Permissions,
+		CreatedBy:   creatorID,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	if request.ExpiresIn > 0 {
+		expiresAt := time.Now().Add(time.Duration(request.ExpiresIn) * 24 * time.Hour)
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := s.apiKeyRepo.Create(ctx, key); err != nil {
+		return nil, err
+	}
+
+	return &models.APIKeyCreateResponse{
+		APIKeyResponse: key.ToResponse(),
+		Key:            rawKey,
+	}, nil
+}
+
+// GetAllAPIKeys retrieves all API keys
+func (s *APIKeyService) GetAllAPIKeys(ctx context.Context) ([]models.APIKeyResponse, error) {
+	keys, err := s.apiKeyRepo.GetAll(ctx)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return toResponses(keys), nil
+}
+
+// GetAPIKey retrieves an API key; its secret is never included
+func (s *APIKeyService) GetAPIKey(ctx context.Context, id string) (*models.APIKeyResponse, error) {
+	// Parse UUID
+	keyID, err := parseID("API key", id)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.apiKeyRepo.GetByID(ctx, keyID)
+	if errors.Is(err, models.ErrAPIKeyNotFound) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, contextError(ctx, fmt.Errorf("failed to get API key: %w", err))
+	}
+
+	response := key.ToResponse()
+	return &response, nil
+}
+
+// RevokeAPIKey revokes an API key so it can no longer authenticate
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, id string) error {
+	// Parse UUID
+	keyID, err := parseID("API key", id)
+	if err != nil {
+		return err
+	}
+
+	return s.apiKeyRepo.Revoke(ctx, keyID)
+}
+
+// GetUserAPIKeys retrieves the API keys a user created
+func (s *APIKeyService) GetUserAPIKeys(ctx context.Context, userID string) ([]models.APIKeyResponse, error) {
+	// Parse user ID
+	creatorID, err := parseID("user", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := s.apiKeyRepo.GetByCreator(ctx, creatorID)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return toResponses(keys), nil
+}
+
+// RevokeUserAPIKey revokes an API key the user created. A key of another user is reported as not
+// found, so its existence is not revealed.
+func (s *APIKeyService) RevokeUserAPIKey(ctx context.Context, userID, id string) error {
+	creatorID, err := parseID("user", userID)
+	if err != nil {
+		return err
+	}
+	keyID, err := parseID("API key", id)
+	if err != nil {
+		return err
+	}
+
+	key, err := s.apiKeyRepo.GetByID(ctx, keyID)
+	if err != nil || key.CreatedBy != creatorID {
+		if ctx.Err() != nil {
+			return contextError(ctx, err)
+		}
+		return ErrAPIKeyNotFound
+	}
+
+	return s.apiKeyRepo.Revoke(ctx, keyID)
+}
+
+// Authenticate resolves a plaintext API key to its stored record
+func (s *APIKeyService) Authenticate(ctx context.Context, rawKey string) (*models.APIKey, error) {
+	if !strings.HasPrefix(rawKey, utils.APIKeyPrefix) {
+		return nil, fmt.Errorf("invalid API key")
+	}
+
+	key, err := s.apiKeyRepo.GetByHash(ctx, utils.HashAPIKey(rawKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid API key")
+	}
+
+	if key.IsRevoked() {
+		return nil, fmt.Errorf("API key has been revoked")
+	}
+
+	if key.IsExpired(time.Now()) {
+		return nil, fmt.Errorf("API key has expired")
+	}
+
+	return key, nil
+}
@@ -0,0 +1,279 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chats/go-user-api/internal/mocks"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/services"
+	"github.com/chats/go-user-api/internal/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAPIKeyService_CreateAPIKey(t *testing.T) {
+	adminID := uuid.New().String()
+
+	t.Run("Successful creation", func(t *testing.T) {
+		mockAPIKeyRepo := new(mocks.MockAPIKeyRepository)
+		apiKeyService := services.NewAPIKeyService(mockAPIKeyRepo)
+
+		request := models.APIKeyCreateRequest{
+			Name:        "billing-service",
+			Permissions: []string{"user:read"},
+			ExpiresIn:   30,
+		}
+
+		var stored *models.APIKey
+		mockAPIKeyRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.APIKey")).Return(nil).Run(func(args mock.Arguments) {
+			stored = args.Get(1).(*models.APIKey)
+		})
+
+		response, err := apiKeyService.CreateAPIKey(context.Background(), adminID, request)
+
+		assert.NoError(t, err)
+		assert.NotNil(t, response)
+		assert.True(t, strings.HasPrefix(response.Key, utils.APIKeyPrefix))
+		assert.Equal(t, request.Name, response.Name)
+		assert.NotNil(t, response.ExpiresAt)
+
+		// Only the hash is persisted
+		assert.NotEqual(t, response.Key, stored.KeyHash)
+		assert.Equal(t, utils.HashAPIKey(response.Key), stored.KeyHash)
+		mockAPIKeyRepo.AssertExpectations(t)
+	})
+
+	t.Run("Invalid permission format", func(t *testing.T) {
+		mockAPIKeyRepo := new(mocks.MockAPIKeyRepository)
+		apiKeyService := services.NewAPIKeyService(mockAPIKeyRepo)
+
+		request := models.APIKeyCreateRequest{
+			Name:        "billing-service",
+			Permissions: []string{"user"},
+		}
+
+		response, err := apiKeyService.CreateAPIKey(context.Background(), adminID, request)
+
+		assert.Error(t, err)
+		assert.Nil(t, response)
+		assert.Contains(t, err.Error(), "expected resource:action")
+		mockAPIKeyRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("No permissions", func(t *testing.T) {
+		mockAPIKeyRepo := new(mocks.MockAPIKeyRepository)
+		apiKeyService := services.NewAPIKeyService(mockAPIKeyRepo)
+
+		request := models.APIKeyCreateRequest{Name: "billing-service"}
+
+		response, err := apiKeyService.CreateAPIKey(context.Background(), adminID, request)
+
+		assert.Error(t, err)
+		assert.Nil(t, response)
+		mockAPIKeyRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+}
+
+func TestAPIKeyService_Authenticate(t *testing.T) {
+	rawKey, prefix, err := utils.GenerateAPIKey()
+	assert.NoError(t, err)
+	keyHash := utils.HashAPIKey(rawKey)
+
+	t.Run("Valid key", func(t *testing.T) {
+		mockAPIKeyRepo := new(mocks.MockAPIKeyRepository)
+		apiKeyService := services.NewAPIKeyService(mockAPIKeyRepo)
+
+		key := &models.APIKey{
+			ID:          uuid.New(),
+			Name:        "billing-service",
+			KeyHash:     keyHash,
+			Prefix:      prefix,
+			Permissions: []string{"user:read"},
+		}
+		mockAPIKeyRepo.On("GetByHash", mock.Anything, keyHash).Return(key, nil)
+
+		result, err := apiKeyService.Authenticate(context.Background(), rawKey)
+
+		assert.NoError(t, err)
+		assert.Equal(t, key.ID, result.ID)
+		assert.True(t, result.HasPermission("user", "read"))
+		assert.False(t, result.HasPermission("user", "delete"))
+		mockAPIKeyRepo.AssertExpectations(t)
+	})
+
+	t.Run("Revoked key", func(t *testing.T) {
+		mockAPIKeyRepo := new(mocks.MockAPIKeyRepository)
+		apiKeyService := services.NewAPIKeyService(mockAPIKeyRepo)
+
+		revokedAt := time.Now().Add(-time.Minute)
+		key := &models.APIKey{ID: uuid.New(), KeyHash: keyHash, RevokedAt: &revokedAt}
+		mockAPIKeyRepo.On("GetByHash", mock.Anything, keyHash).Return(key, nil)
+
+		result, err := apiKeyService.Authenticate(context.Background(), rawKey)
+
+		assert.Error(t, err)
+		assert.Nil(t, result)
+		assert.Contains(t, err.Error(), "revoked")
+	})
+
+	t.Run("Expired key", func(t *testing.T) {
+		mockAPIKeyRepo := new(mocks.MockAPIKeyRepository)
+		apiKeyService := services.NewAPIKeyService(mockAPIKeyRepo)
+
+		expiresAt := time.Now().Add(-time.Hour)
+		key := &models.APIKey{ID: uuid.New(), KeyHash: keyHash, ExpiresAt: &expiresAt}
+		mockAPIKeyRepo.On("GetByHash", mock.Anything, keyHash).Return(key, nil)
+
+		result, err := apiKeyService.Authenticate(context.Background(), rawKey)
+
+		assert.Error(t, err)
+		assert.Nil(t, result)
+		assert.Contains(t, err.Error(), "expired")
+	})
+
+	t.Run("Unknown key", func(t *testing.T) {
+		mockAPIKeyRepo := new(mocks.MockAPIKeyRepository)
+		apiKeyService := services.NewAPIKeyService(mockAPIKeyRepo)
+
+		mockAPIKeyRepo.On("GetByHash", mock.Anything, keyHash).Return(nil, errors.New("API key not found"))
+
+		result, err := apiKeyService.Authenticate(context.Background(), rawKey)
+
+		assert.Error(t, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("Malformed key", func(t *testing.T) {
+		mockAPIKeyRepo := new(mocks.MockAPIKeyRepository)
+		apiKeyService := services.NewAPIKeyService(mockAPIKeyRepo)
+
+		result, err := apiKeyService.Authenticate(context.Background(), "not-a-key")
+
+		assert.Error(t, err)
+		assert.Nil(t, result)
+		mockAPIKeyRepo.AssertNotCalled(t, "GetByHash", mock.Anything, mock.Anything)
+	})
+}
+
+func TestAPIKeyService_GetUserAPIKeys(t *testing.T) {
+	mockAPIKeyRepo := new(mocks.MockAPIKeyRepository)
+	apiKeyService := services.NewAPIKeyService(mockAPIKeyRepo)
+
+	userID := uuid.New()
+	key := &models.APIKey{ID: uuid.New(), Name: "billing-service", KeyHash: "secret-hash", Prefix: "uak_abcd", CreatedBy: userID}
+	mockAPIKeyRepo.On("GetByCreator", mock.Anything, userID).Return([]*models.APIKey{key}, nil)
+
+	keys, err := apiKeyService.GetUserAPIKeys(context.Background(), userID.String())
+
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.Equal(t, "uak_abcd", keys[0].Prefix)
+
+	// Neither the key nor its hash is listed
+	data, err := json.Marshal(keys)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "secret-hash")
+	assert.NotContains(t, string(data), `"key"`)
+}
+
+func TestAPIKeyService_GetAPIKey(t *testing.T) {
+	t.Run("Existing key", func(t *testing.T) {
+		mockAPIKeyRepo := new(mocks.MockAPIKeyRepository)
+		apiKeyService := services.NewAPIKeyService(mockAPIKeyRepo)
+
+		key := &models.APIKey{ID: uuid.New(), Name: "billing-service", KeyHash: "secret-hash", Prefix: "uak_abcd", CreatedBy: uuid.New()}
+		mockAPIKeyRepo.On("GetByID", mock.Anything, key.ID).Return(key, nil)
+
+		response, err := apiKeyService.GetAPIKey(context.Background(), key.ID.String())
+
+		assert.NoError(t, err)
+		assert.Equal(t, key.ID, response.ID)
+		assert.Equal(t, "uak_abcd", response.Prefix)
+	})
+
+	t.Run("Unknown key", func(t *testing.T) {
+		mockAPIKeyRepo := new(mocks.MockAPIKeyRepository)
+		apiKeyService := services.NewAPIKeyService(mockAPIKeyRepo)
+
+		keyID := uuid.New()
+		mockAPIKeyRepo.On("GetByID", mock.Anything, keyID).Return(nil, models.ErrAPIKeyNotFound)
+
+		response, err := apiKeyService.GetAPIKey(context.Background(), keyID.String())
+
+		assert.ErrorIs(t, err, services.ErrAPIKeyNotFound)
+		assert.Nil(t, response)
+	})
+
+	t.Run("Lookup failure is not reported as not found", func(t *testing.T) {
+		mockAPIKeyRepo := new(mocks.MockAPIKeyRepository)
+		apiKeyService := services.NewAPIKeyService(mockAPIKeyRepo)
+
+		keyID := uuid.New()
+		mockAPIKeyRepo.On("GetByID", mock.Anything, keyID).Return(nil, errors.New("connection refused"))
+
+		response, err := apiKeyService.GetAPIKey(context.Background(), keyID.String())
+
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, services.ErrAPIKeyNotFound)
+		assert.Nil(t, response)
+	})
+}
+
+func TestAPIKeyService_RevokeUserAPIKey(t *testing.T) {
+	ownerID := uuid.New()
+	rawKey, _, err := utils.GenerateAPIKey()
+	assert.NoError(t, err)
+	keyHash := utils.HashAPIKey(rawKey)
+
+	t.Run("Revoked key stops authenticating", func(t *testing.T) {
+		mockAPIKeyRepo := new(mocks.MockAPIKeyRepository)
+		apiKeyService := services.NewAPIKeyService(mockAPIKeyRepo)
+
+		key := &models.APIKey{ID: uuid.New(), KeyHash: keyHash, CreatedBy: ownerID}
+		mockAPIKeyRepo.On("GetByID", mock.Anything, key.ID).Return(key, nil)
+		mockAPIKeyRepo.On("GetByHash", mock.Anything, keyHash).Return(key, nil)
+		mockAPIKeyRepo.On("Revoke", mock.Anything, key.ID).Return(nil).Run(func(args mock.Arguments) {
+			revokedAt := time.Now()
+			key.RevokedAt = &revokedAt
+		})
+
+		_, err := apiKeyService.Authenticate(context.Background(), rawKey)
+		assert.NoError(t, err)
+
+		assert.NoError(t, apiKeyService.RevokeUserAPIKey(context.Background(), ownerID.String(), key.ID.String()))
+
+		_, err = apiKeyService.Authenticate(context.Background(), rawKey)
+		assert.ErrorContains(t, err, "revoked")
+	})
+
+	t.Run("Another user's key", func(t *testing.T) {
+		mockAPIKeyRepo := new(mocks.MockAPIKeyRepository)
+		apiKeyService := services.NewAPIKeyService(mockAPIKeyRepo)
+
+		key := &models.APIKey{ID: uuid.New(), KeyHash: keyHash, CreatedBy: ownerID}
+		mockAPIKeyRepo.On("GetByID", mock.Anything, key.ID).Return(key, nil)
+
+		err := apiKeyService.RevokeUserAPIKey(context.Background(), uuid.NewString(), key.ID.String())
+
+		assert.ErrorIs(t, err, services.ErrAPIKeyNotFound)
+		mockAPIKeyRepo.AssertNotCalled(t, "Revoke", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Unknown key", func(t *testing.T) {
+		mockAPIKeyRepo := new(mocks.MockAPIKeyRepository)
+		apiKeyService := services.NewAPIKeyService(mockAPIKeyRepo)
+
+		keyID := uuid.New()
+		mockAPIKeyRepo.On("GetByID", mock.Anything, keyID).Return(nil, errors.New("API key not found"))
+
+		err := apiKeyService.RevokeUserAPIKey(context.Background(), ownerID.String(), keyID.String())
+
+		assert.ErrorIs(t, err, services.ErrAPIKeyNotFound)
+	})
+}
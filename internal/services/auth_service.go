@@ -2,34 +2,91 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/jobs"
+	"github.com/chats/go-user-api/internal/logger"
 	"github.com/chats/go-user-api/internal/models"
 	"github.com/chats/go-user-api/internal/repositories"
+	"github.com/chats/go-user-api/internal/repositories/transaction"
 	"github.com/chats/go-user-api/internal/utils"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
 )
 
+// ErrReauthFailed is returned when the password supplied to re-authenticate does not match
+var ErrReauthFailed = errors.New("password is incorrect")
+
 // AuthService handles authentication-related operations
 type AuthService struct {
-	userRepo repositories.UserRepositoryInterface
-	config   *config.Config
+	userRepo           repositories.UserRepositoryInterface
+	config             *config.Config
+	permissionSnapshot *RolePermissionSnapshot
+	permissionRepo     repositories.PermissionRepositoryInterface
+	challengeVerifier  ChallengeVerifier
+	loginFailures      *loginFailureCounter
+	resetQueue         jobs.Queue
+	txManager          transaction.Manager[transaction.Repository]
 }
 
 func NewAuthService(userRepo repositories.UserRepositoryInterface, config *config.Config) *AuthService {
 	return &AuthService{
-		userRepo: userRepo,
-		config:   config,
+		userRepo:          userRepo,
+		config:            config,
+		challengeVerifier: NoopChallengeVerifier{},
+		loginFailures:     newLoginFailureCounter(config.GetLoginChallengeWindow()),
 	}
 }
 
+// UseChallengeVerifier sets the verifier for challenge tokens required after repeated failed logins
+func (s *AuthService) UseChallengeVerifier(verifier ChallengeVerifier) {
+	s.challengeVerifier = verifier
+}
+
+// UsePasswordResetQueue sets the queue password reset tokens are delivered through; without one,
+// passwords cannot be reset
+func (s *AuthService) UsePasswordResetQueue(queue jobs.Queue) {
+	s.resetQueue = queue
+}
+
+// UseTransactionManager sets the transaction manager a confirmed password reset runs in, so that the
+// new password and the revocation of the user's tokens are written together. Password changes run in
+// it too, joining the request transaction.
+func (s *AuthService) UseTransactionManager(txManager transaction.Manager[transaction.Repository]) {
+	s.txManager = txManager
+}
+
+// UsePermissionSnapshot enables resolving permissions from token roles against the snapshot
+func (s *AuthService) UsePermissionSnapshot(snapshot *RolePermissionSnapshot) {
+	s.permissionSnapshot = snapshot
+}
+
+// UsePermissionRepository lets CheckPermissionDecision tell undefined permissions apart from denials
+func (s *AuthService) UsePermissionRepository(permissionRepo repositories.PermissionRepositoryInterface) {
+	s.permissionRepo = permissionRepo
+}
+
+// StrictPermissionChecks reports whether checks against undefined permissions should be surfaced as errors
+func (s *AuthService) StrictPermissionChecks() bool {
+	return s.config.PermissionCheckStrict && s.permissionRepo != nil
+}
+
 // Login authenticates a user and returns a JWT token
 func (s *AuthService) Login(ctx context.Context, request models.LoginRequest) (*models.LoginResponse, error) {
+	// Require a solved challenge once too many attempts failed for this client or username
+	if err := s.checkLoginChallenge(ctx, request); err != nil {
+		return nil, err
+	}
+
 	// Find user by username
 	user, err := s.userRepo.GetByUsername(ctx, request.Username)
 	if err != nil {
+		s.recordLoginFailure(request)
 		return nil, fmt.Errorf("invalid username or password")
 	}
 
@@ -39,33 +96,277 @@ func (s *AuthService) Login(ctx context.Context, request models.LoginRequest) (*
 	}
 
 	// Verify password
-	if !user.CheckPassword(request.Password) {
+	peppers := s.config.GetPasswordPeppers()
+	match, rehash := utils.VerifyPassword(request.Password, user.Password, peppers)
+	if !match {
+		s.recordLoginFailure(request)
 		return nil, fmt.Errorf("invalid username or password")
 	}
 
+	// Move a hash made with an older pepper, or none, to the newest one
+	if rehash {
+		s.rehashPassword(ctx, user, request.Password, peppers)
+	}
+
+	s.resetLoginFailures(request)
+
+	// Record the login for inactivity tracking
+	loginAt := time.Now()
+	if err := s.userRepo.UpdateLastLogin(ctx, user.ID, loginAt); err != nil {
+		log.Warn().Err(err).Str("user_id", user.ID.String()).Msg("Failed to record last login")
+	} else {
+		user.LastLoginAt = &loginAt
+	}
+
+	return s.issueToken(user, loginAt)
+}
+
+// rehashPassword stores the password hashed with the newest pepper. The login already succeeded,
+// so a failure is only logged and the hash is moved on a later login.
+func (s *AuthService) rehashPassword(ctx context.Context, user *models.User, password string, peppers []string) {
+	hashedPassword, err := utils.HashPassword(password, peppers...)
+	rehashed := false
+	if err == nil {
+		rehashed, err = s.userRepo.RehashPassword(ctx, user.ID, user.Password, hashedPassword)
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("user_id", user.ID.String()).Msg("Failed to rehash password with the current pepper")
+		return
+	}
+
+	// The password changed after it was verified; the newer one stays
+	if !rehashed {
+		return
+	}
+
+	user.Password = hashedPassword
+}
+
+// ReissueToken mints a new token for an authenticated user carrying their current roles,
+// so role changes take effect without logging in again
+func (s *AuthService) ReissueToken(ctx context.Context, userID string) (*models.LoginResponse, error) {
+	// Parse user ID
+	id, err := parseID("user", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load the user with their current roles
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if user is active
+	if !user.IsActive {
+		return nil, fmt.Errorf("user account is inactive")
+	}
+
+	// The user did not enter their password for this token
+	return s.issueToken(user, time.Time{})
+}
+
+// StepUp mints a new token for an authenticated user who entered their password again, allowing
+// the actions that require a recent re-authentication
+func (s *AuthService) StepUp(ctx context.Context, userID, password string) (*models.LoginResponse, error) {
+	user, err := s.checkCurrentPassword(ctx, userID, password)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if user is active
+	if !user.IsActive {
+		return nil, fmt.Errorf("user account is inactive")
+	}
+
+	return s.issueToken(user, time.Now())
+}
+
+// VerifyCurrentPassword checks the password a user supplied to re-authenticate for a sensitive action
+func (s *AuthService) VerifyCurrentPassword(ctx context.Context, userID, password string) error {
+	_, err := s.checkCurrentPassword(ctx, userID, password)
+	return err
+}
+
+// checkCurrentPassword loads the user and verifies their password, returning ErrReauthFailed when it
+// does not match. Mismatches count as failed logins of the user, so once the challenge threshold is
+// reached re-authenticating fails with ErrChallengeRequired until the user logs in with a challenge.
+func (s *AuthService) checkCurrentPassword(ctx context.Context, userID, password string) (*models.User, error) {
+	// Parse user ID
+	id, err := parseID("user", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	// Re-authentication carries no challenge token, so past the threshold it is refused outright
+	attempt := models.LoginRequest{Username: user.Username}
+	if err := s.checkLoginChallenge(ctx, attempt); err != nil {
+		return nil, err
+	}
+
+	if !user.CheckPassword(password, s.config.GetPasswordPeppers()...) {
+		s.recordLoginFailure(attempt)
+		return nil, ErrReauthFailed
+	}
+
+	s.resetLoginFailures(attempt)
+
+	return user, nil
+}
+
+// RequiresReauth reports whether action needs the user to have entered their password recently
+func (s *AuthService) RequiresReauth(action string) bool {
+	return slices.Contains(s.config.GetReauthActions(), action)
+}
+
+// ReauthMaxAge returns how recently the user must have entered their password for a sensitive action
+func (s *AuthService) ReauthMaxAge() time.Duration {
+	return s.config.GetReauthMaxAge()
+}
+
+// RefreshToken exchanges a refresh token for a new access token carrying the user's current roles
+// and a new refresh token. Access tokens are rejected here, as refresh tokens are everywhere else.
+func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (*models.LoginResponse, error) {
+	if s.config.JWTRefreshExpireMinute <= 0 {
+		return nil, fmt.Errorf("refresh tokens are disabled")
+	}
+
+	claims, err := utils.ParseJWT(refreshToken, utils.TokenTypeRefresh, s.config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid refresh token: %w", err)
+	}
+
+	if claims.IssuedAt != nil {
+		revoked, err := s.IsTokenRevoked(ctx, claims.UserID, claims.IssuedAt.Time)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, fmt.Errorf("invalid refresh token: revoked")
+		}
+	}
+
+	// Refreshing picks up the current roles, so it is the same as reissuing
+	return s.ReissueToken(ctx, claims.UserID)
+}
+
+// issueToken generates a JWT for the user's roles and wraps it in a login response; authTime is when
+// the user entered their password, zero when they did not for this token
+func (s *AuthService) issueToken(user *models.User, authTime time.Time) (*models.LoginResponse, error) {
 	// Extract role names for JWT
 	roleNames := make([]string, len(user.Roles))
 	for i, role := range user.Roles {
 		roleNames[i] = role.Name
 	}
 
+	// An expired password still logs in, but the token only allows changing it
+	scope := ""
+	mustChangePassword := user.PasswordExpired(s.config.GetPasswordMaxAge(), time.Now())
+	if mustChangePassword {
+		scope = utils.ScopePasswordChange
+	}
+
 	// Generate JWT token
-	tokenString, expirationTime, err := s.GenerateToken(user.ID, user.Username, roleNames)
+	tokenString, expirationTime, err := utils.GenerateScopedJWT(user.ID, user.Username, roleNames, scope, authTime, s.config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
 	// Create response
 	response := &models.LoginResponse{
-		AccessToken: tokenString,
-		TokenType:   "bearer",
-		ExpiresIn:   int(time.Until(expirationTime).Seconds()),
-		User:        user.ToResponse(),
+		AccessToken:        tokenString,
+		TokenType:          "bearer",
+		ExpiresIn:          int(time.Until(expirationTime).Seconds()),
+		User:               user.ToResponse(),
+		MustChangePassword: mustChangePassword,
+	}
+
+	// A token limited to changing the password gets no refresh token, so the limit cannot be refreshed away
+	if s.config.JWTRefreshExpireMinute > 0 && scope == "" {
+		refreshToken, refreshExpirationTime, err := utils.GenerateRefreshJWT(user.ID, user.Username, s.config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+		}
+		response.RefreshToken = refreshToken
+		response.RefreshExpiresIn = int(time.Until(refreshExpirationTime).Seconds())
 	}
 
 	return response, nil
 }
 
+// loginFailureKeys returns the keys failed logins are counted under
+func loginFailureKeys(request models.LoginRequest) []string {
+	keys := []string{usernameFailureKey(request.Username)}
+	if request.ClientIP != "" {
+		keys = append(keys, "ip:"+request.ClientIP)
+	}
+	return keys
+}
+
+// checkLoginChallenge verifies the challenge token when the failure threshold has been reached
+func (s *AuthService) checkLoginChallenge(ctx context.Context, request models.LoginRequest) error {
+	threshold := s.config.LoginChallengeThreshold
+	if threshold <= 0 {
+		return nil
+	}
+
+	required := false
+	now := time.Now()
+	for _, key := range loginFailureKeys(request) {
+		if s.loginFailures.Count(key, now) >= threshold {
+			required = true
+			break
+		}
+	}
+
+	if !required {
+		return nil
+	}
+
+	if request.CaptchaToken == "" {
+		return ErrChallengeRequired
+	}
+
+	ok, err := s.challengeVerifier.Verify(ctx, request.CaptchaToken, request.ClientIP)
+	if err != nil {
+		log.Warn().Err(err).Str("username", logger.MaskUsername(request.Username)).Msg("Failed to verify login challenge")
+		return ErrChallengeFailed
+	}
+	if !ok {
+		return ErrChallengeFailed
+	}
+
+	return nil
+}
+
+// recordLoginFailure counts a failed login against the username and client IP
+func (s *AuthService) recordLoginFailure(request models.LoginRequest) {
+	if s.config.LoginChallengeThreshold <= 0 {
+		return
+	}
+
+	now := time.Now()
+	for _, key := range loginFailureKeys(request) {
+		s.loginFailures.Add(key, now)
+	}
+}
+
+// usernameFailureKey returns the key failed logins of a username are counted under
+func usernameFailureKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+// resetLoginFailures clears the username's failure count after a successful login. The client IP
+// keeps its count, so one known password does not reset guessing at other usernames from that IP.
+func (s *AuthService) resetLoginFailures(request models.LoginRequest) {
+	s.loginFailures.Reset(usernameFailureKey(request.Username))
+}
+
 // GenerateToken generates a JWT token for a user
 func (s *AuthService) GenerateToken(userID uuid.UUID, username string, roles []string) (string, time.Time, error) {
 	return utils.GenerateJWT(userID, username, roles, s.config)
@@ -74,20 +375,109 @@ func (s *AuthService) GenerateToken(userID uuid.UUID, username string, roles []s
 // VerifyToken verifies a JWT token and returns the claims
 func (s *AuthService) VerifyToken(ctx context.Context, tokenString string) (*utils.JWTClaims, error) {
 	// Parse and verify the token
-	claims, err := utils.ParseJWT(tokenString, s.config)
+	claims, err := utils.ParseJWT(tokenString, utils.TokenTypeAccess, s.config)
 	if err != nil {
 		return nil, fmt.Errorf("invalid token: %w", err)
 	}
 
+	if claims.IssuedAt != nil {
+		revoked, err := s.IsTokenRevoked(ctx, claims.UserID, claims.IssuedAt.Time)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, fmt.Errorf("invalid token: revoked")
+		}
+	}
+
+	if s.RejectsStaleTokens() && claims.IssuedAt != nil {
+		stale, err := s.IsTokenStale(ctx, claims.UserID, claims.IssuedAt.Time)
+		if err != nil {
+			return nil, err
+		}
+		if stale {
+			return nil, fmt.Errorf("invalid token: issued before the user's roles changed")
+		}
+	}
+
 	return claims, nil
 }
 
+// RejectsStaleTokens reports whether tokens issued before the user's last role change are rejected
+func (s *AuthService) RejectsStaleTokens() bool {
+	return s.config.TokenRejectStaleRoles
+}
+
+// IsTokenStale reports whether a token issued at issuedAt predates the user's last role change
+func (s *AuthService) IsTokenStale(ctx context.Context, userID string, issuedAt time.Time) (bool, error) {
+	// Parse user ID
+	id, err := parseID("user", userID)
+	if err != nil {
+		return false, err
+	}
+
+	changedAt, err := s.userRepo.GetRolesChangedAt(ctx, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to check token: %w", err)
+	}
+
+	if changedAt.IsZero() {
+		return false, nil
+	}
+
+	// Token issue times are whole seconds
+	return issuedAt.Before(changedAt.Truncate(time.Second)), nil
+}
+
+// IsTokenRevoked reports whether a token issued at issuedAt predates the last revocation of the
+// user's tokens. Token issue times are whole seconds, so a token issued in the second of the
+// revocation cannot be told apart from one issued before it and is revoked too.
+func (s *AuthService) IsTokenRevoked(ctx context.Context, userID string, issuedAt time.Time) (bool, error) {
+	// Parse user ID
+	id, err := parseID("user", userID)
+	if err != nil {
+		return false, err
+	}
+
+	revokedAt, err := s.userRepo.GetTokensRevokedAt(ctx, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to check token: %w", err)
+	}
+
+	if revokedAt.IsZero() {
+		return false, nil
+	}
+
+	return !issuedAt.After(revokedAt.Truncate(time.Second)), nil
+}
+
+// GetUserRoleNames returns the names of the user's current roles, for tokens issued without them
+func (s *AuthService) GetUserRoleNames(ctx context.Context, userID string) ([]string, error) {
+	// Parse user ID
+	id, err := parseID("user", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get roles: %w", err)
+	}
+
+	roleNames := make([]string, len(user.Roles))
+	for i, role := range user.Roles {
+		roleNames[i] = role.Name
+	}
+
+	return roleNames, nil
+}
+
 // ChangePassword changes a user's password
 func (s *AuthService) ChangePassword(ctx context.Context, userID string, currentPassword, newPassword string) error {
 	// Parse user ID
-	id, err := uuid.Parse(userID)
+	id, err := parseID("user", userID)
 	if err != nil {
-		return fmt.Errorf("invalid user ID: %w", err)
+		return err
 	}
 
 	// Get user
@@ -97,73 +487,216 @@ func (s *AuthService) ChangePassword(ctx context.Context, userID string, current
 	}
 
 	// Verify current password
-	if !user.CheckPassword(currentPassword) {
+	if !user.CheckPassword(currentPassword, s.config.GetPasswordPeppers()...) {
 		return fmt.Errorf("current password is incorrect")
 	}
 
 	// Hash new password
-	hashedPassword, err := utils.HashPassword(newPassword)
+	hashedPassword, err := utils.HashPassword(newPassword, s.config.GetPasswordPeppers()...)
 	if err != nil {
 		return fmt.Errorf("failed to hash password: %w", err)
 	}
 
-	// Update password
-	err = s.userRepo.UpdatePassword(ctx, user.ID, hashedPassword)
+	// Without a transaction manager the password is written through the repository on its own
+	if s.txManager == nil {
+		if err := s.userRepo.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+		return nil
+	}
+
+	err = s.txManager.ExecuteTx(ctx, func(tx transaction.Repository) error {
+		if err := tx.UpdateUserPassword(ctx, user.ID, hashedPassword); err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update password: %w", err)
+		return err
 	}
 
+	// Drop the cached user written outside the repository
+	transaction.AfterCommit(ctx, func() { s.userRepo.InvalidateUser(user.ID) })
+
 	return nil
 }
 
-// ResetPassword resets a user's password (admin function)
-func (s *AuthService) ResetPassword(ctx context.Context, userID string) (string, error) {
+// ResetPassword queues delivery of a password reset token to the user (admin function). The
+// password itself is left unchanged until the user sets a new one with the token.
+func (s *AuthService) ResetPassword(ctx context.Context, userID string) error {
 	// Parse user ID
-	id, err := uuid.Parse(userID)
+	id, err := parseID("user", userID)
 	if err != nil {
-		return "", fmt.Errorf("invalid user ID: %w", err)
+		return err
+	}
+
+	if s.resetQueue == nil {
+		return fmt.Errorf("password reset delivery is not configured")
 	}
 
 	// Get user
 	user, err := s.userRepo.GetByID(ctx, id)
 	if err != nil {
-		return "", fmt.Errorf("user not found: %w", err)
+		return fmt.Errorf("user not found: %w", err)
 	}
 
-	// Generate random password
-	newPassword, err := utils.GenerateRandomPassword(12)
+	// The token only works while the current password is in place, so it can be used once
+	token, expiresAt, err := utils.GeneratePasswordResetJWT(user.ID, user.Username, utils.PasswordFingerprint(user.Password), s.config)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate password: %w", err)
+		return fmt.Errorf("failed to generate reset token: %w", err)
+	}
+
+	job, err := jobs.NewJob(jobs.TypePasswordReset, jobs.PasswordReset{
+		UserID:    user.ID,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.resetQueue.Enqueue(ctx, job); err != nil {
+		return fmt.Errorf("failed to queue password reset: %w", err)
+	}
+
+	return nil
+}
+
+// ConfirmPasswordReset sets a new password with a token delivered by ResetPassword. The token is
+// rejected once the password it was issued for has changed, including by an earlier use of it.
+func (s *AuthService) ConfirmPasswordReset(ctx context.Context, token, newPassword string) error {
+	claims, err := utils.ParseJWT(token, utils.TokenTypePasswordReset, s.config)
+	if err != nil {
+		return fmt.Errorf("invalid reset token: %w", err)
+	}
+
+	id, err := parseID("user", claims.UserID)
+	if err != nil {
+		return fmt.Errorf("invalid reset token: %w", err)
+	}
+
+	// Get user
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("invalid reset token: user not found")
+	}
+
+	if claims.PasswordFingerprint != utils.PasswordFingerprint(user.Password) {
+		return fmt.Errorf("invalid reset token: the password has changed since it was issued")
+	}
+
+	if s.txManager == nil {
+		return fmt.Errorf("password reset is not configured")
 	}
 
 	// Hash new password
-	hashedPassword, err := utils.HashPassword(newPassword)
+	hashedPassword, err := utils.HashPassword(newPassword, s.config.GetPasswordPeppers()...)
 	if err != nil {
-		return "", fmt.Errorf("failed to hash password: %w", err)
+		return fmt.Errorf("failed to hash password: %w", err)
 	}
 
-	// Update password
-	err = s.userRepo.UpdatePassword(ctx, user.ID, hashedPassword)
+	// Tokens issued before the reset may be in the wrong hands, so they are revoked with it
+	err = s.txManager.ExecuteTx(ctx, func(tx transaction.Repository) error {
+		if err := tx.UpdateUserPassword(ctx, user.ID, hashedPassword); err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+		if err := tx.RevokeUserTokens(ctx, user.ID); err != nil {
+			return fmt.Errorf("failed to revoke user tokens: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to update password: %w", err)
+		return err
 	}
 
-	return newPassword, nil
+	// Drop the cached user and revocation time written outside the repository
+	transaction.AfterCommit(ctx, func() { s.userRepo.InvalidateUser(user.ID) })
+
+	return nil
 }
 
 // CheckPermission checks if a user has a specific permission
 func (s *AuthService) CheckPermission(ctx context.Context, userID string, resource, action string) (bool, error) {
 	// Parse user ID
-	id, err := uuid.Parse(userID)
+	id, err := parseID("user", userID)
 	if err != nil {
-		return false, fmt.Errorf("invalid user ID: %w", err)
+		return false, err
 	}
 
 	// Check permission
 	hasPermission, err := s.userRepo.HasPermission(ctx, id, resource, action)
 	if err != nil {
-		return false, fmt.Errorf("failed to check permission: %w", err)
+		return false, contextError(ctx, fmt.Errorf("failed to check permission: %w", err))
 	}
 
 	return hasPermission, nil
 }
+
+// CheckPermissionDecision checks a permission like CheckPermission, but reports PermissionUndefined
+// instead of a denial when no permission exists for the resource and action (e.g. a typo in a check)
+func (s *AuthService) CheckPermissionDecision(ctx context.Context, userID string, resource, action string) (models.PermissionDecision, error) {
+	hasPermission, err := s.CheckPermission(ctx, userID, resource, action)
+	if err != nil {
+		return "", err
+	}
+
+	if hasPermission {
+		return models.PermissionGranted, nil
+	}
+
+	// Without a permission repository a missing permission is indistinguishable from a denial
+	if s.permissionRepo == nil {
+		return models.PermissionDenied, nil
+	}
+
+	exists, err := s.permissionRepo.ExistsByResourceAction(ctx, resource, action)
+	if err != nil {
+		return "", fmt.Errorf("failed to check permission: %w", err)
+	}
+
+	if !exists {
+		return models.PermissionUndefined, nil
+	}
+
+	return models.PermissionDenied, nil
+}
+
+// CheckPermissionWithRoles checks a permission using the token's roles against the permission snapshot,
+// falling back to the database when the snapshot is disabled or stale, or the token carries no roles
+func (s *AuthService) CheckPermissionWithRoles(ctx context.Context, userID string, roles []string, resource, action string) (bool, error) {
+	if s.permissionSnapshot != nil && len(roles) > 0 {
+		if allowed, fresh := s.permissionSnapshot.Check(roles, resource, action); fresh {
+			return allowed, nil
+		}
+	}
+
+	return s.CheckPermission(ctx, userID, resource, action)
+}
+
+// MissingPermissions returns the required "resource:action" permissions the user does not hold, resolved in one check:
+// against the permission snapshot when the token's roles can be used, otherwise from a single load of the user's permissions
+func (s *AuthService) MissingPermissions(ctx context.Context, userID string, roles []string, required []string) ([]string, error) {
+	if s.permissionSnapshot != nil && len(roles) > 0 {
+		if missing, fresh := s.permissionSnapshot.Missing(roles, required); fresh {
+			return missing, nil
+		}
+	}
+
+	// Parse user ID
+	id, err := parseID("user", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := s.userRepo.GetUserPermissions(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check permissions: %w", err)
+	}
+
+	granted := make(map[string]struct{}, len(permissions))
+	for _, permission := range permissions {
+		granted[permission.Resource+":"+permission.Action] = struct{}{}
+	}
+
+	return missingPermissions(granted, required), nil
+}
@@ -3,12 +3,17 @@ package services_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/jobs"
 	"github.com/chats/go-user-api/internal/mocks"
 	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/repositories/transaction"
 	"github.com/chats/go-user-api/internal/services"
 	"github.com/chats/go-user-api/internal/utils"
 	"github.com/google/uuid"
@@ -48,6 +53,7 @@ func TestAuthService_Login(t *testing.T) {
 		// Setup mock repository
 		mockUserRepo := new(mocks.MockUserRepository)
 		mockUserRepo.On("GetByUsername", mock.Anything, "testuser").Return(user, nil)
+		mockUserRepo.On("UpdateLastLogin", mock.Anything, userID, mock.AnythingOfType("time.Time")).Return(nil)
 
 		// Create service
 		authService := services.NewAuthService(mockUserRepo, cfg)
@@ -69,6 +75,7 @@ func TestAuthService_Login(t *testing.T) {
 		assert.Greater(t, response.ExpiresIn, 0)
 		assert.Equal(t, user.ID, response.User.ID)
 		assert.Equal(t, user.Username, response.User.Username)
+		assert.NotNil(t, response.User.LastLoginAt)
 
 		// Verify mock
 		mockUserRepo.AssertExpectations(t)
@@ -156,6 +163,136 @@ func TestAuthService_Login(t *testing.T) {
 		// Verify mock
 		mockUserRepo.AssertExpectations(t)
 	})
+
+	maxAgeCfg := &config.Config{
+		JWTSecret:          "test-secret-key",
+		JWTExpireMinute:    60,
+		PasswordMaxAgeDays: 90,
+	}
+
+	t.Run("Expired password must be changed", func(t *testing.T) {
+		// Setup user whose password is past the max age
+		changedAt := time.Now().AddDate(0, 0, -91)
+		expiredUser := *user
+		expiredUser.PasswordChangedAt = &changedAt
+
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetByUsername", mock.Anything, "testuser").Return(&expiredUser, nil)
+		mockUserRepo.On("UpdateLastLogin", mock.Anything, userID, mock.AnythingOfType("time.Time")).Return(nil)
+
+		authService := services.NewAuthService(mockUserRepo, maxAgeCfg)
+
+		response, err := authService.Login(context.Background(), models.LoginRequest{Username: "testuser", Password: password})
+
+		// Login succeeds, but the token only allows changing the password
+		require.NoError(t, err)
+		assert.True(t, response.MustChangePassword)
+
+		claims, err := utils.ParseJWT(response.AccessToken, utils.TokenTypeAccess, maxAgeCfg)
+		require.NoError(t, err)
+		assert.Equal(t, utils.ScopePasswordChange, claims.Scope)
+	})
+
+	t.Run("Expiry falls back to creation time", func(t *testing.T) {
+		// Setup user stored before password changes were tracked
+		legacyUser := *user
+		legacyUser.CreatedAt = time.Now().AddDate(-1, 0, 0)
+		legacyUser.PasswordChangedAt = nil
+
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetByUsername", mock.Anything, "testuser").Return(&legacyUser, nil)
+		mockUserRepo.On("UpdateLastLogin", mock.Anything, userID, mock.AnythingOfType("time.Time")).Return(nil)
+
+		authService := services.NewAuthService(mockUserRepo, maxAgeCfg)
+
+		response, err := authService.Login(context.Background(), models.LoginRequest{Username: "testuser", Password: password})
+
+		require.NoError(t, err)
+		assert.True(t, response.MustChangePassword)
+	})
+
+	t.Run("Fresh password logs in normally", func(t *testing.T) {
+		// Setup user who changed their password recently
+		changedAt := time.Now().AddDate(0, 0, -10)
+		freshUser := *user
+		freshUser.CreatedAt = time.Now().AddDate(-1, 0, 0)
+		freshUser.PasswordChangedAt = &changedAt
+
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetByUsername", mock.Anything, "testuser").Return(&freshUser, nil)
+		mockUserRepo.On("UpdateLastLogin", mock.Anything, userID, mock.AnythingOfType("time.Time")).Return(nil)
+
+		authService := services.NewAuthService(mockUserRepo, maxAgeCfg)
+
+		response, err := authService.Login(context.Background(), models.LoginRequest{Username: "testuser", Password: password})
+
+		require.NoError(t, err)
+		assert.False(t, response.MustChangePassword)
+
+		claims, err := utils.ParseJWT(response.AccessToken, utils.TokenTypeAccess, maxAgeCfg)
+		require.NoError(t, err)
+		assert.Empty(t, claims.Scope)
+	})
+}
+
+func TestAuthService_LoginPepperRotation(t *testing.T) {
+	password := "test-password"
+	oldHash, err := utils.HashPassword(password, "pepper-1")
+	require.NoError(t, err)
+
+	newUser := func() *models.User {
+		return &models.User{ID: uuid.New(), Username: "testuser", Password: oldHash, IsActive: true}
+	}
+
+	t.Run("Rotated pepper verifies and rehashes", func(t *testing.T) {
+		cfg := &config.Config{JWTSecret: "test-secret-key", JWTExpireMinute: 60, PasswordPepper: "pepper-2, pepper-1"}
+		user := newUser()
+
+		var stored string
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetByUsername", mock.Anything, "testuser").Return(user, nil)
+		mockUserRepo.On("UpdateLastLogin", mock.Anything, user.ID, mock.AnythingOfType("time.Time")).Return(nil)
+		mockUserRepo.On("RehashPassword", mock.Anything, user.ID, oldHash, mock.AnythingOfType("string")).Return(true, nil).Run(func(args mock.Arguments) {
+			stored = args.String(3)
+		})
+		authService := services.NewAuthService(mockUserRepo, cfg)
+
+		response, err := authService.Login(context.Background(), models.LoginRequest{Username: "testuser", Password: password})
+
+		require.NoError(t, err)
+		assert.NotEmpty(t, response.AccessToken)
+		match, rehash := utils.VerifyPassword(password, stored, cfg.GetPasswordPeppers())
+		assert.True(t, match)
+		assert.False(t, rehash, "The stored hash should use the newest pepper")
+		assert.False(t, utils.CheckPassword(password, stored, "pepper-1"))
+	})
+
+	t.Run("Current pepper is not rehashed", func(t *testing.T) {
+		cfg := &config.Config{JWTSecret: "test-secret-key", JWTExpireMinute: 60, PasswordPepper: "pepper-1"}
+		user := newUser()
+
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetByUsername", mock.Anything, "testuser").Return(user, nil)
+		mockUserRepo.On("UpdateLastLogin", mock.Anything, user.ID, mock.AnythingOfType("time.Time")).Return(nil)
+		authService := services.NewAuthService(mockUserRepo, cfg)
+
+		_, err := authService.Login(context.Background(), models.LoginRequest{Username: "testuser", Password: password})
+
+		require.NoError(t, err)
+		mockUserRepo.AssertNotCalled(t, "RehashPassword", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Dropped pepper no longer verifies", func(t *testing.T) {
+		cfg := &config.Config{JWTSecret: "test-secret-key", JWTExpireMinute: 60, PasswordPepper: "pepper-2"}
+
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetByUsername", mock.Anything, "testuser").Return(newUser(), nil)
+		authService := services.NewAuthService(mockUserRepo, cfg)
+
+		_, err := authService.Login(context.Background(), models.LoginRequest{Username: "testuser", Password: password})
+
+		assert.EqualError(t, err, "invalid username or password")
+	})
 }
 
 func TestAuthService_ChangePassword(t *testing.T) {
@@ -200,6 +337,28 @@ func TestAuthService_ChangePassword(t *testing.T) {
 		mockUserRepo.AssertExpectations(t)
 	})
 
+	t.Run("Password change in a transaction", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxRepo := new(mocks.MockTxRepository)
+		mockUserRepo.On("GetByID", mock.Anything, userID).Return(user, nil)
+		mockUserRepo.On("InvalidateUser", userID).Return()
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(func(ctx context.Context, fn func(transaction.Repository) error) error {
+			return fn(mockTxRepo)
+		})
+		mockTxRepo.On("UpdateUserPassword", mock.Anything, userID, mock.AnythingOfType("string")).Return(nil)
+
+		authService := services.NewAuthService(mockUserRepo, cfg)
+		authService.UseTransactionManager(mockTxManager)
+
+		err := authService.ChangePassword(context.Background(), userID.String(), currentPassword, "new-password")
+
+		assert.NoError(t, err)
+		mockTxRepo.AssertCalled(t, "UpdateUserPassword", mock.Anything, userID, mock.AnythingOfType("string"))
+		mockUserRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+		mockUserRepo.AssertCalled(t, "InvalidateUser", userID)
+	})
+
 	t.Run("User not found", func(t *testing.T) {
 		// Setup mock repository
 		mockUserRepo := new(mocks.MockUserRepository)
@@ -346,11 +505,26 @@ func TestAuthService_CheckPermission(t *testing.T) {
 	})
 }
 
+// recordingQueue records the jobs enqueued, or fails with err
+type recordingQueue struct {
+	jobs []jobs.Job
+	err  error
+}
+
+func (q *recordingQueue) Enqueue(_ context.Context, job jobs.Job) error {
+	if q.err != nil {
+		return q.err
+	}
+	q.jobs = append(q.jobs, job)
+	return nil
+}
+
 func TestAuthService_ResetPassword(t *testing.T) {
 	// Create test config
 	cfg := &config.Config{
-		JWTSecret:       "test-secret-key",
-		JWTExpireMinute: 60,
+		JWTSecret:                "test-secret-key",
+		JWTExpireMinute:          60,
+		PasswordResetTokenMinute: 30,
 	}
 
 	// Test user
@@ -359,90 +533,851 @@ func TestAuthService_ResetPassword(t *testing.T) {
 		ID:        userID,
 		Username:  "testuser",
 		Email:     "test@example.com",
+		Password:  "$2a$10$current-password-hash",
 		IsActive:  true,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 
-	t.Run("Successful password reset", func(t *testing.T) {
-		// Setup mock repository
+	t.Run("Queues a reset token job", func(t *testing.T) {
 		mockUserRepo := new(mocks.MockUserRepository)
 		mockUserRepo.On("GetByID", mock.Anything, userID).Return(user, nil)
-		mockUserRepo.On("UpdatePassword", mock.Anything, userID, mock.AnythingOfType("string")).Return(nil)
+		queue := &recordingQueue{}
 
-		// Create service
 		authService := services.NewAuthService(mockUserRepo, cfg)
+		authService.UsePasswordResetQueue(queue)
 
-		// Call service
-		newPassword, err := authService.ResetPassword(context.Background(), userID.String())
+		err := authService.ResetPassword(context.Background(), userID.String())
 
-		// Assert results
-		assert.NoError(t, err)
-		assert.NotEmpty(t, newPassword)
-		assert.GreaterOrEqual(t, len(newPassword), 8) // Minimum password length
+		require.NoError(t, err)
+		require.Len(t, queue.jobs, 1)
+		assert.Equal(t, jobs.TypePasswordReset, queue.jobs[0].Type)
 
-		// Verify mock
-		mockUserRepo.AssertExpectations(t)
+		var payload jobs.PasswordReset
+		require.NoError(t, queue.jobs[0].Decode(&payload))
+		assert.Equal(t, userID, payload.UserID)
+		assert.WithinDuration(t, time.Now().Add(30*time.Minute), payload.ExpiresAt, 5*time.Second)
+
+		// The payload carries a reset token bound to the current password, not a password
+		claims, err := utils.ParseJWT(payload.Token, utils.TokenTypePasswordReset, cfg)
+		require.NoError(t, err)
+		assert.Equal(t, userID.String(), claims.UserID)
+		assert.Equal(t, utils.PasswordFingerprint(user.Password), claims.PasswordFingerprint)
+		assert.NotContains(t, string(queue.jobs[0].Payload), "password\"")
+
+		// The password is left unchanged until the user sets a new one
+		mockUserRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
 	})
 
 	t.Run("User not found", func(t *testing.T) {
-		// Setup mock repository
 		mockUserRepo := new(mocks.MockUserRepository)
 		mockUserRepo.On("GetByID", mock.Anything, userID).Return(nil, errors.New("user not found"))
+		queue := &recordingQueue{}
 
-		// Create service
 		authService := services.NewAuthService(mockUserRepo, cfg)
+		authService.UsePasswordResetQueue(queue)
 
-		// Call service
-		newPassword, err := authService.ResetPassword(context.Background(), userID.String())
+		err := authService.ResetPassword(context.Background(), userID.String())
 
-		// Assert results
 		assert.Error(t, err)
-		assert.Empty(t, newPassword)
 		assert.Contains(t, err.Error(), "user not found")
-
-		// Verify mock
+		assert.Empty(t, queue.jobs)
 		mockUserRepo.AssertExpectations(t)
 	})
 
 	t.Run("Invalid user ID format", func(t *testing.T) {
-		// Setup mock repository
 		mockUserRepo := new(mocks.MockUserRepository)
 
-		// Create service
 		authService := services.NewAuthService(mockUserRepo, cfg)
+		authService.UsePasswordResetQueue(&recordingQueue{})
 
-		// Call service with invalid user ID
-		newPassword, err := authService.ResetPassword(context.Background(), "not-a-uuid")
+		err := authService.ResetPassword(context.Background(), "not-a-uuid")
 
-		// Assert results
 		assert.Error(t, err)
-		assert.Empty(t, newPassword)
 		assert.Contains(t, err.Error(), "invalid user ID")
+		mockUserRepo.AssertExpectations(t)
+	})
 
-		// Verify mock - no methods should be called
+	t.Run("Queue full", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetByID", mock.Anything, userID).Return(user, nil)
+
+		authService := services.NewAuthService(mockUserRepo, cfg)
+		authService.UsePasswordResetQueue(&recordingQueue{err: jobs.ErrQueueFull})
+
+		err := authService.ResetPassword(context.Background(), userID.String())
+
+		assert.ErrorIs(t, err, jobs.ErrQueueFull)
+	})
+
+	t.Run("No queue configured", func(t *testing.T) {
+		authService := services.NewAuthService(new(mocks.MockUserRepository), cfg)
+
+		err := authService.ResetPassword(context.Background(), userID.String())
+
+		assert.EqualError(t, err, "password reset delivery is not configured")
+	})
+}
+
+func TestAuthService_ConfirmPasswordReset(t *testing.T) {
+	cfg := &config.Config{
+		JWTSecret:                "test-secret-key",
+		JWTExpireMinute:          60,
+		PasswordResetTokenMinute: 30,
+	}
+
+	userID := uuid.New()
+	user := &models.User{ID: userID, Username: "testuser", Password: "$2a$10$current-password-hash", IsActive: true}
+	resetToken := func(t *testing.T, password string) string {
+		token, _, err := utils.GeneratePasswordResetJWT(userID, user.Username, utils.PasswordFingerprint(password), cfg)
+		require.NoError(t, err)
+		return token
+	}
+
+	t.Run("Sets the new password and revokes the user's tokens", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxRepo := new(mocks.MockTxRepository)
+		mockUserRepo.On("GetByID", mock.Anything, userID).Return(user, nil)
+		mockUserRepo.On("InvalidateUser", userID).Return()
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(func(ctx context.Context, fn func(transaction.Repository) error) error {
+			return fn(mockTxRepo)
+		})
+		mockTxRepo.On("UpdateUserPassword", mock.Anything, userID, mock.AnythingOfType("string")).Return(nil)
+		mockTxRepo.On("RevokeUserTokens", mock.Anything, userID).Return(nil)
+		authService := services.NewAuthService(mockUserRepo, cfg)
+		authService.UseTransactionManager(mockTxManager)
+
+		err := authService.ConfirmPasswordReset(context.Background(), resetToken(t, user.Password), "new-password-123")
+
+		require.NoError(t, err)
+		mockTxRepo.AssertExpectations(t)
 		mockUserRepo.AssertExpectations(t)
 	})
 
-	t.Run("Error updating password", func(t *testing.T) {
-		// Setup mock repository
+	t.Run("Revocation failure fails the reset", func(t *testing.T) {
 		mockUserRepo := new(mocks.MockUserRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxRepo := new(mocks.MockTxRepository)
 		mockUserRepo.On("GetByID", mock.Anything, userID).Return(user, nil)
-		mockUserRepo.On("UpdatePassword", mock.Anything, userID, mock.AnythingOfType("string")).
-			Return(errors.New("database error"))
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(func(ctx context.Context, fn func(transaction.Repository) error) error {
+			return fn(mockTxRepo)
+		})
+		mockTxRepo.On("UpdateUserPassword", mock.Anything, userID, mock.AnythingOfType("string")).Return(nil)
+		mockTxRepo.On("RevokeUserTokens", mock.Anything, userID).Return(errors.New("connection reset"))
+		authService := services.NewAuthService(mockUserRepo, cfg)
+		authService.UseTransactionManager(mockTxManager)
 
-		// Create service
+		err := authService.ConfirmPasswordReset(context.Background(), resetToken(t, user.Password), "new-password-123")
+
+		assert.ErrorContains(t, err, "failed to revoke user tokens")
+		mockUserRepo.AssertNotCalled(t, "InvalidateUser", mock.Anything)
+	})
+
+	t.Run("Token for a password that has since changed", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetByID", mock.Anything, userID).Return(user, nil)
 		authService := services.NewAuthService(mockUserRepo, cfg)
 
-		// Call service
-		newPassword, err := authService.ResetPassword(context.Background(), userID.String())
+		err := authService.ConfirmPasswordReset(context.Background(), resetToken(t, "$2a$10$previous-password-hash"), "new-password-123")
 
-		// Assert results
-		assert.Error(t, err)
-		assert.Empty(t, newPassword)
-		assert.Contains(t, err.Error(), "failed to update password")
+		assert.EqualError(t, err, "invalid reset token: the password has changed since it was issued")
+		mockUserRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+	})
 
-		// Verify mock
+	t.Run("Access token rejected", func(t *testing.T) {
+		authService := services.NewAuthService(new(mocks.MockUserRepository), cfg)
+		accessToken, _, err := utils.GenerateJWT(userID, user.Username, nil, cfg)
+		require.NoError(t, err)
+
+		err = authService.ConfirmPasswordReset(context.Background(), accessToken, "new-password-123")
+
+		assert.ErrorIs(t, err, utils.ErrWrongTokenType)
+	})
+}
+
+func TestAuthService_CheckPermissionWithRoles(t *testing.T) {
+	// Create test config
+	cfg := &config.Config{
+		JWTSecret:       "test-secret-key",
+		JWTExpireMinute: 60,
+	}
+
+	// Test user ID
+	userID := uuid.New()
+
+	// Role snapshot: editor can read and write users
+	editorRole := &models.Role{ID: uuid.New(), Name: "editor", Permissions: []models.Permission{
+		{Resource: "user", Action: "read"},
+		{Resource: "user", Action: "write"},
+	}}
+	newSnapshot := func(t *testing.T, maxAge time.Duration) *services.RolePermissionSnapshot {
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockRoleRepo.On("GetAll", mock.Anything, true, models.SortOptions{}).Return([]*models.Role{editorRole}, nil)
+
+		snapshot := services.NewRolePermissionSnapshot(mockRoleRepo, maxAge)
+		require.NoError(t, snapshot.Refresh(context.Background()))
+		return snapshot
+	}
+
+	t.Run("Cached allow", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		authService := services.NewAuthService(mockUserRepo, cfg)
+		authService.UsePermissionSnapshot(newSnapshot(t, time.Minute))
+
+		hasPermission, err := authService.CheckPermissionWithRoles(context.Background(), userID.String(), []string{"editor"}, "user", "write")
+
+		assert.NoError(t, err)
+		assert.True(t, hasPermission)
+		mockUserRepo.AssertNotCalled(t, "HasPermission", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Cached deny", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		authService := services.NewAuthService(mockUserRepo, cfg)
+		authService.UsePermissionSnapshot(newSnapshot(t, time.Minute))
+
+		hasPermission, err := authService.CheckPermissionWithRoles(context.Background(), userID.String(), []string{"editor"}, "user", "delete")
+
+		assert.NoError(t, err)
+		assert.False(t, hasPermission)
+		mockUserRepo.AssertNotCalled(t, "HasPermission", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Fallback when roles claim is absent", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("HasPermission", mock.Anything, userID, "user", "read").Return(true, nil)
+
+		authService := services.NewAuthService(mockUserRepo, cfg)
+		authService.UsePermissionSnapshot(newSnapshot(t, time.Minute))
+
+		hasPermission, err := authService.CheckPermissionWithRoles(context.Background(), userID.String(), nil, "user", "read")
+
+		assert.NoError(t, err)
+		assert.True(t, hasPermission)
+		mockUserRepo.AssertExpectations(t)
+	})
+
+	t.Run("Fallback when snapshot is stale", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("HasPermission", mock.Anything, userID, "user", "write").Return(false, nil)
+
+		authService := services.NewAuthService(mockUserRepo, cfg)
+		authService.UsePermissionSnapshot(newSnapshot(t, time.Millisecond))
+		time.Sleep(5 * time.Millisecond)
+
+		// The stale snapshot would allow this; the database no longer does
+		hasPermission, err := authService.CheckPermissionWithRoles(context.Background(), userID.String(), []string{"editor"}, "user", "write")
+
+		assert.NoError(t, err)
+		assert.False(t, hasPermission)
+		mockUserRepo.AssertExpectations(t)
+	})
+
+	t.Run("Fallback when snapshot is invalidated", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("HasPermission", mock.Anything, userID, "user", "read").Return(true, nil)
+
+		snapshot := newSnapshot(t, time.Minute)
+		snapshot.Invalidate()
+
+		authService := services.NewAuthService(mockUserRepo, cfg)
+		authService.UsePermissionSnapshot(snapshot)
+
+		hasPermission, err := authService.CheckPermissionWithRoles(context.Background(), userID.String(), []string{"editor"}, "user", "read")
+
+		assert.NoError(t, err)
+		assert.True(t, hasPermission)
+		mockUserRepo.AssertExpectations(t)
+	})
+}
+
+func TestAuthService_CheckPermissionDecision(t *testing.T) {
+	// Create test config
+	cfg := &config.Config{
+		JWTSecret:             "test-secret-key",
+		JWTExpireMinute:       60,
+		PermissionCheckStrict: true,
+	}
+
+	// Test user ID
+	userID := uuid.New()
+
+	t.Run("Granted", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockPermissionRepo := new(mocks.MockPermissionRepository)
+		mockUserRepo.On("HasPermission", mock.Anything, userID, "user", "read").Return(true, nil)
+
+		authService := services.NewAuthService(mockUserRepo, cfg)
+		authService.UsePermissionRepository(mockPermissionRepo)
+
+		decision, err := authService.CheckPermissionDecision(context.Background(), userID.String(), "user", "read")
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.PermissionGranted, decision)
+		mockPermissionRepo.AssertNotCalled(t, "ExistsByResourceAction", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Denied", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockPermissionRepo := new(mocks.MockPermissionRepository)
+		mockUserRepo.On("HasPermission", mock.Anything, userID, "user", "delete").Return(false, nil)
+		mockPermissionRepo.On("ExistsByResourceAction", mock.Anything, "user", "delete").Return(true, nil)
+
+		authService := services.NewAuthService(mockUserRepo, cfg)
+		authService.UsePermissionRepository(mockPermissionRepo)
+
+		decision, err := authService.CheckPermissionDecision(context.Background(), userID.String(), "user", "delete")
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.PermissionDenied, decision)
+		mockPermissionRepo.AssertExpectations(t)
+	})
+
+	t.Run("Undefined permission", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockPermissionRepo := new(mocks.MockPermissionRepository)
+		mockUserRepo.On("HasPermission", mock.Anything, userID, "users", "read").Return(false, nil)
+		mockPermissionRepo.On("ExistsByResourceAction", mock.Anything, "users", "read").Return(false, nil)
+
+		authService := services.NewAuthService(mockUserRepo, cfg)
+		authService.UsePermissionRepository(mockPermissionRepo)
+
+		decision, err := authService.CheckPermissionDecision(context.Background(), userID.String(), "users", "read")
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.PermissionUndefined, decision)
+		assert.True(t, authService.StrictPermissionChecks())
+
+		// The boolean check keeps treating it as a plain denial
+		hasPermission, err := authService.CheckPermission(context.Background(), userID.String(), "users", "read")
+		assert.NoError(t, err)
+		assert.False(t, hasPermission)
+	})
+
+	t.Run("Undefined is reported as denied without a permission repository", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("HasPermission", mock.Anything, userID, "users", "read").Return(false, nil)
+
+		authService := services.NewAuthService(mockUserRepo, cfg)
+
+		decision, err := authService.CheckPermissionDecision(context.Background(), userID.String(), "users", "read")
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.PermissionDenied, decision)
+		assert.False(t, authService.StrictPermissionChecks())
+	})
+}
+
+// stubChallengeVerifier accepts a single known token
+type stubChallengeVerifier struct {
+	validToken string
+	calls      int
+}
+
+func (v *stubChallengeVerifier) Verify(ctx context.Context, token, remoteIP string) (bool, error) {
+	v.calls++
+	return token == v.validToken, nil
+}
+
+func TestAuthService_LoginChallenge(t *testing.T) {
+	// Create test config: challenge after two failures
+	cfg := &config.Config{
+		JWTSecret:                   "test-secret-key",
+		JWTExpireMinute:             60,
+		LoginChallengeThreshold:     2,
+		LoginChallengeWindowMinutes: 15,
+	}
+
+	userID := uuid.New()
+	password := "test-password"
+	hashedPassword, err := utils.HashPassword(password)
+	require.NoError(t, err)
+
+	user := &models.User{ID: userID, Username: "testuser", Password: hashedPassword, IsActive: true}
+
+	newService := func() (*services.AuthService, *stubChallengeVerifier) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetByUsername", mock.Anything, "testuser").Return(user, nil)
+		mockUserRepo.On("UpdateLastLogin", mock.Anything, userID, mock.AnythingOfType("time.Time")).Return(nil)
+
+		verifier := &stubChallengeVerifier{validToken: "solved"}
+		authService := services.NewAuthService(mockUserRepo, cfg)
+		authService.UseChallengeVerifier(verifier)
+		return authService, verifier
+	}
+
+	failLogin := func(t *testing.T, authService *services.AuthService) {
+		_, err := authService.Login(context.Background(), models.LoginRequest{Username: "testuser", Password: "wrong", ClientIP: "10.0.0.1"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid username or password")
+	}
+
+	t.Run("Below threshold no challenge", func(t *testing.T) {
+		authService, verifier := newService()
+		failLogin(t, authService)
+
+		response, err := authService.Login(context.Background(), models.LoginRequest{Username: "testuser", Password: password, ClientIP: "10.0.0.1"})
+
+		assert.NoError(t, err)
+		assert.NotNil(t, response)
+		assert.Zero(t, verifier.calls)
+	})
+
+	t.Run("Above threshold without token", func(t *testing.T) {
+		authService, verifier := newService()
+		failLogin(t, authService)
+		failLogin(t, authService)
+
+		// Even the right password needs a solved challenge now
+		response, err := authService.Login(context.Background(), models.LoginRequest{Username: "testuser", Password: password, ClientIP: "10.0.0.1"})
+
+		assert.ErrorIs(t, err, services.ErrChallengeRequired)
+		assert.Nil(t, response)
+		assert.Zero(t, verifier.calls)
+	})
+
+	t.Run("Above threshold from the same IP with another username", func(t *testing.T) {
+		authService, _ := newService()
+		failLogin(t, authService)
+		failLogin(t, authService)
+
+		_, err := authService.Login(context.Background(), models.LoginRequest{Username: "otheruser", Password: password, ClientIP: "10.0.0.1"})
+
+		assert.ErrorIs(t, err, services.ErrChallengeRequired)
+	})
+
+	t.Run("Above threshold with invalid token", func(t *testing.T) {
+		authService, verifier := newService()
+		failLogin(t, authService)
+		failLogin(t, authService)
+
+		response, err := authService.Login(context.Background(), models.LoginRequest{Username: "testuser", Password: password, ClientIP: "10.0.0.1", CaptchaToken: "bogus"})
+
+		assert.ErrorIs(t, err, services.ErrChallengeFailed)
+		assert.Nil(t, response)
+		assert.Equal(t, 1, verifier.calls)
+	})
+
+	t.Run("Above threshold with valid token", func(t *testing.T) {
+		authService, verifier := newService()
+		failLogin(t, authService)
+		failLogin(t, authService)
+
+		response, err := authService.Login(context.Background(), models.LoginRequest{Username: "testuser", Password: password, ClientIP: "10.0.0.1", CaptchaToken: "solved"})
+
+		assert.NoError(t, err)
+		assert.NotNil(t, response)
+		assert.Equal(t, 1, verifier.calls)
+
+		// A successful login clears the username's failures
+		response, err = authService.Login(context.Background(), models.LoginRequest{Username: "testuser", Password: password, ClientIP: "10.0.0.2"})
+		assert.NoError(t, err)
+		assert.NotNil(t, response)
+
+		// but not those of the client IP
+		_, err = authService.Login(context.Background(), models.LoginRequest{Username: "otheruser", Password: password, ClientIP: "10.0.0.1"})
+		assert.ErrorIs(t, err, services.ErrChallengeRequired)
+	})
+}
+
+func TestHCaptchaVerifier_Verify(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "test-secret", r.PostForm.Get("secret"))
+		assert.Equal(t, "10.0.0.1", r.PostForm.Get("remoteip"))
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"success": %t}`, r.PostForm.Get("response") == "solved")
+	}))
+	defer server.Close()
+
+	verifier := services.NewHCaptchaVerifier("test-secret", server.URL)
+
+	ok, err := verifier.Verify(context.Background(), "solved", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = verifier.Verify(context.Background(), "bogus", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestAuthService_MissingPermissions(t *testing.T) {
+	cfg := &config.Config{
+		JWTSecret:       "test-secret-key",
+		JWTExpireMinute: 60,
+	}
+	userID := uuid.New()
+	required := []string{"user:write", "role:write"}
+
+	t.Run("Resolved from the snapshot", func(t *testing.T) {
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockRoleRepo.On("GetAll", mock.Anything, true, models.SortOptions{}).Return([]*models.Role{
+			{Name: "editor", Permissions: []models.Permission{{Resource: "user", Action: "write"}}},
+			{Name: "role-manager", Permissions: []models.Permission{{Resource: "role", Action: "write"}}},
+		}, nil)
+		snapshot := services.NewRolePermissionSnapshot(mockRoleRepo, time.Minute)
+		require.NoError(t, snapshot.Refresh(context.Background()))
+
+		mockUserRepo := new(mocks.MockUserRepository)
+		authService := services.NewAuthService(mockUserRepo, cfg)
+		authService.UsePermissionSnapshot(snapshot)
+
+		missing, err := authService.MissingPermissions(context.Background(), userID.String(), []string{"editor"}, required)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"role:write"}, missing)
+
+		// Grants from every role are combined
+		missing, err = authService.MissingPermissions(context.Background(), userID.String(), []string{"editor", "role-manager"}, required)
+		assert.NoError(t, err)
+		assert.Empty(t, missing)
+
+		mockUserRepo.AssertNotCalled(t, "GetUserPermissions", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Resolved from the user's permissions", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetUserPermissions", mock.Anything, userID).Return([]models.Permission{{Resource: "role", Action: "write"}}, nil).Once()
+		authService := services.NewAuthService(mockUserRepo, cfg)
+
+		missing, err := authService.MissingPermissions(context.Background(), userID.String(), nil, required)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"user:write"}, missing)
 		mockUserRepo.AssertExpectations(t)
 	})
+
+	t.Run("Invalid user ID", func(t *testing.T) {
+		authService := services.NewAuthService(new(mocks.MockUserRepository), cfg)
+
+		_, err := authService.MissingPermissions(context.Background(), "not-a-uuid", nil, required)
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid user ID")
+	})
+}
+
+func TestAuthService_ReissueToken(t *testing.T) {
+	cfg := &config.Config{
+		JWTSecret:       "test-secret-key",
+		JWTExpireMinute: 60,
+	}
+	userID := uuid.New()
+
+	t.Run("Reissued token carries the current roles", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetByID", mock.Anything, userID).Return(&models.User{
+			ID:       userID,
+			Username: "johndoe",
+			IsActive: true,
+			Roles:    []models.Role{{Name: "viewer"}, {Name: "editor"}},
+		}, nil)
+		authService := services.NewAuthService(mockUserRepo, cfg)
+
+		// The original token only carried viewer
+		oldToken, _, err := authService.GenerateToken(userID, "johndoe", []string{"viewer"})
+		require.NoError(t, err)
+
+		response, err := authService.ReissueToken(context.Background(), userID.String())
+		require.NoError(t, err)
+		assert.NotEqual(t, oldToken, response.AccessToken)
+		assert.Equal(t, "bearer", response.TokenType)
+
+		claims, err := utils.ParseJWT(response.AccessToken, utils.TokenTypeAccess, cfg)
+		require.NoError(t, err)
+		assert.Equal(t, userID.String(), claims.UserID)
+		assert.Equal(t, []string{"viewer", "editor"}, claims.Roles)
+	})
+
+	t.Run("Inactive user", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetByID", mock.Anything, userID).Return(&models.User{ID: userID, IsActive: false}, nil)
+		authService := services.NewAuthService(mockUserRepo, cfg)
+
+		response, err := authService.ReissueToken(context.Background(), userID.String())
+
+		assert.Error(t, err)
+		assert.Nil(t, response)
+		assert.Contains(t, err.Error(), "inactive")
+	})
+
+	t.Run("Invalid user ID", func(t *testing.T) {
+		authService := services.NewAuthService(new(mocks.MockUserRepository), cfg)
+
+		_, err := authService.ReissueToken(context.Background(), "not-a-uuid")
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid user ID")
+	})
+}
+
+func TestAuthService_StepUp(t *testing.T) {
+	cfg := &config.Config{
+		JWTSecret:       "test-secret-key",
+		JWTExpireMinute: 60,
+	}
+	userID := uuid.New()
+	hashedPassword, err := utils.HashPassword("test-password")
+	require.NoError(t, err)
+
+	newRepo := func() *mocks.MockUserRepository {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetByID", mock.Anything, userID).Return(&models.User{
+			ID:       userID,
+			Username: "johndoe",
+			Password: hashedPassword,
+			IsActive: true,
+			Roles:    []models.Role{{Name: "admin"}},
+		}, nil)
+		return mockUserRepo
+	}
+
+	t.Run("Step-up token carries a fresh auth time", func(t *testing.T) {
+		authService := services.NewAuthService(newRepo(), cfg)
+
+		before := time.Now().Truncate(time.Second)
+		response, err := authService.StepUp(context.Background(), userID.String(), "test-password")
+		require.NoError(t, err)
+
+		claims, err := utils.ParseJWT(response.AccessToken, utils.TokenTypeAccess, cfg)
+		require.NoError(t, err)
+		require.NotNil(t, claims.AuthTime)
+		assert.False(t, claims.AuthTime.Time.Before(before))
+	})
+
+	t.Run("Wrong password", func(t *testing.T) {
+		authService := services.NewAuthService(newRepo(), cfg)
+
+		response, err := authService.StepUp(context.Background(), userID.String(), "wrong-password")
+
+		assert.ErrorIs(t, err, services.ErrReauthFailed)
+		assert.Nil(t, response)
+	})
+
+	t.Run("Wrong passwords count as failed logins", func(t *testing.T) {
+		cfg := &config.Config{JWTSecret: "test-secret-key", JWTExpireMinute: 60, LoginChallengeThreshold: 2, LoginChallengeWindowMinutes: 15}
+		mockUserRepo := newRepo()
+		mockUserRepo.On("GetByUsername", mock.Anything, "johndoe").Return(&models.User{ID: userID, Username: "johndoe", Password: hashedPassword, IsActive: true}, nil)
+		authService := services.NewAuthService(mockUserRepo, cfg)
+		authService.UseChallengeVerifier(&stubChallengeVerifier{validToken: "solved"})
+
+		assert.ErrorIs(t, authService.VerifyCurrentPassword(context.Background(), userID.String(), "wrong-password"), services.ErrReauthFailed)
+		_, err := authService.StepUp(context.Background(), userID.String(), "wrong-password")
+		assert.ErrorIs(t, err, services.ErrReauthFailed)
+
+		// Even the right password is refused until the user logs in with a challenge
+		_, err = authService.StepUp(context.Background(), userID.String(), "test-password")
+		assert.ErrorIs(t, err, services.ErrChallengeRequired)
+		_, err = authService.Login(context.Background(), models.LoginRequest{Username: "johndoe", Password: "test-password"})
+		assert.ErrorIs(t, err, services.ErrChallengeRequired)
+	})
+
+	t.Run("Reissued token carries no auth time", func(t *testing.T) {
+		authService := services.NewAuthService(newRepo(), cfg)
+
+		response, err := authService.ReissueToken(context.Background(), userID.String())
+		require.NoError(t, err)
+
+		claims, err := utils.ParseJWT(response.AccessToken, utils.TokenTypeAccess, cfg)
+		require.NoError(t, err)
+		assert.Nil(t, claims.AuthTime)
+	})
+}
+
+func TestAuthService_RefreshToken(t *testing.T) {
+	cfg := &config.Config{
+		JWTSecret:              "test-secret-key",
+		JWTExpireMinute:        60,
+		JWTRefreshExpireMinute: 120,
+	}
+	userID := uuid.New()
+	user := &models.User{
+		ID:       userID,
+		Username: "johndoe",
+		IsActive: true,
+		Roles:    []models.Role{{Name: "editor"}},
+	}
+
+	t.Run("Login issues a refresh token that obtains new tokens", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetByID", mock.Anything, userID).Return(user, nil)
+		mockUserRepo.On("GetTokensRevokedAt", mock.Anything, userID).Return(time.Time{}, nil)
+		authService := services.NewAuthService(mockUserRepo, cfg)
+
+		issued, err := authService.ReissueToken(context.Background(), userID.String())
+		require.NoError(t, err)
+		require.NotEmpty(t, issued.RefreshToken)
+		assert.Positive(t, issued.RefreshExpiresIn)
+
+		response, err := authService.RefreshToken(context.Background(), issued.RefreshToken)
+		require.NoError(t, err)
+
+		claims, err := utils.ParseJWT(response.AccessToken, utils.TokenTypeAccess, cfg)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"editor"}, claims.Roles)
+		assert.NotEmpty(t, response.RefreshToken)
+	})
+
+	t.Run("Access token rejected", func(t *testing.T) {
+		authService := services.NewAuthService(new(mocks.MockUserRepository), cfg)
+		accessToken, _, err := authService.GenerateToken(userID, "johndoe", []string{"editor"})
+		require.NoError(t, err)
+
+		response, err := authService.RefreshToken(context.Background(), accessToken)
+
+		assert.ErrorIs(t, err, utils.ErrWrongTokenType)
+		assert.Nil(t, response)
+	})
+
+	t.Run("Refresh token rejected where an access token is expected", func(t *testing.T) {
+		authService := services.NewAuthService(new(mocks.MockUserRepository), cfg)
+		refreshToken, _, err := utils.GenerateRefreshJWT(userID, "johndoe", cfg)
+		require.NoError(t, err)
+
+		claims, err := authService.VerifyToken(context.Background(), refreshToken)
+
+		assert.ErrorIs(t, err, utils.ErrWrongTokenType)
+		assert.Nil(t, claims)
+	})
+
+	t.Run("Disabled by default", func(t *testing.T) {
+		disabledCfg := &config.Config{JWTSecret: "test-secret-key", JWTExpireMinute: 60}
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetByID", mock.Anything, userID).Return(user, nil)
+		authService := services.NewAuthService(mockUserRepo, disabledCfg)
+
+		issued, err := authService.ReissueToken(context.Background(), userID.String())
+		require.NoError(t, err)
+		assert.Empty(t, issued.RefreshToken)
+
+		refreshToken, _, err := utils.GenerateRefreshJWT(userID, "johndoe", cfg)
+		require.NoError(t, err)
+		_, err = authService.RefreshToken(context.Background(), refreshToken)
+		assert.ErrorContains(t, err, "refresh tokens are disabled")
+	})
+}
+
+func TestAuthService_StaleTokens(t *testing.T) {
+	userID := uuid.New()
+	issuedAt := time.Now().Truncate(time.Second)
+
+	tests := []struct {
+		name      string
+		changedAt time.Time
+		wantStale bool
+	}{
+		{name: "Roles never changed", changedAt: time.Time{}},
+		{name: "Roles changed before the token was issued", changedAt: issuedAt.Add(-time.Hour)},
+		{name: "Roles changed in the second the token was issued", changedAt: issuedAt.Add(500 * time.Millisecond)},
+		{name: "Roles changed after the token was issued", changedAt: issuedAt.Add(2 * time.Second), wantStale: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserRepo := new(mocks.MockUserRepository)
+			mockUserRepo.On("GetRolesChangedAt", mock.Anything, userID).Return(tt.changedAt, nil)
+			authService := services.NewAuthService(mockUserRepo, &config.Config{TokenRejectStaleRoles: true})
+
+			stale, err := authService.IsTokenStale(context.Background(), userID.String(), issuedAt)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantStale, stale)
+		})
+	}
+
+	t.Run("VerifyToken rejects stale tokens when enabled", func(t *testing.T) {
+		cfg := &config.Config{JWTSecret: "test-secret-key", JWTExpireMinute: 60, TokenRejectStaleRoles: true}
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetRolesChangedAt", mock.Anything, userID).Return(time.Now().Add(time.Minute), nil)
+		mockUserRepo.On("GetTokensRevokedAt", mock.Anything, userID).Return(time.Time{}, nil)
+		authService := services.NewAuthService(mockUserRepo, cfg)
+
+		token, _, err := authService.GenerateToken(userID, "johndoe", []string{"viewer"})
+		require.NoError(t, err)
+
+		_, err = authService.VerifyToken(context.Background(), token)
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "roles changed")
+	})
+
+	t.Run("VerifyToken skips the check when disabled", func(t *testing.T) {
+		cfg := &config.Config{JWTSecret: "test-secret-key", JWTExpireMinute: 60}
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetTokensRevokedAt", mock.Anything, userID).Return(time.Time{}, nil)
+		authService := services.NewAuthService(mockUserRepo, cfg)
+
+		token, _, err := authService.GenerateToken(userID, "johndoe", []string{"viewer"})
+		require.NoError(t, err)
+
+		_, err = authService.VerifyToken(context.Background(), token)
+
+		assert.NoError(t, err)
+		mockUserRepo.AssertNotCalled(t, "GetRolesChangedAt", mock.Anything, mock.Anything)
+	})
+}
+
+func TestAuthService_RevokedTokens(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "test-secret-key", JWTExpireMinute: 60, JWTRefreshExpireMinute: 120}
+	userID := uuid.New()
+	issuedAt := time.Now().Truncate(time.Second)
+
+	tests := []struct {
+		name        string
+		revokedAt   time.Time
+		wantRevoked bool
+	}{
+		{name: "Tokens never revoked", revokedAt: time.Time{}},
+		{name: "Token issued after the revocation", revokedAt: issuedAt.Add(-time.Second)},
+		{name: "Token issued in the second of the revocation", revokedAt: issuedAt.Add(500 * time.Millisecond), wantRevoked: true},
+		{name: "Token issued before the revocation", revokedAt: issuedAt.Add(time.Hour), wantRevoked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserRepo := new(mocks.MockUserRepository)
+			mockUserRepo.On("GetTokensRevokedAt", mock.Anything, userID).Return(tt.revokedAt, nil)
+			authService := services.NewAuthService(mockUserRepo, cfg)
+
+			revoked, err := authService.IsTokenRevoked(context.Background(), userID.String(), issuedAt)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantRevoked, revoked)
+		})
+	}
+
+	// verify issues an access and a refresh token, revokes the user's tokens at revokedAt, then
+	// verifies both
+	verify := func(t *testing.T, revokedAt time.Time) (verifyErr, refreshErr error) {
+		t.Helper()
+
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetTokensRevokedAt", mock.Anything, userID).Return(revokedAt, nil)
+		mockUserRepo.On("GetByID", mock.Anything, userID).Return(&models.User{ID: userID, Username: "johndoe", IsActive: true}, nil)
+		authService := services.NewAuthService(mockUserRepo, cfg)
+
+		token, _, err := authService.GenerateToken(userID, "johndoe", []string{"viewer"})
+		require.NoError(t, err)
+		refreshToken, _, err := utils.GenerateRefreshJWT(userID, "johndoe", cfg)
+		require.NoError(t, err)
+
+		_, verifyErr = authService.VerifyToken(context.Background(), token)
+		_, refreshErr = authService.RefreshToken(context.Background(), refreshToken)
+		return verifyErr, refreshErr
+	}
+
+	t.Run("Tokens issued before the revocation stop validating", func(t *testing.T) {
+		verifyErr, refreshErr := verify(t, time.Now().Add(time.Second))
+
+		assert.ErrorContains(t, verifyErr, "revoked")
+		assert.ErrorContains(t, refreshErr, "revoked")
+	})
+
+	t.Run("Tokens issued after the revocation validate", func(t *testing.T) {
+		verifyErr, refreshErr := verify(t, time.Now().Add(-time.Minute))
+
+		assert.NoError(t, verifyErr)
+		assert.NoError(t, refreshErr)
+	})
 }
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/repositories"
+	"github.com/google/uuid"
+)
+
+// ErrUnknownCacheEntity is returned when invalidating an entity type that is not cached
+var ErrUnknownCacheEntity = errors.New("unknown cache entity")
+
+// CacheService clears cached entities on demand, by ID or for a whole entity type. The entity types
+// are the cache warming targets.
+type CacheService struct {
+	invalidator *repositories.CacheInvalidator
+}
+
+// NewCacheService creates a new cache service
+func NewCacheService(invalidator *repositories.CacheInvalidator) *CacheService {
+	return &CacheService{
+		invalidator: invalidator,
+	}
+}
+
+// Invalidate clears the cached copies of a single user, role or permission
+func (s *CacheService) Invalidate(ctx context.Context, entity string, id uuid.UUID) (*models.CacheInvalidationResponse, error) {
+	var invalidate func(uuid.UUID) (int, error)
+	switch entity {
+	case CacheWarmUsers:
+		invalidate = s.invalidator.InvalidateUser
+	case CacheWarmRoles:
+		invalidate = s.invalidator.InvalidateRole
+	case CacheWarmPermissions:
+		invalidate = s.invalidator.InvalidatePermission
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCacheEntity, entity)
+	}
+
+	cleared, err := invalidate(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to invalidate %s cache: %w", entity, err)
+	}
+
+	return &models.CacheInvalidationResponse{
+		Entity:  entity,
+		ID:      id.String(),
+		Cleared: cleared,
+	}, nil
+}
+
+// InvalidateAll clears every cached user, role or permission
+func (s *CacheService) InvalidateAll(ctx context.Context, entity string) (*models.CacheInvalidationResponse, error) {
+	var invalidate func() (int, error)
+	switch entity {
+	case CacheWarmUsers:
+		invalidate = s.invalidator.InvalidateAllUsers
+	case CacheWarmRoles:
+		invalidate = s.invalidator.InvalidateAllRoles
+	case CacheWarmPermissions:
+		invalidate = s.invalidator.InvalidateAllPermissions
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCacheEntity, entity)
+	}
+
+	cleared, err := invalidate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to invalidate %s cache: %w", entity, err)
+	}
+
+	return &models.CacheInvalidationResponse{
+		Entity:  entity,
+		Cleared: cleared,
+	}, nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/repositories"
+	"github.com/rs/zerolog/log"
+)
+
+// Cache warming targets
+const (
+	CacheWarmRoles       = "roles"
+	CacheWarmPermissions = "permissions"
+	CacheWarmUsers       = "users"
+)
+
+// CacheWarmer preloads frequently read entities so the first requests after a deploy hit a warm cache.
+// It warms through the repositories, which own the cache keys and cached formats.
+type CacheWarmer struct {
+	userRepo       repositories.UserRepositoryInterface
+	roleRepo       repositories.RoleRepositoryInterface
+	permissionRepo repositories.PermissionRepositoryInterface
+	targets        []string
+	recentUsers    int
+}
+
+// NewCacheWarmer creates a new cache warmer
+func NewCacheWarmer(
+	userRepo repositories.UserRepositoryInterface,
+	roleRepo repositories.RoleRepositoryInterface,
+	permissionRepo repositories.PermissionRepositoryInterface,
+	cfg *config.Config,
+) *CacheWarmer {
+	return &CacheWarmer{
+		userRepo:       userRepo,
+		roleRepo:       roleRepo,
+		permissionRepo: permissionRepo,
+		targets:        cfg.GetCacheWarmTargets(),
+		recentUsers:    cfg.CacheWarmRecentUsers,
+	}
+}
+
+// Warm loads every configured target. A failing target is logged and does not stop the others.
+func (w *CacheWarmer) Warm(ctx context.Context) error {
+	var errs []error
+
+	for _, target := range w.targets {
+		start := time.Now()
+
+		count, err := w.warmTarget(ctx, target)
+		if err != nil {
+			log.Warn().Err(err).Str("target", target).Msg("Failed to warm cache")
+			errs = append(errs, err)
+			continue
+		}
+
+		log.Info().
+			Str("target", target).
+			Int("count", count).
+			Dur("duration", time.Since(start)).
+			Msg("Cache warmed")
+	}
+
+	return errors.Join(errs...)
+}
+
+// warmTarget loads a single target and returns the number of entities loaded
+func (w *CacheWarmer) warmTarget(ctx context.Context, target string) (int, error) {
+	switch target {
+	case CacheWarmRoles:
+		roles, err := w.roleRepo.GetAll(ctx, false, models.SortOptions{})
+		if err != nil {
+			return 0, fmt.Errorf("failed to warm roles: %w", err)
+		}
+		return len(roles), nil
+
+	case CacheWarmPermissions:
+		permissions, err := w.permissionRepo.GetAll(ctx, models.SortOptions{})
+		if err != nil {
+			return 0, fmt.Errorf("failed to warm permissions: %w", err)
+		}
+		return len(permissions), nil
+
+	case CacheWarmUsers:
+		if w.recentUsers <= 0 {
+			return 0, nil
+		}
+
+		userIDs, err := w.userRepo.GetRecentlyActiveUserIDs(ctx, w.recentUsers)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get recently active users: %w", err)
+		}
+		if len(userIDs) == 0 {
+			return 0, nil
+		}
+
+		// The batch lookup caches every user with their roles
+		users, err := w.userRepo.GetByIDs(ctx, userIDs)
+		if err != nil {
+			return 0, fmt.Errorf("failed to warm users: %w", err)
+		}
+		return len(users), nil
+
+	default:
+		return 0, fmt.Errorf("unknown cache warm target %q", target)
+	}
+}
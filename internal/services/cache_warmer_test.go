@@ -0,0 +1,146 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/cache"
+	"github.com/chats/go-user-api/internal/database"
+	"github.com/chats/go-user-api/internal/mocks"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/repositories"
+	"github.com/chats/go-user-api/internal/services"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheWarmer_Warm(t *testing.T) {
+	cfg := &config.Config{
+		CacheWarmTargets:     "roles, permissions, users",
+		CacheWarmRecentUsers: 2,
+	}
+
+	t.Run("Warms every configured target", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockPermissionRepo := new(mocks.MockPermissionRepository)
+
+		userIDs := []uuid.UUID{uuid.New(), uuid.New()}
+		mockRoleRepo.On("GetAll", mock.Anything, false, models.SortOptions{}).Return([]*models.Role{{Name: "admin"}}, nil)
+		mockPermissionRepo.On("GetAll", mock.Anything, models.SortOptions{}).Return([]*models.Permission{{Name: "user:read"}}, nil)
+		mockUserRepo.On("GetRecentlyActiveUserIDs", mock.Anything, 2).Return(userIDs, nil)
+		mockUserRepo.On("GetByIDs", mock.Anything, userIDs).Return([]*models.User{{ID: userIDs[0]}, {ID: userIDs[1]}}, nil)
+
+		warmer := services.NewCacheWarmer(mockUserRepo, mockRoleRepo, mockPermissionRepo, cfg)
+
+		assert.NoError(t, warmer.Warm(context.Background()))
+		mockUserRepo.AssertExpectations(t)
+		mockRoleRepo.AssertExpectations(t)
+		mockPermissionRepo.AssertExpectations(t)
+	})
+
+	t.Run("Warms only the configured targets", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockPermissionRepo := new(mocks.MockPermissionRepository)
+
+		mockRoleRepo.On("GetAll", mock.Anything, false, models.SortOptions{}).Return([]*models.Role{}, nil)
+
+		warmer := services.NewCacheWarmer(mockUserRepo, mockRoleRepo, mockPermissionRepo, &config.Config{CacheWarmTargets: "roles"})
+
+		assert.NoError(t, warmer.Warm(context.Background()))
+		mockRoleRepo.AssertExpectations(t)
+		mockPermissionRepo.AssertNotCalled(t, "GetAll", mock.Anything, mock.Anything)
+		mockUserRepo.AssertNotCalled(t, "GetRecentlyActiveUserIDs", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Continues past a failing target", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockPermissionRepo := new(mocks.MockPermissionRepository)
+
+		mockRoleRepo.On("GetAll", mock.Anything, false, models.SortOptions{}).Return([]*models.Role{}, errors.New("database error"))
+		mockPermissionRepo.On("GetAll", mock.Anything, models.SortOptions{}).Return([]*models.Permission{}, nil)
+		mockUserRepo.On("GetRecentlyActiveUserIDs", mock.Anything, 2).Return([]uuid.UUID{}, nil)
+
+		warmer := services.NewCacheWarmer(mockUserRepo, mockRoleRepo, mockPermissionRepo, cfg)
+
+		err := warmer.Warm(context.Background())
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to warm roles")
+		mockPermissionRepo.AssertExpectations(t)
+		mockUserRepo.AssertExpectations(t)
+	})
+
+	t.Run("Unknown target", func(t *testing.T) {
+		warmer := services.NewCacheWarmer(new(mocks.MockUserRepository), new(mocks.MockRoleRepository), new(mocks.MockPermissionRepository), &config.Config{CacheWarmTargets: "sessions"})
+
+		err := warmer.Warm(context.Background())
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), `unknown cache warm target "sessions"`)
+	})
+}
+
+func TestCacheWarmer_PopulatesCacheKeys(t *testing.T) {
+	mockDB, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	redisServer := miniredis.RunT(t)
+	redisClient, err := cache.NewRedisClient(&config.Config{
+		RedisHost:     redisServer.Host(),
+		RedisPort:     redisServer.Port(),
+		RedisCacheTTL: 60,
+	})
+	require.NoError(t, err)
+	defer redisClient.Close()
+
+	db := &database.PostgresDB{DB: sqlx.NewDb(mockDB, "postgres")}
+	userRepo := repositories.NewUserRepository(db, redisClient)
+	roleRepo := repositories.NewRoleRepository(db, redisClient)
+	permissionRepo := repositories.NewPermissionRepository(db, redisClient)
+
+	now := time.Now()
+	userID := uuid.New()
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta("FROM roles")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "created_at", "updated_at"}).
+			AddRow(uuid.New(), "admin", "Administrator", now, now))
+	sqlMock.ExpectQuery(regexp.QuoteMeta("FROM permissions")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "resource", "action", "created_at", "updated_at"}).
+			AddRow(uuid.New(), "user:read", "", "user", "read", now, now))
+	sqlMock.ExpectQuery(regexp.QuoteMeta("ORDER BY last_login_at DESC")).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(userID))
+	sqlMock.ExpectQuery(regexp.QuoteMeta("WHERE id = ANY($1)")).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "password", "first_name", "last_name", "is_active", "last_login_at", "created_at", "updated_at"}).
+			AddRow(userID, "johndoe", "john@example.com", "hashed", "John", "Doe", true, now, now, now))
+	sqlMock.ExpectQuery(regexp.QuoteMeta("WHERE ur.user_id = ANY($1)")).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "id", "name", "description", "created_at", "updated_at"}))
+
+	warmer := services.NewCacheWarmer(userRepo, roleRepo, permissionRepo, &config.Config{
+		CacheWarmTargets:     "roles,permissions,users",
+		CacheWarmRecentUsers: 10,
+	})
+
+	require.NoError(t, warmer.Warm(context.Background()))
+
+	assert.True(t, redisServer.Exists("roles:all"))
+	assert.True(t, redisServer.Exists("permissions:all"))
+	assert.True(t, redisServer.Exists(fmt.Sprintf("user:%s", userID)))
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrRequestCanceled is returned when the caller canceled the request, e.g. a client disconnected, before it completed
+	ErrRequestCanceled = errors.New("request canceled")
+	// ErrRequestTimeout is returned when the request's deadline passed before it completed
+	ErrRequestTimeout = errors.New("request deadline exceeded")
+)
+
+// contextError classifies err as ErrRequestCanceled or ErrRequestTimeout when it was caused by ctx ending,
+// so callers can tell an abandoned request from a failure. Other errors are returned unchanged.
+func contextError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(ctx.Err(), context.Canceled):
+		return fmt.Errorf("%w: %w", ErrRequestCanceled, err)
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w", ErrRequestTimeout, err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/repositories"
+)
+
+// ErrPrivilegeEscalation is returned when a caller grants permissions it does not hold itself
+var ErrPrivilegeEscalation = errors.New("privilege escalation")
+
+// Actor is who makes a request: a user, or an API key limited to the permissions it was issued with
+type Actor struct {
+	UserID string
+	APIKey *models.APIKey
+}
+
+type actorKey struct{}
+
+// WithActor records who makes the request, for checks that depend on the caller rather than the route
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// actorFrom returns the actor recorded by WithActor
+func actorFrom(ctx context.Context) (Actor, bool) {
+	actor, ok := ctx.Value(actorKey{}).(Actor)
+	return actor, ok
+}
+
+// actorHolds returns whether the actor holds a permission: an API key holds those it was issued
+// with, a user those granted through their roles
+func actorHolds(ctx context.Context, userRepo repositories.UserRepositoryInterface, actor Actor) (func(resource, action string) bool, error) {
+	switch {
+	case actor.APIKey != nil:
+		return actor.APIKey.HasPermission, nil
+	case actor.UserID != "":
+		userID, err := parseID("user", actor.UserID)
+		if err != nil {
+			return nil, err
+		}
+		permissions, err := userRepo.GetUserPermissions(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get permissions of acting user: %w", err)
+		}
+		held := make(map[string]bool, len(permissions))
+		for _, permission := range permissions {
+			held[permissionName(permission.Resource, permission.Action)] = true
+		}
+		return func(resource, action string) bool { return held[permissionName(resource, action)] }, nil
+	}
+	return func(resource, action string) bool { return false }, nil
+}
+
+// checkEscalation rejects granting permissions the actor does not hold, naming them. Calls made
+// without an actor, such as startup tasks and background jobs, act for the service and are not checked.
+func checkEscalation(ctx context.Context, userRepo repositories.UserRepositoryInterface, granted []models.Permission) error {
+	actor, ok := actorFrom(ctx)
+	if !ok || len(granted) == 0 {
+		return nil
+	}
+
+	holds, err := actorHolds(ctx, userRepo, actor)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, permission := range granted {
+		name := permissionName(permission.Resource, permission.Action)
+		if !holds(permission.Resource, permission.Action) && !slices.Contains(missing, name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return fmt.Errorf("%w: granting permissions you do not hold: %s", ErrPrivilegeEscalation, strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// DefaultIDListLimit caps how many role or permission IDs a single request may carry
+const DefaultIDListLimit = 100
+
+// ErrInvalidID is returned when an ID in a request is not a valid UUID
+var ErrInvalidID = errors.New("not a valid UUID")
+
+// parseID parses the ID of a kind of entity, reporting a malformed one as ErrInvalidID
+func parseID(kind, id string) (uuid.UUID, error) {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid %s ID %q: %w", kind, id, ErrInvalidID)
+	}
+	return parsed, nil
+}
+
+// parseIDList parses the role or permission IDs of a request. Duplicates are dropped
+// keeping the first occurrence, and every malformed ID is reported in one error.
+// A limit of 0 or less disables the cap.
+func parseIDList(kind string, ids []string, limit int) ([]uuid.UUID, error) {
+	if limit > 0 && len(ids) > limit {
+		return nil, fmt.Errorf("too many %s IDs: got %d, at most %d allowed", kind, len(ids), limit)
+	}
+
+	parsed := make([]uuid.UUID, 0, len(ids))
+	seen := make(map[uuid.UUID]struct{}, len(ids))
+	var invalid []string
+	for _, id := range ids {
+		parsedID, err := uuid.Parse(id)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%q", id))
+			continue
+		}
+		if _, ok := seen[parsedID]; ok {
+			continue
+		}
+		seen[parsedID] = struct{}{}
+		parsed = append(parsed, parsedID)
+	}
+
+	switch len(invalid) {
+	case 0:
+		return parsed, nil
+	case 1:
+		return nil, fmt.Errorf("invalid %s ID: %s", kind, invalid[0])
+	default:
+		return nil, fmt.Errorf("invalid %s IDs: %s", kind, strings.Join(invalid, ", "))
+	}
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/events"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/repositories"
+	"github.com/chats/go-user-api/internal/repositories/transaction"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// InactivityLockService deactivates accounts that have not logged in within the configured threshold
+type InactivityLockService struct {
+	userRepo    repositories.UserRepositoryInterface
+	txManager   transaction.Manager[transaction.Repository]
+	threshold   time.Duration
+	interval    time.Duration
+	exemptRoles []string
+
+	// protectLastAdmin leaves the last active admin unlocked
+	protectLastAdmin bool
+
+	// events publishes a deactivation event per locked user; nil publishes none
+	events EventEmitter
+}
+
+// NewInactivityLockService creates a new inactivity lock service
+func NewInactivityLockService(
+	userRepo repositories.UserRepositoryInterface,
+	txManager transaction.Manager[transaction.Repository],
+	emitter EventEmitter,
+	cfg *config.Config,
+) *InactivityLockService {
+	return &InactivityLockService{
+		userRepo:         userRepo,
+		txManager:        txManager,
+		threshold:        cfg.GetInactivityLockThreshold(),
+		interval:         cfg.GetInactivityLockInterval(),
+		exemptRoles:      cfg.GetInactivityLockExemptRoles(),
+		protectLastAdmin: cfg.LastAdminProtection,
+		events:           emitter,
+	}
+}
+
+// Enabled reports whether a lock threshold is configured
+func (s *InactivityLockService) Enabled() bool {
+	return s.threshold > 0
+}
+
+// LockInactiveUsers deactivates users inactive since before now minus the threshold and returns their IDs
+func (s *InactivityLockService) LockInactiveUsers(ctx context.Context, now time.Time) ([]uuid.UUID, error) {
+	if !s.Enabled() {
+		return nil, nil
+	}
+
+	cutoff := now.Add(-s.threshold)
+
+	users, err := s.userRepo.GetInactiveUsers(ctx, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inactive users: %w", err)
+	}
+
+	lockedIDs := make([]uuid.UUID, 0)
+	for _, user := range users {
+		if !user.IsActive || !user.LastActivityAt().Before(cutoff) {
+			continue
+		}
+
+		// Service accounts and other exempt roles are never locked
+		if user.HasRole(s.exemptRoles...) {
+			continue
+		}
+
+		locked, err := s.lockUser(ctx, user, now)
+		if errors.Is(err, ErrLastAdmin) {
+			log.Warn().Str("user_id", user.ID.String()).Msg("Not locking the last active admin")
+			continue
+		}
+		if err != nil {
+			log.Error().Err(err).Str("user_id", user.ID.String()).Msg("Failed to lock inactive user")
+			continue
+		}
+		if !locked {
+			continue
+		}
+		user.IsActive = false
+		user.UpdatedAt = now
+		transaction.AfterCommit(ctx, func() {
+			s.userRepo.InvalidateUser(user.ID)
+			emitUserEvent(ctx, s.events, events.TypeUserDeactivated, user, map[string]interface{}{
+				"reason":           "inactivity",
+				"last_activity_at": user.LastActivityAt(),
+			})
+		})
+
+		lockedIDs = append(lockedIDs, user.ID)
+	}
+
+	return lockedIDs, nil
+}
+
+// lockUser deactivates user and revokes its tokens in a transaction that fails with ErrLastAdmin
+// when it would leave no active admin. Only is_active and updated_at are written, since user may be
+// stale; it reports false when user was deactivated meanwhile.
+func (s *InactivityLockService) lockUser(ctx context.Context, user *models.User, now time.Time) (bool, error) {
+	checkAdmin := s.guardsAdmin(user)
+
+	var locked bool
+	err := s.txManager.ExecuteTx(ctx, func(tx transaction.Repository) error {
+		if err := requireRollback(tx, checkAdmin); err != nil {
+			return err
+		}
+
+		ids, err := tx.DeactivateUsers(ctx, []uuid.UUID{user.ID}, now)
+		if err != nil {
+			return err
+		}
+		locked = len(ids) > 0
+		if !locked {
+			return nil
+		}
+		if err := tx.RevokeUserTokens(ctx, user.ID); err != nil {
+			return fmt.Errorf("failed to revoke tokens: %w", err)
+		}
+		if checkAdmin {
+			return ensureAdminRemains(ctx, tx)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return locked, nil
+}
+
+// guardsAdmin reports whether locking the user has to leave another active admin
+func (s *InactivityLockService) guardsAdmin(user *models.User) bool {
+	return s.protectLastAdmin && user.HasRole(adminRoleName)
+}
+
+// Start runs LockInactiveUsers on the configured interval until the context is cancelled
+func (s *InactivityLockService) Start(ctx context.Context) {
+	if !s.Enabled() {
+		log.Info().Msg("Inactivity auto-lock is disabled")
+		return
+	}
+
+	interval := s.interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	log.Info().
+		Dur("threshold", s.threshold).
+		Dur("interval", interval).
+		Strs("exempt_roles", s.exemptRoles).
+		Msg("Starting inactivity auto-lock worker")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		locked, err := s.LockInactiveUsers(ctx, time.Now())
+		if err != nil {
+			log.Error().Err(err).Msg("Inactivity auto-lock run failed")
+		} else if len(locked) > 0 {
+			log.Info().Int("count", len(locked)).Msg("Locked inactive user accounts")
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Inactivity auto-lock worker stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
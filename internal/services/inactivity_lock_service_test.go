@@ -0,0 +1,157 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/events"
+	"github.com/chats/go-user-api/internal/mocks"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/repositories/transaction"
+	"github.com/chats/go-user-api/internal/services"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestInactivityLockService_LockInactiveUsers(t *testing.T) {
+	cfg := &config.Config{
+		InactivityLockDays:            30,
+		InactivityLockIntervalMinutes: 60,
+		InactivityLockExemptRoles:     "service",
+		LastAdminProtection:           true,
+	}
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	cutoff := now.Add(-30 * 24 * time.Hour)
+	at := func(d time.Duration) *time.Time {
+		t := cutoff.Add(d)
+		return &t
+	}
+
+	// Seeded users across the boundary
+	staleLogin := &models.User{ID: uuid.New(), Username: "stalelogin", IsActive: true, LastLoginAt: at(-time.Hour), CreatedAt: cutoff.Add(-90 * 24 * time.Hour)}
+	staleNeverLoggedIn := &models.User{ID: uuid.New(), Username: "neverloggedin", IsActive: true, CreatedAt: cutoff.Add(-time.Minute)}
+	freshLogin := &models.User{ID: uuid.New(), Username: "freshlogin", IsActive: true, LastLoginAt: at(time.Hour), CreatedAt: cutoff.Add(-90 * 24 * time.Hour)}
+	exactlyAtCutoff := &models.User{ID: uuid.New(), Username: "atcutoff", IsActive: true, LastLoginAt: at(0), CreatedAt: cutoff.Add(-90 * 24 * time.Hour)}
+	freshNeverLoggedIn := &models.User{ID: uuid.New(), Username: "newuser", IsActive: true, CreatedAt: cutoff.Add(time.Minute)}
+	staleService := &models.User{ID: uuid.New(), Username: "billingsvc", IsActive: true, LastLoginAt: at(-time.Hour), Roles: []models.Role{{Name: "service"}}}
+
+	// setup returns a service whose transactions deactivate the active users given, leaving adminsLeft admins
+	setup := func(c *config.Config, adminsLeft int, active ...*models.User) (*services.InactivityLockService, *mocks.MockUserRepository, *mocks.MockTxRepository, *recordingEmitter) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxRepo := new(mocks.MockTxRepository)
+
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(func(ctx context.Context, fn func(transaction.Repository) error) error {
+			return fn(mockTxRepo)
+		})
+		for _, user := range active {
+			mockTxRepo.On("DeactivateUsers", mock.Anything, []uuid.UUID{user.ID}, now).Return([]uuid.UUID{user.ID}, nil)
+		}
+		mockTxRepo.On("RevokeUserTokens", mock.Anything, mock.Anything).Return(nil)
+		mockTxRepo.On("CountActiveUsersWithRole", mock.Anything, "admin").Return(adminsLeft, nil)
+		mockUserRepo.On("InvalidateUser", mock.Anything).Return()
+
+		emitter := &recordingEmitter{}
+		return services.NewInactivityLockService(mockUserRepo, mockTxManager, emitter, c), mockUserRepo, mockTxRepo, emitter
+	}
+
+	t.Run("Locks only stale users", func(t *testing.T) {
+		seeded := []*models.User{staleLogin, staleNeverLoggedIn, freshLogin, exactlyAtCutoff, freshNeverLoggedIn, staleService}
+		for _, user := range seeded {
+			user.IsActive = true
+		}
+		lockService, mockUserRepo, mockTxRepo, emitter := setup(cfg, 1, seeded...)
+
+		mockUserRepo.On("GetInactiveUsers", mock.Anything, cutoff).Return(seeded, nil)
+
+		locked, err := lockService.LockInactiveUsers(context.Background(), now)
+
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []uuid.UUID{staleLogin.ID, staleNeverLoggedIn.ID}, locked)
+		assert.False(t, staleLogin.IsActive)
+		assert.False(t, staleNeverLoggedIn.IsActive)
+		assert.True(t, freshLogin.IsActive)
+		assert.True(t, exactlyAtCutoff.IsActive)
+		assert.True(t, freshNeverLoggedIn.IsActive)
+		assert.True(t, staleService.IsActive)
+		mockTxRepo.AssertNumberOfCalls(t, "DeactivateUsers", 2)
+		mockUserRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+
+		// Locked users lose their sessions and are announced
+		mockTxRepo.AssertCalled(t, "RevokeUserTokens", mock.Anything, staleLogin.ID)
+		mockTxRepo.AssertCalled(t, "RevokeUserTokens", mock.Anything, staleNeverLoggedIn.ID)
+		mockTxRepo.AssertNumberOfCalls(t, "RevokeUserTokens", 2)
+		mockUserRepo.AssertCalled(t, "InvalidateUser", staleLogin.ID)
+		assert.Equal(t, []string{events.TypeUserDeactivated, events.TypeUserDeactivated}, emitter.types())
+		assert.Equal(t, "inactivity", emitter.emitted[0].Payload["reason"])
+	})
+
+	t.Run("Last admin is not locked", func(t *testing.T) {
+		admin := &models.User{ID: uuid.New(), Username: "admin", IsActive: true, LastLoginAt: at(-time.Hour), Roles: []models.Role{{Name: "admin"}}}
+		lockService, mockUserRepo, mockTxRepo, emitter := setup(cfg, 0, admin)
+		mockUserRepo.On("GetInactiveUsers", mock.Anything, cutoff).Return([]*models.User{admin}, nil)
+
+		locked, err := lockService.LockInactiveUsers(context.Background(), now)
+
+		assert.NoError(t, err)
+		assert.Empty(t, locked)
+		assert.True(t, admin.IsActive)
+		mockUserRepo.AssertNotCalled(t, "InvalidateUser", mock.Anything)
+		assert.Empty(t, emitter.emitted)
+		// Revoking ran inside the transaction that was rolled back
+		mockTxRepo.AssertCalled(t, "RevokeUserTokens", mock.Anything, admin.ID)
+	})
+
+	t.Run("One of several admins is locked", func(t *testing.T) {
+		admin := &models.User{ID: uuid.New(), Username: "admin", IsActive: true, LastLoginAt: at(-time.Hour), Roles: []models.Role{{Name: "admin"}}}
+		lockService, mockUserRepo, _, _ := setup(cfg, 1, admin)
+		mockUserRepo.On("GetInactiveUsers", mock.Anything, cutoff).Return([]*models.User{admin}, nil)
+
+		locked, err := lockService.LockInactiveUsers(context.Background(), now)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{admin.ID}, locked)
+		assert.False(t, admin.IsActive)
+	})
+
+	t.Run("User deactivated meanwhile is skipped", func(t *testing.T) {
+		user := &models.User{ID: uuid.New(), Username: "stale", IsActive: true, LastLoginAt: at(-time.Hour)}
+		lockService, mockUserRepo, mockTxRepo, emitter := setup(cfg, 1)
+		mockTxRepo.On("DeactivateUsers", mock.Anything, []uuid.UUID{user.ID}, now).Return([]uuid.UUID{}, nil)
+		mockUserRepo.On("GetInactiveUsers", mock.Anything, cutoff).Return([]*models.User{user}, nil)
+
+		locked, err := lockService.LockInactiveUsers(context.Background(), now)
+
+		assert.NoError(t, err)
+		assert.Empty(t, locked)
+		assert.Empty(t, emitter.emitted)
+		mockTxRepo.AssertNotCalled(t, "RevokeUserTokens", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Repository error", func(t *testing.T) {
+		lockService, mockUserRepo, mockTxRepo, _ := setup(cfg, 1)
+
+		mockUserRepo.On("GetInactiveUsers", mock.Anything, cutoff).Return([]*models.User{}, errors.New("database error"))
+
+		locked, err := lockService.LockInactiveUsers(context.Background(), now)
+
+		assert.Error(t, err)
+		assert.Nil(t, locked)
+		mockTxRepo.AssertNotCalled(t, "DeactivateUsers", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		lockService, mockUserRepo, _, _ := setup(&config.Config{}, 1)
+
+		locked, err := lockService.LockInactiveUsers(context.Background(), now)
+
+		assert.NoError(t, err)
+		assert.Empty(t, locked)
+		mockUserRepo.AssertNotCalled(t, "GetInactiveUsers", mock.Anything, mock.Anything)
+	})
+}
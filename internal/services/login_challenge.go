@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrChallengeRequired is returned by Login when too many attempts failed and no challenge token was sent
+	ErrChallengeRequired = errors.New("challenge required")
+	// ErrChallengeFailed is returned by Login when the challenge token is rejected
+	ErrChallengeFailed = errors.New("challenge verification failed")
+)
+
+// ChallengeVerifier verifies a challenge (captcha) token solved by the client
+type ChallengeVerifier interface {
+	Verify(ctx context.Context, token, remoteIP string) (bool, error)
+}
+
+// NoopChallengeVerifier accepts every token; it is the default when no provider is configured
+type NoopChallengeVerifier struct{}
+
+// Verify always succeeds
+func (NoopChallengeVerifier) Verify(ctx context.Context, token, remoteIP string) (bool, error) {
+	return true, nil
+}
+
+// HCaptchaVerifyURL is the hCaptcha siteverify endpoint
+const HCaptchaVerifyURL = "https://api.hcaptcha.com/siteverify"
+
+// HCaptchaVerifier verifies tokens with the hCaptcha siteverify API
+type HCaptchaVerifier struct {
+	secret    string
+	verifyURL string
+	client    *http.Client
+}
+
+// NewHCaptchaVerifier creates a verifier for the given secret; an empty verifyURL uses HCaptchaVerifyURL
+func NewHCaptchaVerifier(secret, verifyURL string) *HCaptchaVerifier {
+	if verifyURL == "" {
+		verifyURL = HCaptchaVerifyURL
+	}
+
+	return &HCaptchaVerifier{
+		secret:    secret,
+		verifyURL: verifyURL,
+		client:    &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Verify posts the token to hCaptcha and reports whether it was accepted
+func (v *HCaptchaVerifier) Verify(ctx context.Context, token, remoteIP string) (bool, error) {
+	form := url.Values{
+		"secret":   {v.secret},
+		"response": {token},
+	}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, fmt.Errorf("failed to create challenge request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to verify challenge: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("failed to verify challenge: unexpected status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("failed to decode challenge response: %w", err)
+	}
+
+	return result.Success, nil
+}
+
+// loginFailureCounter counts failed logins per key within a fixed window.
+// Counts are kept in memory, so each instance tracks failures separately.
+type loginFailureCounter struct {
+	window time.Duration
+
+	mu       sync.Mutex
+	failures map[string]*loginFailures
+}
+
+type loginFailures struct {
+	count   int
+	resetAt time.Time
+}
+
+func newLoginFailureCounter(window time.Duration) *loginFailureCounter {
+	return &loginFailureCounter{
+		window:   window,
+		failures: make(map[string]*loginFailures),
+	}
+}
+
+// Count returns the failures recorded for key in the current window
+func (c *loginFailureCounter) Count(key string, now time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.failures[key]
+	if !ok || !now.Before(entry.resetAt) {
+		return 0
+	}
+	return entry.count
+}
+
+// Add records a failure for key
+func (c *loginFailureCounter) Add(key string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Drop expired entries so the map does not grow without bound
+	if len(c.failures) > 1000 {
+		for k, entry := range c.failures {
+			if !now.Before(entry.resetAt) {
+				delete(c.failures, k)
+			}
+		}
+	}
+
+	entry, ok := c.failures[key]
+	if !ok || !now.Before(entry.resetAt) {
+		entry = &loginFailures{resetAt: now.Add(c.window)}
+		c.failures[key] = entry
+	}
+	entry.count++
+}
+
+// Reset clears the failures recorded for key
+func (c *loginFailureCounter) Reset(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.failures, key)
+}
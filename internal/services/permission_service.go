@@ -2,9 +2,12 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
+	"github.com/chats/go-user-api/config"
 	"github.com/chats/go-user-api/internal/models"
 	"github.com/chats/go-user-api/internal/repositories"
 	"github.com/chats/go-user-api/internal/repositories/transaction"
@@ -15,33 +18,73 @@ import (
 type PermissionService struct {
 	permissionRepo repositories.PermissionRepositoryInterface
 	txManager      transaction.Manager[transaction.Repository]
+	enforceNaming  bool
+
+	// permissionSnapshot is marked stale whenever a permission granted through roles changes
+	permissionSnapshot *RolePermissionSnapshot
 }
 
 // NewPermissionService creates a new permission service
 func NewPermissionService(
 	permissionRepo repositories.PermissionRepositoryInterface,
 	txManager transaction.Manager[transaction.Repository],
+	cfg *config.Config,
 ) *PermissionService {
 	return &PermissionService{
 		permissionRepo: permissionRepo,
 		txManager:      txManager,
+		enforceNaming:  cfg.PermissionNameEnforce,
 	}
 }
 
+// UsePermissionSnapshot marks the snapshot stale after a permission is updated or deleted
+func (s *PermissionService) UsePermissionSnapshot(snapshot *RolePermissionSnapshot) {
+	s.permissionSnapshot = snapshot
+}
+
+// permissionName returns the conventional resource:action name for a permission
+func permissionName(resource, action string) string {
+	return resource + ":" + action
+}
+
+// checkPermissionName rejects names that break the resource:action convention when it is enforced
+func (s *PermissionService) checkPermissionName(name, resource, action string) error {
+	if !s.enforceNaming {
+		return nil
+	}
+
+	if expected := permissionName(resource, action); name != expected {
+		return fmt.Errorf("permission name %q does not match resource and action, expected %q", name, expected)
+	}
+
+	return nil
+}
+
 // CreatePermission creates a new permission
 func (s *PermissionService) CreatePermission(ctx context.Context, request models.PermissionCreateRequest) (*models.PermissionResponse, error) {
-	// Check if permission already exists for the resource and action
+	// Fail fast on a taken resource and action. This check is advisory: a concurrent create can pass
+	// it too, so the unique index decides and its violation is reported the same way below.
 	existingPermission, err := s.permissionRepo.GetByResourceAction(ctx, request.Resource, request.Action)
 	if err == nil && existingPermission != nil {
-		return nil, fmt.Errorf("permission already exists for this resource and action")
+		return nil, models.ErrPermissionExists
+	}
+
+	// Derive the name from resource and action when omitted
+	name := request.Name
+	if name == "" {
+		name = permissionName(request.Resource, request.Action)
+	}
+	if err := s.checkPermissionName(name, request.Resource, request.Action); err != nil {
+		return nil, err
 	}
 
 	// Create permission object
 	permission := &models.Permission{
-		Name:        request.Name,
+		Name:        name,
 		Description: request.Description,
 		Resource:    request.Resource,
 		Action:      request.Action,
+		Category:    request.Category,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
@@ -50,6 +93,9 @@ func (s *PermissionService) CreatePermission(ctx context.Context, request models
 	err = s.txManager.ExecuteTx(ctx, func(tx transaction.Repository) error {
 		// Save permission to database
 		if err := tx.CreatePermission(ctx, permission); err != nil {
+			if errors.Is(err, models.ErrPermissionExists) || errors.Is(err, models.ErrPermissionNameExists) {
+				return err
+			}
 			return fmt.Errorf("failed to create permission: %w", err)
 		}
 
@@ -68,15 +114,15 @@ func (s *PermissionService) CreatePermission(ctx context.Context, request models
 // GetPermissionByID retrieves a permission by ID
 func (s *PermissionService) GetPermissionByID(ctx context.Context, id string) (*models.PermissionResponse, error) {
 	// Parse UUID
-	permissionID, err := uuid.Parse(id)
+	permissionID, err := parseID("permission", id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid permission ID: %w", err)
+		return nil, err
 	}
 
 	// Get permission
 	permission, err := s.permissionRepo.GetByID(ctx, permissionID)
 	if err != nil {
-		return nil, err
+		return nil, contextError(ctx, err)
 	}
 
 	// แก้ไขตรงนี้: สร้างตัวแปรก่อนแล้วค่อย return address ของตัวแปรนั้น
@@ -84,21 +130,108 @@ func (s *PermissionService) GetPermissionByID(ctx context.Context, id string) (*
 	return &response, nil
 }
 
-// GetAllPermissions retrieves all permissions
-func (s *PermissionService) GetAllPermissions(ctx context.Context) ([]models.PermissionResponse, error) {
+// GetAllPermissions retrieves all permissions in the requested order
+func (s *PermissionService) GetAllPermissions(ctx context.Context, sort models.SortOptions) ([]models.PermissionResponse, error) {
 	// Get permissions
-	permissions, err := s.permissionRepo.GetAll(ctx)
+	permissions, err := s.permissionRepo.GetAll(ctx, sort)
 	if err != nil {
-		return nil, err
+		return nil, contextError(ctx, err)
+	}
+
+	return toResponses(permissions), nil
+}
+
+// GetPermissionsByCategory retrieves the permissions of a category in the requested order
+func (s *PermissionService) GetPermissionsByCategory(ctx context.Context, category string, sort models.SortOptions) ([]models.PermissionResponse, error) {
+	permissions, err := s.permissionRepo.GetAll(ctx, sort)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	inCategory := make([]*models.Permission, 0, len(permissions))
+	for _, permission := range permissions {
+		if permission.Category == category {
+			inCategory = append(inCategory, permission)
+		}
+	}
+
+	return toResponses(inCategory), nil
+}
+
+// AddRoleCounts sets the number of roles granting each permission, counted in a single query
+func (s *PermissionService) AddRoleCounts(ctx context.Context, permissions []models.PermissionResponse) error {
+	if len(permissions) == 0 {
+		return nil
 	}
 
-	// Convert to response format
-	permissionResponses := make([]models.PermissionResponse, len(permissions))
+	ids := make([]uuid.UUID, len(permissions))
 	for i, permission := range permissions {
-		permissionResponses[i] = permission.ToResponse()
+		ids[i] = permission.ID
 	}
 
-	return permissionResponses, nil
+	counts, err := s.permissionRepo.GetUsageCounts(ctx, ids)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+
+	for i := range permissions {
+		// Permissions no role grants are missing from the counts
+		count := counts[permissions[i].ID]
+		permissions[i].RoleCount = &count
+	}
+
+	return nil
+}
+
+// GetPermissionCatalog retrieves all permissions grouped by category, then by resource. Categories
+// and resources are sorted by name, with uncategorized permissions last.
+func (s *PermissionService) GetPermissionCatalog(ctx context.Context) (*models.PermissionCatalog, error) {
+	permissions, err := s.permissionRepo.GetAll(ctx, models.SortOptions{})
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	byCategory := make(map[string]map[string][]models.PermissionResponse)
+	for _, permission := range permissions {
+		category := permission.Category
+		if category == "" {
+			category = models.UncategorizedPermissions
+		}
+		if byCategory[category] == nil {
+			byCategory[category] = make(map[string][]models.PermissionResponse)
+		}
+		byCategory[category][permission.Resource] = append(byCategory[category][permission.Resource], permission.ToResponse())
+	}
+
+	categories := make([]string, 0, len(byCategory))
+	for category := range byCategory {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if (categories[i] == models.UncategorizedPermissions) != (categories[j] == models.UncategorizedPermissions) {
+			return categories[j] == models.UncategorizedPermissions
+		}
+		return categories[i] < categories[j]
+	})
+
+	catalog := &models.PermissionCatalog{Categories: make([]models.PermissionCategory, 0, len(categories))}
+	for _, category := range categories {
+		resources := make([]string, 0, len(byCategory[category]))
+		for resource := range byCategory[category] {
+			resources = append(resources, resource)
+		}
+		sort.Strings(resources)
+
+		group := models.PermissionCategory{Name: category, Resources: make([]models.PermissionResourceGroup, 0, len(resources))}
+		for _, resource := range resources {
+			perms := byCategory[category][resource]
+			sort.Slice(perms, func(i, j int) bool { return perms[i].Action < perms[j].Action })
+			group.Resources = append(group.Resources, models.PermissionResourceGroup{Resource: resource, Permissions: perms})
+		}
+		catalog.Categories = append(catalog.Categories, group)
+	}
+
+	return catalog, nil
 }
 
 // GetPermissionsByResource retrieves all permissions for a specific resource
@@ -106,24 +239,49 @@ func (s *PermissionService) GetPermissionsByResource(ctx context.Context, resour
 	// Get permissions
 	permissions, err := s.permissionRepo.GetByResource(ctx, resource)
 	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return toResponses(permissions), nil
+}
+
+// GetPermissionsByAction retrieves the permissions for an action across every resource
+func (s *PermissionService) GetPermissionsByAction(ctx context.Context, action string) ([]models.PermissionResponse, error) {
+	permissions, err := s.permissionRepo.GetByAction(ctx, action)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return toResponses(permissions), nil
+}
+
+// GetPermissionsByResourceAction retrieves the permission for an action on a resource as a list, empty
+// when there is none, narrowing the resource's cached permissions rather than failing a lookup
+func (s *PermissionService) GetPermissionsByResourceAction(ctx context.Context, resource, action string) ([]models.PermissionResponse, error) {
+	if err := models.CheckPermissionLookup("action", action); err != nil {
 		return nil, err
 	}
 
-	// Convert to response format
-	permissionResponses := make([]models.PermissionResponse, len(permissions))
-	for i, permission := range permissions {
-		permissionResponses[i] = permission.ToResponse()
+	permissions, err := s.permissionRepo.GetByResource(ctx, resource)
+	if err != nil {
+		return nil, contextError(ctx, err)
 	}
 
-	return permissionResponses, nil
+	matching := make([]*models.Permission, 0, 1)
+	for _, permission := range permissions {
+		if permission.Action == action {
+			matching = append(matching, permission)
+		}
+	}
+	return toResponses(matching), nil
 }
 
 // UpdatePermission updates a permission
 func (s *PermissionService) UpdatePermission(ctx context.Context, id string, request models.PermissionUpdateRequest) (*models.PermissionResponse, error) {
 	// Parse UUID
-	permissionID, err := uuid.Parse(id)
+	permissionID, err := parseID("permission", id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid permission ID: %w", err)
+		return nil, err
 	}
 
 	// Get existing permission
@@ -147,14 +305,14 @@ func (s *PermissionService) UpdatePermission(ctx context.Context, id string, req
 
 		existingPermission, err := s.permissionRepo.GetByResourceAction(ctx, resourceToCheck, actionToCheck)
 		if err == nil && existingPermission != nil && existingPermission.ID != permission.ID {
-			return nil, fmt.Errorf("permission already exists for this resource and action")
+			return nil, models.ErrPermissionExists
 		}
 	}
 
+	// A name that followed the convention follows a resource or action change
+	derivedName := permission.Name == permissionName(permission.Resource, permission.Action)
+
 	// Update fields if provided
-	if request.Name != "" {
-		permission.Name = request.Name
-	}
 	if request.Description != "" {
 		permission.Description = request.Description
 	}
@@ -164,6 +322,17 @@ func (s *PermissionService) UpdatePermission(ctx context.Context, id string, req
 	if request.Action != "" {
 		permission.Action = request.Action
 	}
+	if request.Category != "" {
+		permission.Category = request.Category
+	}
+	if request.Name != "" {
+		permission.Name = request.Name
+	} else if derivedName {
+		permission.Name = permissionName(permission.Resource, permission.Action)
+	}
+	if err := s.checkPermissionName(permission.Name, permission.Resource, permission.Action); err != nil {
+		return nil, err
+	}
 	permission.UpdatedAt = time.Now()
 
 	// Start transaction
@@ -180,19 +349,98 @@ func (s *PermissionService) UpdatePermission(ctx context.Context, id string, req
 		return nil, err
 	}
 
+	// Roles granting the permission now grant it under its new resource and action
+	transaction.AfterCommit(ctx, s.permissionSnapshot.Invalidate)
+
 	// แก้ไขตรงนี้: สร้างตัวแปรก่อนแล้วค่อย return address ของตัวแปรนั้น
 	response := permission.ToResponse()
 	return &response, nil
 }
 
+// ReconcilePermissions creates the built-in permissions missing from the catalog and, when
+// updateDescriptions is set, restores the description of the built-in ones that were edited.
+// Permissions that are not built in are left untouched.
+func (s *PermissionService) ReconcilePermissions(ctx context.Context, updateDescriptions bool) (*models.PermissionReconcileResponse, error) {
+	existing, err := s.permissionRepo.GetAll(ctx, models.SortOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list permissions: %w", err)
+	}
+
+	byResourceAction := make(map[string]*models.Permission, len(existing))
+	for _, permission := range existing {
+		byResourceAction[permissionName(permission.Resource, permission.Action)] = permission
+	}
+
+	var created, updated []*models.Permission
+	unchanged := 0
+	for _, builtin := range models.BuiltinPermissions {
+		permission, ok := byResourceAction[permissionName(builtin.Resource, builtin.Action)]
+		switch {
+		case !ok:
+			created = append(created, &models.Permission{
+				Name:        builtin.Name,
+				Description: builtin.Description,
+				Resource:    builtin.Resource,
+				Action:      builtin.Action,
+				Category:    builtin.Category,
+				CreatedAt:   time.Now(),
+				UpdatedAt:   time.Now(),
+			})
+		case updateDescriptions && permission.Description != builtin.Description:
+			permission.Description = builtin.Description
+			permission.UpdatedAt = time.Now()
+			updated = append(updated, permission)
+		default:
+			unchanged++
+		}
+	}
+
+	if len(created) > 0 || len(updated) > 0 {
+		err = s.txManager.ExecuteTx(ctx, func(tx transaction.Repository) error {
+			for _, permission := range created {
+				if err := tx.CreatePermission(ctx, permission); err != nil {
+					return fmt.Errorf("failed to create permission %s: %w", permission.Name, err)
+				}
+			}
+			for _, permission := range updated {
+				if err := tx.UpdatePermission(ctx, permission); err != nil {
+					return fmt.Errorf("failed to update permission %s: %w", permission.Name, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	response := &models.PermissionReconcileResponse{
+		Created:   make([]models.PermissionResponse, 0, len(created)),
+		Updated:   make([]models.PermissionResponse, 0, len(updated)),
+		Unchanged: unchanged,
+	}
+	for _, permission := range created {
+		response.Created = append(response.Created, permission.ToResponse())
+	}
+	for _, permission := range updated {
+		response.Updated = append(response.Updated, permission.ToResponse())
+	}
+	return response, nil
+}
+
 // DeletePermission deletes a permission
 func (s *PermissionService) DeletePermission(ctx context.Context, id string) error {
 	// Parse UUID
-	permissionID, err := uuid.Parse(id)
+	permissionID, err := parseID("permission", id)
 	if err != nil {
-		return fmt.Errorf("invalid permission ID: %w", err)
+		return err
 	}
 
 	// Delete permission
-	return s.permissionRepo.Delete(ctx, permissionID)
+	if err := s.permissionRepo.Delete(ctx, permissionID); err != nil {
+		return err
+	}
+
+	s.permissionSnapshot.Invalidate()
+	return nil
 }
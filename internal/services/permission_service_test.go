@@ -3,8 +3,10 @@ package services_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 
+	"github.com/chats/go-user-api/config"
 	"github.com/chats/go-user-api/internal/mocks"
 	"github.com/chats/go-user-api/internal/models"
 	"github.com/chats/go-user-api/internal/repositories/transaction"
@@ -12,14 +14,10 @@ import (
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestPermissionService_CreatePermission(t *testing.T) {
-	mockPermissionRepo := new(mocks.MockPermissionRepository)
-	mockTxManager := new(mocks.Manager[transaction.Repository])
-
-	permissionService := services.NewPermissionService(mockPermissionRepo, mockTxManager)
-
 	request := models.PermissionCreateRequest{
 		Name:        "test-permission",
 		Description: "test-description",
@@ -27,7 +25,15 @@ func TestPermissionService_CreatePermission(t *testing.T) {
 		Action:      "test-action",
 	}
 
+	// Each subtest gets its own mocks, so expectations set by one never answer another
+	newService := func() (*services.PermissionService, *mocks.MockPermissionRepository, *mocks.Manager[transaction.Repository]) {
+		mockPermissionRepo := new(mocks.MockPermissionRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		return services.NewPermissionService(mockPermissionRepo, mockTxManager, &config.Config{}), mockPermissionRepo, mockTxManager
+	}
+
 	t.Run("Successful creation", func(t *testing.T) {
+		permissionService, mockPermissionRepo, mockTxManager := newService()
 		mockPermissionRepo.On("GetByResourceAction", mock.Anything, request.Resource, request.Action).Return(nil, nil)
 		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(nil).Run(func(args mock.Arguments) {
 			txFunc := args.Get(1).(func(transaction.Repository) error)
@@ -37,33 +43,29 @@ func TestPermissionService_CreatePermission(t *testing.T) {
 
 		response, err := permissionService.CreatePermission(context.Background(), request)
 
-		assert.NoError(t, err)
-		assert.NotNil(t, response)
+		require.NoError(t, err)
+		require.NotNil(t, response)
 		assert.Equal(t, request.Name, response.Name)
 		mockPermissionRepo.AssertExpectations(t)
 		mockTxManager.AssertExpectations(t)
 	})
 
 	t.Run("Permission already exists", func(t *testing.T) {
-		existingPermission := &models.Permission{}
+		permissionService, mockPermissionRepo, mockTxManager := newService()
+		existingPermission := &models.Permission{ID: uuid.New()}
 		mockPermissionRepo.On("GetByResourceAction", mock.Anything, request.Resource, request.Action).Return(existingPermission, nil)
 
 		response, err := permissionService.CreatePermission(context.Background(), request)
 
-		assert.Error(t, err)
+		require.Error(t, err)
 		assert.Nil(t, response)
-		assert.Contains(t, err.Error(), "permission already exists for this resource and action")
+		assert.ErrorIs(t, err, models.ErrPermissionExists)
 		mockPermissionRepo.AssertExpectations(t)
 		mockTxManager.AssertNotCalled(t, "ExecuteTx", mock.Anything, mock.Anything)
 	})
 }
 
 func TestPermissionService_UpdatePermission(t *testing.T) {
-	mockPermissionRepo := new(mocks.MockPermissionRepository)
-	mockTxManager := new(mocks.Manager[transaction.Repository])
-
-	permissionService := services.NewPermissionService(mockPermissionRepo, mockTxManager)
-
 	id := uuid.New().String()
 	request := models.PermissionUpdateRequest{
 		Name:        "updated-name",
@@ -72,7 +74,15 @@ func TestPermissionService_UpdatePermission(t *testing.T) {
 		Action:      "updated-action",
 	}
 
+	// Each subtest gets its own mocks, so expectations set by one never answer another
+	newService := func() (*services.PermissionService, *mocks.MockPermissionRepository, *mocks.Manager[transaction.Repository]) {
+		mockPermissionRepo := new(mocks.MockPermissionRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		return services.NewPermissionService(mockPermissionRepo, mockTxManager, &config.Config{}), mockPermissionRepo, mockTxManager
+	}
+
 	t.Run("Successful update", func(t *testing.T) {
+		permissionService, mockPermissionRepo, mockTxManager := newService()
 		permission := &models.Permission{
 			ID:          uuid.MustParse(id),
 			Name:        "test-permission",
@@ -90,19 +100,20 @@ func TestPermissionService_UpdatePermission(t *testing.T) {
 
 		response, err := permissionService.UpdatePermission(context.Background(), id, request)
 
-		assert.NoError(t, err)
-		assert.NotNil(t, response)
+		require.NoError(t, err)
+		require.NotNil(t, response)
 		assert.Equal(t, request.Name, response.Name)
 		mockPermissionRepo.AssertExpectations(t)
 		mockTxManager.AssertExpectations(t)
 	})
 
 	t.Run("Permission not found", func(t *testing.T) {
-		mockPermissionRepo.On("GetByID", mock.Anything, uuid.MustParse(id)).Return(nil, errors.New("permission not found"))
+		permissionService, mockPermissionRepo, mockTxManager := newService()
+		mockPermissionRepo.On("GetByID", mock.Anything, uuid.MustParse(id)).Return((*models.Permission)(nil), errors.New("permission not found"))
 
 		response, err := permissionService.UpdatePermission(context.Background(), id, request)
 
-		assert.Error(t, err)
+		require.Error(t, err)
 		assert.Nil(t, response)
 		assert.Contains(t, err.Error(), "permission not found")
 		mockPermissionRepo.AssertExpectations(t)
@@ -114,7 +125,7 @@ func TestPermissionService_DeletePermission(t *testing.T) {
 	mockPermissionRepo := new(mocks.MockPermissionRepository)
 	mockTxManager := new(mocks.Manager[transaction.Repository])
 
-	permissionService := services.NewPermissionService(mockPermissionRepo, mockTxManager)
+	permissionService := services.NewPermissionService(mockPermissionRepo, mockTxManager, &config.Config{})
 
 	id := uuid.New().String()
 
@@ -137,3 +148,381 @@ func TestPermissionService_DeletePermission(t *testing.T) {
 		mockPermissionRepo.On("Delete", mock.Anything, mock.Anything).Return(nil) // Don't do this if expecting no calls
 	})
 }
+
+func TestPermissionService_NamingConvention(t *testing.T) {
+	cfg := &config.Config{PermissionNameEnforce: true}
+
+	newService := func() (*services.PermissionService, *mocks.MockPermissionRepository, *mocks.Manager[transaction.Repository]) {
+		mockPermissionRepo := new(mocks.MockPermissionRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(nil).Run(func(args mock.Arguments) {
+			txFunc := args.Get(1).(func(transaction.Repository) error)
+			txFunc(mockPermissionRepo)
+		})
+		return services.NewPermissionService(mockPermissionRepo, mockTxManager, cfg), mockPermissionRepo, mockTxManager
+	}
+
+	t.Run("Derives name when omitted", func(t *testing.T) {
+		permissionService, mockPermissionRepo, _ := newService()
+		mockPermissionRepo.On("GetByResourceAction", mock.Anything, "user", "read").Return(nil, errors.New("permission not found"))
+		mockPermissionRepo.On("CreatePermission", mock.Anything, mock.AnythingOfType("*models.Permission")).Return(nil)
+
+		response, err := permissionService.CreatePermission(context.Background(), models.PermissionCreateRequest{
+			Resource: "user",
+			Action:   "read",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "user:read", response.Name)
+		mockPermissionRepo.AssertExpectations(t)
+	})
+
+	t.Run("Accepts matching name", func(t *testing.T) {
+		permissionService, mockPermissionRepo, _ := newService()
+		mockPermissionRepo.On("GetByResourceAction", mock.Anything, "user", "read").Return(nil, errors.New("permission not found"))
+		mockPermissionRepo.On("CreatePermission", mock.Anything, mock.AnythingOfType("*models.Permission")).Return(nil)
+
+		response, err := permissionService.CreatePermission(context.Background(), models.PermissionCreateRequest{
+			Name:     "user:read",
+			Resource: "user",
+			Action:   "read",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "user:read", response.Name)
+	})
+
+	t.Run("Rejects mismatching name", func(t *testing.T) {
+		permissionService, mockPermissionRepo, mockTxManager := newService()
+		mockPermissionRepo.On("GetByResourceAction", mock.Anything, "user", "read").Return(nil, errors.New("permission not found"))
+
+		response, err := permissionService.CreatePermission(context.Background(), models.PermissionCreateRequest{
+			Name:     "users:view",
+			Resource: "user",
+			Action:   "read",
+		})
+
+		assert.Error(t, err)
+		assert.Nil(t, response)
+		assert.Contains(t, err.Error(), `expected "user:read"`)
+		mockTxManager.AssertNotCalled(t, "ExecuteTx", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Update re-derives a conventional name", func(t *testing.T) {
+		permissionService, mockPermissionRepo, _ := newService()
+		permission := &models.Permission{ID: uuid.New(), Name: "user:read", Resource: "user", Action: "read"}
+		mockPermissionRepo.On("GetByID", mock.Anything, permission.ID).Return(permission, nil)
+		mockPermissionRepo.On("GetByResourceAction", mock.Anything, "user", "write").Return(nil, errors.New("permission not found"))
+		mockPermissionRepo.On("UpdatePermission", mock.Anything, permission).Return(nil)
+
+		response, err := permissionService.UpdatePermission(context.Background(), permission.ID.String(), models.PermissionUpdateRequest{
+			Action: "write",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "user:write", response.Name)
+	})
+
+	t.Run("Update rejects mismatching name", func(t *testing.T) {
+		permissionService, mockPermissionRepo, mockTxManager := newService()
+		permission := &models.Permission{ID: uuid.New(), Name: "user:read", Resource: "user", Action: "read"}
+		mockPermissionRepo.On("GetByID", mock.Anything, permission.ID).Return(permission, nil)
+
+		response, err := permissionService.UpdatePermission(context.Background(), permission.ID.String(), models.PermissionUpdateRequest{
+			Name: "users:view",
+		})
+
+		assert.Error(t, err)
+		assert.Nil(t, response)
+		assert.Contains(t, err.Error(), "does not match resource and action")
+		mockTxManager.AssertNotCalled(t, "ExecuteTx", mock.Anything, mock.Anything)
+	})
+}
+
+func categorizedPermissions() []*models.Permission {
+	return []*models.Permission{
+		{ID: uuid.New(), Name: "user:write", Resource: "user", Action: "write", Category: "identity"},
+		{ID: uuid.New(), Name: "report:read", Resource: "report", Action: "read"},
+		{ID: uuid.New(), Name: "user:read", Resource: "user", Action: "read", Category: "identity"},
+		{ID: uuid.New(), Name: "role:read", Resource: "role", Action: "read", Category: "identity"},
+		{ID: uuid.New(), Name: "invoice:read", Resource: "invoice", Action: "read", Category: "billing"},
+	}
+}
+
+func TestPermissionService_GetPermissionsByCategory(t *testing.T) {
+	mockPermissionRepo := new(mocks.MockPermissionRepository)
+	mockTxManager := new(mocks.Manager[transaction.Repository])
+	permissionService := services.NewPermissionService(mockPermissionRepo, mockTxManager, &config.Config{})
+
+	sort := models.SortOptions{Field: "name", Order: models.SortAsc}
+	mockPermissionRepo.On("GetAll", mock.Anything, sort).Return(categorizedPermissions(), nil)
+
+	t.Run("Only the category's permissions", func(t *testing.T) {
+		permissions, err := permissionService.GetPermissionsByCategory(context.Background(), "identity", sort)
+
+		assert.NoError(t, err)
+		names := make([]string, 0, len(permissions))
+		for _, permission := range permissions {
+			assert.Equal(t, "identity", permission.Category)
+			names = append(names, permission.Name)
+		}
+		assert.Equal(t, []string{"user:write", "user:read", "role:read"}, names)
+	})
+
+	t.Run("Unknown category", func(t *testing.T) {
+		permissions, err := permissionService.GetPermissionsByCategory(context.Background(), "missing", sort)
+
+		assert.NoError(t, err)
+		assert.Empty(t, permissions)
+	})
+}
+
+func TestPermissionService_GetPermissionsByAction(t *testing.T) {
+	mockPermissionRepo := new(mocks.MockPermissionRepository)
+	permissionService := services.NewPermissionService(mockPermissionRepo, new(mocks.Manager[transaction.Repository]), &config.Config{})
+
+	t.Run("One action across resources", func(t *testing.T) {
+		mockPermissionRepo.On("GetByAction", mock.Anything, "read").Return([]*models.Permission{
+			{ID: uuid.New(), Name: "invoice:read", Resource: "invoice", Action: "read"},
+			{ID: uuid.New(), Name: "role:read", Resource: "role", Action: "read"},
+			{ID: uuid.New(), Name: "user:read", Resource: "user", Action: "read"},
+		}, nil)
+
+		permissions, err := permissionService.GetPermissionsByAction(context.Background(), "read")
+
+		assert.NoError(t, err)
+		resources := make([]string, 0, len(permissions))
+		for _, permission := range permissions {
+			assert.Equal(t, "read", permission.Action)
+			resources = append(resources, permission.Resource)
+		}
+		assert.Equal(t, []string{"invoice", "role", "user"}, resources)
+	})
+
+	t.Run("Resource and action narrow to one", func(t *testing.T) {
+		mockPermissionRepo.On("GetByResource", mock.Anything, "user").Return([]*models.Permission{
+			{ID: uuid.New(), Name: "user:read", Resource: "user", Action: "read"},
+			{ID: uuid.New(), Name: "user:write", Resource: "user", Action: "write"},
+		}, nil)
+
+		permissions, err := permissionService.GetPermissionsByResourceAction(context.Background(), "user", "read")
+
+		assert.NoError(t, err)
+		require.Len(t, permissions, 1)
+		assert.Equal(t, "user:read", permissions[0].Name)
+
+		permissions, err = permissionService.GetPermissionsByResourceAction(context.Background(), "user", "delete")
+
+		assert.NoError(t, err)
+		assert.Empty(t, permissions)
+	})
+
+	t.Run("Blank action rejected", func(t *testing.T) {
+		_, err := permissionService.GetPermissionsByResourceAction(context.Background(), "user", " ")
+
+		assert.ErrorIs(t, err, models.ErrBlankPermissionLookup)
+	})
+}
+
+func TestPermissionService_AddRoleCounts(t *testing.T) {
+	mockPermissionRepo := new(mocks.MockPermissionRepository)
+	permissionService := services.NewPermissionService(mockPermissionRepo, new(mocks.Manager[transaction.Repository]), &config.Config{})
+
+	shared, unused := uuid.New(), uuid.New()
+	permissions := []models.PermissionResponse{{ID: shared, Name: "user:read"}, {ID: unused, Name: "user:purge"}}
+	mockPermissionRepo.On("GetUsageCounts", mock.Anything, []uuid.UUID{shared, unused}).
+		Return(map[uuid.UUID]int{shared: 3}, nil).Once()
+
+	err := permissionService.AddRoleCounts(context.Background(), permissions)
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, permissions[0].RoleCount) {
+		assert.Equal(t, 3, *permissions[0].RoleCount)
+	}
+	// An unused permission reports zero rather than omitting the count
+	if assert.NotNil(t, permissions[1].RoleCount) {
+		assert.Equal(t, 0, *permissions[1].RoleCount)
+	}
+	mockPermissionRepo.AssertExpectations(t)
+}
+
+func TestPermissionService_ReconcilePermissions(t *testing.T) {
+	// The catalog lacks user:delete, has an edited role:read and a custom permission
+	catalog := func() []*models.Permission {
+		permissions := []*models.Permission{
+			{ID: uuid.New(), Name: "report:export", Resource: "report", Action: "export", Description: "Export reports"},
+		}
+		for _, builtin := range models.BuiltinPermissions {
+			if builtin.Name == "user:delete" {
+				continue
+			}
+			permission := builtin
+			permission.ID = uuid.New()
+			if permission.Name == "role:read" {
+				permission.Description = "Edited by an admin"
+			}
+			permissions = append(permissions, &permission)
+		}
+		return permissions
+	}
+
+	setup := func() (*services.PermissionService, *mocks.MockPermissionRepository) {
+		mockPermissionRepo := new(mocks.MockPermissionRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockPermissionRepo.On("GetAll", mock.Anything, models.SortOptions{}).Return(catalog(), nil).Once()
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(nil).Run(func(args mock.Arguments) {
+			args.Get(1).(func(transaction.Repository) error)(mockPermissionRepo)
+		})
+		return services.NewPermissionService(mockPermissionRepo, mockTxManager, &config.Config{}), mockPermissionRepo
+	}
+
+	t.Run("Creates missing built-in permissions and preserves the rest", func(t *testing.T) {
+		permissionService, mockPermissionRepo := setup()
+		mockPermissionRepo.On("CreatePermission", mock.Anything, mock.MatchedBy(func(p *models.Permission) bool {
+			return p.Name == "user:delete" && p.Resource == "user" && p.Action == "delete" && p.Description == "Delete users"
+		})).Return(nil).Once()
+
+		result, err := permissionService.ReconcilePermissions(context.Background(), false)
+
+		assert.NoError(t, err)
+		if assert.Len(t, result.Created, 1) {
+			assert.Equal(t, "user:delete", result.Created[0].Name)
+		}
+		assert.Empty(t, result.Updated)
+		assert.Equal(t, len(models.BuiltinPermissions)-1, result.Unchanged)
+		mockPermissionRepo.AssertNotCalled(t, "UpdatePermission", mock.Anything, mock.Anything)
+		mockPermissionRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+		mockPermissionRepo.AssertExpectations(t)
+	})
+
+	t.Run("Restores edited built-in descriptions when asked", func(t *testing.T) {
+		permissionService, mockPermissionRepo := setup()
+		mockPermissionRepo.On("CreatePermission", mock.Anything, mock.AnythingOfType("*models.Permission")).Return(nil).Once()
+		mockPermissionRepo.On("UpdatePermission", mock.Anything, mock.MatchedBy(func(p *models.Permission) bool {
+			return p.Name == "role:read" && p.Description == "View role information"
+		})).Return(nil).Once()
+
+		result, err := permissionService.ReconcilePermissions(context.Background(), true)
+
+		assert.NoError(t, err)
+		assert.Len(t, result.Created, 1)
+		if assert.Len(t, result.Updated, 1) {
+			assert.Equal(t, "role:read", result.Updated[0].Name)
+		}
+		assert.Equal(t, len(models.BuiltinPermissions)-2, result.Unchanged)
+		mockPermissionRepo.AssertExpectations(t)
+	})
+
+	t.Run("Nothing to do skips the transaction", func(t *testing.T) {
+		mockPermissionRepo := new(mocks.MockPermissionRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		existing := make([]*models.Permission, 0, len(models.BuiltinPermissions))
+		for _, builtin := range models.BuiltinPermissions {
+			permission := builtin
+			existing = append(existing, &permission)
+		}
+		mockPermissionRepo.On("GetAll", mock.Anything, models.SortOptions{}).Return(existing, nil).Once()
+		permissionService := services.NewPermissionService(mockPermissionRepo, mockTxManager, &config.Config{})
+
+		result, err := permissionService.ReconcilePermissions(context.Background(), true)
+
+		assert.NoError(t, err)
+		assert.Empty(t, result.Created)
+		assert.Equal(t, len(models.BuiltinPermissions), result.Unchanged)
+		mockTxManager.AssertNotCalled(t, "ExecuteTx", mock.Anything, mock.Anything)
+	})
+}
+
+func TestPermissionService_GetPermissionCatalog(t *testing.T) {
+	t.Run("Grouped by category then resource", func(t *testing.T) {
+		mockPermissionRepo := new(mocks.MockPermissionRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		permissionService := services.NewPermissionService(mockPermissionRepo, mockTxManager, &config.Config{})
+		mockPermissionRepo.On("GetAll", mock.Anything, models.SortOptions{}).Return(categorizedPermissions(), nil)
+
+		catalog, err := permissionService.GetPermissionCatalog(context.Background())
+
+		assert.NoError(t, err)
+		if assert.Len(t, catalog.Categories, 3) {
+			assert.Equal(t, "billing", catalog.Categories[0].Name)
+			assert.Equal(t, "identity", catalog.Categories[1].Name)
+			assert.Equal(t, models.UncategorizedPermissions, catalog.Categories[2].Name)
+
+			identity := catalog.Categories[1].Resources
+			if assert.Len(t, identity, 2) {
+				assert.Equal(t, "role", identity[0].Resource)
+				assert.Equal(t, "user", identity[1].Resource)
+				if assert.Len(t, identity[1].Permissions, 2) {
+					assert.Equal(t, "read", identity[1].Permissions[0].Action)
+					assert.Equal(t, "write", identity[1].Permissions[1].Action)
+				}
+			}
+
+			uncategorized := catalog.Categories[2].Resources
+			if assert.Len(t, uncategorized, 1) {
+				assert.Equal(t, "report", uncategorized[0].Resource)
+			}
+		}
+	})
+
+	t.Run("Repository error", func(t *testing.T) {
+		mockPermissionRepo := new(mocks.MockPermissionRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		permissionService := services.NewPermissionService(mockPermissionRepo, mockTxManager, &config.Config{})
+		mockPermissionRepo.On("GetAll", mock.Anything, models.SortOptions{}).Return([]*models.Permission(nil), errors.New("db down"))
+
+		catalog, err := permissionService.GetPermissionCatalog(context.Background())
+
+		assert.Error(t, err)
+		assert.Nil(t, catalog)
+	})
+}
+
+func TestPermissionService_GetAllPermissions_ContextEnded(t *testing.T) {
+	tests := []struct {
+		name    string
+		repoErr error
+		want    error
+	}{
+		{"Client canceled", context.Canceled, services.ErrRequestCanceled},
+		{"Deadline passed", context.DeadlineExceeded, services.ErrRequestTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockPermissionRepo := new(mocks.MockPermissionRepository)
+			permissionService := services.NewPermissionService(mockPermissionRepo, new(mocks.Manager[transaction.Repository]), &config.Config{})
+			mockPermissionRepo.On("GetAll", mock.Anything, models.SortOptions{}).Return([]*models.Permission(nil), fmt.Errorf("failed to get permissions: %w", tt.repoErr))
+
+			_, err := permissionService.GetAllPermissions(context.Background(), models.SortOptions{})
+
+			assert.ErrorIs(t, err, tt.want)
+			assert.ErrorIs(t, err, tt.repoErr)
+		})
+	}
+
+	t.Run("Canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// Drivers do not always wrap the context error, so the context itself is checked too
+		mockPermissionRepo := new(mocks.MockPermissionRepository)
+		permissionService := services.NewPermissionService(mockPermissionRepo, new(mocks.Manager[transaction.Repository]), &config.Config{})
+		mockPermissionRepo.On("GetAll", mock.Anything, models.SortOptions{}).Return([]*models.Permission(nil), errors.New("connection closed"))
+
+		_, err := permissionService.GetAllPermissions(ctx, models.SortOptions{})
+
+		assert.ErrorIs(t, err, services.ErrRequestCanceled)
+	})
+
+	t.Run("Other errors are unchanged", func(t *testing.T) {
+		repoErr := errors.New("db down")
+		mockPermissionRepo := new(mocks.MockPermissionRepository)
+		permissionService := services.NewPermissionService(mockPermissionRepo, new(mocks.Manager[transaction.Repository]), &config.Config{})
+		mockPermissionRepo.On("GetAll", mock.Anything, models.SortOptions{}).Return([]*models.Permission(nil), repoErr)
+
+		_, err := permissionService.GetAllPermissions(context.Background(), models.SortOptions{})
+
+		assert.Equal(t, repoErr, err)
+	})
+}
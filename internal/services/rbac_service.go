@@ -0,0 +1,452 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/repositories"
+	"github.com/chats/go-user-api/internal/repositories/transaction"
+	"github.com/google/uuid"
+)
+
+// ErrRBACConflict is returned by ImportRBAC when the document clashes with permissions already stored
+var ErrRBACConflict = errors.New("RBAC import conflict")
+
+// adminRoleName is the role admin-only routes check, so an import never prunes it
+const adminRoleName = "admin"
+
+// RBACService exports and imports the whole role and permission configuration
+type RBACService struct {
+	roleRepo       repositories.RoleRepositoryInterface
+	permissionRepo repositories.PermissionRepositoryInterface
+	txManager      transaction.Manager[transaction.Repository]
+	enforceNaming  bool
+
+	// autoCreatePermissions lets roles reference resource:action permissions the document does not define
+	autoCreatePermissions bool
+
+	// cacheInvalidator clears every cached role, permission and user after an import; nil leaves
+	// the repositories' own invalidation
+	cacheInvalidator *repositories.CacheInvalidator
+
+	// permissionSnapshot is marked stale after each import
+	permissionSnapshot *RolePermissionSnapshot
+}
+
+// NewRBACService creates a new RBAC service
+func NewRBACService(
+	roleRepo repositories.RoleRepositoryInterface,
+	permissionRepo repositories.PermissionRepositoryInterface,
+	txManager transaction.Manager[transaction.Repository],
+	cfg *config.Config,
+) *RBACService {
+	return &RBACService{
+		roleRepo:       roleRepo,
+		permissionRepo: permissionRepo,
+		txManager:      txManager,
+		enforceNaming:  cfg.PermissionNameEnforce,
+
+		autoCreatePermissions: cfg.AutoCreateMissingPermissions,
+	}
+}
+
+// UseCacheInvalidator clears the role, permission and user caches after each import, since the
+// import rewrites them in a transaction the repositories do not see
+func (s *RBACService) UseCacheInvalidator(invalidator *repositories.CacheInvalidator) {
+	s.cacheInvalidator = invalidator
+}
+
+// UsePermissionSnapshot marks the snapshot stale after each import
+func (s *RBACService) UsePermissionSnapshot(snapshot *RolePermissionSnapshot) {
+	s.permissionSnapshot = snapshot
+}
+
+// ExportRBAC returns every permission and role, with role permissions referenced by name, sorted by name
+func (s *RBACService) ExportRBAC(ctx context.Context) (*models.RBACDocument, error) {
+	permissions, err := s.permissionRepo.GetAll(ctx, models.SortOptions{})
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	roles, err := s.roleRepo.GetAll(ctx, true, models.SortOptions{})
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	document := &models.RBACDocument{
+		Version:     models.RBACDocumentVersion,
+		Permissions: make([]models.RBACPermission, 0, len(permissions)),
+		Roles:       make([]models.RBACRole, 0, len(roles)),
+	}
+
+	for _, permission := range permissions {
+		document.Permissions = append(document.Permissions, models.RBACPermission{
+			Name:        permission.Name,
+			Description: permission.Description,
+			Resource:    permission.Resource,
+			Action:      permission.Action,
+		})
+	}
+	sort.Slice(document.Permissions, func(i, j int) bool {
+		return document.Permissions[i].Name < document.Permissions[j].Name
+	})
+
+	for _, role := range roles {
+		names := make([]string, 0, len(role.Permissions))
+		for _, permission := range role.Permissions {
+			names = append(names, permission.Name)
+		}
+		sort.Strings(names)
+
+		document.Roles = append(document.Roles, models.RBACRole{
+			Name:        role.Name,
+			Description: role.Description,
+			Permissions: names,
+		})
+	}
+	sort.Slice(document.Roles, func(i, j int) bool {
+		return document.Roles[i].Name < document.Roles[j].Name
+	})
+
+	return document, nil
+}
+
+// ImportRBAC applies a document in one transaction. Permissions and roles are matched by name,
+// created when missing and updated when they differ; importing the same document twice changes
+// nothing. With prune, roles and permissions missing from the document are deleted. When missing
+// permissions are auto-created, a role may also reference a resource:action the document does not
+// define; it is created in the same transaction unless it already exists.
+func (s *RBACService) ImportRBAC(ctx context.Context, document models.RBACDocument, prune bool) (*models.RBACImportResponse, error) {
+	implied, err := s.validateDocument(&document, prune)
+	if err != nil {
+		return nil, err
+	}
+
+	existingPermissions, err := s.permissionRepo.GetAll(ctx, models.SortOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	existingRoles, err := s.roleRepo.GetAll(ctx, true, models.SortOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	permissionsByName := make(map[string]*models.Permission, len(existingPermissions))
+	permissionsByResourceAction := make(map[string]*models.Permission, len(existingPermissions))
+	for _, permission := range existingPermissions {
+		permissionsByName[permission.Name] = permission
+		permissionsByResourceAction[permissionName(permission.Resource, permission.Action)] = permission
+	}
+
+	// An implied permission that already exists is kept as stored rather than given the derived description
+	for i := range document.Permissions {
+		entry := &document.Permissions[i]
+		if !implied[entry.Name] {
+			continue
+		}
+		if existing, ok := permissionsByName[entry.Name]; ok {
+			entry.Description = existing.Description
+			entry.Resource = existing.Resource
+			entry.Action = existing.Action
+		}
+	}
+
+	rolesByName := make(map[string]*models.Role, len(existingRoles))
+	for _, role := range existingRoles {
+		rolesByName[role.Name] = role
+	}
+
+	// Resource and action are unique, so they cannot move to a permission of another name
+	conflicts := make([]string, 0)
+	for _, permission := range document.Permissions {
+		key := permissionName(permission.Resource, permission.Action)
+		if holder, ok := permissionsByResourceAction[key]; ok && holder.Name != permission.Name {
+			conflicts = append(conflicts, fmt.Sprintf("permission %q: %s already belongs to permission %q", permission.Name, key, holder.Name))
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrRBACConflict, strings.Join(conflicts, "; "))
+	}
+
+	response := &models.RBACImportResponse{
+		PermissionsCreated: make([]string, 0),
+		PermissionsUpdated: make([]string, 0),
+		PermissionsDeleted: make([]string, 0),
+		RolesCreated:       make([]string, 0),
+		RolesUpdated:       make([]string, 0),
+		RolesDeleted:       make([]string, 0),
+	}
+
+	err = s.txManager.ExecuteTx(ctx, func(tx transaction.Repository) error {
+		now := time.Now()
+		permissionIDs := make(map[string]uuid.UUID, len(document.Permissions))
+
+		for _, entry := range document.Permissions {
+			existing, ok := permissionsByName[entry.Name]
+			if !ok {
+				permission := &models.Permission{
+					Name:        entry.Name,
+					Description: entry.Description,
+					Resource:    entry.Resource,
+					Action:      entry.Action,
+					CreatedAt:   now,
+					UpdatedAt:   now,
+				}
+				if err := tx.CreatePermission(ctx, permission); err != nil {
+					return fmt.Errorf("failed to create permission %q: %w", entry.Name, err)
+				}
+				permissionIDs[entry.Name] = permission.ID
+				response.PermissionsCreated = append(response.PermissionsCreated, entry.Name)
+				continue
+			}
+
+			permissionIDs[entry.Name] = existing.ID
+			if existing.Description == entry.Description && existing.Resource == entry.Resource && existing.Action == entry.Action {
+				continue
+			}
+
+			permission := *existing
+			permission.Description = entry.Description
+			permission.Resource = entry.Resource
+			permission.Action = entry.Action
+			permission.UpdatedAt = now
+			if err := tx.UpdatePermission(ctx, &permission); err != nil {
+				return fmt.Errorf("failed to update permission %q: %w", entry.Name, err)
+			}
+			response.PermissionsUpdated = append(response.PermissionsUpdated, entry.Name)
+		}
+
+		for _, entry := range document.Roles {
+			wanted := make([]uuid.UUID, 0, len(entry.Permissions))
+			for _, name := range entry.Permissions {
+				wanted = append(wanted, permissionIDs[name])
+			}
+
+			existing, ok := rolesByName[entry.Name]
+			if !ok {
+				role := &models.Role{
+					Name:        entry.Name,
+					Description: entry.Description,
+					CreatedAt:   now,
+					UpdatedAt:   now,
+				}
+				if err := tx.CreateRole(ctx, role); err != nil {
+					return fmt.Errorf("failed to create role %q: %w", entry.Name, err)
+				}
+				if len(wanted) > 0 {
+					if err := tx.AssignPermissionsToRole(ctx, role.ID, wanted); err != nil {
+						return fmt.Errorf("failed to assign permissions to role %q: %w", entry.Name, err)
+					}
+				}
+				response.RolesCreated = append(response.RolesCreated, entry.Name)
+				continue
+			}
+
+			changed := false
+			if existing.Description != entry.Description {
+				role := *existing
+				role.Description = entry.Description
+				role.UpdatedAt = now
+				if err := tx.UpdateRole(ctx, &role); err != nil {
+					return fmt.Errorf("failed to update role %q: %w", entry.Name, err)
+				}
+				changed = true
+			}
+			if !samePermissionNames(existing.Permissions, entry.Permissions) {
+				if err := tx.AssignPermissionsToRole(ctx, existing.ID, wanted); err != nil {
+					return fmt.Errorf("failed to assign permissions to role %q: %w", entry.Name, err)
+				}
+				changed = true
+			}
+			if changed {
+				response.RolesUpdated = append(response.RolesUpdated, entry.Name)
+			}
+		}
+
+		if !prune {
+			return nil
+		}
+
+		documentRoles := make(map[string]bool, len(document.Roles))
+		for _, entry := range document.Roles {
+			documentRoles[entry.Name] = true
+		}
+
+		// Roles first, so no mapping points at a permission while it is deleted
+		for _, role := range existingRoles {
+			if documentRoles[role.Name] {
+				continue
+			}
+			if err := tx.DeleteRole(ctx, role.ID); err != nil {
+				return fmt.Errorf("failed to delete role %q: %w", role.Name, err)
+			}
+			response.RolesDeleted = append(response.RolesDeleted, role.Name)
+		}
+		for _, permission := range existingPermissions {
+			if _, ok := permissionIDs[permission.Name]; ok {
+				continue
+			}
+			if err := tx.DeletePermission(ctx, permission.ID); err != nil {
+				return fmt.Errorf("failed to delete permission %q: %w", permission.Name, err)
+			}
+			response.PermissionsDeleted = append(response.PermissionsDeleted, permission.Name)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Roles and permissions were rewritten in the transaction, so cached resolutions are stale, as
+	// are cached permissions and users embedding their roles. Failures are logged by the invalidator.
+	transaction.AfterCommit(ctx, func() {
+		s.roleRepo.InvalidatePermissionCache()
+		s.permissionSnapshot.Invalidate()
+		if s.cacheInvalidator != nil {
+			s.cacheInvalidator.InvalidateAllPermissions()
+			s.cacheInvalidator.InvalidateAllRoles()
+			s.cacheInvalidator.InvalidateAllUsers()
+		}
+	})
+
+	return response, nil
+}
+
+// validateDocument checks the document on its own before anything is read or written: names are
+// unique, resource and action are set and unique, and roles only reference permissions in the document.
+// Omitted permission names default to resource:action and duplicate role permissions are dropped.
+// When missing permissions are auto-created, a resource:action reference the document does not define
+// is added to its permissions instead, and the returned set names these implied permissions.
+func (s *RBACService) validateDocument(document *models.RBACDocument, prune bool) (map[string]bool, error) {
+	if document.Version != 0 && document.Version != models.RBACDocumentVersion {
+		return nil, fmt.Errorf("unsupported RBAC document version %d, expected %d", document.Version, models.RBACDocumentVersion)
+	}
+
+	problems := make([]string, 0)
+
+	permissionNames := make(map[string]bool, len(document.Permissions))
+	resourceActions := make(map[string]string, len(document.Permissions))
+	for i := range document.Permissions {
+		permission := &document.Permissions[i]
+		if permission.Resource == "" || permission.Action == "" {
+			problems = append(problems, fmt.Sprintf("permission %q: resource and action are required", permission.Name))
+			continue
+		}
+
+		key := permissionName(permission.Resource, permission.Action)
+		if permission.Name == "" {
+			permission.Name = key
+		}
+		if s.enforceNaming && permission.Name != key {
+			problems = append(problems, fmt.Sprintf("permission %q: name does not match resource and action, expected %q", permission.Name, key))
+		}
+
+		if permissionNames[permission.Name] {
+			problems = append(problems, fmt.Sprintf("duplicate permission %q", permission.Name))
+		}
+		permissionNames[permission.Name] = true
+
+		if other, ok := resourceActions[key]; ok {
+			problems = append(problems, fmt.Sprintf("permission %q: %s is also used by permission %q", permission.Name, key, other))
+		}
+		resourceActions[key] = permission.Name
+	}
+
+	implied := make(map[string]bool)
+	roleNames := make(map[string]bool, len(document.Roles))
+	for i := range document.Roles {
+		role := &document.Roles[i]
+		if role.Name == "" {
+			problems = append(problems, "role name is required")
+			continue
+		}
+		if roleNames[role.Name] {
+			problems = append(problems, fmt.Sprintf("duplicate role %q", role.Name))
+		}
+		roleNames[role.Name] = true
+
+		seen := make(map[string]bool, len(role.Permissions))
+		names := make([]string, 0, len(role.Permissions))
+		for _, name := range role.Permissions {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			if !permissionNames[name] {
+				permission, ok := s.impliedPermission(name)
+				if !ok {
+					problems = append(problems, fmt.Sprintf("role %q references unknown permission %q", role.Name, name))
+					continue
+				}
+				if other, ok := resourceActions[name]; ok {
+					problems = append(problems, fmt.Sprintf("role %q references %q, but it is the resource and action of permission %q", role.Name, name, other))
+					continue
+				}
+
+				document.Permissions = append(document.Permissions, permission)
+				permissionNames[name] = true
+				resourceActions[name] = name
+				implied[name] = true
+			}
+			names = append(names, name)
+		}
+		role.Permissions = names
+	}
+
+	if prune && !roleNames[adminRoleName] {
+		problems = append(problems, fmt.Sprintf("pruning would delete the %q role, include it in the document", adminRoleName))
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid RBAC document: %s", strings.Join(problems, "; "))
+	}
+	return implied, nil
+}
+
+// impliedPermission derives a permission from a resource:action reference when missing permissions
+// are auto-created
+func (s *RBACService) impliedPermission(name string) (models.RBACPermission, bool) {
+	if !s.autoCreatePermissions {
+		return models.RBACPermission{}, false
+	}
+
+	resource, action, ok := strings.Cut(name, ":")
+	if !ok || resource == "" || action == "" || strings.Contains(action, ":") {
+		return models.RBACPermission{}, false
+	}
+
+	return models.RBACPermission{
+		Name:        name,
+		Description: fmt.Sprintf("Created automatically: %s on %s", action, resource),
+		Resource:    resource,
+		Action:      action,
+	}, true
+}
+
+// samePermissionNames reports whether a role's permissions are exactly the named ones
+func samePermissionNames(permissions []models.Permission, names []string) bool {
+	if len(permissions) != len(names) {
+		return false
+	}
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+	for _, permission := range permissions {
+		if !wanted[permission.Name] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,320 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/cache"
+	"github.com/chats/go-user-api/internal/mocks"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/repositories"
+	"github.com/chats/go-user-api/internal/repositories/transaction"
+	"github.com/chats/go-user-api/internal/services"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// rbacStore records what an import writes through the transaction so it can be exported again
+type rbacStore struct {
+	permissions []*models.Permission
+	roles       []*models.Role
+}
+
+func (s *rbacStore) permission(id uuid.UUID) models.Permission {
+	for _, permission := range s.permissions {
+		if permission.ID == id {
+			return *permission
+		}
+	}
+	return models.Permission{}
+}
+
+// newRBACService returns a service over the given roles and permissions whose transaction
+// writes into store
+func newRBACService(roles []*models.Role, permissions []*models.Permission, store *rbacStore) (*services.RBACService, *mocks.MockTxRepository, *mocks.Manager[transaction.Repository]) {
+	return newRBACServiceWithConfig(roles, permissions, store, &config.Config{PermissionNameEnforce: true})
+}
+
+// newRBACServiceWithConfig is newRBACService with the given configuration
+func newRBACServiceWithConfig(roles []*models.Role, permissions []*models.Permission, store *rbacStore, cfg *config.Config) (*services.RBACService, *mocks.MockTxRepository, *mocks.Manager[transaction.Repository]) {
+	mockRoleRepo := new(mocks.MockRoleRepository)
+	mockPermissionRepo := new(mocks.MockPermissionRepository)
+	mockTxManager := new(mocks.Manager[transaction.Repository])
+	mockTxRepo := new(mocks.MockTxRepository)
+
+	mockRoleRepo.On("GetAll", mock.Anything, true, models.SortOptions{}).Return(roles, nil)
+	mockRoleRepo.On("InvalidatePermissionCache").Return()
+	mockPermissionRepo.On("GetAll", mock.Anything, models.SortOptions{}).Return(permissions, nil)
+	mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(nil).Run(func(args mock.Arguments) {
+		txFunc := args.Get(1).(func(transaction.Repository) error)
+		txFunc(mockTxRepo)
+	})
+
+	mockTxRepo.On("CreatePermission", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		permission := args.Get(1).(*models.Permission)
+		permission.ID = uuid.New()
+		store.permissions = append(store.permissions, permission)
+	})
+	mockTxRepo.On("CreateRole", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		role := args.Get(1).(*models.Role)
+		role.ID = uuid.New()
+		store.roles = append(store.roles, role)
+	})
+	mockTxRepo.On("AssignPermissionsToRole", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		roleID := args.Get(1).(uuid.UUID)
+		for _, role := range store.roles {
+			if role.ID == roleID {
+				role.Permissions = nil
+				for _, id := range args.Get(2).([]uuid.UUID) {
+					role.Permissions = append(role.Permissions, store.permission(id))
+				}
+			}
+		}
+	})
+
+	return services.NewRBACService(mockRoleRepo, mockPermissionRepo, mockTxManager, cfg), mockTxRepo, mockTxManager
+}
+
+func TestRBACService_ExportImportRoundTrip(t *testing.T) {
+	userRead := &models.Permission{ID: uuid.New(), Name: "user:read", Description: "Read users", Resource: "user", Action: "read"}
+	userWrite := &models.Permission{ID: uuid.New(), Name: "user:write", Description: "Write users", Resource: "user", Action: "write"}
+	roleRead := &models.Permission{ID: uuid.New(), Name: "role:read", Resource: "role", Action: "read"}
+	permissions := []*models.Permission{userWrite, userRead, roleRead}
+	roles := []*models.Role{
+		{ID: uuid.New(), Name: "viewer", Description: "Read only", Permissions: []models.Permission{*userRead, *roleRead}},
+		{ID: uuid.New(), Name: "admin", Description: "Administrator", Permissions: []models.Permission{*userWrite, *userRead, *roleRead}},
+		{ID: uuid.New(), Name: "guest"},
+	}
+
+	source, _, _ := newRBACService(roles, permissions, &rbacStore{})
+	exported, err := source.ExportRBAC(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, models.RBACDocumentVersion, exported.Version)
+	assert.Equal(t, []string{"role:read", "user:read", "user:write"}, []string{exported.Permissions[0].Name, exported.Permissions[1].Name, exported.Permissions[2].Name})
+	assert.Equal(t, models.RBACRole{Name: "admin", Description: "Administrator", Permissions: []string{"role:read", "user:read", "user:write"}}, exported.Roles[0])
+
+	// Import into an empty store
+	store := &rbacStore{}
+	target, _, _ := newRBACService([]*models.Role{}, []*models.Permission{}, store)
+	result, err := target.ImportRBAC(context.Background(), *exported, false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"role:read", "user:read", "user:write"}, result.PermissionsCreated)
+	assert.Equal(t, []string{"admin", "guest", "viewer"}, result.RolesCreated)
+	assert.Empty(t, result.PermissionsUpdated)
+	assert.Empty(t, result.RolesUpdated)
+
+	// Exporting the imported store gives back the same document
+	reexport, _, _ := newRBACService(store.roles, store.permissions, &rbacStore{})
+	roundTripped, err := reexport.ExportRBAC(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, exported, roundTripped)
+
+	// Importing the same document again changes nothing
+	again, mockTxRepo, _ := newRBACService(store.roles, store.permissions, &rbacStore{})
+	result, err = again.ImportRBAC(context.Background(), *exported, true)
+
+	assert.NoError(t, err)
+	assert.Empty(t, result.PermissionsCreated)
+	assert.Empty(t, result.RolesCreated)
+	assert.Empty(t, result.RolesDeleted)
+	mockTxRepo.AssertNotCalled(t, "UpdatePermission", mock.Anything, mock.Anything)
+	mockTxRepo.AssertNotCalled(t, "AssignPermissionsToRole", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRBACService_ImportRBAC(t *testing.T) {
+	userRead := &models.Permission{ID: uuid.New(), Name: "user:read", Resource: "user", Action: "read"}
+	legacy := &models.Permission{ID: uuid.New(), Name: "legacy:run", Resource: "legacy", Action: "run"}
+	admin := &models.Role{ID: uuid.New(), Name: "admin", Permissions: []models.Permission{*userRead}}
+	auditor := &models.Role{ID: uuid.New(), Name: "auditor"}
+
+	t.Run("Updates by name and prunes extras", func(t *testing.T) {
+		rbacService, mockTxRepo, _ := newRBACService([]*models.Role{admin, auditor}, []*models.Permission{userRead, legacy}, &rbacStore{})
+		mockTxRepo.On("UpdatePermission", mock.Anything, mock.Anything).Return(nil)
+		mockTxRepo.On("DeleteRole", mock.Anything, auditor.ID).Return(nil)
+		mockTxRepo.On("DeletePermission", mock.Anything, legacy.ID).Return(nil)
+
+		result, err := rbacService.ImportRBAC(context.Background(), models.RBACDocument{
+			Permissions: []models.RBACPermission{
+				{Name: "user:read", Description: "Read users", Resource: "user", Action: "read"},
+				{Resource: "user", Action: "write"},
+			},
+			Roles: []models.RBACRole{
+				{Name: "admin", Permissions: []string{"user:read", "user:write", "user:write"}},
+			},
+		}, true)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"user:write"}, result.PermissionsCreated)
+		assert.Equal(t, []string{"user:read"}, result.PermissionsUpdated)
+		assert.Equal(t, []string{"admin"}, result.RolesUpdated)
+		assert.Equal(t, []string{"auditor"}, result.RolesDeleted)
+		assert.Equal(t, []string{"legacy:run"}, result.PermissionsDeleted)
+		mockTxRepo.AssertCalled(t, "UpdatePermission", mock.Anything, mock.MatchedBy(func(permission *models.Permission) bool {
+			return permission.ID == userRead.ID && permission.Description == "Read users"
+		}))
+		mockTxRepo.AssertCalled(t, "AssignPermissionsToRole", mock.Anything, admin.ID, mock.MatchedBy(func(ids []uuid.UUID) bool {
+			return len(ids) == 2 && ids[0] == userRead.ID
+		}))
+	})
+
+	t.Run("Clears cached permissions and users", func(t *testing.T) {
+		redisServer := miniredis.RunT(t)
+		redisClient, err := cache.NewRedisClient(&config.Config{RedisHost: redisServer.Host(), RedisPort: redisServer.Port(), RedisCacheTTL: 60})
+		require.NoError(t, err)
+		defer redisClient.Close()
+		for _, key := range []string{"permission:" + userRead.ID.String(), "permissions:all", "user:" + uuid.NewString(), "users:count"} {
+			require.NoError(t, redisServer.Set(key, "{}"))
+		}
+
+		rbacService, _, _ := newRBACService([]*models.Role{admin}, []*models.Permission{userRead}, &rbacStore{})
+		rbacService.UseCacheInvalidator(repositories.NewCacheInvalidator(redisClient))
+
+		_, err = rbacService.ImportRBAC(context.Background(), models.RBACDocument{
+			Permissions: []models.RBACPermission{{Name: "user:read", Resource: "user", Action: "read"}},
+			Roles:       []models.RBACRole{{Name: "admin", Permissions: []string{"user:read"}}},
+		}, false)
+
+		require.NoError(t, err)
+		assert.Empty(t, redisServer.Keys())
+	})
+
+	t.Run("Conflicting resource and action", func(t *testing.T) {
+		// Stored before names had to follow resource:action
+		readUsers := &models.Permission{ID: uuid.New(), Name: "read-users", Resource: "user", Action: "read"}
+		rbacService, _, mockTxManager := newRBACService([]*models.Role{admin}, []*models.Permission{readUsers}, &rbacStore{})
+
+		result, err := rbacService.ImportRBAC(context.Background(), models.RBACDocument{
+			Permissions: []models.RBACPermission{{Name: "user:read", Resource: "user", Action: "read"}},
+		}, false)
+
+		assert.Nil(t, result)
+		assert.True(t, errors.Is(err, services.ErrRBACConflict))
+		assert.Contains(t, err.Error(), `user:read already belongs to permission "read-users"`)
+		mockTxManager.AssertNotCalled(t, "ExecuteTx", mock.Anything, mock.Anything)
+	})
+
+	tests := []struct {
+		name     string
+		document models.RBACDocument
+		prune    bool
+		wantErr  string
+	}{
+		{
+			name: "Unknown permission reference",
+			document: models.RBACDocument{
+				Roles: []models.RBACRole{{Name: "viewer", Permissions: []string{"user:read"}}},
+			},
+			wantErr: `role "viewer" references unknown permission "user:read"`,
+		},
+		{
+			name: "Duplicate role",
+			document: models.RBACDocument{
+				Roles: []models.RBACRole{{Name: "viewer"}, {Name: "viewer"}},
+			},
+			wantErr: `duplicate role "viewer"`,
+		},
+		{
+			name: "Duplicate resource and action",
+			document: models.RBACDocument{
+				Permissions: []models.RBACPermission{
+					{Resource: "user", Action: "read"},
+					{Name: "user:read", Resource: "user", Action: "read"},
+				},
+			},
+			wantErr: `duplicate permission "user:read"`,
+		},
+		{
+			name: "Name breaks the convention",
+			document: models.RBACDocument{
+				Permissions: []models.RBACPermission{{Name: "read-users", Resource: "user", Action: "read"}},
+			},
+			wantErr: `expected "user:read"`,
+		},
+		{
+			name:     "Pruning the admin role",
+			document: models.RBACDocument{Roles: []models.RBACRole{{Name: "viewer"}}},
+			prune:    true,
+			wantErr:  `pruning would delete the "admin" role`,
+		},
+		{
+			name:     "Unsupported version",
+			document: models.RBACDocument{Version: 2},
+			wantErr:  "unsupported RBAC document version 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rbacService, _, mockTxManager := newRBACService([]*models.Role{admin}, []*models.Permission{userRead}, &rbacStore{})
+
+			result, err := rbacService.ImportRBAC(context.Background(), tt.document, tt.prune)
+
+			assert.Nil(t, result)
+			assert.ErrorContains(t, err, tt.wantErr)
+			assert.False(t, errors.Is(err, services.ErrRBACConflict))
+			mockTxManager.AssertNotCalled(t, "ExecuteTx", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestRBACService_ImportRBAC_MissingPermissions(t *testing.T) {
+	userRead := &models.Permission{ID: uuid.New(), Name: "user:read", Description: "Read users", Resource: "user", Action: "read"}
+	// Validation normalizes role permissions in place, so each import gets its own document
+	document := func() models.RBACDocument {
+		return models.RBACDocument{
+			Roles: []models.RBACRole{{Name: "reporter", Permissions: []string{"report:export", "user:read"}}},
+		}
+	}
+
+	t.Run("Strict mode rejects the reference", func(t *testing.T) {
+		rbacService, _, mockTxManager := newRBACService(nil, []*models.Permission{userRead}, &rbacStore{})
+
+		result, err := rbacService.ImportRBAC(context.Background(), document(), false)
+
+		assert.Nil(t, result)
+		assert.ErrorContains(t, err, `role "reporter" references unknown permission "report:export"`)
+		mockTxManager.AssertNotCalled(t, "ExecuteTx", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Auto-create mode creates and assigns the permission", func(t *testing.T) {
+		store := &rbacStore{}
+		cfg := &config.Config{PermissionNameEnforce: true, AutoCreateMissingPermissions: true}
+		rbacService, mockTxRepo, _ := newRBACServiceWithConfig(nil, []*models.Permission{userRead}, store, cfg)
+
+		result, err := rbacService.ImportRBAC(context.Background(), document(), false)
+
+		assert.NoError(t, err)
+		// user:read already exists, so only report:export is created and nothing is updated
+		assert.Equal(t, []string{"report:export"}, result.PermissionsCreated)
+		assert.Empty(t, result.PermissionsUpdated)
+		assert.Equal(t, []string{"reporter"}, result.RolesCreated)
+		mockTxRepo.AssertNotCalled(t, "UpdatePermission", mock.Anything, mock.Anything)
+
+		if assert.Len(t, store.permissions, 1) {
+			created := store.permissions[0]
+			assert.Equal(t, "report", created.Resource)
+			assert.Equal(t, "export", created.Action)
+			assert.NotEmpty(t, created.Description)
+		}
+		mockTxRepo.AssertCalled(t, "AssignPermissionsToRole", mock.Anything, mock.Anything, mock.MatchedBy(func(ids []uuid.UUID) bool {
+			return len(ids) == 2 && ids[0] == store.permissions[0].ID && ids[1] == userRead.ID
+		}))
+	})
+
+	t.Run("Auto-create mode still rejects references that are not resource:action", func(t *testing.T) {
+		cfg := &config.Config{AutoCreateMissingPermissions: true}
+		rbacService, _, _ := newRBACServiceWithConfig(nil, nil, &rbacStore{}, cfg)
+
+		_, err := rbacService.ImportRBAC(context.Background(), models.RBACDocument{
+			Roles: []models.RBACRole{{Name: "reporter", Permissions: []string{"export-reports"}}},
+		}, false)
+
+		assert.ErrorContains(t, err, `references unknown permission "export-reports"`)
+	})
+}
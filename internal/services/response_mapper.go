@@ -0,0 +1,29 @@
+package services
+
+import "github.com/chats/go-user-api/internal/models"
+
+// responder is implemented by models that convert themselves to their API response format
+type responder[R any] interface {
+	ToResponse() R
+}
+
+// mapToResponses converts each item with fn. The result is never nil, so empty lists encode as [] rather than null.
+func mapToResponses[T, R any](items []T, fn func(T) R) []R {
+	responses := make([]R, len(items))
+	for i, item := range items {
+		responses[i] = fn(item)
+	}
+	return responses
+}
+
+// toResponses converts a list of models to their response format
+func toResponses[T responder[R], R any](items []T) []R {
+	return mapToResponses(items, T.ToResponse)
+}
+
+// permissionsToResponses converts permissions loaded by value, whose ToResponse has a pointer receiver
+func permissionsToResponses(permissions []models.Permission) []models.PermissionResponse {
+	return mapToResponses(permissions, func(permission models.Permission) models.PermissionResponse {
+		return permission.ToResponse()
+	})
+}
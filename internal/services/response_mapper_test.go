@@ -0,0 +1,68 @@
+package services
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapToResponses(t *testing.T) {
+	t.Run("Maps every item in order", func(t *testing.T) {
+		responses := mapToResponses([]int{1, 2, 3}, strconv.Itoa)
+
+		assert.Equal(t, []string{"1", "2", "3"}, responses)
+	})
+
+	t.Run("Nil input returns an empty slice", func(t *testing.T) {
+		responses := mapToResponses(nil, strconv.Itoa)
+
+		assert.NotNil(t, responses)
+		assert.Empty(t, responses)
+
+		encoded, err := json.Marshal(responses)
+		assert.NoError(t, err)
+		assert.JSONEq(t, "[]", string(encoded))
+	})
+
+	t.Run("Empty input returns an empty slice", func(t *testing.T) {
+		responses := mapToResponses([]int{}, strconv.Itoa)
+
+		assert.NotNil(t, responses)
+		assert.Empty(t, responses)
+	})
+}
+
+func TestToResponses(t *testing.T) {
+	t.Run("Uses the model ToResponse", func(t *testing.T) {
+		roles := []*models.Role{
+			{ID: uuid.New(), Name: "admin"},
+			{ID: uuid.New(), Name: "user"},
+		}
+
+		responses := toResponses(roles)
+
+		assert.Equal(t, []models.RoleResponse{roles[0].ToResponse(), roles[1].ToResponse()}, responses)
+	})
+
+	t.Run("Nil input returns an empty slice", func(t *testing.T) {
+		var users []*models.User
+
+		responses := toResponses(users)
+
+		assert.NotNil(t, responses)
+		assert.Empty(t, responses)
+	})
+
+	t.Run("Permissions loaded by value", func(t *testing.T) {
+		permissions := []models.Permission{{ID: uuid.New(), Name: "user:read", Resource: "user", Action: "read"}}
+
+		responses := permissionsToResponses(permissions)
+
+		assert.Equal(t, []models.PermissionResponse{permissions[0].ToResponse()}, responses)
+		assert.NotNil(t, permissionsToResponses(nil))
+	})
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/repositories"
+	"github.com/rs/zerolog/log"
+)
+
+// RolePermissionSnapshot is an in-memory view of the permissions granted to each role.
+// It is only trusted while younger than maxAge, which bounds how long a revoked
+// permission can keep being granted.
+type RolePermissionSnapshot struct {
+	roleRepo repositories.RoleRepositoryInterface
+	maxAge   time.Duration
+
+	mu       sync.RWMutex
+	grants   map[string]map[string]struct{}
+	loadedAt time.Time
+}
+
+// NewRolePermissionSnapshot creates an empty snapshot; it is stale until the first Refresh
+func NewRolePermissionSnapshot(roleRepo repositories.RoleRepositoryInterface, maxAge time.Duration) *RolePermissionSnapshot {
+	return &RolePermissionSnapshot{
+		roleRepo: roleRepo,
+		maxAge:   maxAge,
+	}
+}
+
+// Refresh reloads the role permissions from the repository
+func (s *RolePermissionSnapshot) Refresh(ctx context.Context) error {
+	roles, err := s.roleRepo.GetAll(ctx, true, models.SortOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to load roles: %w", err)
+	}
+
+	grants := make(map[string]map[string]struct{}, len(roles))
+	for _, role := range roles {
+		granted := make(map[string]struct{}, len(role.Permissions))
+		for _, permission := range role.Permissions {
+			granted[permission.Resource+":"+permission.Action] = struct{}{}
+		}
+		grants[role.Name] = granted
+	}
+
+	s.mu.Lock()
+	s.grants = grants
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Invalidate marks the snapshot stale so checks fall back to the database until the next Refresh.
+// It does nothing on a nil snapshot, so callers need not check whether one is enabled.
+func (s *RolePermissionSnapshot) Invalidate() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.loadedAt = time.Time{}
+	s.mu.Unlock()
+}
+
+// Check reports whether any of the roles grants the permission.
+// The second result is false when the snapshot is stale and cannot be trusted.
+func (s *RolePermissionSnapshot) Check(roles []string, resource, action string) (bool, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.loadedAt.IsZero() || time.Since(s.loadedAt) > s.maxAge {
+		return false, false
+	}
+
+	required := resource + ":" + action
+	for _, role := range roles {
+		if _, ok := s.grants[role][required]; ok {
+			return true, true
+		}
+	}
+
+	return false, true
+}
+
+// Missing returns the required "resource:action" permissions that none of the roles grants.
+// The second result is false when the snapshot is stale and cannot be trusted.
+func (s *RolePermissionSnapshot) Missing(roles []string, required []string) ([]string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.loadedAt.IsZero() || time.Since(s.loadedAt) > s.maxAge {
+		return nil, false
+	}
+
+	granted := make(map[string]struct{})
+	for _, role := range roles {
+		for permission := range s.grants[role] {
+			granted[permission] = struct{}{}
+		}
+	}
+
+	return missingPermissions(granted, required), true
+}
+
+// missingPermissions returns the entries of required that are not in granted, in order
+func missingPermissions(granted map[string]struct{}, required []string) []string {
+	missing := make([]string, 0)
+	for _, permission := range required {
+		if _, ok := granted[permission]; !ok {
+			missing = append(missing, permission)
+		}
+	}
+	return missing
+}
+
+// Start refreshes the snapshot at half its max age until the context is cancelled
+func (s *RolePermissionSnapshot) Start(ctx context.Context) {
+	interval := s.maxAge / 2
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Refresh(ctx); err != nil {
+			log.Warn().Err(err).Msg("Failed to refresh role permission snapshot")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -2,9 +2,14 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/chats/go-user-api/config"
 	"github.com/chats/go-user-api/internal/models"
 	"github.com/chats/go-user-api/internal/repositories"
 	"github.com/chats/go-user-api/internal/repositories/transaction"
@@ -17,6 +22,41 @@ type RoleService struct {
 	roleRepo       repositories.RoleRepositoryInterface
 	permissionRepo repositories.PermissionRepositoryInterface
 	txManager      transaction.Manager[transaction.Repository]
+	idListLimit    int
+
+	// userRepo finds the members of a role for permission change previews
+	userRepo repositories.UserRepositoryInterface
+
+	// denyEscalation rejects granting a role permissions the acting caller does not hold
+	denyEscalation bool
+
+	// permissionSnapshot is marked stale whenever a role's permissions change
+	permissionSnapshot *RolePermissionSnapshot
+}
+
+// RoleServiceOptions configures a RoleService; DefaultRoleServiceOptions returns the defaults
+type RoleServiceOptions struct {
+	// IDListLimit caps the permission IDs accepted per request (0 disables the cap)
+	IDListLimit int
+
+	// DenyPrivilegeEscalation rejects granting a role permissions the acting caller does not hold.
+	// The caller's permissions are resolved through the repository set by UseUserRepository.
+	DenyPrivilegeEscalation bool
+}
+
+// DefaultRoleServiceOptions returns the options a RoleService uses unless configured otherwise
+func DefaultRoleServiceOptions() RoleServiceOptions {
+	return RoleServiceOptions{
+		IDListLimit: DefaultIDListLimit,
+	}
+}
+
+// RoleServiceOptionsFromConfig returns the role service options set by cfg
+func RoleServiceOptionsFromConfig(cfg *config.Config) RoleServiceOptions {
+	return RoleServiceOptions{
+		IDListLimit:             cfg.IDListLimit,
+		DenyPrivilegeEscalation: cfg.DenyPrivilegeEscalation,
+	}
 }
 
 // NewRoleService creates a new role service
@@ -24,20 +64,67 @@ func NewRoleService(
 	roleRepo repositories.RoleRepositoryInterface,
 	permissionRepo repositories.PermissionRepositoryInterface,
 	txManager transaction.Manager[transaction.Repository],
+	opts RoleServiceOptions,
 ) *RoleService {
 	return &RoleService{
 		roleRepo:       roleRepo,
 		permissionRepo: permissionRepo,
 		txManager:      txManager,
+		idListLimit:    opts.IDListLimit,
+		denyEscalation: opts.DenyPrivilegeEscalation,
+	}
+}
+
+// UseUserRepository lets PreviewRolePermissionChange count the users holding a role
+func (s *RoleService) UseUserRepository(userRepo repositories.UserRepositoryInterface) {
+	s.userRepo = userRepo
+}
+
+// UsePermissionSnapshot marks the snapshot stale after each change to a role or its permissions
+func (s *RoleService) UsePermissionSnapshot(snapshot *RolePermissionSnapshot) {
+	s.permissionSnapshot = snapshot
+}
+
+// checkPermissionGrant rejects granting a role permissions the acting caller does not hold. The
+// permissions the role already holds are not granted again. Unknown IDs are left to the assignment
+// to reject.
+func (s *RoleService) checkPermissionGrant(ctx context.Context, permissionIDs []uuid.UUID, held []models.Permission) error {
+	if !s.denyEscalation || len(permissionIDs) == 0 {
+		return nil
+	}
+	if s.userRepo == nil {
+		return fmt.Errorf("%w: the acting user's permissions cannot be resolved", ErrPrivilegeEscalation)
+	}
+
+	var granted []models.Permission
+	for _, permissionID := range permissionIDs {
+		if slices.ContainsFunc(held, func(permission models.Permission) bool { return permission.ID == permissionID }) {
+			continue
+		}
+		permission, err := s.permissionRepo.GetByID(ctx, permissionID)
+		if err != nil {
+			continue
+		}
+		granted = append(granted, *permission)
 	}
+	return checkEscalation(ctx, s.userRepo, granted)
 }
 
 // CreateRole creates a new role
 func (s *RoleService) CreateRole(ctx context.Context, request models.RoleCreateRequest) (*models.RoleResponse, error) {
-	// Check if role name already exists
+	// Fail fast on a taken name. This check is advisory: a concurrent create can pass it too, so the
+	// unique index decides and its violation is reported the same way below.
 	existingRole, err := s.roleRepo.GetByName(ctx, request.Name)
 	if err == nil && existingRole != nil {
-		return nil, fmt.Errorf("role name already exists")
+		return nil, models.ErrRoleNameExists
+	}
+
+	permissionIDs, err := parseIDList("permission", request.PermissionIDs, s.idListLimit)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkPermissionGrant(ctx, permissionIDs, nil); err != nil {
+		return nil, err
 	}
 
 	// Create role object
@@ -52,20 +139,14 @@ func (s *RoleService) CreateRole(ctx context.Context, request models.RoleCreateR
 	err = s.txManager.ExecuteTx(ctx, func(tx transaction.Repository) error {
 		// Save role to database
 		if err := tx.CreateRole(ctx, role); err != nil {
+			if errors.Is(err, models.ErrRoleNameExists) {
+				return err
+			}
 			return fmt.Errorf("failed to create role: %w", err)
 		}
 
 		// Assign permissions if provided
-		if len(request.PermissionIDs) > 0 {
-			permissionIDs := make([]uuid.UUID, 0, len(request.PermissionIDs))
-			for _, permissionIDStr := range request.PermissionIDs {
-				permissionID, err := uuid.Parse(permissionIDStr)
-				if err != nil {
-					return fmt.Errorf("invalid permission ID: %w", err)
-				}
-				permissionIDs = append(permissionIDs, permissionID)
-			}
-
+		if len(permissionIDs) > 0 {
 			if err := tx.AssignPermissionsToRole(ctx, role.ID, permissionIDs); err != nil {
 				return fmt.Errorf("failed to assign permissions: %w", err)
 			}
@@ -78,6 +159,8 @@ func (s *RoleService) CreateRole(ctx context.Context, request models.RoleCreateR
 		return nil, err
 	}
 
+	transaction.AfterCommit(ctx, s.permissionSnapshot.Invalidate)
+
 	// Get the updated role with permissions
 	updatedRole, err := s.roleRepo.GetByID(ctx, role.ID)
 	if err != nil {
@@ -89,52 +172,62 @@ func (s *RoleService) CreateRole(ctx context.Context, request models.RoleCreateR
 	}
 
 	// แก้ไขตรงนี้: สร้างตัวแปรก่อนแล้วค่อย return address ของตัวแปรนั้น
-	response := updatedRole.ToResponse()
+	response := updatedRole.ToResponseWith(models.WithPermissions)
 	return &response, nil
 }
 
 // GetRoleByID retrieves a role by ID
 func (s *RoleService) GetRoleByID(ctx context.Context, id string) (*models.RoleResponse, error) {
 	// Parse UUID
-	roleID, err := uuid.Parse(id)
+	roleID, err := parseID("role", id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid role ID: %w", err)
+		return nil, err
 	}
 
 	// Get role
 	role, err := s.roleRepo.GetByID(ctx, roleID)
 	if err != nil {
-		return nil, err
+		return nil, contextError(ctx, err)
 	}
 
 	// แก้ไขตรงนี้: สร้างตัวแปรก่อนแล้วค่อย return address ของตัวแปรนั้น
-	response := role.ToResponse()
+	response := role.ToResponseWith(models.WithPermissions)
 	return &response, nil
 }
 
-// GetAllRoles retrieves all roles
-func (s *RoleService) GetAllRoles(ctx context.Context) ([]models.RoleResponse, error) {
-	// Get roles
-	roles, err := s.roleRepo.GetAll(ctx)
+// AddCounts sets the number of users holding the role and of permissions it grants, counted without
+// loading either list
+func (s *RoleService) AddCounts(ctx context.Context, role *models.RoleResponse) error {
+	userCount, permissionCount, err := s.roleRepo.GetCounts(ctx, role.ID)
 	if err != nil {
-		return nil, err
+		return contextError(ctx, err)
 	}
 
-	// Convert to response format
-	roleResponses := make([]models.RoleResponse, len(roles))
-	for i, role := range roles {
-		roleResponses[i] = role.ToResponse()
+	role.UserCount = &userCount
+	role.PermissionCount = &permissionCount
+	return nil
+}
+
+// GetAllRoles retrieves all roles in the requested order, optionally with their permissions
+func (s *RoleService) GetAllRoles(ctx context.Context, includePermissions bool, sort models.SortOptions) ([]models.RoleResponse, error) {
+	// Get roles
+	roles, err := s.roleRepo.GetAll(ctx, includePermissions, sort)
+	if err != nil {
+		return nil, contextError(ctx, err)
 	}
 
-	return roleResponses, nil
+	opts := models.ResponseOptions{IncludePermissions: includePermissions}
+	return mapToResponses(roles, func(role *models.Role) models.RoleResponse {
+		return role.ToResponseWith(opts)
+	}), nil
 }
 
 // UpdateRole updates a role
 func (s *RoleService) UpdateRole(ctx context.Context, id string, request models.RoleUpdateRequest) (*models.RoleResponse, error) {
 	// Parse UUID
-	roleID, err := uuid.Parse(id)
+	roleID, err := parseID("role", id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid role ID: %w", err)
+		return nil, err
 	}
 
 	// Get existing role
@@ -147,7 +240,7 @@ func (s *RoleService) UpdateRole(ctx context.Context, id string, request models.
 	if request.Name != "" && request.Name != role.Name {
 		existingRole, err := s.roleRepo.GetByName(ctx, request.Name)
 		if err == nil && existingRole != nil {
-			return nil, fmt.Errorf("role name already exists")
+			return nil, models.ErrRoleNameExists
 		}
 	}
 
@@ -160,6 +253,14 @@ func (s *RoleService) UpdateRole(ctx context.Context, id string, request models.
 	}
 	role.UpdatedAt = time.Now()
 
+	permissionIDs, err := parseIDList("permission", request.PermissionIDs, s.idListLimit)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkPermissionGrant(ctx, permissionIDs, role.Permissions); err != nil {
+		return nil, err
+	}
+
 	// Start transaction
 	err = s.txManager.ExecuteTx(ctx, func(tx transaction.Repository) error {
 		// Update role in database
@@ -168,16 +269,7 @@ func (s *RoleService) UpdateRole(ctx context.Context, id string, request models.
 		}
 
 		// Update permissions if provided
-		if len(request.PermissionIDs) > 0 {
-			permissionIDs := make([]uuid.UUID, 0, len(request.PermissionIDs))
-			for _, permissionIDStr := range request.PermissionIDs {
-				permissionID, err := uuid.Parse(permissionIDStr)
-				if err != nil {
-					return fmt.Errorf("invalid permission ID: %w", err)
-				}
-				permissionIDs = append(permissionIDs, permissionID)
-			}
-
+		if len(permissionIDs) > 0 {
 			if err := tx.AssignPermissionsToRole(ctx, role.ID, permissionIDs); err != nil {
 				return fmt.Errorf("failed to assign permissions: %w", err)
 			}
@@ -190,6 +282,15 @@ func (s *RoleService) UpdateRole(ctx context.Context, id string, request models.
 		return nil, err
 	}
 
+	transaction.AfterCommit(ctx, func() {
+		// Users resolve their permissions from cached role sets, which may include this role
+		if len(permissionIDs) > 0 {
+			s.roleRepo.InvalidatePermissionCache()
+		}
+		// The snapshot is keyed by role name, so a rename makes it stale too
+		s.permissionSnapshot.Invalidate()
+	})
+
 	// Get the updated role with permissions
 	updatedRole, err := s.roleRepo.GetByID(ctx, role.ID)
 	if err != nil {
@@ -200,41 +301,329 @@ func (s *RoleService) UpdateRole(ctx context.Context, id string, request models.
 	}
 
 	// แก้ไขตรงนี้: สร้างตัวแปรก่อนแล้วค่อย return address ของตัวแปรนั้น
-	response := updatedRole.ToResponse()
+	response := updatedRole.ToResponseWith(models.WithPermissions)
 	return &response, nil
 }
 
+// AddRolePermissions grants a role the given permissions in one transaction. Permissions the role
+// already holds, and any it holds besides these, are left alone, so repeating a request changes nothing.
+func (s *RoleService) AddRolePermissions(ctx context.Context, id string, request models.RolePermissionsChangeRequest) (*models.RolePermissionsChangeResponse, error) {
+	return s.changeRolePermissions(ctx, id, request, true, func(tx transaction.Repository, roleID uuid.UUID, permissionIDs []uuid.UUID) ([]uuid.UUID, error) {
+		return tx.AddPermissionsToRole(ctx, roleID, permissionIDs)
+	})
+}
+
+// RemoveRolePermissions revokes the given permissions from a role in one transaction. Permissions the
+// role does not hold are skipped and the rest of its permissions are left alone.
+func (s *RoleService) RemoveRolePermissions(ctx context.Context, id string, request models.RolePermissionsChangeRequest) (*models.RolePermissionsChangeResponse, error) {
+	return s.changeRolePermissions(ctx, id, request, false, func(tx transaction.Repository, roleID uuid.UUID, permissionIDs []uuid.UUID) ([]uuid.UUID, error) {
+		return tx.RemovePermissionsFromRole(ctx, roleID, permissionIDs)
+	})
+}
+
+// changeRolePermissions applies an incremental permission change to a role in a transaction and
+// reports which IDs it changed; grants marks a change that adds permissions
+func (s *RoleService) changeRolePermissions(
+	ctx context.Context,
+	id string,
+	request models.RolePermissionsChangeRequest,
+	grants bool,
+	change func(tx transaction.Repository, roleID uuid.UUID, permissionIDs []uuid.UUID) ([]uuid.UUID, error),
+) (*models.RolePermissionsChangeResponse, error) {
+	// Parse UUID
+	roleID, err := parseID("role", id)
+	if err != nil {
+		return nil, err
+	}
+	permissionIDs, err := parseIDList("permission", request.PermissionIDs, s.idListLimit)
+	if err != nil {
+		return nil, err
+	}
+	if len(permissionIDs) == 0 {
+		return nil, fmt.Errorf("at least one permission ID is required")
+	}
+
+	// Make sure the role exists
+	role, err := s.roleRepo.GetByID(ctx, roleID)
+	if err != nil {
+		return nil, err
+	}
+	if grants {
+		if err := s.checkPermissionGrant(ctx, permissionIDs, role.Permissions); err != nil {
+			return nil, err
+		}
+	}
+
+	var changed []uuid.UUID
+	err = s.txManager.ExecuteTx(ctx, func(tx transaction.Repository) error {
+		changed, err = change(tx, roleID, permissionIDs)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.RolePermissionsChangeResponse{
+		RoleID:    roleID,
+		Changed:   make([]string, 0, len(changed)),
+		Unchanged: make([]string, 0),
+	}
+	isChanged := make(map[uuid.UUID]bool, len(changed))
+	for _, permissionID := range changed {
+		isChanged[permissionID] = true
+	}
+	for _, permissionID := range permissionIDs {
+		if isChanged[permissionID] {
+			response.Changed = append(response.Changed, permissionID.String())
+		} else {
+			response.Unchanged = append(response.Unchanged, permissionID.String())
+		}
+	}
+
+	// Users resolve their permissions from cached role sets, which may include this role
+	if len(changed) > 0 {
+		transaction.AfterCommit(ctx, func() {
+			s.roleRepo.InvalidatePermissionCache()
+			s.permissionSnapshot.Invalidate()
+		})
+	}
+
+	permissions, err := s.roleRepo.GetRolePermissions(ctx, roleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role permissions: %w", err)
+	}
+	if permissions == nil {
+		permissions = make([]models.Permission, 0)
+	}
+	response.Permissions = permissions
+
+	return response, nil
+}
+
 // DeleteRole deletes a role
 func (s *RoleService) DeleteRole(ctx context.Context, id string) error {
 	// Parse UUID
-	roleID, err := uuid.Parse(id)
+	roleID, err := parseID("role", id)
 	if err != nil {
-		return fmt.Errorf("invalid role ID: %w", err)
+		return err
 	}
 
 	// Delete role
-	return s.roleRepo.Delete(ctx, roleID)
+	if err := s.roleRepo.Delete(ctx, roleID); err != nil {
+		return err
+	}
+
+	s.permissionSnapshot.Invalidate()
+	return nil
 }
 
 // GetRolePermissions retrieves all permissions for a role
 func (s *RoleService) GetRolePermissions(ctx context.Context, id string) ([]models.PermissionResponse, error) {
 	// Parse UUID
-	roleID, err := uuid.Parse(id)
+	roleID, err := parseID("role", id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid role ID: %w", err)
+		return nil, err
 	}
 
 	// Get permissions
 	permissions, err := s.roleRepo.GetRolePermissions(ctx, roleID)
 	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return permissionsToResponses(permissions), nil
+}
+
+// DiffRolePermissions reports the permissions held only by the first role, only by the other, and by both, ordered by name
+func (s *RoleService) DiffRolePermissions(ctx context.Context, id, otherID string) (*models.RolePermissionDiffResponse, error) {
+	// Parse UUIDs
+	roleID, err := parseID("role", id)
+	if err != nil {
+		return nil, err
+	}
+	otherRoleID, err := parseID("other role", otherID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Both roles must exist; lookups include their permissions
+	role, err := s.roleRepo.GetByID(ctx, roleID)
+	if err != nil {
+		return nil, err
+	}
+	otherRole, err := s.roleRepo.GetByID(ctx, otherRoleID)
+	if err != nil {
+		return nil, fmt.Errorf("other role: %w", err)
+	}
+
+	response := &models.RolePermissionDiffResponse{
+		RoleID:      role.ID,
+		OtherRoleID: otherRole.ID,
+	}
+	response.OnlyInRole, response.OnlyInOther, response.InBoth = diffPermissions(role.Permissions, otherRole.Permissions)
+
+	return response, nil
+}
+
+// PreviewRolePermissionChange reports which permissions replacing a role's permission set with the
+// proposed one would add and remove, and how many users hold the role, without changing anything
+func (s *RoleService) PreviewRolePermissionChange(ctx context.Context, id string, request models.RolePermissionImpactRequest) (*models.RolePermissionImpactResponse, error) {
+	if s.userRepo == nil {
+		return nil, fmt.Errorf("role membership lookup is not configured")
+	}
+
+	// Parse UUIDs
+	roleID, err := parseID("role", id)
+	if err != nil {
+		return nil, err
+	}
+	permissionIDs, err := parseIDList("permission", request.PermissionIDs, s.idListLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	// The lookup includes the current permissions
+	role, err := s.roleRepo.GetByID(ctx, roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve the proposed IDs against every permission at once
+	permissions, err := s.permissionRepo.GetAll(ctx, models.SortOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permissions: %w", err)
+	}
+	known := make(map[uuid.UUID]*models.Permission, len(permissions))
+	for _, permission := range permissions {
+		known[permission.ID] = permission
+	}
+
+	proposed := make([]models.Permission, 0, len(permissionIDs))
+	var unknown []string
+	for _, permissionID := range permissionIDs {
+		permission, ok := known[permissionID]
+		if !ok {
+			unknown = append(unknown, permissionID.String())
+			continue
+		}
+		proposed = append(proposed, *permission)
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown permission IDs: %s", strings.Join(unknown, ", "))
+	}
+
+	// Everyone assigned the role is affected by a change
+	memberIDs, err := s.userRepo.GetUserIDsByFilter(ctx, models.UserFilter{RoleName: role.Name})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role members: %w", err)
+	}
+
+	response := &models.RolePermissionImpactResponse{
+		RoleID:      role.ID,
+		RoleMembers: len(memberIDs),
+	}
+	response.Removed, response.Added, response.Unchanged = diffPermissions(role.Permissions, proposed)
+	if len(response.Added) > 0 || len(response.Removed) > 0 {
+		response.AffectedUsers = response.RoleMembers
+	}
+
+	return response, nil
+}
+
+// diffPermissions splits two permission sets into the permissions only in the first, only in the
+// second and in both, each ordered by name
+func diffPermissions(first, second []models.Permission) (onlyInFirst, onlyInSecond, inBoth []models.PermissionResponse) {
+	onlyInFirst = make([]models.PermissionResponse, 0)
+	onlyInSecond = make([]models.PermissionResponse, 0)
+	inBoth = make([]models.PermissionResponse, 0)
+
+	inSecond := make(map[uuid.UUID]bool, len(second))
+	for _, permission := range second {
+		inSecond[permission.ID] = true
+	}
+
+	inFirst := make(map[uuid.UUID]bool, len(first))
+	for _, permission := range first {
+		inFirst[permission.ID] = true
+		if inSecond[permission.ID] {
+			inBoth = append(inBoth, permission.ToResponse())
+		} else {
+			onlyInFirst = append(onlyInFirst, permission.ToResponse())
+		}
+	}
+	for _, permission := range second {
+		if !inFirst[permission.ID] {
+			onlyInSecond = append(onlyInSecond, permission.ToResponse())
+		}
+	}
+
+	for _, permissions := range [][]models.PermissionResponse{onlyInFirst, onlyInSecond, inBoth} {
+		sort.Slice(permissions, func(i, j int) bool {
+			return permissions[i].Name < permissions[j].Name
+		})
+	}
+
+	return onlyInFirst, onlyInSecond, inBoth
+}
+
+// ValidateRolePermissions checks that permission IDs resolve to existing permissions without assigning them
+func (s *RoleService) ValidateRolePermissions(ctx context.Context, id string, request models.RolePermissionsValidateRequest) (*models.RolePermissionsValidationResponse, error) {
+	// Parse UUID
+	roleID, err := parseID("role", id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make sure the role exists
+	if _, err := s.roleRepo.GetByID(ctx, roleID); err != nil {
 		return nil, err
 	}
 
-	// Convert to response format
-	permissionResponses := make([]models.PermissionResponse, len(permissions))
-	for i, permission := range permissions {
-		permissionResponses[i] = permission.ToResponse()
+	// Load all permissions once instead of looking up each ID
+	permissions, err := s.permissionRepo.GetAll(ctx, models.SortOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permissions: %w", err)
+	}
+
+	known := make(map[uuid.UUID]struct{}, len(permissions))
+	for _, permission := range permissions {
+		known[permission.ID] = struct{}{}
 	}
 
-	return permissionResponses, nil
+	response := &models.RolePermissionsValidationResponse{
+		ValidIDs:     make([]string, 0),
+		InvalidIDs:   make([]string, 0),
+		UnknownIDs:   make([]string, 0),
+		DuplicateIDs: make([]string, 0),
+	}
+
+	seen := make(map[uuid.UUID]bool, len(request.PermissionIDs))
+	for _, permissionIDStr := range request.PermissionIDs {
+		permissionID, err := uuid.Parse(permissionIDStr)
+		if err != nil {
+			response.InvalidIDs = append(response.InvalidIDs, permissionIDStr)
+			continue
+		}
+
+		// Report each duplicated ID once, however often it repeats
+		if reported, ok := seen[permissionID]; ok {
+			if !reported {
+				response.DuplicateIDs = append(response.DuplicateIDs, permissionID.String())
+				seen[permissionID] = true
+			}
+			continue
+		}
+		seen[permissionID] = false
+
+		if _, ok := known[permissionID]; !ok {
+			response.UnknownIDs = append(response.UnknownIDs, permissionID.String())
+			continue
+		}
+
+		response.ValidIDs = append(response.ValidIDs, permissionID.String())
+	}
+
+	response.Valid = len(response.InvalidIDs) == 0 && len(response.UnknownIDs) == 0 && len(response.DuplicateIDs) == 0
+
+	return response, nil
 }
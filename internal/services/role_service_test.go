@@ -0,0 +1,641 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chats/go-user-api/internal/mocks"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/repositories/transaction"
+	"github.com/chats/go-user-api/internal/services"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleService_AddCounts(t *testing.T) {
+	t.Run("Role with several users and permissions", func(t *testing.T) {
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		roleService := services.NewRoleService(mockRoleRepo, new(mocks.MockPermissionRepository), new(mocks.Manager[transaction.Repository]), services.DefaultRoleServiceOptions())
+		role := &models.RoleResponse{ID: uuid.New(), Name: "editor"}
+		mockRoleRepo.On("GetCounts", mock.Anything, role.ID).Return(3, 4, nil)
+
+		require.NoError(t, roleService.AddCounts(context.Background(), role))
+
+		require.NotNil(t, role.UserCount)
+		require.NotNil(t, role.PermissionCount)
+		assert.Equal(t, 3, *role.UserCount)
+		assert.Equal(t, 4, *role.PermissionCount)
+	})
+
+	t.Run("Empty role reports zeros", func(t *testing.T) {
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		roleService := services.NewRoleService(mockRoleRepo, new(mocks.MockPermissionRepository), new(mocks.Manager[transaction.Repository]), services.DefaultRoleServiceOptions())
+		role := &models.RoleResponse{ID: uuid.New(), Name: "empty"}
+		mockRoleRepo.On("GetCounts", mock.Anything, role.ID).Return(0, 0, nil)
+
+		require.NoError(t, roleService.AddCounts(context.Background(), role))
+
+		// Zero counts are still sent, unlike counts that were not requested
+		body, err := json.Marshal(role)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `"user_count":0`)
+		assert.Contains(t, string(body), `"permission_count":0`)
+	})
+
+	t.Run("Counts not requested are omitted", func(t *testing.T) {
+		body, err := json.Marshal(models.RoleResponse{ID: uuid.New(), Name: "editor"})
+		require.NoError(t, err)
+		assert.NotContains(t, string(body), "user_count")
+	})
+}
+
+func TestRoleService_GetAllRoles(t *testing.T) {
+	permissions := []models.Permission{{ID: uuid.New(), Name: "user:read", Resource: "user", Action: "read"}}
+
+	t.Run("Includes permissions", func(t *testing.T) {
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		roleService := services.NewRoleService(mockRoleRepo, new(mocks.MockPermissionRepository), new(mocks.Manager[transaction.Repository]), services.DefaultRoleServiceOptions())
+
+		mockRoleRepo.On("GetAll", mock.Anything, true, models.SortOptions{}).Return([]*models.Role{
+			{ID: uuid.New(), Name: "admin", Permissions: permissions},
+		}, nil)
+
+		roles, err := roleService.GetAllRoles(context.Background(), true, models.SortOptions{})
+
+		assert.NoError(t, err)
+		assert.Len(t, roles, 1)
+		assert.Equal(t, permissions, roles[0].Permissions)
+		mockRoleRepo.AssertExpectations(t)
+	})
+
+	t.Run("Excludes permissions", func(t *testing.T) {
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		roleService := services.NewRoleService(mockRoleRepo, new(mocks.MockPermissionRepository), new(mocks.Manager[transaction.Repository]), services.DefaultRoleServiceOptions())
+
+		mockRoleRepo.On("GetAll", mock.Anything, false, models.SortOptions{}).Return([]*models.Role{
+			{ID: uuid.New(), Name: "admin"},
+		}, nil)
+
+		roles, err := roleService.GetAllRoles(context.Background(), false, models.SortOptions{})
+
+		assert.NoError(t, err)
+		assert.Len(t, roles, 1)
+		assert.Nil(t, roles[0].Permissions)
+		mockRoleRepo.AssertExpectations(t)
+	})
+
+	t.Run("Omits permissions the repository loaded anyway", func(t *testing.T) {
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		roleService := services.NewRoleService(mockRoleRepo, new(mocks.MockPermissionRepository), new(mocks.Manager[transaction.Repository]), services.DefaultRoleServiceOptions())
+
+		mockRoleRepo.On("GetAll", mock.Anything, false, models.SortOptions{}).Return([]*models.Role{
+			{ID: uuid.New(), Name: "admin", Permissions: permissions},
+		}, nil)
+
+		roles, err := roleService.GetAllRoles(context.Background(), false, models.SortOptions{})
+
+		assert.NoError(t, err)
+		assert.Nil(t, roles[0].Permissions)
+	})
+}
+
+func TestRoleService_GetRoleByID(t *testing.T) {
+	permissions := []models.Permission{{ID: uuid.New(), Name: "user:read", Resource: "user", Action: "read"}}
+	roleID := uuid.New()
+
+	mockRoleRepo := new(mocks.MockRoleRepository)
+	roleService := services.NewRoleService(mockRoleRepo, new(mocks.MockPermissionRepository), new(mocks.Manager[transaction.Repository]), services.DefaultRoleServiceOptions())
+
+	mockRoleRepo.On("GetByID", mock.Anything, roleID).Return(&models.Role{ID: roleID, Name: "admin", Permissions: permissions}, nil)
+
+	role, err := roleService.GetRoleByID(context.Background(), roleID.String())
+
+	assert.NoError(t, err)
+	assert.Equal(t, permissions, role.Permissions)
+	mockRoleRepo.AssertExpectations(t)
+}
+
+func TestRoleService_ValidateRolePermissions(t *testing.T) {
+	role := &models.Role{ID: uuid.New(), Name: "editor"}
+	readPermission := &models.Permission{ID: uuid.New(), Name: "user:read", Resource: "user", Action: "read"}
+	writePermission := &models.Permission{ID: uuid.New(), Name: "user:write", Resource: "user", Action: "write"}
+
+	setup := func() (*services.RoleService, *mocks.MockRoleRepository, *mocks.MockPermissionRepository, *mocks.Manager[transaction.Repository]) {
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockPermissionRepo := new(mocks.MockPermissionRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+
+		mockRoleRepo.On("GetByID", mock.Anything, role.ID).Return(role, nil)
+		mockPermissionRepo.On("GetAll", mock.Anything, models.SortOptions{}).Return([]*models.Permission{readPermission, writePermission}, nil)
+
+		return services.NewRoleService(mockRoleRepo, mockPermissionRepo, mockTxManager, services.DefaultRoleServiceOptions()), mockRoleRepo, mockPermissionRepo, mockTxManager
+	}
+
+	t.Run("All permissions valid", func(t *testing.T) {
+		roleService, mockRoleRepo, mockPermissionRepo, mockTxManager := setup()
+
+		result, err := roleService.ValidateRolePermissions(context.Background(), role.ID.String(), models.RolePermissionsValidateRequest{
+			PermissionIDs: []string{readPermission.ID.String(), writePermission.ID.String()},
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, result.Valid)
+		assert.Equal(t, []string{readPermission.ID.String(), writePermission.ID.String()}, result.ValidIDs)
+		assert.Empty(t, result.InvalidIDs)
+		assert.Empty(t, result.UnknownIDs)
+		assert.Empty(t, result.DuplicateIDs)
+		mockRoleRepo.AssertExpectations(t)
+		mockPermissionRepo.AssertExpectations(t)
+		// Nothing is persisted
+		mockTxManager.AssertNotCalled(t, "ExecuteTx", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Unknown and malformed permission IDs", func(t *testing.T) {
+		roleService, _, _, mockTxManager := setup()
+		unknownID := uuid.New().String()
+
+		result, err := roleService.ValidateRolePermissions(context.Background(), role.ID.String(), models.RolePermissionsValidateRequest{
+			PermissionIDs: []string{readPermission.ID.String(), unknownID, "not-a-uuid"},
+		})
+
+		assert.NoError(t, err)
+		assert.False(t, result.Valid)
+		assert.Equal(t, []string{readPermission.ID.String()}, result.ValidIDs)
+		assert.Equal(t, []string{unknownID}, result.UnknownIDs)
+		assert.Equal(t, []string{"not-a-uuid"}, result.InvalidIDs)
+		assert.Empty(t, result.DuplicateIDs)
+		mockTxManager.AssertNotCalled(t, "ExecuteTx", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Duplicate permission IDs", func(t *testing.T) {
+		roleService, _, _, mockTxManager := setup()
+
+		result, err := roleService.ValidateRolePermissions(context.Background(), role.ID.String(), models.RolePermissionsValidateRequest{
+			PermissionIDs: []string{
+				readPermission.ID.String(),
+				writePermission.ID.String(),
+				readPermission.ID.String(),
+				readPermission.ID.String(),
+			},
+		})
+
+		assert.NoError(t, err)
+		assert.False(t, result.Valid)
+		assert.Equal(t, []string{readPermission.ID.String(), writePermission.ID.String()}, result.ValidIDs)
+		assert.Equal(t, []string{readPermission.ID.String()}, result.DuplicateIDs)
+		assert.Empty(t, result.UnknownIDs)
+		assert.Empty(t, result.InvalidIDs)
+		mockTxManager.AssertNotCalled(t, "ExecuteTx", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Invalid role ID", func(t *testing.T) {
+		roleService, _, _, _ := setup()
+
+		result, err := roleService.ValidateRolePermissions(context.Background(), "invalid-id", models.RolePermissionsValidateRequest{})
+
+		assert.Error(t, err)
+		assert.Nil(t, result)
+		assert.Contains(t, err.Error(), "invalid role ID")
+	})
+}
+
+func TestRoleService_CreateRolePermissionIDs(t *testing.T) {
+	setup := func(opts services.RoleServiceOptions) (*services.RoleService, *mocks.Manager[transaction.Repository]) {
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockRoleRepo.On("GetByName", mock.Anything, "editor").Return(nil, errors.New("role not found"))
+
+		return services.NewRoleService(mockRoleRepo, new(mocks.MockPermissionRepository), mockTxManager, opts), mockTxManager
+	}
+
+	t.Run("Over the limit", func(t *testing.T) {
+		roleService, mockTxManager := setup(services.RoleServiceOptions{IDListLimit: 1})
+
+		response, err := roleService.CreateRole(context.Background(), models.RoleCreateRequest{
+			Name:          "editor",
+			PermissionIDs: []string{uuid.New().String(), uuid.New().String()},
+		})
+
+		assert.Error(t, err)
+		assert.Nil(t, response)
+		assert.Contains(t, err.Error(), "too many permission IDs")
+		mockTxManager.AssertNotCalled(t, "ExecuteTx", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Every invalid ID reported", func(t *testing.T) {
+		roleService, mockTxManager := setup(services.DefaultRoleServiceOptions())
+
+		response, err := roleService.CreateRole(context.Background(), models.RoleCreateRequest{
+			Name:          "editor",
+			PermissionIDs: []string{"bad-1", uuid.New().String(), "bad-2"},
+		})
+
+		assert.Error(t, err)
+		assert.Nil(t, response)
+		assert.Equal(t, `invalid permission IDs: "bad-1", "bad-2"`, err.Error())
+		mockTxManager.AssertNotCalled(t, "ExecuteTx", mock.Anything, mock.Anything)
+	})
+}
+
+func TestRoleService_DiffRolePermissions(t *testing.T) {
+	read := models.Permission{ID: uuid.New(), Name: "user:read", Resource: "user", Action: "read"}
+	write := models.Permission{ID: uuid.New(), Name: "user:write", Resource: "user", Action: "write"}
+	deletePermission := models.Permission{ID: uuid.New(), Name: "user:delete", Resource: "user", Action: "delete"}
+	roleRead := models.Permission{ID: uuid.New(), Name: "role:read", Resource: "role", Action: "read"}
+
+	names := func(permissions []models.PermissionResponse) []string {
+		result := make([]string, 0, len(permissions))
+		for _, permission := range permissions {
+			result = append(result, permission.Name)
+		}
+		return result
+	}
+
+	setup := func(role, other *models.Role) *services.RoleService {
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockRoleRepo.On("GetByID", mock.Anything, role.ID).Return(role, nil)
+		mockRoleRepo.On("GetByID", mock.Anything, other.ID).Return(other, nil)
+		return services.NewRoleService(mockRoleRepo, new(mocks.MockPermissionRepository), new(mocks.Manager[transaction.Repository]), services.DefaultRoleServiceOptions())
+	}
+
+	tests := []struct {
+		name            string
+		rolePermissions []models.Permission
+		otherPerms      []models.Permission
+		wantOnlyInRole  []string
+		wantOnlyInOther []string
+		wantInBoth      []string
+	}{
+		{
+			name:            "Partial overlap",
+			rolePermissions: []models.Permission{write, read, deletePermission},
+			otherPerms:      []models.Permission{read, roleRead},
+			wantOnlyInRole:  []string{"user:delete", "user:write"},
+			wantOnlyInOther: []string{"role:read"},
+			wantInBoth:      []string{"user:read"},
+		},
+		{
+			name:            "Identical roles",
+			rolePermissions: []models.Permission{read, write},
+			otherPerms:      []models.Permission{write, read},
+			wantOnlyInRole:  []string{},
+			wantOnlyInOther: []string{},
+			wantInBoth:      []string{"user:read", "user:write"},
+		},
+		{
+			name:            "Disjoint roles",
+			rolePermissions: []models.Permission{read},
+			otherPerms:      []models.Permission{roleRead, deletePermission},
+			wantOnlyInRole:  []string{"user:read"},
+			wantOnlyInOther: []string{"role:read", "user:delete"},
+			wantInBoth:      []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role := &models.Role{ID: uuid.New(), Name: "editor", Permissions: tt.rolePermissions}
+			other := &models.Role{ID: uuid.New(), Name: "viewer", Permissions: tt.otherPerms}
+			roleService := setup(role, other)
+
+			diff, err := roleService.DiffRolePermissions(context.Background(), role.ID.String(), other.ID.String())
+
+			assert.NoError(t, err)
+			assert.Equal(t, role.ID, diff.RoleID)
+			assert.Equal(t, other.ID, diff.OtherRoleID)
+			assert.Equal(t, tt.wantOnlyInRole, names(diff.OnlyInRole))
+			assert.Equal(t, tt.wantOnlyInOther, names(diff.OnlyInOther))
+			assert.Equal(t, tt.wantInBoth, names(diff.InBoth))
+		})
+	}
+
+	t.Run("Other role not found", func(t *testing.T) {
+		role := &models.Role{ID: uuid.New(), Name: "editor"}
+		otherID := uuid.New()
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockRoleRepo.On("GetByID", mock.Anything, role.ID).Return(role, nil)
+		mockRoleRepo.On("GetByID", mock.Anything, otherID).Return(nil, errors.New("role not found"))
+		roleService := services.NewRoleService(mockRoleRepo, new(mocks.MockPermissionRepository), new(mocks.Manager[transaction.Repository]), services.DefaultRoleServiceOptions())
+
+		diff, err := roleService.DiffRolePermissions(context.Background(), role.ID.String(), otherID.String())
+
+		assert.Nil(t, diff)
+		assert.EqualError(t, err, "other role: role not found")
+	})
+
+	t.Run("Invalid role ID", func(t *testing.T) {
+		roleService := services.NewRoleService(new(mocks.MockRoleRepository), new(mocks.MockPermissionRepository), new(mocks.Manager[transaction.Repository]), services.DefaultRoleServiceOptions())
+
+		diff, err := roleService.DiffRolePermissions(context.Background(), uuid.New().String(), "invalid-id")
+
+		assert.Nil(t, diff)
+		assert.ErrorContains(t, err, "invalid other role ID")
+	})
+}
+
+func TestRoleService_PreviewRolePermissionChange(t *testing.T) {
+	read := &models.Permission{ID: uuid.New(), Name: "user:read", Resource: "user", Action: "read"}
+	write := &models.Permission{ID: uuid.New(), Name: "user:write", Resource: "user", Action: "write"}
+	deletePermission := &models.Permission{ID: uuid.New(), Name: "user:delete", Resource: "user", Action: "delete"}
+	roleRead := &models.Permission{ID: uuid.New(), Name: "role:read", Resource: "role", Action: "read"}
+	members := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+
+	names := func(permissions []models.PermissionResponse) []string {
+		result := make([]string, 0, len(permissions))
+		for _, permission := range permissions {
+			result = append(result, permission.Name)
+		}
+		return result
+	}
+
+	setup := func(role *models.Role) (*services.RoleService, *mocks.MockUserRepository) {
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockPermissionRepo := new(mocks.MockPermissionRepository)
+		mockUserRepo := new(mocks.MockUserRepository)
+
+		mockRoleRepo.On("GetByID", mock.Anything, role.ID).Return(role, nil)
+		mockPermissionRepo.On("GetAll", mock.Anything, models.SortOptions{}).Return([]*models.Permission{read, write, deletePermission, roleRead}, nil)
+		mockUserRepo.On("GetUserIDsByFilter", mock.Anything, models.UserFilter{RoleName: role.Name}).Return(members, nil)
+
+		roleService := services.NewRoleService(mockRoleRepo, mockPermissionRepo, new(mocks.Manager[transaction.Repository]), services.DefaultRoleServiceOptions())
+		roleService.UseUserRepository(mockUserRepo)
+		return roleService, mockUserRepo
+	}
+
+	tests := []struct {
+		name          string
+		proposed      []*models.Permission
+		wantAdded     []string
+		wantRemoved   []string
+		wantUnchanged []string
+		wantAffected  int
+	}{
+		{
+			name:          "Added only",
+			proposed:      []*models.Permission{read, write, deletePermission, roleRead},
+			wantAdded:     []string{"role:read", "user:delete"},
+			wantRemoved:   []string{},
+			wantUnchanged: []string{"user:read", "user:write"},
+			wantAffected:  3,
+		},
+		{
+			name:          "Removed only",
+			proposed:      []*models.Permission{read},
+			wantAdded:     []string{},
+			wantRemoved:   []string{"user:write"},
+			wantUnchanged: []string{"user:read"},
+			wantAffected:  3,
+		},
+		{
+			name:          "Mixed",
+			proposed:      []*models.Permission{write, roleRead},
+			wantAdded:     []string{"role:read"},
+			wantRemoved:   []string{"user:read"},
+			wantUnchanged: []string{"user:write"},
+			wantAffected:  3,
+		},
+		{
+			name:          "Unchanged",
+			proposed:      []*models.Permission{write, read},
+			wantAdded:     []string{},
+			wantRemoved:   []string{},
+			wantUnchanged: []string{"user:read", "user:write"},
+			wantAffected:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role := &models.Role{ID: uuid.New(), Name: "editor", Permissions: []models.Permission{*read, *write}}
+			roleService, _ := setup(role)
+
+			ids := make([]string, 0, len(tt.proposed))
+			for _, permission := range tt.proposed {
+				ids = append(ids, permission.ID.String())
+			}
+
+			impact, err := roleService.PreviewRolePermissionChange(context.Background(), role.ID.String(), models.RolePermissionImpactRequest{PermissionIDs: ids})
+
+			assert.NoError(t, err)
+			assert.Equal(t, role.ID, impact.RoleID)
+			assert.Equal(t, tt.wantAdded, names(impact.Added))
+			assert.Equal(t, tt.wantRemoved, names(impact.Removed))
+			assert.Equal(t, tt.wantUnchanged, names(impact.Unchanged))
+			assert.Equal(t, 3, impact.RoleMembers)
+			assert.Equal(t, tt.wantAffected, impact.AffectedUsers)
+		})
+	}
+
+	t.Run("Unknown permission", func(t *testing.T) {
+		role := &models.Role{ID: uuid.New(), Name: "editor"}
+		roleService, mockUserRepo := setup(role)
+		unknownID := uuid.New().String()
+
+		impact, err := roleService.PreviewRolePermissionChange(context.Background(), role.ID.String(), models.RolePermissionImpactRequest{
+			PermissionIDs: []string{read.ID.String(), unknownID},
+		})
+
+		assert.Nil(t, impact)
+		assert.EqualError(t, err, "unknown permission IDs: "+unknownID)
+		mockUserRepo.AssertNotCalled(t, "GetUserIDsByFilter", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Without a user repository", func(t *testing.T) {
+		roleService := services.NewRoleService(new(mocks.MockRoleRepository), new(mocks.MockPermissionRepository), new(mocks.Manager[transaction.Repository]), services.DefaultRoleServiceOptions())
+
+		impact, err := roleService.PreviewRolePermissionChange(context.Background(), uuid.New().String(), models.RolePermissionImpactRequest{})
+
+		assert.Nil(t, impact)
+		assert.ErrorContains(t, err, "not configured")
+	})
+}
+
+func TestRoleService_ChangeRolePermissions(t *testing.T) {
+	role := &models.Role{ID: uuid.New(), Name: "editor"}
+	readPermission := models.Permission{ID: uuid.New(), Name: "user:read", Resource: "user", Action: "read"}
+	writePermission := models.Permission{ID: uuid.New(), Name: "user:write", Resource: "user", Action: "write"}
+
+	// held is what the role holds once the change is applied
+	setup := func(held ...models.Permission) (*services.RoleService, *mocks.MockRoleRepository, *mocks.MockTxRepository, *mocks.Manager[transaction.Repository]) {
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxRepo := new(mocks.MockTxRepository)
+
+		mockRoleRepo.On("GetByID", mock.Anything, role.ID).Return(role, nil)
+		mockRoleRepo.On("GetRolePermissions", mock.Anything, role.ID).Return(held, nil)
+		mockRoleRepo.On("InvalidatePermissionCache").Return()
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(func(ctx context.Context, fn func(transaction.Repository) error) error {
+			return fn(mockTxRepo)
+		})
+
+		return services.NewRoleService(mockRoleRepo, new(mocks.MockPermissionRepository), mockTxManager, services.DefaultRoleServiceOptions()), mockRoleRepo, mockTxRepo, mockTxManager
+	}
+	request := func(permissions ...models.Permission) models.RolePermissionsChangeRequest {
+		ids := make([]string, len(permissions))
+		for i, permission := range permissions {
+			ids[i] = permission.ID.String()
+		}
+		return models.RolePermissionsChangeRequest{PermissionIDs: ids}
+	}
+
+	t.Run("Add keeps the existing permissions", func(t *testing.T) {
+		roleService, mockRoleRepo, mockTxRepo, _ := setup(readPermission, writePermission)
+		mockTxRepo.On("AddPermissionsToRole", mock.Anything, role.ID, []uuid.UUID{writePermission.ID}).Return([]uuid.UUID{writePermission.ID}, nil)
+
+		result, err := roleService.AddRolePermissions(context.Background(), role.ID.String(), request(writePermission))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{writePermission.ID.String()}, result.Changed)
+		assert.Empty(t, result.Unchanged)
+		assert.Equal(t, []models.Permission{readPermission, writePermission}, result.Permissions)
+		// The permission set is never replaced
+		mockTxRepo.AssertNotCalled(t, "AssignPermissionsToRole", mock.Anything, mock.Anything, mock.Anything)
+		mockRoleRepo.AssertCalled(t, "InvalidatePermissionCache")
+	})
+
+	t.Run("Adding a held permission changes nothing", func(t *testing.T) {
+		roleService, mockRoleRepo, mockTxRepo, _ := setup(readPermission)
+		mockTxRepo.On("AddPermissionsToRole", mock.Anything, role.ID, []uuid.UUID{readPermission.ID}).Return([]uuid.UUID{}, nil)
+
+		result, err := roleService.AddRolePermissions(context.Background(), role.ID.String(), request(readPermission))
+
+		assert.NoError(t, err)
+		assert.Empty(t, result.Changed)
+		assert.Equal(t, []string{readPermission.ID.String()}, result.Unchanged)
+		assert.Equal(t, []models.Permission{readPermission}, result.Permissions)
+		mockRoleRepo.AssertNotCalled(t, "InvalidatePermissionCache")
+	})
+
+	t.Run("Change marks the permission snapshot stale", func(t *testing.T) {
+		roleService, _, mockTxRepo, _ := setup(readPermission, writePermission)
+		mockTxRepo.On("AddPermissionsToRole", mock.Anything, role.ID, []uuid.UUID{writePermission.ID}).Return([]uuid.UUID{writePermission.ID}, nil)
+		snapshotRoleRepo := new(mocks.MockRoleRepository)
+		snapshotRoleRepo.On("GetAll", mock.Anything, true, models.SortOptions{}).Return([]*models.Role{role}, nil)
+		snapshot := services.NewRolePermissionSnapshot(snapshotRoleRepo, time.Minute)
+		require.NoError(t, snapshot.Refresh(context.Background()))
+		roleService.UsePermissionSnapshot(snapshot)
+
+		_, err := roleService.AddRolePermissions(context.Background(), role.ID.String(), request(writePermission))
+
+		assert.NoError(t, err)
+		_, fresh := snapshot.Check([]string{role.Name}, "user", "write")
+		assert.False(t, fresh)
+	})
+
+	t.Run("Remove keeps the other permissions", func(t *testing.T) {
+		roleService, mockRoleRepo, mockTxRepo, _ := setup(writePermission)
+		mockTxRepo.On("RemovePermissionsFromRole", mock.Anything, role.ID, []uuid.UUID{readPermission.ID}).Return([]uuid.UUID{readPermission.ID}, nil)
+
+		result, err := roleService.RemoveRolePermissions(context.Background(), role.ID.String(), request(readPermission))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{readPermission.ID.String()}, result.Changed)
+		assert.Equal(t, []models.Permission{writePermission}, result.Permissions)
+		mockTxRepo.AssertNotCalled(t, "AssignPermissionsToRole", mock.Anything, mock.Anything, mock.Anything)
+		mockRoleRepo.AssertCalled(t, "InvalidatePermissionCache")
+	})
+
+	t.Run("Removing a permission the role does not hold changes nothing", func(t *testing.T) {
+		roleService, mockRoleRepo, mockTxRepo, _ := setup(readPermission)
+		mockTxRepo.On("RemovePermissionsFromRole", mock.Anything, role.ID, []uuid.UUID{writePermission.ID}).Return([]uuid.UUID{}, nil)
+
+		result, err := roleService.RemoveRolePermissions(context.Background(), role.ID.String(), request(writePermission))
+
+		assert.NoError(t, err)
+		assert.Empty(t, result.Changed)
+		assert.Equal(t, []string{writePermission.ID.String()}, result.Unchanged)
+		mockRoleRepo.AssertNotCalled(t, "InvalidatePermissionCache")
+	})
+
+	t.Run("Unknown permission IDs", func(t *testing.T) {
+		roleService, mockRoleRepo, mockTxRepo, _ := setup()
+		unknownID := uuid.New()
+		mockTxRepo.On("AddPermissionsToRole", mock.Anything, role.ID, []uuid.UUID{unknownID}).
+			Return(nil, models.MissingPermissionsError([]uuid.UUID{unknownID}, nil))
+
+		result, err := roleService.AddRolePermissions(context.Background(), role.ID.String(), models.RolePermissionsChangeRequest{
+			PermissionIDs: []string{unknownID.String()},
+		})
+
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, models.ErrPermissionNotFound)
+		assert.Contains(t, err.Error(), unknownID.String())
+		mockRoleRepo.AssertNotCalled(t, "InvalidatePermissionCache")
+	})
+
+	t.Run("No permission IDs", func(t *testing.T) {
+		roleService, _, _, mockTxManager := setup()
+
+		result, err := roleService.RemoveRolePermissions(context.Background(), role.ID.String(), models.RolePermissionsChangeRequest{})
+
+		assert.Nil(t, result)
+		assert.EqualError(t, err, "at least one permission ID is required")
+		mockTxManager.AssertNotCalled(t, "ExecuteTx", mock.Anything, mock.Anything)
+	})
+}
+
+func TestRoleService_PrivilegeEscalation(t *testing.T) {
+	actorID := uuid.New()
+	role := &models.Role{ID: uuid.New(), Name: "editor"}
+	readPermission := models.Permission{ID: uuid.New(), Name: "user:read", Resource: "user", Action: "read"}
+	deletePermission := models.Permission{ID: uuid.New(), Name: "user:delete", Resource: "user", Action: "delete"}
+
+	setup := func() (*services.RoleService, *mocks.MockTxRepository) {
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockPermissionRepo := new(mocks.MockPermissionRepository)
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxRepo := new(mocks.MockTxRepository)
+
+		mockRoleRepo.On("GetByID", mock.Anything, role.ID).Return(role, nil)
+		mockRoleRepo.On("GetRolePermissions", mock.Anything, role.ID).Return([]models.Permission{readPermission}, nil)
+		mockRoleRepo.On("InvalidatePermissionCache").Return()
+		mockPermissionRepo.On("GetByID", mock.Anything, readPermission.ID).Return(&readPermission, nil)
+		mockPermissionRepo.On("GetByID", mock.Anything, deletePermission.ID).Return(&deletePermission, nil)
+		// The acting user only holds user:read
+		mockUserRepo.On("GetUserPermissions", mock.Anything, actorID).Return([]models.Permission{readPermission}, nil)
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(func(ctx context.Context, fn func(transaction.Repository) error) error {
+			return fn(mockTxRepo)
+		})
+
+		opts := services.DefaultRoleServiceOptions()
+		opts.DenyPrivilegeEscalation = true
+		roleService := services.NewRoleService(mockRoleRepo, mockPermissionRepo, mockTxManager, opts)
+		roleService.UseUserRepository(mockUserRepo)
+		return roleService, mockTxRepo
+	}
+	ctx := services.WithActor(context.Background(), services.Actor{UserID: actorID.String()})
+
+	t.Run("Granting a permission the actor lacks is rejected", func(t *testing.T) {
+		roleService, mockTxRepo := setup()
+
+		_, err := roleService.AddRolePermissions(ctx, role.ID.String(), models.RolePermissionsChangeRequest{PermissionIDs: []string{deletePermission.ID.String()}})
+
+		assert.ErrorIs(t, err, services.ErrPrivilegeEscalation)
+		assert.ErrorContains(t, err, "user:delete")
+		mockTxRepo.AssertNotCalled(t, "AddPermissionsToRole", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Granting a held permission is allowed", func(t *testing.T) {
+		roleService, mockTxRepo := setup()
+		mockTxRepo.On("AddPermissionsToRole", mock.Anything, role.ID, []uuid.UUID{readPermission.ID}).Return([]uuid.UUID{readPermission.ID}, nil)
+
+		_, err := roleService.AddRolePermissions(ctx, role.ID.String(), models.RolePermissionsChangeRequest{PermissionIDs: []string{readPermission.ID.String()}})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("API keys are held to their scopes", func(t *testing.T) {
+		roleService, _ := setup()
+		keyCtx := services.WithActor(context.Background(), services.Actor{APIKey: &models.APIKey{Permissions: []string{"role:write", "user:read"}}})
+
+		_, err := roleService.AddRolePermissions(keyCtx, role.ID.String(), models.RolePermissionsChangeRequest{PermissionIDs: []string{deletePermission.ID.String()}})
+
+		assert.ErrorIs(t, err, services.ErrPrivilegeEscalation)
+	})
+}
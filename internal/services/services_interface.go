@@ -12,9 +12,11 @@ import (
 // AuthService defines the interface for authentication service operations
 type AuthServiceInterface interface {
 	Login(ctx context.Context, request models.LoginRequest) (*models.LoginResponse, error)
+	ReissueToken(ctx context.Context, userID string) (*models.LoginResponse, error)
 	VerifyToken(ctx context.Context, tokenString string) (*utils.JWTClaims, error)
 	ChangePassword(ctx context.Context, userID string, currentPassword, newPassword string) error
-	ResetPassword(ctx context.Context, userID string) (string, error)
+	ResetPassword(ctx context.Context, userID string) error
+	ConfirmPasswordReset(ctx context.Context, token, newPassword string) error
 	CheckPermission(ctx context.Context, userID string, resource, action string) (bool, error)
 	GenerateToken(userID uuid.UUID, username string, roles []string) (string, time.Time, error)
 }
@@ -24,10 +26,13 @@ type UserServiceInterface interface {
 	CreateUser(ctx context.Context, request models.UserCreateRequest) (*models.UserResponse, error)
 	GetUserByID(ctx context.Context, id string) (*models.UserResponse, error)
 	GetUserByUsername(ctx context.Context, username string) (*models.UserResponse, error)
-	GetAllUsers(ctx context.Context, page, pageSize int) ([]models.UserResponse, int, error)
+	GetAllUsers(ctx context.Context, page, pageSize int, sort models.SortOptions) ([]models.UserResponse, int, error)
 	UpdateUser(ctx context.Context, id string, request models.UserUpdateRequest) (*models.UserResponse, error)
 	DeleteUser(ctx context.Context, id string) error
+	TransferRoles(ctx context.Context, sourceID, targetID string, request models.RoleTransferRequest) (*models.RoleTransferResponse, error)
+	BulkDeactivateUsers(ctx context.Context, actorID string, request models.BulkDeactivateRequest) (*models.BulkDeactivateResponse, error)
 	GetUserPermissions(ctx context.Context, id string) ([]models.PermissionResponse, error)
+	GetUserPermissionsGrouped(ctx context.Context, id string) (map[string][]string, error)
 	HasPermission(ctx context.Context, userID, resource, action string) (bool, error)
 }
 
@@ -35,18 +40,33 @@ type UserServiceInterface interface {
 type RoleServiceInterface interface {
 	CreateRole(ctx context.Context, request models.RoleCreateRequest) (*models.RoleResponse, error)
 	GetRoleByID(ctx context.Context, id string) (*models.RoleResponse, error)
-	GetAllRoles(ctx context.Context) ([]models.RoleResponse, error)
+	GetAllRoles(ctx context.Context, includePermissions bool, sort models.SortOptions) ([]models.RoleResponse, error)
+	DiffRolePermissions(ctx context.Context, id, otherID string) (*models.RolePermissionDiffResponse, error)
 	UpdateRole(ctx context.Context, id string, request models.RoleUpdateRequest) (*models.RoleResponse, error)
 	DeleteRole(ctx context.Context, id string) error
 	GetRolePermissions(ctx context.Context, id string) ([]models.PermissionResponse, error)
+	ValidateRolePermissions(ctx context.Context, id string, request models.RolePermissionsValidateRequest) (*models.RolePermissionsValidationResponse, error)
+	AddRolePermissions(ctx context.Context, id string, request models.RolePermissionsChangeRequest) (*models.RolePermissionsChangeResponse, error)
+	RemoveRolePermissions(ctx context.Context, id string, request models.RolePermissionsChangeRequest) (*models.RolePermissionsChangeResponse, error)
 }
 
 // PermissionService defines the interface for permission service operations
 type PermissionServiceInterface interface {
 	CreatePermission(ctx context.Context, request models.PermissionCreateRequest) (*models.PermissionResponse, error)
 	GetPermissionByID(ctx context.Context, id string) (*models.PermissionResponse, error)
-	GetAllPermissions(ctx context.Context) ([]models.PermissionResponse, error)
+	GetAllPermissions(ctx context.Context, sort models.SortOptions) ([]models.PermissionResponse, error)
 	GetPermissionsByResource(ctx context.Context, resource string) ([]models.PermissionResponse, error)
+	GetPermissionsByAction(ctx context.Context, action string) ([]models.PermissionResponse, error)
+	GetPermissionsByResourceAction(ctx context.Context, resource, action string) ([]models.PermissionResponse, error)
 	UpdatePermission(ctx context.Context, id string, request models.PermissionUpdateRequest) (*models.PermissionResponse, error)
 	DeletePermission(ctx context.Context, id string) error
 }
+
+// APIKeyService defines the interface for API key service operations
+type APIKeyServiceInterface interface {
+	CreateAPIKey(ctx context.Context, createdBy string, request models.APIKeyCreateRequest) (*models.APIKeyCreateResponse, error)
+	GetAllAPIKeys(ctx context.Context) ([]models.APIKeyResponse, error)
+	GetAPIKey(ctx context.Context, id string) (*models.APIKeyResponse, error)
+	RevokeAPIKey(ctx context.Context, id string) error
+	Authenticate(ctx context.Context, rawKey string) (*models.APIKey, error)
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+
+	"github.com/chats/go-user-api/internal/events"
+	"github.com/chats/go-user-api/internal/logger"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/google/uuid"
+)
+
+// EventEmitter queues activity events for publishing without blocking, as events.Dispatcher does
+type EventEmitter interface {
+	Emit(event events.Envelope) bool
+}
+
+// emit publishes a lifecycle event about user through the service's emitter
+func (s *UserService) emit(ctx context.Context, eventType string, user *models.User, payload map[string]interface{}) {
+	emitUserEvent(ctx, s.events, eventType, user, payload)
+}
+
+// emitUserEvent publishes a lifecycle event about user, attributed to the actor recorded on ctx.
+// Calls made without an actor are attributed to the system. Nothing is published without an emitter.
+func emitUserEvent(ctx context.Context, emitter EventEmitter, eventType string, user *models.User, payload map[string]interface{}) {
+	if emitter == nil {
+		return
+	}
+
+	builder := events.NewEvent(eventType).With("user_id", user.ID.String())
+	if user.Username != "" {
+		builder.With("username", logger.MaskUsername(user.Username))
+	}
+	builder.WithPayload(payload)
+	if actor, ok := actorFrom(ctx); ok {
+		switch {
+		case actor.APIKey != nil:
+			builder.ByAPIKey(actor.APIKey.ID.String(), actor.APIKey.Name)
+		case actor.UserID != "":
+			builder.ByUser(actor.UserID, "")
+		}
+	}
+
+	emitter.Emit(builder.Build())
+}
+
+// emitRolesChanged publishes the roles a user holds after they changed
+func (s *UserService) emitRolesChanged(ctx context.Context, user *models.User, roleIDs []uuid.UUID) {
+	ids := make([]string, len(roleIDs))
+	for i, roleID := range roleIDs {
+		ids[i] = roleID.String()
+	}
+	s.emit(ctx, events.TypeUserRolesChanged, user, map[string]interface{}{"role_ids": ids})
+}
@@ -0,0 +1,341 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/chats/go-user-api/internal/events"
+	"github.com/chats/go-user-api/internal/logger"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/repositories/transaction"
+	"github.com/chats/go-user-api/internal/utils"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// userImportBatchSize is the number of imported users created per transaction
+const userImportBatchSize = 100
+
+// maxUserImportRows bounds the rows of a single user import
+const maxUserImportRows = 5000
+
+// generatedPasswordLength is the length of passwords generated for imported users
+const generatedPasswordLength = 16
+
+// importColumns are the columns a user import CSV may have; importRequiredColumns must be present
+var (
+	importColumns = []string{
+		models.ImportColumnUsername, models.ImportColumnEmail, models.ImportColumnFirstName,
+		models.ImportColumnLastName, models.ImportColumnRoles, models.ImportColumnPassword,
+	}
+	importRequiredColumns = []string{models.ImportColumnUsername, models.ImportColumnEmail, models.ImportColumnPassword}
+)
+
+// importedUser is a valid import row waiting to be created
+type importedUser struct {
+	row      *models.UserImportRow
+	user     *models.User
+	roleIDs  []uuid.UUID
+	password string
+	// generated is set when the password was generated, to be handed back
+	generated bool
+}
+
+// ImportUsers creates users from a CSV, validating the header and every row first. Rows that are
+// invalid or duplicate a user, or an earlier row, are reported and skipped; the rest are created in
+// batched transactions, and when a batch fails its rows are created one by one so each reports its
+// own outcome. A dry run only validates. Roles are named in the roles column, separated by ";".
+func (s *UserService) ImportUsers(ctx context.Context, data io.Reader, dryRun bool) (*models.UserImportResponse, error) {
+	reader := csv.NewReader(data)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("CSV is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	columns, err := parseImportHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pending users point into the rows, so read every record before reporting any
+	var records [][]string
+	var lines []int
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		if len(records) == maxUserImportRows {
+			return nil, fmt.Errorf("CSV has more than %d rows", maxUserImportRows)
+		}
+
+		line, _ := reader.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+	response := &models.UserImportResponse{
+		DryRun: dryRun,
+		Rows:   make([]models.UserImportRow, len(records)),
+	}
+
+	roleIDs := make(map[string]uuid.UUID)
+	seen := make(map[string]int)
+	pending := make([]importedUser, 0, len(records))
+	for i, record := range records {
+		row := &response.Rows[i]
+		row.Row = lines[i]
+
+		request, generated, err := s.importRequest(ctx, columns, record, roleIDs)
+		row.Username = request.Username
+		if err != nil {
+			row.Status, row.Error = models.ImportRowInvalid, err.Error()
+			continue
+		}
+
+		if duplicate := s.importDuplicate(ctx, request, row.Row, seen); duplicate != "" {
+			row.Status, row.Error = models.ImportRowDuplicate, duplicate
+			continue
+		}
+
+		user, userRoleIDs, err := s.prepareUser(ctx, request)
+		if err != nil {
+			row.Status, row.Error = models.ImportRowInvalid, err.Error()
+			continue
+		}
+
+		row.Status = models.ImportRowValid
+		pending = append(pending, importedUser{row: row, user: user, roleIDs: userRoleIDs, password: request.Password, generated: generated})
+	}
+
+	if !dryRun {
+		if err := s.createImportedUsers(ctx, pending); err != nil {
+			return nil, err
+		}
+	}
+
+	response.Total = len(response.Rows)
+	for _, row := range response.Rows {
+		switch row.Status {
+		case models.ImportRowCreated:
+			response.Created++
+		case models.ImportRowValid:
+			response.Valid++
+		case models.ImportRowDuplicate:
+			response.Duplicates++
+		case models.ImportRowInvalid:
+			response.Invalid++
+		case models.ImportRowFailed:
+			response.Failed++
+		}
+	}
+
+	return response, nil
+}
+
+// parseImportHeader maps each known column of the header to its field index
+func parseImportHeader(header []string) (map[string]int, error) {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		// Spreadsheets may start the file with a byte order mark
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !slices.Contains(importColumns, name) {
+			return nil, fmt.Errorf("unknown column %q: columns are %s", name, strings.Join(importColumns, ", "))
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("column %q appears more than once", name)
+		}
+		columns[name] = i
+	}
+
+	for _, name := range importRequiredColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+
+	return columns, nil
+}
+
+// importRequest validates a CSV row and turns it into a create request, resolving role names through
+// roleIDs, a cache shared by the rows. A password is generated when the row asks for one, and
+// reported by generated.
+func (s *UserService) importRequest(ctx context.Context, columns map[string]int, record []string, roleIDs map[string]uuid.UUID) (request models.UserCreateRequest, generated bool, err error) {
+	// Passwords are taken as given, other fields trimmed
+	value := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+	field := func(name string) string {
+		return strings.TrimSpace(value(name))
+	}
+
+	request = models.UserCreateRequest{
+		Username:  field(models.ImportColumnUsername),
+		Email:     field(models.ImportColumnEmail),
+		FirstName: field(models.ImportColumnFirstName),
+		LastName:  field(models.ImportColumnLastName),
+		Password:  value(models.ImportColumnPassword),
+	}
+
+	if len(record) != len(columns) {
+		return request, false, fmt.Errorf("expected %d fields, got %d", len(columns), len(record))
+	}
+
+	if len(request.Username) < 6 || len(request.Username) > 50 || strings.IndexFunc(request.Username, isNotAlphanumeric) >= 0 {
+		return request, false, fmt.Errorf("username must be 6 to 50 letters or digits")
+	}
+	if address, err := mail.ParseAddress(request.Email); err != nil || address.Address != request.Email {
+		return request, false, fmt.Errorf("email %q is not a valid address", request.Email)
+	}
+	if len(request.FirstName) > 150 || len(request.LastName) > 150 {
+		return request, false, fmt.Errorf("first and last name must be at most 150 characters")
+	}
+
+	switch {
+	case request.Password == "":
+		return request, false, fmt.Errorf("password is required, or %q to generate one", models.ImportGeneratePassword)
+	case request.Password == models.ImportGeneratePassword:
+		password, err := utils.GenerateRandomPassword(generatedPasswordLength)
+		if err != nil {
+			return request, false, fmt.Errorf("failed to generate password: %w", err)
+		}
+		request.Password = password
+		generated = true
+	case len(request.Password) < 8 || len(request.Password) > 100:
+		return request, false, fmt.Errorf("password must be 8 to 100 characters")
+	}
+
+	for _, name := range strings.Split(field(models.ImportColumnRoles), ";") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		roleID, ok := roleIDs[name]
+		if !ok {
+			role, err := s.roleRepo.GetByName(ctx, name)
+			if err != nil || role == nil {
+				return request, false, fmt.Errorf("unknown role %q", name)
+			}
+			roleID = role.ID
+			roleIDs[name] = roleID
+		}
+		request.RoleIDs = append(request.RoleIDs, roleID.String())
+	}
+
+	return request, generated, nil
+}
+
+// isNotAlphanumeric reports whether r is neither a letter nor a digit
+func isNotAlphanumeric(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// importDuplicate describes why a row duplicates an existing user or an earlier row, or returns ""
+func (s *UserService) importDuplicate(ctx context.Context, request models.UserCreateRequest, row int, seen map[string]int) string {
+	usernameKey := "username:" + strings.ToLower(request.Username)
+	emailKey := "email:" + strings.ToLower(request.Email)
+
+	if earlier, ok := seen[usernameKey]; ok {
+		return fmt.Sprintf("username duplicates row %d", earlier)
+	}
+	if earlier, ok := seen[emailKey]; ok {
+		return fmt.Sprintf("email duplicates row %d", earlier)
+	}
+	seen[usernameKey] = row
+	seen[emailKey] = row
+
+	// Taken emails are only found when the user is created
+	if existing, err := s.userRepo.GetByUsername(ctx, request.Username); err == nil && existing != nil {
+		return models.ErrUsernameExists.Error()
+	}
+
+	return ""
+}
+
+// createImportedUsers creates the valid import rows, a batch per transaction. A failing row rolls back
+// its whole batch, so the batch is then retried a row at a time to find and report it.
+func (s *UserService) createImportedUsers(ctx context.Context, pending []importedUser) error {
+	for _, imported := range pending {
+		if err := imported.user.HashPassword(imported.password, s.passwordPeppers...); err != nil {
+			return fmt.Errorf("failed to hash password: %w", err)
+		}
+	}
+
+	for start := 0; start < len(pending); start += userImportBatchSize {
+		batch := pending[start:min(start+userImportBatchSize, len(pending))]
+
+		err := s.txManager.ExecuteTx(ctx, func(tx transaction.Repository) error {
+			for _, imported := range batch {
+				if err := insertUser(ctx, tx, imported.user, imported.roleIDs); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err == nil {
+			for _, imported := range batch {
+				s.markImported(ctx, imported)
+			}
+			continue
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return contextError(ctx, ctxErr)
+		}
+
+		log.Debug().Err(err).Int("batch_size", len(batch)).Msg("User import batch failed, creating its users one by one")
+		for _, imported := range batch {
+			err := s.txManager.ExecuteTx(ctx, func(tx transaction.Repository) error {
+				return insertUser(ctx, tx, imported.user, imported.roleIDs)
+			})
+			switch {
+			case err == nil:
+				s.markImported(ctx, imported)
+			case errors.Is(err, models.ErrUsernameExists) || errors.Is(err, models.ErrEmailExists):
+				imported.row.Status, imported.row.Error = models.ImportRowDuplicate, err.Error()
+			default:
+				imported.row.Status, imported.row.Error = models.ImportRowFailed, err.Error()
+			}
+		}
+	}
+
+	return nil
+}
+
+// markImported reports an import row as created
+func (s *UserService) markImported(ctx context.Context, imported importedUser) {
+	transaction.AfterCommit(ctx, func() {
+		s.userRepo.InvalidateUser(imported.user.ID)
+		s.emit(ctx, events.TypeUserCreated, imported.user, map[string]interface{}{"source": "import"})
+	})
+
+	userID := imported.user.ID
+	imported.row.Status = models.ImportRowCreated
+	imported.row.UserID = &userID
+	if imported.generated {
+		imported.row.GeneratedPassword = imported.password
+	}
+
+	log.Info().
+		Str("event", "user.created.import").
+		Str("user_id", userID.String()).
+		Str("username", logger.MaskUsername(imported.user.Username)).
+		Msg("User created by import")
+}
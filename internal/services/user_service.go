@@ -2,9 +2,16 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/chats/go-user-api/config"
+	"github.com/chats/go-user-api/internal/events"
+	"github.com/chats/go-user-api/internal/logger"
 	"github.com/chats/go-user-api/internal/models"
 	"github.com/chats/go-user-api/internal/repositories"
 	"github.com/chats/go-user-api/internal/repositories/transaction"
@@ -13,11 +20,152 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// ErrUserNotFound is returned when a user an operation names does not exist
+var ErrUserNotFound = models.ErrUserNotFound
+
+// ErrLastAdmin is returned when a change would leave no active user holding the admin role
+var ErrLastAdmin = errors.New("cannot remove last admin")
+
+// ErrUserNotDeleted is returned when restoring a user that has not been deleted
+var ErrUserNotDeleted = errors.New("user is not deleted")
+
+// bulkDeactivateBatchSize is the number of users deactivated per transaction
+const bulkDeactivateBatchSize = 100
+
+// Default retries of reading back a user just written, and the delay between them
+const (
+	DefaultReadAfterWriteRetries    = 2
+	DefaultReadAfterWriteRetryDelay = 50 * time.Millisecond
+)
+
 // UserService handles user-related operations
 type UserService struct {
-	userRepo  repositories.UserRepositoryInterface
-	roleRepo  repositories.RoleRepositoryInterface
-	txManager transaction.Manager[transaction.Repository]
+	userRepo    repositories.UserRepositoryInterface
+	roleRepo    repositories.RoleRepositoryInterface
+	txManager   transaction.Manager[transaction.Repository]
+	idListLimit int
+
+	// protectLastAdmin rejects changes that would leave no active admin
+	protectLastAdmin bool
+
+	// passwordPeppers are combined with passwords before hashing, newest first
+	passwordPeppers []string
+
+	// defaultSort orders GetAllUsers when no sort is requested
+	defaultSort models.SortOptions
+
+	// domainRoles names the roles granted to new users by lowercase email domain
+	domainRoles map[string][]string
+
+	// partialResults returns list pages whose items could not all be assembled, with warnings,
+	// instead of failing them
+	partialResults bool
+
+	// denyEscalation rejects assigning roles that carry permissions the acting caller does not hold
+	denyEscalation bool
+
+	// defaultActive is whether new users start active; activeOverride is the "resource:action"
+	// permission a caller needs to set is_active on creation instead
+	defaultActive  bool
+	activeOverride string
+
+	// softDelete makes DeleteUser keep the user and its role assignments so it can be restored;
+	// restoreRoles names the roles a restored user gets instead of its prior ones
+	softDelete   bool
+	restoreRoles []string
+
+	// readRetries is how often a user just written is read again when the read misses it, as a
+	// lagging read replica would, waiting readRetryDelay between reads
+	readRetries    int
+	readRetryDelay time.Duration
+
+	// events publishes lifecycle events; nil publishes none
+	events EventEmitter
+}
+
+// UserServiceOptions configures a UserService; DefaultUserServiceOptions returns the defaults
+type UserServiceOptions struct {
+	// IDListLimit caps the role IDs accepted per request (0 disables the cap)
+	IDListLimit int
+
+	// PasswordPeppers are combined with passwords before hashing, newest first
+	PasswordPeppers []string
+
+	// LastAdminProtection rejects deleting, deactivating or demoting the last active admin
+	LastAdminProtection bool
+
+	// DenyPrivilegeEscalation rejects assigning a role carrying permissions the acting caller does
+	// not hold
+	DenyPrivilegeEscalation bool
+
+	// DefaultActive is whether new users start active; ActiveOverridePermission is the
+	// "resource:action" permission a caller needs to choose is_active on creation (empty lets any
+	// caller choose)
+	DefaultActive            bool
+	ActiveOverridePermission string
+
+	// SoftDelete makes DeleteUser keep users restorable rather than purging them; RestoreRoles
+	// names the roles restored users get instead of the roles they held when deleted
+	SoftDelete   bool
+	RestoreRoles []string
+
+	// ReadAfterWriteRetries is how often a user just created or updated is read again when the
+	// read misses it, waiting ReadAfterWriteRetryDelay between reads (0 reads once)
+	ReadAfterWriteRetries    int
+	ReadAfterWriteRetryDelay time.Duration
+
+	// DefaultSort orders GetAllUsers when no sort is requested
+	DefaultSort models.SortOptions
+
+	// PartialResults returns user list pages whose users' roles could not all be loaded, with
+	// warnings, rather than failing them
+	PartialResults bool
+
+	// DomainRoles names the roles granted to new users by lowercase email domain
+	DomainRoles map[string][]string
+
+	// Events publishes user lifecycle events; nil publishes none
+	Events EventEmitter
+}
+
+// DefaultUserServiceOptions returns the options a UserService uses unless configured otherwise
+func DefaultUserServiceOptions() UserServiceOptions {
+	return UserServiceOptions{
+		IDListLimit:              DefaultIDListLimit,
+		LastAdminProtection:      true,
+		DefaultActive:            true,
+		ReadAfterWriteRetries:    DefaultReadAfterWriteRetries,
+		ReadAfterWriteRetryDelay: DefaultReadAfterWriteRetryDelay,
+	}
+}
+
+// UserServiceOptionsFromConfig returns the user service options set by cfg
+func UserServiceOptionsFromConfig(cfg *config.Config) (UserServiceOptions, error) {
+	sortBy, order := cfg.GetUserDefaultSort()
+	defaultSort, err := models.ParseSortOptions(sortBy, order, models.UserSortFields)
+	if err != nil {
+		return UserServiceOptions{}, fmt.Errorf("invalid USER_DEFAULT_SORT: %w", err)
+	}
+	domainRoles, err := cfg.GetDomainRoles()
+	if err != nil {
+		return UserServiceOptions{}, err
+	}
+
+	return UserServiceOptions{
+		IDListLimit:              cfg.IDListLimit,
+		PasswordPeppers:          cfg.GetPasswordPeppers(),
+		LastAdminProtection:      cfg.LastAdminProtection,
+		DenyPrivilegeEscalation:  cfg.DenyPrivilegeEscalation,
+		DefaultActive:            cfg.DefaultUserActive,
+		ActiveOverridePermission: cfg.UserActiveOverridePermission,
+		SoftDelete:               cfg.UserSoftDelete,
+		RestoreRoles:             cfg.GetUserRestoreRoles(),
+		ReadAfterWriteRetries:    cfg.ReadAfterWriteRetries,
+		ReadAfterWriteRetryDelay: cfg.GetReadAfterWriteRetryDelay(),
+		DefaultSort:              defaultSort,
+		PartialResults:           cfg.ListPartialResults,
+		DomainRoles:              domainRoles,
+	}, nil
 }
 
 // NewUserService creates a new user service
@@ -25,70 +173,123 @@ func NewUserService(
 	userRepo repositories.UserRepositoryInterface,
 	roleRepo repositories.RoleRepositoryInterface,
 	txManager transaction.Manager[transaction.Repository],
+	opts UserServiceOptions,
 ) *UserService {
 	return &UserService{
-		userRepo:  userRepo,
-		roleRepo:  roleRepo,
-		txManager: txManager,
+		userRepo:         userRepo,
+		roleRepo:         roleRepo,
+		txManager:        txManager,
+		idListLimit:      opts.IDListLimit,
+		protectLastAdmin: opts.LastAdminProtection,
+		passwordPeppers:  opts.PasswordPeppers,
+		defaultSort:      opts.DefaultSort,
+		domainRoles:      opts.DomainRoles,
+		partialResults:   opts.PartialResults,
+		denyEscalation:   opts.DenyPrivilegeEscalation,
+		defaultActive:    opts.DefaultActive,
+		activeOverride:   opts.ActiveOverridePermission,
+		softDelete:       opts.SoftDelete,
+		restoreRoles:     opts.RestoreRoles,
+		readRetries:      opts.ReadAfterWriteRetries,
+		readRetryDelay:   opts.ReadAfterWriteRetryDelay,
+		events:           opts.Events,
+	}
+}
+
+// initialActive returns whether a new user starts active, rejecting a requested state the acting
+// caller may not choose. Calls made without an actor are not checked.
+func (s *UserService) initialActive(ctx context.Context, requested *bool) (bool, error) {
+	if requested == nil {
+		return s.defaultActive, nil
+	}
+
+	actor, ok := actorFrom(ctx)
+	if !ok || s.activeOverride == "" {
+		return *requested, nil
+	}
+
+	holds, err := actorHolds(ctx, s.userRepo, actor)
+	if err != nil {
+		return false, err
+	}
+	resource, action, _ := strings.Cut(s.activeOverride, ":")
+	if !holds(resource, action) {
+		return false, fmt.Errorf("%w: setting is_active requires %s", ErrPrivilegeEscalation, s.activeOverride)
+	}
+	return *requested, nil
+}
+
+// checkRoleWrite rejects assigning roles when the acting caller does not hold role:write. Calls
+// made without an actor are not checked.
+func (s *UserService) checkRoleWrite(ctx context.Context, roleIDs []uuid.UUID) error {
+	actor, ok := actorFrom(ctx)
+	if !ok || len(roleIDs) == 0 {
+		return nil
+	}
+
+	holds, err := actorHolds(ctx, s.userRepo, actor)
+	if err != nil {
+		return err
+	}
+	if !holds("role", "write") {
+		return fmt.Errorf("%w: assigning roles requires role:write", ErrPrivilegeEscalation)
+	}
+	return nil
+}
+
+// checkRoleGrant rejects assigning roles that carry permissions the acting caller does not hold
+func (s *UserService) checkRoleGrant(ctx context.Context, roleIDs []uuid.UUID) error {
+	if !s.denyEscalation || len(roleIDs) == 0 {
+		return nil
+	}
+
+	var granted []models.Permission
+	for _, roleID := range roleIDs {
+		permissions, err := s.roleRepo.GetRolePermissions(ctx, roleID)
+		if err != nil {
+			return fmt.Errorf("failed to get role permissions: %w", err)
+		}
+		granted = append(granted, permissions...)
 	}
+	return checkEscalation(ctx, s.userRepo, granted)
 }
 
 // CreateUser creates a new user
 func (s *UserService) CreateUser(ctx context.Context, request models.UserCreateRequest) (*models.UserResponse, error) {
-	// Check if username already exists
+	// Fail fast on a taken username. This check is advisory: a concurrent create can pass it too, so
+	// the unique index decides and its violation is reported the same way below.
 	existingUser, err := s.userRepo.GetByUsername(ctx, request.Username)
 	if err == nil && existingUser != nil {
-		return nil, fmt.Errorf("username already exists")
+		return nil, models.ErrUsernameExists
 	}
 
-	// Create user object
-	user := &models.User{
-		Username:  request.Username,
-		Email:     request.Email,
-		FirstName: request.FirstName,
-		LastName:  request.LastName,
-		IsActive:  true,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	user, roleIDs, err := s.prepareUser(ctx, request)
+	if err != nil {
+		return nil, err
 	}
 
 	// Hash password
-	if err := user.HashPassword(request.Password); err != nil {
+	if err := user.HashPassword(request.Password, s.passwordPeppers...); err != nil {
 		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
 
 	// Execute transaction with the unified transaction manager
 	err = s.txManager.ExecuteTx(ctx, func(tx transaction.Repository) error {
-		// Save user to database
-		if err := tx.CreateUser(ctx, user); err != nil {
-			return fmt.Errorf("failed to create user: %w", err)
-		}
-
-		// Assign roles if provided
-		if len(request.RoleIDs) > 0 {
-			roleIDs := make([]uuid.UUID, 0, len(request.RoleIDs))
-			for _, roleIDStr := range request.RoleIDs {
-				roleID, err := uuid.Parse(roleIDStr)
-				if err != nil {
-					return fmt.Errorf("invalid role ID: %w", err)
-				}
-				roleIDs = append(roleIDs, roleID)
-			}
-
-			if err := tx.AssignRolesToUser(ctx, user.ID, roleIDs); err != nil {
-				return fmt.Errorf("failed to assign roles: %w", err)
-			}
-		}
-
-		return nil
+		return insertUser(ctx, tx, user, roleIDs)
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
+	// Drop any cached copy written outside the transaction
+	transaction.AfterCommit(ctx, func() {
+		s.userRepo.InvalidateUser(user.ID)
+		s.emit(ctx, events.TypeUserCreated, user, nil)
+	})
+
 	// Get the updated user with roles
-	updatedUser, err := s.userRepo.GetByID(ctx, user.ID)
+	updatedUser, err := s.getWrittenUser(ctx, user.ID)
 	if err != nil {
 		log.Debug().Err(err).Msg("Failed to get updated user after creation")
 		// Return the user without roles as fallback
@@ -101,23 +302,125 @@ func (s *UserService) CreateUser(ctx context.Context, request models.UserCreateR
 	return &response, nil
 }
 
+// prepareUser builds a new user from a create request, without its password, and resolves the roles
+// to assign it, rejecting roles and an initial state the acting caller may not grant
+func (s *UserService) prepareUser(ctx context.Context, request models.UserCreateRequest) (*models.User, []uuid.UUID, error) {
+	roleIDs, err := parseIDList("role", request.RoleIDs, s.idListLimit)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.checkRoleWrite(ctx, roleIDs); err != nil {
+		return nil, nil, err
+	}
+	if err := s.checkRoleGrant(ctx, roleIDs); err != nil {
+		return nil, nil, err
+	}
+
+	isActive, err := s.initialActive(ctx, request.IsActive)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Add the roles granted by the email domain, skipping those given explicitly
+	for _, roleID := range s.domainRoleIDs(ctx, request.Email) {
+		if !slices.Contains(roleIDs, roleID) {
+			roleIDs = append(roleIDs, roleID)
+		}
+	}
+
+	// Create user object
+	user := &models.User{
+		Username:  request.Username,
+		Email:     request.Email,
+		FirstName: request.FirstName,
+		LastName:  request.LastName,
+		IsActive:  isActive,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	return user, roleIDs, nil
+}
+
+// insertUser saves a new user and assigns its roles within tx
+func insertUser(ctx context.Context, tx transaction.Repository, user *models.User, roleIDs []uuid.UUID) error {
+	// Save user to database
+	if err := tx.CreateUser(ctx, user); err != nil {
+		if errors.Is(err, models.ErrUsernameExists) || errors.Is(err, models.ErrEmailExists) {
+			return err
+		}
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	// Assign roles if provided
+	if len(roleIDs) > 0 {
+		if err := tx.AssignRolesToUser(ctx, user.ID, roleIDs); err != nil {
+			return fmt.Errorf("failed to assign roles: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// getWrittenUser reads back a user just written, reading again up to readRetries times when the read
+// misses it, as one served by a read replica that has not caught up yet would
+func (s *UserService) getWrittenUser(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, id)
+	for retry := 1; err != nil && retry <= s.readRetries; retry++ {
+		log.Debug().Err(err).
+			Str("user_id", id.String()).
+			Int("retry", retry).
+			Msg("Reading back written user again")
+
+		select {
+		case <-ctx.Done():
+			return nil, contextError(ctx, ctx.Err())
+		case <-time.After(s.readRetryDelay):
+		}
+
+		user, err = s.userRepo.GetByID(ctx, id)
+	}
+
+	return user, err
+}
+
+// domainRoleIDs resolves the roles granted to a new user by the domain of their email. A role
+// that cannot be found is skipped with a warning, so a stale mapping does not block creation.
+func (s *UserService) domainRoleIDs(ctx context.Context, email string) []uuid.UUID {
+	at := strings.LastIndex(email, "@")
+	if len(s.domainRoles) == 0 || at < 0 {
+		return nil
+	}
+
+	domain := strings.ToLower(email[at+1:])
+	roleIDs := make([]uuid.UUID, 0, len(s.domainRoles[domain]))
+	for _, name := range s.domainRoles[domain] {
+		role, err := s.roleRepo.GetByName(ctx, name)
+		if err != nil || role == nil {
+			log.Warn().Err(err).Str("domain", domain).Str("role", name).Msg("Skipping domain role that could not be found")
+			continue
+		}
+		roleIDs = append(roleIDs, role.ID)
+	}
+
+	return roleIDs
+}
+
 // GetUserByID retrieves a user by ID
 func (s *UserService) GetUserByID(ctx context.Context, id string) (*models.UserResponse, error) {
 	// Parse UUID
-	userID, err := uuid.Parse(id)
+	userID, err := parseID("user", id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid user ID: %w", err)
+		return nil, err
 	}
 
 	// Get user
 	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
-		return nil, err
+		return nil, contextError(ctx, err)
 	}
 
-	// แก้ไขตรงนี้: สร้างตัวแปรก่อนแล้วค่อย return address ของตัวแปรนั้น
-	response := user.ToResponse()
-	return &response, nil
+	return s.toDetailResponse(ctx, user)
 }
 
 // GetUserByUsername retrieves a user by username
@@ -128,13 +431,33 @@ func (s *UserService) GetUserByUsername(ctx context.Context, username string) (*
 		return nil, err
 	}
 
-	// แก้ไขตรงนี้: สร้างตัวแปรก่อนแล้วค่อย return address ของตัวแปรนั้น
-	response := user.ToResponse()
+	return s.toDetailResponse(ctx, user)
+}
+
+// toDetailResponse converts a single user to its response with the permissions of each role.
+// Lists skip this and return roles without permissions.
+func (s *UserService) toDetailResponse(ctx context.Context, user *models.User) (*models.UserResponse, error) {
+	for i := range user.Roles {
+		permissions, err := s.roleRepo.GetRolePermissions(ctx, user.Roles[i].ID)
+		if err != nil {
+			return nil, contextError(ctx, fmt.Errorf("failed to get permissions for role %s: %w", user.Roles[i].Name, err))
+		}
+		user.Roles[i].Permissions = permissions
+	}
+
+	response := user.ToResponseWith(models.WithPermissions)
 	return &response, nil
 }
 
-// GetAllUsers retrieves all users with pagination
-func (s *UserService) GetAllUsers(ctx context.Context, page, pageSize int) ([]models.UserResponse, int, error) {
+// GetAllUsers retrieves all users with pagination in the requested order
+func (s *UserService) GetAllUsers(ctx context.Context, page, pageSize int, sort models.SortOptions) ([]models.UserResponse, int, error) {
+	users, totalCount, _, err := s.GetAllUsersWithWarnings(ctx, page, pageSize, sort)
+	return users, totalCount, err
+}
+
+// GetAllUsersWithWarnings is GetAllUsers that, when partial results are enabled, returns a page
+// whose users could not all be assembled along with a warning for each problem
+func (s *UserService) GetAllUsersWithWarnings(ctx context.Context, page, pageSize int, sort models.SortOptions) ([]models.UserResponse, int, []models.Warning, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -143,34 +466,67 @@ func (s *UserService) GetAllUsers(ctx context.Context, page, pageSize int) ([]mo
 	}
 
 	offset := (page - 1) * pageSize
+	if sort.IsDefault() {
+		sort = s.defaultSort
+	}
 
-	// Get users
-	users, err := s.userRepo.GetAll(ctx, pageSize, offset)
+	// Get users; a partial page is only kept when partial results are enabled
+	var warnings []models.Warning
+	users, err := s.userRepo.GetAll(ctx, pageSize, offset, sort)
+	var partial *models.PartialResultError
+	if errors.As(err, &partial) && s.partialResults {
+		for _, warning := range partial.Warnings {
+			log.Warn().Str("user_id", warning.ID).Str("warning", warning.Message).Msg("Returning incomplete user in list")
+		}
+		warnings, err = partial.Warnings, nil
+	}
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, nil, contextError(ctx, err)
 	}
 
 	// Get total count
 	totalCount, err := s.userRepo.CountUsers(ctx)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, nil, contextError(ctx, err)
+	}
+
+	return toResponses(users), totalCount, warnings, nil
+}
+
+// SearchUsers retrieves the users matching the filter with pagination, oldest first
+func (s *UserService) SearchUsers(ctx context.Context, filter models.UserFilter, page, pageSize int) ([]models.UserResponse, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+
+	userIDs, err := s.userRepo.GetUserIDsByFilter(ctx, filter)
+	if err != nil {
+		return nil, 0, contextError(ctx, err)
+	}
+
+	totalCount := len(userIDs)
+	offset := (page - 1) * pageSize
+	if offset >= totalCount {
+		return []models.UserResponse{}, totalCount, nil
 	}
 
-	// Convert to response format
-	userResponses := make([]models.UserResponse, len(users))
-	for i, user := range users {
-		userResponses[i] = user.ToResponse()
+	users, err := s.userRepo.GetByIDs(ctx, userIDs[offset:min(offset+pageSize, totalCount)])
+	if err != nil {
+		return nil, 0, contextError(ctx, err)
 	}
 
-	return userResponses, totalCount, nil
+	return toResponses(users), totalCount, nil
 }
 
 // UpdateUser updates a user
 func (s *UserService) UpdateUser(ctx context.Context, id string, request models.UserUpdateRequest) (*models.UserResponse, error) {
 	// Parse UUID
-	userID, err := uuid.Parse(id)
+	userID, err := parseID("user", id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid user ID: %w", err)
+		return nil, err
 	}
 
 	// Get existing user
@@ -183,10 +539,14 @@ func (s *UserService) UpdateUser(ctx context.Context, id string, request models.
 	if request.Username != "" && request.Username != user.Username {
 		existingUser, err := s.userRepo.GetByUsername(ctx, request.Username)
 		if err == nil && existingUser != nil {
-			return nil, fmt.Errorf("username already exists")
+			return nil, models.ErrUsernameExists
 		}
 	}
 
+	// Deactivating an admin or replacing its roles may leave no admin behind
+	wasAdmin := s.guardsAdmin(user)
+	wasActive := user.IsActive
+
 	// Update fields if provided
 	if request.Username != "" {
 		user.Username = request.Username
@@ -205,14 +565,39 @@ func (s *UserService) UpdateUser(ctx context.Context, id string, request models.
 	}
 	user.UpdatedAt = time.Now()
 
+	roleIDs, err := parseIDList("role", request.RoleIDs, s.idListLimit)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRoleWrite(ctx, roleIDs); err != nil {
+		return nil, err
+	}
+
+	// Only the roles the user does not hold yet are granted
+	added := make([]uuid.UUID, 0, len(roleIDs))
+	for _, roleID := range roleIDs {
+		if !slices.ContainsFunc(user.Roles, func(role models.Role) bool { return role.ID == roleID }) {
+			added = append(added, roleID)
+		}
+	}
+	if err := s.checkRoleGrant(ctx, added); err != nil {
+		return nil, err
+	}
+
+	checkAdmins := wasAdmin && (!user.IsActive || len(roleIDs) > 0)
+
 	// Start transaction
 	err = s.txManager.ExecuteTx(ctx, func(tx transaction.Repository) error {
+		if err := requireRollback(tx, checkAdmins); err != nil {
+			return err
+		}
+
 		// Update user in database
 		if err := tx.UpdateUser(ctx, user); err != nil {
 			return fmt.Errorf("failed to update user: %w", err)
 		}
 		if request.Password != "" {
-			hashedPassword, err := utils.HashPassword(request.Password)
+			hashedPassword, err := utils.HashPassword(request.Password, s.passwordPeppers...)
 			if err != nil {
 				return fmt.Errorf("failed to hash password: %w", err)
 			}
@@ -221,20 +606,14 @@ func (s *UserService) UpdateUser(ctx context.Context, id string, request models.
 				return fmt.Errorf("failed to update password: %w", err)
 			}
 		}
-		if len(request.RoleIDs) > 0 {
-			roleIDs := make([]uuid.UUID, 0, len(request.RoleIDs))
-			for _, roleIDStr := range request.RoleIDs {
-				roleID, err := uuid.Parse(roleIDStr)
-				if err != nil {
-					return fmt.Errorf("invalid role ID: %w", err)
-				}
-				roleIDs = append(roleIDs, roleID)
-			}
-
+		if len(roleIDs) > 0 {
 			if err := tx.AssignRolesToUser(ctx, user.ID, roleIDs); err != nil {
 				return fmt.Errorf("failed to assign roles: %w", err)
 			}
 		}
+		if checkAdmins {
+			return ensureAdminRemains(ctx, tx)
+		}
 		return nil
 	})
 
@@ -242,8 +621,21 @@ func (s *UserService) UpdateUser(ctx context.Context, id string, request models.
 		return nil, err
 	}
 
+	// Drop any cached copy written outside the transaction
+	transaction.AfterCommit(ctx, func() {
+		s.userRepo.InvalidateUser(user.ID)
+
+		s.emit(ctx, events.TypeUserUpdated, user, nil)
+		if len(roleIDs) > 0 {
+			s.emitRolesChanged(ctx, user, roleIDs)
+		}
+		if wasActive && !user.IsActive {
+			s.emit(ctx, events.TypeUserDeactivated, user, nil)
+		}
+	})
+
 	// Get the updated user with roles
-	updatedUser, err := s.userRepo.GetByID(ctx, user.ID)
+	updatedUser, err := s.getWrittenUser(ctx, user.ID)
 	if err != nil {
 		log.Debug().Err(err).Msg("Failed to get updated user after update")
 		// แก้ไขตรงนี้: สร้างตัวแปรก่อนแล้วค่อย return address ของตัวแปรนั้น
@@ -256,47 +648,781 @@ func (s *UserService) UpdateUser(ctx context.Context, id string, request models.
 	return &response, nil
 }
 
-// DeleteUser deletes a user
-func (s *UserService) DeleteUser(ctx context.Context, id string) error {
-	// Parse UUID
-	userID, err := uuid.Parse(id)
+// TransferRoles assigns every role of the source user to the target user in one transaction.
+// Roles the target already holds are left alone. In move mode the source loses its roles,
+// and the source can optionally be deactivated.
+func (s *UserService) TransferRoles(ctx context.Context, sourceID, targetID string, request models.RoleTransferRequest) (*models.RoleTransferResponse, error) {
+	mode := request.Mode
+	if mode == "" {
+		mode = models.RoleTransferCopy
+	}
+	if mode != models.RoleTransferCopy && mode != models.RoleTransferMove {
+		return nil, fmt.Errorf("invalid transfer mode %q, must be copy or move", request.Mode)
+	}
+
+	// Parse UUIDs
+	sourceUserID, err := parseID("source user", sourceID)
+	if err != nil {
+		return nil, err
+	}
+	targetUserID, err := parseID("target user", targetID)
 	if err != nil {
-		return fmt.Errorf("invalid user ID: %w", err)
+		return nil, err
+	}
+	if sourceUserID == targetUserID {
+		return nil, fmt.Errorf("source and target users must differ")
 	}
 
-	// Delete user
-	return s.userRepo.Delete(ctx, userID)
-}
+	// Both users must exist
+	source, err := s.getPartyUser(ctx, sourceUserID, "source")
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.getPartyUser(ctx, targetUserID, "target")
+	if err != nil {
+		return nil, err
+	}
 
-// GetUserPermissions retrieves all permissions for a user
-func (s *UserService) GetUserPermissions(ctx context.Context, id string) ([]models.PermissionResponse, error) {
-	// Parse UUID
-	userID, err := uuid.Parse(id)
+	roleIDs, transferred, alreadyAssigned, err := s.combineRoles(ctx, target, source)
 	if err != nil {
-		return nil, fmt.Errorf("invalid user ID: %w", err)
+		return nil, err
 	}
 
-	// Get permissions
-	permissions, err := s.userRepo.GetUserPermissions(ctx, userID)
+	response := &models.RoleTransferResponse{
+		SourceUserID:      source.ID,
+		TargetUserID:      target.ID,
+		Mode:              mode,
+		TransferredRoles:  transferred,
+		AlreadyAssigned:   alreadyAssigned,
+		SourceDeactivated: request.DeactivateSource,
+	}
+
+	// Moving the admin role away or deactivating an admin source may leave no admin behind,
+	// since the target can be inactive
+	checkAdmins := s.guardsAdmin(source) && (mode == models.RoleTransferMove || request.DeactivateSource)
+	deactivateSource := request.DeactivateSource && source.IsActive
+
+	err = s.txManager.ExecuteTx(ctx, func(tx transaction.Repository) error {
+		if err := requireRollback(tx, checkAdmins); err != nil {
+			return err
+		}
+
+		if len(response.TransferredRoles) > 0 {
+			if err := tx.AssignRolesToUser(ctx, target.ID, roleIDs); err != nil {
+				return fmt.Errorf("failed to assign roles to target user: %w", err)
+			}
+		}
+
+		if mode == models.RoleTransferMove && len(source.Roles) > 0 {
+			if err := tx.AssignRolesToUser(ctx, source.ID, []uuid.UUID{}); err != nil {
+				return fmt.Errorf("failed to remove roles from source user: %w", err)
+			}
+		}
+
+		if deactivateSource {
+			source.IsActive = false
+			source.UpdatedAt = time.Now()
+			if err := tx.UpdateUser(ctx, source); err != nil {
+				return fmt.Errorf("failed to deactivate source user: %w", err)
+			}
+		}
+
+		if checkAdmins {
+			return ensureAdminRemains(ctx, tx)
+		}
+
+		return nil
+	})
+
 	if err != nil {
 		return nil, err
 	}
 
-	// Convert to response format
-	permissionResponses := make([]models.PermissionResponse, len(permissions))
-	for i, permission := range permissions {
-		permissionResponses[i] = permission.ToResponse()
+	// Drop cached copies written outside the transaction
+	transaction.AfterCommit(ctx, func() {
+		s.userRepo.InvalidateUser(source.ID)
+		s.userRepo.InvalidateUser(target.ID)
+
+		if len(response.TransferredRoles) > 0 {
+			s.emitRolesChanged(ctx, target, roleIDs)
+		}
+		if mode == models.RoleTransferMove && len(source.Roles) > 0 {
+			s.emitRolesChanged(ctx, source, nil)
+		}
+		if deactivateSource {
+			s.emit(ctx, events.TypeUserDeactivated, source, nil)
+		}
+	})
+
+	return response, nil
+}
+
+// getPartyUser gets the source or target user of an operation on two users, naming the party when
+// it does not exist. Other lookup failures are returned as they are rather than as a missing user.
+func (s *UserService) getPartyUser(ctx context.Context, userID uuid.UUID, party string) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if errors.Is(err, models.ErrUserNotFound) {
+		return nil, fmt.Errorf("%s %w", party, ErrUserNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s user: %w", party, err)
+	}
+	return user, nil
+}
+
+// getUser loads the user an operation names. Only a user that does not exist is reported as
+// ErrUserNotFound; other failures, such as an unreachable database, are not.
+func (s *UserService) getUser(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if errors.Is(err, models.ErrUserNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, contextError(ctx, fmt.Errorf("failed to get user: %w", err))
+	}
+	return user, nil
+}
+
+// combineRoles returns the target's role IDs followed by those of the source's roles the target
+// does not hold yet, with the names of the roles gained and of those it already held. The acting
+// caller must be allowed to grant the roles gained.
+func (s *UserService) combineRoles(ctx context.Context, target, source *models.User) (roleIDs []uuid.UUID, gained, alreadyAssigned []string, err error) {
+	held := make(map[uuid.UUID]bool, len(target.Roles)+len(source.Roles))
+	roleIDs = make([]uuid.UUID, 0, len(target.Roles)+len(source.Roles))
+	gained = make([]string, 0)
+	alreadyAssigned = make([]string, 0)
+	for _, role := range target.Roles {
+		held[role.ID] = true
+		roleIDs = append(roleIDs, role.ID)
+	}
+	for _, role := range source.Roles {
+		if held[role.ID] {
+			alreadyAssigned = append(alreadyAssigned, role.Name)
+			continue
+		}
+		held[role.ID] = true
+		roleIDs = append(roleIDs, role.ID)
+		gained = append(gained, role.Name)
+	}
+
+	// The roles after the target's own are the ones it gains
+	if err := s.checkRoleGrant(ctx, roleIDs[len(target.Roles):]); err != nil {
+		return nil, nil, nil, err
 	}
 
-	return permissionResponses, nil
+	return roleIDs, gained, alreadyAssigned, nil
+}
+
+// MergeUsers folds a duplicate source user into the target user in one transaction. The target
+// gains the source's roles it does not hold yet and the API keys the source created; the source
+// is then soft-deleted and its tokens revoked.
+func (s *UserService) MergeUsers(ctx context.Context, actorID, targetID, sourceID string) (*models.UserMergeResponse, error) {
+	// Parse UUIDs
+	targetUserID, err := parseID("target user", targetID)
+	if err != nil {
+		return nil, err
+	}
+	sourceUserID, err := parseID("source user", sourceID)
+	if err != nil {
+		return nil, err
+	}
+	if sourceUserID == targetUserID {
+		return nil, fmt.Errorf("cannot merge a user into itself")
+	}
+
+	// Both users must exist and neither may already be merged away
+	target, err := s.getPartyUser(ctx, targetUserID, "target")
+	if err != nil {
+		return nil, err
+	}
+	source, err := s.getPartyUser(ctx, sourceUserID, "source")
+	if err != nil {
+		return nil, err
+	}
+	if target.DeletedAt != nil {
+		return nil, fmt.Errorf("target user has been deleted")
+	}
+	if source.DeletedAt != nil {
+		return nil, fmt.Errorf("source user has already been deleted")
+	}
+
+	roleIDs, merged, alreadyAssigned, err := s.combineRoles(ctx, target, source)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.UserMergeResponse{
+		SourceUserID:    source.ID,
+		TargetUserID:    target.ID,
+		MergedRoles:     merged,
+		AlreadyAssigned: alreadyAssigned,
+		SourceDeletedAt: time.Now(),
+	}
+
+	// The target may be inactive, so deleting an admin source may leave no admin behind
+	checkAdmins := s.guardsAdmin(source)
+
+	err = s.txManager.ExecuteTx(ctx, func(tx transaction.Repository) error {
+		// The merge spans several users and is checked once written, so it has to roll back as one
+		if err := transaction.RequireAtomic(tx); err != nil {
+			return err
+		}
+
+		if len(response.MergedRoles) > 0 {
+			if err := tx.AssignRolesToUser(ctx, target.ID, roleIDs); err != nil {
+				return fmt.Errorf("failed to assign roles to target user: %w", err)
+			}
+		}
+
+		if len(source.Roles) > 0 {
+			if err := tx.AssignRolesToUser(ctx, source.ID, []uuid.UUID{}); err != nil {
+				return fmt.Errorf("failed to remove roles from source user: %w", err)
+			}
+		}
+
+		reassigned, err := tx.ReassignAPIKeys(ctx, source.ID, target.ID)
+		if err != nil {
+			return fmt.Errorf("failed to reassign API keys: %w", err)
+		}
+		response.APIKeysReassigned = reassigned
+
+		if err := tx.SoftDeleteUser(ctx, source.ID, response.SourceDeletedAt); err != nil {
+			return fmt.Errorf("failed to delete source user: %w", err)
+		}
+		if err := tx.RevokeUserTokens(ctx, source.ID); err != nil {
+			return fmt.Errorf("failed to revoke source user tokens: %w", err)
+		}
+
+		if checkAdmins {
+			return ensureAdminRemains(ctx, tx)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop cached copies written outside the transaction
+	transaction.AfterCommit(ctx, func() {
+		s.userRepo.InvalidateUser(source.ID)
+		s.userRepo.InvalidateUser(target.ID)
+
+		if len(response.MergedRoles) > 0 {
+			s.emitRolesChanged(ctx, target, roleIDs)
+		}
+		s.emit(ctx, events.TypeUserDeleted, source, map[string]interface{}{"merged_into": target.ID.String()})
+	})
+
+	return response, nil
+}
+
+// BulkDeactivateUsers deactivates every active user matching the filter and revokes their tokens,
+// one transaction per batch. The acting user is never deactivated. A dry run only counts the users.
+func (s *UserService) BulkDeactivateUsers(ctx context.Context, actorID string, request models.BulkDeactivateRequest) (*models.BulkDeactivateResponse, error) {
+	// An empty filter would match everyone
+	if request.Filter.IsEmpty() {
+		return nil, fmt.Errorf("filter must set at least one of query, role_name, is_active, created_before, created_after, created_from or created_to")
+	}
+
+	userIDs, err := s.userRepo.GetUserIDsByFilter(ctx, request.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.BulkDeactivateResponse{
+		DryRun:  request.DryRun,
+		Matched: len(userIDs),
+	}
+
+	for start := 0; start < len(userIDs); start += bulkDeactivateBatchSize {
+		end := min(start+bulkDeactivateBatchSize, len(userIDs))
+
+		users, err := s.userRepo.GetByIDs(ctx, userIDs[start:end])
+		if err != nil {
+			return nil, err
+		}
+
+		batch := make([]*models.User, 0, len(users))
+		for _, user := range users {
+			if user.IsActive && user.ID.String() != actorID {
+				batch = append(batch, user)
+			}
+		}
+
+		if request.DryRun || len(batch) == 0 {
+			response.Deactivated += len(batch)
+			continue
+		}
+
+		checkAdmins := slices.ContainsFunc(batch, s.guardsAdmin)
+
+		batchIDs := make([]uuid.UUID, len(batch))
+		for i, user := range batch {
+			batchIDs[i] = user.ID
+		}
+
+		// The users above may come from the cache, so only is_active and updated_at are written and
+		// users deactivated meanwhile are left out
+		now := time.Now()
+		var deactivatedIDs []uuid.UUID
+		err = s.txManager.ExecuteTx(ctx, func(tx transaction.Repository) error {
+			if err := requireRollback(tx, checkAdmins); err != nil {
+				return err
+			}
+
+			ids, err := tx.DeactivateUsers(ctx, batchIDs, now)
+			if err != nil {
+				return err
+			}
+			deactivatedIDs = ids
+			for _, userID := range deactivatedIDs {
+				if err := tx.RevokeUserTokens(ctx, userID); err != nil {
+					return fmt.Errorf("failed to revoke tokens of user %s: %w", userID, err)
+				}
+			}
+			if checkAdmins {
+				return ensureAdminRemains(ctx, tx)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("stopped after deactivating %d users: %w", response.Deactivated, err)
+		}
+
+		batch = slices.DeleteFunc(batch, func(user *models.User) bool {
+			return !slices.Contains(deactivatedIDs, user.ID)
+		})
+		for _, user := range batch {
+			user.IsActive = false
+			user.UpdatedAt = now
+			transaction.AfterCommit(ctx, func() {
+				s.userRepo.InvalidateUser(user.ID)
+				s.emit(ctx, events.TypeUserDeactivated, user, nil)
+			})
+		}
+		response.Deactivated += len(batch)
+	}
+
+	return response, nil
+}
+
+// DeleteUser deletes a user: soft-deletes it when soft delete is enabled, otherwise purges it
+func (s *UserService) DeleteUser(ctx context.Context, id string) error {
+	// Parse UUID
+	userID, err := parseID("user", id)
+	if err != nil {
+		return err
+	}
+
+	if s.softDelete {
+		return s.softDeleteUser(ctx, userID)
+	}
+	return s.purgeUser(ctx, userID)
+}
+
+// PurgeUser deletes a user, soft-deleted or not, together with its role assignments and API keys
+func (s *UserService) PurgeUser(ctx context.Context, id string) error {
+	// Parse UUID
+	userID, err := parseID("user", id)
+	if err != nil {
+		return err
+	}
+
+	return s.purgeUser(ctx, userID)
+}
+
+// softDeleteUser deactivates a user and marks it deleted, revoking its tokens and API keys. Its
+// role assignments are kept, so restoring the user gives them back.
+func (s *UserService) softDeleteUser(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.DeletedAt != nil {
+		return fmt.Errorf("user has already been deleted")
+	}
+
+	now := time.Now()
+	err = s.txManager.ExecuteTx(ctx, func(tx transaction.Repository) error {
+		if err := requireRollback(tx, s.guardsAdmin(user)); err != nil {
+			return err
+		}
+
+		if err := tx.SoftDeleteUser(ctx, userID, now); err != nil {
+			return fmt.Errorf("failed to delete user: %w", err)
+		}
+		if err := tx.RevokeUserTokens(ctx, userID); err != nil {
+			return fmt.Errorf("failed to revoke user tokens: %w", err)
+		}
+		if _, err := tx.RevokeUserAPIKeys(ctx, userID, now); err != nil {
+			return fmt.Errorf("failed to revoke user API keys: %w", err)
+		}
+
+		if s.guardsAdmin(user) {
+			return ensureAdminRemains(ctx, tx)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	transaction.AfterCommit(ctx, func() {
+		s.userRepo.InvalidateDeletedUser(userID)
+		s.emit(ctx, events.TypeUserDeleted, user, nil)
+	})
+	return nil
+}
+
+// RestoreUser reactivates a soft-deleted user with the roles it held when deleted, or with the
+// configured restore roles instead. Its revoked API keys stay revoked.
+func (s *UserService) RestoreUser(ctx context.Context, id string) (*models.UserResponse, error) {
+	// Parse UUID
+	userID, err := parseID("user", id)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt == nil {
+		return nil, ErrUserNotDeleted
+	}
+
+	// The user gets back the roles it held, or the restore roles in their place
+	roleIDs := s.restoreRoleIDs(ctx)
+	granted := roleIDs
+	if granted == nil {
+		granted = make([]uuid.UUID, 0, len(user.Roles))
+		for _, role := range user.Roles {
+			granted = append(granted, role.ID)
+		}
+	}
+	if err := s.checkRoleGrant(ctx, granted); err != nil {
+		return nil, err
+	}
+
+	err = s.txManager.ExecuteTx(ctx, func(tx transaction.Repository) error {
+		if err := tx.RestoreUser(ctx, userID, time.Now()); err != nil {
+			return fmt.Errorf("failed to restore user: %w", err)
+		}
+
+		if roleIDs != nil {
+			if err := tx.AssignRolesToUser(ctx, userID, roleIDs); err != nil {
+				return fmt.Errorf("failed to assign restore roles: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop any cached copy written outside the transaction
+	transaction.AfterCommit(ctx, func() { s.userRepo.InvalidateUser(userID) })
+
+	return s.GetUserByID(ctx, id)
+}
+
+// RevokeUserTokens revokes every token issued to the user so far, returning when they were revoked
+func (s *UserService) RevokeUserTokens(ctx context.Context, id string) (time.Time, error) {
+	// Parse UUID
+	userID, err := parseID("user", id)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if _, err := s.getUser(ctx, userID); err != nil {
+		return time.Time{}, err
+	}
+
+	revokedAt := time.Now()
+	err = s.txManager.ExecuteTx(ctx, func(tx transaction.Repository) error {
+		if err := tx.RevokeUserTokens(ctx, userID); err != nil {
+			return fmt.Errorf("failed to revoke user tokens: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// Drop the cached revocation time so the next check sees the new one
+	transaction.AfterCommit(ctx, func() { s.userRepo.InvalidateUser(userID) })
+
+	return revokedAt, nil
+}
+
+// restoreRoleIDs resolves the configured restore roles, skipping those that do not exist. It returns
+// nil when none are configured, so restored users keep their prior roles.
+func (s *UserService) restoreRoleIDs(ctx context.Context) []uuid.UUID {
+	if len(s.restoreRoles) == 0 {
+		return nil
+	}
+
+	roleIDs := make([]uuid.UUID, 0, len(s.restoreRoles))
+	for _, name := range s.restoreRoles {
+		role, err := s.roleRepo.GetByName(ctx, name)
+		if err != nil || role == nil {
+			log.Warn().Err(err).Str("role", name).Msg("Skipping restore role that could not be found")
+			continue
+		}
+		roleIDs = append(roleIDs, role.ID)
+	}
+
+	return roleIDs
+}
+
+// purgeUser deletes a user with its role assignments and API keys
+func (s *UserService) purgeUser(ctx context.Context, userID uuid.UUID) error {
+	// The user is loaded first, so the deletion event describes it
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	// Deleting an admin recounts the admins in the same transaction
+	guarded := s.guardsAdmin(user)
+	err = s.txManager.ExecuteTx(ctx, func(tx transaction.Repository) error {
+		if err := requireRollback(tx, guarded); err != nil {
+			return err
+		}
+
+		if err := tx.DeleteUser(ctx, userID); err != nil {
+			return fmt.Errorf("failed to delete user: %w", err)
+		}
+		if guarded {
+			return ensureAdminRemains(ctx, tx)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	transaction.AfterCommit(ctx, func() {
+		s.userRepo.InvalidateDeletedUser(userID)
+		s.emit(ctx, events.TypeUserDeleted, user, nil)
+	})
+	return nil
+}
+
+// guardsAdmin reports whether taking the user out of the active admins has to leave another one
+func (s *UserService) guardsAdmin(user *models.User) bool {
+	return s.protectLastAdmin && user.IsActive && user.HasRole(adminRoleName)
+}
+
+// requireRollback refuses, before anything is written, a change that is checked once written: without
+// a transaction to roll back, a failed check would leave the change in place
+func requireRollback(tx transaction.Repository, checkedAfter bool) error {
+	if !checkedAfter {
+		return nil
+	}
+	return transaction.RequireAtomic(tx)
+}
+
+// ensureAdminRemains fails a transaction whose changes left no active user holding the admin role.
+// It runs after the changes, so the count inside the transaction already reflects them.
+func ensureAdminRemains(ctx context.Context, tx transaction.Repository) error {
+	admins, err := tx.CountActiveUsersWithRole(ctx, adminRoleName)
+	if err != nil {
+		return fmt.Errorf("failed to count admins: %w", err)
+	}
+	if admins == 0 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// SeedInitialAdmin creates the first admin, active and holding the admin role, unless an active admin
+// already exists. The admins are counted in the same transaction as the insert; when two instances
+// start together, the one whose insert loses on the unique username treats the admin as already
+// seeded. It reports whether the admin was created.
+func (s *UserService) SeedInitialAdmin(ctx context.Context, username, email, password string) (bool, error) {
+	role, err := s.roleRepo.GetByName(ctx, adminRoleName)
+	if err != nil {
+		return false, fmt.Errorf("failed to get admin role: %w", err)
+	}
+	if role == nil {
+		return false, fmt.Errorf("%s role not found", adminRoleName)
+	}
+
+	user := &models.User{
+		Username:  username,
+		Email:     email,
+		FirstName: "Admin",
+		LastName:  "User",
+		IsActive:  true,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := user.HashPassword(password, s.passwordPeppers...); err != nil {
+		return false, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	created := false
+	err = s.txManager.ExecuteTx(ctx, func(tx transaction.Repository) error {
+		admins, err := tx.CountActiveUsersWithRole(ctx, adminRoleName)
+		if err != nil {
+			return fmt.Errorf("failed to count admins: %w", err)
+		}
+		if admins > 0 {
+			return nil
+		}
+
+		if err := insertUser(ctx, tx, user, []uuid.UUID{role.ID}); err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	if errors.Is(err, models.ErrUsernameExists) {
+		log.Info().
+			Str("username", logger.MaskUsername(user.Username)).
+			Msg("Initial admin username already taken, assuming another instance seeded it")
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if created {
+		s.userRepo.InvalidateUser(user.ID)
+		log.Info().
+			Str("event", "user.created.initial_admin").
+			Str("user_id", user.ID.String()).
+			Str("username", logger.MaskUsername(user.Username)).
+			Msg("Initial admin created")
+	}
+	return created, nil
+}
+
+// GetUserPermissions retrieves all permissions for a user
+func (s *UserService) GetUserPermissions(ctx context.Context, id string) ([]models.PermissionResponse, error) {
+	// Parse UUID
+	userID, err := parseID("user", id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get permissions
+	permissions, err := s.userRepo.GetUserPermissions(ctx, userID)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return permissionsToResponses(permissions), nil
+}
+
+// GetUserPermissionsGrouped retrieves a user's permissions as a map of resource to actions
+func (s *UserService) GetUserPermissionsGrouped(ctx context.Context, id string) (map[string][]string, error) {
+	permissions, err := s.GetUserPermissions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	grouped := make(map[string][]string)
+	for _, permission := range permissions {
+		grouped[permission.Resource] = append(grouped[permission.Resource], permission.Action)
+	}
+
+	// Keep actions in a stable order
+	for resource := range grouped {
+		sort.Strings(grouped[resource])
+	}
+
+	return grouped, nil
+}
+
+// GetUserPolicy returns the user's complete access as one policy document. The permissions of the
+// user's roles are loaded in one batched query rather than per role, and each effective permission
+// lists the roles granting it.
+func (s *UserService) GetUserPolicy(ctx context.Context, id string) (*models.UserPolicy, error) {
+	userID, err := parseID("user", id)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	permissionsByRole := map[uuid.UUID][]models.Permission{}
+	if len(user.Roles) > 0 {
+		roleIDs := make([]uuid.UUID, len(user.Roles))
+		for i, role := range user.Roles {
+			roleIDs[i] = role.ID
+		}
+		permissionsByRole, err = s.roleRepo.GetPermissionsByRoleIDs(ctx, roleIDs)
+		if err != nil {
+			return nil, contextError(ctx, fmt.Errorf("failed to get role permissions: %w", err))
+		}
+	}
+
+	policy := &models.UserPolicy{
+		Version:              models.UserPolicyVersion,
+		UserID:               user.ID,
+		Username:             user.Username,
+		Roles:                make([]models.PolicyRole, 0, len(user.Roles)),
+		EffectivePermissions: []models.PolicyPermission{},
+	}
+
+	grantedBy := make(map[string][]string)
+	for _, role := range user.Roles {
+		policyRole := models.PolicyRole{ID: role.ID, Name: role.Name, Permissions: []string{}}
+		seen := make(map[string]bool)
+		for _, permission := range permissionsByRole[role.ID] {
+			name := permissionName(permission.Resource, permission.Action)
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			policyRole.Permissions = append(policyRole.Permissions, name)
+			grantedBy[name] = append(grantedBy[name], role.Name)
+		}
+		sort.Strings(policyRole.Permissions)
+		policy.Roles = append(policy.Roles, policyRole)
+	}
+	sort.Slice(policy.Roles, func(i, j int) bool { return policy.Roles[i].Name < policy.Roles[j].Name })
+
+	for name, roleNames := range grantedBy {
+		sort.Strings(roleNames)
+		policy.EffectivePermissions = append(policy.EffectivePermissions, models.PolicyPermission{Permission: name, GrantedBy: roleNames})
+	}
+	sort.Slice(policy.EffectivePermissions, func(i, j int) bool {
+		return policy.EffectivePermissions[i].Permission < policy.EffectivePermissions[j].Permission
+	})
+
+	return policy, nil
+}
+
+// PermissionGrantedBy returns the names of the user's roles granting the "resource:action" permission,
+// sorted, as listed in the user's policy
+func (s *UserService) PermissionGrantedBy(ctx context.Context, id, permission string) ([]string, error) {
+	policy, err := s.GetUserPolicy(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, effective := range policy.EffectivePermissions {
+		if effective.Permission == permission {
+			return effective.GrantedBy, nil
+		}
+	}
+	return []string{}, nil
 }
 
 // HasPermission checks if a user has a specific permission
 func (s *UserService) HasPermission(ctx context.Context, userID, resource, action string) (bool, error) {
 	// Parse UUID
-	id, err := uuid.Parse(userID)
+	id, err := parseID("user", userID)
 	if err != nil {
-		return false, fmt.Errorf("invalid user ID: %w", err)
+		return false, err
 	}
 
 	return s.userRepo.HasPermission(ctx, id, resource, action)
@@ -0,0 +1,1800 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chats/go-user-api/internal/events"
+	"github.com/chats/go-user-api/internal/mocks"
+	"github.com/chats/go-user-api/internal/models"
+	"github.com/chats/go-user-api/internal/repositories/transaction"
+	"github.com/chats/go-user-api/internal/services"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserService_GetUserPermissionsGrouped(t *testing.T) {
+	userID := uuid.New()
+
+	t.Run("Groups permissions by resource", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+
+		userService := services.NewUserService(mockUserRepo, mockRoleRepo, mockTxManager, services.DefaultUserServiceOptions())
+
+		permissions := []models.Permission{
+			{ID: uuid.New(), Name: "user:write", Resource: "user", Action: "write"},
+			{ID: uuid.New(), Name: "role:read", Resource: "role", Action: "read"},
+			{ID: uuid.New(), Name: "user:read", Resource: "user", Action: "read"},
+			{ID: uuid.New(), Name: "permission:delete", Resource: "permission", Action: "delete"},
+		}
+		mockUserRepo.On("GetUserPermissions", mock.Anything, userID).Return(permissions, nil)
+
+		grouped, err := userService.GetUserPermissionsGrouped(context.Background(), userID.String())
+
+		assert.NoError(t, err)
+		assert.Equal(t, map[string][]string{
+			"user":       {"read", "write"},
+			"role":       {"read"},
+			"permission": {"delete"},
+		}, grouped)
+		mockUserRepo.AssertExpectations(t)
+	})
+
+	t.Run("Invalid user ID", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+
+		userService := services.NewUserService(mockUserRepo, mockRoleRepo, mockTxManager, services.DefaultUserServiceOptions())
+
+		grouped, err := userService.GetUserPermissionsGrouped(context.Background(), "invalid-id")
+
+		assert.Error(t, err)
+		assert.Nil(t, grouped)
+		assert.Contains(t, err.Error(), "invalid user ID")
+	})
+}
+
+func TestUserService_RolePermissionsInResponses(t *testing.T) {
+	roleID := uuid.New()
+	permissions := []models.Permission{
+		{ID: uuid.New(), Name: "user:read", Resource: "user", Action: "read"},
+	}
+	newUser := func() *models.User {
+		return &models.User{
+			ID:       uuid.New(),
+			Username: "johndoe",
+			Roles:    []models.Role{{ID: roleID, Name: "viewer", Permissions: permissions}},
+		}
+	}
+
+	t.Run("List omits role permissions", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+
+		userService := services.NewUserService(mockUserRepo, mockRoleRepo, mockTxManager, services.DefaultUserServiceOptions())
+
+		mockUserRepo.On("GetAll", mock.Anything, 10, 0, models.SortOptions{}).Return([]*models.User{newUser()}, nil)
+		mockUserRepo.On("CountUsers", mock.Anything).Return(1, nil)
+
+		users, _, err := userService.GetAllUsers(context.Background(), 1, 10, models.SortOptions{})
+
+		assert.NoError(t, err)
+		assert.Len(t, users, 1)
+		assert.Equal(t, "viewer", users[0].Roles[0].Name)
+		assert.Nil(t, users[0].Roles[0].Permissions)
+		mockRoleRepo.AssertNotCalled(t, "GetRolePermissions", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Single GET includes role permissions", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+
+		userService := services.NewUserService(mockUserRepo, mockRoleRepo, mockTxManager, services.DefaultUserServiceOptions())
+
+		user := newUser()
+		user.Roles[0].Permissions = nil
+		mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		mockRoleRepo.On("GetRolePermissions", mock.Anything, roleID).Return(permissions, nil)
+
+		response, err := userService.GetUserByID(context.Background(), user.ID.String())
+
+		assert.NoError(t, err)
+		assert.Equal(t, permissions, response.Roles[0].Permissions)
+		mockRoleRepo.AssertExpectations(t)
+	})
+
+	t.Run("Single GET fails when permissions cannot be loaded", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+
+		userService := services.NewUserService(mockUserRepo, mockRoleRepo, mockTxManager, services.DefaultUserServiceOptions())
+
+		user := newUser()
+		mockUserRepo.On("GetByUsername", mock.Anything, user.Username).Return(user, nil)
+		mockRoleRepo.On("GetRolePermissions", mock.Anything, roleID).Return([]models.Permission(nil), errors.New("database error"))
+
+		response, err := userService.GetUserByUsername(context.Background(), user.Username)
+
+		assert.Error(t, err)
+		assert.Nil(t, response)
+	})
+}
+
+func TestUserService_GetAllUsers_DefaultSort(t *testing.T) {
+	defaultSort := models.SortOptions{Field: "username", Order: models.SortAsc}
+
+	tests := []struct {
+		name      string
+		requested models.SortOptions
+		want      models.SortOptions
+	}{
+		{name: "Applied when no sort is requested", requested: models.SortOptions{}, want: defaultSort},
+		{
+			name:      "Requested sort wins",
+			requested: models.SortOptions{Field: "email", Order: models.SortDesc},
+			want:      models.SortOptions{Field: "email", Order: models.SortDesc},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserRepo := new(mocks.MockUserRepository)
+			mockRoleRepo := new(mocks.MockRoleRepository)
+			mockTxManager := new(mocks.Manager[transaction.Repository])
+
+			opts := services.DefaultUserServiceOptions()
+			opts.DefaultSort = defaultSort
+			userService := services.NewUserService(mockUserRepo, mockRoleRepo, mockTxManager, opts)
+
+			mockUserRepo.On("GetAll", mock.Anything, 10, 0, tt.want).Return([]*models.User{}, nil)
+			mockUserRepo.On("CountUsers", mock.Anything).Return(0, nil)
+
+			_, _, err := userService.GetAllUsers(context.Background(), 1, 10, tt.requested)
+
+			assert.NoError(t, err)
+			mockUserRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUserService_GetAllUsersWithWarnings(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Username: "johndoe"}
+	danglingID := uuid.New()
+	partial := &models.PartialResultError{Warnings: []models.Warning{
+		{ID: user.ID.String(), Message: fmt.Sprintf("assigned role %s not found", danglingID)},
+	}}
+
+	setup := func(partialResults bool) (*services.UserService, *mocks.MockUserRepository) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		opts := services.DefaultUserServiceOptions()
+		opts.PartialResults = partialResults
+		userService := services.NewUserService(mockUserRepo, new(mocks.MockRoleRepository), new(mocks.Manager[transaction.Repository]), opts)
+
+		// The user's dangling role reference is left out of the page
+		mockUserRepo.On("GetAll", mock.Anything, 10, 0, models.SortOptions{}).Return([]*models.User{user}, partial)
+		mockUserRepo.On("CountUsers", mock.Anything).Return(1, nil)
+		return userService, mockUserRepo
+	}
+
+	t.Run("Lenient returns the user with a warning", func(t *testing.T) {
+		userService, _ := setup(true)
+
+		users, total, warnings, err := userService.GetAllUsersWithWarnings(context.Background(), 1, 10, models.SortOptions{})
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, total)
+		require.Len(t, users, 1)
+		assert.Equal(t, user.ID, users[0].ID)
+		assert.Equal(t, partial.Warnings, warnings)
+	})
+
+	t.Run("Strict fails", func(t *testing.T) {
+		userService, mockUserRepo := setup(false)
+
+		users, _, warnings, err := userService.GetAllUsersWithWarnings(context.Background(), 1, 10, models.SortOptions{})
+
+		assert.ErrorIs(t, err, partial)
+		assert.Contains(t, err.Error(), danglingID.String())
+		assert.Nil(t, users)
+		assert.Nil(t, warnings)
+		mockUserRepo.AssertNotCalled(t, "CountUsers", mock.Anything)
+	})
+}
+
+func TestUserService_CreateUserConcurrent(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockTxManager := new(mocks.Manager[transaction.Repository])
+	mockTxRepo := new(mocks.MockTxRepository)
+	userService := services.NewUserService(mockUserRepo, new(mocks.MockRoleRepository), mockTxManager, services.DefaultUserServiceOptions())
+
+	// Both creates look the username up before either inserts, so both pass the advisory check
+	var checked sync.WaitGroup
+	checked.Add(2)
+	mockUserRepo.On("GetByUsername", mock.Anything, "johndoe").Return(nil, errors.New("user not found")).Run(func(mock.Arguments) {
+		checked.Done()
+		checked.Wait()
+	})
+	mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).
+		Return(func(ctx context.Context, fn func(transaction.Repository) error) error { return fn(mockTxRepo) })
+
+	// The unique index admits the first insert and rejects the second
+	mockTxRepo.On("CreateUser", mock.Anything, mock.AnythingOfType("*models.User")).Return(nil).Once()
+	mockTxRepo.On("CreateUser", mock.Anything, mock.AnythingOfType("*models.User")).Return(models.ErrUsernameExists).Once()
+	mockUserRepo.On("InvalidateUser", mock.Anything).Return()
+	mockUserRepo.On("GetByID", mock.Anything, mock.Anything).Return(nil, errors.New("user not found"))
+
+	errs := make([]error, 2)
+	var done sync.WaitGroup
+	for i := range errs {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			_, errs[i] = userService.CreateUser(context.Background(), models.UserCreateRequest{
+				Username: "johndoe",
+				Email:    fmt.Sprintf("john%d@example.com", i),
+				Password: "password123",
+			})
+		}()
+	}
+	done.Wait()
+
+	var succeeded, conflicted int
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, models.ErrUsernameExists):
+			// The conflict is reported as is, not wrapped in a raw database error
+			assert.EqualError(t, err, "username already exists")
+			conflicted++
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	assert.Equal(t, 1, succeeded)
+	assert.Equal(t, 1, conflicted)
+	mockTxRepo.AssertExpectations(t)
+}
+
+func TestUserService_CreateUserRoleIDs(t *testing.T) {
+	setup := func(opts services.UserServiceOptions) (*services.UserService, *mocks.MockUserRepository, *mocks.Manager[transaction.Repository]) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockUserRepo.On("GetByUsername", mock.Anything, "johndoe").Return(nil, errors.New("user not found"))
+
+		return services.NewUserService(mockUserRepo, new(mocks.MockRoleRepository), mockTxManager, opts), mockUserRepo, mockTxManager
+	}
+
+	request := func(roleIDs ...string) models.UserCreateRequest {
+		return models.UserCreateRequest{
+			Username: "johndoe",
+			Email:    "john@example.com",
+			Password: "password123",
+			RoleIDs:  roleIDs,
+		}
+	}
+
+	t.Run("Duplicates collapsed", func(t *testing.T) {
+		userService, mockUserRepo, mockTxManager := setup(services.DefaultUserServiceOptions())
+		mockTxRepo := new(mocks.MockTxRepository)
+		adminID, editorID := uuid.New(), uuid.New()
+
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(nil).Run(func(args mock.Arguments) {
+			txFunc := args.Get(1).(func(transaction.Repository) error)
+			txFunc(mockTxRepo)
+		})
+		mockTxRepo.On("CreateUser", mock.Anything, mock.AnythingOfType("*models.User")).Return(nil)
+		mockTxRepo.On("AssignRolesToUser", mock.Anything, mock.Anything, []uuid.UUID{adminID, editorID}).Return(nil)
+		mockUserRepo.On("InvalidateUser", mock.Anything).Return()
+		mockUserRepo.On("GetByID", mock.Anything, mock.Anything).Return(nil, errors.New("user not found"))
+
+		response, err := userService.CreateUser(context.Background(), request(
+			adminID.String(), editorID.String(), adminID.String(), editorID.String(),
+		))
+
+		assert.NoError(t, err)
+		assert.NotNil(t, response)
+		mockTxRepo.AssertExpectations(t)
+	})
+
+	t.Run("Over the limit", func(t *testing.T) {
+		opts := services.DefaultUserServiceOptions()
+		opts.IDListLimit = 2
+		userService, _, mockTxManager := setup(opts)
+
+		response, err := userService.CreateUser(context.Background(), request(
+			uuid.New().String(), uuid.New().String(), uuid.New().String(),
+		))
+
+		assert.Error(t, err)
+		assert.Nil(t, response)
+		assert.Contains(t, err.Error(), "too many role IDs: got 3, at most 2 allowed")
+		mockTxManager.AssertNotCalled(t, "ExecuteTx", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Every invalid ID reported", func(t *testing.T) {
+		userService, _, mockTxManager := setup(services.DefaultUserServiceOptions())
+
+		response, err := userService.CreateUser(context.Background(), request(
+			"not-a-uuid", uuid.New().String(), "12345",
+		))
+
+		assert.Error(t, err)
+		assert.Nil(t, response)
+		assert.Equal(t, `invalid role IDs: "not-a-uuid", "12345"`, err.Error())
+		mockTxManager.AssertNotCalled(t, "ExecuteTx", mock.Anything, mock.Anything)
+	})
+}
+
+func TestUserService_CreateUserDomainRoles(t *testing.T) {
+	employee := &models.Role{ID: uuid.New(), Name: "employee"}
+	staff := &models.Role{ID: uuid.New(), Name: "staff"}
+
+	// create runs CreateUser for email and returns the role IDs assigned in the transaction
+	create := func(t *testing.T, email string, roleIDs ...string) []uuid.UUID {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxRepo := new(mocks.MockTxRepository)
+
+		opts := services.DefaultUserServiceOptions()
+		opts.DomainRoles = map[string][]string{"company.com": {"employee", "staff", "contractor"}}
+		userService := services.NewUserService(mockUserRepo, mockRoleRepo, mockTxManager, opts)
+
+		mockUserRepo.On("GetByUsername", mock.Anything, "johndoe").Return(nil, errors.New("user not found"))
+		mockRoleRepo.On("GetByName", mock.Anything, "employee").Return(employee, nil)
+		mockRoleRepo.On("GetByName", mock.Anything, "staff").Return(staff, nil)
+		mockRoleRepo.On("GetByName", mock.Anything, "contractor").Return(nil, errors.New("role not found"))
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(nil).Run(func(args mock.Arguments) {
+			txFunc := args.Get(1).(func(transaction.Repository) error)
+			txFunc(mockTxRepo)
+		})
+		mockTxRepo.On("CreateUser", mock.Anything, mock.AnythingOfType("*models.User")).Return(nil)
+		mockTxRepo.On("AssignRolesToUser", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		mockUserRepo.On("InvalidateUser", mock.Anything).Return()
+		mockUserRepo.On("GetByID", mock.Anything, mock.Anything).Return(nil, errors.New("user not found"))
+
+		_, err := userService.CreateUser(context.Background(), models.UserCreateRequest{
+			Username: "johndoe",
+			Email:    email,
+			Password: "password123",
+			RoleIDs:  roleIDs,
+		})
+		require.NoError(t, err)
+
+		for _, call := range mockTxRepo.Calls {
+			if call.Method == "AssignRolesToUser" {
+				return call.Arguments.Get(2).([]uuid.UUID)
+			}
+		}
+		return nil
+	}
+
+	t.Run("Matching domain", func(t *testing.T) {
+		// The unknown contractor role is skipped
+		assert.Equal(t, []uuid.UUID{employee.ID, staff.ID}, create(t, "John@Company.com"))
+	})
+
+	t.Run("Other domain", func(t *testing.T) {
+		assert.Nil(t, create(t, "john@example.com"))
+	})
+
+	t.Run("Combined with explicit roles", func(t *testing.T) {
+		adminID := uuid.New()
+
+		// staff is given explicitly and by the domain, and assigned once
+		roleIDs := create(t, "john@company.com", adminID.String(), staff.ID.String())
+
+		assert.Equal(t, []uuid.UUID{adminID, staff.ID, employee.ID}, roleIDs)
+	})
+}
+
+func TestUserService_CreateUserReadAfterWrite(t *testing.T) {
+	// create runs CreateUser with the first read of the new user missing it, as a lagging replica would
+	create := func(t *testing.T, retries int) (*models.UserResponse, *mocks.MockUserRepository) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxRepo := new(mocks.MockTxRepository)
+
+		opts := services.DefaultUserServiceOptions()
+		opts.ReadAfterWriteRetries = retries
+		opts.ReadAfterWriteRetryDelay = time.Millisecond
+		userService := services.NewUserService(mockUserRepo, new(mocks.MockRoleRepository), mockTxManager, opts)
+
+		mockUserRepo.On("GetByUsername", mock.Anything, "johndoe").Return(nil, errors.New("user not found"))
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(nil).Run(func(args mock.Arguments) {
+			args.Get(1).(func(transaction.Repository) error)(mockTxRepo)
+		})
+		mockTxRepo.On("CreateUser", mock.Anything, mock.AnythingOfType("*models.User")).Return(nil).Run(func(args mock.Arguments) {
+			created := *args.Get(1).(*models.User)
+			created.Roles = []models.Role{{ID: uuid.New(), Name: "viewer"}}
+			mockUserRepo.On("GetByID", mock.Anything, created.ID).Return(nil, errors.New("user not found")).Once()
+			mockUserRepo.On("GetByID", mock.Anything, created.ID).Return(&created, nil)
+		})
+		mockUserRepo.On("InvalidateUser", mock.Anything).Return()
+
+		response, err := userService.CreateUser(context.Background(), models.UserCreateRequest{
+			Username: "johndoe",
+			Email:    "john@example.com",
+			Password: "password123",
+		})
+		require.NoError(t, err)
+
+		return response, mockUserRepo
+	}
+
+	t.Run("Miss retried until the user is read", func(t *testing.T) {
+		response, mockUserRepo := create(t, 2)
+
+		assert.Equal(t, "johndoe", response.Username)
+		require.Len(t, response.Roles, 1)
+		assert.Equal(t, "viewer", response.Roles[0].Name)
+		mockUserRepo.AssertNumberOfCalls(t, "GetByID", 2)
+	})
+
+	t.Run("Without retries the user is returned without roles", func(t *testing.T) {
+		response, mockUserRepo := create(t, 0)
+
+		assert.Equal(t, "johndoe", response.Username)
+		assert.Empty(t, response.Roles)
+		mockUserRepo.AssertNumberOfCalls(t, "GetByID", 1)
+	})
+}
+
+func TestUserService_CreateUserDefaultActive(t *testing.T) {
+	actorID := uuid.New()
+	active, inactive := true, false
+
+	// create runs CreateUser as the acting user, who only holds user:read, and returns the user
+	// saved in the transaction
+	create := func(t *testing.T, defaultActive bool, requested *bool) (*models.User, error) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxRepo := new(mocks.MockTxRepository)
+
+		opts := services.DefaultUserServiceOptions()
+		opts.DefaultActive = defaultActive
+		opts.ActiveOverridePermission = "user:approve"
+		userService := services.NewUserService(mockUserRepo, mockRoleRepo, mockTxManager, opts)
+
+		mockUserRepo.On("GetByUsername", mock.Anything, "johndoe").Return(nil, errors.New("user not found"))
+		mockUserRepo.On("GetUserPermissions", mock.Anything, actorID).Return([]models.Permission{{Resource: "user", Action: "read"}}, nil)
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(nil).Run(func(args mock.Arguments) {
+			args.Get(1).(func(transaction.Repository) error)(mockTxRepo)
+		})
+		mockTxRepo.On("CreateUser", mock.Anything, mock.AnythingOfType("*models.User")).Return(nil)
+		mockUserRepo.On("InvalidateUser", mock.Anything).Return()
+		mockUserRepo.On("GetByID", mock.Anything, mock.Anything).Return(nil, errors.New("user not found"))
+
+		ctx := services.WithActor(context.Background(), services.Actor{UserID: actorID.String()})
+		_, err := userService.CreateUser(ctx, models.UserCreateRequest{
+			Username: "johndoe",
+			Email:    "john@example.com",
+			Password: "password123",
+			IsActive: requested,
+		})
+		for _, call := range mockTxRepo.Calls {
+			if call.Method == "CreateUser" {
+				return call.Arguments.Get(1).(*models.User), err
+			}
+		}
+		return nil, err
+	}
+
+	t.Run("Active by default", func(t *testing.T) {
+		user, err := create(t, true, nil)
+
+		require.NoError(t, err)
+		assert.True(t, user.IsActive)
+	})
+
+	t.Run("Inactive by default", func(t *testing.T) {
+		user, err := create(t, false, nil)
+
+		require.NoError(t, err)
+		assert.False(t, user.IsActive)
+	})
+
+	t.Run("Override needs the permission", func(t *testing.T) {
+		user, err := create(t, false, &active)
+
+		assert.ErrorIs(t, err, services.ErrPrivilegeEscalation)
+		assert.ErrorContains(t, err, "user:approve")
+		assert.Nil(t, user)
+	})
+
+	t.Run("Override by a permitted API key", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxRepo := new(mocks.MockTxRepository)
+
+		opts := services.DefaultUserServiceOptions()
+		opts.ActiveOverridePermission = "user:approve"
+		userService := services.NewUserService(mockUserRepo, new(mocks.MockRoleRepository), mockTxManager, opts)
+
+		mockUserRepo.On("GetByUsername", mock.Anything, "johndoe").Return(nil, errors.New("user not found"))
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(nil).Run(func(args mock.Arguments) {
+			args.Get(1).(func(transaction.Repository) error)(mockTxRepo)
+		})
+		mockTxRepo.On("CreateUser", mock.Anything, mock.MatchedBy(func(user *models.User) bool { return !user.IsActive })).Return(nil)
+		mockUserRepo.On("InvalidateUser", mock.Anything).Return()
+		mockUserRepo.On("GetByID", mock.Anything, mock.Anything).Return(nil, errors.New("user not found"))
+
+		ctx := services.WithActor(context.Background(), services.Actor{APIKey: &models.APIKey{Permissions: []string{"user:write", "user:approve"}}})
+		response, err := userService.CreateUser(ctx, models.UserCreateRequest{
+			Username: "johndoe",
+			Email:    "john@example.com",
+			Password: "password123",
+			IsActive: &inactive,
+		})
+
+		require.NoError(t, err)
+		assert.False(t, response.IsActive)
+		mockTxRepo.AssertExpectations(t)
+	})
+}
+
+func TestUserService_TransferRoles(t *testing.T) {
+	admin := models.Role{ID: uuid.New(), Name: "admin"}
+	editor := models.Role{ID: uuid.New(), Name: "editor"}
+	viewer := models.Role{ID: uuid.New(), Name: "viewer"}
+
+	setup := func(source, target *models.User) (*services.UserService, *mocks.MockUserRepository, *mocks.MockTxRepository) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxRepo := new(mocks.MockTxRepository)
+
+		mockUserRepo.On("GetByID", mock.Anything, source.ID).Return(source, nil)
+		mockUserRepo.On("GetByID", mock.Anything, target.ID).Return(target, nil)
+		mockUserRepo.On("InvalidateUser", mock.Anything).Return()
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(nil).Run(func(args mock.Arguments) {
+			txFunc := args.Get(1).(func(transaction.Repository) error)
+			txFunc(mockTxRepo)
+		})
+
+		return services.NewUserService(mockUserRepo, new(mocks.MockRoleRepository), mockTxManager, services.DefaultUserServiceOptions()), mockUserRepo, mockTxRepo
+	}
+
+	t.Run("Copy mode", func(t *testing.T) {
+		source := &models.User{ID: uuid.New(), IsActive: true, Roles: []models.Role{admin, editor}}
+		target := &models.User{ID: uuid.New(), IsActive: true}
+		userService, mockUserRepo, mockTxRepo := setup(source, target)
+
+		mockTxRepo.On("AssignRolesToUser", mock.Anything, target.ID, []uuid.UUID{admin.ID, editor.ID}).Return(nil)
+
+		result, err := userService.TransferRoles(context.Background(), source.ID.String(), target.ID.String(), models.RoleTransferRequest{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.RoleTransferCopy, result.Mode)
+		assert.Equal(t, []string{"admin", "editor"}, result.TransferredRoles)
+		assert.Empty(t, result.AlreadyAssigned)
+		assert.False(t, result.SourceDeactivated)
+		mockTxRepo.AssertExpectations(t)
+		// The source keeps its roles
+		mockTxRepo.AssertNotCalled(t, "AssignRolesToUser", mock.Anything, source.ID, mock.Anything)
+		mockTxRepo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything)
+		mockUserRepo.AssertCalled(t, "InvalidateUser", source.ID)
+		mockUserRepo.AssertCalled(t, "InvalidateUser", target.ID)
+	})
+
+	t.Run("Move mode deactivating the source", func(t *testing.T) {
+		source := &models.User{ID: uuid.New(), IsActive: true, Roles: []models.Role{admin}}
+		target := &models.User{ID: uuid.New(), IsActive: true, Roles: []models.Role{viewer}}
+		userService, _, mockTxRepo := setup(source, target)
+
+		mockTxRepo.On("AssignRolesToUser", mock.Anything, target.ID, []uuid.UUID{viewer.ID, admin.ID}).Return(nil)
+		mockTxRepo.On("AssignRolesToUser", mock.Anything, source.ID, []uuid.UUID{}).Return(nil)
+		mockTxRepo.On("UpdateUser", mock.Anything, mock.MatchedBy(func(user *models.User) bool {
+			return user.ID == source.ID && !user.IsActive
+		})).Return(nil)
+		// The target now holds the admin role
+		mockTxRepo.On("CountActiveUsersWithRole", mock.Anything, "admin").Return(1, nil)
+
+		result, err := userService.TransferRoles(context.Background(), source.ID.String(), target.ID.String(), models.RoleTransferRequest{
+			Mode:             models.RoleTransferMove,
+			DeactivateSource: true,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"admin"}, result.TransferredRoles)
+		assert.True(t, result.SourceDeactivated)
+		mockTxRepo.AssertExpectations(t)
+	})
+
+	t.Run("Target already shares some roles", func(t *testing.T) {
+		source := &models.User{ID: uuid.New(), IsActive: true, Roles: []models.Role{admin, editor}}
+		target := &models.User{ID: uuid.New(), IsActive: true, Roles: []models.Role{editor, viewer}}
+		userService, _, mockTxRepo := setup(source, target)
+
+		// editor is assigned once
+		mockTxRepo.On("AssignRolesToUser", mock.Anything, target.ID, []uuid.UUID{editor.ID, viewer.ID, admin.ID}).Return(nil)
+
+		result, err := userService.TransferRoles(context.Background(), source.ID.String(), target.ID.String(), models.RoleTransferRequest{
+			Mode: models.RoleTransferCopy,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"admin"}, result.TransferredRoles)
+		assert.Equal(t, []string{"editor"}, result.AlreadyAssigned)
+		mockTxRepo.AssertExpectations(t)
+	})
+
+	t.Run("Target already holds every role", func(t *testing.T) {
+		source := &models.User{ID: uuid.New(), IsActive: true, Roles: []models.Role{editor}}
+		target := &models.User{ID: uuid.New(), IsActive: true, Roles: []models.Role{editor}}
+		userService, _, mockTxRepo := setup(source, target)
+
+		result, err := userService.TransferRoles(context.Background(), source.ID.String(), target.ID.String(), models.RoleTransferRequest{})
+
+		assert.NoError(t, err)
+		assert.Empty(t, result.TransferredRoles)
+		assert.Equal(t, []string{"editor"}, result.AlreadyAssigned)
+		mockTxRepo.AssertNotCalled(t, "AssignRolesToUser", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Target not found", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		source := &models.User{ID: uuid.New(), Roles: []models.Role{admin}}
+		targetID := uuid.New()
+
+		mockUserRepo.On("GetByID", mock.Anything, source.ID).Return(source, nil)
+		mockUserRepo.On("GetByID", mock.Anything, targetID).Return(nil, models.ErrUserNotFound)
+		userService := services.NewUserService(mockUserRepo, new(mocks.MockRoleRepository), mockTxManager, services.DefaultUserServiceOptions())
+
+		result, err := userService.TransferRoles(context.Background(), source.ID.String(), targetID.String(), models.RoleTransferRequest{})
+
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, services.ErrUserNotFound)
+		assert.Equal(t, "target user not found", err.Error())
+		mockTxManager.AssertNotCalled(t, "ExecuteTx", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Lookup failure is not reported as not found", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		sourceID := uuid.New()
+		mockUserRepo.On("GetByID", mock.Anything, sourceID).Return(nil, errors.New("connection refused"))
+		userService := services.NewUserService(mockUserRepo, new(mocks.MockRoleRepository), new(mocks.Manager[transaction.Repository]), services.DefaultUserServiceOptions())
+
+		result, err := userService.TransferRoles(context.Background(), sourceID.String(), uuid.New().String(), models.RoleTransferRequest{})
+
+		assert.Nil(t, result)
+		assert.NotErrorIs(t, err, services.ErrUserNotFound)
+		assert.EqualError(t, err, "failed to get source user: connection refused")
+	})
+
+	t.Run("Invalid requests", func(t *testing.T) {
+		userService := services.NewUserService(new(mocks.MockUserRepository), new(mocks.MockRoleRepository), new(mocks.Manager[transaction.Repository]), services.DefaultUserServiceOptions())
+		sourceID := uuid.New().String()
+
+		_, err := userService.TransferRoles(context.Background(), sourceID, uuid.New().String(), models.RoleTransferRequest{Mode: "swap"})
+		assert.ErrorContains(t, err, `invalid transfer mode "swap"`)
+
+		_, err = userService.TransferRoles(context.Background(), sourceID, "invalid-id", models.RoleTransferRequest{})
+		assert.ErrorContains(t, err, "invalid target user ID")
+
+		_, err = userService.TransferRoles(context.Background(), sourceID, sourceID, models.RoleTransferRequest{})
+		assert.ErrorContains(t, err, "source and target users must differ")
+	})
+}
+
+func TestUserService_MergeUsers(t *testing.T) {
+	admin := models.Role{ID: uuid.New(), Name: "admin"}
+	editor := models.Role{ID: uuid.New(), Name: "editor"}
+	viewer := models.Role{ID: uuid.New(), Name: "viewer"}
+	actorID := uuid.New().String()
+
+	setup := func(source, target *models.User) (*services.UserService, *mocks.MockUserRepository, *mocks.MockTxRepository) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxRepo := new(mocks.MockTxRepository)
+
+		mockUserRepo.On("GetByID", mock.Anything, source.ID).Return(source, nil)
+		mockUserRepo.On("GetByID", mock.Anything, target.ID).Return(target, nil)
+		mockUserRepo.On("InvalidateUser", mock.Anything).Return()
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(nil).Run(func(args mock.Arguments) {
+			txFunc := args.Get(1).(func(transaction.Repository) error)
+			txFunc(mockTxRepo)
+		})
+
+		// Every merge moves the API keys, soft-deletes the source and revokes its tokens
+		mockTxRepo.On("ReassignAPIKeys", mock.Anything, source.ID, target.ID).Return(2, nil)
+		mockTxRepo.On("SoftDeleteUser", mock.Anything, source.ID, mock.AnythingOfType("time.Time")).Return(nil)
+		mockTxRepo.On("RevokeUserTokens", mock.Anything, source.ID).Return(nil)
+		// An admin source hands the admin role to the active target
+		mockTxRepo.On("CountActiveUsersWithRole", mock.Anything, "admin").Return(1, nil).Maybe()
+
+		return services.NewUserService(mockUserRepo, new(mocks.MockRoleRepository), mockTxManager, services.DefaultUserServiceOptions()), mockUserRepo, mockTxRepo
+	}
+
+	t.Run("Disjoint roles", func(t *testing.T) {
+		source := &models.User{ID: uuid.New(), IsActive: true, Roles: []models.Role{admin}}
+		target := &models.User{ID: uuid.New(), IsActive: true, Roles: []models.Role{viewer}}
+		userService, mockUserRepo, mockTxRepo := setup(source, target)
+
+		mockTxRepo.On("AssignRolesToUser", mock.Anything, target.ID, []uuid.UUID{viewer.ID, admin.ID}).Return(nil)
+		mockTxRepo.On("AssignRolesToUser", mock.Anything, source.ID, []uuid.UUID{}).Return(nil)
+
+		result, err := userService.MergeUsers(context.Background(), actorID, target.ID.String(), source.ID.String())
+
+		assert.NoError(t, err)
+		assert.Equal(t, source.ID, result.SourceUserID)
+		assert.Equal(t, target.ID, result.TargetUserID)
+		assert.Equal(t, []string{"admin"}, result.MergedRoles)
+		assert.Empty(t, result.AlreadyAssigned)
+		assert.Equal(t, 2, result.APIKeysReassigned)
+		assert.False(t, result.SourceDeletedAt.IsZero())
+		mockTxRepo.AssertExpectations(t)
+		mockUserRepo.AssertCalled(t, "InvalidateUser", source.ID)
+		mockUserRepo.AssertCalled(t, "InvalidateUser", target.ID)
+	})
+
+	t.Run("Overlapping roles", func(t *testing.T) {
+		source := &models.User{ID: uuid.New(), IsActive: true, Roles: []models.Role{admin, editor}}
+		target := &models.User{ID: uuid.New(), IsActive: true, Roles: []models.Role{editor, viewer}}
+		userService, _, mockTxRepo := setup(source, target)
+
+		// editor is assigned once
+		mockTxRepo.On("AssignRolesToUser", mock.Anything, target.ID, []uuid.UUID{editor.ID, viewer.ID, admin.ID}).Return(nil)
+		mockTxRepo.On("AssignRolesToUser", mock.Anything, source.ID, []uuid.UUID{}).Return(nil)
+
+		result, err := userService.MergeUsers(context.Background(), actorID, target.ID.String(), source.ID.String())
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"admin"}, result.MergedRoles)
+		assert.Equal(t, []string{"editor"}, result.AlreadyAssigned)
+		mockTxRepo.AssertExpectations(t)
+	})
+
+	t.Run("Target already holds every role", func(t *testing.T) {
+		source := &models.User{ID: uuid.New(), IsActive: true, Roles: []models.Role{editor}}
+		target := &models.User{ID: uuid.New(), IsActive: true, Roles: []models.Role{editor}}
+		userService, _, mockTxRepo := setup(source, target)
+
+		mockTxRepo.On("AssignRolesToUser", mock.Anything, source.ID, []uuid.UUID{}).Return(nil)
+
+		result, err := userService.MergeUsers(context.Background(), actorID, target.ID.String(), source.ID.String())
+
+		assert.NoError(t, err)
+		assert.Empty(t, result.MergedRoles)
+		assert.Equal(t, []string{"editor"}, result.AlreadyAssigned)
+		mockTxRepo.AssertNotCalled(t, "AssignRolesToUser", mock.Anything, target.ID, mock.Anything)
+		mockTxRepo.AssertExpectations(t)
+	})
+
+	t.Run("Source is soft-deleted afterward", func(t *testing.T) {
+		source := &models.User{ID: uuid.New(), IsActive: true, Roles: []models.Role{admin}}
+		target := &models.User{ID: uuid.New(), IsActive: true}
+		userService, _, mockTxRepo := setup(source, target)
+
+		mockTxRepo.On("AssignRolesToUser", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+		result, err := userService.MergeUsers(context.Background(), actorID, target.ID.String(), source.ID.String())
+		assert.NoError(t, err)
+
+		// The source is soft-deleted at the reported time, never hard-deleted
+		mockTxRepo.AssertCalled(t, "SoftDeleteUser", mock.Anything, source.ID, result.SourceDeletedAt)
+		mockTxRepo.AssertNotCalled(t, "SoftDeleteUser", mock.Anything, target.ID, mock.Anything)
+
+		// Once deleted the source cannot be merged again
+		deletedAt := result.SourceDeletedAt
+		source.DeletedAt = &deletedAt
+		again, err := userService.MergeUsers(context.Background(), actorID, target.ID.String(), source.ID.String())
+
+		assert.Error(t, err)
+		assert.Nil(t, again)
+		assert.Contains(t, err.Error(), "source user has already been deleted")
+	})
+
+	t.Run("Failure rolls back", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxRepo := new(mocks.MockTxRepository)
+		source := &models.User{ID: uuid.New(), IsActive: true}
+		target := &models.User{ID: uuid.New(), IsActive: true}
+
+		mockUserRepo.On("GetByID", mock.Anything, source.ID).Return(source, nil)
+		mockUserRepo.On("GetByID", mock.Anything, target.ID).Return(target, nil)
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(errors.New("rolled back")).Run(func(args mock.Arguments) {
+			txFunc := args.Get(1).(func(transaction.Repository) error)
+			txFunc(mockTxRepo)
+		})
+		mockTxRepo.On("ReassignAPIKeys", mock.Anything, source.ID, target.ID).Return(0, nil)
+		mockTxRepo.On("SoftDeleteUser", mock.Anything, source.ID, mock.Anything).Return(errors.New("user not found or already deleted"))
+		userService := services.NewUserService(mockUserRepo, new(mocks.MockRoleRepository), mockTxManager, services.DefaultUserServiceOptions())
+
+		result, err := userService.MergeUsers(context.Background(), actorID, target.ID.String(), source.ID.String())
+
+		assert.Error(t, err)
+		assert.Nil(t, result)
+		mockTxRepo.AssertNotCalled(t, "RevokeUserTokens", mock.Anything, mock.Anything)
+		mockUserRepo.AssertNotCalled(t, "InvalidateUser", mock.Anything)
+	})
+
+	t.Run("Merging a user into itself", func(t *testing.T) {
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		userService := services.NewUserService(new(mocks.MockUserRepository), new(mocks.MockRoleRepository), mockTxManager, services.DefaultUserServiceOptions())
+		id := uuid.New().String()
+
+		result, err := userService.MergeUsers(context.Background(), actorID, id, id)
+
+		assert.Error(t, err)
+		assert.Nil(t, result)
+		mockTxManager.AssertNotCalled(t, "ExecuteTx", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Source not found", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		target := &models.User{ID: uuid.New()}
+		sourceID := uuid.New()
+
+		mockUserRepo.On("GetByID", mock.Anything, target.ID).Return(target, nil)
+		mockUserRepo.On("GetByID", mock.Anything, sourceID).Return(nil, models.ErrUserNotFound)
+		userService := services.NewUserService(mockUserRepo, new(mocks.MockRoleRepository), new(mocks.Manager[transaction.Repository]), services.DefaultUserServiceOptions())
+
+		result, err := userService.MergeUsers(context.Background(), actorID, target.ID.String(), sourceID.String())
+
+		assert.ErrorIs(t, err, services.ErrUserNotFound)
+		assert.Nil(t, result)
+	})
+}
+
+func TestUserService_RevokeUserTokens(t *testing.T) {
+	userID := uuid.New()
+
+	t.Run("Revokes the tokens and drops the cached revocation time", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxRepo := new(mocks.MockTxRepository)
+		userService := services.NewUserService(mockUserRepo, new(mocks.MockRoleRepository), mockTxManager, services.DefaultUserServiceOptions())
+
+		mockUserRepo.On("GetByID", mock.Anything, userID).Return(&models.User{ID: userID}, nil)
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(nil).Run(func(args mock.Arguments) {
+			args.Get(1).(func(transaction.Repository) error)(mockTxRepo)
+		})
+		mockTxRepo.On("RevokeUserTokens", mock.Anything, userID).Return(nil)
+		mockUserRepo.On("InvalidateUser", userID).Return()
+
+		before := time.Now()
+		revokedAt, err := userService.RevokeUserTokens(context.Background(), userID.String())
+
+		require.NoError(t, err)
+		assert.False(t, revokedAt.Before(before))
+		mockTxRepo.AssertCalled(t, "RevokeUserTokens", mock.Anything, userID)
+		mockUserRepo.AssertCalled(t, "InvalidateUser", userID)
+	})
+
+	t.Run("Unknown user", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		userService := services.NewUserService(mockUserRepo, new(mocks.MockRoleRepository), mockTxManager, services.DefaultUserServiceOptions())
+
+		mockUserRepo.On("GetByID", mock.Anything, userID).Return(nil, models.ErrUserNotFound)
+
+		_, err := userService.RevokeUserTokens(context.Background(), userID.String())
+
+		assert.ErrorIs(t, err, services.ErrUserNotFound)
+		mockTxManager.AssertNotCalled(t, "ExecuteTx", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Lookup failure is not reported as not found", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		userService := services.NewUserService(mockUserRepo, new(mocks.MockRoleRepository), mockTxManager, services.DefaultUserServiceOptions())
+
+		mockUserRepo.On("GetByID", mock.Anything, userID).Return(nil, errors.New("connection refused"))
+
+		_, err := userService.RevokeUserTokens(context.Background(), userID.String())
+
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, services.ErrUserNotFound)
+		mockTxManager.AssertNotCalled(t, "ExecuteTx", mock.Anything, mock.Anything)
+	})
+}
+
+func TestUserService_SoftDeleteAndRestore(t *testing.T) {
+	editor := models.Role{ID: uuid.New(), Name: "editor"}
+	viewer := &models.Role{ID: uuid.New(), Name: "viewer"}
+
+	// setup returns a service soft-deleting users with opts, whose mocked store records deletion on user
+	setup := func(user *models.User, opts services.UserServiceOptions) (*services.UserService, *mocks.MockUserRepository, *mocks.MockRoleRepository, *mocks.MockTxRepository) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxRepo := new(mocks.MockTxRepository)
+
+		opts.SoftDelete = true
+		userService := services.NewUserService(mockUserRepo, mockRoleRepo, mockTxManager, opts)
+
+		mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		mockUserRepo.On("InvalidateUser", user.ID).Return()
+		mockUserRepo.On("InvalidateDeletedUser", user.ID).Return()
+		mockRoleRepo.On("GetRolePermissions", mock.Anything, mock.Anything).Return([]models.Permission{}, nil)
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(nil).Run(func(args mock.Arguments) {
+			args.Get(1).(func(transaction.Repository) error)(mockTxRepo)
+		})
+		mockTxRepo.On("SoftDeleteUser", mock.Anything, user.ID, mock.AnythingOfType("time.Time")).Return(nil).Run(func(args mock.Arguments) {
+			deletedAt := args.Get(2).(time.Time)
+			user.DeletedAt = &deletedAt
+			user.IsActive = false
+		})
+		mockTxRepo.On("RevokeUserTokens", mock.Anything, user.ID).Return(nil)
+		mockTxRepo.On("RevokeUserAPIKeys", mock.Anything, user.ID, mock.AnythingOfType("time.Time")).Return(1, nil)
+		mockTxRepo.On("RestoreUser", mock.Anything, user.ID, mock.AnythingOfType("time.Time")).Return(nil).Run(func(args mock.Arguments) {
+			user.DeletedAt = nil
+			user.IsActive = true
+		})
+		mockTxRepo.On("AssignRolesToUser", mock.Anything, user.ID, mock.Anything).Return(nil)
+
+		return userService, mockUserRepo, mockRoleRepo, mockTxRepo
+	}
+
+	t.Run("Restored user regains prior roles", func(t *testing.T) {
+		user := &models.User{ID: uuid.New(), Username: "johndoe", IsActive: true, Roles: []models.Role{editor}}
+		userService, mockUserRepo, _, mockTxRepo := setup(user, services.DefaultUserServiceOptions())
+
+		require.NoError(t, userService.DeleteUser(context.Background(), user.ID.String()))
+
+		// The user is kept, marked deleted, with its role assignments
+		assert.NotNil(t, user.DeletedAt)
+		mockTxRepo.AssertNotCalled(t, "DeleteUser", mock.Anything, mock.Anything)
+		mockTxRepo.AssertNotCalled(t, "AssignRolesToUser", mock.Anything, mock.Anything, mock.Anything)
+		mockUserRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+		mockTxRepo.AssertCalled(t, "RevokeUserAPIKeys", mock.Anything, user.ID, mock.Anything)
+
+		restored, err := userService.RestoreUser(context.Background(), user.ID.String())
+
+		require.NoError(t, err)
+		assert.True(t, restored.IsActive)
+		assert.Nil(t, restored.DeletedAt)
+		require.Len(t, restored.Roles, 1)
+		assert.Equal(t, "editor", restored.Roles[0].Name)
+		mockTxRepo.AssertNotCalled(t, "AssignRolesToUser", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Restore roles replace prior roles", func(t *testing.T) {
+		deletedAt := time.Now()
+		user := &models.User{ID: uuid.New(), Username: "johndoe", DeletedAt: &deletedAt, Roles: []models.Role{editor}}
+		opts := services.DefaultUserServiceOptions()
+		opts.RestoreRoles = []string{"viewer", "missing"}
+		userService, _, mockRoleRepo, mockTxRepo := setup(user, opts)
+
+		mockRoleRepo.On("GetByName", mock.Anything, "viewer").Return(viewer, nil)
+		mockRoleRepo.On("GetByName", mock.Anything, "missing").Return(nil, errors.New("role not found"))
+
+		_, err := userService.RestoreUser(context.Background(), user.ID.String())
+
+		require.NoError(t, err)
+		mockTxRepo.AssertCalled(t, "AssignRolesToUser", mock.Anything, user.ID, []uuid.UUID{viewer.ID})
+	})
+
+	t.Run("Restoring a user that is not deleted", func(t *testing.T) {
+		user := &models.User{ID: uuid.New(), Username: "johndoe", IsActive: true}
+		userService, _, _, mockTxRepo := setup(user, services.DefaultUserServiceOptions())
+
+		_, err := userService.RestoreUser(context.Background(), user.ID.String())
+
+		assert.ErrorIs(t, err, services.ErrUserNotDeleted)
+		mockTxRepo.AssertNotCalled(t, "RestoreUser", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Restoring a user that does not exist", func(t *testing.T) {
+		user := &models.User{ID: uuid.New(), Username: "johndoe"}
+		userService, mockUserRepo, _, _ := setup(user, services.DefaultUserServiceOptions())
+		missingID := uuid.New()
+		mockUserRepo.On("GetByID", mock.Anything, missingID).Return(nil, models.ErrUserNotFound)
+
+		_, err := userService.RestoreUser(context.Background(), missingID.String())
+
+		assert.ErrorIs(t, err, services.ErrUserNotFound)
+	})
+
+	t.Run("Lookup failure is not reported as not found", func(t *testing.T) {
+		user := &models.User{ID: uuid.New(), Username: "johndoe"}
+		userService, mockUserRepo, _, _ := setup(user, services.DefaultUserServiceOptions())
+		failingID := uuid.New()
+		mockUserRepo.On("GetByID", mock.Anything, failingID).Return(nil, errors.New("connection refused"))
+
+		_, err := userService.RestoreUser(context.Background(), failingID.String())
+
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, services.ErrUserNotFound)
+	})
+
+	t.Run("Purge removes everything", func(t *testing.T) {
+		deletedAt := time.Now()
+		user := &models.User{ID: uuid.New(), Username: "johndoe", DeletedAt: &deletedAt, Roles: []models.Role{editor}}
+		opts := services.DefaultUserServiceOptions()
+		opts.LastAdminProtection = false
+		userService, mockUserRepo, _, mockTxRepo := setup(user, opts)
+
+		// The transaction deletes the user with its role assignments and API keys
+		mockTxRepo.On("DeleteUser", mock.Anything, user.ID).Return(nil)
+
+		require.NoError(t, userService.PurgeUser(context.Background(), user.ID.String()))
+
+		mockTxRepo.AssertCalled(t, "DeleteUser", mock.Anything, user.ID)
+		mockUserRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+		mockUserRepo.AssertCalled(t, "InvalidateDeletedUser", user.ID)
+	})
+}
+
+func TestUserService_MergeAndRestoreCheckRoleGrant(t *testing.T) {
+	actorID := uuid.New()
+	admin := models.Role{ID: uuid.New(), Name: "admin"}
+	readPermission := models.Permission{ID: uuid.New(), Resource: "user", Action: "read"}
+	deletePermission := models.Permission{ID: uuid.New(), Resource: "user", Action: "delete"}
+
+	// setup returns a service guarding against escalation, acting for a user who only holds user:read
+	setup := func(users ...*models.User) (*services.UserService, *mocks.Manager[transaction.Repository], context.Context) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+
+		opts := services.DefaultUserServiceOptions()
+		opts.DenyPrivilegeEscalation = true
+		userService := services.NewUserService(mockUserRepo, mockRoleRepo, mockTxManager, opts)
+
+		for _, user := range users {
+			mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		}
+		mockUserRepo.On("GetUserPermissions", mock.Anything, actorID).Return([]models.Permission{readPermission}, nil)
+		mockRoleRepo.On("GetRolePermissions", mock.Anything, admin.ID).Return([]models.Permission{readPermission, deletePermission}, nil)
+
+		ctx := services.WithActor(context.Background(), services.Actor{UserID: actorID.String()})
+		return userService, mockTxManager, ctx
+	}
+
+	t.Run("Merging roles the actor does not hold is blocked", func(t *testing.T) {
+		source := &models.User{ID: uuid.New(), IsActive: true, Roles: []models.Role{admin}}
+		target := &models.User{ID: uuid.New(), IsActive: true}
+		userService, mockTxManager, ctx := setup(source, target)
+
+		_, err := userService.MergeUsers(ctx, actorID.String(), target.ID.String(), source.ID.String())
+
+		assert.ErrorIs(t, err, services.ErrPrivilegeEscalation)
+		assert.ErrorContains(t, err, "user:delete")
+		mockTxManager.AssertNotCalled(t, "ExecuteTx", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Restoring roles the actor does not hold is blocked", func(t *testing.T) {
+		deletedAt := time.Now()
+		user := &models.User{ID: uuid.New(), DeletedAt: &deletedAt, Roles: []models.Role{admin}}
+		userService, mockTxManager, ctx := setup(user)
+
+		_, err := userService.RestoreUser(ctx, user.ID.String())
+
+		assert.ErrorIs(t, err, services.ErrPrivilegeEscalation)
+		mockTxManager.AssertNotCalled(t, "ExecuteTx", mock.Anything, mock.Anything)
+	})
+}
+
+// recordingEmitter keeps the events emitted to it
+type recordingEmitter struct {
+	emitted []events.Envelope
+}
+
+func (e *recordingEmitter) Emit(event events.Envelope) bool {
+	e.emitted = append(e.emitted, event)
+	return true
+}
+
+func (e *recordingEmitter) types() []string {
+	types := make([]string, len(e.emitted))
+	for i, event := range e.emitted {
+		types[i] = event.Type
+	}
+	return types
+}
+
+func TestUserService_LifecycleEvents(t *testing.T) {
+	actorID := uuid.New().String()
+	ctx := services.WithActor(context.Background(), services.Actor{UserID: actorID})
+
+	setup := func(user *models.User) (*services.UserService, *mocks.MockTxRepository, *recordingEmitter) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxRepo := new(mocks.MockTxRepository)
+
+		mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		mockUserRepo.On("InvalidateUser", user.ID).Return()
+		mockUserRepo.On("InvalidateDeletedUser", user.ID).Return()
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(func(ctx context.Context, fn func(transaction.Repository) error) error {
+			return fn(mockTxRepo)
+		})
+		mockTxRepo.On("UpdateUser", mock.Anything, user).Return(nil)
+
+		emitter := &recordingEmitter{}
+		opts := services.DefaultUserServiceOptions()
+		opts.LastAdminProtection = false
+		opts.Events = emitter
+		userService := services.NewUserService(mockUserRepo, new(mocks.MockRoleRepository), mockTxManager, opts)
+		return userService, mockTxRepo, emitter
+	}
+
+	t.Run("Deactivating a user", func(t *testing.T) {
+		user := &models.User{ID: uuid.New(), Username: "johndoe", IsActive: true}
+		userService, _, emitter := setup(user)
+		inactive := false
+
+		_, err := userService.UpdateUser(ctx, user.ID.String(), models.UserUpdateRequest{IsActive: &inactive})
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{events.TypeUserUpdated, events.TypeUserDeactivated}, emitter.types())
+		assert.Equal(t, user.ID.String(), emitter.emitted[1].Payload["user_id"])
+		assert.Equal(t, events.Actor{ID: actorID, Type: "user"}, emitter.emitted[1].Actor)
+	})
+
+	t.Run("Deleting a user", func(t *testing.T) {
+		user := &models.User{ID: uuid.New(), Username: "johndoe", IsActive: true}
+		userService, mockTxRepo, emitter := setup(user)
+		mockTxRepo.On("DeleteUser", mock.Anything, user.ID).Return(nil)
+
+		require.NoError(t, userService.DeleteUser(ctx, user.ID.String()))
+
+		assert.Equal(t, []string{events.TypeUserDeleted}, emitter.types())
+		assert.Equal(t, user.ID.String(), emitter.emitted[0].Payload["user_id"])
+		assert.NotEmpty(t, emitter.emitted[0].Payload["username"])
+	})
+
+	t.Run("Deleting a user in a request transaction publishes once committed", func(t *testing.T) {
+		user := &models.User{ID: uuid.New(), Username: "johndoe", IsActive: true}
+		userService, mockTxRepo, emitter := setup(user)
+		mockTxRepo.On("DeleteUser", mock.Anything, user.ID).Return(nil)
+		hooks := &transaction.CommitHooks{}
+		txCtx := context.WithValue(ctx, transaction.CommitHooksKey, hooks)
+
+		require.NoError(t, userService.DeleteUser(txCtx, user.ID.String()))
+		assert.Empty(t, emitter.emitted)
+
+		hooks.Run()
+		assert.Equal(t, []string{events.TypeUserDeleted}, emitter.types())
+	})
+
+	t.Run("Failed changes publish nothing", func(t *testing.T) {
+		user := &models.User{ID: uuid.New(), Username: "johndoe", IsActive: true}
+		userService, mockTxRepo, emitter := setup(user)
+		mockTxRepo.On("DeleteUser", mock.Anything, user.ID).Return(errors.New("boom"))
+
+		assert.Error(t, userService.DeleteUser(ctx, user.ID.String()))
+
+		assert.Empty(t, emitter.emitted)
+	})
+}
+
+func TestUserService_GetUserPolicy(t *testing.T) {
+	userRead := models.Permission{ID: uuid.New(), Name: "user:read", Resource: "user", Action: "read"}
+	userWrite := models.Permission{ID: uuid.New(), Name: "user:write", Resource: "user", Action: "write"}
+	roleRead := models.Permission{ID: uuid.New(), Name: "role:read", Resource: "role", Action: "read"}
+
+	editor := models.Role{ID: uuid.New(), Name: "editor"}
+	viewer := models.Role{ID: uuid.New(), Name: "viewer"}
+
+	t.Run("Overlapping roles", func(t *testing.T) {
+		user := &models.User{ID: uuid.New(), Username: "johndoe", Roles: []models.Role{viewer, editor}}
+
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		mockRoleRepo.On("GetPermissionsByRoleIDs", mock.Anything, []uuid.UUID{viewer.ID, editor.ID}).Return(map[uuid.UUID][]models.Permission{
+			editor.ID: {userWrite, userRead},
+			viewer.ID: {userRead, roleRead},
+		}, nil)
+		userService := services.NewUserService(mockUserRepo, mockRoleRepo, new(mocks.Manager[transaction.Repository]), services.DefaultUserServiceOptions())
+
+		policy, err := userService.GetUserPolicy(context.Background(), user.ID.String())
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.UserPolicyVersion, policy.Version)
+		assert.Equal(t, user.ID, policy.UserID)
+		assert.Equal(t, []models.PolicyRole{
+			{ID: editor.ID, Name: "editor", Permissions: []string{"user:read", "user:write"}},
+			{ID: viewer.ID, Name: "viewer", Permissions: []string{"role:read", "user:read"}},
+		}, policy.Roles)
+		assert.Equal(t, []models.PolicyPermission{
+			{Permission: "role:read", GrantedBy: []string{"viewer"}},
+			{Permission: "user:read", GrantedBy: []string{"editor", "viewer"}},
+			{Permission: "user:write", GrantedBy: []string{"editor"}},
+		}, policy.EffectivePermissions)
+
+		// Only the user's roles are loaded, in one batched query rather than one per role
+		mockRoleRepo.AssertNotCalled(t, "GetRolePermissions", mock.Anything, mock.Anything)
+		mockRoleRepo.AssertNotCalled(t, "GetAll", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("User without roles", func(t *testing.T) {
+		user := &models.User{ID: uuid.New(), Username: "janedoe"}
+
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		userService := services.NewUserService(mockUserRepo, mockRoleRepo, new(mocks.Manager[transaction.Repository]), services.DefaultUserServiceOptions())
+
+		policy, err := userService.GetUserPolicy(context.Background(), user.ID.String())
+
+		assert.NoError(t, err)
+		assert.Empty(t, policy.Roles)
+		assert.NotNil(t, policy.EffectivePermissions)
+		assert.Empty(t, policy.EffectivePermissions)
+		mockRoleRepo.AssertNotCalled(t, "GetPermissionsByRoleIDs", mock.Anything, mock.Anything)
+	})
+
+	t.Run("User not found", func(t *testing.T) {
+		userID := uuid.New()
+
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetByID", mock.Anything, userID).Return(nil, models.ErrUserNotFound)
+		userService := services.NewUserService(mockUserRepo, new(mocks.MockRoleRepository), new(mocks.Manager[transaction.Repository]), services.DefaultUserServiceOptions())
+
+		policy, err := userService.GetUserPolicy(context.Background(), userID.String())
+
+		assert.ErrorIs(t, err, services.ErrUserNotFound)
+		assert.Nil(t, policy)
+	})
+
+	t.Run("Invalid user ID", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		userService := services.NewUserService(mockUserRepo, new(mocks.MockRoleRepository), new(mocks.Manager[transaction.Repository]), services.DefaultUserServiceOptions())
+
+		policy, err := userService.GetUserPolicy(context.Background(), "not-a-uuid")
+
+		assert.ErrorIs(t, err, services.ErrInvalidID)
+		assert.NotErrorIs(t, err, services.ErrUserNotFound)
+		assert.Nil(t, policy)
+		mockUserRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Lookup failure is not reported as not found", func(t *testing.T) {
+		userID := uuid.New()
+
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockUserRepo.On("GetByID", mock.Anything, userID).Return(nil, errors.New("connection refused"))
+		userService := services.NewUserService(mockUserRepo, new(mocks.MockRoleRepository), new(mocks.Manager[transaction.Repository]), services.DefaultUserServiceOptions())
+
+		policy, err := userService.GetUserPolicy(context.Background(), userID.String())
+
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, services.ErrUserNotFound)
+		assert.Nil(t, policy)
+	})
+}
+
+func TestUserService_BulkDeactivateUsers(t *testing.T) {
+	filter := models.UserFilter{RoleName: "contractor"}
+
+	setup := func(matched []*models.User) (*services.UserService, *mocks.MockUserRepository, *mocks.Manager[transaction.Repository], *mocks.MockTxRepository) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxRepo := new(mocks.MockTxRepository)
+
+		matchedIDs := make([]uuid.UUID, len(matched))
+		for i, user := range matched {
+			matchedIDs[i] = user.ID
+		}
+		mockUserRepo.On("GetUserIDsByFilter", mock.Anything, filter).Return(matchedIDs, nil)
+
+		// Users are loaded a batch of 100 at a time
+		for start := 0; start < len(matched); start += 100 {
+			end := min(start+100, len(matched))
+			mockUserRepo.On("GetByIDs", mock.Anything, matchedIDs[start:end]).Return(matched[start:end], nil)
+		}
+		mockUserRepo.On("InvalidateUser", mock.Anything).Return()
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(nil).Run(func(args mock.Arguments) {
+			txFunc := args.Get(1).(func(transaction.Repository) error)
+			txFunc(mockTxRepo)
+		})
+		mockTxRepo.On("RevokeUserTokens", mock.Anything, mock.Anything).Return(nil)
+
+		return services.NewUserService(mockUserRepo, new(mocks.MockRoleRepository), mockTxManager, services.DefaultUserServiceOptions()), mockUserRepo, mockTxManager, mockTxRepo
+	}
+
+	ids := func(users ...*models.User) []uuid.UUID {
+		userIDs := make([]uuid.UUID, len(users))
+		for i, user := range users {
+			userIDs[i] = user.ID
+		}
+		return userIDs
+	}
+
+	t.Run("Deactivates the matched users only", func(t *testing.T) {
+		actor := &models.User{ID: uuid.New(), IsActive: true}
+		alice := &models.User{ID: uuid.New(), Username: "alice", IsActive: true}
+		bob := &models.User{ID: uuid.New(), Username: "bob", IsActive: true}
+		inactive := &models.User{ID: uuid.New(), Username: "carol", IsActive: false}
+		unmatched := &models.User{ID: uuid.New(), Username: "dave", IsActive: true}
+		userService, mockUserRepo, _, mockTxRepo := setup([]*models.User{alice, actor, bob, inactive})
+		mockTxRepo.On("DeactivateUsers", mock.Anything, ids(alice, bob), mock.Anything).Return(ids(alice, bob), nil)
+
+		result, err := userService.BulkDeactivateUsers(context.Background(), actor.ID.String(), models.BulkDeactivateRequest{Filter: filter})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.BulkDeactivateResponse{Matched: 4, Deactivated: 2}, result)
+		assert.False(t, alice.IsActive)
+		assert.False(t, bob.IsActive)
+		assert.True(t, actor.IsActive)
+		assert.True(t, unmatched.IsActive)
+		mockTxRepo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything)
+		mockTxRepo.AssertCalled(t, "RevokeUserTokens", mock.Anything, alice.ID)
+		mockTxRepo.AssertCalled(t, "RevokeUserTokens", mock.Anything, bob.ID)
+		mockUserRepo.AssertCalled(t, "InvalidateUser", alice.ID)
+		mockUserRepo.AssertNotCalled(t, "InvalidateUser", unmatched.ID)
+	})
+
+	t.Run("Users deactivated meanwhile are skipped", func(t *testing.T) {
+		alice := &models.User{ID: uuid.New(), Username: "alice", IsActive: true}
+		// Cached as active, but already deactivated in the database
+		stale := &models.User{ID: uuid.New(), Username: "bob", IsActive: true}
+		userService, mockUserRepo, _, mockTxRepo := setup([]*models.User{alice, stale})
+		mockTxRepo.On("DeactivateUsers", mock.Anything, ids(alice, stale), mock.Anything).Return(ids(alice), nil)
+
+		result, err := userService.BulkDeactivateUsers(context.Background(), uuid.New().String(), models.BulkDeactivateRequest{Filter: filter})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.BulkDeactivateResponse{Matched: 2, Deactivated: 1}, result)
+		mockTxRepo.AssertNotCalled(t, "RevokeUserTokens", mock.Anything, stale.ID)
+		mockUserRepo.AssertNotCalled(t, "InvalidateUser", stale.ID)
+	})
+
+	t.Run("Dry run counts without deactivating", func(t *testing.T) {
+		alice := &models.User{ID: uuid.New(), IsActive: true}
+		inactive := &models.User{ID: uuid.New(), IsActive: false}
+		userService, _, mockTxManager, _ := setup([]*models.User{alice, inactive})
+
+		result, err := userService.BulkDeactivateUsers(context.Background(), uuid.New().String(), models.BulkDeactivateRequest{Filter: filter, DryRun: true})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.BulkDeactivateResponse{DryRun: true, Matched: 2, Deactivated: 1}, result)
+		assert.True(t, alice.IsActive)
+		mockTxManager.AssertNotCalled(t, "ExecuteTx", mock.Anything, mock.Anything)
+	})
+
+	t.Run("One transaction per batch", func(t *testing.T) {
+		matched := make([]*models.User, 150)
+		for i := range matched {
+			matched[i] = &models.User{ID: uuid.New(), IsActive: true}
+		}
+		userService, _, mockTxManager, mockTxRepo := setup(matched)
+		mockTxRepo.On("DeactivateUsers", mock.Anything, ids(matched[:100]...), mock.Anything).Return(ids(matched[:100]...), nil)
+		mockTxRepo.On("DeactivateUsers", mock.Anything, ids(matched[100:]...), mock.Anything).Return(ids(matched[100:]...), nil)
+
+		result, err := userService.BulkDeactivateUsers(context.Background(), uuid.New().String(), models.BulkDeactivateRequest{Filter: filter})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 150, result.Deactivated)
+		mockTxManager.AssertNumberOfCalls(t, "ExecuteTx", 2)
+		mockTxRepo.AssertNumberOfCalls(t, "DeactivateUsers", 2)
+		mockTxRepo.AssertNumberOfCalls(t, "RevokeUserTokens", 150)
+	})
+
+	t.Run("Empty filter", func(t *testing.T) {
+		userService := services.NewUserService(new(mocks.MockUserRepository), new(mocks.MockRoleRepository), new(mocks.Manager[transaction.Repository]), services.DefaultUserServiceOptions())
+
+		result, err := userService.BulkDeactivateUsers(context.Background(), uuid.New().String(), models.BulkDeactivateRequest{DryRun: true})
+
+		assert.Nil(t, result)
+		assert.ErrorContains(t, err, "filter must set at least one")
+	})
+}
+
+func TestUserService_ImportUsers(t *testing.T) {
+	editor := &models.Role{ID: uuid.New(), Name: "editor"}
+	viewer := &models.Role{ID: uuid.New(), Name: "viewer"}
+
+	setup := func() (*services.UserService, *mocks.MockUserRepository, *mocks.Manager[transaction.Repository], *mocks.MockTxRepository) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxRepo := new(mocks.MockTxRepository)
+
+		mockUserRepo.On("GetByUsername", mock.Anything, "existinguser").Return(&models.User{ID: uuid.New(), Username: "existinguser"}, nil)
+		mockUserRepo.On("GetByUsername", mock.Anything, mock.Anything).Return(nil, errors.New("user not found"))
+		mockUserRepo.On("InvalidateUser", mock.Anything).Return()
+		mockRoleRepo.On("GetByName", mock.Anything, "editor").Return(editor, nil)
+		mockRoleRepo.On("GetByName", mock.Anything, "viewer").Return(viewer, nil)
+		mockRoleRepo.On("GetByName", mock.Anything, mock.Anything).Return(nil, errors.New("role not found"))
+
+		// The transaction fails when the function does, as a real one would
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(
+			func(_ context.Context, fn func(transaction.Repository) error) error {
+				return fn(mockTxRepo)
+			})
+		mockTxRepo.On("AssignRolesToUser", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+		return services.NewUserService(mockUserRepo, mockRoleRepo, mockTxManager, services.DefaultUserServiceOptions()), mockUserRepo, mockTxManager, mockTxRepo
+	}
+
+	// creates makes CreateUser succeed and give the user an ID, except for the given emails
+	creates := func(mockTxRepo *mocks.MockTxRepository, takenEmails ...string) {
+		mockTxRepo.On("CreateUser", mock.Anything, mock.MatchedBy(func(user *models.User) bool {
+			return slices.Contains(takenEmails, user.Email)
+		})).Return(models.ErrEmailExists)
+		mockTxRepo.On("CreateUser", mock.Anything, mock.AnythingOfType("*models.User")).Return(nil).Run(func(args mock.Arguments) {
+			args.Get(1).(*models.User).ID = uuid.New()
+		})
+	}
+
+	t.Run("Valid CSV creates every user", func(t *testing.T) {
+		userService, mockUserRepo, mockTxManager, mockTxRepo := setup()
+		creates(mockTxRepo)
+
+		csv := "username,email,first_name,last_name,roles,password\n" +
+			"janedoe1,jane@example.com,Jane,Doe,editor;viewer,password123\n" +
+			"johndoe1,john@example.com,John,Doe,,generate\n"
+
+		result, err := userService.ImportUsers(context.Background(), strings.NewReader(csv), false)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Total)
+		assert.Equal(t, 2, result.Created)
+		require.Len(t, result.Rows, 2)
+
+		jane, john := result.Rows[0], result.Rows[1]
+		assert.Equal(t, 2, jane.Row)
+		assert.Equal(t, models.ImportRowCreated, jane.Status)
+		require.NotNil(t, jane.UserID)
+		assert.Empty(t, jane.GeneratedPassword)
+		assert.Equal(t, models.ImportRowCreated, john.Status)
+		assert.Len(t, john.GeneratedPassword, 16)
+
+		// One transaction for the batch
+		mockTxManager.AssertNumberOfCalls(t, "ExecuteTx", 1)
+		mockTxRepo.AssertCalled(t, "AssignRolesToUser", mock.Anything, *jane.UserID, []uuid.UUID{editor.ID, viewer.ID})
+		mockUserRepo.AssertCalled(t, "InvalidateUser", *john.UserID)
+	})
+
+	t.Run("Invalid and duplicate rows are reported and skipped", func(t *testing.T) {
+		userService, _, _, mockTxRepo := setup()
+		creates(mockTxRepo, "taken@example.com")
+
+		csv := "username,email,password,roles\n" +
+			"janedoe1,jane@example.com,password123,editor\n" +
+			"short,short@example.com,password123,\n" +
+			"baduser1,not-an-email,password123,\n" +
+			"nopass01,nopass@example.com,,\n" +
+			"ghostie1,ghost@example.com,password123,ghost\n" +
+			"existinguser,existing@example.com,password123,\n" +
+			"janedoe1,other@example.com,password123,\n" +
+			"takenmail,taken@example.com,password123,\n" +
+			"fewfields\n"
+
+		result, err := userService.ImportUsers(context.Background(), strings.NewReader(csv), false)
+
+		require.NoError(t, err)
+		assert.Equal(t, 9, result.Total)
+		assert.Equal(t, 1, result.Created)
+		assert.Equal(t, 5, result.Invalid)
+		assert.Equal(t, 3, result.Duplicates)
+
+		status := make(map[int]models.UserImportRow)
+		for _, row := range result.Rows {
+			status[row.Row] = row
+		}
+		assert.Equal(t, models.ImportRowCreated, status[2].Status)
+		assert.Contains(t, status[3].Error, "username")
+		assert.Contains(t, status[4].Error, "email")
+		assert.Contains(t, status[5].Error, "password is required")
+		assert.Contains(t, status[6].Error, `unknown role "ghost"`)
+		assert.Equal(t, models.ImportRowDuplicate, status[7].Status)
+		assert.Equal(t, models.ImportRowDuplicate, status[8].Status)
+		assert.Contains(t, status[8].Error, "row 2")
+		// The taken email fails its batch, and the retry row by row pins it down
+		assert.Equal(t, models.ImportRowDuplicate, status[9].Status)
+		assert.Equal(t, models.ErrEmailExists.Error(), status[9].Error)
+		assert.Contains(t, status[10].Error, "expected 4 fields")
+	})
+
+	t.Run("Dry run validates without creating", func(t *testing.T) {
+		userService, _, mockTxManager, _ := setup()
+
+		csv := "username,email,password\n" +
+			"janedoe1,jane@example.com,password123\n" +
+			"baduser1,not-an-email,password123\n"
+
+		result, err := userService.ImportUsers(context.Background(), strings.NewReader(csv), true)
+
+		require.NoError(t, err)
+		assert.True(t, result.DryRun)
+		assert.Equal(t, 1, result.Valid)
+		assert.Equal(t, 1, result.Invalid)
+		assert.Zero(t, result.Created)
+		assert.Equal(t, models.ImportRowValid, result.Rows[0].Status)
+		assert.Nil(t, result.Rows[0].UserID)
+		mockTxManager.AssertNotCalled(t, "ExecuteTx", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Invalid header", func(t *testing.T) {
+		userService, _, _, _ := setup()
+
+		for csv, want := range map[string]string{
+			"":                                "CSV is empty",
+			"username,email\n":                `missing column "password"`,
+			"username,email,password,phone\n": `unknown column "phone"`,
+			"username,email,password,email\n": `column "email" appears more than once`,
+		} {
+			_, err := userService.ImportUsers(context.Background(), strings.NewReader(csv), true)
+			assert.ErrorContains(t, err, want)
+		}
+	})
+}
+
+// sequentialTx is a transaction repository that applies its writes as they run, as MongoDB does
+// without transaction support
+type sequentialTx struct {
+	*mocks.MockTxRepository
+}
+
+func (sequentialTx) Sequential() bool { return true }
+
+func TestUserService_LastAdminProtection(t *testing.T) {
+	admin := models.Role{ID: uuid.New(), Name: "admin"}
+	viewer := models.Role{ID: uuid.New(), Name: "viewer"}
+
+	// The transaction fails, and so rolls back, whenever fn does
+	setup := func(user *models.User, adminsLeft int, opts services.UserServiceOptions) (*services.UserService, *mocks.MockUserRepository, *mocks.MockTxRepository) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxRepo := new(mocks.MockTxRepository)
+
+		mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		mockUserRepo.On("InvalidateUser", user.ID).Return()
+		mockUserRepo.On("InvalidateDeletedUser", user.ID).Return()
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(func(ctx context.Context, fn func(transaction.Repository) error) error {
+			return fn(mockTxRepo)
+		})
+		mockTxRepo.On("DeleteUser", mock.Anything, user.ID).Return(nil)
+		mockTxRepo.On("UpdateUser", mock.Anything, user).Return(nil)
+		mockTxRepo.On("AssignRolesToUser", mock.Anything, user.ID, mock.Anything).Return(nil)
+		mockTxRepo.On("CountActiveUsersWithRole", mock.Anything, "admin").Return(adminsLeft, nil)
+
+		return services.NewUserService(mockUserRepo, new(mocks.MockRoleRepository), mockTxManager, opts), mockUserRepo, mockTxRepo
+	}
+
+	t.Run("Deleting the last admin is blocked", func(t *testing.T) {
+		user := &models.User{ID: uuid.New(), IsActive: true, Roles: []models.Role{admin}}
+		userService, mockUserRepo, mockTxRepo := setup(user, 0, services.DefaultUserServiceOptions())
+
+		err := userService.DeleteUser(context.Background(), user.ID.String())
+
+		assert.ErrorIs(t, err, services.ErrLastAdmin)
+		assert.EqualError(t, err, "cannot remove last admin")
+		// The count runs after the delete, in the same transaction
+		mockTxRepo.AssertCalled(t, "DeleteUser", mock.Anything, user.ID)
+		mockUserRepo.AssertNotCalled(t, "InvalidateDeletedUser", mock.Anything)
+		mockUserRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Deleting an admin without transactions is refused before writing", func(t *testing.T) {
+		user := &models.User{ID: uuid.New(), IsActive: true, Roles: []models.Role{admin}}
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxRepo := new(mocks.MockTxRepository)
+		mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(func(ctx context.Context, fn func(transaction.Repository) error) error {
+			return fn(sequentialTx{mockTxRepo})
+		})
+		userService := services.NewUserService(mockUserRepo, new(mocks.MockRoleRepository), mockTxManager, services.DefaultUserServiceOptions())
+
+		err := userService.DeleteUser(context.Background(), user.ID.String())
+
+		assert.ErrorIs(t, err, transaction.ErrNotAtomic)
+		mockTxRepo.AssertNotCalled(t, "DeleteUser", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Deleting one of several admins is allowed", func(t *testing.T) {
+		user := &models.User{ID: uuid.New(), IsActive: true, Roles: []models.Role{admin}}
+		userService, mockUserRepo, mockTxRepo := setup(user, 1, services.DefaultUserServiceOptions())
+
+		err := userService.DeleteUser(context.Background(), user.ID.String())
+
+		assert.NoError(t, err)
+		mockTxRepo.AssertCalled(t, "CountActiveUsersWithRole", mock.Anything, "admin")
+		mockUserRepo.AssertCalled(t, "InvalidateDeletedUser", user.ID)
+	})
+
+	t.Run("Demoting the last admin is blocked", func(t *testing.T) {
+		user := &models.User{ID: uuid.New(), IsActive: true, Roles: []models.Role{admin}}
+		userService, mockUserRepo, _ := setup(user, 0, services.DefaultUserServiceOptions())
+
+		result, err := userService.UpdateUser(context.Background(), user.ID.String(), models.UserUpdateRequest{
+			RoleIDs: []string{viewer.ID.String()},
+		})
+
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, services.ErrLastAdmin)
+		mockUserRepo.AssertNotCalled(t, "InvalidateUser", mock.Anything)
+	})
+
+	t.Run("Deactivating one of several admins is allowed", func(t *testing.T) {
+		user := &models.User{ID: uuid.New(), IsActive: true, Roles: []models.Role{admin}}
+		userService, _, mockTxRepo := setup(user, 1, services.DefaultUserServiceOptions())
+		inactive := false
+
+		result, err := userService.UpdateUser(context.Background(), user.ID.String(), models.UserUpdateRequest{IsActive: &inactive})
+
+		assert.NoError(t, err)
+		assert.False(t, result.IsActive)
+		mockTxRepo.AssertCalled(t, "CountActiveUsersWithRole", mock.Anything, "admin")
+	})
+
+	t.Run("Deactivating the last admin in bulk is blocked", func(t *testing.T) {
+		user := &models.User{ID: uuid.New(), IsActive: true, Roles: []models.Role{admin}}
+		userService, mockUserRepo, mockTxRepo := setup(user, 0, services.DefaultUserServiceOptions())
+		filter := models.UserFilter{RoleName: "admin"}
+		mockUserRepo.On("GetUserIDsByFilter", mock.Anything, filter).Return([]uuid.UUID{user.ID}, nil)
+		mockUserRepo.On("GetByIDs", mock.Anything, []uuid.UUID{user.ID}).Return([]*models.User{user}, nil)
+		mockTxRepo.On("DeactivateUsers", mock.Anything, []uuid.UUID{user.ID}, mock.Anything).Return([]uuid.UUID{user.ID}, nil)
+		mockTxRepo.On("RevokeUserTokens", mock.Anything, user.ID).Return(nil)
+
+		result, err := userService.BulkDeactivateUsers(context.Background(), uuid.New().String(), models.BulkDeactivateRequest{Filter: filter})
+
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, services.ErrLastAdmin)
+	})
+
+	t.Run("Other users are not counted", func(t *testing.T) {
+		user := &models.User{ID: uuid.New(), IsActive: true, Roles: []models.Role{viewer}}
+		userService, _, mockTxRepo := setup(user, 0, services.DefaultUserServiceOptions())
+
+		err := userService.DeleteUser(context.Background(), user.ID.String())
+
+		assert.NoError(t, err)
+		mockTxRepo.AssertNotCalled(t, "CountActiveUsersWithRole", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		user := &models.User{ID: uuid.New(), IsActive: true, Roles: []models.Role{admin}}
+		opts := services.DefaultUserServiceOptions()
+		opts.LastAdminProtection = false
+		userService, _, mockTxRepo := setup(user, 0, opts)
+
+		err := userService.DeleteUser(context.Background(), user.ID.String())
+
+		assert.NoError(t, err)
+		mockTxRepo.AssertCalled(t, "DeleteUser", mock.Anything, user.ID)
+		mockTxRepo.AssertNotCalled(t, "CountActiveUsersWithRole", mock.Anything, mock.Anything)
+	})
+}
+
+func TestUserService_PrivilegeEscalation(t *testing.T) {
+	actorID := uuid.New()
+	viewer := &models.Role{ID: uuid.New(), Name: "viewer"}
+	admin := &models.Role{ID: uuid.New(), Name: "admin"}
+	readPermission := models.Permission{ID: uuid.New(), Resource: "user", Action: "read"}
+	deletePermission := models.Permission{ID: uuid.New(), Resource: "user", Action: "delete"}
+	roleWritePermission := models.Permission{ID: uuid.New(), Resource: "role", Action: "write"}
+
+	// create runs CreateUser assigning roleID as an actor holding the held permissions
+	create := func(t *testing.T, roleID uuid.UUID, held ...models.Permission) (*mocks.MockTxRepository, error) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxRepo := new(mocks.MockTxRepository)
+
+		opts := services.DefaultUserServiceOptions()
+		opts.DenyPrivilegeEscalation = true
+		userService := services.NewUserService(mockUserRepo, mockRoleRepo, mockTxManager, opts)
+
+		mockUserRepo.On("GetByUsername", mock.Anything, "johndoe").Return(nil, errors.New("user not found"))
+		mockUserRepo.On("GetUserPermissions", mock.Anything, actorID).Return(held, nil)
+		mockRoleRepo.On("GetRolePermissions", mock.Anything, viewer.ID).Return([]models.Permission{readPermission}, nil)
+		mockRoleRepo.On("GetRolePermissions", mock.Anything, admin.ID).Return([]models.Permission{readPermission, deletePermission}, nil)
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(nil).Run(func(args mock.Arguments) {
+			args.Get(1).(func(transaction.Repository) error)(mockTxRepo)
+		})
+		mockTxRepo.On("CreateUser", mock.Anything, mock.AnythingOfType("*models.User")).Return(nil)
+		mockTxRepo.On("AssignRolesToUser", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		mockUserRepo.On("InvalidateUser", mock.Anything).Return()
+		mockUserRepo.On("GetByID", mock.Anything, mock.Anything).Return(nil, errors.New("user not found"))
+
+		ctx := services.WithActor(context.Background(), services.Actor{UserID: actorID.String()})
+		_, err := userService.CreateUser(ctx, models.UserCreateRequest{
+			Username: "johndoe",
+			Email:    "john@example.com",
+			Password: "password123",
+			RoleIDs:  []string{roleID.String()},
+		})
+		return mockTxRepo, err
+	}
+
+	t.Run("Escalation attempt is blocked", func(t *testing.T) {
+		mockTxRepo, err := create(t, admin.ID, readPermission, roleWritePermission)
+
+		assert.ErrorIs(t, err, services.ErrPrivilegeEscalation)
+		assert.ErrorContains(t, err, "user:delete")
+		mockTxRepo.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Assignment within the actor's privileges is allowed", func(t *testing.T) {
+		mockTxRepo, err := create(t, viewer.ID, readPermission, roleWritePermission)
+
+		assert.NoError(t, err)
+		mockTxRepo.AssertCalled(t, "AssignRolesToUser", mock.Anything, mock.Anything, []uuid.UUID{viewer.ID})
+	})
+
+	t.Run("Assignment without role:write is blocked", func(t *testing.T) {
+		mockTxRepo, err := create(t, viewer.ID, readPermission)
+
+		assert.ErrorIs(t, err, services.ErrPrivilegeEscalation)
+		assert.ErrorContains(t, err, "role:write")
+		mockTxRepo.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything)
+	})
+}
+
+func TestUserService_SeedInitialAdmin(t *testing.T) {
+	admin := &models.Role{ID: uuid.New(), Name: "admin"}
+
+	seed := func(t *testing.T, existingAdmins int, createErr error) (*mocks.MockTxRepository, *mocks.MockUserRepository, bool, error) {
+		t.Helper()
+
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockRoleRepo := new(mocks.MockRoleRepository)
+		mockTxManager := new(mocks.Manager[transaction.Repository])
+		mockTxRepo := new(mocks.MockTxRepository)
+
+		mockRoleRepo.On("GetByName", mock.Anything, "admin").Return(admin, nil)
+		mockTxManager.On("ExecuteTx", mock.Anything, mock.AnythingOfType("func(transaction.Repository) error")).Return(func(ctx context.Context, fn func(transaction.Repository) error) error {
+			return fn(mockTxRepo)
+		})
+		mockTxRepo.On("CountActiveUsersWithRole", mock.Anything, "admin").Return(existingAdmins, nil)
+		mockTxRepo.On("CreateUser", mock.Anything, mock.AnythingOfType("*models.User")).Return(createErr)
+		mockTxRepo.On("AssignRolesToUser", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		mockUserRepo.On("InvalidateUser", mock.Anything).Return()
+
+		userService := services.NewUserService(mockUserRepo, mockRoleRepo, mockTxManager, services.DefaultUserServiceOptions())
+		created, err := userService.SeedInitialAdmin(context.Background(), "rootadmin", "root@example.com", "a-strong-password")
+		return mockTxRepo, mockUserRepo, created, err
+	}
+
+	t.Run("Seeds the configured admin when none exists", func(t *testing.T) {
+		mockTxRepo, mockUserRepo, created, err := seed(t, 0, nil)
+
+		assert.NoError(t, err)
+		assert.True(t, created)
+		mockTxRepo.AssertCalled(t, "CreateUser", mock.Anything, mock.MatchedBy(func(user *models.User) bool {
+			return user.Username == "rootadmin" && user.Email == "root@example.com" && user.IsActive &&
+				user.Password != "a-strong-password" && user.CheckPassword("a-strong-password")
+		}))
+		mockTxRepo.AssertCalled(t, "AssignRolesToUser", mock.Anything, mock.Anything, []uuid.UUID{admin.ID})
+		mockUserRepo.AssertCalled(t, "InvalidateUser", mock.Anything)
+	})
+
+	t.Run("Skips seeding when an admin exists", func(t *testing.T) {
+		mockTxRepo, mockUserRepo, created, err := seed(t, 1, nil)
+
+		assert.NoError(t, err)
+		assert.False(t, created)
+		mockTxRepo.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything)
+		mockUserRepo.AssertNotCalled(t, "InvalidateUser", mock.Anything)
+	})
+
+	t.Run("Skips seeding when another instance inserted the admin first", func(t *testing.T) {
+		mockTxRepo, mockUserRepo, created, err := seed(t, 0, models.ErrUsernameExists)
+
+		assert.NoError(t, err)
+		assert.False(t, created)
+		mockTxRepo.AssertNotCalled(t, "AssignRolesToUser", mock.Anything, mock.Anything, mock.Anything)
+		mockUserRepo.AssertNotCalled(t, "InvalidateUser", mock.Anything)
+	})
+
+	t.Run("Other insert failures are returned", func(t *testing.T) {
+		_, _, created, err := seed(t, 0, models.ErrEmailExists)
+
+		assert.ErrorIs(t, err, models.ErrEmailExists)
+		assert.False(t, created)
+	})
+}
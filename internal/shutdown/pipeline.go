@@ -0,0 +1,125 @@
+package shutdown
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chats/go-user-api/config"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultTimeout bounds a stage that the configured order leaves out
+const DefaultTimeout = 10 * time.Second
+
+// Stage is a component stopped on shutdown
+type Stage struct {
+	Name string
+	// Stop drains the component and should give up once ctx is done
+	Stop func(ctx context.Context) error
+	// Force stops the component at once when Stop overruns its timeout; nil when it cannot be forced
+	Force func()
+	// Timeout bounds Stop; it is set from the configured order
+	Timeout time.Duration
+}
+
+// Result is the outcome of a stage
+type Result struct {
+	Name     string
+	Duration time.Duration
+	Err      error
+	// TimedOut is set when Stop overran its timeout, and Forced when Force was then called
+	TimedOut bool
+	Forced   bool
+}
+
+// Pipeline stops components one after another, so that each drains before the next starts
+type Pipeline struct {
+	stages []Stage
+}
+
+// NewPipeline orders the stages as configured, each with its configured timeout. Stages the order
+// leaves out run last, in the order given, with DefaultTimeout; an unknown name in the order is an error.
+func NewPipeline(stages []Stage, order []config.ShutdownStage) (*Pipeline, error) {
+	byName := make(map[string]Stage, len(stages))
+	for _, stage := range stages {
+		byName[stage.Name] = stage
+	}
+
+	ordered := make([]Stage, 0, len(stages))
+	listed := make(map[string]bool, len(order))
+	for _, entry := range order {
+		stage, ok := byName[entry.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown shutdown stage %q", entry.Name)
+		}
+		stage.Timeout = entry.Timeout
+		ordered = append(ordered, stage)
+		listed[entry.Name] = true
+	}
+
+	for _, stage := range stages {
+		if !listed[stage.Name] {
+			stage.Timeout = DefaultTimeout
+			ordered = append(ordered, stage)
+		}
+	}
+
+	return &Pipeline{stages: ordered}, nil
+}
+
+// Stages returns the stages in the order they run
+func (p *Pipeline) Stages() []Stage {
+	return p.stages
+}
+
+// Run stops every stage in order and logs the outcome of each. A stage that overruns its timeout
+// is forced and left behind, so one stuck component cannot hold up the rest.
+func (p *Pipeline) Run() []Result {
+	results := make([]Result, 0, len(p.stages))
+	for _, stage := range p.stages {
+		result := run(stage)
+		results = append(results, result)
+
+		event := log.Info()
+		switch {
+		case result.TimedOut:
+			event = log.Warn().Bool("forced", result.Forced)
+		case result.Err != nil:
+			event = log.Error().Err(result.Err)
+		}
+		event.Str("stage", result.Name).
+			Dur("duration", result.Duration).
+			Dur("timeout", stage.Timeout).
+			Msg("Shutdown stage finished")
+	}
+	return results
+}
+
+func run(stage Stage) Result {
+	ctx, cancel := context.WithTimeout(context.Background(), stage.Timeout)
+	defer cancel()
+
+	log.Info().Str("stage", stage.Name).Dur("timeout", stage.Timeout).Msg("Shutdown stage started")
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- stage.Stop(ctx)
+	}()
+
+	result := Result{Name: stage.Name}
+	select {
+	case err := <-done:
+		result.Err = err
+	case <-ctx.Done():
+		result.TimedOut = true
+		result.Err = ctx.Err()
+		if stage.Force != nil {
+			stage.Force()
+			result.Forced = true
+		}
+	}
+	result.Duration = time.Since(start)
+	return result
+}
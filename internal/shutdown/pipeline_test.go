@@ -0,0 +1,154 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chats/go-user-api/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeComponent records when it is stopped and forced; a hung component only stops when forced
+type fakeComponent struct {
+	name   string
+	hung   bool
+	err    error
+	log    *[]string
+	mu     *sync.Mutex
+	forced chan struct{}
+}
+
+func newFake(name string, log *[]string, mu *sync.Mutex) *fakeComponent {
+	return &fakeComponent{name: name, log: log, mu: mu, forced: make(chan struct{})}
+}
+
+func (f *fakeComponent) record(entry string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	*f.log = append(*f.log, entry)
+}
+
+func (f *fakeComponent) stage() Stage {
+	return Stage{
+		Name: f.name,
+		Stop: func(ctx context.Context) error {
+			f.record("stop " + f.name)
+			if f.hung {
+				<-f.forced
+			}
+			return f.err
+		},
+		Force: func() {
+			f.record("force " + f.name)
+			close(f.forced)
+		},
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	t.Run("Stages run in the configured order", func(t *testing.T) {
+		var events []string
+		var mu sync.Mutex
+		grpc, http, broker := newFake("grpc", &events, &mu), newFake("http", &events, &mu), newFake("events", &events, &mu)
+
+		pipeline, err := NewPipeline([]Stage{http.stage(), broker.stage(), grpc.stage()}, []config.ShutdownStage{
+			{Name: "grpc", Timeout: time.Second},
+			{Name: "http", Timeout: time.Second},
+			{Name: "events", Timeout: time.Second},
+		})
+		require.NoError(t, err)
+
+		results := pipeline.Run()
+
+		assert.Equal(t, []string{"stop grpc", "stop http", "stop events"}, events)
+		require.Len(t, results, 3)
+		for _, result := range results {
+			assert.NoError(t, result.Err)
+			assert.False(t, result.TimedOut)
+		}
+	})
+
+	t.Run("A stage timeout forces a stop and the next stage still runs", func(t *testing.T) {
+		var events []string
+		var mu sync.Mutex
+		grpc, http := newFake("grpc", &events, &mu), newFake("http", &events, &mu)
+		grpc.hung = true
+
+		pipeline, err := NewPipeline([]Stage{grpc.stage(), http.stage()}, []config.ShutdownStage{
+			{Name: "grpc", Timeout: 20 * time.Millisecond},
+			{Name: "http", Timeout: time.Second},
+		})
+		require.NoError(t, err)
+
+		results := pipeline.Run()
+
+		assert.Equal(t, []string{"stop grpc", "force grpc", "stop http"}, events)
+		require.Len(t, results, 2)
+		assert.True(t, results[0].TimedOut)
+		assert.True(t, results[0].Forced)
+		assert.ErrorIs(t, results[0].Err, context.DeadlineExceeded)
+		assert.False(t, results[1].TimedOut)
+	})
+
+	t.Run("A stage that cannot be forced is left behind", func(t *testing.T) {
+		stuck := make(chan struct{})
+		defer close(stuck)
+		pipeline, err := NewPipeline([]Stage{{
+			Name: "events",
+			Stop: func(ctx context.Context) error {
+				<-stuck
+				return nil
+			},
+		}}, []config.ShutdownStage{{Name: "events", Timeout: 10 * time.Millisecond}})
+		require.NoError(t, err)
+
+		results := pipeline.Run()
+
+		require.Len(t, results, 1)
+		assert.True(t, results[0].TimedOut)
+		assert.False(t, results[0].Forced)
+	})
+
+	t.Run("Stop errors are reported", func(t *testing.T) {
+		var events []string
+		var mu sync.Mutex
+		http := newFake("http", &events, &mu)
+		http.err = errors.New("listener closed")
+
+		pipeline, err := NewPipeline([]Stage{http.stage()}, nil)
+		require.NoError(t, err)
+
+		results := pipeline.Run()
+
+		require.Len(t, results, 1)
+		assert.EqualError(t, results[0].Err, "listener closed")
+		assert.False(t, results[0].TimedOut)
+	})
+}
+
+func TestNewPipeline(t *testing.T) {
+	stages := []Stage{{Name: "grpc"}, {Name: "http"}, {Name: "events"}}
+
+	t.Run("Unlisted stages run last with the default timeout", func(t *testing.T) {
+		pipeline, err := NewPipeline(stages, []config.ShutdownStage{{Name: "http", Timeout: 3 * time.Second}})
+		require.NoError(t, err)
+
+		ordered := pipeline.Stages()
+		require.Len(t, ordered, 3)
+		assert.Equal(t, "http", ordered[0].Name)
+		assert.Equal(t, 3*time.Second, ordered[0].Timeout)
+		assert.Equal(t, "grpc", ordered[1].Name)
+		assert.Equal(t, DefaultTimeout, ordered[1].Timeout)
+		assert.Equal(t, "events", ordered[2].Name)
+	})
+
+	t.Run("Unknown stage", func(t *testing.T) {
+		_, err := NewPipeline(stages, []config.ShutdownStage{{Name: "kafka", Timeout: time.Second}})
+
+		assert.EqualError(t, err, `unknown shutdown stage "kafka"`)
+	})
+}
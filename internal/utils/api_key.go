@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	// APIKeyPrefix marks a credential as an API key issued by this service
+	APIKeyPrefix = "uak_"
+	// apiKeyDisplayLength is the number of leading characters kept for identification
+	apiKeyDisplayLength = 12
+)
+
+// GenerateAPIKey generates a new random API key and returns it with its display prefix
+func GenerateAPIKey() (string, string, error) {
+	randomBytes := make([]byte, 32)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	key := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(randomBytes)
+	return key, key[:apiKeyDisplayLength], nil
+}
+
+// HashAPIKey returns the SHA-256 hex digest used to store and look up an API key
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
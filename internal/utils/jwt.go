@@ -1,39 +1,125 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/chats/go-user-api/config"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
 )
 
+// ScopePasswordChange limits a token to changing the user's expired password
+const ScopePasswordChange = "password_change"
+
+// Token types; an access token authorizes requests, a refresh token only obtains new tokens, and a
+// password reset token only sets a new password
+const (
+	TokenTypeAccess        = "access"
+	TokenTypeRefresh       = "refresh"
+	TokenTypePasswordReset = "password_reset"
+)
+
+// ErrWrongTokenType is returned when a token of one type is presented where another is expected
+var ErrWrongTokenType = errors.New("wrong token type")
+
 // JWTClaims represents the custom claims in JWT token
 type JWTClaims struct {
 	UserID   string   `json:"user_id"`
 	Username string   `json:"username"`
 	Roles    []string `json:"roles"`
+	// RolesOmitted marks a token whose user had more roles than JWT_MAX_ROLES allows, so the roles
+	// have to be looked up rather than read from Roles
+	RolesOmitted bool `json:"roles_omitted,omitempty"`
+	// Scope restricts what the token may be used for; empty means unrestricted
+	Scope string `json:"scope,omitempty"`
+	// Type is access or refresh; tokens issued before it existed carry none and count as access tokens
+	Type string `json:"typ,omitempty"`
+	// AuthTime is when the user last entered their password, set on tokens from a login or a
+	// step-up; tokens reissued or refreshed from those carry none
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	// PasswordFingerprint binds a password reset token to the password it replaces, so it works once
+	PasswordFingerprint string `json:"pwf,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// TokenType returns the type of the token, treating an untyped token as an access token
+func (c *JWTClaims) TokenType() string {
+	if c.Type == "" {
+		return TokenTypeAccess
+	}
+	return c.Type
+}
+
 // GenerateJWT generates a JWT token for a user
 func GenerateJWT(userID uuid.UUID, username string, roles []string, cfg *config.Config) (string, time.Time, error) {
-	// Set expiration time
-	expirationTime := time.Now().Add(cfg.GetJWTExpiration())
+	return GenerateScopedJWT(userID, username, roles, "", time.Time{}, cfg)
+}
 
-	// Create claims
+// GenerateScopedJWT generates an access token for a user restricted to scope, recording authTime as
+// when the user entered their password unless it is zero. Roles beyond JWT_MAX_ROLES are not
+// truncated, which would drop access; the token carries none instead
+func GenerateScopedJWT(userID uuid.UUID, username string, roles []string, scope string, authTime time.Time, cfg *config.Config) (string, time.Time, error) {
 	claims := JWTClaims{
 		UserID:   userID.String(),
 		Username: username,
 		Roles:    roles,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "go-user-api",
-			Subject:   username,
-		},
+		Scope:    scope,
+		Type:     TokenTypeAccess,
+	}
+	if !authTime.IsZero() {
+		claims.AuthTime = jwt.NewNumericDate(authTime)
+	}
+
+	if cfg.JWTMaxRoles > 0 && len(roles) > cfg.JWTMaxRoles {
+		log.Warn().
+			Str("user_id", claims.UserID).
+			Int("roles", len(roles)).
+			Int("max_roles", cfg.JWTMaxRoles).
+			Msg("Omitting roles from access token, checks will look them up")
+
+		claims.Roles = nil
+		claims.RolesOmitted = true
+	}
+
+	return signJWT(claims, cfg.GetJWTExpiration(), cfg)
+}
+
+// GenerateRefreshJWT generates a refresh token for a user; it carries no roles, since the
+// access tokens it obtains are built from the user's current ones
+func GenerateRefreshJWT(userID uuid.UUID, username string, cfg *config.Config) (string, time.Time, error) {
+	return signJWT(JWTClaims{
+		UserID:   userID.String(),
+		Username: username,
+		Type:     TokenTypeRefresh,
+	}, cfg.GetJWTRefreshExpiration(), cfg)
+}
+
+// GeneratePasswordResetJWT generates a password reset token for a user, valid until the password
+// with the given fingerprint is replaced or the token expires
+func GeneratePasswordResetJWT(userID uuid.UUID, username, passwordFingerprint string, cfg *config.Config) (string, time.Time, error) {
+	return signJWT(JWTClaims{
+		UserID:              userID.String(),
+		Username:            username,
+		Type:                TokenTypePasswordReset,
+		PasswordFingerprint: passwordFingerprint,
+	}, cfg.GetPasswordResetTokenExpiration(), cfg)
+}
+
+// signJWT sets the registered claims and signs the token
+func signJWT(claims JWTClaims, lifetime time.Duration, cfg *config.Config) (string, time.Time, error) {
+	// Set expiration time
+	now := time.Now()
+	expirationTime := now.Add(lifetime)
+
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(expirationTime),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		Issuer:    "go-user-api",
+		Subject:   claims.Username,
 	}
 
 	// Create token with claims
@@ -48,8 +134,8 @@ func GenerateJWT(userID uuid.UUID, username string, roles []string, cfg *config.
 	return tokenString, expirationTime, nil
 }
 
-// ParseJWT parses a JWT token
-func ParseJWT(tokenString string, cfg *config.Config) (*JWTClaims, error) {
+// ParseJWT parses a JWT token and checks it is of the expected type
+func ParseJWT(tokenString string, tokenType string, cfg *config.Config) (*JWTClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
 		// Validate the signing method
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -71,6 +157,10 @@ func ParseJWT(tokenString string, cfg *config.Config) (*JWTClaims, error) {
 		return nil, fmt.Errorf("failed to get token claims")
 	}
 
+	if claims.TokenType() != tokenType {
+		return nil, fmt.Errorf("%w: expected %s token, got %s token", ErrWrongTokenType, tokenType, claims.TokenType())
+	}
+
 	return claims, nil
 }
 
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/chats/go-user-api/config"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
@@ -29,7 +30,7 @@ func TestGenerateAndParseJWT(t *testing.T) {
 	assert.True(t, expirationTime.Before(time.Now().Add(time.Hour+time.Minute)))
 
 	// ทดสอบ Parse JWT
-	claims, err := ParseJWT(tokenString, cfg)
+	claims, err := ParseJWT(tokenString, TokenTypeAccess, cfg)
 	assert.NoError(t, err)
 	assert.Equal(t, userID.String(), claims.UserID)
 	assert.Equal(t, username, claims.Username)
@@ -90,7 +91,7 @@ func TestParseJWTWithInvalidToken(t *testing.T) {
 	}
 
 	// ทดสอบกับ token ที่ไม่ถูกต้อง
-	_, err := ParseJWT("invalid.token.string", cfg)
+	_, err := ParseJWT("invalid.token.string", TokenTypeAccess, cfg)
 	assert.Error(t, err)
 
 	// ทดสอบกับ token ที่หมดอายุ
@@ -104,6 +105,59 @@ func TestParseJWTWithInvalidToken(t *testing.T) {
 	expiredTokenString, _, err := GenerateJWT(userID, "expireduser", []string{"user"}, expiredCfg)
 	assert.NoError(t, err)
 
-	_, err = ParseJWT(expiredTokenString, cfg)
+	_, err = ParseJWT(expiredTokenString, TokenTypeAccess, cfg)
 	assert.Error(t, err)
 }
+
+func TestParseJWTTokenType(t *testing.T) {
+	cfg := &config.Config{
+		JWTSecret:              "test-secret-key",
+		JWTExpireMinute:        60,
+		JWTRefreshExpireMinute: 120,
+	}
+	userID := uuid.New()
+
+	accessToken, _, err := GenerateJWT(userID, "testuser", []string{"admin"}, cfg)
+	assert.NoError(t, err)
+	refreshToken, expiresAt, err := GenerateRefreshJWT(userID, "testuser", cfg)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(120*time.Minute), expiresAt, time.Minute)
+
+	t.Run("Each type parses as itself", func(t *testing.T) {
+		claims, err := ParseJWT(accessToken, TokenTypeAccess, cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, TokenTypeAccess, claims.Type)
+
+		claims, err = ParseJWT(refreshToken, TokenTypeRefresh, cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, TokenTypeRefresh, claims.Type)
+		assert.Equal(t, userID.String(), claims.UserID)
+		assert.Empty(t, claims.Roles)
+	})
+
+	t.Run("Refresh token rejected as access token", func(t *testing.T) {
+		_, err := ParseJWT(refreshToken, TokenTypeAccess, cfg)
+		assert.ErrorIs(t, err, ErrWrongTokenType)
+		assert.Contains(t, err.Error(), "expected access token, got refresh token")
+	})
+
+	t.Run("Access token rejected as refresh token", func(t *testing.T) {
+		_, err := ParseJWT(accessToken, TokenTypeRefresh, cfg)
+		assert.ErrorIs(t, err, ErrWrongTokenType)
+	})
+
+	t.Run("Untyped token counts as access token", func(t *testing.T) {
+		legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, JWTClaims{
+			UserID: userID.String(),
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+		}).SignedString([]byte(cfg.JWTSecret))
+		assert.NoError(t, err)
+
+		_, err = ParseJWT(legacy, TokenTypeAccess, cfg)
+		assert.NoError(t, err)
+		_, err = ParseJWT(legacy, TokenTypeRefresh, cfg)
+		assert.ErrorIs(t, err, ErrWrongTokenType)
+	})
+}
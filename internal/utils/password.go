@@ -1,8 +1,11 @@
 package utils
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 
 	"golang.org/x/crypto/bcrypt"
@@ -30,17 +33,55 @@ func GenerateRandomPassword(length int) (string, error) {
 	return password, nil
 }
 
-// HashPassword creates a bcrypt hash of the password
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+// PasswordFingerprint returns a short digest of a stored password hash. A token carrying it is only
+// valid while the password is unchanged, without revealing anything about the hash.
+func PasswordFingerprint(hash string) string {
+	sum := sha256.Sum256([]byte(hash))
+	return hex.EncodeToString(sum[:8])
+}
+
+// HashPassword creates a bcrypt hash of the password. With peppers, the password is first combined
+// with the first (newest) one.
+func HashPassword(password string, peppers ...string) (string, error) {
+	input := []byte(password)
+	if len(peppers) > 0 {
+		input = pepperPassword(password, peppers[0])
+	}
+
+	bytes, err := bcrypt.GenerateFromPassword(input, bcrypt.DefaultCost)
 	if err != nil {
 		return "", fmt.Errorf("failed to hash password: %w", err)
 	}
 	return string(bytes), nil
 }
 
-// CheckPassword checks if the provided password matches the stored hash
-func CheckPassword(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+// CheckPassword checks if the provided password matches the stored hash, trying each pepper
+func CheckPassword(password, hash string, peppers ...string) bool {
+	match, _ := VerifyPassword(password, hash, peppers)
+	return match
+}
+
+// VerifyPassword checks the password against the stored hash with each pepper, newest first, then
+// without one for hashes stored before peppering was enabled. rehash reports that the password
+// matched but not with the newest pepper, so the hash should be replaced.
+func VerifyPassword(password, hash string, peppers []string) (match, rehash bool) {
+	for i, pepper := range peppers {
+		if bcrypt.CompareHashAndPassword([]byte(hash), pepperPassword(password, pepper)) == nil {
+			return true, i > 0
+		}
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+		return true, len(peppers) > 0
+	}
+
+	return false, false
+}
+
+// pepperPassword combines the password with a pepper as base64 HMAC-SHA256, which also keeps
+// long passwords within bcrypt's 72-byte input limit
+func pepperPassword(password, pepper string) []byte {
+	mac := hmac.New(sha256.New, []byte(pepper))
+	mac.Write([]byte(password))
+	return []byte(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
 }
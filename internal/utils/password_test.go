@@ -57,3 +57,56 @@ func TestHashPasswordAndCheckPassword(t *testing.T) {
 	isValid = CheckPassword(plainPassword, hashedPassword2)
 	assert.True(t, isValid, "Password check should return true for correct password with different hash")
 }
+
+func TestHashPasswordWithPepper(t *testing.T) {
+	plainPassword := "secureP@ssw0rd"
+
+	t.Run("Pepper off", func(t *testing.T) {
+		hashedPassword, err := HashPassword(plainPassword)
+		assert.NoError(t, err)
+
+		match, rehash := VerifyPassword(plainPassword, hashedPassword, nil)
+		assert.True(t, match)
+		assert.False(t, rehash)
+	})
+
+	t.Run("Pepper on", func(t *testing.T) {
+		hashedPassword, err := HashPassword(plainPassword, "pepper-1")
+		assert.NoError(t, err)
+
+		assert.True(t, CheckPassword(plainPassword, hashedPassword, "pepper-1"))
+		assert.False(t, CheckPassword("wrongPassword", hashedPassword, "pepper-1"))
+
+		// The hash alone, without the pepper, does not verify the password
+		assert.False(t, CheckPassword(plainPassword, hashedPassword))
+		assert.False(t, CheckPassword(plainPassword, hashedPassword, "other-pepper"))
+	})
+
+	t.Run("Rotation", func(t *testing.T) {
+		oldHash, err := HashPassword(plainPassword, "pepper-1")
+		assert.NoError(t, err)
+		peppers := []string{"pepper-2", "pepper-1"}
+
+		match, rehash := VerifyPassword(plainPassword, oldHash, peppers)
+		assert.True(t, match)
+		assert.True(t, rehash, "A hash made with an older pepper should be replaced")
+
+		newHash, err := HashPassword(plainPassword, peppers...)
+		assert.NoError(t, err)
+		match, rehash = VerifyPassword(plainPassword, newHash, peppers)
+		assert.True(t, match)
+		assert.False(t, rehash)
+
+		match, _ = VerifyPassword("wrongPassword", oldHash, peppers)
+		assert.False(t, match)
+	})
+
+	t.Run("Unpeppered hash after enabling a pepper", func(t *testing.T) {
+		legacyHash, err := HashPassword(plainPassword)
+		assert.NoError(t, err)
+
+		match, rehash := VerifyPassword(plainPassword, legacyHash, []string{"pepper-1"})
+		assert.True(t, match)
+		assert.True(t, rehash)
+	})
+}